@@ -0,0 +1,292 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package acme obtains certificates from ACME servers, like Let's Encrypt,
+// validating the domains with HTTP-01 challenges answered by the tsuru API.
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	xacme "golang.org/x/crypto/acme"
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	ErrDisabled          = errors.New("ACME is not configured, please set acme:directory-url")
+	ErrChallengeNotFound = errors.New("challenge not found")
+)
+
+// Challenger routes the requests made by the ACME server to validate the
+// HTTP-01 challenges of a domain to the tsuru API.
+type Challenger interface {
+	// Present starts sending the challenge requests of the domain to
+	// tsuru.
+	Present(domain string) error
+
+	// CleanUp stops sending the challenge requests of the domain to
+	// tsuru.
+	CleanUp(domain string) error
+}
+
+type challenge struct {
+	Token            string `bson:"_id"`
+	Domain           string
+	KeyAuthorization string
+	CreatedAt        time.Time
+}
+
+type account struct {
+	DirectoryURL string `bson:"_id"`
+	Key          string
+}
+
+// Enabled reports whether tsuru is configured to obtain certificates from an
+// ACME server.
+func Enabled() bool {
+	directoryURL, _ := config.GetString("acme:directory-url")
+	return directoryURL != ""
+}
+
+// RenewBefore returns how long before its expiration a certificate should be
+// renewed, configured in days by acme:renew-before and defaulting to 30 days.
+func RenewBefore() time.Duration {
+	days, err := config.GetInt("acme:renew-before")
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Obtain requests a certificate for the domain to the ACME server. The key
+// authorizations of the challenges are stored in the database while they're
+// validated, so any tsuru API instance is able to answer the requests of the
+// server, and the challenger routes these requests to tsuru. It returns the
+// PEM encoded certificate chain and private key.
+func Obtain(ctx context.Context, domain string, challenger Challenger) (string, string, error) {
+	client, err := newClient(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	order, err := client.AuthorizeOrder(ctx, xacme.DomainIDs(domain))
+	if err != nil {
+		return "", "", err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		var authz *xacme.Authorization
+		authz, err = client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return "", "", err
+		}
+		if authz.Status != xacme.StatusPending {
+			continue
+		}
+		err = authorize(ctx, client, authz, challenger)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domain},
+		DNSNames: []string{domain},
+	}, key)
+	if err != nil {
+		return "", "", err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", err
+	}
+	var certificate []byte
+	for _, der := range chain {
+		certificate = append(certificate, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return string(certificate), keyPEM, nil
+}
+
+// authorize fulfills the HTTP-01 challenge of the pending authorization,
+// waiting for the server to validate it.
+func authorize(ctx context.Context, client *xacme.Client, authz *xacme.Authorization, challenger Challenger) error {
+	domain := authz.Identifier.Value
+	var chal *xacme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("the ACME server offered no http-01 challenge for %q", domain)
+	}
+	keyAuthorization, err := client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return err
+	}
+	err = storeChallenge(chal.Token, domain, keyAuthorization)
+	if err != nil {
+		return err
+	}
+	defer removeChallenge(chal.Token)
+	err = challenger.Present(domain)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cleanErr := challenger.CleanUp(domain); cleanErr != nil {
+			log.Errorf("[acme] unable to clean up the challenge of %q: %s", domain, cleanErr)
+		}
+	}()
+	_, err = client.Accept(ctx, chal)
+	if err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+// KeyAuthorization returns the response to the HTTP-01 challenge identified
+// by the token.
+func KeyAuthorization(token string) (string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	var chal challenge
+	err = conn.ACMEChallenges().FindId(token).One(&chal)
+	if err == mgo.ErrNotFound {
+		return "", ErrChallengeNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return chal.KeyAuthorization, nil
+}
+
+func storeChallenge(token, domain, keyAuthorization string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ACMEChallenges().UpsertId(token, challenge{
+		Token:            token,
+		Domain:           domain,
+		KeyAuthorization: keyAuthorization,
+		CreatedAt:        time.Now().UTC(),
+	})
+	return err
+}
+
+func removeChallenge(token string) {
+	conn, err := db.Conn()
+	if err != nil {
+		log.Errorf("[acme] unable to remove challenge %q: %s", token, err)
+		return
+	}
+	defer conn.Close()
+	err = conn.ACMEChallenges().RemoveId(token)
+	if err != nil && err != mgo.ErrNotFound {
+		log.Errorf("[acme] unable to remove challenge %q: %s", token, err)
+	}
+}
+
+// newClient returns a client of the configured ACME server, registered with
+// the account shared by all tsuru API instances.
+func newClient(ctx context.Context) (*xacme.Client, error) {
+	directoryURL, _ := config.GetString("acme:directory-url")
+	if directoryURL == "" {
+		return nil, ErrDisabled
+	}
+	key, err := accountKey(directoryURL)
+	if err != nil {
+		return nil, err
+	}
+	client := &xacme.Client{Key: key, DirectoryURL: directoryURL, UserAgent: "tsuru"}
+	var acct xacme.Account
+	if email, _ := config.GetString("acme:email"); email != "" {
+		acct.Contact = []string{"mailto:" + email}
+	}
+	_, err = client.Register(ctx, &acct, xacme.AcceptTOS)
+	if err != nil && err != xacme.ErrAccountAlreadyExists {
+		return nil, err
+	}
+	return client, nil
+}
+
+// accountKey returns the key of the account in the ACME server, generating
+// it in the first use of the server.
+func accountKey(directoryURL string) (crypto.Signer, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var acct account
+	err = conn.ACMEAccounts().FindId(directoryURL).One(&acct)
+	if err == nil {
+		return decodeKey(acct.Key)
+	}
+	if err != mgo.ErrNotFound {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.ACMEAccounts().Insert(account{DirectoryURL: directoryURL, Key: keyPEM})
+	if mgo.IsDup(err) {
+		// another instance created the account first
+		return accountKey(directoryURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+func decodeKey(keyPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("invalid ACME account key")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
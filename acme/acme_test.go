@@ -0,0 +1,118 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	xacme "golang.org/x/crypto/acme"
+	"gopkg.in/check.v1"
+)
+
+type fakeChallenger struct {
+	present []string
+	cleanUp []string
+	err     error
+}
+
+func (f *fakeChallenger) Present(domain string) error {
+	f.present = append(f.present, domain)
+	return f.err
+}
+
+func (f *fakeChallenger) CleanUp(domain string) error {
+	f.cleanUp = append(f.cleanUp, domain)
+	return nil
+}
+
+func (s *S) TestEnabled(c *check.C) {
+	c.Assert(Enabled(), check.Equals, true)
+	config.Unset("acme:directory-url")
+	c.Assert(Enabled(), check.Equals, false)
+}
+
+func (s *S) TestRenewBefore(c *check.C) {
+	c.Assert(RenewBefore(), check.Equals, 30*24*time.Hour)
+	config.Set("acme:renew-before", 10)
+	c.Assert(RenewBefore(), check.Equals, 10*24*time.Hour)
+}
+
+func (s *S) TestObtain(c *check.C) {
+	s.server.Resolve("myapp.io", s.challengeServer.Listener.Addr().String())
+	challenger := &fakeChallenger{}
+	certificate, key, err := Obtain(context.Background(), "myapp.io", challenger)
+	c.Assert(err, check.IsNil)
+	c.Assert(challenger.present, check.DeepEquals, []string{"myapp.io"})
+	c.Assert(challenger.cleanUp, check.DeepEquals, []string{"myapp.io"})
+	keyPair, err := tls.X509KeyPair([]byte(certificate), []byte(key))
+	c.Assert(err, check.IsNil)
+	c.Assert(keyPair.Certificate, check.HasLen, 2)
+	leaf, err := x509.ParseCertificate(keyPair.Certificate[0])
+	c.Assert(err, check.IsNil)
+	roots := x509.NewCertPool()
+	roots.AddCert(s.server.CACertificate)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "myapp.io", Roots: roots})
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	count, err := conn.ACMEChallenges().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestObtainReusesAccount(c *check.C) {
+	s.server.Resolve("myapp.io", s.challengeServer.Listener.Addr().String())
+	_, _, err := Obtain(context.Background(), "myapp.io", &fakeChallenger{})
+	c.Assert(err, check.IsNil)
+	_, _, err = Obtain(context.Background(), "myapp.io", &fakeChallenger{})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.server.Issued(), check.HasLen, 2)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	count, err := conn.ACMEAccounts().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+}
+
+func (s *S) TestObtainChallengeNotRouted(c *check.C) {
+	challenger := &fakeChallenger{}
+	_, _, err := Obtain(context.Background(), "myapp.io", challenger)
+	c.Assert(err, check.FitsTypeOf, &xacme.AuthorizationError{})
+	c.Assert(challenger.cleanUp, check.DeepEquals, []string{"myapp.io"})
+	c.Assert(s.server.Issued(), check.HasLen, 0)
+}
+
+func (s *S) TestObtainPresentFailure(c *check.C) {
+	s.server.Resolve("myapp.io", s.challengeServer.Listener.Addr().String())
+	challenger := &fakeChallenger{err: errors.New("router unavailable")}
+	_, _, err := Obtain(context.Background(), "myapp.io", challenger)
+	c.Assert(err, check.ErrorMatches, "router unavailable")
+	c.Assert(challenger.cleanUp, check.HasLen, 0)
+}
+
+func (s *S) TestObtainDisabled(c *check.C) {
+	config.Unset("acme:directory-url")
+	_, _, err := Obtain(context.Background(), "myapp.io", &fakeChallenger{})
+	c.Assert(err, check.Equals, ErrDisabled)
+}
+
+func (s *S) TestKeyAuthorization(c *check.C) {
+	err := storeChallenge("token1", "myapp.io", "token1.thumbprint")
+	c.Assert(err, check.IsNil)
+	keyAuthorization, err := KeyAuthorization("token1")
+	c.Assert(err, check.IsNil)
+	c.Assert(keyAuthorization, check.Equals, "token1.thumbprint")
+	removeChallenge("token1")
+	_, err = KeyAuthorization("token1")
+	c.Assert(err, check.Equals, ErrChallengeNotFound)
+}
@@ -0,0 +1,749 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package acmetest provides a local ACME server for tests, implementing the
+// subset of RFC 8555 used to obtain certificates with HTTP-01 challenges.
+package acmetest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an ACME server issuing certificates signed by its own CA. The
+// HTTP-01 challenges are validated synchronously, when the client accepts
+// them, by requesting the challenge path in port 80 of the domain. Resolve
+// makes the server send the requests of a domain to another address, as the
+// domains used in tests usually don't exist.
+type Server struct {
+	// URL is the base URL of the server.
+	URL string
+
+	// Validity is the validity of the issued certificates, defaulting to
+	// 90 days.
+	Validity time.Duration
+
+	// CACertificate is the certificate signing the issued certificates.
+	CACertificate *x509.Certificate
+
+	server       *httptest.Server
+	httpClient   *http.Client
+	caKey        *ecdsa.PrivateKey
+	mu           sync.Mutex
+	nextID       int
+	addresses    map[string]string
+	accounts     map[string]*account
+	orders       map[string]*order
+	authzs       map[string]*authorization
+	certificates map[string][]byte
+	issued       []*x509.Certificate
+}
+
+type account struct {
+	url        string
+	key        crypto.PublicKey
+	thumbprint string
+	contact    []string
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type order struct {
+	id             string
+	account        string
+	status         string
+	identifiers    []identifier
+	authorizations []string
+	certificate    string
+}
+
+type authorization struct {
+	id         string
+	account    string
+	status     string
+	identifier identifier
+	token      string
+	chalStatus string
+	chalError  string
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	E   string `json:"e"`
+	N   string `json:"n"`
+}
+
+type protectedHeader struct {
+	Alg   string `json:"alg"`
+	JWK   *jwk   `json:"jwk"`
+	KID   string `json:"kid"`
+	Nonce string `json:"nonce"`
+	URL   string `json:"url"`
+}
+
+type request struct {
+	header  protectedHeader
+	account *account
+	key     crypto.PublicKey
+	payload []byte
+}
+
+type problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+// NewServer starts an ACME server, which must be closed after use.
+func NewServer() (*Server, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tsuru ACME test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, caKey.Public(), caKey)
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Validity:      90 * 24 * time.Hour,
+		CACertificate: caCert,
+		caKey:         caKey,
+		addresses:     make(map[string]string),
+		accounts:      make(map[string]*account),
+		orders:        make(map[string]*order),
+		authzs:        make(map[string]*authorization),
+		certificates:  make(map[string][]byte),
+	}
+	s.httpClient = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: s.dial,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s, nil
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// DirectoryURL returns the URL of the directory of the server, used to
+// configure ACME clients.
+func (s *Server) DirectoryURL() string {
+	return s.URL + "/directory"
+}
+
+// Resolve sends the challenge requests of the domain to the address, in the
+// host:port form.
+func (s *Server) Resolve(domain, address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addresses[domain] = address
+}
+
+// Issued returns the certificates issued by the server.
+func (s *Server) Issued() []*x509.Certificate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*x509.Certificate(nil), s.issued...)
+}
+
+func (s *Server) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if resolved, ok := s.addresses[host]; ok {
+		addr = resolved
+	}
+	s.mu.Unlock()
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, addr)
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return strconv.Itoa(s.nextID)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", randomString())
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Path == "/directory" {
+		s.directory(w)
+		return
+	}
+	if r.URL.Path == "/new-nonce" {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+	if r.Method != "POST" {
+		writeProblem(w, http.StatusMethodNotAllowed, "malformed", "method not allowed")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, err := s.parseRequest(r)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	if r.URL.Path == "/new-account" {
+		s.newAccount(w, req)
+		return
+	}
+	if req.account == nil {
+		writeProblem(w, http.StatusBadRequest, "accountDoesNotExist", "the request must be signed by an account")
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) == 1 && parts[0] == "new-order" {
+		s.newOrder(w, req)
+		return
+	}
+	if len(parts) != 2 {
+		writeProblem(w, http.StatusNotFound, "malformed", "not found")
+		return
+	}
+	switch parts[0] {
+	case "order":
+		s.getOrder(w, req, parts[1])
+	case "authz":
+		s.getAuthorization(w, req, parts[1])
+	case "challenge":
+		s.acceptChallenge(w, req, parts[1])
+	case "finalize":
+		s.finalize(w, req, parts[1])
+	case "cert":
+		s.getCertificate(w, req, parts[1])
+	default:
+		writeProblem(w, http.StatusNotFound, "malformed", "not found")
+	}
+}
+
+func (s *Server) directory(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"newNonce":   s.URL + "/new-nonce",
+		"newAccount": s.URL + "/new-account",
+		"newOrder":   s.URL + "/new-order",
+		"revokeCert": s.URL + "/revoke-cert",
+		"keyChange":  s.URL + "/key-change",
+	})
+}
+
+// parseRequest decodes the JWS in the body of the request, checking its
+// signature with the key of the account or with the embedded key.
+func (s *Server) parseRequest(r *http.Request) (*request, error) {
+	var body struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(body.Protected)
+	if err != nil {
+		return nil, err
+	}
+	var req request
+	err = json.Unmarshal(rawHeader, &req.header)
+	if err != nil {
+		return nil, err
+	}
+	if req.header.Nonce == "" {
+		return nil, errors.New("missing nonce")
+	}
+	if req.header.URL != s.URL+r.URL.Path {
+		return nil, fmt.Errorf("the url in the header, %q, doesn't match the request", req.header.URL)
+	}
+	if req.header.KID != "" {
+		req.account = s.accounts[req.header.KID]
+		if req.account == nil {
+			return nil, fmt.Errorf("unknown account %q", req.header.KID)
+		}
+		req.key = req.account.key
+	} else if req.header.JWK != nil {
+		req.key, err = req.header.JWK.publicKey()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("missing jwk or kid")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(body.Signature)
+	if err != nil {
+		return nil, err
+	}
+	err = verify(req.header.Alg, req.key, []byte(body.Protected+"."+body.Payload), signature)
+	if err != nil {
+		return nil, err
+	}
+	req.payload, err = base64.RawURLEncoding.DecodeString(body.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func verify(alg string, key crypto.PublicKey, data, signature []byte) error {
+	hash := sha256.Sum256(data)
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(signature) != 64 {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		ss := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, hash[:], r, ss) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return errors.New("invalid signature")
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature)
+	}
+	return errors.New("unsupported key")
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// thumbprint returns the JWK thumbprint of the key, as defined in RFC 7638.
+func (k *jwk) thumbprint() string {
+	var canonical string
+	if k.Kty == "RSA" {
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	} else {
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Crv, k.Kty, k.X, k.Y)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (s *Server) newAccount(w http.ResponseWriter, req *request) {
+	if req.header.JWK == nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", "new accounts must be signed with a jwk")
+		return
+	}
+	var payload struct {
+		Contact            []string `json:"contact"`
+		OnlyReturnExisting bool     `json:"onlyReturnExisting"`
+	}
+	if len(req.payload) > 0 {
+		err := json.Unmarshal(req.payload, &payload)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+			return
+		}
+	}
+	thumbprint := req.header.JWK.thumbprint()
+	for _, acct := range s.accounts {
+		if acct.thumbprint == thumbprint {
+			writeJSON(w, http.StatusOK, acct.url, acct.json())
+			return
+		}
+	}
+	if payload.OnlyReturnExisting {
+		writeProblem(w, http.StatusBadRequest, "accountDoesNotExist", "account does not exist")
+		return
+	}
+	acct := &account{
+		url:        s.URL + "/account/" + s.newID(),
+		key:        req.key,
+		thumbprint: thumbprint,
+		contact:    payload.Contact,
+	}
+	s.accounts[acct.url] = acct
+	writeJSON(w, http.StatusCreated, acct.url, acct.json())
+}
+
+func (a *account) json() map[string]interface{} {
+	return map[string]interface{}{"status": "valid", "contact": a.contact}
+}
+
+func (s *Server) newOrder(w http.ResponseWriter, req *request) {
+	var payload struct {
+		Identifiers []identifier `json:"identifiers"`
+	}
+	err := json.Unmarshal(req.payload, &payload)
+	if err != nil || len(payload.Identifiers) == 0 {
+		writeProblem(w, http.StatusBadRequest, "malformed", "the order must have identifiers")
+		return
+	}
+	o := &order{
+		id:          s.newID(),
+		account:     req.account.url,
+		status:      "pending",
+		identifiers: payload.Identifiers,
+	}
+	for _, id := range payload.Identifiers {
+		if id.Type != "dns" {
+			writeProblem(w, http.StatusBadRequest, "unsupportedIdentifier", "only dns identifiers are supported")
+			return
+		}
+		authz := &authorization{
+			id:         s.newID(),
+			account:    req.account.url,
+			status:     "pending",
+			identifier: id,
+			token:      randomString(),
+			chalStatus: "pending",
+		}
+		s.authzs[authz.id] = authz
+		o.authorizations = append(o.authorizations, s.URL+"/authz/"+authz.id)
+	}
+	s.orders[o.id] = o
+	writeJSON(w, http.StatusCreated, s.orderURL(o), s.orderJSON(o))
+}
+
+func (s *Server) orderURL(o *order) string {
+	return s.URL + "/order/" + o.id
+}
+
+func (s *Server) orderJSON(o *order) map[string]interface{} {
+	result := map[string]interface{}{
+		"status":         o.status,
+		"identifiers":    o.identifiers,
+		"authorizations": o.authorizations,
+		"finalize":       s.URL + "/finalize/" + o.id,
+	}
+	if o.certificate != "" {
+		result["certificate"] = s.URL + "/cert/" + o.certificate
+	}
+	return result
+}
+
+// updateOrder moves the order to ready when all its authorizations are valid
+// or to invalid when any of them is invalid.
+func (s *Server) updateOrder(o *order) {
+	if o.status != "pending" {
+		return
+	}
+	ready := true
+	for _, authzURL := range o.authorizations {
+		authz := s.authzs[strings.TrimPrefix(authzURL, s.URL+"/authz/")]
+		switch authz.status {
+		case "invalid":
+			o.status = "invalid"
+			return
+		case "pending":
+			ready = false
+		}
+	}
+	if ready {
+		o.status = "ready"
+	}
+}
+
+func (s *Server) findOrder(w http.ResponseWriter, req *request, id string) *order {
+	o, ok := s.orders[id]
+	if !ok || o.account != req.account.url {
+		writeProblem(w, http.StatusNotFound, "malformed", "order not found")
+		return nil
+	}
+	s.updateOrder(o)
+	return o
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, req *request, id string) {
+	o := s.findOrder(w, req, id)
+	if o != nil {
+		writeJSON(w, http.StatusOK, s.orderURL(o), s.orderJSON(o))
+	}
+}
+
+func (s *Server) findAuthorization(w http.ResponseWriter, req *request, id string) *authorization {
+	authz, ok := s.authzs[id]
+	if !ok || authz.account != req.account.url {
+		writeProblem(w, http.StatusNotFound, "malformed", "authorization not found")
+		return nil
+	}
+	return authz
+}
+
+func (s *Server) getAuthorization(w http.ResponseWriter, req *request, id string) {
+	authz := s.findAuthorization(w, req, id)
+	if authz != nil {
+		writeJSON(w, http.StatusOK, "", s.authorizationJSON(authz))
+	}
+}
+
+func (s *Server) authorizationJSON(authz *authorization) map[string]interface{} {
+	return map[string]interface{}{
+		"status":     authz.status,
+		"identifier": authz.identifier,
+		"challenges": []interface{}{s.challengeJSON(authz)},
+	}
+}
+
+func (s *Server) challengeJSON(authz *authorization) map[string]interface{} {
+	result := map[string]interface{}{
+		"type":   "http-01",
+		"url":    s.URL + "/challenge/" + authz.id,
+		"token":  authz.token,
+		"status": authz.chalStatus,
+	}
+	if authz.chalError != "" {
+		result["error"] = problem{
+			Type:   "urn:ietf:params:acme:error:unauthorized",
+			Detail: authz.chalError,
+			Status: http.StatusForbidden,
+		}
+	}
+	return result
+}
+
+// acceptChallenge validates the HTTP-01 challenge of the authorization,
+// fetching the key authorization from the domain.
+func (s *Server) acceptChallenge(w http.ResponseWriter, req *request, id string) {
+	authz := s.findAuthorization(w, req, id)
+	if authz == nil {
+		return
+	}
+	if authz.chalStatus == "pending" {
+		expected := authz.token + "." + req.account.thumbprint
+		url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", authz.identifier.Value, authz.token)
+		s.mu.Unlock()
+		err := s.validate(url, expected)
+		s.mu.Lock()
+		if err != nil {
+			authz.chalStatus = "invalid"
+			authz.chalError = err.Error()
+			authz.status = "invalid"
+		} else {
+			authz.chalStatus = "valid"
+			authz.status = "valid"
+		}
+	}
+	writeJSON(w, http.StatusOK, "", s.challengeJSON(authz))
+}
+
+func (s *Server) validate(url, expected string) error {
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if got := strings.TrimSpace(string(body)); got != expected {
+		return fmt.Errorf("%s returned %q, expected %q", url, got, expected)
+	}
+	return nil
+}
+
+func (s *Server) finalize(w http.ResponseWriter, req *request, id string) {
+	o := s.findOrder(w, req, id)
+	if o == nil {
+		return
+	}
+	if o.status != "ready" {
+		writeProblem(w, http.StatusForbidden, "orderNotReady", fmt.Sprintf("the order is %s", o.status))
+		return
+	}
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	err := json.Unmarshal(req.payload, &payload)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	der, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "badCSR", err.Error())
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err == nil {
+		err = csr.CheckSignature()
+	}
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "badCSR", err.Error())
+		return
+	}
+	if !sameNames(csr.DNSNames, o.identifiers) {
+		writeProblem(w, http.StatusBadRequest, "badCSR", "the names in the CSR don't match the order")
+		return
+	}
+	certificate, err := s.sign(csr)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "serverInternal", err.Error())
+		return
+	}
+	o.certificate = s.newID()
+	o.status = "valid"
+	s.certificates[o.certificate] = certificate
+	writeJSON(w, http.StatusOK, s.orderURL(o), s.orderJSON(o))
+}
+
+func sameNames(names []string, identifiers []identifier) bool {
+	if len(names) != len(identifiers) {
+		return false
+	}
+	expected := make(map[string]bool, len(identifiers))
+	for _, id := range identifiers {
+		expected[id.Value] = true
+	}
+	for _, name := range names {
+		if !expected[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// sign issues a certificate for the request, returning the PEM encoded
+// chain.
+func (s *Server) sign(csr *x509.CertificateRequest) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(s.Validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.CACertificate, csr.PublicKey, s.caKey)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	s.issued = append(s.issued, leaf)
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.CACertificate.Raw})...)
+	return chain, nil
+}
+
+func (s *Server) getCertificate(w http.ResponseWriter, req *request, id string) {
+	certificate, ok := s.certificates[id]
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "malformed", "certificate not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Write(certificate)
+}
+
+func writeJSON(w http.ResponseWriter, status int, location string, v interface{}) {
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeProblem(w http.ResponseWriter, status int, errType, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{
+		Type:   "urn:ietf:params:acme:error:" + errType,
+		Detail: detail,
+		Status: status,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
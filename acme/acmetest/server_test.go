@@ -0,0 +1,145 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acmetest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	server *Server
+	client *acme.Client
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpTest(c *check.C) {
+	var err error
+	s.server, err = NewServer()
+	c.Assert(err, check.IsNil)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, check.IsNil)
+	s.client = &acme.Client{Key: key, DirectoryURL: s.server.DirectoryURL()}
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+// challengeServer starts a server answering the HTTP-01 challenges of the
+// domain with the responses computed by the client.
+func (s *S) challengeServer(c *check.C, domain string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+		response, err := s.client.HTTP01ChallengeResponse(token)
+		c.Check(err, check.IsNil)
+		fmt.Fprint(w, response)
+	}))
+	s.server.Resolve(domain, srv.Listener.Addr().String())
+	return srv
+}
+
+func (s *S) obtain(ctx context.Context, domain string) ([][]byte, error) {
+	order, err := s.client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return nil, err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := s.client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.client.Accept(ctx, authz.Challenges[0])
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.client.WaitAuthorization(ctx, authzURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	order, err = s.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{domain}}, key)
+	if err != nil {
+		return nil, err
+	}
+	chain, _, err := s.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	return chain, err
+}
+
+func (s *S) TestRegister(c *check.C) {
+	ctx := context.Background()
+	acct, err := s.client.Register(ctx, &acme.Account{Contact: []string{"mailto:admin@example.com"}}, acme.AcceptTOS)
+	c.Assert(err, check.IsNil)
+	c.Assert(acct.URI, check.Matches, s.server.URL+"/account/.*")
+	c.Assert(acct.Status, check.Equals, acme.StatusValid)
+	_, err = s.client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	c.Assert(err, check.Equals, acme.ErrAccountAlreadyExists)
+}
+
+func (s *S) TestObtainCertificate(c *check.C) {
+	srv := s.challengeServer(c, "myapp.io")
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := s.client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	c.Assert(err, check.IsNil)
+	chain, err := s.obtain(ctx, "myapp.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(chain, check.HasLen, 2)
+	leaf, err := x509.ParseCertificate(chain[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(leaf.DNSNames, check.DeepEquals, []string{"myapp.io"})
+	roots := x509.NewCertPool()
+	roots.AddCert(s.server.CACertificate)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "myapp.io", Roots: roots})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.server.Issued(), check.HasLen, 1)
+}
+
+func (s *S) TestObtainCertificateInvalidChallenge(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "wrong")
+	}))
+	defer srv.Close()
+	s.server.Resolve("myapp.io", srv.Listener.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := s.client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	c.Assert(err, check.IsNil)
+	_, err = s.obtain(ctx, "myapp.io")
+	c.Assert(err, check.FitsTypeOf, &acme.AuthorizationError{})
+	c.Assert(err, check.ErrorMatches, `.*returned "wrong".*`)
+	c.Assert(s.server.Issued(), check.HasLen, 0)
+}
+
+func (s *S) TestObtainCertificateWithoutAccount(c *check.C) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := s.client.AuthorizeOrder(ctx, acme.DomainIDs("myapp.io"))
+	c.Assert(err, check.NotNil)
+}
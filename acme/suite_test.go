@@ -0,0 +1,64 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/acme/acmetest"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	server          *acmetest.Server
+	challengeServer *httptest.Server
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_acme_tests")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = dbtest.ClearAllCollections(conn.ACMEChallenges().Database)
+	c.Assert(err, check.IsNil)
+	s.server, err = acmetest.NewServer()
+	c.Assert(err, check.IsNil)
+	config.Set("acme:directory-url", s.server.DirectoryURL())
+	s.challengeServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyAuthorization, err := KeyAuthorization(strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(keyAuthorization))
+	}))
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+	s.challengeServer.Close()
+	config.Unset("acme")
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.ACMEChallenges().Database.DropDatabase()
+}
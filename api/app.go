@@ -21,6 +21,7 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
@@ -28,6 +29,7 @@ import (
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/rec"
 	"github.com/tsuru/tsuru/repository"
+	"github.com/tsuru/tsuru/secret"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -45,6 +47,10 @@ func getAppFromContext(name string, r *http.Request) (app.App, error) {
 	return *a, nil
 }
 
+func appTarget(appName string) event.Target {
+	return event.Target{Type: event.TargetTypeApp, Value: appName}
+}
+
 func getApp(name string) (*app.App, error) {
 	a, err := app.GetByName(name)
 	if err != nil {
@@ -62,10 +68,6 @@ func getApp(name string) (*app.App, error) {
 //   401: Unauthorized
 //   404: Not found
 func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
 	if err != nil {
 		return err
@@ -79,12 +81,22 @@ func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !canDelete {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "app-delete", "app="+a.Name)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
 	w.Header().Set("Content-Type", "application/x-json-stream")
-	err = app.Delete(&a, writer)
+	evt.SetLogWriter(writer)
+	err = app.Delete(&a, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 	}
@@ -283,7 +295,16 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			return &errors.HTTP{Code: http.StatusBadRequest, Message: app.InvalidPlatformError.Error()}
 		}
 	}
-	rec.Log(u.Email, "create-app", "app="+a.Name, "platform="+a.Platform, "plan="+a.Plan.Name, "description="+a.Description)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	err = app.CreateApp(&a, u)
 	if err != nil {
 		log.Errorf("Got error while creating app: %s", err)
@@ -404,20 +425,26 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			}
 		}
 	}
-	u, err := t.User()
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
 	if err != nil {
 		return err
 	}
+	defer func() { evt.Done(err) }()
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.Update(updateData, writer)
-	if err == app.ErrPlanNotFound {
+	evt.SetLogWriter(writer)
+	err = a.Update(updateData, evt)
+	if err == app.ErrPlanNotFound || err == app.ErrPoolProvisionerChange {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return err
 	}
-	rec.Log(u.Email, "update-app", "app="+appName, "description="+updateData.Description, "pool="+updateData.Pool)
 	return err
 }
 
@@ -456,10 +483,6 @@ func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	}
 	processName := r.FormValue("process")
 	appName := r.URL.Query().Get(":app")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -473,12 +496,22 @@ func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "add-units", "app="+appName, fmt.Sprintf("units=%d", n))
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateUnitAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.AddUnits(n, processName, writer)
+	evt.SetLogWriter(writer)
+	err = a.AddUnits(n, processName, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return nil
@@ -500,10 +533,6 @@ func removeUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	processName := r.FormValue("process")
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
@@ -519,12 +548,22 @@ func removeUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "remove-units", "app="+appName, fmt.Sprintf("units=%d", n))
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateUnitRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.RemoveUnits(uint(n), processName, writer)
+	evt.SetLogWriter(writer)
+	err = a.RemoveUnits(uint(n), processName, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return nil
@@ -653,10 +692,6 @@ func setUnitsStatus(w http.ResponseWriter, r *http.Request, t auth.Token) error
 //   404: App or team not found
 //   409: Grant already exists
 func grantAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	teamName := r.URL.Query().Get(":team")
 	team := new(auth.Team)
@@ -673,7 +708,16 @@ func grantAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "grant-app-access", "app="+appName, "team="+teamName)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateGrant,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -699,13 +743,8 @@ func grantAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error
 //   403: Forbidden
 //   404: App or team not found
 func revokeAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	teamName := r.URL.Query().Get(":team")
-	rec.Log(u.Email, "revoke-app-access", "app="+appName, "team="+teamName)
 	team := new(auth.Team)
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -720,6 +759,16 @@ func revokeAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateRevoke,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -751,6 +800,7 @@ func revokeAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error
 // method: POST
 // responses:
 //   200: Ok
+//   400: Invalid data
 //   401: Unauthorized
 //   404: App not found
 func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) error {
@@ -759,12 +809,17 @@ func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if len(command) < 1 {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	once := r.FormValue("once")
+	isolated, _ := strconv.ParseBool(r.FormValue("isolated"))
+	var timeout time.Duration
+	if timeoutStr := r.FormValue("timeout"); timeoutStr != "" {
+		seconds, err := strconv.Atoi(timeoutStr)
+		if err != nil || seconds < 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "timeout must be a non-negative number of seconds"}
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -778,12 +833,39 @@ func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "run-command", "app="+appName, "command="+command)
+	if isolated {
+		if a.Deploys == 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: app.ErrIsolatedRunNotDeployed.Error()}
+		}
+		prov, err := a.GetProvisioner()
+		if err != nil {
+			return err
+		}
+		if _, ok := prov.(provision.IsolatedExecutor); !ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: app.ErrIsolatedRunNotSupported.Error()}
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(appName),
+		Kind:        permission.PermAppRun,
+		Owner:       t,
+		DisableLock: true,
+		CustomData:  event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.Run(command, writer, once == "true")
+	evt.SetLogWriter(writer)
+	if isolated {
+		err = a.RunIsolated(command, evt, timeout)
+	} else {
+		err = a.Run(command, evt, once == "true")
+	}
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return nil
@@ -832,15 +914,19 @@ func getEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 
 func writeEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error {
 	var result []bind.EnvVar
+	envs, err := a.DecryptedEnvs()
+	if err != nil {
+		return err
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if len(variables) > 0 {
 		for _, variable := range variables {
-			if v, ok := a.Env[variable]; ok {
+			if v, ok := envs[variable]; ok {
 				result = append(result, v)
 			}
 		}
 	} else {
-		for _, v := range a.Env {
+		for _, v := range envs {
 			result = append(result, v)
 		}
 	}
@@ -881,11 +967,6 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		msg := "You must provide the list of environment variables"
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
-	extra := fmt.Sprintf("private=%t", e.Private)
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -900,23 +981,44 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	envs := map[string]string{}
 	variables := []bind.EnvVar{}
 	for _, v := range e.Envs {
-		envs[v.Name] = v.Value
+		err = checkSecretReference(t, v.Value)
+		if err != nil {
+			return err
+		}
 		variables = append(variables, bind.EnvVar{Name: v.Name, Value: v.Value, Public: !e.Private})
 	}
-	rec.Log(u.Email, "set-env", "app="+appName, envs, extra)
+	customData := event.FormToCustomData(r.Form)
+	if e.Private {
+		for _, field := range customData {
+			name := strings.ToLower(field["name"].(string))
+			if strings.HasPrefix(name, "envs.") && strings.HasSuffix(name, ".value") {
+				field["value"] = "*****"
+			}
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateEnvSet,
+		Owner:      t,
+		CustomData: customData,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
 	err = a.SetEnvs(
 		bind.SetEnvApp{
 			Envs:          variables,
 			PublicOnly:    true,
 			ShouldRestart: !e.NoRestart,
-		}, writer)
+		}, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return nil
@@ -924,6 +1026,27 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return nil
 }
 
+// checkSecretReference ensures that the user can set envs in the context of
+// the team owning the secret referenced by value. Apps can only resolve the
+// secrets of their team owner, checking the user prevents an app transferred
+// to another team from getting references to the secrets of that team.
+func checkSecretReference(t auth.Token, value string) error {
+	if !secret.IsReference(value) {
+		return nil
+	}
+	ref, err := secret.ParseReference(value)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if !permission.Check(t, permission.PermAppUpdateEnvSet, permission.Context(permission.CtxTeam, ref.Team())) {
+		return &errors.HTTP{
+			Code:    http.StatusForbidden,
+			Message: fmt.Sprintf("you're not allowed to reference secrets of team %q", ref.Team()),
+		}
+	}
+	return nil
+}
+
 // title: unset envs
 // path: /apps/{app}/env
 // method: DELETE
@@ -945,10 +1068,6 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
 	appName := r.URL.Query().Get(":app")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -962,18 +1081,28 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "unset-env", "app="+appName, fmt.Sprintf("envs=%s", variables))
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateEnvUnset,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
 	noRestart, _ := strconv.ParseBool(r.URL.Query().Get("noRestart"))
 	err = a.UnsetEnvs(
 		bind.UnsetEnvApp{
 			VariableNames: variables,
 			PublicOnly:    true,
 			ShouldRestart: !noRestart,
-		}, writer)
+		}, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return nil
@@ -1001,12 +1130,7 @@ func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		msg := "You must provide the cname."
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
-	rec.Log(u.Email, "add-cname", "app="+appName, "cname="+strings.Join(cnames, ", "))
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -1020,6 +1144,16 @@ func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateCnameAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	if err = a.AddCName(cnames...); err == nil {
 		return nil
 	}
@@ -1043,10 +1177,6 @@ func unsetCName(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		msg := "You must provide the cname."
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -1061,7 +1191,16 @@ func unsetCName(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "remove-cname", "app="+appName, "cnames="+strings.Join(cnames, ", "))
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateCnameRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	if err = a.RemoveCName(cnames...); err == nil {
 		return nil
 	}
@@ -1230,24 +1369,34 @@ func bindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) e
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(t.GetUserName(), "bind-app", "instance="+instanceName, "app="+appName)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateBind,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = instance.BindApp(a, !noRestart, writer)
+	evt.SetLogWriter(writer)
+	err = instance.BindApp(a, !noRestart, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return nil
 	}
-	fmt.Fprintf(writer, "\nInstance %q is now bound to the app %q.\n", instanceName, appName)
+	fmt.Fprintf(evt, "\nInstance %q is now bound to the app %q.\n", instanceName, appName)
 	envs := a.InstanceEnv(instanceName)
 	if len(envs) > 0 {
-		fmt.Fprintf(writer, "The following environment variables are available for use in your app:\n\n")
+		fmt.Fprintf(evt, "The following environment variables are available for use in your app:\n\n")
 		for k := range envs {
-			fmt.Fprintf(writer, "- %s\n", k)
+			fmt.Fprintf(evt, "- %s\n", k)
 		}
-		fmt.Fprintf(writer, "- %s\n", app.TsuruServicesEnvVar)
+		fmt.Fprintf(evt, "- %s\n", app.TsuruServicesEnvVar)
 	}
 	return nil
 }
@@ -1265,10 +1414,6 @@ func unbindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	instanceName, appName, serviceName := r.URL.Query().Get(":instance"), r.URL.Query().Get(":app"),
 		r.URL.Query().Get(":service")
 	noRestart, _ := strconv.ParseBool(r.URL.Query().Get("noRestart"))
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	instance, a, err := getServiceInstance(serviceName, instanceName, appName)
 	if err != nil {
 		return err
@@ -1290,17 +1435,27 @@ func unbindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "unbind-app", "instance="+instanceName, "app="+appName)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateUnbind,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = instance.UnbindApp(a, !noRestart, writer)
+	evt.SetLogWriter(writer)
+	err = instance.UnbindApp(a, !noRestart, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return nil
 	}
-	fmt.Fprintf(writer, "\nInstance %q is not bound to the app %q anymore.\n", instanceName, appName)
+	fmt.Fprintf(evt, "\nInstance %q is not bound to the app %q anymore.\n", instanceName, appName)
 	return nil
 }
 
@@ -1314,10 +1469,6 @@ func unbindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 //   404: App not found
 func restart(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	process := r.URL.Query().Get("process")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -1332,12 +1483,22 @@ func restart(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "restart", "app="+appName)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateRestart,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.Restart(process, writer)
+	evt.SetLogWriter(writer)
+	err = a.Restart(process, evt)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return err
@@ -1356,10 +1517,6 @@ func restart(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   404: App not found
 func sleep(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	process := r.URL.Query().Get("process")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -1383,12 +1540,22 @@ func sleep(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "sleep", "app="+appName)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateSleep,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.Sleep(w, process, proxyURL)
+	evt.SetLogWriter(w)
+	err = a.Sleep(evt, process, proxyURL)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return err
@@ -1452,10 +1619,6 @@ func addLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   409: App locked
 //   412: Number of units or platform don't match
 func swap(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	app1Name := r.FormValue("app1")
 	app2Name := r.FormValue("app2")
 	forceSwap := r.FormValue("force")
@@ -1525,8 +1688,28 @@ func swap(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			}
 		}
 	}
-	rec.Log(u.Email, "swap", "app1="+app1Name, "app2="+app2Name)
-	return app.Swap(app1, app2, cnameOnly)
+	evt1, err := event.New(&event.Opts{
+		Target:     appTarget(app1Name),
+		Kind:       permission.PermAppUpdateSwap,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	evt2, err := event.New(&event.Opts{
+		Target:     appTarget(app2Name),
+		Kind:       permission.PermAppUpdateSwap,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		evt1.Abort()
+		return err
+	}
+	defer func() { evt1.Done(err); evt2.Done(err) }()
+	err = app.Swap(app1, app2, cnameOnly)
+	return err
 }
 
 // title: app start
@@ -1539,12 +1722,7 @@ func swap(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   404: App not found
 func start(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	process := r.URL.Query().Get("process")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
-	rec.Log(u.Email, "start", "app="+appName)
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -1558,11 +1736,22 @@ func start(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateStart,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.Start(w, process)
+	evt.SetLogWriter(w)
+	err = a.Start(evt, process)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return err
@@ -1580,12 +1769,7 @@ func start(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   404: App not found
 func stop(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	process := r.URL.Query().Get("process")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
-	rec.Log(u.Email, "stop", "app="+appName)
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -1599,11 +1783,22 @@ func stop(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateStop,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = a.Stop(w, process)
+	evt.SetLogWriter(w)
+	err = a.Stop(evt, process)
 	if err != nil {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return err
@@ -1721,10 +1916,6 @@ func appMetricEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   401: Unauthorized
 //   404: App not found
 func appRebuildRoutes(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
 	if err != nil {
 		return err
@@ -1738,7 +1929,16 @@ func appRebuildRoutes(w http.ResponseWriter, r *http.Request, t auth.Token) erro
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "app-rebuild-routes", "app="+r.URL.Query().Get(":app"))
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppAdminRoutes,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/json")
 	result, err := a.RebuildRoutes()
 	if err != nil {
@@ -1746,3 +1946,116 @@ func appRebuildRoutes(w http.ResponseWriter, r *http.Request, t auth.Token) erro
 	}
 	return json.NewEncoder(w).Encode(&result)
 }
+
+// title: app routes status
+// path: /apps/{app}/routes
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func appRoutesStatus(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadRoutes,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	status, err := a.RoutesStatus()
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(status)
+}
+
+// title: routes drift list
+// path: /routes/drift
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+func routesDriftList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermAppReadRoutes)
+	if len(contexts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	filter := appFilterByContext(contexts, nil)
+	filter.Name = r.URL.Query().Get("app")
+	statuses, err := app.ListRoutesDrifts(filter)
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(statuses)
+}
+
+// title: change app pool
+// path: /apps/{app}/pool
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appChangePool(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.FormValue("pool")
+	if poolName == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the pool."}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppAdminPool,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppAdminPool,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	err = a.ChangePool(poolName, evt)
+	if err != nil {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		return err
+	}
+	return nil
+}
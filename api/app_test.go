@@ -9,9 +9,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +29,7 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event/eventtest"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -455,12 +459,11 @@ func (s *S) TestDelete(c *check.C) {
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "app-delete",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + myApp.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(myApp.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.delete",
+	}, eventtest.HasEvent)
 	_, err = repository.Manager().GetRepository(myApp.Name)
 	c.Assert(err, check.NotNil)
 }
@@ -619,12 +622,11 @@ func (s *S) TestCreateAppRemoveRole(c *check.C) {
 	c.Assert(gotApp.Teams, check.DeepEquals, []string{s.team.Name})
 	c.Assert(s.provisioner.GetUnits(&gotApp), check.HasLen, 0)
 	u, _ := token.User()
-	action := rectest.Action{
-		Action: "create-app",
-		User:   u.Email,
-		Extra:  []interface{}{"app=someapp", "platform=zend", "plan=", "description="},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("someapp"),
+		Owner:  u.Email,
+		Kind:   "app.create",
+	}, eventtest.HasEvent)
 	_, err = repository.Manager().GetRepository(a.Name)
 	c.Assert(err, check.IsNil)
 }
@@ -661,12 +663,11 @@ func (s *S) TestCreateApp(c *check.C) {
 	c.Assert(gotApp.Teams, check.DeepEquals, []string{s.team.Name})
 	c.Assert(s.provisioner.GetUnits(&gotApp), check.HasLen, 0)
 	u, _ := token.User()
-	action := rectest.Action{
-		Action: "create-app",
-		User:   u.Email,
-		Extra:  []interface{}{"app=someapp", "platform=zend", "plan=", "description="},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("someapp"),
+		Owner:  u.Email,
+		Kind:   "app.create",
+	}, eventtest.HasEvent)
 	_, err = repository.Manager().GetRepository(a.Name)
 	c.Assert(err, check.IsNil)
 }
@@ -721,12 +722,11 @@ func (s *S) TestCreateAppTeamOwner(c *check.C) {
 	c.Assert(gotApp.Teams, check.DeepEquals, []string{t1.Name})
 	c.Assert(s.provisioner.GetUnits(&gotApp), check.HasLen, 0)
 	u, _ := token.User()
-	action := rectest.Action{
-		Action: "create-app",
-		User:   u.Email,
-		Extra:  []interface{}{"app=someapp", "platform=zend", "plan=", "description="},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("someapp"),
+		Owner:  u.Email,
+		Kind:   "app.create",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestCreateAppCustomPlan(c *check.C) {
@@ -770,12 +770,11 @@ func (s *S) TestCreateAppCustomPlan(c *check.C) {
 	c.Assert(s.provisioner.GetUnits(&gotApp), check.HasLen, 0)
 	c.Assert(gotApp.Plan, check.DeepEquals, expectedPlan)
 	u, _ := token.User()
-	action := rectest.Action{
-		Action: "create-app",
-		User:   u.Email,
-		Extra:  []interface{}{"app=someapp", "platform=zend", "plan=myplan", "description="},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("someapp"),
+		Owner:  u.Email,
+		Kind:   "app.create",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestCreateAppWithDescription(c *check.C) {
@@ -810,12 +809,11 @@ func (s *S) TestCreateAppWithDescription(c *check.C) {
 	c.Assert(gotApp.Teams, check.DeepEquals, []string{s.team.Name})
 	c.Assert(s.provisioner.GetUnits(&gotApp), check.HasLen, 0)
 	u, _ := token.User()
-	action := rectest.Action{
-		Action: "create-app",
-		User:   u.Email,
-		Extra:  []interface{}{"app=someapp", "platform=zend", "plan=", "description=my app description"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("someapp"),
+		Owner:  u.Email,
+		Kind:   "app.create",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestCreateAppTwoTeams(c *check.C) {
@@ -957,12 +955,11 @@ func (s *S) TestCreateAppWithDisabledPlatformAndPlatformUpdater(c *check.C) {
 	c.Assert(gotApp.Teams, check.DeepEquals, []string{s.team.Name})
 	c.Assert(s.provisioner.GetUnits(&gotApp), check.HasLen, 0)
 	u, _ := token.User()
-	action := rectest.Action{
-		Action: "create-app",
-		User:   u.Email,
-		Extra:  []interface{}{"app=someapp", "platform=platDis", "plan=", "description="},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("someapp"),
+		Owner:  u.Email,
+		Kind:   "app.create",
+	}, eventtest.HasEvent)
 	_, err = repository.Manager().GetRepository(a.Name)
 	c.Assert(err, check.IsNil)
 }
@@ -1011,12 +1008,11 @@ func (s *S) TestUpdateAppWithDescriptionOnly(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(gotApp.Description, check.DeepEquals, "my app description")
 	u, err := token.User()
-	action := rectest.Action{
-		Action: "update-app",
-		User:   u.Email,
-		Extra:  []interface{}{"app=myapp", "description=my app description", "pool="},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapp"),
+		Owner:  u.Email,
+		Kind:   "app.update",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestUpdateAppWithPoolOnly(c *check.C) {
@@ -1269,12 +1265,11 @@ func (s *S) TestAddUnits(c *check.C) {
 	units, err := app.Units()
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 3)
-	action := rectest.Action{
-		Action: "add-units",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=armorandsword", "units=3"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("armorandsword"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.unit.add",
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"added 3 units"}`+"\n")
 }
 
@@ -1344,12 +1339,11 @@ func (s *S) TestAddUnitsWorksIfProcessIsOmited(c *check.C) {
 	units, err := app.Units()
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 3)
-	action := rectest.Action{
-		Action: "add-units",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=armorandsword", "units=3"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("armorandsword"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.unit.add",
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"added 3 units"}`+"\n")
 }
 
@@ -1404,12 +1398,11 @@ func (s *S) TestRemoveUnits(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 1)
 	c.Assert(s.provisioner.GetUnits(app), check.HasLen, 1)
-	action := rectest.Action{
-		Action: "remove-units",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=velha", "units=2"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("velha"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.unit.remove",
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"removing 2 units"}`+"\n")
 }
 
@@ -1472,12 +1465,11 @@ func (s *S) TestRemoveUnitsWorksIfProcessIsOmited(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 1)
 	c.Assert(s.provisioner.GetUnits(app), check.HasLen, 1)
-	action := rectest.Action{
-		Action: "remove-units",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=velha", "units=2"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("velha"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.unit.remove",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestRemoveUnitsReturns400IfNumberIsInvalid(c *check.C) {
@@ -1751,12 +1743,11 @@ func (s *S) TestAddTeamToTheApp(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(app.Teams, check.HasLen, 2)
 	c.Assert(app.Teams[1], check.Equals, s.team.Name)
-	action := rectest.Action{
-		Action: "grant-app-access",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + app.Name, "team=" + s.team.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(app.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.grant",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestGrantAccessToTeamReturn404IfTheAppDoesNotExist(c *check.C) {
@@ -1863,12 +1854,11 @@ func (s *S) TestRevokeAccessFromTeam(c *check.C) {
 	app, err := app.GetByName(a.Name)
 	c.Assert(app.Teams, check.HasLen, 1)
 	c.Assert(app.Teams[0], check.Equals, "abcd")
-	action := rectest.Action{
-		Action: "revoke-app-access",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + app.Name, "team=" + s.team.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(app.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.revoke",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestRevokeAccessFromTeamReturn404IfTheAppDoesNotExist(c *check.C) {
@@ -2042,12 +2032,11 @@ func (s *S) TestRunOnce(c *check.C) {
 	expected += " ls"
 	cmds := s.provisioner.GetCmds(expected, &a)
 	c.Assert(cmds, check.HasLen, 1)
-	action := rectest.Action{
-		Action: "run-command",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, "command=ls"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.run",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestRun(c *check.C) {
@@ -2071,12 +2060,92 @@ func (s *S) TestRun(c *check.C) {
 	expected += " ls"
 	cmds := s.provisioner.GetCmds(expected, &a)
 	c.Assert(cmds, check.HasLen, 1)
-	action := rectest.Action{
-		Action: "run-command",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, "command=ls"},
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.run",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestRunIsolated(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	s.provisioner.PrepareOutput([]byte("migrated"))
+	request, err := http.NewRequest("POST", "/apps/myapp/run", strings.NewReader("command=./migrate.sh&isolated=true&timeout=300"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"migrated"}`+"\n")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " ./migrate.sh"
+	cmds := s.provisioner.GetCmds(expected, a)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Isolated, check.Equals, true)
+	c.Assert(cmds[0].Timeout, check.Equals, 5*time.Minute)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.run",
+		StartCustomData: []map[string]interface{}{
+			{"name": "command", "value": "./migrate.sh"},
+			{"name": "isolated", "value": "true"},
+			{"name": "timeout", "value": "300"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestRunIsolatedExitCode(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	s.provisioner.PrepareOutput([]byte("no such table"))
+	s.provisioner.PrepareExitCode(1)
+	request, err := http.NewRequest("POST", "/apps/myapp/run", strings.NewReader("command=./migrate.sh&isolated=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	expected := `{"Message":"no such table"}` + "\n" +
+		`{"Message":"","Error":"command finished with exit code 1"}` + "\n"
+	c.Assert(recorder.Body.String(), check.Equals, expected)
+}
+
+func (s *S) TestRunIsolatedAppNotDeployed(c *check.C) {
+	a := app.App{Name: "secrets", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/secrets/run", strings.NewReader("command=ls&isolated=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrIsolatedRunNotDeployed.Error()+"\n")
+	c.Assert(s.provisioner.GetCmds("", &a), check.HasLen, 0)
+}
+
+func (s *S) TestRunIsolatedInvalidTimeout(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	for _, timeout := range []string{"abc", "-1"} {
+		body := strings.NewReader("command=ls&isolated=true&timeout=" + timeout)
+		request, err := http.NewRequest("POST", "/apps/myapp/run", body)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m := RunServer(true)
+		m.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, "timeout must be a non-negative number of seconds\n")
 	}
-	c.Assert(action, rectest.IsRecorded)
 }
 
 func (s *S) TestRunReturnsTheOutputOfTheCommandEvenIfItFails(c *check.C) {
@@ -2240,6 +2309,46 @@ func (s *S) TestGetEnv(c *check.C) {
 	c.Assert(action, rectest.IsRecorded)
 }
 
+func (s *S) TestGetEnvDoesNotResolveSecretReferences(c *check.C) {
+	dir, err := ioutil.TempDir("", "secrets")
+	c.Assert(err, check.IsNil)
+	defer os.RemoveAll(dir)
+	err = os.MkdirAll(filepath.Join(dir, s.team.Name, "db"), 0700)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, s.team.Name, "db", "password"), []byte("s3cr3t"), 0600)
+	c.Assert(err, check.IsNil)
+	config.Set("secrets:provider", "file")
+	config.Set("secrets:file:base-dir", dir)
+	defer config.Unset("secrets")
+	a := app.App{
+		Name:      "everything-i-want",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Env: map[string]bind.EnvVar{
+			"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "secret://tsuruteam/db#password", Public: false},
+		},
+	}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/env?env=DATABASE_PASSWORD", a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	expected := []map[string]interface{}{{
+		"name":   "DATABASE_PASSWORD",
+		"value":  "secret://tsuruteam/db#password",
+		"public": false,
+	}}
+	result := []map[string]interface{}{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, expected)
+}
+
 func (s *S) TestGetEnvMultipleVariables(c *check.C) {
 	a := app.App{
 		Name:      "four-sticks",
@@ -2373,20 +2482,50 @@ func (s *S) TestSetEnvPublicEnvironmentVariableInTheApp(c *check.C) {
 	c.Assert(err, check.IsNil)
 	expected := bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: true}
 	c.Assert(app.Env["DATABASE_HOST"], check.DeepEquals, expected)
-	envs := map[string]string{
-		"DATABASE_HOST": "localhost",
-	}
-	action := rectest.Action{
-		Action: "set-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, envs, "private=false"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Envs.0.Name", "value": "DATABASE_HOST"},
+			{"name": "Envs.0.Value", "value": "localhost"},
+		},
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals,
 		`{"Message":"---- Setting 1 new environment variables ----\n"}
 `)
 }
 
+func (s *S) TestSetEnvSecretReferenceOfAnotherTeam(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	d := Envs{
+		Envs: []struct{ Name, Value string }{
+			{"DATABASE_PASSWORD", "secret://otherteam/db#password"},
+		},
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/black-dog/env", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "you're not allowed to reference secrets of team \"otherteam\"\n")
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	_, ok := dbApp.Env["DATABASE_PASSWORD"]
+	c.Assert(ok, check.Equals, false)
+}
+
 func (s *S) TestSetEnvHandlerShouldSetAPrivateEnvironmentVariableInTheApp(c *check.C) {
 	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -2415,15 +2554,15 @@ func (s *S) TestSetEnvHandlerShouldSetAPrivateEnvironmentVariableInTheApp(c *che
 	c.Assert(err, check.IsNil)
 	expected := bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: false}
 	c.Assert(app.Env["DATABASE_HOST"], check.DeepEquals, expected)
-	envs := map[string]string{
-		"DATABASE_HOST": "localhost",
-	}
-	action := rectest.Action{
-		Action: "set-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, envs, "private=true"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Envs.0.Name", "value": "DATABASE_HOST"},
+			{"name": "Envs.0.Value", "value": "*****"},
+		},
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals,
 		`{"Message":"---- Setting 1 new environment variables ----\n"}
 `)
@@ -2475,15 +2614,15 @@ func (s *S) TestSetEnvHandlerShouldSetADoublePrivateEnvironmentVariableInTheApp(
 	c.Assert(err, check.IsNil)
 	expected := bind.EnvVar{Name: "DATABASE_HOST", Value: "127.0.0.1", Public: false}
 	c.Assert(app.Env["DATABASE_HOST"], check.DeepEquals, expected)
-	envs := map[string]string{
-		"DATABASE_HOST": "127.0.0.1",
-	}
-	action := rectest.Action{
-		Action: "set-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, envs, "private=true"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Envs.0.Name", "value": "DATABASE_HOST"},
+			{"name": "Envs.0.Value", "value": "*****"},
+		},
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals,
 		`{"Message":"---- Setting 1 new environment variables ----\n"}
 `)
@@ -2520,16 +2659,17 @@ func (s *S) TestSetEnvHandlerShouldSetMultipleEnvironmentVariablesInTheApp(c *ch
 	expectedUser := bind.EnvVar{Name: "DATABASE_USER", Value: "root", Public: true}
 	c.Assert(app.Env["DATABASE_HOST"], check.DeepEquals, expectedHost)
 	c.Assert(app.Env["DATABASE_USER"], check.DeepEquals, expectedUser)
-	envs := map[string]string{
-		"DATABASE_HOST": "localhost",
-		"DATABASE_USER": "root",
-	}
-	action := rectest.Action{
-		Action: "set-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, envs, "private=false"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Envs.0.Name", "value": "DATABASE_HOST"},
+			{"name": "Envs.0.Value", "value": "localhost"},
+			{"name": "Envs.1.Name", "value": "DATABASE_USER"},
+			{"name": "Envs.1.Value", "value": "root"},
+		},
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestSetEnvHandlerShouldNotChangeValueOfSerivceVariables(c *check.C) {
@@ -2598,15 +2738,15 @@ func (s *S) TestSetEnvHandlerNoRestart(c *check.C) {
 	c.Assert(err, check.IsNil)
 	expected := bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost", Public: true}
 	c.Assert(app.Env["DATABASE_HOST"], check.DeepEquals, expected)
-	envs := map[string]string{
-		"DATABASE_HOST": "localhost",
-	}
-	action := rectest.Action{
-		Action: "set-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, envs, "private=false"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Envs.0.Name", "value": "DATABASE_HOST"},
+			{"name": "Envs.0.Value", "value": "localhost"},
+		},
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals,
 		`{"Message":"---- Setting 1 new environment variables ----\n"}
 `)
@@ -2715,12 +2855,11 @@ func (s *S) TestUnsetEnv(c *check.C) {
 	app, err := app.GetByName("swift")
 	c.Assert(err, check.IsNil)
 	c.Assert(app.Env, check.DeepEquals, expected)
-	action := rectest.Action{
-		Action: "unset-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, "envs=[DATABASE_HOST]"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.unset",
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals,
 		`{"Message":"---- Unsetting 1 environment variables ----\n"}
 `)
@@ -2754,12 +2893,11 @@ func (s *S) TestUnsetEnvNoRestart(c *check.C) {
 	app, err := app.GetByName("swift")
 	c.Assert(err, check.IsNil)
 	c.Assert(app.Env, check.DeepEquals, expected)
-	action := rectest.Action{
-		Action: "unset-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, "envs=[DATABASE_HOST]"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.unset",
+	}, eventtest.HasEvent)
 	c.Assert(recorder.Body.String(), check.Equals,
 		`{"Message":"---- Unsetting 1 environment variables ----\n"}
 `)
@@ -2798,12 +2936,11 @@ func (s *S) TestUnsetEnvHandlerRemovesAllGivenEnvironmentVariables(c *check.C) {
 		},
 	}
 	c.Assert(app.Env, check.DeepEquals, expected)
-	action := rectest.Action{
-		Action: "unset-env",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name, "envs=[DATABASE_HOST DATABASE_USER]"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.env.unset",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestUnsetHandlerDoesNotRemovePrivateVariables(c *check.C) {
@@ -2915,12 +3052,11 @@ func (s *S) TestAddCName(c *check.C) {
 	app, err := app.GetByName(a.Name)
 	c.Assert(err, check.IsNil)
 	c.Assert(app.CName, check.DeepEquals, []string{"leper.secretcompany.com", "blog.tsuru.com"})
-	action := rectest.Action{
-		Action: "add-cname",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + app.Name, "cname=leper.secretcompany.com, blog.tsuru.com"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(app.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.cname.add",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestAddCNameAcceptsWildCard(c *check.C) {
@@ -2940,12 +3076,11 @@ func (s *S) TestAddCNameAcceptsWildCard(c *check.C) {
 	app, err := app.GetByName(a.Name)
 	c.Assert(err, check.IsNil)
 	c.Assert(app.CName, check.DeepEquals, []string{"*.leper.secretcompany.com"})
-	action := rectest.Action{
-		Action: "add-cname",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + app.Name, "cname=*.leper.secretcompany.com"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(app.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.cname.add",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestAddCNameErrsOnInvalidCName(c *check.C) {
@@ -3053,12 +3188,11 @@ func (s *S) TestRemoveCNameHandler(c *check.C) {
 	app, err := app.GetByName(a.Name)
 	c.Assert(err, check.IsNil)
 	c.Assert(app.CName, check.DeepEquals, []string{})
-	action := rectest.Action{
-		Action: "remove-cname",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + app.Name, "cnames=foo.bar.com"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(app.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.cname.remove",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestRemoveCNameTwoCnames(c *check.C) {
@@ -3084,12 +3218,11 @@ func (s *S) TestRemoveCNameTwoCnames(c *check.C) {
 	app, err := app.GetByName(a.Name)
 	c.Assert(err, check.IsNil)
 	c.Assert(app.CName, check.DeepEquals, []string{})
-	action := rectest.Action{
-		Action: "remove-cname",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + app.Name, "cnames=foo.bar.com, bar.com"},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(app.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.cname.remove",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestRemoveCNameUnknownApp(c *check.C) {
@@ -3572,12 +3705,11 @@ func (s *S) TestBindHandler(c *check.C) {
 	c.Assert(parts[6], check.Matches, `{"Message":"- TSURU_SERVICES\\n"}`)
 	c.Assert(parts[7], check.Equals, "")
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "bind-app",
-		User:   s.user.Email,
-		Extra:  []interface{}{"instance=" + instance.Name, "app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.bind",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestBindHandlerWithoutEnvsDontRestartTheApp(c *check.C) {
@@ -3629,12 +3761,11 @@ func (s *S) TestBindHandlerWithoutEnvsDontRestartTheApp(c *check.C) {
 	c.Assert(parts[0], check.Equals, `{"Message":"\nInstance \"my-mysql\" is now bound to the app \"painkiller\".\n"}`)
 	c.Assert(parts[1], check.Equals, "")
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "bind-app",
-		User:   s.user.Email,
-		Extra:  []interface{}{"instance=" + instance.Name, "app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.bind",
+	}, eventtest.HasEvent)
 	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
 }
 
@@ -3799,12 +3930,11 @@ func (s *S) TestBindWithManyInstanceNameWithSameNameAndNoRestartFlag(c *check.C)
 	c.Assert(parts[5], check.Matches, `{"Message":"- TSURU_SERVICES\\n"}`)
 	c.Assert(parts[6], check.Equals, "")
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "bind-app",
-		User:   s.user.Email,
-		Extra:  []interface{}{"instance=" + instance.Name, "app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.bind",
+	}, eventtest.HasEvent)
 	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name, "service_name": instance.ServiceName}).One(&result)
 	c.Assert(err, check.IsNil)
 	c.Assert(result.Apps, check.DeepEquals, []string{})
@@ -3892,12 +4022,11 @@ func (s *S) TestUnbindHandler(c *check.C) {
 	c.Assert(parts[2], check.Equals, `{"Message":"\nInstance \"my-mysql\" is not bound to the app \"painkiller\" anymore.\n"}`)
 	c.Assert(parts[3], check.Equals, "")
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "unbind-app",
-		User:   s.user.Email,
-		Extra:  []interface{}{"instance=" + instance.Name, "app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.unbind",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestUnbindNoRestartFlag(c *check.C) {
@@ -3980,12 +4109,11 @@ func (s *S) TestUnbindNoRestartFlag(c *check.C) {
 	c.Assert(parts[1], check.Equals, `{"Message":"\nInstance \"my-mysql\" is not bound to the app \"painkiller\" anymore.\n"}`)
 	c.Assert(parts[2], check.Equals, "")
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "unbind-app",
-		User:   s.user.Email,
-		Extra:  []interface{}{"instance=" + instance.Name, "app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.unbind",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestUnbindWithSameInstanceName(c *check.C) {
@@ -4161,12 +4289,11 @@ func (s *S) TestRestartHandler(c *check.C) {
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "restart",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.restart",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestRestartHandlerReturns404IfTheAppDoesNotExist(c *check.C) {
@@ -4220,12 +4347,11 @@ func (s *S) TestSleepHandler(c *check.C) {
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
-	action := rectest.Action{
-		Action: "sleep",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.sleep",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestSleepHandlerReturns400IfTheProxyIsNotSet(c *check.C) {
@@ -4373,8 +4499,16 @@ func (s *S) TestSwap(c *check.C) {
 	m := RunServer(true)
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
-	action := rectest.Action{Action: "swap", User: s.user.Email, Extra: []interface{}{"app1=app1", "app2=app2"}}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("app1"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.swap",
+	}, eventtest.HasEvent)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("app2"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.swap",
+	}, eventtest.HasEvent)
 	var dbApp app.App
 	err = s.conn.Apps().Find(bson.M{"name": app1.Name}).One(&dbApp)
 	c.Assert(err, check.IsNil)
@@ -4400,8 +4534,16 @@ func (s *S) TestSwapCnameOnly(c *check.C) {
 	m := RunServer(true)
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
-	action := rectest.Action{Action: "swap", User: s.user.Email, Extra: []interface{}{"app1=app1", "app2=app2"}}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("app1"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.swap",
+	}, eventtest.HasEvent)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("app2"),
+		Owner:  s.user.Email,
+		Kind:   "app.update.swap",
+	}, eventtest.HasEvent)
 	var dbApp app.App
 	err = s.conn.Apps().Find(bson.M{"name": app1.Name}).One(&dbApp)
 	c.Assert(err, check.IsNil)
@@ -4551,12 +4693,11 @@ func (s *S) TestStartHandler(c *check.C) {
 	c.Assert(starts, check.Equals, 1)
 	starts = s.provisioner.Starts(&a, "worker")
 	c.Assert(starts, check.Equals, 0)
-	action := rectest.Action{
-		Action: "start",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.start",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestStopHandler(c *check.C) {
@@ -4576,12 +4717,11 @@ func (s *S) TestStopHandler(c *check.C) {
 	c.Assert(stops, check.Equals, 1)
 	stops = s.provisioner.Stops(&a, "worker")
 	c.Assert(stops, check.Equals, 0)
-	action := rectest.Action{
-		Action: "stop",
-		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name},
-	}
-	c.Assert(action, rectest.IsRecorded)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.stop",
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestForceDeleteLock(c *check.C) {
@@ -4803,3 +4943,178 @@ func (s *S) TestRebuildRoutes(c *check.C) {
 	json.Unmarshal(recorder.Body.Bytes(), &parsed)
 	c.Assert(parsed, check.DeepEquals, app.RebuildRoutesResult{})
 }
+
+func (s *S) TestAppRoutesStatus(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = s.conn.RoutesStatus().UpsertId(a.Name, app.RoutesStatus{
+		App:       a.Name,
+		Routers:   []app.RouterRoutesStatus{{Router: "fake", Missing: []string{"http://10.0.0.1:8080"}}},
+		CheckedAt: time.Now().UTC(),
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myappx/routes", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var status app.RoutesStatus
+	err = json.Unmarshal(recorder.Body.Bytes(), &status)
+	c.Assert(err, check.IsNil)
+	c.Assert(status.App, check.Equals, a.Name)
+	c.Assert(status.Routers[0].Missing, check.DeepEquals, []string{"http://10.0.0.1:8080"})
+}
+
+func (s *S) TestAppRoutesStatusNotChecked(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myappx/routes", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestAppRoutesStatusWithoutPermission(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myappx/routes", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestRoutesDriftList(c *check.C) {
+	a1 := app.App{Name: "myapp1", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a1, s.user)
+	c.Assert(err, check.IsNil)
+	a2 := app.App{Name: "myapp2", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a2, s.user)
+	c.Assert(err, check.IsNil)
+	a3 := app.App{Name: "myapp3", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a3, s.user)
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC()
+	for _, status := range []app.RoutesStatus{
+		{App: a1.Name, Routers: []app.RouterRoutesStatus{{Router: "fake", Extra: []string{"http://10.0.0.1:8080"}}}, CheckedAt: now},
+		{App: a2.Name, Routers: []app.RouterRoutesStatus{{Router: "fake"}}, CheckedAt: now},
+		{App: a3.Name, Routers: []app.RouterRoutesStatus{{Router: "fake", Missing: []string{"http://10.0.0.3:8080"}}}, CheckedAt: now},
+	} {
+		_, err = s.conn.RoutesStatus().UpsertId(status.App, status)
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadRoutes,
+		Context: permission.Context(permission.CtxApp, a1.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppReadRoutes,
+		Context: permission.Context(permission.CtxApp, a2.Name),
+	})
+	request, err := http.NewRequest("GET", "/routes/drift", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var statuses []app.RoutesStatus
+	err = json.Unmarshal(recorder.Body.Bytes(), &statuses)
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses, check.HasLen, 1)
+	c.Assert(statuses[0].App, check.Equals, a1.Name)
+	c.Assert(statuses[0].Routers[0].Extra, check.DeepEquals, []string{"http://10.0.0.1:8080"})
+}
+
+func (s *S) TestRoutesDriftListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/routes/drift", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestAppChangePool(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool2")
+	err = provision.AddTeamsToPool("pool2", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppAdminPool,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader("pool=pool2")
+	request, err := http.NewRequest("POST", "/apps/myapp/pool", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	u, err := token.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target:          appTarget("myapp"),
+		Owner:           u.Email,
+		Kind:            "app.admin.pool",
+		StartCustomData: []map[string]interface{}{{"name": "pool", "value": "pool2"}},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppChangePoolWithoutPool(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/myapp/pool", strings.NewReader(""))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "You must provide the pool.\n")
+}
+
+func (s *S) TestAppChangePoolWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdate,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/myapp/pool", strings.NewReader("pool=pool2"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/tsuru/tsuru/acme"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: app certificate list
+// path: /apps/{app}/certificate
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func listCertificates(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := certificateApp(r, t, permission.PermAppReadCertificate)
+	if err != nil {
+		return err
+	}
+	if len(a.Certificates) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.Certificates)
+}
+
+// title: app certificate set
+// path: /apps/{app}/certificate
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Certificate set
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func setCertificate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := certificateApp(r, t, permission.PermAppUpdateCertificateSet)
+	if err != nil {
+		return err
+	}
+	cname := r.FormValue("cname")
+	certificate := r.FormValue("certificate")
+	key := r.FormValue("key")
+	if cname == "" || certificate == "" || key == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the cname, the certificate and the key."}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateCertificateSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(url.Values{"cname": {cname}}),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.SetCertificate(cname, certificate, key)
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	return err
+}
+
+// title: app certificate unset
+// path: /apps/{app}/certificate
+// method: DELETE
+// responses:
+//   200: Certificate removed
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or certificate not found
+func unsetCertificate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := certificateApp(r, t, permission.PermAppUpdateCertificateUnset)
+	if err != nil {
+		return err
+	}
+	cname := r.URL.Query().Get("cname")
+	if cname == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the cname."}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateCertificateUnset,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.RemoveCertificate(cname)
+	if err == app.ErrCertificateNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app ACME certificate list
+// path: /apps/{app}/certificate/acme
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func listACMECertificates(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := certificateApp(r, t, permission.PermAppReadCertificate)
+	if err != nil {
+		return err
+	}
+	certificates, err := a.ACMECertificates()
+	if err != nil {
+		return err
+	}
+	if len(certificates) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(certificates)
+}
+
+// title: ACME challenge
+// path: /.well-known/acme-challenge/{token}
+// method: GET
+// produce: text/plain
+// responses:
+//   200: OK
+//   404: Challenge not found
+func acmeChallenge(w http.ResponseWriter, r *http.Request) error {
+	keyAuthorization, err := acme.KeyAuthorization(r.URL.Query().Get(":token"))
+	if err == acme.ErrChallengeNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, err = w.Write([]byte(keyAuthorization))
+	return err
+}
+
+func certificateApp(r *http.Request, t auth.Token, scheme *permission.PermissionScheme) (*app.App, error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return nil, err
+	}
+	allowed := permission.Check(t, scheme,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return nil, permission.ErrUnauthorized
+	}
+	return &a, nil
+}
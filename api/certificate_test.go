@@ -0,0 +1,269 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// setEnvEncryptionKey configures the key used to encrypt the private keys of
+// the certificates, returning a function that removes it.
+func setEnvEncryptionKey() func() {
+	config.Set("envs:encryption:current-key", "key1")
+	config.Set("envs:encryption:keys", map[interface{}]interface{}{
+		"key1": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("1"), 32)),
+	})
+	return func() {
+		config.Unset("envs:encryption")
+	}
+}
+
+func (s *S) TestSetCertificate(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	body := url.Values{
+		"cname":       {routertest.CertificateCName},
+		"certificate": {routertest.Certificate},
+		"key":         {routertest.CertificateKey},
+	}
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/certificate", a.Name), strings.NewReader(body.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	certificate, err := routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	c.Assert(certificate, check.Equals, routertest.Certificate)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Certificates, check.HasLen, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.certificate.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "cname", "value": routertest.CertificateCName},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestSetCertificateInvalid(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	body := url.Values{
+		"cname":       {routertest.CertificateCName},
+		"certificate": {routertest.Certificate},
+		"key":         {"invalid key"},
+	}
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/certificate", a.Name), strings.NewReader(body.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, "invalid certificate: .*\n")
+}
+
+func (s *S) TestSetCertificateMissingParams(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	body := strings.NewReader("cname=" + routertest.CertificateCName)
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/certificate", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "You must provide the cname, the certificate and the key.\n")
+}
+
+func (s *S) TestListCertificates(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/certificate", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var certificates []map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &certificates)
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 1)
+	c.Assert(certificates[0]["CName"], check.Equals, routertest.CertificateCName)
+	c.Assert(certificates[0]["Certificate"], check.Equals, routertest.Certificate)
+	c.Assert(certificates[0]["ExpiresAt"], check.NotNil)
+	_, hasKey := certificates[0]["Key"]
+	c.Assert(hasKey, check.Equals, false)
+}
+
+func (s *S) TestListCertificatesEmpty(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/certificate", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestUnsetCertificate(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/certificate?cname=%s", a.Name, routertest.CertificateCName), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Certificates, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.certificate.unset",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnsetCertificateNotFound(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/certificate?cname=%s", a.Name, routertest.CertificateCName), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrCertificateNotFound.Error()+"\n")
+}
+
+func (s *S) TestListACMECertificates(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	now := time.Now().UTC()
+	err := s.conn.ACMECertificates().Insert(app.ACMECertificate{
+		CName:       routertest.CertificateCName,
+		App:         a.Name,
+		Status:      app.ACMEStatusFailed,
+		Error:       "connection refused",
+		Attempts:    1,
+		NextAttempt: now.Add(time.Minute),
+		UpdatedAt:   now,
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/certificate/acme", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var certificates []map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &certificates)
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 1)
+	c.Assert(certificates[0]["CName"], check.Equals, routertest.CertificateCName)
+	c.Assert(certificates[0]["Status"], check.Equals, app.ACMEStatusFailed)
+	c.Assert(certificates[0]["Error"], check.Equals, "connection refused")
+}
+
+func (s *S) TestListACMECertificatesEmpty(c *check.C) {
+	a := s.createTestApp(c, bson.M{
+		"plan.router": "fake-tls",
+		"cname":       []string{routertest.CertificateCName},
+	})
+	defer setEnvEncryptionKey()()
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/certificate/acme", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestACMEChallenge(c *check.C) {
+	err := s.conn.ACMEChallenges().Insert(bson.M{"_id": "token1", "domain": routertest.CertificateCName, "keyauthorization": "token1.thumbprint"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/.well-known/acme-challenge/token1", nil)
+	c.Assert(err, check.IsNil)
+	request.Host = routertest.CertificateCName
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/plain")
+	c.Assert(recorder.Body.String(), check.Equals, "token1.thumbprint")
+}
+
+func (s *S) TestACMEChallengeNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/.well-known/acme-challenge/token1", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
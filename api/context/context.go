@@ -19,6 +19,7 @@ const (
 	delayedHandlerKey
 	preventUnlockKey
 	appContextKey
+	deployQueuedKey
 )
 
 func Clear(r *http.Request) {
@@ -88,6 +89,19 @@ func IsPreventUnlock(r *http.Request) bool {
 	return false
 }
 
+// SetDeployQueued marks the deploy in the request to be queued, as the app
+// is locked by another deploy.
+func SetDeployQueued(r *http.Request) {
+	context.Set(r, deployQueuedKey, true)
+}
+
+func IsDeployQueued(r *http.Request) bool {
+	if v := context.Get(r, deployQueuedKey); v != nil {
+		return v.(bool)
+	}
+	return false
+}
+
 func SetRequestID(r *http.Request, requestIDHeader, requestID string) {
 	context.Set(r, requestIDHeader, requestID)
 }
@@ -7,6 +7,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	stdio "io"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -14,11 +15,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tsuru/tsuru/api/context"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 )
 
 // title: app deploy
@@ -96,16 +100,47 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			}
 		}
 	}
+	var dockerfile bool
+	if dockerfileString := r.URL.Query().Get("dockerfile"); dockerfileString != "" {
+		dockerfile, err = strconv.ParseBool(dockerfileString)
+		if err != nil {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			}
+		}
+		if dockerfile && file == nil {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "you must upload a file to deploy with a Dockerfile.",
+			}
+		}
+	}
+	var canaryWeight int
+	if canary := r.FormValue("canary"); canary != "" {
+		canaryWeight, err = strconv.Atoi(canary)
+		if err != nil || canaryWeight < 1 || canaryWeight > 99 {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: app.ErrInvalidCanaryWeight.Error(),
+			}
+		}
+	}
+	if instance.Canary != nil {
+		return &errors.HTTP{Code: http.StatusConflict, Message: app.ErrCanaryInProgress.Error()}
+	}
 	opts := app.DeployOptions{
-		App:        instance,
-		Commit:     commit,
-		FileSize:   fileSize,
-		File:       file,
-		ArchiveURL: archiveURL,
-		User:       userName,
-		Image:      image,
-		Origin:     origin,
-		Build:      build,
+		App:          instance,
+		Commit:       commit,
+		FileSize:     fileSize,
+		File:         file,
+		ArchiveURL:   archiveURL,
+		User:         userName,
+		Image:        image,
+		Origin:       origin,
+		Build:        build,
+		Dockerfile:   dockerfile,
+		CanaryWeight: canaryWeight,
 	}
 	if t.GetAppName() != app.InternalAppName {
 		canDeploy := permission.Check(t, permSchemeForDeploy(opts),
@@ -118,9 +153,26 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			return &errors.HTTP{Code: http.StatusForbidden, Message: "User does not have permission to do this action in this app"}
 		}
 	}
+	if context.IsDeployQueued(r) {
+		return enqueueDeploy(w, opts)
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permSchemeForDeploy(opts),
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Cancelable: opts.Kind().Cancelable(),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("X-Tsuru-Deploy-Id", evt.UniqueID.Hex())
 	writer := io.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
 	defer writer.Stop()
-	opts.OutputStream = writer
+	evt.SetLogWriter(writer)
+	opts.OutputStream = evt
+	opts.Event = evt
 	err = app.Deploy(opts)
 	if err == nil {
 		fmt.Fprintln(w, "\nOK")
@@ -138,6 +190,8 @@ func permSchemeForDeploy(opts app.DeployOptions) *permission.PermissionScheme {
 		return permission.PermAppDeployUpload
 	case app.DeployUploadBuild:
 		return permission.PermAppDeployBuild
+	case app.DeployDockerfile:
+		return permission.PermAppDeployDockerfile
 	case app.DeployArchiveURL:
 		return permission.PermAppDeployArchiveUrl
 	default:
@@ -200,10 +254,20 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
 	}
 	image := r.FormValue("image")
-	if image == "" {
+	var release int
+	if version := r.FormValue("release"); version != "" {
+		release, err = strconv.Atoi(version)
+		if err != nil || release <= 0 {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("invalid release version %q", version),
+			}
+		}
+	}
+	if image == "" && release == 0 {
 		return &errors.HTTP{
 			Code:    http.StatusBadRequest,
-			Message: "you cannot rollback without an image name",
+			Message: "you cannot rollback without an image name or a release",
 		}
 	}
 	origin := r.FormValue("origin")
@@ -224,14 +288,26 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	if !canRollback {
 		return &errors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppDeployRollback,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := io.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &io.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
 	err = app.Rollback(app.DeployOptions{
 		App:          instance,
-		OutputStream: writer,
+		OutputStream: evt,
 		Image:        image,
+		Release:      release,
 		User:         t.GetUserName(),
 		Origin:       origin,
 	})
@@ -241,6 +317,145 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	return nil
 }
 
+// title: promote image
+// path: /apps/{appname}/promote
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: OK
+//   400: Invalid data
+//   403: Forbidden
+//   404: Not found
+func deployPromote(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":appname")
+	source, err := app.GetByName(appName)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	destName := r.FormValue("to")
+	if destName == "" {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "you must specify the app to promote the image to",
+		}
+	}
+	dest, err := app.GetByName(destName)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", destName)}
+	}
+	canRead := permission.Check(t, permission.PermAppReadDeploy,
+		append(permission.Contexts(permission.CtxTeam, source.Teams),
+			permission.Context(permission.CtxApp, source.Name),
+			permission.Context(permission.CtxPool, source.Pool),
+		)...,
+	)
+	canPromote := permission.Check(t, permission.PermAppDeployPromote,
+		append(permission.Contexts(permission.CtxTeam, dest.Teams),
+			permission.Context(permission.CtxApp, dest.Name),
+			permission.Context(permission.CtxPool, dest.Pool),
+		)...,
+	)
+	if !canRead || !canPromote {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	if dest.Canary != nil {
+		return &errors.HTTP{Code: http.StatusConflict, Message: app.ErrCanaryInProgress.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(dest.Name),
+		Kind:       permission.PermAppDeployPromote,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := io.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &io.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	err = app.Promote(app.PromoteOptions{
+		Source:       source,
+		Dest:         dest,
+		Image:        r.FormValue("image"),
+		User:         t.GetUserName(),
+		OutputStream: evt,
+	})
+	if err != nil {
+		writer.Encode(io.SimpleJsonMessage{Error: err.Error()})
+	}
+	return nil
+}
+
+// title: promote canary
+// path: /apps/{appname}/deploy/canary/promote
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: OK
+//   400: No canary deploy
+//   403: Forbidden
+//   404: Not found
+func deployCanaryPromote(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return finishCanary(w, r, t, permission.PermAppDeployCanaryPromote, (*app.App).PromoteCanary)
+}
+
+// title: abort canary
+// path: /apps/{appname}/deploy/canary/abort
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: OK
+//   400: No canary deploy
+//   403: Forbidden
+//   404: Not found
+func deployCanaryAbort(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return finishCanary(w, r, t, permission.PermAppDeployCanaryAbort, (*app.App).AbortCanary)
+}
+
+func finishCanary(w http.ResponseWriter, r *http.Request, t auth.Token, perm *permission.PermissionScheme, finish func(*app.App, stdio.Writer) error) error {
+	appName := r.URL.Query().Get(":appname")
+	instance, err := app.GetByName(appName)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	allowed := permission.Check(t, perm,
+		append(permission.Contexts(permission.CtxTeam, instance.Teams),
+			permission.Context(permission.CtxApp, instance.Name),
+			permission.Context(permission.CtxPool, instance.Pool),
+		)...,
+	)
+	if !allowed {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	if instance.Canary == nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: app.ErrNoCanary.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       perm,
+		Owner:      t,
+		CustomData: map[string]interface{}{"image": instance.Canary.Image},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := io.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &io.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	err = finish(instance, evt)
+	if err != nil {
+		writer.Encode(io.SimpleJsonMessage{Error: err.Error()})
+	}
+	return nil
+}
+
 // title: deploy list
 // path: /deploys
 // method: GET
@@ -302,3 +517,102 @@ func deployInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	w.Header().Add("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(deploy)
 }
+
+func getReadableDeploy(r *http.Request, t auth.Token) (*app.DeployData, error) {
+	deploy, err := app.GetDeploy(r.URL.Query().Get(":deploy"))
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: "Deploy not found."}
+	}
+	dbApp, err := app.GetByName(deploy.App)
+	if err != nil {
+		return nil, err
+	}
+	canGet := permission.Check(t, permission.PermAppReadDeploy,
+		append(permission.Contexts(permission.CtxTeam, dbApp.Teams),
+			permission.Context(permission.CtxApp, dbApp.Name),
+			permission.Context(permission.CtxPool, dbApp.Pool),
+		)...,
+	)
+	if !canGet {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: "Deploy not found."}
+	}
+	return deploy, nil
+}
+
+// title: deploy log
+// path: /deploys/{deploy}/log
+// method: GET
+// produce: text
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func deployLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	deploy, err := getReadableDeploy(r, t)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text")
+	var closeChan <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closeChan = notifier.CloseNotify()
+	} else {
+		closeChan = make(chan bool)
+	}
+	return app.FollowDeployLog(deploy, w, closeChan)
+}
+
+// title: deploy cancel
+// path: /deploys/{deploy}/cancel
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   204: OK
+//   400: Empty reason
+//   401: Unauthorized
+//   404: Not found
+//   409: Deploy is not cancelable
+func deployCancel(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	deploy, err := getReadableDeploy(r, t)
+	if err != nil {
+		return err
+	}
+	reason := r.FormValue("reason")
+	if reason == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "reason is mandatory"}
+	}
+	notCancelable := &errors.HTTP{Code: http.StatusConflict, Message: "deploy is not cancelable"}
+	if !deploy.Running {
+		return notCancelable
+	}
+	a, err := app.GetByName(deploy.App)
+	if err != nil {
+		return err
+	}
+	prov, err := a.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	if _, ok := prov.(provision.DeployCanceler); !ok {
+		return &errors.HTTP{Code: http.StatusConflict, Message: "the provisioner of the app does not support deploy cancellation"}
+	}
+	evt, err := event.GetByID(deploy.ID)
+	if err == event.ErrEventNotFound {
+		return notCancelable
+	}
+	if err != nil {
+		return err
+	}
+	if !canCancelEvent(t, evt) {
+		return permission.ErrUnauthorized
+	}
+	err = evt.TryCancel(reason, t.GetUserName())
+	if err == event.ErrNotCancelable {
+		return notCancelable
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// enqueueDeploy queues a deploy whose app is locked by another deploy,
+// answering with the queued deploy instead of its output.
+func enqueueDeploy(w http.ResponseWriter, opts app.DeployOptions) error {
+	qd, err := app.EnqueueDeploy(opts)
+	if err == app.ErrDeployNotQueueable {
+		return &errors.HTTP{Code: http.StatusConflict, Message: fmt.Sprintf("%s", &opts.App.Lock)}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	return json.NewEncoder(w).Encode(qd)
+}
+
+// title: deploy queue list
+// path: /apps/{appname}/deploy/queue
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func deployQueueList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := deployQueueApp(r, t, permission.PermAppReadDeploy)
+	if err != nil {
+		return err
+	}
+	deploys, err := app.ListQueuedDeploys(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(deploys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(deploys)
+}
+
+// title: deploy queue update
+// path: /apps/{appname}/deploy/queue
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func deployQueueUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := deployQueueApp(r, t, permission.PermAppUpdateDeployQueue)
+	if err != nil {
+		return err
+	}
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for enabled"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppUpdateDeployQueue,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return a.SetDeployQueue(enabled)
+}
+
+// title: deploy queue remove
+// path: /apps/{appname}/deploy/queue/{id}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: App or queued deploy not found
+func deployQueueRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := deployQueueApp(r, t, permission.PermAppDeployQueue)
+	if err != nil {
+		return err
+	}
+	id := r.URL.Query().Get(":id")
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppDeployQueue,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.RemoveQueuedDeploy(a.Name, id)
+	if err == app.ErrQueuedDeployNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+func deployQueueApp(r *http.Request, t auth.Token, scheme *permission.PermissionScheme) (*app.App, error) {
+	appName := r.URL.Query().Get(":appname")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return nil, err
+	}
+	allowed := permission.Check(t, scheme,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return nil, permission.ErrUnauthorized
+	}
+	return &a, nil
+}
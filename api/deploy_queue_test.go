@@ -0,0 +1,209 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *DeploySuite) lockAppForDeploy(c *check.C, appName string) {
+	locked, err := app.AcquireApplicationLock(appName, "someone@tsuru.io", fmt.Sprintf("POST /apps/%s/deploy", appName))
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+}
+
+func (s *DeploySuite) TestDeployQueuedOnLockedApp(c *check.C) {
+	oldDuration := lockWaitDuration
+	lockWaitDuration = 100 * time.Millisecond
+	defer func() { lockWaitDuration = oldDuration }()
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = a.SetDeployQueue(true)
+	c.Assert(err, check.IsNil)
+	s.lockAppForDeploy(c, a.Name)
+	defer app.ReleaseApplicationLock(a.Name)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("image=127.0.0.1:5000/tsuru/otherapp"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var qd app.QueuedDeploy
+	err = json.Unmarshal(recorder.Body.Bytes(), &qd)
+	c.Assert(err, check.IsNil)
+	c.Assert(qd.App, check.Equals, a.Name)
+	c.Assert(qd.Kind, check.Equals, app.DeployImage)
+	c.Assert(qd.Image, check.Equals, "127.0.0.1:5000/tsuru/otherapp")
+	deploys, err := app.ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 1)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Lock.Owner, check.Equals, "someone@tsuru.io")
+}
+
+func (s *DeploySuite) TestDeployQueuedOnLockedAppWithFile(c *check.C) {
+	oldDuration := lockWaitDuration
+	lockWaitDuration = 100 * time.Millisecond
+	defer func() { lockWaitDuration = oldDuration }()
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = a.SetDeployQueue(true)
+	c.Assert(err, check.IsNil)
+	s.lockAppForDeploy(c, a.Name)
+	defer app.ReleaseApplicationLock(a.Name)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, check.IsNil)
+	file.Write([]byte("hello world!"))
+	writer.Close()
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/deploy", a.Name), &body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Matches, "App locked by someone@tsuru.io, running POST /apps/otherapp/deploy.*")
+	deploys, err := app.ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 0)
+}
+
+func (s *DeploySuite) TestDeployQueueList(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	qd, err := app.EnqueueDeploy(app.DeployOptions{App: &a, Image: "myimage"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/deploy/queue", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var deploys []app.QueuedDeploy
+	err = json.Unmarshal(recorder.Body.Bytes(), &deploys)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 1)
+	c.Assert(deploys[0].ID, check.Equals, qd.ID)
+	c.Assert(deploys[0].Image, check.Equals, "myimage")
+}
+
+func (s *DeploySuite) TestDeployQueueListEmpty(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/deploy/queue", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *DeploySuite) TestDeployQueueUpdate(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/deploy/queue", a.Name), strings.NewReader("enabled=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployQueue, check.Equals, true)
+}
+
+func (s *DeploySuite) TestDeployQueueUpdateInvalid(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/deploy/queue", a.Name), strings.NewReader("enabled=maybe"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid value for enabled\n")
+}
+
+func (s *DeploySuite) TestDeployQueueRemove(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	qd, err := app.EnqueueDeploy(app.DeployOptions{App: &a, Image: "myimage"})
+	c.Assert(err, check.IsNil)
+	s.lockAppForDeploy(c, a.Name)
+	defer app.ReleaseApplicationLock(a.Name)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/deploy/queue/%s", a.Name, qd.ID.Hex()), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	deploys, err := app.ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 0)
+}
+
+func (s *DeploySuite) TestDeployQueueRemoveNotFound(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/deploy/queue/%s", a.Name, bson.NewObjectId().Hex()), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrQueuedDeployNotFound.Error()+"\n")
+}
@@ -21,6 +21,8 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
@@ -186,6 +188,28 @@ func (s *DeploySuite) TestDeployOriginImage(c *check.C) {
 	c.Assert(result.Origin, check.Equals, "image")
 }
 
+func (s *DeploySuite) TestDeployImageNotCancelable(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("image=127.0.0.1:5000/tsuru/otherapp"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var evt event.Event
+	err = s.conn.Events().Find(bson.M{"target.value": a.Name, "kind.name": permission.PermAppDeployImage.FullName()}).One(&evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Cancelable, check.Equals, false)
+}
+
 func (s *DeploySuite) TestDeployArchiveURL(c *check.C) {
 	user, _ := s.token.User()
 	a := app.App{
@@ -208,6 +232,16 @@ func (s *DeploySuite) TestDeployArchiveURL(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text")
 	c.Assert(recorder.Body.String(), check.Equals, "Archive deploy called\nOK\n")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy.archive-url",
+		StartCustomData: []map[string]interface{}{
+			{"name": "archive-url", "value": "http://something.tar.gz"},
+			{"name": "user", "value": "fulano"},
+		},
+		LogMatches: "Archive deploy called",
+	}, eventtest.HasEvent)
 }
 
 func (s *DeploySuite) TestDeployUploadFile(c *check.C) {
@@ -242,6 +276,62 @@ func (s *DeploySuite) TestDeployUploadFile(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "Upload deploy called\nOK\n")
 }
 
+func (s *DeploySuite) TestDeployUploadFileDockerfile(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{
+		Name:      "otherapp",
+		Platform:  "python",
+		Plan:      app.Plan{Router: "fake"},
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s&dockerfile=true", a.Name, a.Name)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, check.IsNil)
+	file.Write([]byte("hello world!"))
+	writer.Close()
+	request, err := http.NewRequest("POST", url, &body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "Dockerfile deploy called\nOK\n")
+	var deploy app.DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Image, check.Equals, "app-image")
+}
+
+func (s *DeploySuite) TestDeployDockerfileWithoutFile(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{
+		Name:      "otherapp",
+		Platform:  "python",
+		Plan:      app.Plan{Router: "fake"},
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s&dockerfile=true", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "you must upload a file to deploy with a Dockerfile.\n")
+}
+
 func (s *DeploySuite) TestDeployWithCommit(c *check.C) {
 	token, err := nativeScheme.AppLogin(app.InternalAppName)
 	c.Assert(err, check.IsNil)
@@ -472,6 +562,10 @@ func (s *DeploySuite) TestPermSchemeForDeploy(c *check.C) {
 			app.DeployOptions{File: ioutil.NopCloser(bytes.NewReader(nil)), Build: true},
 			permission.PermAppDeployBuild,
 		},
+		{
+			app.DeployOptions{File: ioutil.NopCloser(bytes.NewReader(nil)), Dockerfile: true},
+			permission.PermAppDeployDockerfile,
+		},
 		{
 			app.DeployOptions{},
 			permission.PermAppDeployArchiveUrl,
@@ -947,6 +1041,56 @@ func (s *DeploySuite) TestDeployRollbackHandlerWithInexistVersion(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"Rollback deploy called\"}\n")
 }
 
+func (s *DeploySuite) TestDeployRollbackHandlerWithRelease(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	for _, image := range []string{"tsuru/app-otherapp:v1", "tsuru/app-otherapp:v2"} {
+		err = app.Deploy(app.DeployOptions{App: &a, Image: image, OutputStream: &bytes.Buffer{}})
+		c.Assert(err, check.IsNil)
+	}
+	v := url.Values{}
+	v.Set("release", "1")
+	u := fmt.Sprintf("/apps/%s/deploy/rollback", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Rolling back to the release 1 of the app.*Rollback deploy called.*`)
+	releases, err := app.ListReleases(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 3)
+	c.Assert(releases[0].Image, check.Equals, "tsuru/app-otherapp:v1")
+	c.Assert(releases[0].RollbackOf, check.Equals, 1)
+}
+
+func (s *DeploySuite) TestDeployRollbackHandlerWithInvalidRelease(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	v := url.Values{}
+	v.Set("release", "abc")
+	u := fmt.Sprintf("/apps/%s/deploy/rollback", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid release version \"abc\"\n")
+}
+
 func (s *DeploySuite) TestDiffDeploy(c *check.C) {
 	diff := `--- hello.go	2015-11-25 16:04:22.409241045 +0000
 +++ hello.go	2015-11-18 18:40:21.385697080 +0000
@@ -1035,3 +1179,386 @@ func (s *DeploySuite) TestDiffDeployWhenUserDoesNotHaveAccessToApp(c *check.C) {
 `
 	c.Assert(recorder.Body.String(), check.Equals, expected+permission.ErrUnauthorized.Error()+"\n")
 }
+
+func (s *DeploySuite) TestDeployWithInvalidCanaryWeight(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	server := RunServer(true)
+	for _, weight := range []string{"0", "100", "abc"} {
+		v := url.Values{}
+		v.Set("image", "myimage")
+		v.Set("canary", weight)
+		u := fmt.Sprintf("/apps/%s/deploy", a.Name)
+		request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, app.ErrInvalidCanaryWeight.Error()+"\n")
+	}
+}
+
+func (s *DeploySuite) TestDeployWithCanaryInProgress(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"canary": app.Canary{Image: "myimage", Weight: 10}}})
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("image", "otherimage")
+	u := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrCanaryInProgress.Error()+"\n")
+}
+
+func (s *DeploySuite) TestDeployCanaryPromoteHandler(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"canary": app.Canary{Image: "myimage", Weight: 10}}})
+	c.Assert(err, check.IsNil)
+	u := fmt.Sprintf("/apps/%s/deploy/canary/promote", a.Name)
+	request, err := http.NewRequest("POST", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"canary myimage promoted\"}\n")
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Canary, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target:          appTarget(a.Name),
+		Owner:           s.token.GetUserName(),
+		Kind:            "app.deploy.canary.promote",
+		StartCustomData: map[string]interface{}{"image": "myimage"},
+		LogMatches:      "canary myimage promoted",
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployCanaryAbortHandler(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"canary": app.Canary{Image: "myimage", Weight: 10}}})
+	c.Assert(err, check.IsNil)
+	u := fmt.Sprintf("/apps/%s/deploy/canary/abort", a.Name)
+	request, err := http.NewRequest("POST", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"canary myimage aborted\"}\n")
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Canary, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target:          appTarget(a.Name),
+		Owner:           s.token.GetUserName(),
+		Kind:            "app.deploy.canary.abort",
+		StartCustomData: map[string]interface{}{"image": "myimage"},
+		LogMatches:      "canary myimage aborted",
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployCanaryAbortHandlerWithoutCanary(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	u := fmt.Sprintf("/apps/%s/deploy/canary/abort", a.Name)
+	request, err := http.NewRequest("POST", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrNoCanary.Error()+"\n")
+}
+
+func (s *DeploySuite) TestDeployCanaryPromoteHandlerWithoutPermission(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeployCanaryAbort,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	u := fmt.Sprintf("/apps/%s/deploy/canary/promote", a.Name)
+	request, err := http.NewRequest("POST", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *DeploySuite) TestDeployPromoteHandler(c *check.C) {
+	user, _ := s.token.User()
+	source := app.App{Name: "myapp-staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&source, nil)
+	dest := app.App{Name: "myapp-prod", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&dest, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&dest, nil)
+	defer s.logConn.Logs(dest.Name).DropCollection()
+	v := url.Values{}
+	v.Set("to", dest.Name)
+	v.Set("image", "app-image-old")
+	u := fmt.Sprintf("/apps/%s/promote", source.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Promoting the image app-image-old from the app \\"myapp-staging\\" to the app \\"myapp-prod\\".*Promote image called.*`)
+	var deploy app.DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": dest.Name}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Image, check.Equals, "app-image-old")
+	c.Assert(deploy.Origin, check.Equals, "promote:myapp-staging")
+	c.Assert(eventtest.EventDesc{
+		Target:     appTarget(dest.Name),
+		Owner:      s.token.GetUserName(),
+		Kind:       "app.deploy.promote",
+		LogMatches: "Promote image called",
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployPromoteHandlerWithoutDestination(c *check.C) {
+	user, _ := s.token.User()
+	source := app.App{Name: "myapp-staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&source, nil)
+	u := fmt.Sprintf("/apps/%s/promote", source.Name)
+	request, err := http.NewRequest("POST", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "you must specify the app to promote the image to\n")
+}
+
+func (s *DeploySuite) TestDeployPromoteHandlerWithoutPermissionInDestination(c *check.C) {
+	user, _ := s.token.User()
+	source := app.App{Name: "myapp-staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&source, nil)
+	dest := app.App{Name: "myapp-prod", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&dest, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&dest, nil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppDeployPromote,
+		Context: permission.Context(permission.CtxApp, source.Name),
+	})
+	v := url.Values{}
+	v.Set("to", dest.Name)
+	u := fmt.Sprintf("/apps/%s/promote", source.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	var deploys []app.DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": dest.Name}).All(&deploys)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 0)
+}
+
+func (s *DeploySuite) TestDeployReturnsDeployID(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	id := recorder.Header().Get("X-Tsuru-Deploy-Id")
+	c.Assert(bson.IsObjectIdHex(id), check.Equals, true)
+	deploy, err := app.GetDeploy(id)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.App, check.Equals, a.Name)
+	c.Assert(deploy.Log, check.Equals, "Archive deploy called")
+	c.Assert(deploy.Running, check.Equals, false)
+}
+
+func (s *DeploySuite) TestDeployLog(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	deploy := app.DeployData{ID: bson.NewObjectId(), App: a.Name, Image: "app-image", Log: "---- Building ----\nOK\n"}
+	err = s.conn.Deploys().Insert(deploy)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/deploys/"+deploy.ID.Hex()+"/log", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text")
+	c.Assert(recorder.Body.String(), check.Equals, "---- Building ----\nOK\n")
+}
+
+func (s *DeploySuite) TestDeployLogByUserWithoutAccess(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	deploy := app.DeployData{ID: bson.NewObjectId(), App: a.Name, Log: "secret output"}
+	err = s.conn.Deploys().Insert(deploy)
+	c.Assert(err, check.IsNil)
+	token := customUserWithPermission(c, "myuser", permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxApp, "otherapp"),
+	})
+	request, err := http.NewRequest("GET", "/deploys/"+deploy.ID.Hex()+"/log", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *DeploySuite) TestDeployCancel(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       permission.PermAppDeploy,
+		Owner:      s.token,
+		Cancelable: true,
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	deploy := app.DeployData{ID: evt.UniqueID, App: a.Name, Image: "diff", Running: true}
+	err = s.conn.Deploys().Insert(deploy)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("reason=wrong branch")
+	request, err := http.NewRequest("POST", "/deploys/"+deploy.ID.Hex()+"/cancel", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	got, err := event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.CancelInfo.Asked, check.Equals, true)
+	c.Assert(got.CancelInfo.Reason, check.Equals, "wrong branch")
+}
+
+func (s *DeploySuite) TestDeployCancelProvisionerNotSupported(c *check.C) {
+	oldProvisioner := app.Provisioner
+	app.Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() { app.Provisioner = oldProvisioner }()
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	deploy := app.DeployData{ID: bson.NewObjectId(), App: a.Name, Image: "diff", Running: true}
+	err = s.conn.Deploys().Insert(deploy)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("reason=wrong branch")
+	request, err := http.NewRequest("POST", "/deploys/"+deploy.ID.Hex()+"/cancel", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, "the provisioner of the app does not support deploy cancellation\n")
+}
+
+func (s *DeploySuite) TestDeployCancelNotRunning(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	deploy := app.DeployData{ID: bson.NewObjectId(), App: a.Name, Image: "app-image"}
+	err = s.conn.Deploys().Insert(deploy)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body string
+		code int
+		msg  string
+	}{
+		{"", http.StatusBadRequest, "reason is mandatory\n"},
+		{"reason=wrong branch", http.StatusConflict, "deploy is not cancelable\n"},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/deploys/"+deploy.ID.Hex()+"/cancel", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, tt.code)
+		c.Check(recorder.Body.String(), check.Equals, tt.msg)
+	}
+}
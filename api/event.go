@@ -0,0 +1,290 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func hasGlobalContext(contexts []permission.PermissionContext) bool {
+	for _, c := range contexts {
+		if c.CtxType == permission.CtxGlobal {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTargetsForToken returns the targets whose events may be read by the
+// owner of the given token.
+func eventTargetsForToken(t auth.Token) ([]event.TargetFilter, error) {
+	allowed := []event.TargetFilter{}
+	contexts := permission.ContextsForPermission(t, permission.PermAppReadEvents)
+	if len(contexts) > 0 {
+		if hasGlobalContext(contexts) {
+			allowed = append(allowed, event.TargetFilter{Type: event.TargetTypeApp})
+		} else {
+			apps, err := app.List(appFilterByContext(contexts, nil))
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, len(apps))
+			for i, a := range apps {
+				names[i] = a.Name
+			}
+			allowed = append(allowed, event.TargetFilter{Type: event.TargetTypeApp, Values: names})
+		}
+	}
+	contexts = permission.ContextsForPermission(t, permission.PermPoolReadEvents)
+	if len(contexts) > 0 {
+		if hasGlobalContext(contexts) {
+			allowed = append(allowed, event.TargetFilter{Type: event.TargetTypePool})
+		} else {
+			pools := []string{}
+			for _, c := range contexts {
+				if c.CtxType == permission.CtxPool {
+					pools = append(pools, c.Value)
+				}
+			}
+			allowed = append(allowed, event.TargetFilter{Type: event.TargetTypePool, Values: pools})
+		}
+	}
+	contexts = permission.ContextsForPermission(t, permission.PermNodeReadEvents)
+	if hasGlobalContext(contexts) {
+		allowed = append(allowed,
+			event.TargetFilter{Type: event.TargetTypeNode},
+			event.TargetFilter{Type: event.TargetTypeContainer},
+		)
+	}
+	contexts = permission.ContextsForPermission(t, permission.PermServiceInstanceReadEvents)
+	if len(contexts) > 0 {
+		if hasGlobalContext(contexts) {
+			allowed = append(allowed, event.TargetFilter{Type: event.TargetTypeServiceInstance})
+		} else {
+			var teams []string
+			names := []string{}
+			for _, c := range contexts {
+				switch c.CtxType {
+				case permission.CtxServiceInstance:
+					names = append(names, c.Value)
+				case permission.CtxTeam:
+					teams = append(teams, c.Value)
+				}
+			}
+			if len(teams) > 0 {
+				instances, err := service.GetServicesInstancesByTeamsAndNames(teams, []string{}, "", "")
+				if err != nil {
+					return nil, err
+				}
+				for _, si := range instances {
+					names = append(names, si.ServiceName+"/"+si.Name)
+				}
+			}
+			allowed = append(allowed, event.TargetFilter{Type: event.TargetTypeServiceInstance, Values: names})
+		}
+	}
+	return allowed, nil
+}
+
+func eventFilterFromQuery(r *http.Request) (*event.Filter, error) {
+	query := r.URL.Query()
+	filter := &event.Filter{
+		KindType:  event.KindType(query.Get("kindtype")),
+		KindName:  query.Get("kindname"),
+		OwnerType: event.OwnerType(query.Get("ownertype")),
+		OwnerName: query.Get("ownername"),
+	}
+	if targetType := query.Get("target.type"); targetType != "" {
+		tt, err := event.GetTargetType(targetType)
+		if err != nil {
+			return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		filter.Target.Type = tt
+	}
+	filter.Target.Value = query.Get("target.value")
+	if running := query.Get("running"); running != "" {
+		v, err := strconv.ParseBool(running)
+		if err != nil {
+			return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for running: " + running}
+		}
+		filter.Running = &v
+	}
+	for _, part := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := query.Get(part.name)
+		if value == "" {
+			continue
+		}
+		v, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for " + part.name + ": " + value}
+		}
+		*part.dst = v
+	}
+	filter.Skip, _ = strconv.Atoi(query.Get("skip"))
+	filter.Limit, _ = strconv.Atoi(query.Get("limit"))
+	return filter, nil
+}
+
+// title: event list
+// path: /events
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid filter
+//   401: Unauthorized
+func eventList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	filter, err := eventFilterFromQuery(r)
+	if err != nil {
+		return err
+	}
+	filter.AllowedTargets, err = eventTargetsForToken(t)
+	if err != nil {
+		return err
+	}
+	events, err := event.List(filter)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(events)
+}
+
+func getReadableEvent(r *http.Request, t auth.Token) (*event.Event, error) {
+	uuid := r.URL.Query().Get(":uuid")
+	if !bson.IsObjectIdHex(uuid) {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: "uuid parameter is not ObjectId: " + uuid}
+	}
+	evt, err := event.GetByID(bson.ObjectIdHex(uuid))
+	if err == event.ErrEventNotFound {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := eventTargetsForToken(t)
+	if err != nil {
+		return nil, err
+	}
+	for _, at := range allowed {
+		if at.Type != evt.Target.Type {
+			continue
+		}
+		if at.Values == nil {
+			return evt, nil
+		}
+		for _, v := range at.Values {
+			if v == evt.Target.Value {
+				return evt, nil
+			}
+		}
+	}
+	return nil, &errors.HTTP{Code: http.StatusNotFound, Message: event.ErrEventNotFound.Error()}
+}
+
+// title: event info
+// path: /events/{uuid}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid uuid
+//   401: Unauthorized
+//   404: Not found
+func eventInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	evt, err := getReadableEvent(r, t)
+	if err != nil {
+		return err
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(evt)
+}
+
+// canCancelEvent checks whether the owner of the token has the permission
+// used to start the event, in the context of the event target.
+func canCancelEvent(t auth.Token, evt *event.Event) bool {
+	if evt.Kind.Type != event.KindTypePermission {
+		return permission.Check(t, permission.PermAll)
+	}
+	var scheme *permission.PermissionScheme
+	for _, s := range permission.PermissionRegistry.Permissions() {
+		if s.FullName() == evt.Kind.Name {
+			scheme = s
+			break
+		}
+	}
+	if scheme == nil {
+		return false
+	}
+	var contexts []permission.PermissionContext
+	switch evt.Target.Type {
+	case event.TargetTypeApp:
+		a, err := app.GetByName(evt.Target.Value)
+		if err != nil {
+			return false
+		}
+		contexts = append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)
+	case event.TargetTypePool:
+		contexts = append(contexts, permission.Context(permission.CtxPool, evt.Target.Value))
+	case event.TargetTypeServiceInstance:
+		contexts = append(contexts, permission.Context(permission.CtxServiceInstance, evt.Target.Value))
+	}
+	return permission.Check(t, scheme, contexts...)
+}
+
+// title: event cancel
+// path: /events/{uuid}/cancel
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   204: OK
+//   400: Invalid uuid or empty reason
+//   401: Unauthorized
+//   404: Not found
+//   409: Event is not cancelable
+func eventCancel(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	evt, err := getReadableEvent(r, t)
+	if err != nil {
+		return err
+	}
+	if !canCancelEvent(t, evt) {
+		return permission.ErrUnauthorized
+	}
+	reason := r.FormValue("reason")
+	if reason == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "reason is mandatory"}
+	}
+	err = evt.TryCancel(reason, t.GetUserName())
+	if err == event.ErrNotCancelable {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
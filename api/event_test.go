@@ -0,0 +1,230 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createEvents(c *check.C) (*event.Event, *event.Event) {
+	evt1, err := event.New(&event.Opts{
+		Target: event.Target{Type: event.TargetTypeApp, Value: "app1"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt1.Done(nil), check.IsNil)
+	evt2, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeNode, Value: "http://10.0.0.1:2375"},
+		InternalKind: "healer",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt2.Done(nil), check.IsNil)
+	return evt1, evt2
+}
+
+func (s *S) TestEventList(c *check.C) {
+	evt1, evt2 := s.createEvents(c)
+	request, err := http.NewRequest("GET", "/events", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []event.Event
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	c.Assert(result[0].UniqueID, check.Equals, evt2.UniqueID)
+	c.Assert(result[1].UniqueID, check.Equals, evt1.UniqueID)
+}
+
+func (s *S) TestEventListFilterByTarget(c *check.C) {
+	evt1, _ := s.createEvents(c)
+	request, err := http.NewRequest("GET", "/events?target.type=app&target.value=app1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []event.Event
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].UniqueID, check.Equals, evt1.UniqueID)
+}
+
+func (s *S) TestEventListInvalidTargetType(c *check.C) {
+	request, err := http.NewRequest("GET", "/events?target.type=invalid", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, event.ErrInvalidTargetType.Error()+"\n")
+}
+
+func (s *S) TestEventListWithoutPermissions(c *check.C) {
+	s.createEvents(c)
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/events", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestEventListFilteredByAppPermission(c *check.C) {
+	a := app.App{Name: "app1", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt1, _ := s.createEvents(c)
+	evt3, err := event.New(&event.Opts{
+		Target: event.Target{Type: event.TargetTypeApp, Value: "app2"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt3.Done(nil), check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEvents,
+		Context: permission.Context(permission.CtxApp, "app1"),
+	})
+	request, err := http.NewRequest("GET", "/events", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []event.Event
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].UniqueID, check.Equals, evt1.UniqueID)
+}
+
+func (s *S) TestEventInfo(c *check.C) {
+	evt1, _ := s.createEvents(c)
+	request, err := http.NewRequest("GET", "/events/"+evt1.UniqueID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result event.Event
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.UniqueID, check.Equals, evt1.UniqueID)
+	c.Assert(result.Kind, check.DeepEquals, evt1.Kind)
+	c.Assert(result.Target, check.DeepEquals, evt1.Target)
+}
+
+func (s *S) TestEventInfoInvalidID(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/xyz", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestEventInfoWithoutPermission(c *check.C) {
+	_, evt2 := s.createEvents(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEvents,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/events/"+evt2.UniqueID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestEventCancel(c *check.C) {
+	a := app.App{Name: "app1", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: "app1"},
+		Kind:       permission.PermAppDeploy,
+		Owner:      s.token,
+		Cancelable: true,
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	body := strings.NewReader("reason=we ain't gonna take it")
+	request, err := http.NewRequest("POST", "/events/"+evt.UniqueID.Hex()+"/cancel", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	got, err := event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.CancelInfo.Asked, check.Equals, true)
+	c.Assert(got.CancelInfo.Reason, check.Equals, "we ain't gonna take it")
+	c.Assert(got.CancelInfo.Owner, check.Equals, s.token.GetUserName())
+}
+
+func (s *S) TestEventCancelNotCancelable(c *check.C) {
+	evt, err := event.New(&event.Opts{
+		Target: event.Target{Type: event.TargetTypeApp, Value: "app1"},
+		Kind:   permission.PermAppDeploy,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	body := strings.NewReader("reason=because")
+	request, err := http.NewRequest("POST", "/events/"+evt.UniqueID.Hex()+"/cancel", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestEventCancelWithoutReason(c *check.C) {
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: "app1"},
+		Kind:       permission.PermAppDeploy,
+		Owner:      s.token,
+		Cancelable: true,
+	})
+	c.Assert(err, check.IsNil)
+	defer evt.Done(nil)
+	request, err := http.NewRequest("POST", "/events/"+evt.UniqueID.Hex()+"/cancel", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,279 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func jobError(err error) error {
+	switch err {
+	case app.ErrJobNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrJobAlreadyExists, app.ErrJobRunning:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case app.ErrJobInvalidName, app.ErrJobCommandRequired, app.ErrJobsNotSupported, app.ErrJobAppNotDeployed:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// getJobApp returns the app in the request, checking whether the user has the
+// given permission in it.
+func getJobApp(r *http.Request, t auth.Token, perm *permission.PermissionScheme) (*app.App, error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return nil, err
+	}
+	allowed := permission.Check(t, perm,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return nil, permission.ErrUnauthorized
+	}
+	return &a, nil
+}
+
+// title: job list
+// path: /apps/{app}/jobs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func jobList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getJobApp(r, t, permission.PermAppReadJob)
+	if err != nil {
+		return err
+	}
+	jobs, err := a.Jobs()
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(jobs)
+}
+
+// title: job info
+// path: /apps/{app}/jobs/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func jobInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getJobApp(r, t, permission.PermAppReadJob)
+	if err != nil {
+		return err
+	}
+	job, err := a.GetJob(r.URL.Query().Get(":name"))
+	if err != nil {
+		return jobError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(job)
+}
+
+// title: job create
+// path: /apps/{app}/jobs
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Job created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+//   409: Job already exists
+func jobCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	job := app.Job{
+		Name:     r.FormValue("name"),
+		Command:  r.FormValue("command"),
+		Schedule: r.FormValue("schedule"),
+	}
+	a, err := getJobApp(r, t, permission.PermAppUpdateJobCreate)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppUpdateJobCreate,
+		Owner:       t,
+		DisableLock: true,
+		CustomData:  event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.AddJob(job)
+	if err != nil {
+		return jobError(err)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// title: job update
+// path: /apps/{app}/jobs/{name}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Job updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func jobUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	command := r.FormValue("command")
+	schedule := r.FormValue("schedule")
+	a, err := getJobApp(r, t, permission.PermAppUpdateJobUpdate)
+	if err != nil {
+		return err
+	}
+	current, err := a.GetJob(r.URL.Query().Get(":name"))
+	if err != nil {
+		return jobError(err)
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppUpdateJobUpdate,
+		Owner:       t,
+		DisableLock: true,
+		CustomData:  append(event.FormToCustomData(r.Form), map[string]interface{}{"name": ":name", "value": current.Name}),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	if command != "" {
+		current.Command = command
+	}
+	if schedule != "" {
+		current.Schedule = schedule
+	}
+	return jobError(a.UpdateJob(*current))
+}
+
+// title: job delete
+// path: /apps/{app}/jobs/{name}
+// method: DELETE
+// responses:
+//   200: Job removed
+//   401: Unauthorized
+//   404: Not found
+func jobDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getJobApp(r, t, permission.PermAppUpdateJobDelete)
+	if err != nil {
+		return err
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppUpdateJobDelete,
+		Owner:       t,
+		DisableLock: true,
+		CustomData:  []map[string]interface{}{{"name": ":name", "value": name}},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return jobError(a.RemoveJob(name))
+}
+
+// title: job run
+// path: /apps/{app}/jobs/{name}/run
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func jobRun(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getJobApp(r, t, permission.PermAppUpdateJobRun)
+	if err != nil {
+		return err
+	}
+	name := r.URL.Query().Get(":name")
+	if _, err = a.GetJob(name); err != nil {
+		return jobError(err)
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppUpdateJobRun,
+		Owner:       t,
+		DisableLock: true,
+		CustomData:  []map[string]interface{}{{"name": ":name", "value": name}},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	run, err := a.RunJob(name, evt)
+	if err == nil && run.ExitCode != 0 {
+		err = fmt.Errorf("job %q finished with exit code %d", name, run.ExitCode)
+	}
+	if err != nil {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+	}
+	return nil
+}
+
+// title: job runs
+// path: /apps/{app}/jobs/{name}/runs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: Not found
+func jobRuns(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getJobApp(r, t, permission.PermAppReadJob)
+	if err != nil {
+		return err
+	}
+	name := r.URL.Query().Get(":name")
+	if _, err = a.GetJob(name); err != nil {
+		return jobError(err)
+	}
+	skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	runs, err := a.JobRuns(name, skip, limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(runs)
+}
@@ -0,0 +1,329 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestJobList(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	err = a.AddJob(app.Job{Name: "cleanup", Command: "./cleanup.sh", Schedule: "@hourly"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []app.Job
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	c.Assert(result[0].Name, check.Equals, "backup")
+	c.Assert(result[0].Command, check.Equals, "./backup.sh")
+	c.Assert(result[1].Name, check.Equals, "cleanup")
+}
+
+func (s *S) TestJobListEmpty(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestJobListWithoutPermission(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxApp, "other-app"),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestJobInfo(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/backup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result app.Job
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Name, check.Equals, "backup")
+	c.Assert(result.App, check.Equals, "myapp")
+	c.Assert(result.Schedule, check.Equals, "0 3 * * *")
+}
+
+func (s *S) TestJobInfoNotFound(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/backup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestJobCreate(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateJobCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("name=backup&command=./backup.sh&schedule=0+3+*+*+*")
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	job, err := a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Command, check.Equals, "./backup.sh")
+	c.Assert(job.Schedule, check.Equals, "0 3 * * *")
+	c.Assert(job.NextRun.IsZero(), check.Equals, false)
+	u, err := token.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  u.Email,
+		Kind:   "app.update.job.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "backup"},
+			{"name": "command", "value": "./backup.sh"},
+			{"name": "schedule", "value": "0 3 * * *"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestJobCreateInvalidSchedule(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	body := strings.NewReader("name=backup&command=./backup.sh&schedule=61+*+*+*+*")
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s)invalid schedule "61 \* \* \* \*".*`)
+}
+
+func (s *S) TestJobCreateAlreadyExists(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("name=backup&command=./other.sh&schedule=@daily")
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestJobUpdate(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("schedule=@hourly")
+	request, err := http.NewRequest("PUT", "/apps/myapp/jobs/backup", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	job, err := a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Command, check.Equals, "./backup.sh")
+	c.Assert(job.Schedule, check.Equals, "@hourly")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.job.update",
+		StartCustomData: []map[string]interface{}{
+			{"name": "schedule", "value": "@hourly"},
+			{"name": ":name", "value": "backup"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestJobUpdateNotFound(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	body := strings.NewReader("schedule=@hourly")
+	request, err := http.NewRequest("PUT", "/apps/myapp/jobs/backup", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestJobDelete(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/myapp/jobs/backup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = a.GetJob("backup")
+	c.Assert(err, check.Equals, app.ErrJobNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target:          appTarget(a.Name),
+		Owner:           s.token.GetUserName(),
+		Kind:            "app.update.job.delete",
+		StartCustomData: []map[string]interface{}{{"name": ":name", "value": "backup"}},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestJobDeleteWithoutPermission(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("DELETE", "/apps/myapp/jobs/backup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestJobRun(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareOutput([]byte("backup done"))
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs/backup/run", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"backup done"}`+"\n")
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Manual, check.Equals, true)
+	c.Assert(runs[0].Output, check.Equals, "backup done")
+	c.Assert(eventtest.EventDesc{
+		Target:          appTarget(a.Name),
+		Owner:           s.token.GetUserName(),
+		Kind:            "app.update.job.run",
+		StartCustomData: []map[string]interface{}{{"name": ":name", "value": "backup"}},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestJobRunExitCode(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareOutput([]byte("disk full"))
+	s.provisioner.PrepareExitCode(2)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs/backup/run", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"disk full"}`+"\n"+`{"Message":"","Error":"job \"backup\" finished with exit code 2"}`+"\n")
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].ExitCode, check.Equals, 2)
+}
+
+func (s *S) TestJobRuns(c *check.C) {
+	a := s.createTestApp(c, bson.M{"deploys": 1})
+	err := a.AddJob(app.Job{Name: "backup", Command: "./backup.sh", Schedule: "0 3 * * *"})
+	c.Assert(err, check.IsNil)
+	for i := 0; i < 3; i++ {
+		_, err = a.RunJob("backup", nil)
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/backup/runs?limit=2", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []app.JobRun
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	c.Assert(result[0].Job, check.Equals, "backup")
+}
+
+func (s *S) TestJobRunsJobNotFound(c *check.C) {
+	s.createTestApp(c, bson.M{"deploys": 1})
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/backup/runs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -8,12 +8,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"runtime"
 
+	"github.com/cezarsa/form"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/api/context"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
 	"golang.org/x/net/websocket"
 )
 
@@ -69,3 +75,89 @@ func scanLogs(stream io.Reader) error {
 	dispatcher.Stop()
 	return nil
 }
+
+// title: log service config list
+// path: /logs/config
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+func logServiceConfigList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermPoolUpdateLogs)
+	if len(contexts) == 0 {
+		return permission.ErrUnauthorized
+	}
+	configs, err := app.LogServiceConfigLoadAll()
+	if err != nil {
+		return err
+	}
+	pools := make([]string, 0, len(contexts))
+	for _, ctx := range contexts {
+		if ctx.CtxType == permission.CtxGlobal {
+			pools = nil
+			break
+		}
+		if ctx.CtxType == permission.CtxPool {
+			pools = append(pools, ctx.Value)
+		}
+	}
+	if pools != nil {
+		filtered := map[string]app.LogServiceConfig{}
+		for _, p := range pools {
+			if entry, ok := configs[p]; ok {
+				filtered[p] = entry
+			}
+		}
+		configs = filtered
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(configs)
+}
+
+// title: log service config set
+// path: /logs/config
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func logServiceConfigSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	pool := r.FormValue("pool")
+	var conf app.LogServiceConfig
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&conf, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var allowed bool
+	if pool == "" {
+		allowed = permission.Check(t, permission.PermPoolUpdateLogs)
+	} else {
+		allowed = permission.Check(t, permission.PermPoolUpdateLogs, permission.Context(permission.CtxPool, pool))
+	}
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: pool},
+		Kind:       permission.PermPoolUpdateLogs,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = conf.Save(pool)
+	if err == app.ErrInvalidLogBackend || err == app.ErrLogBackendURLRequired {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
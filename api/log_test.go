@@ -5,8 +5,10 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"sort"
@@ -18,6 +20,9 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/repository/repositorytest"
 	"golang.org/x/net/websocket"
 	"gopkg.in/check.v1"
@@ -198,3 +203,86 @@ func (s *S) BenchmarkScanLogs(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.StopTimer()
 }
+
+func (s *S) TestLogServiceConfigSet(c *check.C) {
+	body := strings.NewReader("pool=pool1&Backend=elasticsearch&URL=http://localhost:9200&Index=logs")
+	request, err := http.NewRequest("POST", "/logs/config", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	configs, err := app.LogServiceConfigLoadAll()
+	c.Assert(err, check.IsNil)
+	c.Assert(configs["pool1"], check.DeepEquals, app.LogServiceConfig{
+		Backend: "elasticsearch",
+		URL:     "http://localhost:9200",
+		Index:   "logs",
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.logs",
+		StartCustomData: []map[string]interface{}{
+			{"name": "pool", "value": "pool1"},
+			{"name": "Backend", "value": "elasticsearch"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestLogServiceConfigSetInvalid(c *check.C) {
+	body := strings.NewReader("pool=pool1&Backend=elasticsearch")
+	request, err := http.NewRequest("POST", "/logs/config", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrLogBackendURLRequired.Error()+"\n")
+}
+
+func (s *S) TestLogServiceConfigSetDefaultRequiresGlobalPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateLogs,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	body := strings.NewReader("Backend=mongodb")
+	request, err := http.NewRequest("POST", "/logs/config", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestLogServiceConfigList(c *check.C) {
+	conf := app.LogServiceConfig{Backend: "elasticsearch", URL: "http://localhost:9200"}
+	err := conf.Save("pool1")
+	c.Assert(err, check.IsNil)
+	err = conf.Save("pool2")
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateLogs,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	request, err := http.NewRequest("GET", "/logs/config", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var configs map[string]app.LogServiceConfig
+	err = json.Unmarshal(recorder.Body.Bytes(), &configs)
+	c.Assert(err, check.IsNil)
+	c.Assert(configs, check.DeepEquals, map[string]app.LogServiceConfig{
+		"pool1": {Backend: "elasticsearch", URL: "http://localhost:9200"},
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/yaml.v1"
+)
+
+// title: export app manifest
+// path: /apps/{app}/manifest
+// method: GET
+// produce: application/json, application/x-yaml
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: App not found
+func exportManifest(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadEnv,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	m, err := a.Manifest()
+	if err != nil {
+		return err
+	}
+	if r.URL.Query().Get("format") == "yaml" {
+		data, err := yaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/x-yaml")
+		_, err = w.Write(data)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(m)
+}
+
+// title: apply app manifest
+// path: /apps/{app}/apply
+// method: POST
+// consume: application/x-yaml, application/json
+// produce: application/json, application/x-json-stream
+// responses:
+//   200: OK
+//   400: Invalid manifest
+//   401: Unauthorized
+//   404: App not found
+func applyManifest(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry-run"))
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	appContexts := append(permission.Contexts(permission.CtxTeam, a.Teams),
+		permission.Context(permission.CtxApp, a.Name),
+		permission.Context(permission.CtxPool, a.Pool),
+	)
+	if !permission.Check(t, permission.PermAppRead, appContexts...) {
+		return permission.ErrUnauthorized
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	m, err := app.ParseManifest(data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	changes, err := a.DiffManifest(m)
+	if err != nil {
+		if _, ok := err.(*errors.ValidationError); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	if changes == nil {
+		changes = []app.ManifestChange{}
+	}
+	if !permission.Check(t, permission.PermAppReadEnv, appContexts...) {
+		for i := range changes {
+			changes[i].MaskEnvValue()
+		}
+	}
+	if dryRun {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(changes)
+	}
+	for i := range changes {
+		if !permission.Check(t, manifestChangePermission(&changes[i]), appContexts...) {
+			return permission.ErrUnauthorized
+		}
+		if env := changes[i].Env(); env != nil {
+			err = checkSecretReference(t, env.Value)
+			if err != nil {
+				return err
+			}
+		}
+		instance := changes[i].ServiceInstance()
+		if instance == nil {
+			continue
+		}
+		instancePerm := permission.PermServiceInstanceUpdateBind
+		if changes[i].Action == app.ManifestActionRemove {
+			instancePerm = permission.PermServiceInstanceUpdateUnbind
+		}
+		allowed := permission.Check(t, instancePerm,
+			append(permission.Contexts(permission.CtxTeam, instance.Teams),
+				permission.Context(permission.CtxServiceInstance, instance.Name),
+			)...,
+		)
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdate,
+		Owner:      t,
+		CustomData: changes,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	err = a.ApplyManifest(changes, evt)
+	if err != nil {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		return nil
+	}
+	return nil
+}
+
+func manifestChangePermission(change *app.ManifestChange) *permission.PermissionScheme {
+	remove := change.Action == app.ManifestActionRemove
+	switch change.Field {
+	case app.ManifestFieldDescription:
+		return permission.PermAppUpdateDescription
+	case app.ManifestFieldPlan:
+		return permission.PermAppUpdatePlan
+	case app.ManifestFieldPool:
+		return permission.PermAppUpdatePool
+	case app.ManifestFieldTeamOwner:
+		return permission.PermAppUpdateTeamowner
+	case app.ManifestFieldEnv:
+		if remove {
+			return permission.PermAppUpdateEnvUnset
+		}
+		return permission.PermAppUpdateEnvSet
+	case app.ManifestFieldCName:
+		if remove {
+			return permission.PermAppUpdateCnameRemove
+		}
+		return permission.PermAppUpdateCnameAdd
+	case app.ManifestFieldService:
+		if remove {
+			return permission.PermAppUpdateUnbind
+		}
+		return permission.PermAppUpdateBind
+	case app.ManifestFieldUnits:
+		if remove {
+			return permission.PermAppUpdateUnitRemove
+		}
+		return permission.PermAppUpdateUnitAdd
+	}
+	return permission.PermAppUpdate
+}
@@ -0,0 +1,258 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestExportManifest(c *check.C) {
+	a := s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEnv,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/manifest", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var m app.Manifest
+	err = json.Unmarshal(recorder.Body.Bytes(), &m)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, app.Manifest{
+		Name:        "myapp",
+		Description: "my app",
+		Plan:        a.Plan.Name,
+		Pool:        a.Pool,
+		TeamOwner:   s.team.Name,
+		Envs: &[]app.ManifestEnv{
+			{Name: "DEBUG", Value: "1"},
+			{Name: "PASSWORD", Value: "secret", Private: true},
+		},
+		CNames:   &[]string{},
+		Services: &[]app.ManifestService{},
+	})
+}
+
+func (s *S) TestExportManifestYAML(c *check.C) {
+	a := s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	request, err := http.NewRequest("GET", "/apps/myapp/manifest?format=yaml", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-yaml")
+	m, err := app.ParseManifest(recorder.Body.Bytes())
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Name, check.Equals, a.Name)
+	c.Assert(*m.Envs, check.DeepEquals, []app.ManifestEnv{
+		{Name: "DEBUG", Value: "1"},
+		{Name: "PASSWORD", Value: "secret", Private: true},
+	})
+}
+
+func (s *S) TestExportManifestForbidden(c *check.C) {
+	a := s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/manifest", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestApplyManifestDryRun(c *check.C) {
+	a := s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`envs:
+- name: DEBUG
+  value: "0"
+- name: PASSWORD
+  value: secret
+  private: true
+cnames:
+- myapp.example.com
+`)
+	request, err := http.NewRequest("POST", "/apps/myapp/apply?dry-run=true", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-yaml")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var changes []app.ManifestChange
+	err = json.Unmarshal(recorder.Body.Bytes(), &changes)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []app.ManifestChange{
+		{Field: "env", Action: "update", Name: "DEBUG", Old: "*****", New: "0"},
+		{Field: "cname", Action: "add", Name: "myapp.example.com"},
+	})
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DEBUG"].Value, check.Equals, "1")
+	c.Assert(dbApp.CName, check.HasLen, 0)
+}
+
+func (s *S) TestApplyManifestDryRunWithEnvPermission(c *check.C) {
+	a := s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppReadEnv,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`{"envs": [{"name": "DEBUG", "value": "0"}]}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/apply?dry-run=true", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var changes []app.ManifestChange
+	err = json.Unmarshal(recorder.Body.Bytes(), &changes)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []app.ManifestChange{
+		{Field: "env", Action: "update", Name: "DEBUG", Old: "1", New: "0"},
+		{Field: "env", Action: "remove", Name: "PASSWORD", Old: "*****"},
+	})
+}
+
+func (s *S) TestApplyManifest(c *check.C) {
+	a := s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvUnset,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`{"name": "myapp", "envs": [{"name": "DEBUG", "value": "0"}]}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/apply", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Applying 2 changes to the app \\"myapp\\".*`)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DEBUG"].Value, check.Equals, "0")
+	_, ok := dbApp.Env["PASSWORD"]
+	c.Assert(ok, check.Equals, false)
+	u, err := token.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  u.Email,
+		Kind:   "app.update",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestApplyManifestForbidden(c *check.C) {
+	a := s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`envs:
+- name: DEBUG
+  value: "1"
+`)
+	request, err := http.NewRequest("POST", "/apps/myapp/apply", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	_, ok := dbApp.Env["PASSWORD"]
+	c.Assert(ok, check.Equals, true)
+}
+
+func (s *S) TestApplyManifestInvalid(c *check.C) {
+	s.createTestApp(c, bson.M{"description": "my app"},
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"name: [myapp", "unable to parse manifest: .*"},
+		{"name: otherapp", `manifest is for the app "otherapp", not "myapp"`},
+		{"units:\n  web: -1", `invalid number of units for the process "web": -1`},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/apps/myapp/apply", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(strings.TrimSpace(recorder.Body.String()), check.Matches, tt.msg)
+	}
+}
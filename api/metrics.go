@@ -0,0 +1,62 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const unknownRoute = "unknown"
+
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tsuru",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "The number of requests handled by the API, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tsuru",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "The time taken by the API to handle requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCount, requestDuration)
+}
+
+func observeRequest(method, route string, statusCode int, duration time.Duration) {
+	if route == "" {
+		route = unknownRoute
+	}
+	requestCount.WithLabelValues(method, route, strconv.Itoa(statusCode)).Inc()
+	requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// title: metrics
+// path: /metrics
+// method: GET
+// produce: text/plain
+// responses:
+//   200: Ok
+//   401: Unauthorized
+func metricsHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermMetricsRead) {
+		return permission.ErrUnauthorized
+	}
+	promhttp.Handler().ServeHTTP(w, r)
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/codegangsta/negroni"
+	dto "github.com/prometheus/client_model/go"
+	apiRouter "github.com/tsuru/tsuru/api/router"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func requestCountValue(c *check.C, method, route, code string) float64 {
+	var m dto.Metric
+	err := requestCount.WithLabelValues(method, route, code).Write(&m)
+	c.Assert(err, check.IsNil)
+	return m.GetCounter().GetValue()
+}
+
+func (s *S) TestLoggerMiddlewareObservesRequests(c *check.C) {
+	router := apiRouter.NewRouter()
+	router.Add("1.0", "PUT", "/my/{thing}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	before := requestCountValue(c, "PUT", "/my/{thing}", "201")
+	unknownBefore := requestCountValue(c, "PUT", unknownRoute, "201")
+	h, handlerLog := doHandler()
+	handlerLog.response = http.StatusCreated
+	middle := loggerMiddleware{
+		logger: log.New(&bytes.Buffer{}, "", 0),
+		router: router,
+	}
+	request, err := http.NewRequest("PUT", "/my/path", nil)
+	c.Assert(err, check.IsNil)
+	middle.ServeHTTP(negroni.NewResponseWriter(httptest.NewRecorder()), request, h)
+	c.Assert(requestCountValue(c, "PUT", "/my/{thing}", "201"), check.Equals, before+1)
+	request, err = http.NewRequest("PUT", "/other/path", nil)
+	c.Assert(err, check.IsNil)
+	middle.ServeHTTP(negroni.NewResponseWriter(httptest.NewRecorder()), request, h)
+	c.Assert(requestCountValue(c, "PUT", unknownRoute, "201"), check.Equals, unknownBefore+1)
+}
+
+func (s *S) TestMetricsHandler(c *check.C) {
+	observeRequest("GET", "/apps", http.StatusOK, 0)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMetricsRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/metrics", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*tsuru_http_requests_total\{code="200",method="GET",route="/apps"\} \d+.*`)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*go_goroutines \d+.*`)
+}
+
+func (s *S) TestMetricsHandlerWithoutToken(c *check.C) {
+	request, err := http.NewRequest("GET", "/metrics", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestMetricsHandlerWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/metrics", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
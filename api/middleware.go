@@ -16,9 +16,11 @@ import (
 	"github.com/nu7hatch/gouuid"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/api/context"
+	apiRouter "github.com/tsuru/tsuru/api/router"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
 )
@@ -98,8 +100,11 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 	err := context.GetRequestError(r)
 	if err != nil {
 		code := http.StatusInternalServerError
-		if e, ok := err.(*errors.HTTP); ok {
+		switch e := err.(type) {
+		case *errors.HTTP:
 			code = e.Code
+		case event.ErrEventLocked:
+			code = http.StatusConflict
 		}
 		flushing, ok := w.(*io.FlushingWriter)
 		if ok && flushing.Wrote() {
@@ -130,6 +135,23 @@ func authTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.Handl
 
 type appLockMiddleware struct {
 	excludedHandlers []http.Handler
+
+	// queueableHandlers are handlers that may run without the lock when the
+	// app is locked by a deploy and has the deploy queue enabled.
+	queueableHandlers []http.Handler
+}
+
+func handlerIn(h http.Handler, handlers []http.Handler) bool {
+	if h == nil {
+		return false
+	}
+	hPtr := reflect.ValueOf(h).Pointer()
+	for _, handler := range handlers {
+		if reflect.ValueOf(handler).Pointer() == hPtr {
+			return true
+		}
+	}
+	return false
 }
 
 var lockWaitDuration time.Duration = 10 * time.Second
@@ -140,14 +162,9 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 		return
 	}
 	currentHandler := context.GetDelayedHandler(r)
-	if currentHandler != nil {
-		currentHandlerPtr := reflect.ValueOf(currentHandler).Pointer()
-		for _, h := range m.excludedHandlers {
-			if reflect.ValueOf(h).Pointer() == currentHandlerPtr {
-				next(w, r)
-				return
-			}
-		}
+	if handlerIn(currentHandler, m.excludedHandlers) {
+		next(w, r)
+		return
 	}
 	appName := r.URL.Query().Get(":app")
 	if appName == "" {
@@ -195,6 +212,11 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 			httpErr.Message = fmt.Sprintf("Error to get application: %s", err)
 		}
 	} else {
+		if a.DeployQueue && a.Lock.IsDeploy() && handlerIn(currentHandler, m.queueableHandlers) {
+			context.SetDeployQueued(r)
+			next(w, r)
+			return
+		}
 		httpErr.Code = http.StatusConflict
 		if a.Lock.Locked {
 			httpErr.Message = fmt.Sprintf("%s", &a.Lock)
@@ -214,11 +236,13 @@ func runDelayedHandler(w http.ResponseWriter, r *http.Request) {
 
 type loggerMiddleware struct {
 	logger *stdLog.Logger
+	router *apiRouter.DelayedRouter
 }
 
-func newLoggerMiddleware() *loggerMiddleware {
+func newLoggerMiddleware(router *apiRouter.DelayedRouter) *loggerMiddleware {
 	return &loggerMiddleware{
 		logger: stdLog.New(os.Stdout, "", 0),
+		router: router,
 	}
 }
 
@@ -232,4 +256,9 @@ func (l *loggerMiddleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, ne
 	}
 	nowFormatted := time.Now().Format(time.RFC3339Nano)
 	l.logger.Printf("%s %s %s %d in %0.6fms", nowFormatted, r.Method, r.URL.Path, statusCode, float64(duration)/float64(time.Millisecond))
+	var route string
+	if l.router != nil {
+		route = l.router.RoutePath(r)
+	}
+	observeRequest(r.Method, route, statusCode, duration)
 }
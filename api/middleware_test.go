@@ -19,6 +19,7 @@ import (
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/io"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -139,6 +140,17 @@ func (s *S) TestErrorHandlingMiddlewareWithHTTPError(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, 403)
 }
 
+func (s *S) TestErrorHandlingMiddlewareWithEventLockedError(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	context.AddRequestError(request, event.ErrEventLocked{Event: &event.Event{}})
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
 func (s *S) TestAuthTokenMiddlewareWithoutToken(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
@@ -425,6 +437,103 @@ func (s *S) TestAppLockMiddlewareDoesNothingForExcludedHandlers(c *check.C) {
 	c.Assert(log.called, check.Equals, true)
 }
 
+func (s *S) TestAppLockMiddlewareQueuesDeployOnLockedApp(c *check.C) {
+	oldDuration := lockWaitDuration
+	lockWaitDuration = time.Second
+	defer func() { lockWaitDuration = oldDuration }()
+	myApp := app.App{
+		Name:        "my-app",
+		DeployQueue: true,
+		Lock: app.AppLock{
+			Locked:      true,
+			Reason:      "POST /apps/my-app/deploy",
+			Owner:       "someone",
+			AcquireDate: time.Date(2048, time.November, 10, 10, 0, 0, 0, time.UTC),
+		},
+	}
+	err := s.conn.Apps().Insert(myApp)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": myApp.Name})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/?:app=my-app", nil)
+	c.Assert(err, check.IsNil)
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	context.SetDelayedHandler(request, finalHandler)
+	h, log := doHandler()
+	m := &appLockMiddleware{
+		queueableHandlers: []http.Handler{finalHandler},
+	}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(context.IsDeployQueued(request), check.Equals, true)
+	c.Assert(context.GetRequestError(request), check.IsNil)
+	dbApp, err := app.GetByName(myApp.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Lock.Owner, check.Equals, "someone")
+}
+
+func (s *S) TestAppLockMiddlewareDoesNotQueueWithoutDeployQueue(c *check.C) {
+	oldDuration := lockWaitDuration
+	lockWaitDuration = time.Second
+	defer func() { lockWaitDuration = oldDuration }()
+	myApp := app.App{
+		Name: "my-app",
+		Lock: app.AppLock{
+			Locked: true,
+			Reason: "POST /apps/my-app/deploy",
+			Owner:  "someone",
+		},
+	}
+	err := s.conn.Apps().Insert(myApp)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": myApp.Name})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/?:app=my-app", nil)
+	c.Assert(err, check.IsNil)
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	context.SetDelayedHandler(request, finalHandler)
+	h, log := doHandler()
+	m := &appLockMiddleware{
+		queueableHandlers: []http.Handler{finalHandler},
+	}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	c.Assert(context.IsDeployQueued(request), check.Equals, false)
+	httpErr := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(httpErr.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestAppLockMiddlewareDoesNotQueueOnNonDeployLock(c *check.C) {
+	oldDuration := lockWaitDuration
+	lockWaitDuration = time.Second
+	defer func() { lockWaitDuration = oldDuration }()
+	myApp := app.App{
+		Name:        "my-app",
+		DeployQueue: true,
+		Lock: app.AppLock{
+			Locked: true,
+			Reason: "POST /apps/my-app/units",
+			Owner:  "someone",
+		},
+	}
+	err := s.conn.Apps().Insert(myApp)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": myApp.Name})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/?:app=my-app", nil)
+	c.Assert(err, check.IsNil)
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	context.SetDelayedHandler(request, finalHandler)
+	h, log := doHandler()
+	m := &appLockMiddleware{
+		queueableHandlers: []http.Handler{finalHandler},
+	}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	httpErr := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(httpErr.Code, check.Equals, http.StatusConflict)
+}
+
 func (s *S) TestAppLockMiddlewareWaitForLock(c *check.C) {
 	myApp := app.App{
 		Name: "my-app",
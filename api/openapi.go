@@ -0,0 +1,24 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:generate go run ./openapi/generator/main.go -o openapi_spec.go
+
+package api
+
+import (
+	"io"
+	"net/http"
+)
+
+// title: openapi specification
+// path: /openapi.json
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+func openAPI(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	_, err := io.WriteString(w, openAPISpec)
+	return err
+}
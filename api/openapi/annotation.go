@@ -0,0 +1,116 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// anyMethods are the methods of handlers without a method annotation, which
+// are bound to all of them.
+var anyMethods = []string{"GET", "POST", "PUT", "DELETE"}
+
+var (
+	annotationRegexp = regexp.MustCompile(`^([a-z]+):\s*(.*)$`)
+	responseRegexp   = regexp.MustCompile(`^\s+(\d{3}):\s*(.*)$`)
+	pathParamRegexp  = regexp.MustCompile(`{([^}]+)}`)
+)
+
+// Annotations are the annotations in the doc comment of a handler.
+type Annotations struct {
+	Title     string
+	Path      string
+	Method    string
+	Consume   []string
+	Produce   []string
+	Responses map[string]string
+}
+
+// ParseAnnotations parses the annotations in the given doc comment, as
+// returned by (*ast.CommentGroup).Text. It returns nil when the comment has no
+// annotations.
+func ParseAnnotations(doc string) (*Annotations, error) {
+	if !strings.HasPrefix(doc, "title:") && !strings.Contains(doc, "\ntitle:") {
+		return nil, nil
+	}
+	a := Annotations{Responses: map[string]string{}}
+	var inResponses bool
+	for _, line := range strings.Split(doc, "\n") {
+		if inResponses {
+			if parts := responseRegexp.FindStringSubmatch(line); parts != nil {
+				a.Responses[parts[1]] = parts[2]
+				continue
+			}
+			inResponses = false
+		}
+		parts := annotationRegexp.FindStringSubmatch(line)
+		if parts == nil {
+			continue
+		}
+		value := strings.TrimSpace(parts[2])
+		switch parts[1] {
+		case "title":
+			a.Title = value
+		case "path":
+			a.Path = value
+		case "method":
+			a.Method = strings.ToUpper(value)
+		case "consume":
+			a.Consume = splitList(value)
+		case "produce":
+			a.Produce = splitList(value)
+		case "responses":
+			inResponses = true
+		default:
+			return nil, fmt.Errorf("unknown annotation %q", parts[1])
+		}
+	}
+	if a.Path == "" {
+		return nil, fmt.Errorf("missing path annotation")
+	}
+	if len(a.Responses) == 0 {
+		return nil, fmt.Errorf("missing responses annotation")
+	}
+	return &a, nil
+}
+
+// Methods returns the HTTP methods of the annotated handler.
+func (a *Annotations) Methods() []string {
+	if a.Method == "" {
+		return anyMethods
+	}
+	return []string{a.Method}
+}
+
+// PathParams returns the names of the parameters in the path of the
+// annotated handler.
+func (a *Annotations) PathParams() []string {
+	var params []string
+	for _, parts := range pathParamRegexp.FindAllStringSubmatch(a.Path, -1) {
+		params = append(params, parts[1])
+	}
+	return params
+}
+
+func (a *Annotations) consumes(contentType string) bool {
+	for _, c := range a.Consume {
+		if c == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
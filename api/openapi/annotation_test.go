@@ -0,0 +1,60 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestParseAnnotations(c *check.C) {
+	doc := `title: app info
+path: /apps/{app}/units/{unit}
+method: get
+consume: application/x-www-form-urlencoded
+produce: application/json, application/x-yaml
+responses:
+  200: OK
+  404: Not found
+`
+	a, err := ParseAnnotations(doc)
+	c.Assert(err, check.IsNil)
+	c.Assert(a, check.DeepEquals, &Annotations{
+		Title:     "app info",
+		Path:      "/apps/{app}/units/{unit}",
+		Method:    "GET",
+		Consume:   []string{"application/x-www-form-urlencoded"},
+		Produce:   []string{"application/json", "application/x-yaml"},
+		Responses: map[string]string{"200": "OK", "404": "Not found"},
+	})
+	c.Assert(a.Methods(), check.DeepEquals, []string{"GET"})
+	c.Assert(a.PathParams(), check.DeepEquals, []string{"app", "unit"})
+}
+
+func (s *S) TestParseAnnotationsWithoutMethod(c *check.C) {
+	a, err := ParseAnnotations("title: proxy\npath: /services/proxy\nresponses:\n  200: OK\n")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Methods(), check.DeepEquals, []string{"GET", "POST", "PUT", "DELETE"})
+	c.Assert(a.PathParams(), check.IsNil)
+}
+
+func (s *S) TestParseAnnotationsNotAnnotated(c *check.C) {
+	a, err := ParseAnnotations("listApps returns the list of apps.\n")
+	c.Assert(err, check.IsNil)
+	c.Assert(a, check.IsNil)
+}
+
+func (s *S) TestParseAnnotationsInvalid(c *check.C) {
+	var tests = []struct {
+		doc string
+		err string
+	}{
+		{"title: x\npath: /x\nconsme: text\nresponses:\n  200: OK\n", `unknown annotation "consme"`},
+		{"title: x\nmethod: GET\nresponses:\n  200: OK\n", "missing path annotation"},
+		{"title: x\npath: /x\nmethod: GET\n", "missing responses annotation"},
+	}
+	for _, tt := range tests {
+		a, err := ParseAnnotations(tt.doc)
+		c.Check(a, check.IsNil)
+		c.Check(err, check.ErrorMatches, tt.err)
+	}
+}
@@ -0,0 +1,473 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	contentTypeJSON      = "application/json"
+	contentTypeForm      = "application/x-www-form-urlencoded"
+	contentTypeMultipart = "multipart/form-data"
+)
+
+// Package is a parsed and type checked package containing annotated
+// handlers. Info must have the Types, Defs and Uses maps filled.
+type Package struct {
+	Files []*ast.File
+	Info  *types.Info
+}
+
+// Generator builds an OpenAPI document from the annotated handlers of one or
+// more packages.
+type Generator struct {
+	// TokenType is the qualified name of the type received by handlers that
+	// require authentication.
+	TokenType string
+
+	doc          *Document
+	schemas      *schemaBuilder
+	operationIDs map[string]bool
+}
+
+// NewGenerator returns a generator of a document with the given API title
+// and version.
+func NewGenerator(title, version string) *Generator {
+	return &Generator{
+		TokenType: "github.com/tsuru/tsuru/auth.Token",
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    Info{Title: title, Version: version},
+			Paths:   map[string]PathItem{},
+		},
+		schemas:      newSchemaBuilder(),
+		operationIDs: map[string]bool{},
+	}
+}
+
+// AddPackage adds the operations of all annotated handlers in the given
+// package to the document.
+func (g *Generator) AddPackage(pkg *Package) error {
+	decls := map[*types.Func]*ast.FuncDecl{}
+	var handlers []*ast.FuncDecl
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			decl, ok := d.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if fn, ok := pkg.Info.Defs[decl.Name].(*types.Func); ok {
+				decls[fn] = decl
+			}
+			if decl.Doc != nil && decl.Recv == nil {
+				handlers = append(handlers, decl)
+			}
+		}
+	}
+	for _, decl := range handlers {
+		annotations, err := ParseAnnotations(decl.Doc.Text())
+		if err != nil {
+			return fmt.Errorf("%s: %s", decl.Name.Name, err)
+		}
+		if annotations == nil {
+			continue
+		}
+		fn, ok := pkg.Info.Defs[decl.Name].(*types.Func)
+		if !ok {
+			return fmt.Errorf("%s: missing type information", decl.Name.Name)
+		}
+		a := analyzer{info: pkg.Info, decls: decls, visited: map[*ast.FuncDecl]bool{}}
+		h := a.analyze(decl)
+		h.authenticated = g.isAuthenticated(fn)
+		err = g.addOperations(fn, annotations, h)
+		if err != nil {
+			return fmt.Errorf("%s: %s", decl.Name.Name, err)
+		}
+	}
+	return nil
+}
+
+// Document returns the generated document.
+func (g *Generator) Document() *Document {
+	g.doc.Components.Schemas = g.schemas.schemas
+	g.doc.Components.SecuritySchemes = map[string]SecurityScheme{
+		securityScheme: {
+			Type:        "apiKey",
+			In:          "header",
+			Name:        "Authorization",
+			Description: `The token of the user, in the form "bearer <token>".`,
+		},
+	}
+	return g.doc
+}
+
+func (g *Generator) isAuthenticated(fn *types.Func) bool {
+	params := fn.Type().(*types.Signature).Params()
+	for i := 0; i < params.Len(); i++ {
+		if named, ok := params.At(i).Type().(*types.Named); ok && qualifiedName(named) == g.TokenType {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Generator) addOperations(fn *types.Func, annotations *Annotations, h *handlerInfo) error {
+	methods := annotations.Methods()
+	item := g.doc.Paths[annotations.Path]
+	if item == nil {
+		item = PathItem{}
+		g.doc.Paths[annotations.Path] = item
+	}
+	for _, method := range methods {
+		key := strings.ToLower(method)
+		if _, ok := item[key]; ok {
+			return fmt.Errorf("duplicate operation %s %s", method, annotations.Path)
+		}
+		op := g.operation(method, annotations, h)
+		op.OperationID = g.operationID(fn, method, len(methods) > 1)
+		item[key] = op
+	}
+	return nil
+}
+
+func (g *Generator) operationID(fn *types.Func, method string, withMethod bool) string {
+	id := fn.Name()
+	if withMethod {
+		id += strings.Title(strings.ToLower(method))
+	}
+	if g.operationIDs[id] && fn.Pkg() != nil {
+		id = fn.Pkg().Name() + strings.Title(id)
+	}
+	g.operationIDs[id] = true
+	return id
+}
+
+func (g *Generator) operation(method string, annotations *Annotations, h *handlerInfo) *Operation {
+	op := Operation{
+		Summary:   annotations.Title,
+		Responses: map[string]*Response{},
+	}
+	if tag := pathTag(annotations.Path); tag != "" {
+		op.Tags = []string{tag}
+	}
+	for _, name := range annotations.PathParams() {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	for _, name := range h.query {
+		op.Parameters = append(op.Parameters, queryParameter(name))
+	}
+	hasBody := method == http.MethodPost || method == http.MethodPut
+	formInBody := hasBody && (annotations.consumes(contentTypeForm) || annotations.consumes(contentTypeMultipart))
+	if !formInBody {
+		for _, name := range h.form {
+			op.Parameters = append(op.Parameters, queryParameter(name))
+		}
+	}
+	if hasBody && len(annotations.Consume) > 0 {
+		op.RequestBody = &RequestBody{Content: map[string]MediaType{}}
+		for _, contentType := range annotations.Consume {
+			op.RequestBody.Content[contentType] = MediaType{Schema: g.requestSchema(contentType, h)}
+		}
+	}
+	for code, description := range annotations.Responses {
+		resp := Response{Description: description}
+		if strings.HasPrefix(code, "2") && code != "204" && len(annotations.Produce) > 0 {
+			resp.Content = map[string]MediaType{}
+			for _, contentType := range annotations.Produce {
+				var media MediaType
+				if contentType == contentTypeJSON && h.response != nil {
+					media.Schema = g.schemas.schemaFor(h.response)
+				}
+				resp.Content[contentType] = media
+			}
+		}
+		op.Responses[code] = &resp
+	}
+	if h.authenticated {
+		op.Security = []map[string][]string{{securityScheme: {}}}
+	}
+	return &op
+}
+
+func (g *Generator) requestSchema(contentType string, h *handlerInfo) *Schema {
+	switch contentType {
+	case contentTypeJSON:
+		if h.jsonBody != nil {
+			return g.schemas.schemaFor(h.jsonBody)
+		}
+		return &Schema{Type: "object"}
+	case contentTypeForm, contentTypeMultipart:
+		s := Schema{Type: "object"}
+		if h.formBody != nil {
+			s = *g.schemas.formSchema(h.formBody)
+		}
+		if s.Properties == nil {
+			s.Properties = map[string]*Schema{}
+		}
+		for _, name := range h.form {
+			s.Properties[name] = &Schema{Type: "string"}
+		}
+		for _, name := range h.postForm {
+			s.Properties[name] = &Schema{Type: "string"}
+		}
+		if contentType == contentTypeMultipart {
+			for _, name := range h.files {
+				s.Properties[name] = &Schema{Type: "string", Format: "binary"}
+			}
+		}
+		if len(s.Properties) == 0 {
+			s.Properties = nil
+		}
+		return &s
+	}
+	return &Schema{Type: "string"}
+}
+
+func queryParameter(name string) Parameter {
+	return Parameter{Name: name, In: "query", Schema: &Schema{Type: "string"}}
+}
+
+func pathTag(path string) string {
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	if strings.HasPrefix(segment, "{") {
+		return ""
+	}
+	return segment
+}
+
+// handlerInfo holds what could be learned about a handler from its code.
+type handlerInfo struct {
+	query         []string
+	form          []string
+	postForm      []string
+	files         []string
+	jsonBody      types.Type
+	formBody      types.Type
+	response      types.Type
+	authenticated bool
+}
+
+// analyzer inspects the body of a handler, and of the functions in the same
+// package it hands the request or the response writer to, looking for the
+// parameters it reads and the values it decodes and encodes.
+type analyzer struct {
+	info     *types.Info
+	decls    map[*types.Func]*ast.FuncDecl
+	visited  map[*ast.FuncDecl]bool
+	requests map[types.Object]bool
+	writers  map[types.Object]bool
+	encoders map[types.Object]bool
+	h        handlerInfo
+}
+
+func (a *analyzer) analyze(decl *ast.FuncDecl) *handlerInfo {
+	a.requests = map[types.Object]bool{}
+	a.writers = map[types.Object]bool{}
+	a.encoders = map[types.Object]bool{}
+	for _, field := range decl.Type.Params.List {
+		for _, name := range field.Names {
+			obj := a.info.Defs[name]
+			if obj == nil {
+				continue
+			}
+			switch types.TypeString(obj.Type(), nil) {
+			case "*net/http.Request":
+				a.requests[obj] = true
+			case "net/http.ResponseWriter":
+				a.writers[obj] = true
+			}
+		}
+	}
+	a.walk(decl)
+	return &a.h
+}
+
+func (a *analyzer) walk(decl *ast.FuncDecl) {
+	if a.visited[decl] || decl.Body == nil {
+		return
+	}
+	a.visited[decl] = true
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i, rhs := range n.Rhs {
+					if a.isEncoder(rhs) {
+						if obj := a.object(n.Lhs[i]); obj != nil {
+							a.encoders[obj] = true
+						}
+					}
+				}
+			}
+		case *ast.IndexExpr:
+			if a.isRequestField(n.X, "Form") {
+				a.h.form = appendName(a.h.form, a.stringValue(n.Index))
+			}
+		case *ast.CallExpr:
+			a.call(n)
+		}
+		return true
+	})
+}
+
+func (a *analyzer) call(call *ast.CallExpr) {
+	if id, ok := call.Fun.(*ast.Ident); ok {
+		a.helperCall(id, call.Args)
+		return
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	switch sel.Sel.Name {
+	case "Get":
+		if inner, ok := sel.X.(*ast.CallExpr); ok && len(call.Args) == 1 {
+			if innerSel, ok := inner.Fun.(*ast.SelectorExpr); ok && innerSel.Sel.Name == "Query" && a.isRequestField(innerSel.X, "URL") {
+				if name := a.stringValue(call.Args[0]); !strings.HasPrefix(name, ":") {
+					a.h.query = appendName(a.h.query, name)
+				}
+			}
+		}
+		if a.isRequestField(sel.X, "Form") && len(call.Args) == 1 {
+			a.h.form = appendName(a.h.form, a.stringValue(call.Args[0]))
+		}
+		if a.isRequestField(sel.X, "PostForm") && len(call.Args) == 1 {
+			a.h.postForm = appendName(a.h.postForm, a.stringValue(call.Args[0]))
+		}
+	case "FormValue":
+		if a.isRequest(sel.X) && len(call.Args) == 1 {
+			a.h.form = appendName(a.h.form, a.stringValue(call.Args[0]))
+		}
+	case "PostFormValue":
+		if a.isRequest(sel.X) && len(call.Args) == 1 {
+			a.h.postForm = appendName(a.h.postForm, a.stringValue(call.Args[0]))
+		}
+	case "FormFile":
+		if a.isRequest(sel.X) && len(call.Args) == 1 {
+			a.h.files = appendName(a.h.files, a.stringValue(call.Args[0]))
+		}
+	case "Decode":
+		if inner, ok := sel.X.(*ast.CallExpr); ok && isCallTo(inner, "NewDecoder") && len(inner.Args) == 1 && a.isRequestField(inner.Args[0], "Body") && len(call.Args) == 1 {
+			a.h.jsonBody = deref(a.info.TypeOf(call.Args[0]))
+		}
+	case "DecodeValues":
+		if len(call.Args) == 2 && a.isRequestField(call.Args[1], "Form") {
+			a.h.formBody = deref(a.info.TypeOf(call.Args[0]))
+		}
+	case "Encode":
+		if (a.isEncoder(sel.X) || a.encoders[a.object(sel.X)]) && len(call.Args) == 1 {
+			a.h.response = a.info.TypeOf(call.Args[0])
+		}
+	}
+}
+
+// helperCall follows calls to functions of the same package which receive
+// the request or the response writer.
+func (a *analyzer) helperCall(id *ast.Ident, args []ast.Expr) {
+	fn, ok := a.info.Uses[id].(*types.Func)
+	if !ok {
+		return
+	}
+	decl, ok := a.decls[fn]
+	if !ok || a.visited[decl] {
+		return
+	}
+	var params []*ast.Ident
+	for _, field := range decl.Type.Params.List {
+		params = append(params, field.Names...)
+	}
+	var follow bool
+	for i, arg := range args {
+		if i >= len(params) {
+			break
+		}
+		obj := a.info.Defs[params[i]]
+		if obj == nil {
+			continue
+		}
+		if a.isRequest(arg) {
+			a.requests[obj] = true
+			follow = true
+		}
+		if a.isWriter(arg) {
+			a.writers[obj] = true
+			follow = true
+		}
+	}
+	if follow {
+		a.walk(decl)
+	}
+}
+
+func (a *analyzer) object(expr ast.Expr) types.Object {
+	if id, ok := expr.(*ast.Ident); ok {
+		if obj := a.info.Defs[id]; obj != nil {
+			return obj
+		}
+		return a.info.Uses[id]
+	}
+	return nil
+}
+
+func (a *analyzer) isRequest(expr ast.Expr) bool {
+	obj := a.object(expr)
+	return obj != nil && a.requests[obj]
+}
+
+func (a *analyzer) isWriter(expr ast.Expr) bool {
+	obj := a.object(expr)
+	return obj != nil && a.writers[obj]
+}
+
+func (a *analyzer) isRequestField(expr ast.Expr, field string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == field && a.isRequest(sel.X)
+}
+
+// isEncoder reports whether the given expression is a JSON encoder writing
+// to the response.
+func (a *analyzer) isEncoder(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	return ok && isCallTo(call, "NewEncoder") && len(call.Args) == 1 && a.isWriter(call.Args[0])
+}
+
+func (a *analyzer) stringValue(expr ast.Expr) string {
+	tv, ok := a.info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return ""
+	}
+	return constant.StringVal(tv.Value)
+}
+
+func isCallTo(call *ast.CallExpr, name string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == name
+}
+
+func appendName(names []string, name string) []string {
+	if name == "" {
+		return names
+	}
+	i := sort.SearchStrings(names, name)
+	if i < len(names) && names[i] == name {
+		return names
+	}
+	names = append(names, "")
+	copy(names[i+1:], names[i:])
+	names[i] = name
+	return names
+}
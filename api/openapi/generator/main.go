@@ -0,0 +1,134 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/tsuru/tsuru/api/openapi"
+)
+
+// handlerPackages are the packages containing annotated handlers.
+var handlerPackages = []string{
+	"github.com/tsuru/tsuru/api",
+	"github.com/tsuru/tsuru/provision/docker",
+}
+
+var fileTpl = `// AUTOMATICALLY GENERATED FILE - DO NOT EDIT!
+// Please run 'go generate' to update this file.
+//
+// Copyright {{.Time.Year}} tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+var openAPISpec = ` + "`{{.Spec}}`" + `
+`
+
+type context struct {
+	Time time.Time
+	Spec string
+}
+
+func main() {
+	out := flag.String("o", "", "output file")
+	flag.Parse()
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	var generator *openapi.Generator
+	for _, path := range handlerPackages {
+		pkg, info, err := loadPackage(fset, imp, path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if generator == nil {
+			generator = openapi.NewGenerator("tsuru", apiVersion(pkg))
+		}
+		err = generator.AddPackage(&openapi.Package{Files: info.files, Info: info.types})
+		if err != nil {
+			log.Fatalf("%s: %s", path, err)
+		}
+	}
+	spec, err := json.MarshalIndent(generator.Document(), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if bytes.Contains(spec, []byte("`")) {
+		log.Fatal("the specification can't contain backquotes")
+	}
+	tmpl, err := template.New("tpl").Parse(fileTpl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, context{Time: time.Now(), Spec: string(spec)})
+	if err != nil {
+		log.Fatal(err)
+	}
+	formatedFile, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("unable to format code: %s\n%s", err, buf.Bytes())
+	}
+	file, err := os.OpenFile(*out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0660)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	file.Write(formatedFile)
+}
+
+type packageInfo struct {
+	files []*ast.File
+	types *types.Info
+}
+
+// loadPackage parses and type checks the non test files of a package. Type
+// errors are ignored, as the generator only needs the types of the handlers.
+func loadPackage(fset *token.FileSet, imp types.Importer, path string) (*types.Package, *packageInfo, error) {
+	buildPkg, err := build.Import(path, ".", 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var files []*ast.File
+	for _, name := range buildPkg.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(buildPkg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{Importer: imp, Error: func(error) {}}
+	pkg, _ := conf.Check(path, fset, files, info)
+	return pkg, &packageInfo{files: files, types: info}, nil
+}
+
+func apiVersion(pkg *types.Package) string {
+	c, ok := pkg.Scope().Lookup("Version").(*types.Const)
+	if !ok || c.Val().Kind() != constant.String {
+		log.Fatalf("%s: missing Version constant", pkg.Path())
+	}
+	return constant.StringVal(c.Val())
+}
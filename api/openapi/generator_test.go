@@ -0,0 +1,240 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"gopkg.in/check.v1"
+)
+
+const handlersSource = `package example
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type Token interface {
+	GetValue() string
+}
+
+type App struct {
+	Name      string            ` + "`json:\"name\"`" + `
+	Units     int               ` + "`json:\"units,omitempty\"`" + `
+	CreatedAt time.Time
+	Env       map[string]string
+	Parent    *App
+	secret    string
+}
+
+type envInput struct {
+	Envs []string
+	Private bool
+}
+
+// title: app info
+// path: /apps/{app}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   404: Not found
+func appInfo(w http.ResponseWriter, r *http.Request, t Token) error {
+	return writeApp(w, &App{Name: r.URL.Query().Get(":app")})
+}
+
+func writeApp(w http.ResponseWriter, a *App) error {
+	encoder := json.NewEncoder(w)
+	return encoder.Encode(a)
+}
+
+// title: app create
+// path: /apps
+// method: POST
+// consume: application/json
+// produce: application/json
+// responses:
+//   201: Created
+func createApp(w http.ResponseWriter, r *http.Request, t Token) error {
+	var a App
+	err := json.NewDecoder(r.Body).Decode(&a)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(a)
+}
+
+// title: set envs
+// path: /apps/{app}/env
+// method: POST
+// consume: application/x-www-form-urlencoded, multipart/form-data
+// responses:
+//   200: OK
+func setEnv(w http.ResponseWriter, r *http.Request, t Token) error {
+	var e envInput
+	var d decoder
+	d.DecodeValues(&e, r.Form)
+	r.FormFile("file")
+	return restart(r)
+}
+
+func restart(req *http.Request) error {
+	req.FormValue("noRestart")
+	return nil
+}
+
+type decoder struct{}
+
+func (decoder) DecodeValues(v interface{}, values map[string][]string) {}
+
+// title: app list
+// path: /apps
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+func appList(w http.ResponseWriter, r *http.Request) error {
+	r.URL.Query().Get("name")
+	r.FormValue("pool")
+	return json.NewEncoder(w).Encode([]App{})
+}
+
+// title: proxy
+// path: /proxy/{path}
+// responses:
+//   200: OK
+func proxy(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+`
+
+func parseHandlers(c *check.C, src string) *Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "handlers.go", src, parser.ParseComments)
+	c.Assert(err, check.IsNil)
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("example", fset, []*ast.File{f}, info)
+	c.Assert(err, check.IsNil)
+	return &Package{Files: []*ast.File{f}, Info: info}
+}
+
+func (s *S) TestGenerator(c *check.C) {
+	g := NewGenerator("tsuru", "1.0")
+	g.TokenType = "example.Token"
+	err := g.AddPackage(parseHandlers(c, handlersSource))
+	c.Assert(err, check.IsNil)
+	doc := g.Document()
+	c.Assert(doc.OpenAPI, check.Equals, "3.0.0")
+	c.Assert(doc.Info, check.Equals, Info{Title: "tsuru", Version: "1.0"})
+	c.Assert(doc.Paths, check.HasLen, 4)
+	appRef := &Schema{Ref: "#/components/schemas/example.App"}
+	c.Assert(doc.Paths["/apps/{app}"]["get"], check.DeepEquals, &Operation{
+		OperationID: "appInfo",
+		Summary:     "app info",
+		Tags:        []string{"apps"},
+		Parameters: []Parameter{
+			{Name: "app", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		},
+		Responses: map[string]*Response{
+			"200": {Description: "OK", Content: map[string]MediaType{"application/json": {Schema: appRef}}},
+			"404": {Description: "Not found"},
+		},
+		Security: []map[string][]string{{"bearer": {}}},
+	})
+	create := doc.Paths["/apps"]["post"]
+	c.Assert(create.RequestBody, check.DeepEquals, &RequestBody{
+		Content: map[string]MediaType{"application/json": {Schema: appRef}},
+	})
+	c.Assert(create.Responses["201"].Content["application/json"].Schema, check.DeepEquals, appRef)
+	list := doc.Paths["/apps"]["get"]
+	c.Assert(list.Security, check.IsNil)
+	c.Assert(list.Parameters, check.DeepEquals, []Parameter{
+		{Name: "name", In: "query", Schema: &Schema{Type: "string"}},
+		{Name: "pool", In: "query", Schema: &Schema{Type: "string"}},
+	})
+	c.Assert(list.Responses["200"].Content["application/json"].Schema, check.DeepEquals, &Schema{Type: "array", Items: appRef})
+	c.Assert(list.Responses["204"].Content, check.IsNil)
+	env := doc.Paths["/apps/{app}/env"]["post"]
+	c.Assert(env.Parameters, check.HasLen, 1)
+	c.Assert(env.RequestBody.Content["application/x-www-form-urlencoded"].Schema, check.DeepEquals, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"Envs":      {Type: "array", Items: &Schema{Type: "string"}},
+			"Private":   {Type: "boolean"},
+			"noRestart": {Type: "string"},
+		},
+	})
+	c.Assert(env.RequestBody.Content["multipart/form-data"].Schema.Properties["file"], check.DeepEquals, &Schema{Type: "string", Format: "binary"})
+	proxy := doc.Paths["/proxy/{path}"]
+	c.Assert(proxy, check.HasLen, 4)
+	c.Assert(proxy["get"].OperationID, check.Equals, "proxyGet")
+	c.Assert(proxy["delete"].OperationID, check.Equals, "proxyDelete")
+	c.Assert(doc.Components.Schemas, check.DeepEquals, map[string]*Schema{
+		"example.App": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"name":      {Type: "string"},
+				"units":     {Type: "integer"},
+				"CreatedAt": {Type: "string", Format: "date-time"},
+				"Env":       {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+				"Parent":    appRef,
+			},
+		},
+	})
+	c.Assert(doc.Components.SecuritySchemes["bearer"].Name, check.Equals, "Authorization")
+}
+
+func (s *S) TestGeneratorDuplicateOperation(c *check.C) {
+	src := `package example
+
+import "net/http"
+
+// title: first
+// path: /apps
+// method: GET
+// responses:
+//   200: OK
+func first(w http.ResponseWriter, r *http.Request) error { return nil }
+
+// title: second
+// path: /apps
+// method: GET
+// responses:
+//   200: OK
+func second(w http.ResponseWriter, r *http.Request) error { return nil }
+`
+	g := NewGenerator("tsuru", "1.0")
+	err := g.AddPackage(parseHandlers(c, src))
+	c.Assert(err, check.ErrorMatches, "second: duplicate operation GET /apps")
+}
+
+func (s *S) TestGeneratorInvalidAnnotations(c *check.C) {
+	src := `package example
+
+import "net/http"
+
+// title: first
+// method: GET
+// responses:
+//   200: OK
+func first(w http.ResponseWriter, r *http.Request) error { return nil }
+`
+	g := NewGenerator("tsuru", "1.0")
+	err := g.AddPackage(parseHandlers(c, src))
+	c.Assert(err, check.ErrorMatches, "first: missing path annotation")
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// wellKnownSchemas are the schemas of types whose JSON representation can't
+// be derived from their definition.
+var wellKnownSchemas = map[string]Schema{
+	"time.Time":                     {Type: "string", Format: "date-time"},
+	"gopkg.in/mgo.v2/bson.ObjectId": {Type: "string"},
+	"encoding/json.RawMessage":      {},
+}
+
+// schemaBuilder derives schemas from Go types, following the rules of the
+// encoding/json package. Named struct types are added to the schemas map and
+// referenced by name.
+type schemaBuilder struct {
+	schemas map[string]*Schema
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{schemas: map[string]*Schema{}}
+}
+
+func (b *schemaBuilder) schemaFor(t types.Type) *Schema {
+	switch t := t.(type) {
+	case *types.Named:
+		if s, ok := wellKnownSchemas[qualifiedName(t)]; ok {
+			return &s
+		}
+		_, isStruct := t.Underlying().(*types.Struct)
+		if implements(t, "MarshalJSON") {
+			if isStruct {
+				return &Schema{Type: "object"}
+			}
+			return &Schema{}
+		}
+		if implements(t, "MarshalText") {
+			return &Schema{Type: "string"}
+		}
+		if !isStruct {
+			return b.schemaFor(t.Underlying())
+		}
+		name := schemaName(t)
+		if _, ok := b.schemas[name]; !ok {
+			// The placeholder is filled after being added, so recursive
+			// types reference it instead of looping forever.
+			s := &Schema{}
+			b.schemas[name] = s
+			*s = *b.structSchema(t.Underlying().(*types.Struct), "json")
+		}
+		return &Schema{Ref: schemaRefPrefix + name}
+	case *types.Pointer:
+		return b.schemaFor(t.Elem())
+	case *types.Basic:
+		return basicSchema(t)
+	case *types.Slice:
+		if isByte(t.Elem()) {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return b.arraySchema(t.Elem())
+	case *types.Array:
+		return b.arraySchema(t.Elem())
+	case *types.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaFor(t.Elem())}
+	case *types.Struct:
+		return b.structSchema(t, "json")
+	case *types.Chan, *types.Signature:
+		return nil
+	}
+	return &Schema{}
+}
+
+func (b *schemaBuilder) arraySchema(elem types.Type) *Schema {
+	items := b.schemaFor(elem)
+	if items == nil {
+		items = &Schema{}
+	}
+	return &Schema{Type: "array", Items: items}
+}
+
+// structSchema returns the schema of the fields of a struct, named after the
+// given struct tag key.
+func (b *schemaBuilder) structSchema(st *types.Struct, tagKey string) *Schema {
+	s := Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		name, opts := parseTag(reflect.StructTag(st.Tag(i)).Get(tagKey))
+		if name == "-" && opts == "" {
+			continue
+		}
+		if field.Anonymous() && name == "" {
+			if embedded, ok := deref(field.Type()).Underlying().(*types.Struct); ok {
+				for propName, prop := range b.structSchema(embedded, tagKey).Properties {
+					if _, ok := s.Properties[propName]; !ok {
+						s.Properties[propName] = prop
+					}
+				}
+				continue
+			}
+		}
+		if !field.Exported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name()
+		}
+		prop := b.schemaFor(field.Type())
+		if prop == nil {
+			continue
+		}
+		if hasOption(opts, "string") {
+			prop = &Schema{Type: "string"}
+		}
+		s.Properties[name] = prop
+	}
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return &s
+}
+
+// formSchema returns the schema of a form decoded into a value of the given
+// type.
+func (b *schemaBuilder) formSchema(t types.Type) *Schema {
+	if st, ok := deref(t).Underlying().(*types.Struct); ok {
+		return b.structSchema(st, "form")
+	}
+	return &Schema{Type: "object"}
+}
+
+func basicSchema(t *types.Basic) *Schema {
+	info := t.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return &Schema{Type: "boolean"}
+	case info&types.IsInteger != 0:
+		s := Schema{Type: "integer"}
+		switch t.Kind() {
+		case types.Int32, types.Uint32:
+			s.Format = "int32"
+		case types.Int64, types.Uint64:
+			s.Format = "int64"
+		}
+		return &s
+	case info&types.IsFloat != 0:
+		s := Schema{Type: "number", Format: "double"}
+		if t.Kind() == types.Float32 {
+			s.Format = "float"
+		}
+		return &s
+	case info&types.IsString != 0:
+		return &Schema{Type: "string"}
+	}
+	return &Schema{}
+}
+
+func parseTag(tag string) (string, string) {
+	parts := strings.SplitN(tag, ",", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func hasOption(opts, option string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+func implements(t *types.Named, method string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, method)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+func qualifiedName(t *types.Named) string {
+	obj := t.Obj()
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+func schemaName(t *types.Named) string {
+	obj := t.Obj()
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Name() + "." + obj.Name()
+}
+
+func deref(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
+func isByte(t types.Type) bool {
+	basic, ok := t.(*types.Basic)
+	return ok && basic.Kind() == types.Byte
+}
@@ -0,0 +1,9855 @@
+// AUTOMATICALLY GENERATED FILE - DO NOT EDIT!
+// Please run 'go generate' to update this file.
+//
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+var openAPISpec = `{
+  "openapi": "3.0.0",
+  "info": {
+    "title": "tsuru",
+    "version": "1.0.0-rc6"
+  },
+  "paths": {
+    "/": {
+      "get": {
+        "operationId": "index",
+        "summary": "index",
+        "responses": {
+          "200": {
+            "description": "OK"
+          }
+        }
+      }
+    },
+    "/.well-known/acme-challenge/{token}": {
+      "get": {
+        "operationId": "acmeChallenge",
+        "summary": "ACME challenge",
+        "tags": [
+          ".well-known"
+        ],
+        "parameters": [
+          {
+            "name": "token",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "text/plain": {}
+            }
+          },
+          "404": {
+            "description": "Challenge not found"
+          }
+        }
+      }
+    },
+    "/apps": {
+      "get": {
+        "operationId": "appList",
+        "summary": "app list",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "locked",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "name",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "owner",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "platform",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "pool",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "teamOwner",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "List apps",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/api.miniApp"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createApp",
+        "summary": "app create",
+        "tags": [
+          "apps"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "description": {
+                    "type": "string"
+                  },
+                  "name": {
+                    "type": "string"
+                  },
+                  "plan": {
+                    "type": "string"
+                  },
+                  "platform": {
+                    "type": "string"
+                  },
+                  "teamOwner": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "App created",
+            "content": {
+              "application/json": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Quota exceeded"
+          },
+          "409": {
+            "description": "App already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/deploy": {
+      "post": {
+        "operationId": "deploy",
+        "summary": "app deploy",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "build",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "dockerfile",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "origin",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "archive-url": {
+                    "type": "string"
+                  },
+                  "canary": {
+                    "type": "string"
+                  },
+                  "commit": {
+                    "type": "string"
+                  },
+                  "image": {
+                    "type": "string"
+                  },
+                  "user": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/deploy/canary/abort": {
+      "post": {
+        "operationId": "deployCanaryAbort",
+        "summary": "abort canary",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "No canary deploy"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/deploy/canary/promote": {
+      "post": {
+        "operationId": "deployCanaryPromote",
+        "summary": "promote canary",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "No canary deploy"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/deploy/queue": {
+      "get": {
+        "operationId": "deployQueueList",
+        "summary": "deploy queue list",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.QueuedDeploy"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "deployQueueUpdate",
+        "summary": "deploy queue update",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "enabled": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/deploy/queue/{id}": {
+      "delete": {
+        "operationId": "deployQueueRemove",
+        "summary": "deploy queue remove",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or queued deploy not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/deploy/rollback": {
+      "post": {
+        "operationId": "deployRollback",
+        "summary": "rollback",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "image": {
+                    "type": "string"
+                  },
+                  "origin": {
+                    "type": "string"
+                  },
+                  "release": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/diff": {
+      "post": {
+        "operationId": "diffDeploy",
+        "summary": "deploy diff",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "customdata": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/promote": {
+      "post": {
+        "operationId": "deployPromote",
+        "summary": "promote image",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "image": {
+                    "type": "string"
+                  },
+                  "to": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{appname}/quota": {
+      "get": {
+        "operationId": "getAppQuota",
+        "summary": "application quota",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/quota.Quota"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Application not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "changeAppQuota",
+        "summary": "update application quota",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "appname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "limit": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Quota updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Application not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/apply": {
+      "post": {
+        "operationId": "applyManifest",
+        "summary": "apply app manifest",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "dry-run",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object"
+              }
+            },
+            "application/x-yaml": {
+              "schema": {
+                "type": "string"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.ManifestChange"
+                  }
+                }
+              },
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid manifest"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/certificate": {
+      "delete": {
+        "operationId": "unsetCertificate",
+        "summary": "app certificate unset",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "cname",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Certificate removed"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or certificate not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "listCertificates",
+        "summary": "app certificate list",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.Certificate"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "setCertificate",
+        "summary": "app certificate set",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "certificate": {
+                    "type": "string"
+                  },
+                  "cname": {
+                    "type": "string"
+                  },
+                  "key": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Certificate set"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/certificate/acme": {
+      "get": {
+        "operationId": "listACMECertificates",
+        "summary": "app ACME certificate list",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.ACMECertificate"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/cname": {
+      "delete": {
+        "operationId": "unsetCName",
+        "summary": "unset cname",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "setCName",
+        "summary": "set cname",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "cname": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/env": {
+      "delete": {
+        "operationId": "unsetEnv",
+        "summary": "unset envs",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "env",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "noRestart",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Envs removed",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getEnv",
+        "summary": "get envs",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "setEnv",
+        "summary": "set envs",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Envs": {
+                    "type": "array",
+                    "items": {
+                      "type": "object",
+                      "properties": {
+                        "Name": {
+                          "type": "string"
+                        },
+                        "Value": {
+                          "type": "string"
+                        }
+                      }
+                    }
+                  },
+                  "NoRestart": {
+                    "type": "boolean"
+                  },
+                  "Private": {
+                    "type": "boolean"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Envs updated",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/jobs": {
+      "get": {
+        "operationId": "jobList",
+        "summary": "job list",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.Job"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "jobCreate",
+        "summary": "job create",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "command": {
+                    "type": "string"
+                  },
+                  "name": {
+                    "type": "string"
+                  },
+                  "schedule": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Job created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          },
+          "409": {
+            "description": "Job already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/jobs/{name}": {
+      "delete": {
+        "operationId": "jobDelete",
+        "summary": "job delete",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Job removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "jobInfo",
+        "summary": "job info",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/app.Job"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "jobUpdate",
+        "summary": "job update",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "command": {
+                    "type": "string"
+                  },
+                  "schedule": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Job updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/jobs/{name}/run": {
+      "post": {
+        "operationId": "jobRun",
+        "summary": "job run",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/jobs/{name}/runs": {
+      "get": {
+        "operationId": "jobRuns",
+        "summary": "job runs",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "skip",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.JobRun"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/lock": {
+      "delete": {
+        "operationId": "forceDeleteLock",
+        "summary": "app unlock",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/log": {
+      "get": {
+        "operationId": "appLog",
+        "summary": "app log",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "follow",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "lines",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "source",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "unit",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addLog",
+        "summary": "app log",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "message": {
+                    "type": "string"
+                  },
+                  "source": {
+                    "type": "string"
+                  },
+                  "unit": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/manifest": {
+      "get": {
+        "operationId": "exportManifest",
+        "summary": "export app manifest",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "format",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/app.Manifest"
+                }
+              },
+              "application/x-yaml": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/metric/envs": {
+      "get": {
+        "operationId": "appMetricEnvs",
+        "summary": "metric envs",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/pool": {
+      "post": {
+        "operationId": "appChangePool",
+        "summary": "change app pool",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "pool": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/releases": {
+      "get": {
+        "operationId": "releasesList",
+        "summary": "app release list",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.Release"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/releases/diff": {
+      "get": {
+        "operationId": "releasesDiff",
+        "summary": "app release diff",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "from",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "to",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.ReleaseChange"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid release version"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or release not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/restart": {
+      "post": {
+        "operationId": "restart",
+        "summary": "app restart",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "process",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/routers": {
+      "get": {
+        "operationId": "listAppRouters",
+        "summary": "app router list",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/router.AppRouter"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addAppRouter",
+        "summary": "app router add",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Address": {
+                    "type": "string"
+                  },
+                  "Name": {
+                    "type": "string"
+                  },
+                  "Opts": {
+                    "type": "object",
+                    "additionalProperties": {
+                      "type": "string"
+                    }
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Router added"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or router not found"
+          },
+          "409": {
+            "description": "Router already attached to the app"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/routers/{router}": {
+      "delete": {
+        "operationId": "removeAppRouter",
+        "summary": "app router remove",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "router",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Router removed"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or router not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/routes": {
+      "get": {
+        "operationId": "appRoutesStatus",
+        "summary": "app routes status",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/app.RoutesStatus"
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "appRebuildRoutes",
+        "summary": "rebuild routes",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/app.RebuildRoutesResult"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/run": {
+      "post": {
+        "operationId": "runCommand",
+        "summary": "run commands",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "command": {
+                    "type": "string"
+                  },
+                  "isolated": {
+                    "type": "string"
+                  },
+                  "once": {
+                    "type": "string"
+                  },
+                  "timeout": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/sleep": {
+      "post": {
+        "operationId": "sleep",
+        "summary": "app sleep",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "process",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "proxy",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/start": {
+      "post": {
+        "operationId": "start",
+        "summary": "app start",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "process",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/stop": {
+      "post": {
+        "operationId": "stop",
+        "summary": "app stop",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "process",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/teams/{team}": {
+      "delete": {
+        "operationId": "revokeAppAccess",
+        "summary": "revoke access to app",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "team",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Access revoked"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "App or team not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "grantAppAccess",
+        "summary": "grant access to app",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "team",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Access granted"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or team not found"
+          },
+          "409": {
+            "description": "Grant already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{app}/units/{unit}": {
+      "post": {
+        "operationId": "setUnitStatus",
+        "summary": "set unit status",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "unit",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "status": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or unit not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{name}": {
+      "delete": {
+        "operationId": "appDelete",
+        "summary": "remove app",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "App removed",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "appInfo",
+        "summary": "app info",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateApp",
+        "summary": "app update",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "description": {
+                    "type": "string"
+                  },
+                  "plan": {
+                    "type": "string"
+                  },
+                  "pool": {
+                    "type": "string"
+                  },
+                  "teamOwner": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "App updated",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/apps/{name}/units": {
+      "delete": {
+        "operationId": "removeUnits",
+        "summary": "remove units",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "process",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "units",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Units removed",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "addUnits",
+        "summary": "add units",
+        "tags": [
+          "apps"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "process": {
+                    "type": "string"
+                  },
+                  "units": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Units added",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/auth/login": {
+      "post": {
+        "operationId": "login",
+        "summary": "login",
+        "tags": [
+          "auth"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        }
+      }
+    },
+    "/auth/scheme": {
+      "get": {
+        "operationId": "authScheme",
+        "summary": "get auth scheme",
+        "tags": [
+          "auth"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/api.schemeData"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/debug/goroutines": {
+      "get": {
+        "operationId": "dumpGoroutines",
+        "summary": "dump goroutines",
+        "tags": [
+          "debug"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/debug/pprof": {
+      "get": {
+        "operationId": "indexHandler",
+        "summary": "profile index handler",
+        "tags": [
+          "debug"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/debug/pprof/cmdline": {
+      "get": {
+        "operationId": "cmdlineHandler",
+        "summary": "profile cmdline handler",
+        "tags": [
+          "debug"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/debug/pprof/profile": {
+      "get": {
+        "operationId": "profileHandler",
+        "summary": "profile handler",
+        "tags": [
+          "debug"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/debug/pprof/symbol": {
+      "get": {
+        "operationId": "symbolHandler",
+        "summary": "profile symbol handler",
+        "tags": [
+          "debug"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/deploys": {
+      "get": {
+        "operationId": "deploysList",
+        "summary": "deploy list",
+        "tags": [
+          "deploys"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "skip",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.DeployData"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/deploys/{deploy}": {
+      "get": {
+        "operationId": "deployInfo",
+        "summary": "deploy info",
+        "tags": [
+          "deploys"
+        ],
+        "parameters": [
+          {
+            "name": "deploy",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/app.DeployData"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/deploys/{deploy}/cancel": {
+      "post": {
+        "operationId": "deployCancel",
+        "summary": "deploy cancel",
+        "tags": [
+          "deploys"
+        ],
+        "parameters": [
+          {
+            "name": "deploy",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "reason": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "204": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Empty reason"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          },
+          "409": {
+            "description": "Deploy is not cancelable"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/deploys/{deploy}/log": {
+      "get": {
+        "operationId": "deployLog",
+        "summary": "deploy log",
+        "tags": [
+          "deploys"
+        ],
+        "parameters": [
+          {
+            "name": "deploy",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "text": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/autoscale/config": {
+      "get": {
+        "operationId": "autoScaleGetConfig",
+        "summary": "get autoscale config",
+        "tags": [
+          "docker"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/docker.autoScaleConfig"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/autoscale/rules": {
+      "get": {
+        "operationId": "autoScaleListRules",
+        "summary": "autoscale rules list",
+        "tags": [
+          "docker"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/docker.autoScaleRule"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "autoScaleSetRule",
+        "summary": "autoscale set rule",
+        "tags": [
+          "docker"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Enabled": {
+                    "type": "boolean"
+                  },
+                  "Error": {
+                    "type": "string"
+                  },
+                  "MaxContainerCount": {
+                    "type": "integer"
+                  },
+                  "MaxMemoryRatio": {
+                    "type": "number",
+                    "format": "float"
+                  },
+                  "MetadataFilter": {
+                    "type": "string"
+                  },
+                  "PreventRebalance": {
+                    "type": "boolean"
+                  },
+                  "ScaleDownRatio": {
+                    "type": "number",
+                    "format": "float"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/autoscale/rules/{id}": {
+      "delete": {
+        "operationId": "autoScaleDeleteRule",
+        "summary": "delete autoscale rule",
+        "tags": [
+          "docker"
+        ],
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/autoscale/units": {
+      "get": {
+        "operationId": "unitAutoScaleHistoryHandler",
+        "summary": "unit autoscale history",
+        "tags": [
+          "docker"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "skip",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/docker.unitAutoScaleEvent"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/autoscale/units/rules": {
+      "get": {
+        "operationId": "unitAutoScaleListRules",
+        "summary": "unit autoscale rules list",
+        "tags": [
+          "docker"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/docker.unitAutoScaleRule"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "unitAutoScaleSetRule",
+        "summary": "unit autoscale set rule",
+        "tags": [
+          "docker"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "AppName": {
+                    "type": "string"
+                  },
+                  "CPUTarget": {
+                    "type": "number",
+                    "format": "double"
+                  },
+                  "Enabled": {
+                    "type": "boolean"
+                  },
+                  "MaxUnits": {
+                    "type": "integer"
+                  },
+                  "MemoryTarget": {
+                    "type": "number",
+                    "format": "double"
+                  },
+                  "MinUnits": {
+                    "type": "integer"
+                  },
+                  "Process": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/autoscale/units/rules/{app}": {
+      "delete": {
+        "operationId": "unitAutoScaleDeleteRule",
+        "summary": "unit autoscale delete rule",
+        "tags": [
+          "docker"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "process",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/container/{id}/move": {
+      "post": {
+        "operationId": "moveContainerHandler",
+        "summary": "move container",
+        "tags": [
+          "docker"
+        ],
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/containers/move": {
+      "post": {
+        "operationId": "moveContainersHandler",
+        "summary": "move containers",
+        "tags": [
+          "docker"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/node": {
+      "get": {
+        "operationId": "listNodesHandler",
+        "summary": "list nodes",
+        "tags": [
+          "docker"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {}
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addNodeHandler",
+        "summary": "add node",
+        "tags": [
+          "docker"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateNodeHandler",
+        "summary": "update nodes",
+        "tags": [
+          "docker"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/node/{address}": {
+      "delete": {
+        "operationId": "removeNodeHandler",
+        "summary": "remove node",
+        "tags": [
+          "docker"
+        ],
+        "parameters": [
+          {
+            "name": "address",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "no-rebalance",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "remove-iaas",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/nodecontainers": {
+      "get": {
+        "operationId": "nodeContainerList",
+        "summary": "remove node container list",
+        "tags": [
+          "docker"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/nodecontainer.NodeContainerConfigGroup"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/docker/nodecontainers/{name}": {
+      "delete": {
+        "operationId": "nodeContainerDelete",
+        "summary": "remove node container",
+        "tags": [
+          "docker"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "pool",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/events": {
+      "get": {
+        "operationId": "eventList",
+        "summary": "event list",
+        "tags": [
+          "events"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/event.Event"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "400": {
+            "description": "Invalid filter"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/events/{uuid}": {
+      "get": {
+        "operationId": "eventInfo",
+        "summary": "event info",
+        "tags": [
+          "events"
+        ],
+        "parameters": [
+          {
+            "name": "uuid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/event.Event"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid uuid"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/events/{uuid}/cancel": {
+      "post": {
+        "operationId": "eventCancel",
+        "summary": "event cancel",
+        "tags": [
+          "events"
+        ],
+        "parameters": [
+          {
+            "name": "uuid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "reason": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "204": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Invalid uuid or empty reason"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          },
+          "409": {
+            "description": "Event is not cancelable"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/healthcheck": {
+      "get": {
+        "operationId": "healthcheck",
+        "summary": "healthcheck",
+        "tags": [
+          "healthcheck"
+        ],
+        "parameters": [
+          {
+            "name": "check",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "500": {
+            "description": "Internal server error"
+          }
+        }
+      }
+    },
+    "/iaas/machines": {
+      "get": {
+        "operationId": "machinesList",
+        "summary": "machine list",
+        "tags": [
+          "iaas"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/iaas.Machine"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/iaas/machines/{machine_id}": {
+      "delete": {
+        "operationId": "machineDestroy",
+        "summary": "machine destroy",
+        "tags": [
+          "iaas"
+        ],
+        "parameters": [
+          {
+            "name": "machine_id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/iaas/templates": {
+      "get": {
+        "operationId": "templatesList",
+        "summary": "machine template list",
+        "tags": [
+          "iaas"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/iaas.Template"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "templateCreate",
+        "summary": "template create",
+        "tags": [
+          "iaas"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Data": {
+                    "type": "array",
+                    "items": {
+                      "$ref": "#/components/schemas/iaas.TemplateData"
+                    }
+                  },
+                  "IaaSName": {
+                    "type": "string"
+                  },
+                  "Name": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Template created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/iaas/templates/{template_name}": {
+      "delete": {
+        "operationId": "templateDestroy",
+        "summary": "template destroy",
+        "tags": [
+          "iaas"
+        ],
+        "parameters": [
+          {
+            "name": "template_name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "templateUpdate",
+        "summary": "template update",
+        "tags": [
+          "iaas"
+        ],
+        "parameters": [
+          {
+            "name": "template_name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Data": {
+                    "type": "array",
+                    "items": {
+                      "$ref": "#/components/schemas/iaas.TemplateData"
+                    }
+                  },
+                  "IaaSName": {
+                    "type": "string"
+                  },
+                  "Name": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/info": {
+      "get": {
+        "operationId": "info",
+        "summary": "api info",
+        "tags": [
+          "info"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/logs/config": {
+      "get": {
+        "operationId": "logServiceConfigList",
+        "summary": "log service config list",
+        "tags": [
+          "logs"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {
+                    "$ref": "#/components/schemas/app.LogServiceConfig"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "logServiceConfigSet",
+        "summary": "log service config set",
+        "tags": [
+          "logs"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Backend": {
+                    "type": "string"
+                  },
+                  "Index": {
+                    "type": "string"
+                  },
+                  "URL": {
+                    "type": "string"
+                  },
+                  "pool": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/metrics": {
+      "get": {
+        "operationId": "metricsHandler",
+        "summary": "metrics",
+        "tags": [
+          "metrics"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "text/plain": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/node/status": {
+      "post": {
+        "operationId": "setNodeStatus",
+        "summary": "set node status",
+        "tags": [
+          "node"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Addrs": {
+                    "type": "array",
+                    "items": {
+                      "type": "string"
+                    }
+                  },
+                  "Checks": {
+                    "type": "array",
+                    "items": {
+                      "$ref": "#/components/schemas/provision.NodeCheckResult"
+                    }
+                  },
+                  "Units": {
+                    "type": "array",
+                    "items": {
+                      "$ref": "#/components/schemas/provision.UnitStatusData"
+                    }
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.UpdateUnitsResult"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or unit not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "openAPI",
+        "summary": "openapi specification",
+        "tags": [
+          "openapi.json"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {}
+            }
+          }
+        }
+      }
+    },
+    "/permissions": {
+      "get": {
+        "operationId": "listPermissions",
+        "summary": "list permissions",
+        "tags": [
+          "permissions"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/api.permissionSchemeData"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/plans": {
+      "get": {
+        "operationId": "listPlans",
+        "summary": "plan list",
+        "tags": [
+          "plans"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.Plan"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addPlan",
+        "summary": "plan create",
+        "tags": [
+          "plans"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "cpushare": {
+                    "type": "string"
+                  },
+                  "default": {
+                    "type": "string"
+                  },
+                  "memory": {
+                    "type": "string"
+                  },
+                  "name": {
+                    "type": "string"
+                  },
+                  "router": {
+                    "type": "string"
+                  },
+                  "swap": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Plan created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Plan already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/plans/routers": {
+      "get": {
+        "operationId": "listRouters",
+        "summary": "router list",
+        "tags": [
+          "plans"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/router.PlanRouter"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/plans/{name}": {
+      "delete": {
+        "operationId": "removePlan",
+        "summary": "remove plan",
+        "tags": [
+          "plans"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Plan removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Plan not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/platforms": {
+      "get": {
+        "operationId": "platformList",
+        "summary": "platform list",
+        "tags": [
+          "platforms"
+        ],
+        "responses": {
+          "200": {
+            "description": "List platforms",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.Platform"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "platformAdd",
+        "summary": "add platform",
+        "tags": [
+          "platforms"
+        ],
+        "requestBody": {
+          "content": {
+            "multipart/form-data": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "dockerfile_content": {
+                    "type": "string",
+                    "format": "binary"
+                  },
+                  "name": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Platform created",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/platforms/{name}": {
+      "delete": {
+        "operationId": "platformRemove",
+        "summary": "remove platform",
+        "tags": [
+          "platforms"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Platform removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "platformUpdate",
+        "summary": "update platform",
+        "tags": [
+          "platforms"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Platform updated",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/pools": {
+      "get": {
+        "operationId": "poolList",
+        "summary": "pool list",
+        "tags": [
+          "pools"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/provision.Pool"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "User not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addPoolHandler",
+        "summary": "pool create",
+        "tags": [
+          "pools"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "default": {
+                    "type": "string"
+                  },
+                  "force": {
+                    "type": "string"
+                  },
+                  "name": {
+                    "type": "string"
+                  },
+                  "provisioner": {
+                    "type": "string"
+                  },
+                  "public": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Pool created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Pool already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/pools/{name}": {
+      "delete": {
+        "operationId": "removePoolHandler",
+        "summary": "remove pool",
+        "tags": [
+          "pools"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Pool removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Pool not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "poolUpdateHandler",
+        "summary": "pool update",
+        "tags": [
+          "pools"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "default": {
+                    "type": "string"
+                  },
+                  "force": {
+                    "type": "string"
+                  },
+                  "provisioner": {
+                    "type": "string"
+                  },
+                  "public": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Pool updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Pool not found"
+          },
+          "409": {
+            "description": "Default pool already defined"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/pools/{name}/team": {
+      "delete": {
+        "operationId": "removeTeamToPoolHandler",
+        "summary": "remove team from pool",
+        "tags": [
+          "pools"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Pool updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Pool not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addTeamToPoolHandler",
+        "summary": "add team too pool",
+        "tags": [
+          "pools"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "team": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Pool updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Pool not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/role/default": {
+      "get": {
+        "operationId": "listDefaultRoles",
+        "summary": "list default roles",
+        "tags": [
+          "role"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/permission.Role"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addDefaultRole",
+        "summary": "add default role",
+        "tags": [
+          "role"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/roles": {
+      "get": {
+        "operationId": "listRoles",
+        "summary": "role list",
+        "tags": [
+          "roles"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addRole",
+        "summary": "role create",
+        "tags": [
+          "roles"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "context": {
+                    "type": "string"
+                  },
+                  "description": {
+                    "type": "string"
+                  },
+                  "name": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Role created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Role already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/roles/{name}": {
+      "delete": {
+        "operationId": "removeRole",
+        "summary": "remove role",
+        "tags": [
+          "roles"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Role removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Role not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "roleInfo",
+        "summary": "role info",
+        "tags": [
+          "roles"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Role not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/roles/{name}/permissions": {
+      "post": {
+        "operationId": "addPermissions",
+        "summary": "add permissions",
+        "tags": [
+          "roles"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "permission": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Permission not allowed"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/roles/{name}/permissions/{permission}": {
+      "delete": {
+        "operationId": "removePermissions",
+        "summary": "remove permission",
+        "tags": [
+          "roles"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "permission",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Permission removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/roles/{name}/user": {
+      "post": {
+        "operationId": "assignRole",
+        "summary": "assign role to user",
+        "tags": [
+          "roles"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "context": {
+                    "type": "string"
+                  },
+                  "email": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Role not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/roles/{name}/user/{email}": {
+      "delete": {
+        "operationId": "dissociateRole",
+        "summary": "dissociate role from user",
+        "tags": [
+          "roles"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "email",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "context",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Role not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/routes/drift": {
+      "get": {
+        "operationId": "routesDriftList",
+        "summary": "routes drift list",
+        "tags": [
+          "routes"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.RoutesStatus"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services": {
+      "get": {
+        "operationId": "serviceList",
+        "summary": "service list",
+        "tags": [
+          "services"
+        ],
+        "responses": {
+          "200": {
+            "description": "List services",
+            "content": {
+              "application/json": {}
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "serviceCreate",
+        "summary": "service create",
+        "tags": [
+          "services"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "endpoint": {
+                    "type": "string"
+                  },
+                  "id": {
+                    "type": "string"
+                  },
+                  "password": {
+                    "type": "string"
+                  },
+                  "team": {
+                    "type": "string"
+                  },
+                  "username": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Service created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Service already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/instances": {
+      "get": {
+        "operationId": "serviceInstances",
+        "summary": "service instance list",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "app",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "List services instances",
+            "content": {
+              "application/json": {}
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/proxy/service/{service}": {
+      "delete": {
+        "operationId": "serviceProxyDelete",
+        "summary": "service proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "serviceProxyGet",
+        "summary": "service proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "serviceProxyPost",
+        "summary": "service proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "serviceProxyPut",
+        "summary": "service proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{name}": {
+      "delete": {
+        "operationId": "serviceDelete",
+        "summary": "service delete",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Service removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden (team is not the owner or service with instances)"
+          },
+          "404": {
+            "description": "Service not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "serviceInfo",
+        "summary": "service info",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "type": "object"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "serviceUpdate",
+        "summary": "service update",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "endpoint": {
+                    "type": "string"
+                  },
+                  "password": {
+                    "type": "string"
+                  },
+                  "username": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Service updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden (team is not the owner)"
+          },
+          "404": {
+            "description": "Service not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{name}/doc": {
+      "get": {
+        "operationId": "serviceDoc",
+        "summary": "service doc",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "serviceAddDoc",
+        "summary": "change service documentation",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "doc": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Documentation updated"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden (team is not the owner or service with instances)"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{name}/instances/{instance}": {
+      "delete": {
+        "operationId": "removeServiceInstance",
+        "summary": "remove service instance",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "unbindall",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Service removed",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{name}/plans": {
+      "get": {
+        "operationId": "servicePlans",
+        "summary": "service plans",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/service.Plan"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{service}/instances": {
+      "post": {
+        "operationId": "createServiceInstance",
+        "summary": "service instance create",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "description": {
+                    "type": "string"
+                  },
+                  "name": {
+                    "type": "string"
+                  },
+                  "owner": {
+                    "type": "string"
+                  },
+                  "plan": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Service created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Service already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{service}/instances/permission/{instance}/{team}": {
+      "delete": {
+        "operationId": "serviceInstanceRevokeTeam",
+        "summary": "revoke access to service instance",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "team",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Access revoked"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "serviceInstanceGrantTeam",
+        "summary": "grant access to service instance",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "team",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Access granted"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{service}/instances/{instance}": {
+      "get": {
+        "operationId": "serviceInstance",
+        "summary": "service instance info",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/api.serviceInstanceInfo"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateServiceInstance",
+        "summary": "service instance update",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "description": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Service instance updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{service}/instances/{instance}/status": {
+      "get": {
+        "operationId": "serviceInstanceStatus",
+        "summary": "service instance status",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "List services instances"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{service}/instances/{instance}/{app}": {
+      "delete": {
+        "operationId": "unbindServiceInstance",
+        "summary": "unbind service instance",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "noRestart",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "bindServiceInstance",
+        "summary": "bind service instance",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "app",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "noRestart": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/x-json-stream": {}
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{service}/proxy/{instance}": {
+      "delete": {
+        "operationId": "serviceInstanceProxyDelete",
+        "summary": "service instance proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "serviceInstanceProxyGet",
+        "summary": "service instance proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "serviceInstanceProxyPost",
+        "summary": "service instance proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "serviceInstanceProxyPut",
+        "summary": "service instance proxy",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "instance",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "callback",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Instance not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/services/{service}/team/{team}": {
+      "delete": {
+        "operationId": "revokeServiceAccess",
+        "summary": "revoke access to a service",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "team",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Access revoked"
+          },
+          "400": {
+            "description": "Team not found"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service not found"
+          },
+          "409": {
+            "description": "Team does not has access to this service"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "grantServiceAccess",
+        "summary": "grant access to a service",
+        "tags": [
+          "services"
+        ],
+        "parameters": [
+          {
+            "name": "service",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "team",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Service updated"
+          },
+          "400": {
+            "description": "Team not found"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Service not found"
+          },
+          "409": {
+            "description": "Team already has access to this service"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/swap": {
+      "post": {
+        "operationId": "swap",
+        "summary": "app swap",
+        "tags": [
+          "swap"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "app1": {
+                    "type": "string"
+                  },
+                  "app2": {
+                    "type": "string"
+                  },
+                  "cnameOnly": {
+                    "type": "string"
+                  },
+                  "force": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App not found"
+          },
+          "409": {
+            "description": "App locked"
+          },
+          "412": {
+            "description": "Number of units or platform don't match"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/teams": {
+      "get": {
+        "operationId": "teamList",
+        "summary": "team list",
+        "tags": [
+          "teams"
+        ],
+        "responses": {
+          "200": {
+            "description": "List teams",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "type": "object",
+                    "additionalProperties": {}
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createTeam",
+        "summary": "team create",
+        "tags": [
+          "teams"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "name": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Team created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Team already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/teams/{name}": {
+      "delete": {
+        "operationId": "removeTeam",
+        "summary": "remove team",
+        "tags": [
+          "teams"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Team removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/units/status": {
+      "post": {
+        "operationId": "setUnitsStatus",
+        "summary": "set unit status",
+        "tags": [
+          "units"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Units": {
+                    "type": "array",
+                    "items": {
+                      "$ref": "#/components/schemas/provision.UnitStatusData"
+                    }
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/app.UpdateUnitsResult"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "App or unit not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users": {
+      "delete": {
+        "operationId": "removeUser",
+        "summary": "remove user",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "user",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "User removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "listUsers",
+        "summary": "user list",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "context",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "role",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "userEmail",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/api.apiUser"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createUser",
+        "summary": "user create",
+        "tags": [
+          "users"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "email": {
+                    "type": "string"
+                  },
+                  "password": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "User created"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "409": {
+            "description": "User already exists"
+          }
+        }
+      }
+    },
+    "/users/api-key": {
+      "get": {
+        "operationId": "showAPIToken",
+        "summary": "show token",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "user",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "User not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "regenerateAPIToken",
+        "summary": "regenerate token",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "user",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "User not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/info": {
+      "get": {
+        "operationId": "userInfo",
+        "summary": "user info",
+        "tags": [
+          "users"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/api.apiUser"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/keys": {
+      "get": {
+        "operationId": "listKeys",
+        "summary": "list keys",
+        "tags": [
+          "users"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "addKeyToUser",
+        "summary": "add key",
+        "tags": [
+          "users"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "force": {
+                    "type": "string"
+                  },
+                  "key": {
+                    "type": "string"
+                  },
+                  "name": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Key already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/keys/{key}": {
+      "delete": {
+        "operationId": "removeKeyFromUser",
+        "summary": "remove key",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "key",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/password": {
+      "put": {
+        "operationId": "changePassword",
+        "summary": "change password",
+        "tags": [
+          "users"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "confirm": {
+                    "type": "string"
+                  },
+                  "new": {
+                    "type": "string"
+                  },
+                  "old": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/tokens": {
+      "delete": {
+        "operationId": "logout",
+        "summary": "logout",
+        "tags": [
+          "users"
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{email}/password": {
+      "post": {
+        "operationId": "resetPassword",
+        "summary": "reset password",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "email",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "token",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Ok"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "403": {
+            "description": "Forbidden"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        }
+      }
+    },
+    "/users/{email}/quota": {
+      "get": {
+        "operationId": "getUserQuota",
+        "summary": "user quota",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "email",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/quota.Quota"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "User not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "changeUserQuota",
+        "summary": "update user quota",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "email",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "limit": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Quota updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "User not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/webhooks": {
+      "get": {
+        "operationId": "webhookList",
+        "summary": "webhook list",
+        "tags": [
+          "webhooks"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/webhook.Webhook"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "webhookCreate",
+        "summary": "webhook create",
+        "tags": [
+          "webhooks"
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Description": {
+                    "type": "string"
+                  },
+                  "EventFilter": {
+                    "$ref": "#/components/schemas/webhook.EventFilter"
+                  },
+                  "Name": {
+                    "type": "string"
+                  },
+                  "Secret": {
+                    "type": "string"
+                  },
+                  "TeamOwner": {
+                    "type": "string"
+                  },
+                  "URL": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Webhook created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "409": {
+            "description": "Webhook already exists"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/webhooks/{name}": {
+      "delete": {
+        "operationId": "webhookDelete",
+        "summary": "webhook delete",
+        "tags": [
+          "webhooks"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Webhook removed"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "webhookInfo",
+        "summary": "webhook info",
+        "tags": [
+          "webhooks"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/webhook.Webhook"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "webhookUpdate",
+        "summary": "webhook update",
+        "tags": [
+          "webhooks"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "Description": {
+                    "type": "string"
+                  },
+                  "EventFilter": {
+                    "$ref": "#/components/schemas/webhook.EventFilter"
+                  },
+                  "Name": {
+                    "type": "string"
+                  },
+                  "Secret": {
+                    "type": "string"
+                  },
+                  "TeamOwner": {
+                    "type": "string"
+                  },
+                  "URL": {
+                    "type": "string"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Webhook updated"
+          },
+          "400": {
+            "description": "Invalid data"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/webhooks/{name}/deliveries": {
+      "get": {
+        "operationId": "webhookDeliveries",
+        "summary": "webhook deliveries",
+        "tags": [
+          "webhooks"
+        ],
+        "parameters": [
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "skip",
+            "in": "query",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/webhook.Delivery"
+                  }
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "No content"
+          },
+          "401": {
+            "description": "Unauthorized"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        },
+        "security": [
+          {
+            "bearer": []
+          }
+        ]
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "api.apiUser": {
+        "type": "object",
+        "properties": {
+          "Email": {
+            "type": "string"
+          },
+          "Permissions": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/api.rolePermissionData"
+            }
+          },
+          "Roles": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/api.rolePermissionData"
+            }
+          }
+        }
+      },
+      "api.miniApp": {
+        "type": "object",
+        "properties": {
+          "cname": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "ip": {
+            "type": "string"
+          },
+          "lock": {},
+          "name": {
+            "type": "string"
+          },
+          "units": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/provision.Unit"
+            }
+          }
+        }
+      },
+      "api.permissionSchemeData": {
+        "type": "object",
+        "properties": {
+          "Contexts": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Name": {
+            "type": "string"
+          }
+        }
+      },
+      "api.rolePermissionData": {
+        "type": "object",
+        "properties": {
+          "ContextType": {
+            "type": "string"
+          },
+          "ContextValue": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          }
+        }
+      },
+      "api.schemeData": {
+        "type": "object",
+        "properties": {
+          "data": {
+            "type": "object",
+            "additionalProperties": {}
+          },
+          "name": {
+            "type": "string"
+          }
+        }
+      },
+      "api.serviceInstanceInfo": {
+        "type": "object",
+        "properties": {
+          "Apps": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "CustomInfo": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "Description": {
+            "type": "string"
+          },
+          "PlanDescription": {
+            "type": "string"
+          },
+          "PlanName": {
+            "type": "string"
+          },
+          "TeamOwner": {
+            "type": "string"
+          },
+          "Teams": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "app.ACMECertificate": {
+        "type": "object",
+        "properties": {
+          "App": {
+            "type": "string"
+          },
+          "Attempts": {
+            "type": "integer"
+          },
+          "CName": {
+            "type": "string"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "ExpiresAt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "NextAttempt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Status": {
+            "type": "string"
+          },
+          "UpdatedAt": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "app.Certificate": {
+        "type": "object",
+        "properties": {
+          "CName": {
+            "type": "string"
+          },
+          "Certificate": {
+            "type": "string"
+          },
+          "ExpiresAt": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "app.DeployData": {
+        "type": "object",
+        "properties": {
+          "App": {
+            "type": "string"
+          },
+          "CanRollback": {
+            "type": "boolean"
+          },
+          "Canceled": {
+            "type": "boolean"
+          },
+          "Commit": {
+            "type": "string"
+          },
+          "Diff": {
+            "type": "string"
+          },
+          "Duration": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "ID": {},
+          "Image": {
+            "type": "string"
+          },
+          "Log": {
+            "type": "string"
+          },
+          "Origin": {
+            "type": "string"
+          },
+          "RemoveDate": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Running": {
+            "type": "boolean"
+          },
+          "Timestamp": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "User": {
+            "type": "string"
+          }
+        }
+      },
+      "app.Job": {
+        "type": "object",
+        "properties": {
+          "App": {
+            "type": "string"
+          },
+          "Command": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "NextRun": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Schedule": {
+            "type": "string"
+          }
+        }
+      },
+      "app.JobRun": {
+        "type": "object",
+        "properties": {
+          "App": {
+            "type": "string"
+          },
+          "Command": {
+            "type": "string"
+          },
+          "EndTime": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "ExitCode": {
+            "type": "integer"
+          },
+          "ID": {},
+          "Job": {
+            "type": "string"
+          },
+          "Manual": {
+            "type": "boolean"
+          },
+          "Output": {
+            "type": "string"
+          },
+          "StartTime": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "app.LogServiceConfig": {
+        "type": "object",
+        "properties": {
+          "Backend": {
+            "type": "string"
+          },
+          "Index": {
+            "type": "string"
+          },
+          "URL": {
+            "type": "string"
+          }
+        }
+      },
+      "app.Manifest": {
+        "type": "object",
+        "properties": {
+          "cnames": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "description": {
+            "type": "string"
+          },
+          "envs": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/app.ManifestEnv"
+            }
+          },
+          "name": {
+            "type": "string"
+          },
+          "plan": {
+            "type": "string"
+          },
+          "pool": {
+            "type": "string"
+          },
+          "services": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/app.ManifestService"
+            }
+          },
+          "teamOwner": {
+            "type": "string"
+          },
+          "units": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "integer"
+            }
+          }
+        }
+      },
+      "app.ManifestChange": {
+        "type": "object",
+        "properties": {
+          "action": {
+            "type": "string"
+          },
+          "field": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "new": {
+            "type": "string"
+          },
+          "old": {
+            "type": "string"
+          }
+        }
+      },
+      "app.ManifestEnv": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "private": {
+            "type": "boolean"
+          },
+          "value": {
+            "type": "string"
+          }
+        }
+      },
+      "app.ManifestService": {
+        "type": "object",
+        "properties": {
+          "instance": {
+            "type": "string"
+          },
+          "service": {
+            "type": "string"
+          }
+        }
+      },
+      "app.Plan": {
+        "type": "object",
+        "properties": {
+          "cpushare": {
+            "type": "integer"
+          },
+          "default": {
+            "type": "boolean"
+          },
+          "memory": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "name": {
+            "type": "string"
+          },
+          "router": {
+            "type": "string"
+          },
+          "swap": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "app.Platform": {
+        "type": "object",
+        "properties": {
+          "Disabled": {
+            "type": "boolean"
+          },
+          "Name": {
+            "type": "string"
+          }
+        }
+      },
+      "app.QueuedDeploy": {
+        "type": "object",
+        "properties": {
+          "App": {
+            "type": "string"
+          },
+          "ArchiveURL": {
+            "type": "string"
+          },
+          "Attempts": {
+            "type": "integer"
+          },
+          "CanaryWeight": {
+            "type": "integer"
+          },
+          "Commit": {
+            "type": "string"
+          },
+          "ID": {},
+          "Image": {
+            "type": "string"
+          },
+          "Kind": {
+            "type": "string"
+          },
+          "NextAttempt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Origin": {
+            "type": "string"
+          },
+          "Timestamp": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "User": {
+            "type": "string"
+          }
+        }
+      },
+      "app.RebuildRoutesResult": {
+        "type": "object",
+        "properties": {
+          "Added": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Removed": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "app.Release": {
+        "type": "object",
+        "properties": {
+          "app": {
+            "type": "string"
+          },
+          "envs": {
+            "type": "object",
+            "additionalProperties": {
+              "$ref": "#/components/schemas/bind.EnvVar"
+            }
+          },
+          "image": {
+            "type": "string"
+          },
+          "processes": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "integer"
+            }
+          },
+          "reason": {
+            "type": "string"
+          },
+          "rollbackOf": {
+            "type": "integer"
+          },
+          "timestamp": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "tsuruYaml": {
+            "$ref": "#/components/schemas/provision.TsuruYamlData"
+          },
+          "user": {
+            "type": "string"
+          },
+          "version": {
+            "type": "integer"
+          }
+        }
+      },
+      "app.ReleaseChange": {
+        "type": "object",
+        "properties": {
+          "action": {
+            "type": "string"
+          },
+          "field": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "new": {
+            "type": "string"
+          },
+          "old": {
+            "type": "string"
+          }
+        }
+      },
+      "app.RouterRoutesStatus": {
+        "type": "object",
+        "properties": {
+          "Error": {
+            "type": "string"
+          },
+          "Extra": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Missing": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Router": {
+            "type": "string"
+          }
+        }
+      },
+      "app.RoutesStatus": {
+        "type": "object",
+        "properties": {
+          "App": {
+            "type": "string"
+          },
+          "CheckedAt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "Fixed": {
+            "type": "boolean"
+          },
+          "Routers": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/app.RouterRoutesStatus"
+            }
+          }
+        }
+      },
+      "app.UpdateUnitsResult": {
+        "type": "object",
+        "properties": {
+          "Found": {
+            "type": "boolean"
+          },
+          "ID": {
+            "type": "string"
+          }
+        }
+      },
+      "bind.EnvVar": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "public": {
+            "type": "boolean"
+          },
+          "value": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.BlockLimit": {
+        "type": "object",
+        "properties": {
+          "Path": {
+            "type": "string"
+          },
+          "Rate": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.BlockWeight": {
+        "type": "object",
+        "properties": {
+          "Path": {
+            "type": "string"
+          },
+          "Weight": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.Config": {
+        "type": "object",
+        "properties": {
+          "AttachStderr": {
+            "type": "boolean"
+          },
+          "AttachStdin": {
+            "type": "boolean"
+          },
+          "AttachStdout": {
+            "type": "boolean"
+          },
+          "Cmd": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "CpuShares": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "Cpuset": {
+            "type": "string"
+          },
+          "Dns": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Domainname": {
+            "type": "string"
+          },
+          "Entrypoint": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Env": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "ExposedPorts": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "object"
+            }
+          },
+          "Hostname": {
+            "type": "string"
+          },
+          "Image": {
+            "type": "string"
+          },
+          "KernelMemory": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "Labels": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "MacAddress": {
+            "type": "string"
+          },
+          "Memory": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "MemoryReservation": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "MemorySwap": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "Mounts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.Mount"
+            }
+          },
+          "NetworkDisabled": {
+            "type": "boolean"
+          },
+          "OnBuild": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "OpenStdin": {
+            "type": "boolean"
+          },
+          "PidsLimit": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "PortSpecs": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "SecurityOpts": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "StdinOnce": {
+            "type": "boolean"
+          },
+          "StopSignal": {
+            "type": "string"
+          },
+          "Tty": {
+            "type": "boolean"
+          },
+          "User": {
+            "type": "string"
+          },
+          "VolumeDriver": {
+            "type": "string"
+          },
+          "Volumes": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "object"
+            }
+          },
+          "VolumesFrom": {
+            "type": "string"
+          },
+          "WorkingDir": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.Device": {
+        "type": "object",
+        "properties": {
+          "CgroupPermissions": {
+            "type": "string"
+          },
+          "PathInContainer": {
+            "type": "string"
+          },
+          "PathOnHost": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.HostConfig": {
+        "type": "object",
+        "properties": {
+          "Binds": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "BlkioDeviceReadBps": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.BlockLimit"
+            }
+          },
+          "BlkioDeviceReadIOps": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.BlockLimit"
+            }
+          },
+          "BlkioDeviceWriteBps": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.BlockLimit"
+            }
+          },
+          "BlkioDeviceWriteIOps": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.BlockLimit"
+            }
+          },
+          "BlkioWeight": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "BlkioWeightDevice": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.BlockWeight"
+            }
+          },
+          "CapAdd": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "CapDrop": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "CgroupParent": {
+            "type": "string"
+          },
+          "ContainerIDFile": {
+            "type": "string"
+          },
+          "CpuPeriod": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "CpuQuota": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "CpuShares": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "Cpuset": {
+            "type": "string"
+          },
+          "CpusetCpus": {
+            "type": "string"
+          },
+          "CpusetMems": {
+            "type": "string"
+          },
+          "Devices": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.Device"
+            }
+          },
+          "Dns": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "DnsOptions": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "DnsSearch": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "ExtraHosts": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "GroupAdd": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "IpcMode": {
+            "type": "string"
+          },
+          "Links": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "LogConfig": {
+            "$ref": "#/components/schemas/docker.LogConfig"
+          },
+          "LxcConf": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.KeyValuePair"
+            }
+          },
+          "Memory": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "MemorySwap": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "MemorySwappiness": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "NetworkMode": {
+            "type": "string"
+          },
+          "OomKillDisable": {
+            "type": "boolean"
+          },
+          "OomScoreAdj": {
+            "type": "integer"
+          },
+          "PidMode": {
+            "type": "string"
+          },
+          "PortBindings": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "array",
+              "items": {
+                "$ref": "#/components/schemas/docker.PortBinding"
+              }
+            }
+          },
+          "Privileged": {
+            "type": "boolean"
+          },
+          "PublishAllPorts": {
+            "type": "boolean"
+          },
+          "ReadonlyRootfs": {
+            "type": "boolean"
+          },
+          "RestartPolicy": {
+            "$ref": "#/components/schemas/docker.RestartPolicy"
+          },
+          "SecurityOpt": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "ShmSize": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "UTSMode": {
+            "type": "string"
+          },
+          "Ulimits": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/docker.ULimit"
+            }
+          },
+          "UsernsMode": {
+            "type": "string"
+          },
+          "VolumeDriver": {
+            "type": "string"
+          },
+          "VolumesFrom": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "docker.KeyValuePair": {
+        "type": "object",
+        "properties": {
+          "Key": {
+            "type": "string"
+          },
+          "Value": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.LogConfig": {
+        "type": "object",
+        "properties": {
+          "Config": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "Type": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.Mount": {
+        "type": "object",
+        "properties": {
+          "Destination": {
+            "type": "string"
+          },
+          "Driver": {
+            "type": "string"
+          },
+          "Mode": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "RW": {
+            "type": "boolean"
+          },
+          "Source": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.PortBinding": {
+        "type": "object",
+        "properties": {
+          "HostIP": {
+            "type": "string"
+          },
+          "HostPort": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.RestartPolicy": {
+        "type": "object",
+        "properties": {
+          "MaximumRetryCount": {
+            "type": "integer"
+          },
+          "Name": {
+            "type": "string"
+          }
+        }
+      },
+      "docker.ULimit": {
+        "type": "object",
+        "properties": {
+          "Hard": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "Soft": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "docker.autoScaleConfig": {
+        "type": "object",
+        "properties": {
+          "Enabled": {
+            "type": "boolean"
+          },
+          "GroupByMetadata": {
+            "type": "string"
+          },
+          "RunInterval": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "TotalMemoryMetadata": {
+            "type": "string"
+          },
+          "WaitTimeNewMachine": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "docker.autoScaleRule": {
+        "type": "object",
+        "properties": {
+          "Enabled": {
+            "type": "boolean"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "MaxContainerCount": {
+            "type": "integer"
+          },
+          "MaxMemoryRatio": {
+            "type": "number",
+            "format": "float"
+          },
+          "MetadataFilter": {
+            "type": "string"
+          },
+          "PreventRebalance": {
+            "type": "boolean"
+          },
+          "ScaleDownRatio": {
+            "type": "number",
+            "format": "float"
+          }
+        }
+      },
+      "docker.unitAutoScaleEvent": {
+        "type": "object",
+        "properties": {
+          "Action": {
+            "type": "string"
+          },
+          "AppName": {
+            "type": "string"
+          },
+          "EndTime": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "ID": {},
+          "Log": {
+            "type": "string"
+          },
+          "Process": {
+            "type": "string"
+          },
+          "Reason": {
+            "type": "string"
+          },
+          "StartTime": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Successful": {
+            "type": "boolean"
+          },
+          "UnitsAfter": {
+            "type": "integer"
+          },
+          "UnitsBefore": {
+            "type": "integer"
+          }
+        }
+      },
+      "docker.unitAutoScaleRule": {
+        "type": "object",
+        "properties": {
+          "AppName": {
+            "type": "string"
+          },
+          "CPUTarget": {
+            "type": "number",
+            "format": "double"
+          },
+          "Enabled": {
+            "type": "boolean"
+          },
+          "MaxUnits": {
+            "type": "integer"
+          },
+          "MemoryTarget": {
+            "type": "number",
+            "format": "double"
+          },
+          "MinUnits": {
+            "type": "integer"
+          },
+          "Process": {
+            "type": "string"
+          }
+        }
+      },
+      "event.CancelInfo": {
+        "type": "object",
+        "properties": {
+          "AckTime": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Asked": {
+            "type": "boolean"
+          },
+          "Canceled": {
+            "type": "boolean"
+          },
+          "Owner": {
+            "type": "string"
+          },
+          "Reason": {
+            "type": "string"
+          },
+          "StartTime": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "event.Event": {
+        "type": "object",
+        "properties": {
+          "CancelInfo": {
+            "$ref": "#/components/schemas/event.CancelInfo"
+          },
+          "Cancelable": {
+            "type": "boolean"
+          },
+          "EndCustomData": {},
+          "EndTime": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "Kind": {
+            "$ref": "#/components/schemas/event.Kind"
+          },
+          "LockUpdateTime": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Log": {
+            "type": "string"
+          },
+          "Owner": {
+            "$ref": "#/components/schemas/event.Owner"
+          },
+          "Running": {
+            "type": "boolean"
+          },
+          "StartCustomData": {},
+          "StartTime": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Target": {
+            "$ref": "#/components/schemas/event.Target"
+          },
+          "UniqueID": {}
+        }
+      },
+      "event.Kind": {
+        "type": "object",
+        "properties": {
+          "Name": {
+            "type": "string"
+          },
+          "Type": {
+            "type": "string"
+          }
+        }
+      },
+      "event.Owner": {
+        "type": "object",
+        "properties": {
+          "Name": {
+            "type": "string"
+          },
+          "Type": {
+            "type": "string"
+          }
+        }
+      },
+      "event.Target": {
+        "type": "object",
+        "properties": {
+          "Type": {
+            "type": "string"
+          },
+          "Value": {
+            "type": "string"
+          }
+        }
+      },
+      "iaas.Machine": {
+        "type": "object",
+        "properties": {
+          "Address": {
+            "type": "string"
+          },
+          "CreationParams": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "Iaas": {
+            "type": "string"
+          },
+          "Id": {
+            "type": "string"
+          },
+          "Port": {
+            "type": "integer"
+          },
+          "Status": {
+            "type": "string"
+          }
+        }
+      },
+      "iaas.Template": {
+        "type": "object",
+        "properties": {
+          "Data": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/iaas.TemplateData"
+            }
+          },
+          "IaaSName": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          }
+        }
+      },
+      "iaas.TemplateData": {
+        "type": "object",
+        "properties": {
+          "Name": {
+            "type": "string"
+          },
+          "Value": {
+            "type": "string"
+          }
+        }
+      },
+      "nodecontainer.NodeContainerConfig": {
+        "type": "object",
+        "properties": {
+          "Config": {
+            "$ref": "#/components/schemas/docker.Config"
+          },
+          "HostConfig": {
+            "$ref": "#/components/schemas/docker.HostConfig"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "PinnedImage": {
+            "type": "string"
+          }
+        }
+      },
+      "nodecontainer.NodeContainerConfigGroup": {
+        "type": "object",
+        "properties": {
+          "ConfigPools": {
+            "type": "object",
+            "additionalProperties": {
+              "$ref": "#/components/schemas/nodecontainer.NodeContainerConfig"
+            }
+          },
+          "Name": {
+            "type": "string"
+          }
+        }
+      },
+      "permission.Role": {
+        "type": "object",
+        "properties": {
+          "Description": {
+            "type": "string"
+          },
+          "context": {
+            "type": "string"
+          },
+          "events": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "name": {
+            "type": "string"
+          },
+          "scheme_names": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "provision.NodeCheckResult": {
+        "type": "object",
+        "properties": {
+          "Err": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "Successful": {
+            "type": "boolean"
+          }
+        }
+      },
+      "provision.Pool": {
+        "type": "object",
+        "properties": {
+          "Default": {
+            "type": "boolean"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "Provisioner": {
+            "type": "string"
+          },
+          "Public": {
+            "type": "boolean"
+          },
+          "Teams": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "provision.TsuruYamlData": {
+        "type": "object",
+        "properties": {
+          "Deploy": {
+            "$ref": "#/components/schemas/provision.TsuruYamlDeploy"
+          },
+          "Healthcheck": {
+            "$ref": "#/components/schemas/provision.TsuruYamlHealthcheck"
+          },
+          "Hooks": {
+            "$ref": "#/components/schemas/provision.TsuruYamlHooks"
+          }
+        }
+      },
+      "provision.TsuruYamlDeploy": {
+        "type": "object",
+        "properties": {
+          "Strategy": {
+            "type": "string"
+          },
+          "max_surge": {},
+          "max_unavailable": {}
+        }
+      },
+      "provision.TsuruYamlHealthcheck": {
+        "type": "object",
+        "properties": {
+          "Match": {
+            "type": "string"
+          },
+          "Method": {
+            "type": "string"
+          },
+          "Path": {
+            "type": "string"
+          },
+          "Status": {
+            "type": "integer"
+          },
+          "allowed_failures": {
+            "type": "integer"
+          }
+        }
+      },
+      "provision.TsuruYamlHooks": {
+        "type": "object",
+        "properties": {
+          "Build": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Restart": {
+            "$ref": "#/components/schemas/provision.TsuruYamlRestartHooks"
+          }
+        }
+      },
+      "provision.TsuruYamlRestartHooks": {
+        "type": "object",
+        "properties": {
+          "After": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Before": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "provision.Unit": {
+        "type": "object",
+        "properties": {
+          "Address": {
+            "$ref": "#/components/schemas/url.URL"
+          },
+          "AppName": {
+            "type": "string"
+          },
+          "ID": {
+            "type": "string"
+          },
+          "Ip": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "ProcessName": {
+            "type": "string"
+          },
+          "Status": {
+            "type": "string"
+          },
+          "Type": {
+            "type": "string"
+          }
+        }
+      },
+      "provision.UnitStatusData": {
+        "type": "object",
+        "properties": {
+          "ID": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "Status": {
+            "type": "string"
+          }
+        }
+      },
+      "quota.Quota": {
+        "type": "object",
+        "properties": {
+          "InUse": {
+            "type": "integer"
+          },
+          "Limit": {
+            "type": "integer"
+          }
+        }
+      },
+      "router.AppRouter": {
+        "type": "object",
+        "properties": {
+          "address": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "opts": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "router.PlanRouter": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string"
+          }
+        }
+      },
+      "service.Plan": {
+        "type": "object",
+        "properties": {
+          "Description": {
+            "type": "string"
+          },
+          "Name": {
+            "type": "string"
+          }
+        }
+      },
+      "url.URL": {
+        "type": "object",
+        "properties": {
+          "ForceQuery": {
+            "type": "boolean"
+          },
+          "Fragment": {
+            "type": "string"
+          },
+          "Host": {
+            "type": "string"
+          },
+          "OmitHost": {
+            "type": "boolean"
+          },
+          "Opaque": {
+            "type": "string"
+          },
+          "Path": {
+            "type": "string"
+          },
+          "RawFragment": {
+            "type": "string"
+          },
+          "RawPath": {
+            "type": "string"
+          },
+          "RawQuery": {
+            "type": "string"
+          },
+          "Scheme": {
+            "type": "string"
+          },
+          "User": {
+            "$ref": "#/components/schemas/url.Userinfo"
+          }
+        }
+      },
+      "url.Userinfo": {
+        "type": "object"
+      },
+      "webhook.Attempt": {
+        "type": "object",
+        "properties": {
+          "Duration": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "Error": {
+            "type": "string"
+          },
+          "StatusCode": {
+            "type": "integer"
+          },
+          "Timestamp": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "webhook.Delivery": {
+        "type": "object",
+        "properties": {
+          "App": {
+            "type": "string"
+          },
+          "Attempts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/webhook.Attempt"
+            }
+          },
+          "Event": {
+            "type": "string"
+          },
+          "ID": {},
+          "NextAttempt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Payload": {
+            "type": "string"
+          },
+          "Status": {
+            "type": "string"
+          },
+          "Timestamp": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Webhook": {
+            "type": "string"
+          }
+        }
+      },
+      "webhook.EventFilter": {
+        "type": "object",
+        "properties": {
+          "Apps": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Events": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "Teams": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "webhook.Webhook": {
+        "type": "object",
+        "properties": {
+          "Description": {
+            "type": "string"
+          },
+          "EventFilter": {
+            "$ref": "#/components/schemas/webhook.EventFilter"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "TeamOwner": {
+            "type": "string"
+          },
+          "URL": {
+            "type": "string"
+          }
+        }
+      }
+    },
+    "securitySchemes": {
+      "bearer": {
+        "type": "apiKey",
+        "in": "header",
+        "name": "Authorization",
+        "description": "The token of the user, in the form \"bearer \u003ctoken\u003e\"."
+      }
+    }
+  }
+}`
@@ -0,0 +1,31 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/api/openapi"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestOpenAPI(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/openapi.json", nil)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var doc openapi.Document
+	err = json.Unmarshal(recorder.Body.Bytes(), &doc)
+	c.Assert(err, check.IsNil)
+	c.Assert(doc.OpenAPI, check.Equals, openapi.Version)
+	c.Assert(doc.Info.Version, check.Equals, Version)
+	c.Assert(doc.Paths["/openapi.json"]["get"].OperationID, check.Equals, "openAPI")
+	c.Assert(doc.Paths["/apps/{appname}/deploy"]["post"].Security, check.NotNil)
+}
@@ -431,7 +431,7 @@ func listPermissions(w http.ResponseWriter, r *http.Request, t auth.Token) error
 // title: add default role
 // path: /role/default
 // method: POST
-// consme: application/x-www-form-urlencoded
+// consume: application/x-www-form-urlencoded
 // responses:
 //   200: Ok
 //   400: Invalid data
@@ -87,10 +87,11 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	isDefault, _ := strconv.ParseBool(r.FormValue("default"))
 	force, _ := strconv.ParseBool(r.FormValue("force"))
 	p := provision.AddPoolOptions{
-		Name:    r.FormValue("name"),
-		Public:  public,
-		Default: isDefault,
-		Force:   force,
+		Name:        r.FormValue("name"),
+		Public:      public,
+		Default:     isDefault,
+		Force:       force,
+		Provisioner: r.FormValue("provisioner"),
 	}
 	err := provision.AddPool(p)
 	if err == provision.ErrDefaultPoolAlreadyExists {
@@ -99,16 +100,23 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error
 			Message: err.Error(),
 		}
 	}
-	if err == provision.ErrPoolNameIsRequired {
+	if err == provision.ErrPoolNameIsRequired || err == provision.ErrPoolProvisionerNotFound {
 		return &terrors.HTTP{
 			Code:    http.StatusBadRequest,
 			Message: err.Error(),
 		}
 	}
-	if err == nil {
-		w.WriteHeader(http.StatusCreated)
+	if err != nil {
+		return err
 	}
-	return err
+	if p.Provisioner != "" {
+		_, err = provision.Initialize(p.Provisioner)
+		if err != nil {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
 }
 
 // title: remove pool
@@ -193,6 +201,7 @@ func removeTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Toke
 // consume: application/x-www-form-urlencoded
 // responses:
 //   200: Pool updated
+//   400: Invalid data
 //   401: Unauthorized
 //   404: Pool not found
 //   409: Default pool already defined
@@ -210,17 +219,30 @@ func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) err
 		public, _ := strconv.ParseBool(v)
 		query["public"] = public
 	}
+	provisioner := r.FormValue("provisioner")
+	if provisioner != "" {
+		query["provisioner"] = provisioner
+	}
 	poolName := r.URL.Query().Get(":name")
 	forceDefault, _ := strconv.ParseBool(r.FormValue("force"))
 	err := provision.PoolUpdate(poolName, query, forceDefault)
 	if err == provision.ErrPoolNotFound {
 		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
 	}
-	if err == provision.ErrDefaultPoolAlreadyExists {
+	if err == provision.ErrDefaultPoolAlreadyExists || err == provision.ErrPoolHasApps {
 		return &terrors.HTTP{
 			Code:    http.StatusConflict,
 			Message: err.Error(),
 		}
 	}
+	if err == provision.ErrPoolProvisionerNotFound {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if provisioner != "" {
+		_, err = provision.Initialize(provisioner)
+	}
 	return err
 }
@@ -11,6 +11,7 @@ import (
 	"net/http/httptest"
 	"strings"
 
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -414,3 +415,74 @@ func (s *S) TestPoolUpdateNotFound(c *check.C) {
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
 }
+
+func (s *S) TestAddPoolWithProvisioner(c *check.C) {
+	b := bytes.NewBufferString("name=pool1&provisioner=fake")
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	defer provision.RemovePool("pool1")
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake")
+}
+
+func (s *S) TestAddPoolWithUnknownProvisioner(c *check.C) {
+	b := bytes.NewBufferString("name=pool1&provisioner=unknown")
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrPoolProvisionerNotFound.Error()+"\n")
+	_, err = provision.GetPoolByName("pool1")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+}
+
+func (s *S) TestPoolUpdateProvisionerHandler(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	b := bytes.NewBufferString("provisioner=fake")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake")
+}
+
+func (s *S) TestPoolUpdateProvisionerHandlerWithApps(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	a := app.App{Name: "myapp", Pool: "pool1", TeamOwner: s.team.Name}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	b := bytes.NewBufferString("provisioner=fake")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrPoolHasApps.Error()+"\n")
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "")
+}
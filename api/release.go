@@ -0,0 +1,116 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: app release list
+// path: /apps/{app}/releases
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func releasesList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, readEnvs, err := releaseApp(r, t)
+	if err != nil {
+		return err
+	}
+	releases, err := app.ListReleases(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(releases) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	for i := range releases {
+		if readEnvs {
+			releases[i].MaskPrivateEnvs()
+		} else {
+			releases[i].Envs = nil
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(releases)
+}
+
+// title: app release diff
+// path: /apps/{app}/releases/diff
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid release version
+//   401: Unauthorized
+//   404: App or release not found
+func releasesDiff(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, readEnvs, err := releaseApp(r, t)
+	if err != nil {
+		return err
+	}
+	from, err := getRelease(a.Name, r.URL.Query().Get("from"))
+	if err != nil {
+		return err
+	}
+	to, err := getRelease(a.Name, r.URL.Query().Get("to"))
+	if err != nil {
+		return err
+	}
+	changes, err := app.DiffReleases(from, to)
+	if err != nil {
+		return err
+	}
+	visible := []app.ReleaseChange{}
+	for _, change := range changes {
+		if readEnvs || change.Field != app.ManifestFieldEnv {
+			visible = append(visible, change)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(visible)
+}
+
+// releaseApp returns the app the releases belong to, checking whether the
+// user may see them and whether the envs in them may be shown as well.
+func releaseApp(r *http.Request, t auth.Token) (*app.App, bool, error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return nil, false, err
+	}
+	appContexts := append(permission.Contexts(permission.CtxTeam, a.Teams),
+		permission.Context(permission.CtxApp, a.Name),
+		permission.Context(permission.CtxPool, a.Pool),
+	)
+	if !permission.Check(t, permission.PermAppReadDeploy, appContexts...) {
+		return nil, false, permission.ErrUnauthorized
+	}
+	return &a, permission.Check(t, permission.PermAppReadEnv, appContexts...), nil
+}
+
+func getRelease(appName, version string) (*app.Release, error) {
+	v, err := strconv.Atoi(version)
+	if err != nil || v <= 0 {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid release version %q", version)}
+	}
+	release, err := app.GetRelease(appName, v)
+	if err == app.ErrReleaseNotFound {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("release %d not found", v)}
+	}
+	return release, err
+}
@@ -0,0 +1,198 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestReleasesList(c *check.C) {
+	a := s.createTestApp(c, nil,
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	err := app.Deploy(app.DeployOptions{App: a, Image: "tsuru/app-myapp:v1", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppReadEnv,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/releases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var releases []app.Release
+	err = json.Unmarshal(recorder.Body.Bytes(), &releases)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 2)
+	c.Assert(releases[0].Version, check.Equals, 2)
+	c.Assert(releases[0].Reason, check.Equals, app.ReleaseEnvSet)
+	c.Assert(releases[0].Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(releases[0].Envs["DEBUG"].Value, check.Equals, "0")
+	c.Assert(releases[0].Envs["PASSWORD"].Value, check.Equals, "*****")
+	c.Assert(releases[1].Version, check.Equals, 1)
+	c.Assert(releases[1].Reason, check.Equals, app.ReleaseDeploy)
+}
+
+func (s *S) TestReleasesListWithoutEnvPermission(c *check.C) {
+	a := s.createTestApp(c, nil,
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	err := app.Deploy(app.DeployOptions{App: a, Image: "tsuru/app-myapp:v1", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/releases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var releases []app.Release
+	err = json.Unmarshal(recorder.Body.Bytes(), &releases)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 2)
+	c.Assert(releases[0].Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(releases[0].Envs, check.IsNil)
+	c.Assert(releases[1].Envs, check.IsNil)
+}
+
+func (s *S) TestReleasesListNoContent(c *check.C) {
+	s.createTestApp(c, nil)
+	request, err := http.NewRequest("GET", "/apps/myapp/releases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestReleasesListForbidden(c *check.C) {
+	a := s.createTestApp(c, nil,
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	err := app.Deploy(app.DeployOptions{App: a, Image: "tsuru/app-myapp:v1", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/releases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestReleasesDiff(c *check.C) {
+	a := s.createTestApp(c, nil,
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	err := app.Deploy(app.DeployOptions{App: a, Image: "tsuru/app-myapp:v1", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/releases/diff?from=1&to=2", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var changes []app.ReleaseChange
+	err = json.Unmarshal(recorder.Body.Bytes(), &changes)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []app.ReleaseChange{
+		{Field: "env", Action: "update", Name: "DEBUG", Old: "1", New: "0"},
+	})
+}
+
+func (s *S) TestReleasesDiffWithoutEnvPermission(c *check.C) {
+	a := s.createTestApp(c, nil,
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	err := app.Deploy(app.DeployOptions{App: a, Image: "tsuru/app-myapp:v1", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/releases/diff?from=1&to=2", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var changes []app.ReleaseChange
+	err = json.Unmarshal(recorder.Body.Bytes(), &changes)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []app.ReleaseChange{})
+}
+
+func (s *S) TestReleasesDiffInvalid(c *check.C) {
+	a := s.createTestApp(c, nil,
+		bind.EnvVar{Name: "DEBUG", Value: "1", Public: true},
+		bind.EnvVar{Name: "PASSWORD", Value: "secret"},
+	)
+	err := app.Deploy(app.DeployOptions{App: a, Image: "tsuru/app-myapp:v1", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		query string
+		code  int
+		msg   string
+	}{
+		{"from=1", http.StatusBadRequest, `invalid release version ""`},
+		{"from=x&to=1", http.StatusBadRequest, `invalid release version "x"`},
+		{"from=1&to=9", http.StatusNotFound, `release 9 not found`},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("GET", "/apps/myapp/releases/diff?"+tt.query, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, tt.code)
+		c.Check(strings.TrimSpace(recorder.Body.String()), check.Equals, tt.msg)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cezarsa/form"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/router"
+)
+
+// title: app router list
+// path: /apps/{app}/routers
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: App not found
+func listAppRouters(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := routerApp(r, t, permission.PermAppRead)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.RoutersWithAddr())
+}
+
+// title: app router add
+// path: /apps/{app}/routers
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Router added
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or router not found
+//   409: Router already attached to the app
+func addAppRouter(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var appRouter router.AppRouter
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&appRouter, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if appRouter.Name == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the router name."}
+	}
+	a, err := routerApp(r, t, permission.PermAppUpdateRouterAdd)
+	if err != nil {
+		return err
+	}
+	if _, err = router.Get(appRouter.Name); err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateRouterAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.AddRouter(appRouter)
+	switch err {
+	case app.ErrRouterAlreadyAttached:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case router.ErrOptsNotSupported, router.ErrInvalidOpts, app.ErrRoutersSwapped:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app router remove
+// path: /apps/{app}/routers/{router}
+// method: DELETE
+// responses:
+//   200: Router removed
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or router not found
+func removeAppRouter(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := routerApp(r, t, permission.PermAppUpdateRouterRemove)
+	if err != nil {
+		return err
+	}
+	routerName := r.URL.Query().Get(":router")
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateRouterRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.RemoveRouter(routerName)
+	switch err {
+	case app.ErrRouterNotAttached:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrLastRouter, app.ErrRoutersSwapped:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+func routerApp(r *http.Request, t auth.Token, scheme *permission.PermissionScheme) (*app.App, error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return nil, err
+	}
+	allowed := permission.Check(t, scheme,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return nil, permission.ErrUnauthorized
+	}
+	return &a, nil
+}
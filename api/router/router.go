@@ -20,6 +20,7 @@ const versionMatcher = "/{version:[0-9.]+}"
 type Route struct {
 	route   *mux.Route
 	version string
+	path    string
 }
 
 func NewRouter() *DelayedRouter {
@@ -44,14 +45,15 @@ func (r *DelayedRouter) registerVars(req *http.Request, vars map[string]string)
 
 func (r *DelayedRouter) addRoute(version, path string, h http.Handler, methods ...string) *mux.Route {
 	muxRoute := r.mux.NewRoute().Handler(h).Methods(methods...)
-	route := &Route{route: muxRoute, version: version}
+	route := &Route{route: muxRoute, version: version, path: path}
 	r.routes[muxRoute] = route
 	versionRegexp := regexp.MustCompile("/(?P<version>[0-9.]+)/")
 	muxRoute.MatcherFunc(func(httpRequest *http.Request, rm *mux.RouteMatch) bool {
 		d := versionRegexp.FindStringSubmatch(httpRequest.URL.Path)
 		return len(d) > 1 && r.routes[muxRoute].version == d[1]
 	}).PathPrefix(versionMatcher).Path(path)
-	r.mux.NewRoute().Path(path).Handler(h).Methods(methods...)
+	unversionedRoute := r.mux.NewRoute().Path(path).Handler(h).Methods(methods...)
+	r.routes[unversionedRoute] = &Route{route: unversionedRoute, path: path}
 	return muxRoute
 }
 
@@ -64,6 +66,19 @@ func (r *DelayedRouter) AddAll(version, path string, h http.Handler) *mux.Route
 	return r.addRoute(version, path, h, "GET", "POST", "PUT", "DELETE")
 }
 
+// RoutePath returns the path template of the route matching the request, or
+// an empty string when no route matches it.
+func (r *DelayedRouter) RoutePath(req *http.Request) string {
+	var match mux.RouteMatch
+	if !r.mux.Match(req, &match) {
+		return ""
+	}
+	if route, ok := r.routes[match.Route]; ok {
+		return route.path
+	}
+	return ""
+}
+
 func (r *DelayedRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var match mux.RouteMatch
 	if !r.mux.Match(req, &match) {
@@ -108,3 +108,25 @@ func (s *S) TestDelayedRouterAddAll(c *check.C) {
 		called = false
 	}
 }
+
+func (s *S) TestDelayedRouterRoutePath(c *check.C) {
+	router := NewRouter()
+	router.Add("1.0", "GET", "/dream/{world}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	router.Add("1.0", "DELETE", "/dream/{world}/doors/{door}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var tests = []struct {
+		method   string
+		url      string
+		expected string
+	}{
+		{"GET", "/dream/tel'aran'rhiod", "/dream/{world}"},
+		{"GET", "/1.0/dream/tel'aran'rhiod", "/dream/{world}"},
+		{"DELETE", "/dream/tel'aran'rhiod/doors/red", "/dream/{world}/doors/{door}"},
+		{"POST", "/dream/tel'aran'rhiod", ""},
+		{"GET", "/nightmare", ""},
+	}
+	for _, t := range tests {
+		request, err := http.NewRequest(t.method, t.url, nil)
+		c.Assert(err, check.IsNil)
+		c.Check(router.RoutePath(request), check.Equals, t.expected, check.Commentf("%s %s", t.method, t.url))
+	}
+}
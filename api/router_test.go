@@ -0,0 +1,161 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestListAppRouters(c *check.C) {
+	a := s.createTestApp(c, bson.M{"plan.router": "fake"})
+	request, err := http.NewRequest("GET", "/apps/myapp/routers", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var routers []router.AppRouter
+	err = json.Unmarshal(recorder.Body.Bytes(), &routers)
+	c.Assert(err, check.IsNil)
+	c.Assert(routers, check.DeepEquals, []router.AppRouter{{Name: "fake", Address: a.Name + ".fakerouter.com"}})
+}
+
+func (s *S) TestAddAppRouter(c *check.C) {
+	a := s.createTestApp(c, bson.M{"plan.router": "fake"})
+	body := url.Values{"Name": {"fake-tls"}, "Opts.visibility": {"internal"}}
+	request, err := http.NewRequest("POST", "/apps/myapp/routers", strings.NewReader(body.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(routertest.TLSRouter.HasBackend(a.Name), check.Equals, true)
+	c.Assert(routertest.TLSRouter.Opts(a.Name), check.DeepEquals, map[string]string{"visibility": "internal"})
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Routers, check.DeepEquals, []router.AppRouter{
+		{Name: "fake"},
+		{Name: "fake-tls", Opts: map[string]string{"visibility": "internal"}},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.router.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Name", "value": "fake-tls"},
+			{"name": "Opts.visibility", "value": "internal"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAddAppRouterAlreadyAttached(c *check.C) {
+	s.createTestApp(c, bson.M{"plan.router": "fake"})
+	body := url.Values{"Name": {"fake"}}
+	request, err := http.NewRequest("POST", "/apps/myapp/routers", strings.NewReader(body.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestAddAppRouterNotFound(c *check.C) {
+	s.createTestApp(c, bson.M{"plan.router": "fake"})
+	body := url.Values{"Name": {"unknown"}}
+	request, err := http.NewRequest("POST", "/apps/myapp/routers", strings.NewReader(body.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAddAppRouterWithoutPermission(c *check.C) {
+	a := s.createTestApp(c, bson.M{"plan.router": "fake"})
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := url.Values{"Name": {"fake-tls"}}
+	request, err := http.NewRequest("POST", "/apps/myapp/routers", strings.NewReader(body.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(routertest.TLSRouter.HasBackend(a.Name), check.Equals, false)
+}
+
+func (s *S) TestRemoveAppRouter(c *check.C) {
+	a := s.createTestApp(c, bson.M{"plan.router": "fake"})
+	err := a.AddRouter(router.AppRouter{Name: "fake-tls"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/myapp/routers/fake-tls", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(routertest.TLSRouter.HasBackend(a.Name), check.Equals, false)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Routers, check.DeepEquals, []router.AppRouter{{Name: "fake"}})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.user.Email,
+		Kind:   "app.update.router.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": ":router", "value": "fake-tls"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestRemoveAppRouterLast(c *check.C) {
+	s.createTestApp(c, bson.M{"plan.router": "fake"})
+	request, err := http.NewRequest("DELETE", "/apps/myapp/routers/fake", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrLastRouter.Error()+"\n")
+}
+
+func (s *S) TestRemoveAppRouterNotAttached(c *check.C) {
+	s.createTestApp(c, bson.M{"plan.router": "fake"})
+	request, err := http.NewRequest("DELETE", "/apps/myapp/routers/fake-tls", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
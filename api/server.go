@@ -25,7 +25,9 @@ import (
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/webhook"
 	"golang.org/x/net/websocket"
+	"gopkg.in/mgo.v2/bson"
 	"gopkg.in/tylerb/graceful.v1"
 )
 
@@ -39,6 +41,26 @@ func getProvisioner() (string, error) {
 	return provisioner, err
 }
 
+// initializePoolProvisioners initializes the provisioners assigned to pools,
+// which may be different from the default provisioner.
+func initializePoolProvisioners() error {
+	pools, err := provision.ListPools(bson.M{"provisioner": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	for _, pool := range pools {
+		if pool.Provisioner == "" {
+			continue
+		}
+		_, err = provision.Initialize(pool.Provisioner)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pool %q uses %q provisioner.\n", pool.Name, pool.Provisioner)
+	}
+	return nil
+}
+
 type TsuruHandler struct {
 	method string
 	path   string
@@ -96,6 +118,7 @@ func RunServer(dry bool) http.Handler {
 		m.Add("1.0", "Get", "/", Handler(index))
 	}
 	m.Add("1.0", "Get", "/info", Handler(info))
+	m.Add("1.0", "Get", "/openapi.json", Handler(openAPI))
 
 	m.Add("1.0", "Get", "/services/instances", AuthorizationRequiredHandler(serviceInstances))
 	m.Add("1.0", "Get", "/services/{service}/instances/{instance}", AuthorizationRequiredHandler(serviceInstance))
@@ -126,6 +149,14 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}", AuthorizationRequiredHandler(appInfo))
 	m.Add("1.0", "Post", "/apps/{app}/cname", AuthorizationRequiredHandler(setCName))
 	m.Add("1.0", "Delete", "/apps/{app}/cname", AuthorizationRequiredHandler(unsetCName))
+	m.Add("1.0", "Get", "/apps/{app}/certificate", AuthorizationRequiredHandler(listCertificates))
+	m.Add("1.0", "Put", "/apps/{app}/certificate", AuthorizationRequiredHandler(setCertificate))
+	m.Add("1.0", "Delete", "/apps/{app}/certificate", AuthorizationRequiredHandler(unsetCertificate))
+	m.Add("1.0", "Get", "/apps/{app}/certificate/acme", AuthorizationRequiredHandler(listACMECertificates))
+	m.Add("1.0", "Get", "/.well-known/acme-challenge/{token}", Handler(acmeChallenge))
+	m.Add("1.0", "Get", "/apps/{app}/routers", AuthorizationRequiredHandler(listAppRouters))
+	m.Add("1.0", "Post", "/apps/{app}/routers", AuthorizationRequiredHandler(addAppRouter))
+	m.Add("1.0", "Delete", "/apps/{app}/routers/{router}", AuthorizationRequiredHandler(removeAppRouter))
 	runHandler := AuthorizationRequiredHandler(runCommand)
 	m.Add("1.0", "Post", "/apps/{app}/run", runHandler)
 	m.Add("1.0", "Post", "/apps/{app}/restart", AuthorizationRequiredHandler(restart))
@@ -138,6 +169,10 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}/env", AuthorizationRequiredHandler(getEnv))
 	m.Add("1.0", "Post", "/apps/{app}/env", AuthorizationRequiredHandler(setEnv))
 	m.Add("1.0", "Delete", "/apps/{app}/env", AuthorizationRequiredHandler(unsetEnv))
+	m.Add("1.0", "Get", "/apps/{app}/manifest", AuthorizationRequiredHandler(exportManifest))
+	m.Add("1.0", "Post", "/apps/{app}/apply", AuthorizationRequiredHandler(applyManifest))
+	m.Add("1.0", "Get", "/apps/{app}/releases", AuthorizationRequiredHandler(releasesList))
+	m.Add("1.0", "Get", "/apps/{app}/releases/diff", AuthorizationRequiredHandler(releasesDiff))
 	m.Add("1.0", "Get", "/apps", AuthorizationRequiredHandler(appList))
 	m.Add("1.0", "Post", "/apps", AuthorizationRequiredHandler(createApp))
 	forceDeleteLockHandler := AuthorizationRequiredHandler(forceDeleteLock)
@@ -154,14 +189,41 @@ func RunServer(dry bool) http.Handler {
 	logPostHandler := AuthorizationRequiredHandler(addLog)
 	m.Add("1.0", "Post", "/apps/{app}/log", logPostHandler)
 	m.Add("1.0", "Post", "/apps/{appname}/deploy/rollback", AuthorizationRequiredHandler(deployRollback))
+	m.Add("1.0", "Post", "/apps/{appname}/promote", AuthorizationRequiredHandler(deployPromote))
+	m.Add("1.0", "Post", "/apps/{appname}/deploy/canary/promote", AuthorizationRequiredHandler(deployCanaryPromote))
+	m.Add("1.0", "Post", "/apps/{appname}/deploy/canary/abort", AuthorizationRequiredHandler(deployCanaryAbort))
 	m.Add("1.0", "Get", "/apps/{app}/metric/envs", AuthorizationRequiredHandler(appMetricEnvs))
+	m.Add("1.0", "Get", "/apps/{app}/routes", AuthorizationRequiredHandler(appRoutesStatus))
 	m.Add("1.0", "Post", "/apps/{app}/routes", AuthorizationRequiredHandler(appRebuildRoutes))
+	m.Add("1.0", "Get", "/routes/drift", AuthorizationRequiredHandler(routesDriftList))
+	m.Add("1.0", "Post", "/apps/{app}/pool", AuthorizationRequiredHandler(appChangePool))
 
 	m.Add("1.0", "Post", "/units/status", AuthorizationRequiredHandler(setUnitsStatus))
 	m.Add("1.0", "Post", "/node/status", AuthorizationRequiredHandler(setNodeStatus))
 
 	m.Add("1.0", "Get", "/deploys", AuthorizationRequiredHandler(deploysList))
 	m.Add("1.0", "Get", "/deploys/{deploy}", AuthorizationRequiredHandler(deployInfo))
+	m.Add("1.0", "Get", "/deploys/{deploy}/log", AuthorizationRequiredHandler(deployLog))
+	m.Add("1.0", "Post", "/deploys/{deploy}/cancel", AuthorizationRequiredHandler(deployCancel))
+
+	m.Add("1.0", "Get", "/events", AuthorizationRequiredHandler(eventList))
+	m.Add("1.0", "Get", "/events/{uuid}", AuthorizationRequiredHandler(eventInfo))
+	m.Add("1.0", "Post", "/events/{uuid}/cancel", AuthorizationRequiredHandler(eventCancel))
+
+	m.Add("1.0", "Get", "/apps/{app}/jobs", AuthorizationRequiredHandler(jobList))
+	m.Add("1.0", "Post", "/apps/{app}/jobs", AuthorizationRequiredHandler(jobCreate))
+	m.Add("1.0", "Get", "/apps/{app}/jobs/{name}", AuthorizationRequiredHandler(jobInfo))
+	m.Add("1.0", "Put", "/apps/{app}/jobs/{name}", AuthorizationRequiredHandler(jobUpdate))
+	m.Add("1.0", "Delete", "/apps/{app}/jobs/{name}", AuthorizationRequiredHandler(jobDelete))
+	m.Add("1.0", "Post", "/apps/{app}/jobs/{name}/run", AuthorizationRequiredHandler(jobRun))
+	m.Add("1.0", "Get", "/apps/{app}/jobs/{name}/runs", AuthorizationRequiredHandler(jobRuns))
+
+	m.Add("1.0", "Get", "/webhooks", AuthorizationRequiredHandler(webhookList))
+	m.Add("1.0", "Post", "/webhooks", AuthorizationRequiredHandler(webhookCreate))
+	m.Add("1.0", "Get", "/webhooks/{name}", AuthorizationRequiredHandler(webhookInfo))
+	m.Add("1.0", "Put", "/webhooks/{name}", AuthorizationRequiredHandler(webhookUpdate))
+	m.Add("1.0", "Delete", "/webhooks/{name}", AuthorizationRequiredHandler(webhookDelete))
+	m.Add("1.0", "Get", "/webhooks/{name}/deliveries", AuthorizationRequiredHandler(webhookDeliveries))
 
 	m.Add("1.0", "Get", "/platforms", AuthorizationRequiredHandler(platformList))
 	m.Add("1.0", "Post", "/platforms", AuthorizationRequiredHandler(platformAdd))
@@ -171,8 +233,14 @@ func RunServer(dry bool) http.Handler {
 	// These handlers don't use {app} on purpose. Using :app means that only
 	// the token generate for the given app is valid, but these handlers
 	// use a token generated for Gandalf.
-	m.Add("1.0", "Post", "/apps/{appname}/repository/clone", AuthorizationRequiredHandler(deploy))
-	m.Add("1.0", "Post", "/apps/{appname}/deploy", AuthorizationRequiredHandler(deploy))
+	deployHandler := AuthorizationRequiredHandler(deploy)
+	m.Add("1.0", "Post", "/apps/{appname}/repository/clone", deployHandler)
+	m.Add("1.0", "Post", "/apps/{appname}/deploy", deployHandler)
+	m.Add("1.0", "Get", "/apps/{appname}/deploy/queue", AuthorizationRequiredHandler(deployQueueList))
+	deployQueueUpdateHandler := AuthorizationRequiredHandler(deployQueueUpdate)
+	m.Add("1.0", "Put", "/apps/{appname}/deploy/queue", deployQueueUpdateHandler)
+	deployQueueRemoveHandler := AuthorizationRequiredHandler(deployQueueRemove)
+	m.Add("1.0", "Delete", "/apps/{appname}/deploy/queue/{id}", deployQueueRemoveHandler)
 	diffDeployHandler := AuthorizationRequiredHandler(diffDeploy)
 	m.Add("1.0", "Post", "/apps/{appname}/diff", diffDeployHandler)
 
@@ -203,6 +271,8 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Post", "/users/api-key", AuthorizationRequiredHandler(regenerateAPIToken))
 
 	m.Add("1.0", "Get", "/logs", websocket.Handler(addLogs))
+	m.Add("1.0", "Get", "/logs/config", AuthorizationRequiredHandler(logServiceConfigList))
+	m.Add("1.0", "Post", "/logs/config", AuthorizationRequiredHandler(logServiceConfigSet))
 
 	m.Add("1.0", "Get", "/teams", AuthorizationRequiredHandler(teamList))
 	m.Add("1.0", "Post", "/teams", AuthorizationRequiredHandler(createTeam))
@@ -255,10 +325,12 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/debug/pprof/threadcreate", AuthorizationRequiredHandler(indexHandler))
 	m.Add("1.0", "Get", "/debug/pprof/block", AuthorizationRequiredHandler(indexHandler))
 
+	m.Add("1.0", "Get", "/metrics", AuthorizationRequiredHandler(metricsHandler))
+
 	n := negroni.New()
 	n.Use(negroni.NewRecovery())
 	if !dry {
-		n.Use(newLoggerMiddleware())
+		n.Use(newLoggerMiddleware(m))
 	}
 	n.UseHandler(m)
 	n.Use(negroni.HandlerFunc(contextClearerMiddleware))
@@ -274,6 +346,10 @@ func RunServer(dry bool) http.Handler {
 		registerUnitHandler,
 		setUnitStatusHandler,
 		diffDeployHandler,
+		deployQueueUpdateHandler,
+		deployQueueRemoveHandler,
+	}, queueableHandlers: []http.Handler{
+		deployHandler,
 	}})
 	n.UseHandler(http.HandlerFunc(runDelayedHandler))
 
@@ -310,16 +386,22 @@ func RunServer(dry bool) http.Handler {
 		if err != nil {
 			fmt.Println("Warning: configuration didn't declare a provisioner, using default provisioner.")
 		}
-		app.Provisioner, err = provision.Get(provisioner)
+		app.Provisioner, err = provision.Initialize(provisioner)
 		if err != nil {
 			fatal(err)
 		}
 		fmt.Printf("Using %q provisioner.\n", provisioner)
-		if initializableProvisioner, ok := app.Provisioner.(provision.InitializableProvisioner); ok {
-			err = initializableProvisioner.Initialize()
-			if err != nil {
-				fatal(err)
-			}
+		err = initializePoolProvisioners()
+		if err != nil {
+			fatal(err)
+		}
+		err = webhook.RegisterQueueTask()
+		if err != nil {
+			fatal(err)
+		}
+		err = app.RegisterDeployQueueTask()
+		if err != nil {
+			fatal(err)
 		}
 		if messageProvisioner, ok := app.Provisioner.(provision.MessageProvisioner); ok {
 			startupMessage, err = messageProvisioner.StartupMessage()
@@ -356,6 +438,21 @@ func RunServer(dry bool) http.Handler {
 		idleTracker := newIdleTracker()
 		shutdown.Register(idleTracker)
 		shutdown.Register(&logTracker)
+		jobScheduler := app.NewJobScheduler()
+		jobScheduler.Start()
+		shutdown.Register(jobScheduler)
+		acmeRenewer := app.NewACMERenewer()
+		acmeRenewer.Start()
+		shutdown.Register(acmeRenewer)
+		routesReconciler := app.NewRoutesReconciler()
+		routesReconciler.Start()
+		shutdown.Register(routesReconciler)
+		deliveryRetrier := webhook.NewDeliveryRetrier()
+		deliveryRetrier.Start()
+		shutdown.Register(deliveryRetrier)
+		deployQueueRetrier := app.NewDeployQueueRetrier()
+		deployQueueRetrier.Start()
+		shutdown.Register(deployQueueRetrier)
 		readTimeout, _ := config.GetInt("server:read-timeout")
 		writeTimeout, _ := config.GetInt("server:write-timeout")
 		srv := &graceful.Server{
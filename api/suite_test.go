@@ -13,6 +13,7 @@ import (
 	"github.com/gorilla/context"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/auth/native"
 	"github.com/tsuru/tsuru/db"
@@ -27,6 +28,7 @@ import (
 	"github.com/tsuru/tsuru/router/routertest"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func Test(t *testing.T) { check.TestingT(t) }
@@ -81,6 +83,25 @@ func (s *S) createUserAndTeam(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+// createTestApp creates the app myapp, owned by the team of the suite. The
+// given fields are then set in the database and the envs set in the app.
+func (s *S) createTestApp(c *check.C, fields bson.M, envs ...bind.EnvVar) *app.App {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	if len(fields) > 0 {
+		err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": fields})
+		c.Assert(err, check.IsNil)
+	}
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	if len(envs) > 0 {
+		err = dbApp.SetEnvs(bind.SetEnvApp{Envs: envs}, nil)
+		c.Assert(err, check.IsNil)
+	}
+	return dbApp
+}
+
 var nativeScheme = auth.ManagedScheme(native.NativeScheme{})
 
 func (s *S) SetUpSuite(c *check.C) {
@@ -93,6 +114,7 @@ func (s *S) SetUpSuite(c *check.C) {
 
 func (s *S) SetUpTest(c *check.C) {
 	routertest.FakeRouter.Reset()
+	routertest.TLSRouter.Reset()
 	repositorytest.Reset()
 	var err error
 	s.conn, err = db.Conn()
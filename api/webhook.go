@@ -0,0 +1,224 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cezarsa/form"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/webhook"
+)
+
+func webhookError(err error) error {
+	switch err {
+	case webhook.ErrWebhookNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case webhook.ErrWebhookAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case webhook.ErrNameRequired, webhook.ErrTeamOwnerRequired, webhook.ErrInvalidURL, webhook.ErrInvalidEvent, webhook.ErrSecretRequired:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+func webhookFromForm(r *http.Request) (*webhook.Webhook, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var w webhook.Webhook
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&w, r.Form)
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return &w, nil
+}
+
+func getReadableWebhook(r *http.Request, t auth.Token) (*webhook.Webhook, error) {
+	w, err := webhook.Find(r.URL.Query().Get(":name"))
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	allowed := permission.Check(t, permission.PermWebhookRead,
+		permission.Context(permission.CtxTeam, w.TeamOwner),
+	)
+	if !allowed {
+		return nil, permission.ErrUnauthorized
+	}
+	return w, nil
+}
+
+// title: webhook list
+// path: /webhooks
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func webhookList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermWebhookRead)
+	if len(contexts) == 0 {
+		return permission.ErrUnauthorized
+	}
+	var teams []string
+	if !hasGlobalContext(contexts) {
+		teams = []string{}
+		for _, c := range contexts {
+			if c.CtxType == permission.CtxTeam {
+				teams = append(teams, c.Value)
+			}
+		}
+	}
+	webhooks, err := webhook.List(teams)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(webhooks)
+}
+
+// title: webhook info
+// path: /webhooks/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func webhookInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := getReadableWebhook(r, t)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(hook)
+}
+
+// title: webhook create
+// path: /webhooks
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Webhook created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Webhook already exists
+func webhookCreate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := webhookFromForm(r)
+	if err != nil {
+		return err
+	}
+	if hook.TeamOwner == "" {
+		return webhookError(webhook.ErrTeamOwnerRequired)
+	}
+	allowed := permission.Check(t, permission.PermWebhookCreate,
+		permission.Context(permission.CtxTeam, hook.TeamOwner),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = webhook.Create(hook)
+	if err != nil {
+		return webhookError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(map[string]string{"secret": hook.Secret})
+}
+
+// title: webhook update
+// path: /webhooks/{name}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Webhook updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func webhookUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := webhookFromForm(r)
+	if err != nil {
+		return err
+	}
+	hook.Name = r.URL.Query().Get(":name")
+	current, err := webhook.Find(hook.Name)
+	if err != nil {
+		return webhookError(err)
+	}
+	if hook.TeamOwner == "" {
+		hook.TeamOwner = current.TeamOwner
+	}
+	for _, team := range []string{current.TeamOwner, hook.TeamOwner} {
+		allowed := permission.Check(t, permission.PermWebhookUpdate,
+			permission.Context(permission.CtxTeam, team),
+		)
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+	}
+	return webhookError(webhook.Update(*hook))
+}
+
+// title: webhook delete
+// path: /webhooks/{name}
+// method: DELETE
+// responses:
+//   200: Webhook removed
+//   401: Unauthorized
+//   404: Not found
+func webhookDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := webhook.Find(r.URL.Query().Get(":name"))
+	if err != nil {
+		return webhookError(err)
+	}
+	allowed := permission.Check(t, permission.PermWebhookDelete,
+		permission.Context(permission.CtxTeam, hook.TeamOwner),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	return webhookError(webhook.Delete(hook.Name))
+}
+
+// title: webhook deliveries
+// path: /webhooks/{name}/deliveries
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: Not found
+func webhookDeliveries(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := getReadableWebhook(r, t)
+	if err != nil {
+		return err
+	}
+	skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	deliveries, err := webhook.ListDeliveries(hook.Name, skip, limit)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(deliveries)
+}
@@ -0,0 +1,297 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/webhook"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestWebhookList(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com", Secret: "x"})
+	c.Assert(err, check.IsNil)
+	err = webhook.Create(&webhook.Webhook{Name: "w2", TeamOwner: "team2", URL: "http://b.com"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermWebhookRead,
+		Context: permission.Context(permission.CtxTeam, "team1"),
+	})
+	request, err := http.NewRequest("GET", "/webhooks", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(recorder.Body.String(), check.Not(check.Matches), `(?s).*"x".*`)
+	var result []webhook.Webhook
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []webhook.Webhook{
+		{Name: "w1", TeamOwner: "team1", URL: "http://a.com"},
+	})
+}
+
+func (s *S) TestWebhookListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/webhooks", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestWebhookInfo(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/webhooks/w1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result webhook.Webhook
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com"})
+}
+
+func (s *S) TestWebhookInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/webhooks/w1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestWebhookInfoWithoutPermission(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermWebhookRead,
+		Context: permission.Context(permission.CtxTeam, "team2"),
+	})
+	request, err := http.NewRequest("GET", "/webhooks/w1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestWebhookCreate(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermWebhookCreate,
+		Context: permission.Context(permission.CtxTeam, "team1"),
+	})
+	body := strings.NewReader("Name=w1&TeamOwner=team1&URL=http://a.com&Secret=s3cr3t&EventFilter.Events.0=app.deploy&EventFilter.Apps.0=myapp")
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(recorder.Body.String(), check.Equals, "{\"secret\":\"s3cr3t\"}\n")
+	hook, err := webhook.Find("w1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*hook, check.DeepEquals, webhook.Webhook{
+		Name:      "w1",
+		TeamOwner: "team1",
+		URL:       "http://a.com",
+		Secret:    "s3cr3t",
+		EventFilter: webhook.EventFilter{
+			Events: []string{webhook.EventDeploy},
+			Apps:   []string{"myapp"},
+		},
+	})
+}
+
+func (s *S) TestWebhookCreateGeneratesSecret(c *check.C) {
+	body := strings.NewReader("Name=w1&TeamOwner=team1&URL=http://a.com")
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var result map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	hook, err := webhook.Find("w1")
+	c.Assert(err, check.IsNil)
+	c.Assert(hook.Secret, check.Not(check.Equals), "")
+	c.Assert(result, check.DeepEquals, map[string]string{"secret": hook.Secret})
+}
+
+func (s *S) TestWebhookCreateInvalid(c *check.C) {
+	body := strings.NewReader("Name=w1&TeamOwner=team1&URL=ftp://a.com")
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, webhook.ErrInvalidURL.Error()+"\n")
+}
+
+func (s *S) TestWebhookCreateAlreadyExists(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("Name=w1&TeamOwner=team1&URL=http://b.com")
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestWebhookCreateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermWebhookCreate,
+		Context: permission.Context(permission.CtxTeam, "team2"),
+	})
+	body := strings.NewReader("Name=w1&TeamOwner=team1&URL=http://a.com")
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = webhook.Find("w1")
+	c.Assert(err, check.Equals, webhook.ErrWebhookNotFound)
+}
+
+func (s *S) TestWebhookUpdate(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com", Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermWebhookUpdate,
+		Context: permission.Context(permission.CtxTeam, "team1"),
+	})
+	body := strings.NewReader("URL=http://b.com&EventFilter.Events.0=app.restart")
+	request, err := http.NewRequest("PUT", "/webhooks/w1", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	hook, err := webhook.Find("w1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*hook, check.DeepEquals, webhook.Webhook{
+		Name:        "w1",
+		TeamOwner:   "team1",
+		URL:         "http://b.com",
+		Secret:      "s3cr3t",
+		EventFilter: webhook.EventFilter{Events: []string{webhook.EventRestart}},
+	})
+}
+
+func (s *S) TestWebhookUpdateChangingTeamWithoutPermission(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermWebhookUpdate,
+		Context: permission.Context(permission.CtxTeam, "team1"),
+	})
+	body := strings.NewReader("URL=http://a.com&TeamOwner=team2")
+	request, err := http.NewRequest("PUT", "/webhooks/w1", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	hook, err := webhook.Find("w1")
+	c.Assert(err, check.IsNil)
+	c.Assert(hook.TeamOwner, check.Equals, "team1")
+}
+
+func (s *S) TestWebhookDelete(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermWebhookDelete,
+		Context: permission.Context(permission.CtxTeam, "team1"),
+	})
+	request, err := http.NewRequest("DELETE", "/webhooks/w1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = webhook.Find("w1")
+	c.Assert(err, check.Equals, webhook.ErrWebhookNotFound)
+}
+
+func (s *S) TestWebhookDeleteNotFound(c *check.C) {
+	request, err := http.NewRequest("DELETE", "/webhooks/w1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestWebhookDeliveries(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "w1", TeamOwner: "team1", URL: "http://a.com"})
+	c.Assert(err, check.IsNil)
+	d := webhook.Delivery{
+		ID:      bson.NewObjectId(),
+		Webhook: "w1",
+		Event:   webhook.EventDeploy,
+		App:     "myapp",
+		Status:  webhook.DeliveryStatusFailed,
+		Attempts: []webhook.Attempt{
+			{StatusCode: http.StatusInternalServerError, Error: "unexpected status code: 500"},
+		},
+	}
+	err = s.conn.WebhookDeliveries().Insert(d)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/webhooks/w1/deliveries", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []webhook.Delivery
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].ID, check.Equals, d.ID)
+	c.Assert(result[0].Status, check.Equals, webhook.DeliveryStatusFailed)
+	c.Assert(result[0].Attempts, check.HasLen, 1)
+	c.Assert(result[0].Attempts[0].Error, check.Equals, "unexpected status code: 500")
+}
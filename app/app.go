@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/action"
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
@@ -26,7 +27,9 @@ import (
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/secret"
 	"github.com/tsuru/tsuru/service"
+	"github.com/tsuru/tsuru/webhook"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -41,6 +44,13 @@ var (
 	ErrNoAccess          = stderr.New("team does not have access to this app")
 	ErrCannotOrphanApp   = stderr.New("cannot revoke access from this team, as it's the unique team with access to the app")
 	ErrDisabledPlatform  = stderr.New("Disabled Platform, only admin users can create applications with the platform")
+
+	ErrSwapDifferentProvisioners = stderr.New("cannot swap apps running on different provisioners")
+	ErrSwapDifferentRouters      = stderr.New("cannot swap apps attached to different routers")
+	ErrPoolProvisionerChange     = stderr.New("the new pool uses a different provisioner, the app must be moved with the pool change admin operation")
+
+	ErrIsolatedRunNotSupported = stderr.New("the provisioner of the app doesn't support isolated runs")
+	ErrIsolatedRunNotDeployed  = stderr.New("the app must be deployed before running isolated commands")
 )
 
 const (
@@ -51,6 +61,8 @@ const (
 
 	TsuruServicesEnvVar = "TSURU_SERVICES"
 	defaultAppDir       = "/home/application/current"
+
+	defaultIsolatedRunTimeout = time.Hour
 )
 
 // AppLock stores information about a lock hold on the app
@@ -120,13 +132,22 @@ type App struct {
 	Plan           Plan
 	Pool           string
 	Description    string
+	Canary         *Canary `bson:",omitempty"`
+	EnvKey         *EnvKey `bson:",omitempty"`
+	DeployQueue    bool
+	Certificates   []Certificate      `bson:",omitempty"`
+	Routers        []router.AppRouter `bson:",omitempty"`
 
 	quota.Quota
 }
 
 // Units returns the list of units.
 func (app *App) Units() ([]provision.Unit, error) {
-	return Provisioner.Units(app)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	return prov.Units(app)
 }
 
 // MarshalJSON marshals the app in json format.
@@ -151,6 +172,18 @@ func (app *App) MarshalJSON() ([]byte, error) {
 	result["teamowner"] = app.TeamOwner
 	result["plan"] = app.Plan
 	result["lock"] = app.Lock
+	if app.Canary != nil {
+		result["canary"] = app.Canary
+	}
+	if app.DeployQueue {
+		result["deployQueue"] = app.DeployQueue
+	}
+	if len(app.Certificates) > 0 {
+		result["certificates"] = app.CertificateExpirations()
+	}
+	if len(app.Routers) > 0 {
+		result["routers"] = app.RoutersWithAddr()
+	}
 	return json.Marshal(&result)
 }
 
@@ -291,11 +324,22 @@ func (app *App) Update(updateData App, w io.Writer) error {
 		app.Description = description
 	}
 	if poolName != "" {
+		oldProv, err := app.GetProvisioner()
+		if err != nil {
+			return err
+		}
 		app.Pool = poolName
-		_, err := app.GetPoolForApp(app.Pool)
+		_, err = app.GetPoolForApp(app.Pool)
 		if err != nil {
 			return err
 		}
+		newProv, err := app.GetProvisioner()
+		if err != nil {
+			return err
+		}
+		if oldProv != newProv {
+			return ErrPoolProvisionerChange
+		}
 	}
 	conn, err := db.Conn()
 	if err != nil {
@@ -390,7 +434,10 @@ func Delete(app *App, w io.Writer) error {
 		log.Errorf("[delete-app: %s] %s", appName, msg)
 		hasErrors = true
 	}
-	err = Provisioner.Destroy(app)
+	prov, err := app.GetProvisioner()
+	if err == nil {
+		err = prov.Destroy(app)
+	}
 	if err != nil {
 		logErr("Unable to destroy app in provisioner", err)
 	}
@@ -402,8 +449,10 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to remove app from repository manager", err)
 	}
-	token := app.Env["TSURU_APP_TOKEN"].Value
-	err = AuthScheme.AppLogout(token)
+	token, err := app.decryptedEnvValue("TSURU_APP_TOKEN")
+	if err == nil {
+		err = AuthScheme.AppLogout(token)
+	}
 	if err != nil {
 		logErr("Unable to remove app token in destroy", err)
 	}
@@ -414,13 +463,12 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to release app quota", err)
 	}
-	logConn, err := db.LogConn()
+	logService, err := app.logService()
 	if err == nil {
-		defer logConn.Close()
-		err = logConn.Logs(appName).DropCollection()
+		err = logService.Remove(appName)
 	}
 	if err != nil {
-		logErr("Unable to remove logs collection", err)
+		logErr("Unable to remove logs", err)
 	}
 	conn, err := db.Conn()
 	if err == nil {
@@ -434,6 +482,27 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to mark old deploys as removed", err)
 	}
+	err = removeReleases(appName)
+	if err != nil {
+		logErr("Unable to remove app releases", err)
+	}
+	err = removeQueuedDeploys(appName)
+	if err != nil {
+		logErr("Unable to remove app queued deploys", err)
+	}
+	err = removeJobs(appName)
+	if err != nil {
+		logErr("Unable to remove app jobs", err)
+	}
+	err = removeACMECertificates(appName)
+	if err != nil {
+		logErr("Unable to remove app ACME certificates", err)
+	}
+	err = removeRoutesStatus(appName)
+	if err != nil {
+		logErr("Unable to remove app routes status", err)
+	}
+	app.notifyWebhooks(webhook.EventDelete, "", nil, nil)
 	return nil
 }
 
@@ -490,6 +559,10 @@ func (app *App) AddUnits(n uint, process string, writer io.Writer) error {
 		&reserveUnitsToAdd,
 		&provisionAddUnits,
 	).Execute(app, n, writer, process)
+	app.notifyWebhooks(webhook.EventAddUnits, "", err, map[string]interface{}{
+		"units":   n,
+		"process": process,
+	})
 	return err
 }
 
@@ -499,7 +572,15 @@ func (app *App) AddUnits(n uint, process string, writer io.Writer) error {
 //     1. Remove units from the provisioner
 //     2. Update quota
 func (app *App) RemoveUnits(n uint, process string, writer io.Writer) error {
-	err := Provisioner.RemoveUnits(app, n, process, writer)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.RemoveUnits(app, n, process, writer)
+	app.notifyWebhooks(webhook.EventRemoveUnits, "", err, map[string]interface{}{
+		"units":   n,
+		"process": process,
+	})
 	if err != nil {
 		return err
 	}
@@ -524,13 +605,17 @@ func (app *App) RemoveUnits(n uint, process string, writer io.Writer) error {
 
 // SetUnitStatus changes the status of the given unit.
 func (app *App) SetUnitStatus(unitName string, status provision.Status) error {
-	units, err := app.Units()
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	units, err := prov.Units(app)
 	if err != nil {
 		return err
 	}
 	for _, unit := range units {
 		if strings.HasPrefix(unit.ID, unitName) {
-			return Provisioner.SetUnitStatus(unit, status)
+			return prov.SetUnitStatus(unit, status)
 		}
 	}
 	return &provision.UnitNotFoundError{ID: unitName}
@@ -812,18 +897,75 @@ func (app *App) Run(cmd string, w io.Writer, once bool) error {
 	return app.sourced(cmd, io.MultiWriter(w, &logWriter), once)
 }
 
+// RunIsolated runs the command in a temporary unit created from the current
+// image of the app, instead of using one of the units serving the app. The
+// unit is removed when the command finishes or when the timeout is reached. A
+// zero timeout means the value of the "run:isolated-timeout" setting.
+func (app *App) RunIsolated(cmd string, w io.Writer, timeout time.Duration) error {
+	if app.Deploys == 0 {
+		return ErrIsolatedRunNotDeployed
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	executor, ok := prov.(provision.IsolatedExecutor)
+	if !ok {
+		return ErrIsolatedRunNotSupported
+	}
+	if timeout <= 0 {
+		timeout = isolatedRunTimeout()
+	}
+	app.Log(fmt.Sprintf("running '%s' in an isolated unit", cmd), "tsuru", "api")
+	logWriter := LogWriter{App: app, Source: "app-run"}
+	logWriter.Async()
+	defer logWriter.Close()
+	out := io.MultiWriter(w, &logWriter)
+	code, err := executor.ExecuteCommandIsolated(provision.IsolatedExecOptions{
+		App:     app,
+		Cmd:     sourcedCommand(cmd),
+		Stdout:  out,
+		Stderr:  out,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("command finished with exit code %d", code)
+	}
+	return nil
+}
+
+func isolatedRunTimeout() time.Duration {
+	timeout, err := config.GetInt("run:isolated-timeout")
+	if err != nil || timeout <= 0 {
+		return defaultIsolatedRunTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
 func (app *App) sourced(cmd string, w io.Writer, once bool) error {
+	return app.run(sourcedCommand(cmd), w, once)
+}
+
+// sourcedCommand wraps the command so it runs in the app directory, after
+// sourcing apprc.
+func sourcedCommand(cmd string) string {
 	source := "[ -f /home/application/apprc ] && source /home/application/apprc"
 	cd := fmt.Sprintf("[ -d %s ] && cd %s", defaultAppDir, defaultAppDir)
-	cmd = fmt.Sprintf("%s; %s; %s", source, cd, cmd)
-	return app.run(cmd, w, once)
+	return fmt.Sprintf("%s; %s; %s", source, cd, cmd)
 }
 
 func (app *App) run(cmd string, w io.Writer, once bool) error {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
 	if once {
-		return Provisioner.ExecuteCommandOnce(w, w, app, cmd)
+		return prov.ExecuteCommandOnce(w, w, app, cmd)
 	}
-	return Provisioner.ExecuteCommand(w, w, app, cmd)
+	return prov.ExecuteCommand(w, w, app, cmd)
 }
 
 // Restart runs the restart hook for the app, writing its output to w.
@@ -837,7 +979,14 @@ func (app *App) Restart(process string, w io.Writer) error {
 		log.Errorf("[restart] error on write app log for the app %s - %s", app.Name, err)
 		return err
 	}
-	err = Provisioner.Restart(app, process, w)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.Restart(app, process, w)
+	app.notifyWebhooks(webhook.EventRestart, "", err, map[string]interface{}{
+		"process": process,
+	})
 	if err != nil {
 		log.Errorf("[restart] error on restart the app %s - %s", app.Name, err)
 		return err
@@ -851,7 +1000,11 @@ func (app *App) Stop(w io.Writer, process string) error {
 		msg = fmt.Sprintf("\n ---> Stopping the app %q\n", app.Name)
 	}
 	log.Write(w, []byte(msg))
-	err := Provisioner.Stop(app, process)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.Stop(app, process)
 	if err != nil {
 		log.Errorf("[stop] error on stop the app %s - %s", app.Name, err)
 		return err
@@ -865,12 +1018,7 @@ func (app *App) Sleep(w io.Writer, process string, proxyURL *url.URL) error {
 		msg = fmt.Sprintf("\n ---> Putting the app %q to sleep\n", app.Name)
 	}
 	log.Write(w, []byte(msg))
-	routerName, err := app.GetRouter()
-	if err != nil {
-		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
-		return err
-	}
-	r, err := router.Get(routerName)
+	r, err := app.allRouters()
 	if err != nil {
 		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
 		return err
@@ -888,7 +1036,11 @@ func (app *App) Sleep(w io.Writer, process string, proxyURL *url.URL) error {
 		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
 		return err
 	}
-	err = Provisioner.Sleep(app, process)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.Sleep(app, process)
 	if err != nil {
 		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
 		for _, route := range oldRoutes {
@@ -920,6 +1072,31 @@ func (app *App) GetName() string {
 }
 
 // GetPool returns the pool of the app.
+// GetProvisioner returns the provisioner used by the app, which is the
+// provisioner of its pool.
+func (app *App) GetProvisioner() (provision.Provisioner, error) {
+	return provisionerForPool(app.Pool)
+}
+
+// provisionerForPool returns the provisioner used by apps in the given pool.
+// Apps in pools without a provisioner use the default Provisioner.
+func provisionerForPool(poolName string) (provision.Provisioner, error) {
+	if poolName == "" {
+		return Provisioner, nil
+	}
+	pool, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return Provisioner, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pool.Provisioner == "" {
+		return Provisioner, nil
+	}
+	return provision.Get(pool.Provisioner)
+}
+
 func (app *App) GetPool() string {
 	return app.Pool
 }
@@ -1029,6 +1206,11 @@ func (app *App) SetEnvs(setEnvs bind.SetEnvApp, w io.Writer) error {
 // overridden (if set to false, setEnvsToApp may override a private variable).
 //
 // shouldRestart defines if the server should be restarted after saving vars.
+//
+// Values referencing an external secret (secret://<team>/<path>#<key>) must
+// belong to the team owner of the app and be resolvable by the configured
+// secret provider, but are stored as references and only resolved when the
+// units of the app are created.
 func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 	if len(setEnvs.Envs) == 0 {
 		return nil
@@ -1037,6 +1219,21 @@ func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 		fmt.Fprintf(w, "---- Setting %d new environment variables ----\n", len(setEnvs.Envs))
 	}
 	for _, env := range setEnvs.Envs {
+		if !secret.IsReference(env.Value) {
+			continue
+		}
+		_, err := secret.Resolve(env.Value, app.TeamOwner)
+		if err != nil {
+			return &errors.ValidationError{Message: fmt.Sprintf("invalid value for env %q: %s", env.Name, err)}
+		}
+	}
+	envs := make([]bind.EnvVar, len(setEnvs.Envs))
+	copy(envs, setEnvs.Envs)
+	err := app.encryptEnvs(envs)
+	if err != nil {
+		return err
+	}
+	for _, env := range envs {
 		set := true
 		if setEnvs.PublicOnly {
 			e, err := app.getEnv(env.Name)
@@ -1053,14 +1250,19 @@ func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 		return err
 	}
 	defer conn.Close()
-	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": app.Env}})
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": app.Env, "envkey": app.EnvKey}})
 	if err != nil {
 		return err
 	}
+	app.createEnvRelease(ReleaseEnvSet)
 	if !setEnvs.ShouldRestart {
 		return nil
 	}
-	return Provisioner.Restart(app, "", w)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	return prov.Restart(app, "", w)
 }
 
 // UnsetEnvs removes environment variables from an app, serializing the
@@ -1107,10 +1309,15 @@ func (app *App) unsetEnvsToApp(unsetEnvs bind.UnsetEnvApp, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	app.createEnvRelease(ReleaseEnvUnset)
 	if !unsetEnvs.ShouldRestart {
 		return nil
 	}
-	return Provisioner.Restart(app, "", w)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	return prov.Restart(app, "", w)
 }
 
 type rollbackFunc func(provision.App, string) error
@@ -1143,6 +1350,7 @@ func (app *App) AddCName(cnames ...string) error {
 	if err != nil {
 		return err
 	}
+	app.requestACMECertificates(cnames)
 	return nil
 }
 
@@ -1157,22 +1365,31 @@ func (app *App) RemoveCName(cnames ...string) error {
 	if err != nil {
 		return err
 	}
+	app.removeCNameCertificates(cnames)
+	app.cancelACMECertificates(cnames)
 	return nil
 }
 
-func (app *App) parsedTsuruServices() map[string][]bind.ServiceInstance {
+func (app *App) parsedTsuruServices() (map[string][]bind.ServiceInstance, error) {
 	var tsuruServices map[string][]bind.ServiceInstance
-	if servicesEnv, ok := app.Env[TsuruServicesEnvVar]; ok {
-		json.Unmarshal([]byte(servicesEnv.Value), &tsuruServices)
+	if _, ok := app.Env[TsuruServicesEnvVar]; ok {
+		value, err := app.decryptedEnvValue(TsuruServicesEnvVar)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(value), &tsuruServices)
 	} else {
 		tsuruServices = make(map[string][]bind.ServiceInstance)
 	}
-	return tsuruServices
+	return tsuruServices, nil
 }
 
 //func (app *App) AddInstance(serviceName string, instance bind.ServiceInstance, shouldRestart bool, writer io.Writer) error {
 func (app *App) AddInstance(instanceApp bind.InstanceApp, writer io.Writer) error {
-	tsuruServices := app.parsedTsuruServices()
+	tsuruServices, err := app.parsedTsuruServices()
+	if err != nil {
+		return err
+	}
 	serviceInstances := appendOrUpdateServiceInstance(tsuruServices[instanceApp.ServiceName], instanceApp.Instance)
 	tsuruServices[instanceApp.ServiceName] = serviceInstances
 	servicesJson, err := json.Marshal(tsuruServices)
@@ -1232,7 +1449,10 @@ func findServiceEnv(tsuruServices map[string][]bind.ServiceInstance, name string
 
 //func (app *App) RemoveInstance(serviceName string, instance bind.ServiceInstance, shouldRestart bool, writer io.Writer) error {
 func (app *App) RemoveInstance(instanceApp bind.InstanceApp, writer io.Writer) error {
-	tsuruServices := app.parsedTsuruServices()
+	tsuruServices, err := app.parsedTsuruServices()
+	if err != nil {
+		return err
+	}
 	toUnsetEnvs := make([]string, 0, len(instanceApp.Instance.Envs))
 	for varName := range instanceApp.Instance.Envs {
 		toUnsetEnvs = append(toUnsetEnvs, varName)
@@ -1246,7 +1466,6 @@ func (app *App) RemoveInstance(instanceApp bind.InstanceApp, writer io.Writer) e
 		}
 	}
 	var servicesJson []byte
-	var err error
 	if index >= 0 {
 		for i := index; i < len(serviceInstances)-1; i++ {
 			serviceInstances[i] = serviceInstances[i+1]
@@ -1308,7 +1527,7 @@ func (app *App) RemoveInstance(instanceApp bind.InstanceApp, writer io.Writer) e
 // user can filter where the message come from.
 func (app *App) Log(message, source, unit string) error {
 	messages := strings.Split(message, "\n")
-	logs := make([]interface{}, 0, len(messages))
+	logs := make([]Applog, 0, len(messages))
 	for _, msg := range messages {
 		if msg != "" {
 			l := Applog{
@@ -1322,13 +1541,16 @@ func (app *App) Log(message, source, unit string) error {
 		}
 	}
 	if len(logs) > 0 {
-		notify(app.Name, logs)
-		conn, err := db.LogConn()
+		notifyMessages := make([]interface{}, len(logs))
+		for i := range logs {
+			notifyMessages[i] = logs[i]
+		}
+		notify(app.Name, notifyMessages)
+		service, err := app.logService()
 		if err != nil {
 			return err
 		}
-		defer conn.Close()
-		return conn.Logs(app.Name).Insert(logs...)
+		return service.Add(app.Name, logs)
 	}
 	return nil
 }
@@ -1336,7 +1558,11 @@ func (app *App) Log(message, source, unit string) error {
 // LastLogs returns a list of the last `lines` log of the app, matching the
 // fields in the log instance received as an example.
 func (app *App) LastLogs(lines int, filterLog Applog) ([]Applog, error) {
-	logsProvisioner, ok := Provisioner.(provision.OptionalLogsProvisioner)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	logsProvisioner, ok := prov.(provision.OptionalLogsProvisioner)
 	if ok {
 		enabled, doc, err := logsProvisioner.LogsEnabled(app)
 		if err != nil {
@@ -1346,28 +1572,11 @@ func (app *App) LastLogs(lines int, filterLog Applog) ([]Applog, error) {
 			return nil, stderr.New(doc)
 		}
 	}
-	conn, err := db.LogConn()
+	service, err := app.logService()
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-	logs := []Applog{}
-	q := bson.M{}
-	if filterLog.Source != "" {
-		q["source"] = filterLog.Source
-	}
-	if filterLog.Unit != "" {
-		q["unit"] = filterLog.Unit
-	}
-	err = conn.Logs(app.Name).Find(q).Sort("-$natural").Limit(lines).All(&logs)
-	if err != nil {
-		return nil, err
-	}
-	l := len(logs)
-	for i := 0; i < l/2; i++ {
-		logs[i], logs[l-1-i] = logs[l-1-i], logs[i]
-	}
-	return logs, nil
+	return service.List(app.Name, lines, filterLog)
 }
 
 type Filter struct {
@@ -1444,27 +1653,71 @@ func List(filter *Filter) ([]App, error) {
 		return apps, err
 	}
 	if filter != nil && len(filter.Statuses) > 0 {
-		provisionApps := make([]provision.App, len(apps))
-		for i := range apps {
-			provisionApps[i] = &apps[i]
-		}
-		provisionApps, err = Provisioner.FilterAppsByUnitStatus(provisionApps, filter.Statuses)
+		apps, err = filterAppsByUnitStatus(apps, filter.Statuses)
 		if err != nil {
 			return []App{}, err
 		}
-		for i := range provisionApps {
-			apps[i] = *(provisionApps[i].(*App))
-		}
-		apps = apps[:len(provisionApps)]
 	}
 	return apps, nil
 }
 
+// filterAppsByUnitStatus asks the provisioner of each app which apps have
+// units in one of the given statuses, keeping the order of the apps.
+func filterAppsByUnitStatus(apps []App, statuses []string) ([]App, error) {
+	var provisioners []provision.Provisioner
+	appsByProvisioner := make(map[provision.Provisioner][]provision.App)
+	for i := range apps {
+		prov, err := apps[i].GetProvisioner()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := appsByProvisioner[prov]; !ok {
+			provisioners = append(provisioners, prov)
+		}
+		appsByProvisioner[prov] = append(appsByProvisioner[prov], &apps[i])
+	}
+	found := make(map[string]bool)
+	for _, prov := range provisioners {
+		filtered, err := prov.FilterAppsByUnitStatus(appsByProvisioner[prov], statuses)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range filtered {
+			found[a.GetName()] = true
+		}
+	}
+	result := make([]App, 0, len(found))
+	for _, a := range apps {
+		if found[a.Name] {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
 // Swap calls the Provisioner.Swap.
 // And updates the app.CName in the database.
 func Swap(app1, app2 *App, cnameOnly bool) error {
+	prov, err := app1.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	prov2, err := app2.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	if prov != prov2 {
+		return ErrSwapDifferentProvisioners
+	}
+	sameRouters, err := haveSameRouters(app1, app2)
+	if err != nil {
+		return err
+	}
+	if !sameRouters {
+		return ErrSwapDifferentRouters
+	}
 	if !cnameOnly {
-		err := Provisioner.Swap(app1, app2)
+		err = prov.Swap(app1, app2)
 		if err != nil {
 			return err
 		}
@@ -1476,7 +1729,7 @@ func Swap(app1, app2 *App, cnameOnly bool) error {
 	defer conn.Close()
 	app1.CName, app2.CName = app2.CName, app1.CName
 	updateCName := func(app *App) error {
-		app.Ip, err = Provisioner.Addr(app)
+		app.Ip, err = prov.Addr(app)
 		if err != nil {
 			return err
 		}
@@ -1500,7 +1753,11 @@ func (app *App) Start(w io.Writer, process string) error {
 		msg = fmt.Sprintf("\n ---> Starting the app %q\n", app.Name)
 	}
 	log.Write(w, []byte(msg))
-	err := Provisioner.Start(app, process)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.Start(app, process)
 	if err != nil {
 		log.Errorf("[start] error on start the app %s - %s", app.Name, err)
 		return err
@@ -1529,29 +1786,48 @@ func (app *App) GetUpdatePlatform() bool {
 }
 
 func (app *App) RegisterUnit(unitId string, customData map[string]interface{}) error {
-	units, err := app.Units()
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	units, err := prov.Units(app)
 	if err != nil {
 		return err
 	}
 	for _, unit := range units {
 		if strings.HasPrefix(unit.ID, unitId) {
-			return Provisioner.RegisterUnit(unit, customData)
+			return prov.RegisterUnit(unit, customData)
 		}
 	}
 	return &provision.UnitNotFoundError{ID: unitId}
 }
 
+// GetRouter returns the name of the main router of the app, the first of its
+// routers. The address, canary weights and certificates of the app are
+// managed in this router.
 func (app *App) GetRouter() (string, error) {
+	if len(app.Routers) > 0 {
+		return app.Routers[0].Name, nil
+	}
 	return app.Plan.getRouter()
 }
 
 func (app *App) MetricEnvs() map[string]string {
-	return Provisioner.MetricEnvs(app)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		log.Errorf("[metric-envs] unable to get provisioner for app %s: %s", app.Name, err)
+		return map[string]string{}
+	}
+	return prov.MetricEnvs(app)
 }
 
 func (app *App) Shell(opts provision.ShellOptions) error {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
 	opts.App = app
-	return Provisioner.Shell(opts)
+	return prov.Shell(opts)
 }
 
 type ProcfileError struct {
@@ -1567,73 +1843,87 @@ type RebuildRoutesResult struct {
 	Removed []string
 }
 
+// RebuildRoutes makes the routes of the app in each of its routers match its
+// routable units, also recreating its backend and cnames when missing.
 func (app *App) RebuildRoutes() (*RebuildRoutesResult, error) {
-	routerName, err := app.GetRouter()
+	routers, err := app.GetRouters()
 	if err != nil {
 		return nil, err
 	}
-	r, err := router.Get(routerName)
+	var result RebuildRoutesResult
+	for i, appRouter := range routers {
+		err = app.rebuildRouterRoutes(appRouter, i == 0, &result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if app.Canary != nil {
+		err = app.applyCanaryWeights()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &result, nil
+}
+
+// rebuildRouterRoutes rebuilds the routes of the app in one of its routers,
+// adding the changes to result. The address of the app is updated only when
+// it's the main router.
+func (app *App) rebuildRouterRoutes(appRouter router.AppRouter, main bool, result *RebuildRoutesResult) error {
+	r, err := router.Get(appRouter.Name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	err = r.AddBackend(app.Name)
+	err = router.AddBackendOpts(r, app.Name, appRouter.Opts)
 	if err != nil && err != router.ErrBackendExists {
-		return nil, err
+		return err
 	}
 	var newAddr string
-	if newAddr, err = r.Addr(app.GetName()); err == nil && newAddr != app.Ip {
+	if newAddr, err = r.Addr(app.GetName()); main && err == nil && newAddr != app.Ip {
 		var conn *db.Storage
 		conn, err = db.Conn()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		defer conn.Close()
 		err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"ip": newAddr}})
 		if err != nil {
-			return nil, err
+			return err
 		}
 		app.Ip = newAddr
 	}
 	for _, cname := range app.CName {
 		err = r.SetCName(cname, app.Name)
 		if err != nil && err != router.ErrCNameExists {
-			return nil, err
+			return err
 		}
 	}
 	oldRoutes, err := r.Routes(app.GetName())
 	if err != nil {
-		return nil, err
+		return err
 	}
-	expectedMap := make(map[string]*url.URL)
-	units, err := Provisioner.RoutableUnits(app)
+	prov, err := app.GetProvisioner()
 	if err != nil {
-		return nil, err
-	}
-	for _, unit := range units {
-		expectedMap[unit.Address.String()] = unit.Address
+		return err
 	}
-	var toRemove []*url.URL
-	for _, url := range oldRoutes {
-		if _, isPresent := expectedMap[url.String()]; isPresent {
-			delete(expectedMap, url.String())
-		} else {
-			toRemove = append(toRemove, url)
-		}
+	units, err := prov.RoutableUnits(app)
+	if err != nil {
+		return err
 	}
-	var result RebuildRoutesResult
-	for _, toAddUrl := range expectedMap {
+	toAdd, toRemove := routesDiff(oldRoutes, units)
+	for _, toAddUrl := range toAdd {
 		err := r.AddRoute(app.GetName(), toAddUrl)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		result.Added = append(result.Added, toAddUrl.String())
+		result.Added = appendUnique(result.Added, toAddUrl.String())
 	}
 	for _, toRemoveUrl := range toRemove {
 		err := r.RemoveRoute(app.GetName(), toRemoveUrl)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		result.Removed = append(result.Removed, toRemoveUrl.String())
+		result.Removed = appendUnique(result.Removed, toRemoveUrl.String())
 	}
-	return &result, nil
+	return nil
 }
@@ -9,9 +9,12 @@ import (
 	"encoding/json"
 	stderr "errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"sync"
@@ -1142,6 +1145,72 @@ func (s *S) TestSetEnvsWhenAppHaveNoUnits(c *check.C) {
 	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
 }
 
+func (s *S) TestSetEnvsWithSecretReference(c *check.C) {
+	dir, err := ioutil.TempDir("", "secrets")
+	c.Assert(err, check.IsNil)
+	defer os.RemoveAll(dir)
+	err = os.MkdirAll(filepath.Join(dir, s.team.Name, "db"), 0700)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, s.team.Name, "db", "password"), []byte("s3cr3t"), 0600)
+	c.Assert(err, check.IsNil)
+	config.Set("secrets:provider", "file")
+	config.Set("secrets:file:base-dir", dir)
+	defer config.Unset("secrets")
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "secret://tsuruteam/db#password"}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.Env["DATABASE_PASSWORD"], check.DeepEquals, bind.EnvVar{
+		Name:  "DATABASE_PASSWORD",
+		Value: "secret://tsuruteam/db#password",
+	})
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_HOST", Value: "localhost"},
+			{Name: "DATABASE_USER", Value: "secret://tsuruteam/db#user"},
+		},
+	}, nil)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `invalid value for env "DATABASE_USER": unable to resolve secret://tsuruteam/db#user: secret not found`)
+	newApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.Env, check.HasLen, 1)
+}
+
+func (s *S) TestSetEnvsWithSecretReferenceNoProvider(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "secret://tsuruteam/db#password"}},
+	}, nil)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `invalid value for env "DATABASE_PASSWORD": secrets:provider is not configured`)
+}
+
+func (s *S) TestSetEnvsWithSecretReferenceOfAnotherTeam(c *check.C) {
+	dir, err := ioutil.TempDir("", "secrets")
+	c.Assert(err, check.IsNil)
+	defer os.RemoveAll(dir)
+	err = os.MkdirAll(filepath.Join(dir, "otherteam", "db"), 0700)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "otherteam", "db", "password"), []byte("s3cr3t"), 0600)
+	c.Assert(err, check.IsNil)
+	config.Set("secrets:provider", "file")
+	config.Set("secrets:file:base-dir", dir)
+	defer config.Unset("secrets")
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "secret://otherteam/db#password"}},
+	}, nil)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `invalid value for env "DATABASE_PASSWORD": unable to resolve secret://otherteam/db#password: secret belongs to another team`)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.Env, check.HasLen, 0)
+}
+
 func (s *S) TestUnsetEnvRespectsThePublicOnlyFlagKeepPrivateVariablesWhenItsTrue(c *check.C) {
 	a := App{
 		Name: "myapp",
@@ -1887,7 +1956,9 @@ func (s *S) TestAddInstanceMultipleServices(c *check.C) {
 	}
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	c.Assert(a.parsedTsuruServices(), check.DeepEquals, expected)
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, expected)
 	delete(a.Env, TsuruServicesEnvVar)
 	c.Assert(a.Env, check.DeepEquals, map[string]bind.EnvVar{
 		"DATABASE_NAME": {
@@ -1943,7 +2014,9 @@ func (s *S) TestAddInstanceAndRemoveInstanceMultipleServices(c *check.C) {
 	}
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	c.Assert(a.parsedTsuruServices(), check.DeepEquals, expected)
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, expected)
 	delete(a.Env, TsuruServicesEnvVar)
 	c.Assert(a.Env, check.DeepEquals, map[string]bind.EnvVar{
 		"DATABASE_NAME": {
@@ -2073,7 +2146,8 @@ func (s *S) TestRemoveInstanceNotFound(c *check.C) {
 	c.Assert(err, check.IsNil)
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	services := a.parsedTsuruServices()
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
 	c.Assert(services, check.DeepEquals, map[string][]bind.ServiceInstance{
 		"mysql": {
 			{
@@ -2110,7 +2184,8 @@ func (s *S) TestRemoveInstanceServiceNotFound(c *check.C) {
 	c.Assert(err, check.IsNil)
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	services := a.parsedTsuruServices()
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
 	c.Assert(services, check.DeepEquals, map[string][]bind.ServiceInstance{
 		"mysql": {
 			{
@@ -2678,6 +2753,87 @@ func (s *S) TestRunWithoutEnv(c *check.C) {
 	c.Assert(cmds, check.HasLen, 1)
 }
 
+func (s *S) TestRunIsolated(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	s.provisioner.PrepareOutput([]byte("migrated"))
+	var buf bytes.Buffer
+	err := a.RunIsolated("./migrate.sh", &buf, 5*time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "migrated")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " ./migrate.sh"
+	cmds := s.provisioner.GetCmds(expected, a)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Isolated, check.Equals, true)
+	c.Assert(cmds[0].Timeout, check.Equals, 5*time.Minute)
+	var logs []Applog
+	timeout := time.After(5 * time.Second)
+	for {
+		logs, err = a.LastLogs(10, Applog{})
+		c.Assert(err, check.IsNil)
+		if len(logs) > 1 {
+			break
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for logs")
+		default:
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(logs, check.HasLen, 2)
+	c.Assert(logs[0].Message, check.Equals, "running './migrate.sh' in an isolated unit")
+	c.Assert(logs[1].Message, check.Equals, "migrated")
+	c.Assert(logs[1].Source, check.Equals, "app-run")
+}
+
+func (s *S) TestRunIsolatedDefaultTimeout(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.RunIsolated("./migrate.sh", ioutil.Discard, 0)
+	c.Assert(err, check.IsNil)
+	cmds := s.provisioner.GetCmds("", a)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Timeout, check.Equals, time.Hour)
+	config.Set("run:isolated-timeout", 120)
+	defer config.Unset("run:isolated-timeout")
+	err = a.RunIsolated("./migrate.sh", ioutil.Discard, 0)
+	c.Assert(err, check.IsNil)
+	cmds = s.provisioner.GetCmds("", a)
+	c.Assert(cmds, check.HasLen, 2)
+	c.Assert(cmds[1].Timeout, check.Equals, 2*time.Minute)
+}
+
+func (s *S) TestRunIsolatedExitCode(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	s.provisioner.PrepareExitCode(2)
+	err := a.RunIsolated("./migrate.sh", ioutil.Discard, 0)
+	c.Assert(err, check.ErrorMatches, "command finished with exit code 2")
+}
+
+func (s *S) TestRunIsolatedFailure(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	s.provisioner.PrepareFailure("ExecuteCommandIsolated", provision.ErrExecTimeout)
+	err := a.RunIsolated("./migrate.sh", ioutil.Discard, time.Second)
+	c.Assert(err, check.Equals, provision.ErrExecTimeout)
+}
+
+func (s *S) TestRunIsolatedAppNotDeployed(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	a.Deploys = 0
+	err := a.RunIsolated("./migrate.sh", ioutil.Discard, 0)
+	c.Assert(err, check.Equals, ErrIsolatedRunNotDeployed)
+	c.Assert(s.provisioner.GetCmds("", a), check.HasLen, 0)
+}
+
+func (s *S) TestRunIsolatedNotSupported(c *check.C) {
+	s.addPoolWithProvisioner(c, "pool2", noImageProvisioner{s.provisioner})
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	a.Pool = "pool2"
+	err := a.RunIsolated("./migrate.sh", ioutil.Discard, 0)
+	c.Assert(err, check.Equals, ErrIsolatedRunNotSupported)
+}
+
 func (s *S) TestEnvs(c *check.C) {
 	app := App{
 		Name: "time",
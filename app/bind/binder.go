@@ -8,12 +8,14 @@ package bind
 
 import "io"
 
-// EnvVar represents a environment variable for an app.
+// EnvVar represents a environment variable for an app. Encrypted indicates
+// that Value holds the encrypted value of a private variable.
 type EnvVar struct {
 	Name         string `json:"name"`
 	Value        string `json:"value"`
 	Public       bool   `json:"public"`
 	InstanceName string `json:"-"`
+	Encrypted    bool   `json:"-" bson:",omitempty"`
 }
 
 // Unit represents an application unit to be used in binds.
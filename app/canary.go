@@ -0,0 +1,239 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	stderr "errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrCanaryInProgress    = stderr.New("there is a canary deploy in progress, it must be promoted or aborted first")
+	ErrNoCanary            = stderr.New("there is no canary deploy in progress")
+	ErrInvalidCanaryWeight = stderr.New("canary weight must be between 1 and 99")
+	ErrCanaryWithoutUnits  = stderr.New("canary deploys require the app to have running units")
+	ErrCanaryNotSupported  = stderr.New("the provisioner does not support canary deploys")
+)
+
+// Canary holds information about the canary deploy of an app, in which units
+// running a new image receive part of the traffic of the app, alongside the
+// units running its current image.
+type Canary struct {
+	Image     string
+	Weight    int
+	User      string
+	Timestamp time.Time
+}
+
+// GetCanaryWeight returns the percentage of the traffic sent to the canary
+// units of the app, or 0 when there is no canary deploy.
+func (app *App) GetCanaryWeight() int {
+	if app.Canary == nil {
+		return 0
+	}
+	return app.Canary.Weight
+}
+
+// prepareCanary checks whether the app can be deployed as a canary with the
+// given options and marks the app as being in a canary deploy, so that the
+// provisioner keeps the current units running.
+func (app *App) prepareCanary(opts *DeployOptions) error {
+	if opts.CanaryWeight < 1 || opts.CanaryWeight > 99 {
+		return ErrInvalidCanaryWeight
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	if _, ok := prov.(provision.CanaryDeployer); !ok {
+		return ErrCanaryNotSupported
+	}
+	routerName, err := app.GetRouter()
+	if err != nil {
+		return err
+	}
+	r, err := router.Get(routerName)
+	if err != nil {
+		return err
+	}
+	if _, ok := r.(router.WeightedRouter); !ok {
+		return fmt.Errorf("the router %q does not support weighted routes, required by canary deploys", routerName)
+	}
+	units, err := app.Units()
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return ErrCanaryWithoutUnits
+	}
+	app.Canary = &Canary{
+		Weight:    opts.CanaryWeight,
+		User:      opts.User,
+		Timestamp: time.Now().UTC(),
+	}
+	return nil
+}
+
+func (app *App) saveCanary() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var update bson.M
+	if app.Canary == nil {
+		update = bson.M{"$unset": bson.M{"canary": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"canary": app.Canary}}
+	}
+	return conn.Apps().Update(bson.M{"name": app.Name}, update)
+}
+
+// canaryWeights returns the weight of each route of the app in the router, so
+// that the routes of the canary units receive the configured percentage of
+// the traffic, regardless of the number of units running each image.
+func (app *App) canaryWeights(r router.Router) (map[string]int, error) {
+	if app.Canary == nil || app.Canary.Image == "" {
+		return nil, nil
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	deployer, ok := prov.(provision.CanaryDeployer)
+	if !ok {
+		return nil, nil
+	}
+	canaryUnits, err := deployer.CanaryUnits(app, app.Canary.Image)
+	if err != nil {
+		return nil, err
+	}
+	isCanary := make(map[string]bool, len(canaryUnits))
+	for _, u := range canaryUnits {
+		if u.Address != nil {
+			isCanary[u.Address.String()] = true
+		}
+	}
+	routes, err := r.Routes(app.Name)
+	if err != nil {
+		return nil, err
+	}
+	var canaryRoutes, stableRoutes []string
+	for _, route := range routes {
+		if isCanary[route.String()] {
+			canaryRoutes = append(canaryRoutes, route.String())
+		} else {
+			stableRoutes = append(stableRoutes, route.String())
+		}
+	}
+	if len(canaryRoutes) == 0 || len(stableRoutes) == 0 {
+		return nil, nil
+	}
+	canaryWeight := app.Canary.Weight * len(stableRoutes)
+	stableWeight := (100 - app.Canary.Weight) * len(canaryRoutes)
+	divisor := gcd(canaryWeight, stableWeight)
+	weights := make(map[string]int, len(routes))
+	for _, route := range canaryRoutes {
+		weights[route] = canaryWeight / divisor
+	}
+	for _, route := range stableRoutes {
+		weights[route] = stableWeight / divisor
+	}
+	return weights, nil
+}
+
+// applyCanaryWeights updates the weight of the routes of the app, resetting
+// them when there is no canary deploy in progress.
+func (app *App) applyCanaryWeights() error {
+	routerName, err := app.GetRouter()
+	if err != nil {
+		return err
+	}
+	r, err := router.Get(routerName)
+	if err != nil {
+		return err
+	}
+	wRouter, ok := r.(router.WeightedRouter)
+	if !ok {
+		return nil
+	}
+	weights, err := app.canaryWeights(r)
+	if err != nil {
+		return err
+	}
+	return wRouter.SetWeights(app.Name, weights)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// PromoteCanary finishes the canary deploy of the app, replacing all its units
+// with units running the canary image and sending all the traffic to them.
+func (app *App) PromoteCanary(w io.Writer) error {
+	return app.finishCanary(w, true)
+}
+
+// AbortCanary cancels the canary deploy of the app, removing the canary units
+// and sending all the traffic back to the units running the current image.
+func (app *App) AbortCanary(w io.Writer) error {
+	return app.finishCanary(w, false)
+}
+
+func (app *App) finishCanary(w io.Writer, promote bool) error {
+	if app.Canary == nil {
+		return ErrNoCanary
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	deployer, ok := prov.(provision.CanaryDeployer)
+	if !ok {
+		return ErrCanaryNotSupported
+	}
+	image := app.Canary.Image
+	if promote {
+		err = deployer.PromoteCanary(app, image, w)
+	} else {
+		err = deployer.AbortCanary(app, image, w)
+	}
+	if err != nil {
+		return err
+	}
+	canary := app.Canary
+	app.Canary = nil
+	err = app.saveCanary()
+	if err != nil {
+		app.Canary = canary
+		return err
+	}
+	if promote {
+		_, err = app.createDeployRelease(&DeployOptions{User: canary.User}, image)
+		if err != nil {
+			log.Errorf("[canary] unable to create release for app %q: %s", app.Name, err)
+		}
+	}
+	err = app.applyCanaryWeights()
+	if err != nil {
+		log.Errorf("[canary] unable to reset route weights for app %q: %s", app.Name, err)
+	}
+	_, err = app.RebuildRoutes()
+	if err != nil {
+		log.Errorf("[canary] unable to rebuild routes for app %q: %s", app.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestDeployCanary(c *check.C) {
+	a := s.createTestApp(c, App{Plan: Plan{Router: "fake"}}, 3)
+	defer s.provisioner.Destroy(a)
+	writer := &bytes.Buffer{}
+	err := Deploy(DeployOptions{
+		App:          a,
+		Image:        "myimage",
+		OutputStream: writer,
+		User:         "me@me.com",
+		CanaryWeight: 10,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Canary, check.NotNil)
+	c.Assert(a.Canary.Image, check.Equals, "myimage")
+	c.Assert(a.Canary.Weight, check.Equals, 10)
+	c.Assert(a.Canary.User, check.Equals, "me@me.com")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Canary, check.NotNil)
+	c.Assert(dbApp.Canary.Image, check.Equals, "myimage")
+	c.Assert(dbApp.GetCanaryWeight(), check.Equals, 10)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 4)
+	canaryUnits, err := s.provisioner.CanaryUnits(a, "myimage")
+	c.Assert(err, check.IsNil)
+	c.Assert(canaryUnits, check.HasLen, 1)
+	weights, err := routertest.FakeRouter.Weights(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.HasLen, 4)
+	for _, u := range units {
+		expected := 3
+		if u.ID == canaryUnits[0].ID {
+			expected = 1
+		}
+		c.Assert(weights[u.Address.String()], check.Equals, expected)
+	}
+}
+
+func (s *S) TestDeployCanaryInvalidWeight(c *check.C) {
+	a := s.createTestApp(c, App{Plan: Plan{Router: "fake"}}, 1)
+	defer s.provisioner.Destroy(a)
+	for _, weight := range []int{-1, 100} {
+		err := Deploy(DeployOptions{
+			App:          a,
+			Image:        "myimage",
+			OutputStream: &bytes.Buffer{},
+			CanaryWeight: weight,
+		})
+		c.Assert(err, check.Equals, ErrInvalidCanaryWeight)
+	}
+	c.Assert(a.Canary, check.IsNil)
+}
+
+func (s *S) TestDeployCanaryWithoutUnits(c *check.C) {
+	a := s.createTestApp(c, App{Plan: Plan{Router: "fake"}}, 0)
+	defer s.provisioner.Destroy(a)
+	err := Deploy(DeployOptions{
+		App:          a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		CanaryWeight: 50,
+	})
+	c.Assert(err, check.Equals, ErrCanaryWithoutUnits)
+	c.Assert(a.Canary, check.IsNil)
+}
+
+func (s *S) TestDeployWithCanaryInProgress(c *check.C) {
+	a := s.createTestApp(c, App{Plan: Plan{Router: "fake"}}, 1)
+	defer s.provisioner.Destroy(a)
+	err := Deploy(DeployOptions{
+		App:          a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		CanaryWeight: 50,
+	})
+	c.Assert(err, check.IsNil)
+	err = Deploy(DeployOptions{
+		App:          a,
+		Image:        "otherimage",
+		OutputStream: &bytes.Buffer{},
+	})
+	c.Assert(err, check.Equals, ErrCanaryInProgress)
+}
+
+func (s *S) TestPromoteCanary(c *check.C) {
+	a := s.createTestApp(c, App{Plan: Plan{Router: "fake"}}, 2)
+	defer s.provisioner.Destroy(a)
+	err := Deploy(DeployOptions{
+		App:          a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		CanaryWeight: 20,
+	})
+	c.Assert(err, check.IsNil)
+	canaryUnits, err := s.provisioner.CanaryUnits(a, "myimage")
+	c.Assert(err, check.IsNil)
+	c.Assert(canaryUnits, check.HasLen, 1)
+	writer := &bytes.Buffer{}
+	err = a.PromoteCanary(writer)
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "canary myimage promoted")
+	c.Assert(a.Canary, check.IsNil)
+	var dbApp App
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Canary, check.IsNil)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	c.Assert(units[0].ID, check.Equals, canaryUnits[0].ID)
+	weights, err := routertest.FakeRouter.Weights(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{
+		units[0].Address.String(): 1,
+		units[1].Address.String(): 1,
+	})
+}
+
+func (s *S) TestAbortCanary(c *check.C) {
+	a := s.createTestApp(c, App{Plan: Plan{Router: "fake"}}, 2)
+	defer s.provisioner.Destroy(a)
+	oldUnits, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	err = Deploy(DeployOptions{
+		App:          a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		CanaryWeight: 20,
+	})
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	err = a.AbortCanary(writer)
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "canary myimage aborted")
+	c.Assert(a.Canary, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Canary, check.IsNil)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.DeepEquals, oldUnits)
+	weights, err := routertest.FakeRouter.Weights(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{
+		units[0].Address.String(): 1,
+		units[1].Address.String(): 1,
+	})
+}
+
+func (s *S) TestFinishCanaryWithoutCanary(c *check.C) {
+	a := s.createTestApp(c, App{Plan: Plan{Router: "fake"}}, 1)
+	defer s.provisioner.Destroy(a)
+	err := a.PromoteCanary(nil)
+	c.Assert(err, check.Equals, ErrNoCanary)
+	err = a.AbortCanary(nil)
+	c.Assert(err, check.Equals, ErrNoCanary)
+}
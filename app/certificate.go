@@ -0,0 +1,224 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	stderr "errors"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrCertificateNotFound = stderr.New("certificate not found")
+
+// Certificate is the TLS certificate used by the router of an app to serve
+// one of its cnames over HTTPS. The private key is stored encrypted with the
+// env key of the app, so it's protected by the same master keys as the
+// private environment variables.
+type Certificate struct {
+	CName       string
+	Certificate string
+	Key         string `json:"-"`
+	ExpiresAt   time.Time
+}
+
+// tlsRouter returns the router of the app, failing if it isn't able to serve
+// cnames over HTTPS.
+func (app *App) tlsRouter() (router.TLSRouter, error) {
+	routerName, err := app.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	r, err := router.Get(routerName)
+	if err != nil {
+		return nil, err
+	}
+	tlsRouter, ok := r.(router.TLSRouter)
+	if !ok {
+		return nil, fmt.Errorf("the router %q does not support certificates", routerName)
+	}
+	return tlsRouter, nil
+}
+
+// validateCertificate checks that the key matches the certificate and that
+// the certificate is valid for the cname, returning its expiration date.
+func validateCertificate(cname, certificate, key string) (time.Time, error) {
+	keyPair, err := tls.X509KeyPair([]byte(certificate), []byte(key))
+	if err != nil {
+		return time.Time{}, &errors.ValidationError{Message: fmt.Sprintf("invalid certificate: %s", err)}
+	}
+	leaf, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return time.Time{}, &errors.ValidationError{Message: fmt.Sprintf("invalid certificate: %s", err)}
+	}
+	err = leaf.VerifyHostname(cname)
+	if err != nil {
+		return time.Time{}, &errors.ValidationError{Message: fmt.Sprintf("invalid certificate: %s", err)}
+	}
+	return leaf.NotAfter.UTC(), nil
+}
+
+func (app *App) hasCName(cname string) bool {
+	for _, c := range app.CName {
+		if c == cname {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *App) certificateAdditionalData(cname string) []byte {
+	return []byte(app.Name + "/certificate/" + cname)
+}
+
+// encryptCertificateKey encrypts the private key of the certificate of the
+// cname with the env key of the app, creating the env key if needed.
+func (app *App) encryptCertificateKey(cname, key string) (string, error) {
+	dataKey, err := app.dataKey(true)
+	if err != nil {
+		return "", err
+	}
+	if dataKey == nil {
+		return "", ErrEnvEncryptionDisabled
+	}
+	sealed, err := sealEnvData(dataKey, []byte(key), app.certificateAdditionalData(cname))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (app *App) decryptCertificateKey(cert Certificate) (string, error) {
+	dataKey, err := app.dataKey(false)
+	if err != nil {
+		return "", err
+	}
+	if dataKey == nil {
+		return "", fmt.Errorf("the app %q has certificates but no env key", app.Name)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(cert.Key)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt the key of the certificate of %q: %s", cert.CName, err)
+	}
+	key, err := openEnvData(dataKey, sealed, app.certificateAdditionalData(cert.CName))
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt the key of the certificate of %q: %s", cert.CName, err)
+	}
+	return string(key), nil
+}
+
+// SetCertificate adds the certificate and the private key, both PEM encoded,
+// to the router of the app, to serve the cname over HTTPS. The cname must be
+// assigned to the app and the certificate must be valid for it. An existing
+// certificate of the cname is replaced, and the cname stops getting
+// certificates through ACME.
+func (app *App) SetCertificate(cname, certificate, key string) error {
+	err := app.setCertificate(cname, certificate, key)
+	if err != nil {
+		return err
+	}
+	app.cancelACMECertificates([]string{cname})
+	return nil
+}
+
+func (app *App) setCertificate(cname, certificate, key string) error {
+	if !app.hasCName(cname) {
+		return &errors.ValidationError{Message: fmt.Sprintf("cname %q is not assigned to the app", cname)}
+	}
+	expiresAt, err := validateCertificate(cname, certificate, key)
+	if err != nil {
+		return err
+	}
+	tlsRouter, err := app.tlsRouter()
+	if err != nil {
+		return err
+	}
+	sealedKey, err := app.encryptCertificateKey(cname, key)
+	if err != nil {
+		return err
+	}
+	err = tlsRouter.AddCertificate(cname, certificate, key)
+	if err != nil {
+		return err
+	}
+	cert := Certificate{CName: cname, Certificate: certificate, Key: sealedKey, ExpiresAt: expiresAt}
+	certificates := make([]Certificate, 0, len(app.Certificates)+1)
+	for _, c := range app.Certificates {
+		if c.CName != cname {
+			certificates = append(certificates, c)
+		}
+	}
+	app.Certificates = append(certificates, cert)
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"certificates": app.Certificates, "envkey": app.EnvKey}})
+}
+
+// RemoveCertificate removes the certificate of the cname from the router of
+// the app.
+func (app *App) RemoveCertificate(cname string) error {
+	index := -1
+	for i, c := range app.Certificates {
+		if c.CName == cname {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ErrCertificateNotFound
+	}
+	tlsRouter, err := app.tlsRouter()
+	if err != nil {
+		return err
+	}
+	err = tlsRouter.RemoveCertificate(cname)
+	if err != nil && err != router.ErrCertificateNotFound {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$pull": bson.M{"certificates": bson.M{"cname": cname}}})
+	if err != nil {
+		return err
+	}
+	app.Certificates = append(app.Certificates[:index], app.Certificates[index+1:]...)
+	return nil
+}
+
+// removeCNameCertificates removes the certificates of cnames no longer
+// assigned to the app. Failures are only logged, as the cnames are already
+// gone.
+func (app *App) removeCNameCertificates(cnames []string) {
+	for _, cname := range cnames {
+		err := app.RemoveCertificate(cname)
+		if err != nil && err != ErrCertificateNotFound {
+			log.Errorf("[certificates] unable to remove the certificate of %q from the app %q: %s", cname, app.Name, err)
+		}
+	}
+}
+
+// CertificateExpirations returns the expiration date of the certificate of
+// each cname of the app.
+func (app *App) CertificateExpirations() map[string]time.Time {
+	expirations := make(map[string]time.Time, len(app.Certificates))
+	for _, c := range app.Certificates {
+		expirations[c.CName] = c.ExpiresAt
+	}
+	return expirations
+}
@@ -0,0 +1,293 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/acme"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/periodic"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	ACMEStatusPending = "pending"
+	ACMEStatusIssued  = "issued"
+	ACMEStatusFailed  = "failed"
+)
+
+var (
+	// acmeIssueTimeout is how long the issuance of a certificate may take,
+	// including the validation of its challenge.
+	acmeIssueTimeout = 5 * time.Minute
+
+	// acmeMaxRetryInterval is the longest interval between attempts to
+	// obtain a certificate after failures.
+	acmeMaxRetryInterval = 24 * time.Hour
+)
+
+// ACMECertificate is the status of the certificate of a cname obtained
+// automatically from the ACME server configured in tsuru. NextAttempt is when
+// the certificate is going to be requested again, either to renew it or to
+// retry after a failure.
+type ACMECertificate struct {
+	CName       string `bson:"_id"`
+	App         string
+	Status      string
+	Error       string
+	ExpiresAt   time.Time
+	Attempts    int
+	NextAttempt time.Time
+	UpdatedAt   time.Time
+}
+
+// acmeChallenger sends the ACME challenges of the cnames to the tsuru API
+// through the router of the app.
+type acmeChallenger struct {
+	router  router.ACMEChallengeRouter
+	address *url.URL
+}
+
+func (c *acmeChallenger) Present(domain string) error {
+	return c.router.AddChallengeRoute(domain, c.address)
+}
+
+func (c *acmeChallenger) CleanUp(domain string) error {
+	return c.router.RemoveChallengeRoute(domain)
+}
+
+// acmeChallengeRouter returns the router of the app, failing if it isn't
+// able to route the ACME challenges of the cnames to tsuru.
+func (app *App) acmeChallengeRouter() (router.ACMEChallengeRouter, error) {
+	tlsRouter, err := app.tlsRouter()
+	if err != nil {
+		return nil, err
+	}
+	challengeRouter, ok := tlsRouter.(router.ACMEChallengeRouter)
+	if !ok {
+		routerName, _ := app.GetRouter()
+		return nil, fmt.Errorf("the router %q does not support ACME challenges", routerName)
+	}
+	return challengeRouter, nil
+}
+
+// requestACMECertificates schedules the issuance of certificates for the
+// cnames, when tsuru is configured with an ACME server and the router of the
+// app supports it. Failures are only logged, as the cnames are already added.
+func (app *App) requestACMECertificates(cnames []string) {
+	if !acme.Enabled() {
+		return
+	}
+	_, err := app.acmeChallengeRouter()
+	if err != nil {
+		log.Debugf("[acme] not requesting certificates for the app %q: %s", app.Name, err)
+		return
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		log.Errorf("[acme] unable to request certificates for the app %q: %s", app.Name, err)
+		return
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	for _, cname := range cnames {
+		_, err = conn.ACMECertificates().UpsertId(cname, ACMECertificate{
+			CName:       cname,
+			App:         app.Name,
+			Status:      ACMEStatusPending,
+			NextAttempt: now,
+			UpdatedAt:   now,
+		})
+		if err != nil {
+			log.Errorf("[acme] unable to request the certificate of %q for the app %q: %s", cname, app.Name, err)
+		}
+	}
+}
+
+// cancelACMECertificates stops obtaining certificates for the cnames.
+func (app *App) cancelACMECertificates(cnames []string) {
+	conn, err := db.Conn()
+	if err != nil {
+		log.Errorf("[acme] unable to cancel certificates of the app %q: %s", app.Name, err)
+		return
+	}
+	defer conn.Close()
+	_, err = conn.ACMECertificates().RemoveAll(bson.M{"_id": bson.M{"$in": cnames}, "app": app.Name})
+	if err != nil {
+		log.Errorf("[acme] unable to cancel certificates of the app %q: %s", app.Name, err)
+	}
+}
+
+func removeACMECertificates(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ACMECertificates().RemoveAll(bson.M{"app": appName})
+	return err
+}
+
+// ACMECertificates returns the status of the certificates of the cnames of
+// the app obtained through ACME.
+func (app *App) ACMECertificates() ([]ACMECertificate, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var certificates []ACMECertificate
+	err = conn.ACMECertificates().Find(bson.M{"app": app.Name}).Sort("_id").All(&certificates)
+	if err != nil {
+		return nil, err
+	}
+	return certificates, nil
+}
+
+// obtainACMECertificate obtains a certificate for the cname from the ACME
+// server and adds it to the router of the app, returning its expiration
+// date.
+func (app *App) obtainACMECertificate(ctx context.Context, cname string) (time.Time, error) {
+	challengeRouter, err := app.acmeChallengeRouter()
+	if err != nil {
+		return time.Time{}, err
+	}
+	host, _ := config.GetString("host")
+	if host == "" {
+		return time.Time{}, fmt.Errorf("the address of the tsuru API is required to answer ACME challenges, please set host")
+	}
+	address, err := url.Parse(host)
+	if err != nil {
+		return time.Time{}, err
+	}
+	certificate, key, err := acme.Obtain(ctx, cname, &acmeChallenger{router: challengeRouter, address: address})
+	if err != nil {
+		return time.Time{}, err
+	}
+	err = app.setCertificate(cname, certificate, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return validateCertificate(cname, certificate, key)
+}
+
+// ACMERenewer periodically obtains the pending certificates of cnames and
+// renews the certificates close to their expiration. A certificate is claimed
+// by moving its next attempt beyond the time its issuance may take, so only
+// one API instance requests it to the ACME server.
+type ACMERenewer struct {
+	*periodic.Loop
+}
+
+// NewACMERenewer returns a renewer that looks for due certificates every
+// minute.
+func NewACMERenewer() *ACMERenewer {
+	r := &ACMERenewer{}
+	r.Loop = &periodic.Loop{
+		Name:     "ACME certificate renewer",
+		Interval: time.Minute,
+		Task:     r.runOnce,
+	}
+	return r
+}
+
+func (r *ACMERenewer) runOnce() error {
+	if !acme.Enabled() {
+		return nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var certificates []ACMECertificate
+	err = conn.ACMECertificates().Find(bson.M{"nextattempt": bson.M{"$gt": time.Time{}, "$lte": time.Now().UTC()}}).All(&certificates)
+	if err != nil {
+		return fmt.Errorf("error getting certificates: %s", err)
+	}
+	for i := range certificates {
+		err = r.renew(&certificates[i])
+		if err != nil {
+			log.Errorf("[acme] error obtaining the certificate of %q for the app %q: %s", certificates[i].CName, certificates[i].App, err)
+		}
+	}
+	return nil
+}
+
+// renew claims the certificate and requests it to the ACME server, updating
+// its status. It does nothing if the certificate was already claimed by
+// another instance.
+func (r *ACMERenewer) renew(cert *ACMECertificate) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	err = conn.ACMECertificates().Update(
+		bson.M{"_id": cert.CName, "nextattempt": cert.NextAttempt},
+		bson.M{"$set": bson.M{"nextattempt": now.Add(2 * acmeIssueTimeout)}},
+	)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	a, err := GetByName(cert.App)
+	if err == ErrAppNotFound || (err == nil && !a.hasCName(cert.CName)) {
+		return conn.ACMECertificates().RemoveId(cert.CName)
+	}
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), acmeIssueTimeout)
+	defer cancel()
+	expiresAt, issueErr := a.obtainACMECertificate(ctx, cert.CName)
+	now = time.Now().UTC()
+	var update bson.M
+	if issueErr == nil {
+		renewAt := expiresAt.Add(-acme.RenewBefore())
+		if renewAt.Before(now) {
+			renewAt = now.Add(expiresAt.Sub(now) / 2)
+		}
+		update = bson.M{
+			"status":      ACMEStatusIssued,
+			"error":       "",
+			"expiresat":   expiresAt,
+			"attempts":    0,
+			"nextattempt": renewAt,
+			"updatedat":   now,
+		}
+	} else {
+		retryInterval := time.Minute << uint(cert.Attempts)
+		if retryInterval > acmeMaxRetryInterval || retryInterval <= 0 {
+			retryInterval = acmeMaxRetryInterval
+		}
+		update = bson.M{
+			"status":      ACMEStatusFailed,
+			"error":       issueErr.Error(),
+			"attempts":    cert.Attempts + 1,
+			"nextattempt": now.Add(retryInterval),
+			"updatedat":   now,
+		}
+	}
+	err = conn.ACMECertificates().Update(bson.M{"_id": cert.CName, "app": cert.App}, bson.M{"$set": update})
+	if err == mgo.ErrNotFound {
+		return issueErr
+	}
+	if err != nil {
+		return err
+	}
+	return issueErr
+}
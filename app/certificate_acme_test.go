@@ -0,0 +1,240 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/acme"
+	"github.com/tsuru/tsuru/acme/acmetest"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// startACMEServer configures tsuru with a local ACME server, whose challenge
+// requests for the test cname reach a server answering them like the tsuru
+// API. The challenge routes seen by this server are recorded in routes.
+func (s *S) startACMEServer(c *check.C) (server *acmetest.Server, routes *[]*url.URL, cleanup func()) {
+	server, err := acmetest.NewServer()
+	c.Assert(err, check.IsNil)
+	routes = &[]*url.URL{}
+	challengeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*routes = append(*routes, routertest.TLSRouter.ChallengeRoute(routertest.CertificateCName))
+		keyAuthorization, err := acme.KeyAuthorization(strings.TrimPrefix(r.URL.Path, router.ACMEChallengePath))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(keyAuthorization))
+	}))
+	server.Resolve(routertest.CertificateCName, challengeServer.Listener.Addr().String())
+	config.Set("acme:directory-url", server.DirectoryURL())
+	config.Set("host", "http://tsuru.example.com:8080")
+	restoreKeys := setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})
+	return server, routes, func() {
+		restoreKeys()
+		config.Unset("acme")
+		config.Unset("host")
+		challengeServer.Close()
+		server.Close()
+	}
+}
+
+func (s *S) TestAddCNameRequestsACMECertificate(c *check.C) {
+	_, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := &App{Name: "myapp", Plan: Plan{Router: "fake-tls"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	s.provisioner.Provision(a)
+	defer s.provisioner.Destroy(a)
+	err = a.AddCName(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 1)
+	c.Assert(certificates[0].CName, check.Equals, routertest.CertificateCName)
+	c.Assert(certificates[0].App, check.Equals, a.Name)
+	c.Assert(certificates[0].Status, check.Equals, ACMEStatusPending)
+	c.Assert(certificates[0].NextAttempt.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestAddCNameACMEDisabled(c *check.C) {
+	a := &App{Name: "myapp", Plan: Plan{Router: "fake-tls"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	s.provisioner.Provision(a)
+	defer s.provisioner.Destroy(a)
+	err = a.AddCName(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 0)
+}
+
+func (s *S) TestAddCNameACMERouterWithoutSupport(c *check.C) {
+	_, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := &App{Name: "myapp", Plan: Plan{Router: "fake"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	s.provisioner.Provision(a)
+	defer s.provisioner.Destroy(a)
+	err = a.AddCName(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 0)
+}
+
+func (s *S) TestRemoveCNameCancelsACMECertificate(c *check.C) {
+	_, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := &App{Name: "myapp", Plan: Plan{Router: "fake-tls"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	s.provisioner.Provision(a)
+	defer s.provisioner.Destroy(a)
+	err = a.AddCName(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	err = a.RemoveCName(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 0)
+}
+
+func (s *S) TestSetCertificateCancelsACMECertificate(c *check.C) {
+	_, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.requestACMECertificates(a.CName)
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 0)
+}
+
+func (s *S) TestACMERenewerObtainsCertificate(c *check.C) {
+	server, routes, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.requestACMECertificates(a.CName)
+	err := NewACMERenewer().runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Issued(), check.HasLen, 1)
+	c.Assert(*routes, check.HasLen, 1)
+	c.Assert((*routes)[0].String(), check.Equals, "http://tsuru.example.com:8080")
+	c.Assert(routertest.TLSRouter.ChallengeRoute(routertest.CertificateCName), check.IsNil)
+	certificate, err := routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	c.Assert(certificate, check.Matches, "(?s)-----BEGIN CERTIFICATE-----.*")
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Certificates, check.HasLen, 1)
+	c.Assert(stored.Certificates[0].Certificate, check.Equals, certificate)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 1)
+	cert := certificates[0]
+	c.Assert(cert.Status, check.Equals, ACMEStatusIssued)
+	c.Assert(cert.Error, check.Equals, "")
+	c.Assert(cert.ExpiresAt.Equal(stored.Certificates[0].ExpiresAt), check.Equals, true)
+	c.Assert(cert.NextAttempt.Equal(cert.ExpiresAt.Add(-acme.RenewBefore())), check.Equals, true)
+}
+
+func (s *S) TestACMERenewerRenewsCertificate(c *check.C) {
+	server, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.requestACMECertificates(a.CName)
+	renewer := NewACMERenewer()
+	err := renewer.runOnce()
+	c.Assert(err, check.IsNil)
+	err = renewer.runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Issued(), check.HasLen, 1)
+	err = s.conn.ACMECertificates().UpdateId(routertest.CertificateCName, bson.M{"$set": bson.M{"nextattempt": time.Now().UTC()}})
+	c.Assert(err, check.IsNil)
+	err = renewer.runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Issued(), check.HasLen, 2)
+	certificate, err := routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	leaf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Issued()[1].Raw})
+	c.Assert(strings.HasPrefix(certificate, string(leaf)), check.Equals, true)
+}
+
+func (s *S) TestACMERenewerFailure(c *check.C) {
+	server, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	server.Resolve(routertest.CertificateCName, "127.0.0.1:1")
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.requestACMECertificates(a.CName)
+	before := time.Now().UTC()
+	err := NewACMERenewer().runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Issued(), check.HasLen, 0)
+	_, err = routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	c.Assert(routertest.TLSRouter.ChallengeRoute(routertest.CertificateCName), check.IsNil)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 1)
+	cert := certificates[0]
+	c.Assert(cert.Status, check.Equals, ACMEStatusFailed)
+	c.Assert(cert.Error, check.Not(check.Equals), "")
+	c.Assert(cert.Attempts, check.Equals, 1)
+	c.Assert(cert.NextAttempt.After(before.Add(time.Minute)), check.Equals, true)
+}
+
+func (s *S) TestACMERenewerSkipsClaimedCertificate(c *check.C) {
+	server, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.requestACMECertificates(a.CName)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	cert := certificates[0]
+	err = s.conn.ACMECertificates().UpdateId(cert.CName, bson.M{"$set": bson.M{"nextattempt": cert.NextAttempt.Add(-time.Second)}})
+	c.Assert(err, check.IsNil)
+	err = NewACMERenewer().renew(&cert)
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Issued(), check.HasLen, 0)
+}
+
+func (s *S) TestACMERenewerRemovesCNamesNotInApp(c *check.C) {
+	server, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.requestACMECertificates([]string{"other.io"})
+	err := NewACMERenewer().runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Issued(), check.HasLen, 0)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 0)
+}
+
+func (s *S) TestRemoveACMECertificates(c *check.C) {
+	_, _, cleanup := s.startACMEServer(c)
+	defer cleanup()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.requestACMECertificates(a.CName)
+	err := removeACMECertificates(a.Name)
+	c.Assert(err, check.IsNil)
+	certificates, err := a.ACMECertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.HasLen, 0)
+}
@@ -0,0 +1,163 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestSetCertificate(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	certificate, err := routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	c.Assert(certificate, check.Equals, routertest.Certificate)
+	c.Assert(routertest.TLSRouter.GetKey(routertest.CertificateCName), check.Equals, routertest.CertificateKey)
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.EnvKey, check.NotNil)
+	c.Assert(stored.Certificates, check.HasLen, 1)
+	cert := stored.Certificates[0]
+	c.Assert(cert.CName, check.Equals, routertest.CertificateCName)
+	c.Assert(cert.Certificate, check.Equals, routertest.Certificate)
+	c.Assert(cert.Key, check.Not(check.Equals), routertest.CertificateKey)
+	c.Assert(cert.ExpiresAt.Year(), check.Equals, 2126)
+	key, err := stored.decryptCertificateKey(cert)
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.Equals, routertest.CertificateKey)
+}
+
+func (s *S) TestSetCertificateReplacesExisting(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Certificates, check.HasLen, 1)
+}
+
+func (s *S) TestSetCertificateCNameNotInApp(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	err := a.SetCertificate("other.io", routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `cname "other.io" is not assigned to the app`)
+}
+
+func (s *S) TestSetCertificateInvalid(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.CName = append(a.CName, "other.io")
+	err := a.SetCertificate("other.io", routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, "invalid certificate: x509: .*other.io.*")
+	err = a.SetCertificate(routertest.CertificateCName, routertest.Certificate, "invalid key")
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, "invalid certificate: .*")
+	_, err = routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+}
+
+func (s *S) TestSetCertificateRouterWithoutTLS(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	a.Plan.Router = "fake"
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.ErrorMatches, `the router "fake" does not support certificates`)
+}
+
+func (s *S) TestSetCertificateEncryptionDisabled(c *check.C) {
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.Equals, ErrEnvEncryptionDisabled)
+	_, err = routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+}
+
+func (s *S) TestRemoveCertificate(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	err = a.RemoveCertificate(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Certificates, check.HasLen, 0)
+	_, err = routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Certificates, check.HasLen, 0)
+	err = a.RemoveCertificate(routertest.CertificateCName)
+	c.Assert(err, check.Equals, ErrCertificateNotFound)
+}
+
+func (s *S) TestRemoveCNameRemovesCertificate(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := &App{Name: "myapp", Plan: Plan{Router: "fake-tls"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	s.provisioner.Provision(a)
+	defer s.provisioner.Destroy(a)
+	err = a.AddCName(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	err = a.RemoveCName(routertest.CertificateCName)
+	c.Assert(err, check.IsNil)
+	_, err = routertest.TLSRouter.GetCertificate(routertest.CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Certificates, check.HasLen, 0)
+}
+
+func (s *S) TestReencryptAllEnvsWithCertificates(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	setEnvMasterKeys("key2", map[string]string{"key1": testEnvKey1, "key2": testEnvKey2})
+	_, err = ReencryptAllEnvs(nil)
+	c.Assert(err, check.IsNil)
+	setEnvMasterKeys("key2", map[string]string{"key2": testEnvKey2})
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.EnvKey.MasterKeyID, check.Equals, "key2")
+	c.Assert(stored.Certificates, check.HasLen, 1)
+	key, err := stored.decryptCertificateKey(stored.Certificates[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.Equals, routertest.CertificateKey)
+}
+
+func (s *S) TestAppMarshalJSONWithCertificates(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{CName: []string{routertest.CertificateCName}, Plan: Plan{Router: "fake-tls"}}, 0)
+	err := a.SetCertificate(routertest.CertificateCName, routertest.Certificate, routertest.CertificateKey)
+	c.Assert(err, check.IsNil)
+	data, err := a.MarshalJSON()
+	c.Assert(err, check.IsNil)
+	var result map[string]interface{}
+	err = json.Unmarshal(data, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result["certificates"], check.DeepEquals, map[string]interface{}{
+		routertest.CertificateCName: a.Certificates[0].ExpiresAt.Format(time.RFC3339),
+	})
+	var raw bson.M
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&raw)
+	c.Assert(err, check.IsNil)
+	c.Assert(raw["certificates"], check.HasLen, 1)
+}
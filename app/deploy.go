@@ -5,16 +5,19 @@
 package app
 
 import (
-	"bytes"
+	stderr "errors"
 	"fmt"
 	"io"
 	"regexp"
 	"time"
 
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/safe"
+	"github.com/tsuru/tsuru/webhook"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -22,13 +25,41 @@ type DeployKind string
 
 const (
 	DeployArchiveURL  DeployKind = "archive-url"
+	DeployDockerfile  DeployKind = "dockerfile"
 	DeployGit         DeployKind = "git"
 	DeployImage       DeployKind = "image"
+	DeployPromote     DeployKind = "promote"
 	DeployRollback    DeployKind = "rollback"
 	DeployUpload      DeployKind = "upload"
 	DeployUploadBuild DeployKind = "uploadbuild"
 )
 
+// Cancelable reports whether deploys of the kind can be canceled while
+// running. Only deploys that build a new image can be canceled, as the
+// provisioners cancel them by stopping the build.
+func (k DeployKind) Cancelable() bool {
+	switch k {
+	case DeployArchiveURL, DeployGit, DeployUpload, DeployUploadBuild:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrDockerfileDeployNotSupported = stderr.New("the provisioner does not support deploys with Dockerfile")
+	ErrDeployCanceled               = stderr.New("deploy canceled by user request")
+)
+
+var (
+	// deployLogFlushInterval is the interval for storing the output of
+	// running deploys, so clients may follow it with FollowDeployLog.
+	deployLogFlushInterval = time.Second
+
+	// deployCancelCheckInterval is the interval for checking whether the
+	// cancellation of a running deploy was requested.
+	deployCancelCheckInterval = 2 * time.Second
+)
+
 type DeployData struct {
 	ID          bson.ObjectId `bson:"_id,omitempty"`
 	App         string
@@ -43,6 +74,8 @@ type DeployData struct {
 	CanRollback bool
 	RemoveDate  time.Time `bson:",omitempty"`
 	Diff        string
+	Running     bool
+	Canceled    bool
 }
 
 // ListDeploys returns the list of deploy that match a given filter.
@@ -57,8 +90,13 @@ func ListDeploys(filter *Filter, skip, limit int) ([]DeployData, error) {
 		return nil, err
 	}
 	apps := make([]string, len(appsList))
+	provisioners := make(map[string]provision.Provisioner, len(appsList))
 	for i, a := range appsList {
 		apps[i] = a.GetName()
+		provisioners[apps[i]], err = a.GetProvisioner()
+		if err != nil {
+			return nil, err
+		}
 	}
 	var list []DeployData
 	f := bson.M{"app": bson.M{"$in": apps}, "removedate": bson.M{"$exists": false}}
@@ -73,6 +111,8 @@ func ListDeploys(filter *Filter, skip, limit int) ([]DeployData, error) {
 		"origin":      1,
 		"canrollback": 1,
 		"removedate":  1,
+		"running":     1,
+		"canceled":    1,
 	}
 	query := conn.Deploys().Find(f).Select(s).Sort("-timestamp")
 	if skip != 0 {
@@ -87,7 +127,7 @@ func ListDeploys(filter *Filter, skip, limit int) ([]DeployData, error) {
 	validImages := set{}
 	for _, appName := range apps {
 		var imgs []string
-		imgs, err = Provisioner.ValidAppImages(appName)
+		imgs, err = provisioners[appName].ValidAppImages(appName)
 		if err != nil {
 			return nil, err
 		}
@@ -145,16 +185,41 @@ type DeployOptions struct {
 	Origin       string
 	Rollback     bool
 	Build        bool
+	CanaryWeight int
+
+	// Dockerfile indicates that File must be built with the Dockerfile
+	// included in it, instead of the platform of the app.
+	Dockerfile bool
+
+	// Release is the version of the release being restored by a rollback.
+	Release int
+
+	// PromoteFrom is the name of the app that built the image, when
+	// promoting it to App.
+	PromoteFrom string
+
+	// Event is the event tracking the deploy. Its unique ID is used as the
+	// ID of the deploy and, when it's cancelable, the deploy is canceled
+	// once the event is.
+	Event *event.Event
+
+	id bson.ObjectId
 }
 
 func (o *DeployOptions) Kind() DeployKind {
 	if o.Rollback {
 		return DeployRollback
 	}
+	if o.PromoteFrom != "" {
+		return DeployPromote
+	}
 	if o.Image != "" {
 		return DeployImage
 	}
 	if o.File != nil {
+		if o.Dockerfile {
+			return DeployDockerfile
+		}
 		if o.Build {
 			return DeployUploadBuild
 		}
@@ -169,24 +234,73 @@ func (o *DeployOptions) Kind() DeployKind {
 // Deploy runs a deployment of an application. It will first try to run an
 // archive based deploy (if opts.ArchiveURL is not empty), and then fallback to
 // the Git based deployment.
-func Deploy(opts DeployOptions) error {
-	var outBuffer bytes.Buffer
+func Deploy(opts DeployOptions) (err error) {
+	if opts.App.Canary != nil {
+		return ErrCanaryInProgress
+	}
+	if opts.CanaryWeight != 0 {
+		err = opts.App.prepareCanary(&opts)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.Event != nil {
+		opts.id = opts.Event.UniqueID
+	} else {
+		opts.id = bson.NewObjectId()
+	}
+	outBuffer := safe.NewBuffer(nil)
 	start := time.Now()
 	logWriter := LogWriter{App: opts.App}
 	logWriter.Async()
 	defer logWriter.Close()
-	writer := io.MultiWriter(&tsuruIo.NoErrorWriter{Writer: opts.OutputStream}, &outBuffer, &logWriter)
+	writer := io.MultiWriter(&tsuruIo.NoErrorWriter{Writer: opts.OutputStream}, outBuffer, &logWriter)
 	elapsed := time.Since(start)
 	saveErr := saveDeployData(&opts, "diff", "", elapsed, nil)
 	if saveErr != nil {
 		log.Errorf("WARNING: couldn't save deploy data, deploy opts: %#v", opts)
 	}
-	imageId, err := deployToProvisioner(&opts, writer)
+	var imageId string
+	cancelWatcher := newDeployCancelWatcher(&opts)
+	if cancelWatcher.check() {
+		cancelWatcher.ack()
+		err = ErrDeployCanceled
+	} else {
+		stopFlush := flushDeployLog(opts.id, outBuffer)
+		cancelWatcher.start()
+		imageId, err = deployToProvisioner(&opts, writer)
+		if cancelWatcher.stop() && err != nil {
+			err = ErrDeployCanceled
+		}
+		stopFlush()
+	}
+	defer func() {
+		kind := webhook.EventDeploy
+		if opts.Rollback {
+			kind = webhook.EventRollback
+		}
+		opts.App.notifyWebhooks(kind, opts.User, err, map[string]interface{}{
+			"kind":   string(opts.Kind()),
+			"image":  imageId,
+			"commit": opts.Commit,
+			"origin": opts.Origin,
+		})
+	}()
 	elapsed = time.Since(start)
+	observeDeploy(opts.Kind(), elapsed, err)
 	saveErr = saveDeployData(&opts, imageId, outBuffer.String(), elapsed, err)
 	if saveErr != nil {
 		log.Errorf("WARNING: couldn't save deploy data, deploy opts: %#v", opts)
 	}
+	if opts.App.Canary != nil {
+		if err == nil {
+			opts.App.Canary.Image = imageId
+			err = opts.App.saveCanary()
+		}
+		if err != nil {
+			opts.App.Canary = nil
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -194,6 +308,12 @@ func Deploy(opts DeployOptions) error {
 	if err != nil {
 		log.Errorf("WARNING: couldn't increment deploy count, deploy opts: %#v", opts)
 	}
+	if opts.App.Canary == nil && opts.Release == 0 {
+		_, err = opts.App.createDeployRelease(&opts, imageId)
+		if err != nil {
+			log.Errorf("WARNING: couldn't create release, deploy opts: %#v: %s", opts, err)
+		}
+	}
 	if opts.App.UpdatePlatform == true {
 		opts.App.SetUpdatePlatform(false)
 	}
@@ -205,21 +325,35 @@ func Deploy(opts DeployOptions) error {
 }
 
 func deployToProvisioner(opts *DeployOptions, writer io.Writer) (string, error) {
+	prov, err := opts.App.GetProvisioner()
+	if err != nil {
+		return "", err
+	}
 	switch opts.Kind() {
 	case DeployRollback:
-		return Provisioner.Rollback(opts.App, opts.Image, writer)
+		return prov.Rollback(opts.App, opts.Image, writer)
+	case DeployPromote:
+		if promoter, ok := prov.(provision.ImagePromoter); ok {
+			return promoter.PromoteImage(opts.App, opts.Image, writer)
+		}
+		return "", ErrPromoteNotSupported
+	case DeployDockerfile:
+		if deployer, ok := prov.(provision.DockerfileDeployer); ok {
+			return deployer.DockerfileDeploy(opts.App, opts.File, writer)
+		}
+		return "", ErrDockerfileDeployNotSupported
 	case DeployImage:
-		if deployer, ok := Provisioner.(provision.ImageDeployer); ok {
+		if deployer, ok := prov.(provision.ImageDeployer); ok {
 			return deployer.ImageDeploy(opts.App, opts.Image, writer)
 		}
 		fallthrough
 	case DeployUpload, DeployUploadBuild:
-		if deployer, ok := Provisioner.(provision.UploadDeployer); ok {
+		if deployer, ok := prov.(provision.UploadDeployer); ok {
 			return deployer.UploadDeploy(opts.App, opts.File, opts.FileSize, opts.Build, writer)
 		}
 		fallthrough
 	default:
-		return Provisioner.(provision.ArchiveDeployer).ArchiveDeploy(opts.App, opts.ArchiveURL, writer)
+		return prov.(provision.ArchiveDeployer).ArchiveDeploy(opts.App, opts.ArchiveURL, writer)
 	}
 }
 
@@ -237,6 +371,8 @@ func saveDeployData(opts *DeployOptions, imageId, log string, duration time.Dura
 		Image:     imageId,
 		Log:       log,
 		User:      opts.User,
+		Running:   imageId == "diff",
+		Canceled:  deployError == ErrDeployCanceled,
 	}
 	if opts.Origin != "" {
 		deploy.Origin = opts.Origin
@@ -251,11 +387,25 @@ func saveDeployData(opts *DeployOptions, imageId, log string, duration time.Dura
 	if err != nil {
 		return err
 	}
-	if len(dep) == 1 {
-		deploy.Diff = dep[0].Diff
+	if opts.id == "" {
+		if len(dep) == 1 {
+			deploy.Diff = dep[0].Diff
+		}
+		query := bson.M{"$set": deploy}
+		_, err = conn.Deploys().Upsert(bson.M{"app": deploy.App, "image": "diff"}, query)
+		return err
+	}
+	// While running, the deploy is saved with the "diff" image. Diffs saved
+	// before the deploy started are moved to the deploy with the given ID.
+	for _, d := range dep {
+		if d.Diff != "" {
+			deploy.Diff = d.Diff
+		}
+		if d.ID != opts.id {
+			conn.Deploys().RemoveId(d.ID)
+		}
 	}
-	query := bson.M{"$set": deploy}
-	_, err = conn.Deploys().Upsert(bson.M{"app": deploy.App, "image": "diff"}, query)
+	_, err = conn.Deploys().UpsertId(opts.id, bson.M{"$set": deploy})
 	return err
 }
 
@@ -315,14 +465,66 @@ func getImage(appName string, img string) (string, error) {
 	return deploy.Image, nil
 }
 
+// Rollback deploys a previous image of the app. When opts.Release is set,
+// the app is rolled back to that release: besides its image, the envs and
+// the number of units of each process in the release are restored. Rolling
+// back to an image restores the last release of that image, if any.
 func Rollback(opts DeployOptions) error {
-	if !regexp.MustCompile(":v[0-9]+$").MatchString(opts.Image) {
-		img, err := getImage(opts.App.Name, opts.Image)
-		// err is not handled here because it is handled by Deploy
-		if err == nil {
-			opts.Image = img
+	if opts.App.Canary != nil {
+		return ErrCanaryInProgress
+	}
+	var release *Release
+	var err error
+	if opts.Release != 0 {
+		release, err = GetRelease(opts.App.Name, opts.Release)
+		if err != nil {
+			return err
+		}
+		if release.Image == "" {
+			return fmt.Errorf("release %d of the app %q has no image", release.Version, opts.App.Name)
+		}
+		opts.Image = release.Image
+	} else {
+		if !regexp.MustCompile(":v[0-9]+$").MatchString(opts.Image) {
+			img, err := getImage(opts.App.Name, opts.Image)
+			// err is not handled here because it is handled by Deploy
+			if err == nil {
+				opts.Image = img
+			}
+			opts.Rollback = true
+		}
+		release, err = lastRelease(bson.M{"app": opts.App.Name, "image": opts.Image})
+		if err == ErrReleaseNotFound {
+			return Deploy(opts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	opts.Rollback = true
+	opts.Release = release.Version
+	fmt.Fprintf(opts.OutputStream, "---- Rolling back to the release %d of the app %q ----\n", release.Version, opts.App.Name)
+	currentEnvs, currentEnvKey := opts.App.Env, opts.App.EnvKey
+	err = opts.App.restoreReleaseEnvs(release)
+	if err != nil {
+		return err
+	}
+	err = Deploy(opts)
+	if err == nil {
+		err = opts.App.restoreReleaseUnits(release, opts.OutputStream)
+	}
+	if err != nil {
+		if restoreErr := opts.App.replaceEnvs(currentEnvs, currentEnvKey); restoreErr != nil {
+			log.Errorf("WARNING: couldn't restore the envs of the app %q after a failed rollback: %s", opts.App.Name, restoreErr)
 		}
-		opts.Rollback = true
+		return err
 	}
-	return Deploy(opts)
+	_, err = opts.App.createRelease(Release{
+		Reason:     ReleaseRollback,
+		User:       opts.User,
+		RollbackOf: release.Version,
+		Image:      release.Image,
+		TsuruYaml:  release.TsuruYaml,
+	})
+	return err
 }
@@ -0,0 +1,106 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+)
+
+// deployCancelWatcher checks the event of a deploy for cancellation requests,
+// asking the provisioner to cancel the deploy when one is found.
+type deployCancelWatcher struct {
+	opts     *DeployOptions
+	canceled int32
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+func newDeployCancelWatcher(opts *DeployOptions) *deployCancelWatcher {
+	return &deployCancelWatcher{opts: opts}
+}
+
+func (cw *deployCancelWatcher) cancelable() bool {
+	return cw.opts.Event != nil && cw.opts.Event.Cancelable
+}
+
+// check reports whether the cancellation of the deploy was requested.
+func (cw *deployCancelWatcher) check() bool {
+	if !cw.cancelable() {
+		return false
+	}
+	asked, err := cw.opts.Event.CancelAsked()
+	if err != nil {
+		log.Errorf("[deploy] unable to check cancellation of the deploy of %q: %s", cw.opts.App.Name, err)
+		return false
+	}
+	return asked
+}
+
+// ack acknowledges the cancellation in the event of the deploy.
+func (cw *deployCancelWatcher) ack() {
+	if _, err := cw.opts.Event.AckCancel(); err != nil {
+		log.Errorf("[deploy] unable to acknowledge cancellation of the deploy of %q: %s", cw.opts.App.Name, err)
+	}
+	atomic.StoreInt32(&cw.canceled, 1)
+}
+
+// cancel asks the provisioner to cancel the running deploy, reporting
+// whether it succeeded. The cancellation is only acknowledged when the
+// provisioner succeeds, so it may be retried otherwise.
+func (cw *deployCancelWatcher) cancel() bool {
+	prov, err := cw.opts.App.GetProvisioner()
+	if err == nil {
+		canceler, ok := prov.(provision.DeployCanceler)
+		if !ok {
+			log.Errorf("[deploy] unable to cancel the deploy of %q: the provisioner does not support it", cw.opts.App.Name)
+			return false
+		}
+		err = canceler.CancelDeploy(cw.opts.App)
+	}
+	if err != nil {
+		log.Errorf("[deploy] unable to cancel the deploy of %q: %s", cw.opts.App.Name, err)
+		return false
+	}
+	cw.ack()
+	return true
+}
+
+// start watches the event in background until stop is called or the
+// deploy is canceled. Failed cancellations are retried on every check.
+func (cw *deployCancelWatcher) start() {
+	if !cw.cancelable() {
+		return
+	}
+	cw.quit = make(chan struct{})
+	cw.done = make(chan struct{})
+	go func() {
+		defer close(cw.done)
+		ticker := time.NewTicker(deployCancelCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cw.quit:
+				return
+			case <-ticker.C:
+			}
+			if cw.check() && cw.cancel() {
+				return
+			}
+		}
+	}()
+}
+
+// stop stops watching the event, reporting whether the deploy was canceled.
+func (cw *deployCancelWatcher) stop() bool {
+	if cw.quit != nil {
+		close(cw.quit)
+		<-cw.done
+	}
+	return atomic.LoadInt32(&cw.canceled) == 1
+}
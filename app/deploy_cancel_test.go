@@ -0,0 +1,157 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newDeployEvent(c *check.C, appName string) *event.Event {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: appName},
+		InternalKind: "deploy",
+		Cancelable:   true,
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestDeployUsesEventID(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	evt := s.newDeployEvent(c, a.Name)
+	defer evt.Done(nil)
+	err := Deploy(DeployOptions{App: a, Image: "myimage", OutputStream: &bytes.Buffer{}, Event: evt})
+	c.Assert(err, check.IsNil)
+	deploy, err := GetDeploy(evt.UniqueID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.App, check.Equals, a.Name)
+	c.Assert(deploy.Image, check.Equals, "myimage")
+	c.Assert(deploy.Log, check.Equals, "Image deploy called")
+	c.Assert(deploy.Running, check.Equals, false)
+	c.Assert(deploy.Canceled, check.Equals, false)
+}
+
+func (s *S) TestDeployKeepsDiffWithEventID(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := SaveDiffData("the diff", a.Name)
+	c.Assert(err, check.IsNil)
+	evt := s.newDeployEvent(c, a.Name)
+	defer evt.Done(nil)
+	err = Deploy(DeployOptions{App: a, Image: "myimage", OutputStream: &bytes.Buffer{}, Event: evt})
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+	deploy, err := GetDeploy(evt.UniqueID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Diff, check.Equals, "the diff")
+}
+
+func (s *S) TestDeployCanceledBeforeStart(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	evt := s.newDeployEvent(c, a.Name)
+	defer evt.Done(nil)
+	err := evt.TryCancel("wrong branch", "admin@tsuru.io")
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	err = Deploy(DeployOptions{App: a, Image: "myimage", OutputStream: writer, Event: evt})
+	c.Assert(err, check.Equals, ErrDeployCanceled)
+	c.Assert(writer.String(), check.Equals, "")
+	deploy, err := GetDeploy(evt.UniqueID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Canceled, check.Equals, true)
+	c.Assert(deploy.Running, check.Equals, false)
+	c.Assert(deploy.Error, check.Equals, ErrDeployCanceled.Error())
+	c.Assert(a.Deploys, check.Equals, uint(1))
+	c.Assert(s.provisioner.Cancels(a), check.Equals, 0)
+}
+
+func (s *S) TestDeployCancelWatcher(c *check.C) {
+	defer func(interval time.Duration) {
+		deployCancelCheckInterval = interval
+	}(deployCancelCheckInterval)
+	deployCancelCheckInterval = 10 * time.Millisecond
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	evt := s.newDeployEvent(c, a.Name)
+	defer evt.Done(nil)
+	cw := newDeployCancelWatcher(&DeployOptions{App: a, Event: evt})
+	c.Assert(cw.check(), check.Equals, false)
+	cw.start()
+	err := evt.TryCancel("wrong branch", "admin@tsuru.io")
+	c.Assert(err, check.IsNil)
+	timeout := time.After(5 * time.Second)
+	for s.provisioner.Cancels(a) == 0 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for the deploy to be canceled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	c.Assert(cw.stop(), check.Equals, true)
+	c.Assert(s.provisioner.Cancels(a), check.Equals, 1)
+	got, err := event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.CancelInfo.Canceled, check.Equals, true)
+}
+
+func (s *S) TestDeployCancelWatcherCancelFailure(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	evt := s.newDeployEvent(c, a.Name)
+	defer evt.Done(nil)
+	cw := newDeployCancelWatcher(&DeployOptions{App: a, Event: evt})
+	err := evt.TryCancel("wrong branch", "admin@tsuru.io")
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareFailure("CancelDeploy", errors.New("no build container"))
+	c.Assert(cw.check(), check.Equals, true)
+	c.Assert(cw.cancel(), check.Equals, false)
+	c.Assert(cw.stop(), check.Equals, false)
+	c.Assert(s.provisioner.Cancels(a), check.Equals, 0)
+	got, err := event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.CancelInfo.Asked, check.Equals, true)
+	c.Assert(got.CancelInfo.Canceled, check.Equals, false)
+	c.Assert(cw.check(), check.Equals, true)
+}
+
+func (s *S) TestDeployCancelWatcherRetriesFailedCancel(c *check.C) {
+	defer func(interval time.Duration) {
+		deployCancelCheckInterval = interval
+	}(deployCancelCheckInterval)
+	deployCancelCheckInterval = 10 * time.Millisecond
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	evt := s.newDeployEvent(c, a.Name)
+	defer evt.Done(nil)
+	cw := newDeployCancelWatcher(&DeployOptions{App: a, Event: evt})
+	s.provisioner.PrepareFailure("CancelDeploy", errors.New("no build container"))
+	err := evt.TryCancel("wrong branch", "admin@tsuru.io")
+	c.Assert(err, check.IsNil)
+	cw.start()
+	timeout := time.After(5 * time.Second)
+	for s.provisioner.Cancels(a) == 0 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for the deploy to be canceled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	c.Assert(cw.stop(), check.Equals, true)
+	got, err := event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.CancelInfo.Canceled, check.Equals, true)
+}
+
+func (s *S) TestDeployCancelWatcherNotCancelable(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	cw := newDeployCancelWatcher(&DeployOptions{App: a})
+	c.Assert(cw.check(), check.Equals, false)
+	cw.start()
+	c.Assert(cw.stop(), check.Equals, false)
+}
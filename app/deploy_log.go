@@ -0,0 +1,91 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"io"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// flushDeployLog periodically stores the contents of the buffer as the log of
+// the running deploy with the given ID. The returned function stops the
+// flushing.
+func flushDeployLog(id bson.ObjectId, buf *safe.Buffer) func() {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(deployLogFlushInterval)
+		defer ticker.Stop()
+		var flushed int
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+			data := buf.String()
+			if len(data) == flushed {
+				continue
+			}
+			err := storeDeployLog(id, data)
+			if err != nil {
+				log.Errorf("[deploy] unable to store the log of the deploy %s: %s", id.Hex(), err)
+				continue
+			}
+			flushed = len(data)
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+func storeDeployLog(id bson.ObjectId, data string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Deploys().Update(
+		bson.M{"_id": id, "running": true},
+		bson.M{"$set": bson.M{"log": data}},
+	)
+}
+
+// FollowDeployLog writes the log of the given deploy to w. While the deploy is
+// running, new output is written as it's stored, until the deploy finishes or
+// the stop channel is closed.
+func FollowDeployLog(deploy *DeployData, w io.Writer, stop <-chan bool) error {
+	var written int
+	for {
+		if len(deploy.Log) > written {
+			_, err := io.WriteString(w, deploy.Log[written:])
+			if err != nil {
+				return err
+			}
+			written = len(deploy.Log)
+		}
+		if !deploy.Running {
+			return nil
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(deployLogFlushInterval):
+		}
+		var err error
+		deploy, err = GetDeploy(deploy.ID.Hex())
+		if err != nil {
+			return err
+		}
+	}
+}
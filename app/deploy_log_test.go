@@ -0,0 +1,89 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestFlushDeployLog(c *check.C) {
+	defer func(interval time.Duration) {
+		deployLogFlushInterval = interval
+	}(deployLogFlushInterval)
+	deployLogFlushInterval = 10 * time.Millisecond
+	id := bson.NewObjectId()
+	err := s.conn.Deploys().Insert(DeployData{ID: id, App: "myapp", Image: "diff", Running: true})
+	c.Assert(err, check.IsNil)
+	buf := safe.NewBuffer(nil)
+	stop := flushDeployLog(id, buf)
+	buf.WriteString("---- Building application image ----\n")
+	timeout := time.After(5 * time.Second)
+	for {
+		deploy, err := GetDeploy(id.Hex())
+		c.Assert(err, check.IsNil)
+		if deploy.Log != "" {
+			c.Assert(deploy.Log, check.Equals, "---- Building application image ----\n")
+			break
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for the deploy log to be stored")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	stop()
+}
+
+func (s *S) TestFlushDeployLogFinishedDeploy(c *check.C) {
+	id := bson.NewObjectId()
+	err := s.conn.Deploys().Insert(DeployData{ID: id, App: "myapp", Image: "app-image", Log: "final log"})
+	c.Assert(err, check.IsNil)
+	err = storeDeployLog(id, "partial log")
+	c.Assert(err, check.NotNil)
+	deploy, err := GetDeploy(id.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Log, check.Equals, "final log")
+}
+
+func (s *S) TestFollowDeployLogFinished(c *check.C) {
+	deploy := DeployData{ID: bson.NewObjectId(), App: "myapp", Log: "deploy log\nOK\n"}
+	var buf bytes.Buffer
+	err := FollowDeployLog(&deploy, &buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "deploy log\nOK\n")
+}
+
+func (s *S) TestFollowDeployLogRunning(c *check.C) {
+	defer func(interval time.Duration) {
+		deployLogFlushInterval = interval
+	}(deployLogFlushInterval)
+	deployLogFlushInterval = 10 * time.Millisecond
+	deploy := DeployData{ID: bson.NewObjectId(), App: "myapp", Image: "diff", Log: "first\n", Running: true}
+	err := s.conn.Deploys().Insert(deploy)
+	c.Assert(err, check.IsNil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.conn.Deploys().UpdateId(deploy.ID, bson.M{"$set": bson.M{"log": "first\nsecond\n", "running": false}})
+	}()
+	var buf bytes.Buffer
+	err = FollowDeployLog(&deploy, &buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "first\nsecond\n")
+}
+
+func (s *S) TestFollowDeployLogStop(c *check.C) {
+	deploy := DeployData{ID: bson.NewObjectId(), App: "myapp", Log: "first\n", Running: true}
+	stop := make(chan bool)
+	close(stop)
+	var buf bytes.Buffer
+	err := FollowDeployLog(&deploy, &buf, stop)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "first\n")
+}
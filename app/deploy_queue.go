@@ -0,0 +1,397 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	stderr "errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/tsuru/monsterqueue"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/periodic"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	deployQueueTaskName = "deploy-queue"
+
+	// DeployQueueLockReason is the reason of the app locks acquired to run
+	// queued deploys.
+	DeployQueueLockReason = "queued deploy"
+)
+
+var (
+	ErrDeployNotQueueable   = stderr.New("deploys uploading files cannot be queued")
+	ErrQueuedDeployNotFound = stderr.New("queued deploy not found")
+
+	// deployQueueRetryInterval is how long a queued deploy waits before
+	// trying again to acquire the lock of its app.
+	deployQueueRetryInterval = 30 * time.Second
+
+	// deployQueueMaxAttempts is how many times a queued deploy tries to
+	// acquire the lock of its app before being dropped from the queue.
+	deployQueueMaxAttempts = 120
+
+	deployLockRegexp = regexp.MustCompile(`/apps/[^/]+/(deploy|repository/clone)$`)
+)
+
+// QueuedDeploy is a deploy waiting for the lock of its app, held by another
+// deploy, to be released. The oldest deploy in the queue of a locked app
+// holds the time of the next attempt to run the queue.
+type QueuedDeploy struct {
+	ID           bson.ObjectId `bson:"_id"`
+	App          string
+	Kind         DeployKind
+	Commit       string
+	ArchiveURL   string
+	Image        string
+	Origin       string
+	User         string
+	CanaryWeight int
+	Timestamp    time.Time
+	Attempts     int       `bson:",omitempty"`
+	NextAttempt  time.Time `bson:",omitempty"`
+}
+
+// IsDeploy reports whether the lock is held by a deploy of the app.
+func (l *AppLock) IsDeploy() bool {
+	return l.Locked && (l.Reason == DeployQueueLockReason || deployLockRegexp.MatchString(l.Reason))
+}
+
+// SetDeployQueue enables or disables the deploy queue of the app. Deploys
+// already queued are kept when the queue is disabled.
+func (app *App) SetDeployQueue(enabled bool) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"deployqueue": enabled}})
+	if err != nil {
+		return err
+	}
+	app.DeployQueue = enabled
+	return nil
+}
+
+// EnqueueDeploy queues a deploy to be executed once the app is unlocked. Any
+// pending deploy of the same kind is superseded by the new one, as only the
+// latest would be kept in the app after running all of them.
+func EnqueueDeploy(opts DeployOptions) (*QueuedDeploy, error) {
+	if opts.File != nil {
+		return nil, ErrDeployNotQueueable
+	}
+	qd := QueuedDeploy{
+		ID:           bson.NewObjectId(),
+		App:          opts.App.Name,
+		Kind:         opts.Kind(),
+		Commit:       opts.Commit,
+		ArchiveURL:   opts.ArchiveURL,
+		Image:        opts.Image,
+		Origin:       opts.Origin,
+		User:         opts.User,
+		CanaryWeight: opts.CanaryWeight,
+		Timestamp:    time.Now().UTC(),
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_, err = conn.DeployQueue().RemoveAll(bson.M{"app": qd.App, "kind": qd.Kind})
+	if err != nil {
+		return nil, err
+	}
+	err = conn.DeployQueue().Insert(qd)
+	if err != nil {
+		return nil, err
+	}
+	err = enqueueDeployQueueTask(qd.App)
+	if err != nil {
+		return nil, err
+	}
+	return &qd, nil
+}
+
+// ListQueuedDeploys returns the deploys of the app waiting in its queue, in
+// the order they will be executed.
+func ListQueuedDeploys(appName string) ([]QueuedDeploy, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var deploys []QueuedDeploy
+	err = conn.DeployQueue().Find(bson.M{"app": appName}).Sort("timestamp").All(&deploys)
+	if err != nil {
+		return nil, err
+	}
+	return deploys, nil
+}
+
+// RemoveQueuedDeploy drops a deploy from the queue of the app.
+func RemoveQueuedDeploy(appName, id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrQueuedDeployNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.DeployQueue().Remove(bson.M{"_id": bson.ObjectIdHex(id), "app": appName})
+	if err == mgo.ErrNotFound {
+		return ErrQueuedDeployNotFound
+	}
+	return err
+}
+
+func removeQueuedDeploys(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.DeployQueue().RemoveAll(bson.M{"app": appName})
+	return err
+}
+
+// takeQueuedDeploy removes the oldest deploy from the queue of the app,
+// returning nil if the queue is empty.
+func takeQueuedDeploy(appName string) (*QueuedDeploy, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var qd QueuedDeploy
+	_, err = conn.DeployQueue().Find(bson.M{"app": appName}).Sort("timestamp").Apply(mgo.Change{Remove: true}, &qd)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &qd, nil
+}
+
+// runQueuedDeploy runs the oldest deploy in the queue of the app, if the app
+// is not locked. Otherwise, the queue is retried later by the deploy queue
+// retrier.
+func runQueuedDeploy(appName string) error {
+	locked, err := AcquireApplicationLock(appName, "tsuru", DeployQueueLockReason)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return delayQueuedDeploys(appName)
+	}
+	ran, deployErr := runOldestQueuedDeploy(appName)
+	ReleaseApplicationLock(appName)
+	if ran {
+		err = continueDeployQueue(appName)
+	}
+	if deployErr != nil {
+		return deployErr
+	}
+	return err
+}
+
+// runOldestQueuedDeploy takes the oldest deploy from the queue of the app,
+// already locked, and runs it. It reports whether a deploy was run.
+func runOldestQueuedDeploy(appName string) (bool, error) {
+	qd, err := takeQueuedDeploy(appName)
+	if err != nil || qd == nil {
+		return false, err
+	}
+	a, err := GetByName(appName)
+	if err != nil {
+		return false, err
+	}
+	opts := DeployOptions{
+		App:          a,
+		Commit:       qd.Commit,
+		ArchiveURL:   qd.ArchiveURL,
+		Image:        qd.Image,
+		Origin:       qd.Origin,
+		User:         qd.User,
+		CanaryWeight: qd.CanaryWeight,
+	}
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: appName},
+		InternalKind: "queued-deploy",
+		CustomData:   qd,
+		Cancelable:   opts.Kind().Cancelable(),
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			return false, requeueDeploy(qd)
+		}
+		return false, err
+	}
+	opts.OutputStream = evt
+	opts.Event = evt
+	err = Deploy(opts)
+	evt.Done(err)
+	return true, err
+}
+
+// continueDeployQueue enqueues the task running the next deploy in the queue
+// of the app, if there is one.
+func continueDeployQueue(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	count, err := conn.DeployQueue().Find(bson.M{"app": appName}).Count()
+	if err != nil || count == 0 {
+		return err
+	}
+	return enqueueDeployQueueTask(appName)
+}
+
+// requeueDeploy puts back a deploy taken from the queue, keeping its place,
+// and delays the queue of its app.
+func requeueDeploy(qd *QueuedDeploy) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.DeployQueue().Insert(qd)
+	if err != nil {
+		return err
+	}
+	return delayQueuedDeploys(qd.App)
+}
+
+// delayQueuedDeploys schedules the next attempt to run the queue of the app.
+// The oldest deploy in the queue is dropped when it has already tried too
+// many times.
+func delayQueuedDeploys(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		var qd QueuedDeploy
+		err = conn.DeployQueue().Find(bson.M{"app": appName}).Sort("timestamp").One(&qd)
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if qd.Attempts+1 < deployQueueMaxAttempts {
+			err = conn.DeployQueue().UpdateId(qd.ID, bson.M{
+				"$inc": bson.M{"attempts": 1},
+				"$set": bson.M{"nextattempt": time.Now().UTC().Add(deployQueueRetryInterval)},
+			})
+			if err != mgo.ErrNotFound {
+				return err
+			}
+			continue
+		}
+		log.Errorf("[deploy queue] dropping queued deploy %s of %q, the app remained locked after %d attempts", qd.ID.Hex(), appName, qd.Attempts+1)
+		err = conn.DeployQueue().RemoveId(qd.ID)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+	}
+}
+
+// NewDeployQueueRetrier returns a loop enqueueing again the tasks of the
+// deploy queues whose next attempt is due.
+func NewDeployQueueRetrier() *periodic.Loop {
+	return &periodic.Loop{
+		Name:     "deploy queue retrier",
+		Interval: 10 * time.Second,
+		Task:     retryQueuedDeploys,
+	}
+}
+
+// retryQueuedDeploys enqueues the tasks of the due deploy queues. Each of
+// them is claimed by clearing its next attempt, so only one API instance
+// enqueues it.
+func retryQueuedDeploys() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var deploys []QueuedDeploy
+	query := bson.M{"nextattempt": bson.M{"$gt": time.Time{}, "$lte": time.Now().UTC()}}
+	err = conn.DeployQueue().Find(query).Select(bson.M{"app": 1, "nextattempt": 1}).All(&deploys)
+	if err != nil {
+		return fmt.Errorf("error getting queued deploys: %s", err)
+	}
+	for _, qd := range deploys {
+		err = conn.DeployQueue().Update(
+			bson.M{"_id": qd.ID, "nextattempt": qd.NextAttempt},
+			bson.M{"$unset": bson.M{"nextattempt": ""}},
+		)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			log.Errorf("[deploy queue] unable to claim the queue of %q: %s", qd.App, err)
+			continue
+		}
+		err = enqueueDeployQueueTask(qd.App)
+		if err != nil {
+			log.Errorf("[deploy queue] unable to enqueue the queue of %q: %s", qd.App, err)
+			conn.DeployQueue().UpdateId(qd.ID, bson.M{"$set": bson.M{"nextattempt": qd.NextAttempt}})
+		}
+	}
+	return nil
+}
+
+type deployQueueTask struct{}
+
+func (t *deployQueueTask) Name() string {
+	return deployQueueTaskName
+}
+
+func (t *deployQueueTask) Run(job monsterqueue.Job) {
+	appName, ok := job.Parameters()["app"].(string)
+	if !ok {
+		job.Error(stderr.New("invalid parameters, expected app"))
+		return
+	}
+	err := runQueuedDeploy(appName)
+	if err != nil {
+		log.Errorf("[deploy queue] unable to run queued deploy of %q: %s", appName, err)
+		job.Error(err)
+		return
+	}
+	job.Success(nil)
+}
+
+// RegisterDeployQueueTask registers the task running queued deploys in the
+// tsuru queue.
+func RegisterDeployQueueTask() error {
+	q, err := queue.Queue()
+	if err != nil {
+		return err
+	}
+	return q.RegisterTask(&deployQueueTask{})
+}
+
+func enqueueDeployQueueTask(appName string) error {
+	q, err := queue.Queue()
+	if err != nil {
+		return err
+	}
+	_, err = q.Enqueue(deployQueueTaskName, monsterqueue.JobParams{"app": appName})
+	return err
+}
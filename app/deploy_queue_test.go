@@ -0,0 +1,227 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAppLockIsDeploy(c *check.C) {
+	var tests = []struct {
+		lock     AppLock
+		expected bool
+	}{
+		{AppLock{Locked: true, Reason: "POST /apps/myapp/deploy"}, true},
+		{AppLock{Locked: true, Reason: "POST /apps/myapp/repository/clone"}, true},
+		{AppLock{Locked: true, Reason: DeployQueueLockReason}, true},
+		{AppLock{Locked: true, Reason: "POST /apps/myapp/units"}, false},
+		{AppLock{Locked: true, Reason: "POST /apps/myapp/deploy/rollback"}, false},
+		{AppLock{Locked: false, Reason: "POST /apps/myapp/deploy"}, false},
+	}
+	for _, t := range tests {
+		c.Check(t.lock.IsDeploy(), check.Equals, t.expected, check.Commentf("reason: %q", t.lock.Reason))
+	}
+}
+
+func (s *S) TestSetDeployQueue(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetDeployQueue(true)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.DeployQueue, check.Equals, true)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployQueue, check.Equals, true)
+	err = a.SetDeployQueue(false)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployQueue, check.Equals, false)
+}
+
+func (s *S) TestEnqueueDeploy(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	qd, err := EnqueueDeploy(DeployOptions{App: a, Image: "myimage", User: "someone@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	c.Assert(qd.App, check.Equals, a.Name)
+	c.Assert(qd.Kind, check.Equals, DeployImage)
+	c.Assert(qd.Image, check.Equals, "myimage")
+	c.Assert(qd.User, check.Equals, "someone@tsuru.io")
+	deploys, err := ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 1)
+	c.Assert(deploys[0].ID, check.Equals, qd.ID)
+}
+
+func (s *S) TestEnqueueDeployCollapsesSameKind(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	_, err := EnqueueDeploy(DeployOptions{App: a, Image: "myimage:v1"})
+	c.Assert(err, check.IsNil)
+	archive, err := EnqueueDeploy(DeployOptions{App: a, ArchiveURL: "http://example.com/app.tar.gz"})
+	c.Assert(err, check.IsNil)
+	image, err := EnqueueDeploy(DeployOptions{App: a, Image: "myimage:v2"})
+	c.Assert(err, check.IsNil)
+	deploys, err := ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 2)
+	c.Assert(deploys[0].ID, check.Equals, archive.ID)
+	c.Assert(deploys[1].ID, check.Equals, image.ID)
+	c.Assert(deploys[1].Image, check.Equals, "myimage:v2")
+}
+
+func (s *S) TestEnqueueDeployWithFile(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	_, err := EnqueueDeploy(DeployOptions{App: a, File: ioutil.NopCloser(bytes.NewBuffer([]byte("my file")))})
+	c.Assert(err, check.Equals, ErrDeployNotQueueable)
+	deploys, err := ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 0)
+}
+
+func (s *S) TestRemoveQueuedDeploy(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	qd, err := EnqueueDeploy(DeployOptions{App: a, Image: "myimage"})
+	c.Assert(err, check.IsNil)
+	err = RemoveQueuedDeploy("otherapp", qd.ID.Hex())
+	c.Assert(err, check.Equals, ErrQueuedDeployNotFound)
+	err = RemoveQueuedDeploy(a.Name, qd.ID.Hex())
+	c.Assert(err, check.IsNil)
+	deploys, err := ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 0)
+	err = RemoveQueuedDeploy(a.Name, qd.ID.Hex())
+	c.Assert(err, check.Equals, ErrQueuedDeployNotFound)
+	err = RemoveQueuedDeploy(a.Name, "invalid")
+	c.Assert(err, check.Equals, ErrQueuedDeployNotFound)
+}
+
+func (s *S) TestTakeQueuedDeploy(c *check.C) {
+	now := time.Now().UTC()
+	first := QueuedDeploy{ID: bson.NewObjectId(), App: "myapp", Kind: DeployImage, Timestamp: now.Add(-time.Minute)}
+	second := QueuedDeploy{ID: bson.NewObjectId(), App: "myapp", Kind: DeployGit, Timestamp: now}
+	err := s.conn.DeployQueue().Insert(second, first)
+	c.Assert(err, check.IsNil)
+	qd, err := takeQueuedDeploy("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(qd.ID, check.Equals, first.ID)
+	qd, err = takeQueuedDeploy("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(qd.ID, check.Equals, second.ID)
+	qd, err = takeQueuedDeploy("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(qd, check.IsNil)
+}
+
+func (s *S) TestRunQueuedDeploy(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	_, err := EnqueueDeploy(DeployOptions{App: a, Image: "myimage", User: "someone@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	err = runQueuedDeploy(a.Name)
+	c.Assert(err, check.IsNil)
+	deploys, err := ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 0)
+	var deploy DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Image, check.Equals, "myimage")
+	c.Assert(deploy.User, check.Equals, "someone@tsuru.io")
+	c.Assert(deploy.Log, check.Equals, "Image deploy called")
+	evt, err := event.GetByID(deploy.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Running, check.Equals, false)
+	c.Assert(evt.Kind.Name, check.Equals, "queued-deploy")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Lock.Locked, check.Equals, false)
+}
+
+func (s *S) TestRunQueuedDeployEmptyQueue(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := runQueuedDeploy(a.Name)
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Lock.Locked, check.Equals, false)
+}
+
+func (s *S) TestRunQueuedDeployLockedApp(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	locked, err := AcquireApplicationLock(a.Name, "someone@tsuru.io", "POST /apps/myapp/deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer ReleaseApplicationLock(a.Name)
+	_, err = EnqueueDeploy(DeployOptions{App: a, Image: "myimage"})
+	c.Assert(err, check.IsNil)
+	err = runQueuedDeploy(a.Name)
+	c.Assert(err, check.IsNil)
+	deploys, err := ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 1)
+	c.Assert(deploys[0].Attempts, check.Equals, 1)
+	c.Assert(deploys[0].NextAttempt.After(time.Now()), check.Equals, true)
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestRunQueuedDeployLockedAppMaxAttempts(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	locked, err := AcquireApplicationLock(a.Name, "someone@tsuru.io", "POST /apps/myapp/deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer ReleaseApplicationLock(a.Name)
+	now := time.Now().UTC()
+	err = s.conn.DeployQueue().Insert(
+		QueuedDeploy{ID: bson.NewObjectId(), App: a.Name, Kind: DeployImage, Timestamp: now.Add(-time.Minute), Attempts: deployQueueMaxAttempts - 1},
+		QueuedDeploy{ID: bson.NewObjectId(), App: a.Name, Kind: DeployGit, Timestamp: now},
+	)
+	c.Assert(err, check.IsNil)
+	err = runQueuedDeploy(a.Name)
+	c.Assert(err, check.IsNil)
+	deploys, err := ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 1)
+	c.Assert(deploys[0].Kind, check.Equals, DeployGit)
+	c.Assert(deploys[0].Attempts, check.Equals, 1)
+}
+
+func (s *S) TestRetryQueuedDeploys(c *check.C) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	due := QueuedDeploy{ID: bson.NewObjectId(), App: "myapp", Kind: DeployImage, Timestamp: now, NextAttempt: now.Add(-time.Second)}
+	notDue := QueuedDeploy{ID: bson.NewObjectId(), App: "otherapp", Kind: DeployImage, Timestamp: now, NextAttempt: now.Add(time.Hour)}
+	err := s.conn.DeployQueue().Insert(due, notDue)
+	c.Assert(err, check.IsNil)
+	err = retryQueuedDeploys()
+	c.Assert(err, check.IsNil)
+	var stored QueuedDeploy
+	err = s.conn.DeployQueue().FindId(due.ID).One(&stored)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.NextAttempt.IsZero(), check.Equals, true)
+	err = s.conn.DeployQueue().FindId(notDue.ID).One(&stored)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.NextAttempt.Equal(notDue.NextAttempt), check.Equals, true)
+}
+
+func (s *S) TestRemoveQueuedDeploys(c *check.C) {
+	err := s.conn.DeployQueue().Insert(
+		QueuedDeploy{ID: bson.NewObjectId(), App: "myapp", Kind: DeployImage},
+		QueuedDeploy{ID: bson.NewObjectId(), App: "otherapp", Kind: DeployImage},
+	)
+	c.Assert(err, check.IsNil)
+	err = removeQueuedDeploys("myapp")
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.DeployQueue().Find(nil).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+}
@@ -649,6 +649,26 @@ func (s *S) TestDeployToProvisionerUpload(c *check.C) {
 	c.Assert(logs, check.Equals, "Upload deploy called")
 }
 
+func (s *S) TestDeployToProvisionerDockerfile(c *check.C) {
+	a := App{
+		Name:     "someApp",
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	writer := &bytes.Buffer{}
+	opts := DeployOptions{App: &a, File: ioutil.NopCloser(bytes.NewBuffer([]byte("my file"))), Dockerfile: true}
+	imageId, err := deployToProvisioner(&opts, writer)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageId, check.Equals, "app-image")
+	logs := writer.String()
+	c.Assert(logs, check.Equals, "Dockerfile deploy called")
+}
+
 func (s *S) TestDeployToProvisionerImage(c *check.C) {
 	a := App{
 		Name:     "someApp",
@@ -862,6 +882,10 @@ func (s *S) TestDeployKind(c *check.C) {
 			DeployOptions{Image: "quay.io/tsuru/python"},
 			DeployImage,
 		},
+		{
+			DeployOptions{Image: "tsuru/app-myapp:v1", PromoteFrom: "myapp"},
+			DeployPromote,
+		},
 		{
 			DeployOptions{File: ioutil.NopCloser(bytes.NewBuffer(nil))},
 			DeployUpload,
@@ -870,6 +894,10 @@ func (s *S) TestDeployKind(c *check.C) {
 			DeployOptions{File: ioutil.NopCloser(bytes.NewBuffer(nil)), Build: true},
 			DeployUploadBuild,
 		},
+		{
+			DeployOptions{File: ioutil.NopCloser(bytes.NewBuffer(nil)), Dockerfile: true},
+			DeployDockerfile,
+		},
 		{
 			DeployOptions{Commit: "abcef48439"},
 			DeployGit,
@@ -883,3 +911,22 @@ func (s *S) TestDeployKind(c *check.C) {
 		c.Check(t.input.Kind(), check.Equals, t.expected)
 	}
 }
+
+func (s *S) TestDeployKindCancelable(c *check.C) {
+	var tests = []struct {
+		kind       DeployKind
+		cancelable bool
+	}{
+		{DeployArchiveURL, true},
+		{DeployGit, true},
+		{DeployUpload, true},
+		{DeployUploadBuild, true},
+		{DeployDockerfile, false},
+		{DeployImage, false},
+		{DeployPromote, false},
+		{DeployRollback, false},
+	}
+	for _, t := range tests {
+		c.Check(t.kind.Cancelable(), check.Equals, t.cancelable, check.Commentf("kind %s", t.kind))
+	}
+}
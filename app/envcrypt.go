@@ -0,0 +1,386 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	envKeySize = 32
+
+	maxEnvReencryptRetries = 3
+)
+
+var ErrEnvEncryptionDisabled = errors.New("envs:encryption:current-key is not configured")
+
+// EnvKey is the data key used to encrypt the values of the private
+// environment variables of an app. The key is stored encrypted by one of the
+// master keys, defined in the tsuru config file.
+type EnvKey struct {
+	MasterKeyID string
+	Key         []byte
+}
+
+// envMasterKeys returns the id of the master key used to encrypt new data
+// keys and all the master keys, indexed by id. An empty id means the
+// encryption of envs is disabled.
+func envMasterKeys() (string, map[string][]byte, error) {
+	current, _ := config.GetString("envs:encryption:current-key")
+	keys := map[string][]byte{}
+	rawKeys, err := config.Get("envs:encryption:keys")
+	if err != nil {
+		if current != "" {
+			return "", nil, fmt.Errorf("envs:encryption:keys must contain the key %q", current)
+		}
+		return "", keys, nil
+	}
+	keyMap, ok := rawKeys.(map[interface{}]interface{})
+	if !ok {
+		return "", nil, errors.New("envs:encryption:keys must be a map of key ids to keys")
+	}
+	for id, value := range keyMap {
+		idStr := fmt.Sprint(id)
+		key, err := base64.StdEncoding.DecodeString(fmt.Sprint(value))
+		if err != nil || len(key) != envKeySize {
+			return "", nil, fmt.Errorf("envs:encryption:keys: key %q must be %d base64 encoded bytes", idStr, envKeySize)
+		}
+		keys[idStr] = key
+	}
+	if _, ok := keys[current]; current != "" && !ok {
+		return "", nil, fmt.Errorf("envs:encryption:keys must contain the key %q", current)
+	}
+	return current, keys, nil
+}
+
+// GenerateEnvMasterKey returns a new random master key, base64 encoded, in
+// the format expected by envs:encryption:keys.
+func GenerateEnvMasterKey() (string, error) {
+	key := make([]byte, envKeySize)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func sealEnvData(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newEnvCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func openEnvData(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newEnvCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted data")
+	}
+	nonce := ciphertext[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], additionalData)
+}
+
+func newEnvCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// dataKey returns the plain data key of the app. When the app doesn't have
+// a key yet and create is true, a new one is generated and encrypted with
+// the current master key. A nil key means encryption is disabled.
+func (app *App) dataKey(create bool) ([]byte, error) {
+	current, masterKeys, err := envMasterKeys()
+	if err != nil {
+		return nil, err
+	}
+	if app.EnvKey != nil {
+		masterKey, ok := masterKeys[app.EnvKey.MasterKeyID]
+		if !ok {
+			return nil, fmt.Errorf("master key %q used by the app %q is not in envs:encryption:keys", app.EnvKey.MasterKeyID, app.Name)
+		}
+		key, err := openEnvData(masterKey, app.EnvKey.Key, []byte(app.Name))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt the env key of the app %q: %s", app.Name, err)
+		}
+		return key, nil
+	}
+	if !create || current == "" {
+		return nil, nil
+	}
+	key := make([]byte, envKeySize)
+	_, err = io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealEnvData(masterKeys[current], key, []byte(app.Name))
+	if err != nil {
+		return nil, err
+	}
+	app.EnvKey = &EnvKey{MasterKeyID: current, Key: sealed}
+	return key, nil
+}
+
+func (app *App) envAdditionalData(name string) []byte {
+	return []byte(app.Name + "/" + name)
+}
+
+// encryptEnvs encrypts the values of the private variables in envs, when
+// the encryption of envs is enabled.
+func (app *App) encryptEnvs(envs []bind.EnvVar) error {
+	var key []byte
+	for i := range envs {
+		envs[i].Encrypted = false
+		if envs[i].Public {
+			continue
+		}
+		if key == nil {
+			var err error
+			key, err = app.dataKey(true)
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				return nil
+			}
+		}
+		sealed, err := sealEnvData(key, []byte(envs[i].Value), app.envAdditionalData(envs[i].Name))
+		if err != nil {
+			return err
+		}
+		envs[i].Value = base64.StdEncoding.EncodeToString(sealed)
+		envs[i].Encrypted = true
+	}
+	return nil
+}
+
+func (app *App) decryptEnv(env bind.EnvVar, key []byte) (bind.EnvVar, error) {
+	if !env.Encrypted {
+		return env, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(env.Value)
+	if err != nil {
+		return env, fmt.Errorf("unable to decrypt env %q: %s", env.Name, err)
+	}
+	value, err := openEnvData(key, sealed, app.envAdditionalData(env.Name))
+	if err != nil {
+		return env, fmt.Errorf("unable to decrypt env %q: %s", env.Name, err)
+	}
+	env.Value = string(value)
+	env.Encrypted = false
+	return env, nil
+}
+
+// DecryptedEnvs returns the environment variables of the app with the
+// values of private variables in plain text. It should only be used when
+// the values are sent to the units of the app or to authorized users.
+func (app *App) DecryptedEnvs() (map[string]bind.EnvVar, error) {
+	envs := make(map[string]bind.EnvVar, len(app.Env))
+	var key []byte
+	for name, env := range app.Env {
+		if env.Encrypted && key == nil {
+			var err error
+			key, err = app.dataKey(false)
+			if err != nil {
+				return nil, err
+			}
+			if key == nil {
+				return nil, fmt.Errorf("the app %q has encrypted envs but no env key", app.Name)
+			}
+		}
+		decrypted, err := app.decryptEnv(env, key)
+		if err != nil {
+			return nil, err
+		}
+		envs[name] = decrypted
+	}
+	return envs, nil
+}
+
+// decryptedEnvValue returns the plain value of one environment variable of
+// the app, or an empty string if it isn't defined.
+func (app *App) decryptedEnvValue(name string) (string, error) {
+	env, ok := app.Env[name]
+	if !ok || !env.Encrypted {
+		return env.Value, nil
+	}
+	key, err := app.dataKey(false)
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", fmt.Errorf("the app %q has encrypted envs but no env key", app.Name)
+	}
+	env, err = app.decryptEnv(env, key)
+	return env.Value, err
+}
+
+// reencryptEnvs decrypts all the environment variables of the app and
+// encrypts the private ones again with a new data key, protected by the
+// current master key, along with the keys of the certificates of the app.
+func (app *App) reencryptEnvs() error {
+	envs, err := app.DecryptedEnvs()
+	if err != nil {
+		return err
+	}
+	certificateKeys := make([]string, len(app.Certificates))
+	for i, cert := range app.Certificates {
+		certificateKeys[i], err = app.decryptCertificateKey(cert)
+		if err != nil {
+			return err
+		}
+	}
+	current, _, err := envMasterKeys()
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return ErrEnvEncryptionDisabled
+	}
+	app.EnvKey = nil
+	list := make([]bind.EnvVar, 0, len(envs))
+	for _, env := range envs {
+		list = append(list, env)
+	}
+	err = app.encryptEnvs(list)
+	if err != nil {
+		return err
+	}
+	app.Env = make(map[string]bind.EnvVar, len(list))
+	for _, env := range list {
+		app.Env[env.Name] = env
+	}
+	for i, key := range certificateKeys {
+		app.Certificates[i].Key, err = app.encryptCertificateKey(app.Certificates[i].CName, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReencryptAllEnvs encrypts the private environment variables of all apps
+// and their releases with new data keys, protected by the current master
+// key. It's used to encrypt envs stored before the encryption was enabled
+// and to rotate the master key, after which the previous master key may be
+// removed from the config. It returns the number of apps updated.
+func ReencryptAllEnvs(w io.Writer) (int, error) {
+	current, _, err := envMasterKeys()
+	if err != nil {
+		return 0, err
+	}
+	if current == "" {
+		return 0, ErrEnvEncryptionDisabled
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	var names []string
+	err = conn.Apps().Find(nil).Distinct("name", &names)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, name := range names {
+		err = reencryptAppEnvs(name)
+		if err == ErrAppNotFound {
+			continue
+		}
+		if err != nil {
+			return count, fmt.Errorf("unable to re-encrypt envs of the app %q: %s", name, err)
+		}
+		err = reencryptReleaseEnvs(name)
+		if err != nil {
+			return count, fmt.Errorf("unable to re-encrypt envs of the releases of the app %q: %s", name, err)
+		}
+		count++
+		if w != nil {
+			fmt.Fprintf(w, "envs of the app %q re-encrypted\n", name)
+		}
+	}
+	return count, nil
+}
+
+// reencryptAppEnvs re-encrypts the envs of the app, retrying if they're
+// changed by someone else in the meantime. Changes are detected by matching
+// the envs and certificates read, as they may change without the key.
+func reencryptAppEnvs(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for i := 0; ; i++ {
+		var doc bson.Raw
+		err = conn.Apps().Find(bson.M{"name": name}).One(&doc)
+		if err == mgo.ErrNotFound {
+			return ErrAppNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var a App
+		err = doc.Unmarshal(&a)
+		if err != nil {
+			return err
+		}
+		var read struct {
+			Env          bson.Raw
+			Certificates bson.Raw
+		}
+		err = doc.Unmarshal(&read)
+		if err != nil {
+			return err
+		}
+		query := bson.M{
+			"name":         a.Name,
+			"envkey":       a.EnvKey,
+			"env":          rawFieldQuery(read.Env),
+			"certificates": rawFieldQuery(read.Certificates),
+		}
+		err = a.reencryptEnvs()
+		if err != nil {
+			return err
+		}
+		err = conn.Apps().Update(query, bson.M{"$set": bson.M{"env": a.Env, "envkey": a.EnvKey, "certificates": a.Certificates}})
+		if err != mgo.ErrNotFound || i == maxEnvReencryptRetries-1 {
+			return err
+		}
+	}
+}
+
+// rawFieldQuery matches a field with the exact value read, preserving the
+// order of the keys of documents.
+func rawFieldQuery(value bson.Raw) interface{} {
+	if value.Kind == 0 {
+		return bson.M{"$exists": false}
+	}
+	return value
+}
@@ -0,0 +1,210 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	testEnvKey1 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("1"), envKeySize))
+	testEnvKey2 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("2"), envKeySize))
+)
+
+func setEnvMasterKeys(current string, keys map[string]string) func() {
+	rawKeys := map[interface{}]interface{}{}
+	for id, key := range keys {
+		rawKeys[id] = key
+	}
+	config.Set("envs:encryption:current-key", current)
+	config.Set("envs:encryption:keys", rawKeys)
+	return func() {
+		config.Unset("envs:encryption")
+	}
+}
+
+func (s *S) TestEnvMasterKeys(c *check.C) {
+	current, keys, err := envMasterKeys()
+	c.Assert(err, check.IsNil)
+	c.Assert(current, check.Equals, "")
+	c.Assert(keys, check.HasLen, 0)
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1, "key2": testEnvKey2})()
+	current, keys, err = envMasterKeys()
+	c.Assert(err, check.IsNil)
+	c.Assert(current, check.Equals, "key1")
+	c.Assert(keys, check.DeepEquals, map[string][]byte{
+		"key1": bytes.Repeat([]byte("1"), envKeySize),
+		"key2": bytes.Repeat([]byte("2"), envKeySize),
+	})
+}
+
+func (s *S) TestEnvMasterKeysInvalid(c *check.C) {
+	defer setEnvMasterKeys("key3", map[string]string{"key1": testEnvKey1})()
+	_, _, err := envMasterKeys()
+	c.Assert(err, check.ErrorMatches, `envs:encryption:keys must contain the key "key3"`)
+	setEnvMasterKeys("key1", map[string]string{"key1": "c2hvcnQ="})
+	_, _, err = envMasterKeys()
+	c.Assert(err, check.ErrorMatches, `envs:encryption:keys: key "key1" must be 32 base64 encoded bytes`)
+}
+
+func (s *S) TestGenerateEnvMasterKey(c *check.C) {
+	key, err := GenerateEnvMasterKey()
+	c.Assert(err, check.IsNil)
+	data, err := base64.StdEncoding.DecodeString(key)
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.HasLen, envKeySize)
+	other, err := GenerateEnvMasterKey()
+	c.Assert(err, check.IsNil)
+	c.Assert(other, check.Not(check.Equals), key)
+}
+
+func (s *S) TestSetEnvsEncryptsPrivateValues(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_PASSWORD", Value: "secret"},
+			{Name: "DEBUG", Value: "1", Public: true},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.EnvKey, check.NotNil)
+	c.Assert(stored.EnvKey.MasterKeyID, check.Equals, "key1")
+	password := stored.Env["DATABASE_PASSWORD"]
+	c.Assert(password.Encrypted, check.Equals, true)
+	c.Assert(password.Value, check.Not(check.Equals), "secret")
+	c.Assert(stored.Env["DEBUG"], check.DeepEquals, bind.EnvVar{Name: "DEBUG", Value: "1", Public: true})
+	envs, err := stored.DecryptedEnvs()
+	c.Assert(err, check.IsNil)
+	c.Assert(envs, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "secret"},
+		"DEBUG":             {Name: "DEBUG", Value: "1", Public: true},
+	})
+	err = stored.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "API_KEY", Value: "key"}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	stored, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.EnvKey.Key, check.DeepEquals, a.EnvKey.Key)
+	envs, err = stored.DecryptedEnvs()
+	c.Assert(err, check.IsNil)
+	c.Assert(envs["API_KEY"].Value, check.Equals, "key")
+	c.Assert(envs["DATABASE_PASSWORD"].Value, check.Equals, "secret")
+}
+
+func (s *S) TestSetEnvsEncryptionDisabled(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "secret", Encrypted: true}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.EnvKey, check.IsNil)
+	c.Assert(stored.Env["DATABASE_PASSWORD"], check.DeepEquals, bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "secret"})
+}
+
+func (s *S) TestDecryptedEnvsMissingMasterKey(c *check.C) {
+	restore := setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "secret"}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	setEnvMasterKeys("key2", map[string]string{"key2": testEnvKey2})
+	defer restore()
+	_, err = a.DecryptedEnvs()
+	c.Assert(err, check.ErrorMatches, `master key "key1" used by the app "myapp" is not in envs:encryption:keys`)
+}
+
+func (s *S) TestDecryptedEnvsTamperedValue(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_PASSWORD", Value: "secret"},
+			{Name: "API_KEY", Value: "key"},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	env := a.Env["API_KEY"]
+	env.Value = a.Env["DATABASE_PASSWORD"].Value
+	a.Env["API_KEY"] = env
+	_, err = a.DecryptedEnvs()
+	c.Assert(err, check.ErrorMatches, `unable to decrypt env "API_KEY": .*`)
+}
+
+func (s *S) TestAddInstanceWithEncryptedEnvs(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddInstance(bind.InstanceApp{
+		ServiceName: "mysql",
+		Instance:    bind.ServiceInstance{Name: "mydb", Envs: map[string]string{"DATABASE_HOST": "localhost"}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Env["DATABASE_HOST"].Encrypted, check.Equals, true)
+	c.Assert(a.Env[TsuruServicesEnvVar].Encrypted, check.Equals, true)
+	err = a.AddInstance(bind.InstanceApp{
+		ServiceName: "redis",
+		Instance:    bind.ServiceInstance{Name: "mycache", Envs: map[string]string{"REDIS_HOST": "localhost"}},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, map[string][]bind.ServiceInstance{
+		"mysql": {{Name: "mydb", Envs: map[string]string{"DATABASE_HOST": "localhost"}}},
+		"redis": {{Name: "mycache", Envs: map[string]string{"REDIS_HOST": "localhost"}}},
+	})
+}
+
+func (s *S) TestReencryptAllEnvs(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_PASSWORD", Value: "secret"},
+			{Name: "DEBUG", Value: "1", Public: true},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	_, err = ReencryptAllEnvs(nil)
+	c.Assert(err, check.Equals, ErrEnvEncryptionDisabled)
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	var buf bytes.Buffer
+	count, err := ReencryptAllEnvs(&buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+	c.Assert(buf.String(), check.Equals, "envs of the app \"myapp\" re-encrypted\n")
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.EnvKey.MasterKeyID, check.Equals, "key1")
+	c.Assert(stored.Env["DATABASE_PASSWORD"].Encrypted, check.Equals, true)
+	c.Assert(stored.Env["DEBUG"].Encrypted, check.Equals, false)
+	setEnvMasterKeys("key2", map[string]string{"key1": testEnvKey1, "key2": testEnvKey2})
+	count, err = ReencryptAllEnvs(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+	rotated, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(rotated.EnvKey.MasterKeyID, check.Equals, "key2")
+	c.Assert(rotated.EnvKey.Key, check.Not(check.DeepEquals), stored.EnvKey.Key)
+	setEnvMasterKeys("key2", map[string]string{"key2": testEnvKey2})
+	envs, err := rotated.DecryptedEnvs()
+	c.Assert(err, check.IsNil)
+	c.Assert(envs["DATABASE_PASSWORD"].Value, check.Equals, "secret")
+	c.Assert(envs["DEBUG"].Value, check.Equals, "1")
+	var raw bson.M
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&raw)
+	c.Assert(err, check.IsNil)
+	c.Assert(raw["envkey"], check.NotNil)
+}
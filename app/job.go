@@ -0,0 +1,315 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultJobTimeout = time.Hour
+
+	// maxJobRunOutput is the maximum size of the output stored in a job
+	// run, only the end of larger outputs is kept. The full output is
+	// still available in the app logs.
+	maxJobRunOutput = 64 * 1024
+)
+
+var (
+	ErrJobNotFound        = errors.New("job not found")
+	ErrJobAlreadyExists   = errors.New("there's already a job with this name in the app")
+	ErrJobInvalidName     = errors.New("invalid job name, it must start with a letter and contain only lowercase letters, numbers and dashes")
+	ErrJobCommandRequired = errors.New("job command is required")
+	ErrJobRunning         = errors.New("job is already running")
+	ErrJobsNotSupported   = errors.New("the provisioner of the app doesn't support jobs")
+	ErrJobAppNotDeployed  = errors.New("the app must be deployed before running jobs")
+
+	jobNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,39}$`)
+
+	jobLimiter     = &provision.MongodbLimiter{}
+	jobLimiterOnce sync.Once
+)
+
+// Job is a command that runs periodically in a temporary unit of an app,
+// created from the current image of the app, according to a cron schedule.
+type Job struct {
+	Name     string
+	App      string
+	Command  string
+	Schedule string
+	NextRun  time.Time
+}
+
+// JobRun holds the result of one execution of a job.
+type JobRun struct {
+	ID        bson.ObjectId `bson:"_id"`
+	App       string
+	Job       string
+	Command   string
+	Manual    bool
+	StartTime time.Time
+	EndTime   time.Time
+	ExitCode  int
+	Output    string
+	Error     string `bson:",omitempty"`
+}
+
+func (j *Job) validate() (*jobSchedule, error) {
+	if !jobNameRegexp.MatchString(j.Name) {
+		return nil, ErrJobInvalidName
+	}
+	if j.Command == "" {
+		return nil, ErrJobCommandRequired
+	}
+	return parseJobSchedule(j.Schedule)
+}
+
+func (j *Job) lockName() string {
+	return fmt.Sprintf("job:%s:%s", j.App, j.Name)
+}
+
+// AddJob validates and stores a new job in the app, scheduling its first
+// run.
+func (app *App) AddJob(job Job) error {
+	job.App = app.Name
+	schedule, err := job.validate()
+	if err != nil {
+		return err
+	}
+	job.NextRun = schedule.next(time.Now())
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Jobs().Insert(job)
+	if mgo.IsDup(err) {
+		return ErrJobAlreadyExists
+	}
+	return err
+}
+
+// UpdateJob changes the command and the schedule of an existing job of the
+// app, rescheduling its next run.
+func (app *App) UpdateJob(job Job) error {
+	job.App = app.Name
+	schedule, err := job.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Jobs().Update(bson.M{"app": app.Name, "name": job.Name}, bson.M{
+		"$set": bson.M{
+			"command":  job.Command,
+			"schedule": job.Schedule,
+			"nextrun":  schedule.next(time.Now()),
+		},
+	})
+	if err == mgo.ErrNotFound {
+		return ErrJobNotFound
+	}
+	return err
+}
+
+// RemoveJob removes the job from the app, along with the history of its runs.
+func (app *App) RemoveJob(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Jobs().Remove(bson.M{"app": app.Name, "name": name})
+	if err == mgo.ErrNotFound {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.JobRuns().RemoveAll(bson.M{"app": app.Name, "job": name})
+	return err
+}
+
+// GetJob returns the job with the given name in the app.
+func (app *App) GetJob(name string) (*Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var job Job
+	err = conn.Jobs().Find(bson.M{"app": app.Name, "name": name}).One(&job)
+	if err == mgo.ErrNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Jobs returns the jobs of the app, sorted by name.
+func (app *App) Jobs() ([]Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var jobs []Job
+	err = conn.Jobs().Find(bson.M{"app": app.Name}).Sort("name").All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// JobRuns returns the runs of a job in the app, most recent first.
+func (app *App) JobRuns(name string, skip, limit int) ([]JobRun, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := conn.JobRuns().Find(bson.M{"app": app.Name, "job": name}).Sort("-starttime", "-_id")
+	if skip != 0 {
+		query = query.Skip(skip)
+	}
+	if limit != 0 {
+		query = query.Limit(limit)
+	}
+	var runs []JobRun
+	err = query.All(&runs)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// RunJob runs the job right away, regardless of its schedule, writing the
+// output of the command to w. It fails with ErrJobRunning when the job is
+// already running, in this or in any other tsuru API instance.
+func (app *App) RunJob(name string, w io.Writer) (*JobRun, error) {
+	job, err := app.GetJob(name)
+	if err != nil {
+		return nil, err
+	}
+	done, ok := lockJob(job)
+	if !ok {
+		return nil, ErrJobRunning
+	}
+	defer done()
+	return job.run(app, w, true)
+}
+
+func removeJobs(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Jobs().RemoveAll(bson.M{"app": appName})
+	if err != nil {
+		return err
+	}
+	_, err = conn.JobRuns().RemoveAll(bson.M{"app": appName})
+	return err
+}
+
+// lockJob prevents concurrent runs of the same job. The lock is stored in
+// MongoDB, so it's shared by all tsuru API instances, and expires if the
+// instance holding it dies.
+func lockJob(job *Job) (func(), bool) {
+	jobLimiterOnce.Do(func() {
+		jobLimiter.Initialize(1)
+	})
+	return jobLimiter.TryStart(job.lockName())
+}
+
+func jobTimeout() time.Duration {
+	timeout, err := config.GetInt("jobs:timeout")
+	if err != nil || timeout <= 0 {
+		return defaultJobTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// run executes the job in a temporary unit of the app, with the same
+// environment used by Run. The output is sent to the app log and to w, and
+// stored, along with the exit code, in a new JobRun.
+func (j *Job) run(app *App, w io.Writer, manual bool) (*JobRun, error) {
+	if app.Deploys == 0 {
+		return nil, ErrJobAppNotDeployed
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	executor, ok := prov.(provision.IsolatedExecutor)
+	if !ok {
+		return nil, ErrJobsNotSupported
+	}
+	run := JobRun{
+		ID:        bson.NewObjectId(),
+		App:       app.Name,
+		Job:       j.Name,
+		Command:   j.Command,
+		Manual:    manual,
+		StartTime: time.Now().UTC(),
+	}
+	app.Log(fmt.Sprintf("running job %q: '%s'", j.Name, j.Command), "tsuru", "api")
+	logWriter := LogWriter{App: app, Source: "app-job", Unit: j.Name}
+	logWriter.Async()
+	var output safe.Buffer
+	writers := []io.Writer{&output, &logWriter}
+	if w != nil {
+		writers = append(writers, w)
+	}
+	out := io.MultiWriter(writers...)
+	run.ExitCode, err = executor.ExecuteCommandIsolated(provision.IsolatedExecOptions{
+		App:     app,
+		Cmd:     sourcedCommand(j.Command),
+		Stdout:  out,
+		Stderr:  out,
+		Timeout: jobTimeout(),
+	})
+	logWriter.Close()
+	logWriter.Wait(time.Minute)
+	run.EndTime = time.Now().UTC()
+	run.Output = output.String()
+	if len(run.Output) > maxJobRunOutput {
+		run.Output = run.Output[len(run.Output)-maxJobRunOutput:]
+	}
+	if err != nil {
+		run.Error = err.Error()
+		app.Log(fmt.Sprintf("job %q failed: %s", j.Name, err), "tsuru", "api")
+	} else {
+		app.Log(fmt.Sprintf("job %q finished with exit code %d", j.Name, run.ExitCode), "tsuru", "api")
+	}
+	conn, dbErr := db.Conn()
+	if dbErr != nil {
+		return nil, dbErr
+	}
+	defer conn.Close()
+	dbErr = conn.JobRuns().Insert(run)
+	if dbErr != nil {
+		return nil, dbErr
+	}
+	return &run, err
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestParseJobScheduleInvalid(c *check.C) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"", `invalid schedule "": expected 5 fields, got 0`},
+		{"* * * *", `invalid schedule "\* \* \* \*": expected 5 fields, got 4`},
+		{"60 * * * *", `invalid schedule "60 \* \* \* \*": value "60" out of range \[0-59\] in minute field`},
+		{"* 24 * * *", `.*value "24" out of range \[0-23\] in hour field`},
+		{"* * 0 * *", `.*value "0" out of range \[1-31\] in day of month field`},
+		{"* * * 13 *", `.*value "13" out of range \[1-12\] in month field`},
+		{"* * * * 8", `.*value "8" out of range \[0-7\] in day of week field`},
+		{"5-1 * * * *", `.*value "5-1" out of range \[0-59\] in minute field`},
+		{"*/0 * * * *", `.*invalid step "0" in minute field`},
+		{"a * * * *", `.*invalid value "a" in minute field`},
+		{"@every 5m", `invalid schedule "@every 5m": expected 5 fields, got 2`},
+	}
+	for _, t := range tests {
+		_, err := parseJobSchedule(t.spec)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("spec %q", t.spec))
+	}
+}
+
+func (s *S) TestJobScheduleNext(c *check.C) {
+	base := time.Date(2016, 5, 10, 14, 32, 20, 0, time.UTC) // a tuesday
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2016, 5, 10, 14, 33, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2016, 5, 10, 14, 45, 0, 0, time.UTC)},
+		{"30 * * * *", time.Date(2016, 5, 10, 15, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2016, 5, 11, 3, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2016, 5, 10, 17, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2016, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2016, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2016, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2016, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 5", time.Date(2016, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2016, 5, 10, 15, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2016, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2016, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2016, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, t := range tests {
+		schedule, err := parseJobSchedule(t.spec)
+		c.Assert(err, check.IsNil)
+		c.Check(schedule.next(base), check.DeepEquals, t.expected, check.Commentf("spec %q", t.spec))
+	}
+}
+
+func (s *S) TestJobScheduleNextIsAfterTime(c *check.C) {
+	schedule, err := parseJobSchedule("0 * * * *")
+	c.Assert(err, check.IsNil)
+	now := time.Date(2016, 5, 10, 14, 0, 0, 0, time.UTC)
+	c.Assert(schedule.next(now), check.DeepEquals, time.Date(2016, 5, 10, 15, 0, 0, 0, time.UTC))
+}
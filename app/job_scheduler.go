@@ -0,0 +1,112 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/periodic"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// JobScheduler periodically looks for jobs whose next run is due, running each
+// of them in a temporary unit of its app. A run is claimed by atomically
+// moving the next run of the job forward, so only one API instance executes
+// it, and the job lock prevents a run from starting while the previous one is
+// still running.
+type JobScheduler struct {
+	*periodic.Loop
+	running sync.WaitGroup
+}
+
+// NewJobScheduler returns a scheduler that looks for due jobs every 10
+// seconds.
+func NewJobScheduler() *JobScheduler {
+	s := &JobScheduler{}
+	s.Loop = &periodic.Loop{
+		Name:     "job scheduler",
+		Interval: 10 * time.Second,
+		Task:     s.runOnce,
+	}
+	return s
+}
+
+// Shutdown stops the scheduler loop and waits for the running jobs to finish.
+func (s *JobScheduler) Shutdown() {
+	s.Loop.Shutdown()
+	s.running.Wait()
+}
+
+func (s *JobScheduler) runOnce() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	var jobs []Job
+	err = conn.Jobs().Find(bson.M{"nextrun": bson.M{"$gt": time.Time{}, "$lte": now}}).All(&jobs)
+	if err != nil {
+		return fmt.Errorf("error getting jobs: %s", err)
+	}
+	for i := range jobs {
+		err = s.schedule(&jobs[i], now)
+		if err != nil {
+			log.Errorf("[jobs] error running job %q of app %s: %s", jobs[i].Name, jobs[i].App, err)
+		}
+	}
+	return nil
+}
+
+// schedule claims the due run of the job and starts it in background. It
+// does nothing if the run was already claimed by another instance.
+func (s *JobScheduler) schedule(job *Job, now time.Time) error {
+	schedule, err := parseJobSchedule(job.Schedule)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Jobs().Update(
+		bson.M{"app": job.App, "name": job.Name, "nextrun": job.NextRun},
+		bson.M{"$set": bson.M{"nextrun": schedule.next(now)}},
+	)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	done, ok := lockJob(job)
+	if !ok {
+		log.Debugf("[jobs] skipped job %q of app %s, previous run still running", job.Name, job.App)
+		return nil
+	}
+	a, err := GetByName(job.App)
+	if err != nil {
+		done()
+		return err
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer done()
+		run, runErr := job.run(a, nil, false)
+		if runErr != nil {
+			log.Errorf("[jobs] error running job %q of app %s: %s", job.Name, job.App, runErr)
+		} else {
+			log.Debugf("[jobs] job %q of app %s finished with exit code %d", job.Name, job.App, run.ExitCode)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) setJobNextRun(c *check.C, a *App, name string, nextRun time.Time) *Job {
+	err := s.conn.Jobs().Update(bson.M{"app": a.Name, "name": name}, bson.M{"$set": bson.M{"nextrun": nextRun}})
+	c.Assert(err, check.IsNil)
+	job, err := a.GetJob(name)
+	c.Assert(err, check.IsNil)
+	return job
+}
+
+func (s *S) TestJobSchedulerRunsDueJobs(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "* * * * *"})
+	c.Assert(err, check.IsNil)
+	err = a.AddJob(Job{Name: "report", Command: "./report.sh", Schedule: "@yearly"})
+	c.Assert(err, check.IsNil)
+	s.setJobNextRun(c, a, "backup", time.Now().Add(-time.Minute))
+	s.provisioner.PrepareOutput([]byte("backup done"))
+	scheduler := NewJobScheduler()
+	err = scheduler.runOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.running.Wait()
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Manual, check.Equals, false)
+	c.Assert(runs[0].Output, check.Equals, "backup done")
+	runs, err = a.JobRuns("report", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 0)
+	job, err := a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(job.NextRun.After(time.Now()), check.Equals, true)
+	err = scheduler.runOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.running.Wait()
+	runs, err = a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+}
+
+func (s *S) TestJobSchedulerRunClaimedByOtherInstance(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "* * * * *"})
+	c.Assert(err, check.IsNil)
+	job := s.setJobNextRun(c, a, "backup", time.Now().Add(-time.Minute))
+	other := NewJobScheduler()
+	err = other.schedule(job, time.Now())
+	c.Assert(err, check.IsNil)
+	other.running.Wait()
+	scheduler := NewJobScheduler()
+	err = scheduler.schedule(job, time.Now())
+	c.Assert(err, check.IsNil)
+	scheduler.running.Wait()
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+}
+
+func (s *S) TestJobSchedulerSkipsRunningJob(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "* * * * *"})
+	c.Assert(err, check.IsNil)
+	job := s.setJobNextRun(c, a, "backup", time.Now().Add(-time.Minute))
+	done, ok := lockJob(job)
+	c.Assert(ok, check.Equals, true)
+	defer done()
+	scheduler := NewJobScheduler()
+	err = scheduler.runOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.running.Wait()
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 0)
+	dbJob, err := a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbJob.NextRun.After(time.Now()), check.Equals, true)
+}
+
+func (s *S) TestJobSchedulerShutdown(c *check.C) {
+	scheduler := NewJobScheduler()
+	scheduler.Interval = time.Hour
+	scheduler.Start()
+	done := make(chan bool)
+	go func() {
+		scheduler.Shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for the scheduler to stop")
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAddJob(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	before := time.Now()
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "*/5 * * * *"})
+	c.Assert(err, check.IsNil)
+	job, err := a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(job.App, check.Equals, a.Name)
+	c.Assert(job.Command, check.Equals, "./backup.sh")
+	c.Assert(job.Schedule, check.Equals, "*/5 * * * *")
+	c.Assert(job.NextRun.After(before), check.Equals, true)
+	c.Assert(job.NextRun.Minute()%5, check.Equals, 0)
+}
+
+func (s *S) TestAddJobAlreadyExists(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	err = a.AddJob(Job{Name: "backup", Command: "./other.sh", Schedule: "@hourly"})
+	c.Assert(err, check.Equals, ErrJobAlreadyExists)
+}
+
+func (s *S) TestAddJobSameNameOtherApp(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	other := App{Name: "otherapp"}
+	err = other.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestAddJobInvalid(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	tests := []struct {
+		job Job
+		err string
+	}{
+		{Job{Name: "", Command: "ls", Schedule: "@daily"}, ErrJobInvalidName.Error()},
+		{Job{Name: "Backup", Command: "ls", Schedule: "@daily"}, ErrJobInvalidName.Error()},
+		{Job{Name: "1backup", Command: "ls", Schedule: "@daily"}, ErrJobInvalidName.Error()},
+		{Job{Name: "backup", Command: "", Schedule: "@daily"}, ErrJobCommandRequired.Error()},
+		{Job{Name: "backup", Command: "ls", Schedule: ""}, `invalid schedule "".*`},
+	}
+	for _, t := range tests {
+		err := a.AddJob(t.job)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+	jobs, err := a.Jobs()
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+}
+
+func (s *S) TestUpdateJob(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "0 0 1 1 *"})
+	c.Assert(err, check.IsNil)
+	err = a.UpdateJob(Job{Name: "backup", Command: "./backup.sh -v", Schedule: "* * * * *"})
+	c.Assert(err, check.IsNil)
+	job, err := a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Command, check.Equals, "./backup.sh -v")
+	c.Assert(job.Schedule, check.Equals, "* * * * *")
+	c.Assert(job.NextRun.Before(time.Now().Add(2*time.Minute)), check.Equals, true)
+}
+
+func (s *S) TestUpdateJobNotFound(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.UpdateJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestRemoveJob(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	_, err = a.RunJob("backup", nil)
+	c.Assert(err, check.IsNil)
+	err = a.RemoveJob("backup")
+	c.Assert(err, check.IsNil)
+	_, err = a.GetJob("backup")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+	n, err := s.conn.JobRuns().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	err = a.RemoveJob("backup")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestJobs(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "report", Command: "./report.sh", Schedule: "@weekly"})
+	c.Assert(err, check.IsNil)
+	err = a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	other := App{Name: "otherapp"}
+	err = other.AddJob(Job{Name: "cleanup", Command: "./cleanup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	jobs, err := a.Jobs()
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 2)
+	c.Assert(jobs[0].Name, check.Equals, "backup")
+	c.Assert(jobs[1].Name, check.Equals, "report")
+}
+
+func (s *S) TestRunJob(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareOutput([]byte("backup done\n"))
+	var buf bytes.Buffer
+	run, err := a.RunJob("backup", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "backup done\n")
+	c.Assert(run.App, check.Equals, a.Name)
+	c.Assert(run.Job, check.Equals, "backup")
+	c.Assert(run.Command, check.Equals, "./backup.sh")
+	c.Assert(run.Manual, check.Equals, true)
+	c.Assert(run.ExitCode, check.Equals, 0)
+	c.Assert(run.Output, check.Equals, "backup done\n")
+	c.Assert(run.EndTime.Before(run.StartTime), check.Equals, false)
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " ./backup.sh"
+	cmds := s.provisioner.GetCmds(expected, a)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Isolated, check.Equals, true)
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].ID, check.Equals, run.ID)
+	c.Assert(runs[0].Output, check.Equals, "backup done\n")
+	logs, err := a.LastLogs(3, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 3)
+	c.Assert(logs[0].Message, check.Equals, `running job "backup": './backup.sh'`)
+	c.Assert(logs[1].Message, check.Equals, "backup done")
+	c.Assert(logs[1].Source, check.Equals, "app-job")
+	c.Assert(logs[1].Unit, check.Equals, "backup")
+	c.Assert(logs[2].Message, check.Equals, `job "backup" finished with exit code 0`)
+}
+
+func (s *S) TestRunJobExitCode(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareExitCode(3)
+	run, err := a.RunJob("backup", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(run.ExitCode, check.Equals, 3)
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].ExitCode, check.Equals, 3)
+}
+
+func (s *S) TestRunJobFailure(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareFailure("ExecuteCommandIsolated", errors.New("no nodes available"))
+	run, err := a.RunJob("backup", nil)
+	c.Assert(err, check.ErrorMatches, "no nodes available")
+	c.Assert(run.Error, check.Equals, "no nodes available")
+	runs, err := a.JobRuns("backup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Error, check.Equals, "no nodes available")
+}
+
+func (s *S) TestRunJobTruncatesStoredOutput(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	output := bytes.Repeat([]byte("a"), maxJobRunOutput)
+	s.provisioner.PrepareOutput(append([]byte("start"), output...))
+	run, err := a.RunJob("backup", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(run.Output, check.Equals, string(output))
+}
+
+func (s *S) TestRunJobAlreadyRunning(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	job, err := a.GetJob("backup")
+	c.Assert(err, check.IsNil)
+	done, ok := lockJob(job)
+	c.Assert(ok, check.Equals, true)
+	_, err = a.RunJob("backup", nil)
+	c.Assert(err, check.Equals, ErrJobRunning)
+	done()
+	_, err = a.RunJob("backup", nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRunJobNotDeployed(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	a.Deploys = 0
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	_, err = a.RunJob("backup", nil)
+	c.Assert(err, check.Equals, ErrJobAppNotDeployed)
+}
+
+func (s *S) TestRunJobNotSupported(c *check.C) {
+	s.addPoolWithProvisioner(c, "pool2", noImageProvisioner{s.provisioner})
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	a.Pool = "pool2"
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	_, err = a.RunJob("backup", nil)
+	c.Assert(err, check.Equals, ErrJobsNotSupported)
+}
+
+func (s *S) TestJobTimeout(c *check.C) {
+	c.Assert(jobTimeout(), check.Equals, time.Hour)
+	config.Set("jobs:timeout", 30)
+	defer config.Unset("jobs:timeout")
+	c.Assert(jobTimeout(), check.Equals, 30*time.Second)
+}
+
+func (s *S) TestJobRunsPagination(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	var ids []bson.ObjectId
+	for i := 0; i < 3; i++ {
+		run, runErr := a.RunJob("backup", nil)
+		c.Assert(runErr, check.IsNil)
+		ids = append(ids, run.ID)
+	}
+	runs, err := a.JobRuns("backup", 1, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].ID, check.Equals, ids[1])
+}
+
+func (s *S) TestDeleteRemovesJobs(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.AddJob(Job{Name: "backup", Command: "./backup.sh", Schedule: "@daily"})
+	c.Assert(err, check.IsNil)
+	_, err = a.RunJob("backup", nil)
+	c.Assert(err, check.IsNil)
+	err = Delete(a, nil)
+	c.Assert(err, check.IsNil)
+	n, err := s.conn.Jobs().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	n, err = s.conn.JobRuns().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
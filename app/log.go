@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/queue"
 )
@@ -17,6 +16,11 @@ import (
 var LogPubSubQueuePrefix = "pubsub:"
 var bulkMaxWaitTime = time.Second
 
+// logServiceTTL is the time the log dispatchers keep using the log service
+// of an app before resolving it again, so changes in the pool of the app and
+// in the log service configs are picked up.
+var logServiceTTL = time.Minute
+
 type LogListener struct {
 	c <-chan Applog
 	q queue.PubSubQ
@@ -151,9 +155,11 @@ func (d *logDispatcher) Stop() {
 }
 
 type appLogDispatcher struct {
-	appName string
-	done    chan bool
-	toFlush chan *Applog
+	appName    string
+	done       chan bool
+	toFlush    chan *Applog
+	service    LogService
+	resolvedAt time.Time
 }
 
 func newAppLogDispatcher(appName string) *appLogDispatcher {
@@ -170,7 +176,7 @@ func (d *appLogDispatcher) runFlusher() {
 	t := time.NewTimer(bulkMaxWaitTime)
 	pos := 0
 	sz := 200
-	bulkBuffer := make([]interface{}, sz)
+	bulkBuffer := make([]Applog, sz)
 	for {
 		var flush bool
 		select {
@@ -181,7 +187,7 @@ func (d *appLogDispatcher) runFlusher() {
 				flush = true
 				break
 			}
-			bulkBuffer[pos] = msg
+			bulkBuffer[pos] = *msg
 			pos++
 			flush = sz == pos
 		case <-t.C:
@@ -189,14 +195,12 @@ func (d *appLogDispatcher) runFlusher() {
 			t.Reset(bulkMaxWaitTime)
 		}
 		if flush {
-			conn, err := db.LogConn()
+			service, err := d.logService()
 			if err != nil {
-				log.Errorf("[log flusher] unable to connect to mongodb: %s", err)
+				log.Errorf("[log flusher] unable to find the log service: %s", err)
 				continue
 			}
-			coll := conn.Logs(d.appName)
-			err = coll.Insert(bulkBuffer[:pos]...)
-			coll.Close()
+			err = service.Add(d.appName, bulkBuffer[:pos])
 			if err != nil {
 				log.Errorf("[log flusher] unable to insert logs: %s", err)
 				continue
@@ -205,3 +209,18 @@ func (d *appLogDispatcher) runFlusher() {
 		}
 	}
 }
+
+// logService returns the log service of the app, resolving it again once
+// logServiceTTL has passed since the last time it was resolved.
+func (d *appLogDispatcher) logService() (LogService, error) {
+	if d.service != nil && time.Since(d.resolvedAt) < logServiceTTL {
+		return d.service, nil
+	}
+	service, err := logServiceForAppName(d.appName)
+	if err != nil {
+		return nil, err
+	}
+	d.service = service
+	d.resolvedAt = time.Now()
+	return service, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/scopedconfig"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	logServiceConfigCollection = "app-logs"
+
+	LogBackendMongoDB       = "mongodb"
+	LogBackendElasticsearch = "elasticsearch"
+
+	defaultElasticsearchIndex = "tsuru-logs"
+)
+
+var (
+	ErrInvalidLogBackend     = errors.New("invalid log backend, it must be mongodb or elasticsearch")
+	ErrLogBackendURLRequired = errors.New("url is required for the elasticsearch log backend")
+)
+
+// LogService stores and retrieves the logs of apps. The backend used by an
+// app is chosen by the log service config of its pool.
+type LogService interface {
+	// Add stores the log entries of the app.
+	Add(appName string, logs []Applog) error
+
+	// List returns the last lines entries of the app matching the fields
+	// in filter, oldest first.
+	List(appName string, lines int, filter Applog) ([]Applog, error)
+
+	// Remove removes all the log entries of the app.
+	Remove(appName string) error
+}
+
+// LogServiceConfig is the configuration of the log backend of a pool. An
+// empty Backend means the mongodb backend.
+type LogServiceConfig struct {
+	Backend string
+	URL     string
+	Index   string
+}
+
+func loadLogServiceConfig() *scopedconfig.ScopedConfig {
+	conf := scopedconfig.FindScopedConfig(logServiceConfigCollection)
+	conf.ShallowMerge = true
+	return conf
+}
+
+// LogServiceConfigLoadAll returns the log service config of all pools,
+// indexed by pool name. The default config is stored in the empty key.
+func LogServiceConfigLoadAll() (map[string]LogServiceConfig, error) {
+	conf := loadLogServiceConfig()
+	var all map[string]LogServiceConfig
+	err := conf.LoadAll(&all)
+	return all, err
+}
+
+func (c *LogServiceConfig) validate() error {
+	switch c.Backend {
+	case "", LogBackendMongoDB:
+	case LogBackendElasticsearch:
+		if c.URL == "" {
+			return ErrLogBackendURLRequired
+		}
+		if _, err := url.Parse(c.URL); err != nil {
+			return fmt.Errorf("invalid url for the elasticsearch log backend: %s", err)
+		}
+	default:
+		return ErrInvalidLogBackend
+	}
+	return nil
+}
+
+// Save validates and stores the config for the pool. An empty pool name
+// changes the default config, used by pools without a config of their own.
+func (c *LogServiceConfig) Save(pool string) error {
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+	return loadLogServiceConfig().Save(pool, *c)
+}
+
+func (c *LogServiceConfig) service() (LogService, error) {
+	switch c.Backend {
+	case "", LogBackendMongoDB:
+		return &mongoLogService{}, nil
+	case LogBackendElasticsearch:
+		index := c.Index
+		if index == "" {
+			index = defaultElasticsearchIndex
+		}
+		return newElasticsearchLogService(c.URL, index), nil
+	}
+	return nil, ErrInvalidLogBackend
+}
+
+// LogServiceForPool returns the log service configured for the pool.
+func LogServiceForPool(pool string) (LogService, error) {
+	var conf LogServiceConfig
+	err := loadLogServiceConfig().Load(pool, &conf)
+	if err != nil {
+		return nil, err
+	}
+	return conf.service()
+}
+
+func (app *App) logService() (LogService, error) {
+	return LogServiceForPool(app.Pool)
+}
+
+// logServiceForAppName returns the log service of the app with the given
+// name, falling back to the default one when the app doesn't exist anymore.
+func logServiceForAppName(appName string) (LogService, error) {
+	a, err := GetByName(appName)
+	if err == ErrAppNotFound {
+		return LogServiceForPool("")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.logService()
+}
+
+// mongoLogService stores logs in a capped collection per app.
+type mongoLogService struct{}
+
+func (s *mongoLogService) Add(appName string, logs []Applog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	conn, err := db.LogConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	docs := make([]interface{}, len(logs))
+	for i := range logs {
+		docs[i] = logs[i]
+	}
+	return conn.Logs(appName).Insert(docs...)
+}
+
+func (s *mongoLogService) List(appName string, lines int, filter Applog) ([]Applog, error) {
+	conn, err := db.LogConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	logs := []Applog{}
+	q := bson.M{}
+	if filter.Source != "" {
+		q["source"] = filter.Source
+	}
+	if filter.Unit != "" {
+		q["unit"] = filter.Unit
+	}
+	err = conn.Logs(appName).Find(q).Sort("-$natural").Limit(lines).All(&logs)
+	if err != nil {
+		return nil, err
+	}
+	reverseLogs(logs)
+	return logs, nil
+}
+
+func (s *mongoLogService) Remove(appName string) error {
+	conn, err := db.LogConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Logs(appName).DropCollection()
+}
+
+func reverseLogs(logs []Applog) {
+	l := len(logs)
+	for i := 0; i < l/2; i++ {
+		logs[i], logs[l-1-i] = logs[l-1-i], logs[i]
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+// elasticsearchLogService stores logs in an index of an Elasticsearch
+// compatible server, using its HTTP API. The appname, source and unit fields
+// are used in term queries, so they should be mapped as keywords in the
+// index.
+type elasticsearchLogService struct {
+	url    string
+	index  string
+	client *http.Client
+}
+
+type elasticsearchLogEntry struct {
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	Source  string    `json:"source"`
+	AppName string    `json:"appname"`
+	Unit    string    `json:"unit"`
+}
+
+type elasticsearchSearchResult struct {
+	Hits struct {
+		Hits []struct {
+			Source elasticsearchLogEntry `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+type elasticsearchError struct {
+	code    int
+	method  string
+	path    string
+	message string
+}
+
+func (e *elasticsearchError) Error() string {
+	return fmt.Sprintf("elasticsearch: invalid response code %d from %s %s: %s", e.code, e.method, e.path, e.message)
+}
+
+// isIndexNotFound tells whether the error was caused by a missing index,
+// which is only created when the first log is added.
+func isIndexNotFound(err error) bool {
+	esErr, ok := err.(*elasticsearchError)
+	return ok && esErr.code == http.StatusNotFound
+}
+
+type elasticsearchBulkResult struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Error json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func newElasticsearchLogService(url, index string) *elasticsearchLogService {
+	return &elasticsearchLogService{
+		url:    strings.TrimRight(url, "/"),
+		index:  index,
+		client: tsuruNet.Dial5Full300Client,
+	}
+}
+
+func (s *elasticsearchLogService) Add(appName string, logs []Applog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	action := map[string]interface{}{"index": map[string]string{"_index": s.index}}
+	for _, l := range logs {
+		err := encoder.Encode(action)
+		if err != nil {
+			return err
+		}
+		err = encoder.Encode(elasticsearchLogEntry{
+			Date:    l.Date,
+			Message: l.Message,
+			Source:  l.Source,
+			AppName: appName,
+			Unit:    l.Unit,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	var result elasticsearchBulkResult
+	err := s.do("POST", "/_bulk", "application/x-ndjson", &body, &result)
+	if err != nil {
+		return err
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, status := range item {
+				if len(status.Error) > 0 {
+					return fmt.Errorf("elasticsearch: unable to index logs: %s", status.Error)
+				}
+			}
+		}
+		return fmt.Errorf("elasticsearch: unable to index logs")
+	}
+	return nil
+}
+
+func (s *elasticsearchLogService) List(appName string, lines int, filter Applog) ([]Applog, error) {
+	terms := []map[string]interface{}{
+		{"term": map[string]string{"appname": appName}},
+	}
+	if filter.Source != "" {
+		terms = append(terms, map[string]interface{}{"term": map[string]string{"source": filter.Source}})
+	}
+	if filter.Unit != "" {
+		terms = append(terms, map[string]interface{}{"term": map[string]string{"unit": filter.Unit}})
+	}
+	query := map[string]interface{}{
+		"size":  lines,
+		"sort":  []map[string]string{{"date": "desc"}},
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": terms}},
+	}
+	data, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	var result elasticsearchSearchResult
+	err = s.do("POST", "/"+s.index+"/_search", "application/json", bytes.NewReader(data), &result)
+	if isIndexNotFound(err) {
+		return []Applog{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]Applog, len(result.Hits.Hits))
+	for i, hit := range result.Hits.Hits {
+		logs[i] = Applog{
+			Date:    hit.Source.Date,
+			Message: hit.Source.Message,
+			Source:  hit.Source.Source,
+			AppName: hit.Source.AppName,
+			Unit:    hit.Source.Unit,
+		}
+	}
+	reverseLogs(logs)
+	return logs, nil
+}
+
+func (s *elasticsearchLogService) Remove(appName string) error {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{"term": map[string]string{"appname": appName}},
+	}
+	data, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	err = s.do("POST", "/"+s.index+"/_delete_by_query", "application/json", bytes.NewReader(data), nil)
+	if isIndexNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *elasticsearchLogService) do(method, path, contentType string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, s.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(rsp.Body)
+		return &elasticsearchError{
+			code:    rsp.StatusCode,
+			method:  method,
+			path:    path,
+			message: strings.TrimSpace(string(data)),
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(rsp.Body).Decode(result)
+}
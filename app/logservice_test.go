@@ -0,0 +1,383 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+// fakeElasticsearch is a minimal in memory implementation of the parts of
+// the Elasticsearch API used by the log service.
+type fakeElasticsearch struct {
+	sync.Mutex
+	server   *httptest.Server
+	indexes  map[string][]elasticsearchLogEntry
+	failBulk bool
+}
+
+func newFakeElasticsearch() *fakeElasticsearch {
+	f := &fakeElasticsearch{indexes: map[string][]elasticsearchLogEntry{}}
+	f.server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeElasticsearch) Close() {
+	f.server.Close()
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "_bulk":
+		f.bulk(w, r)
+	case len(parts) == 2 && parts[1] == "_search":
+		f.search(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "_delete_by_query":
+		f.deleteByQuery(w, r, parts[0])
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (f *fakeElasticsearch) bulk(w http.ResponseWriter, r *http.Request) {
+	if f.failBulk {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": true,
+			"items": []map[string]interface{}{
+				{"index": map[string]interface{}{"status": 400, "error": map[string]string{"type": "mapper_parsing_exception"}}},
+			},
+		})
+		return
+	}
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action struct {
+			Index struct {
+				Index string `json:"_index"`
+			} `json:"index"`
+		}
+		json.Unmarshal(scanner.Bytes(), &action)
+		scanner.Scan()
+		var entry elasticsearchLogEntry
+		json.Unmarshal(scanner.Bytes(), &entry)
+		f.indexes[action.Index.Index] = append(f.indexes[action.Index.Index], entry)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": false})
+}
+
+type fakeElasticsearchQuery struct {
+	Size  int                     `json:"size"`
+	Query fakeElasticsearchFilter `json:"query"`
+}
+
+type fakeElasticsearchFilter struct {
+	Term map[string]string `json:"term"`
+	Bool struct {
+		Filter []struct {
+			Term map[string]string `json:"term"`
+		} `json:"filter"`
+	} `json:"bool"`
+}
+
+func (q *fakeElasticsearchFilter) terms() map[string]string {
+	terms := map[string]string{}
+	for k, v := range q.Term {
+		terms[k] = v
+	}
+	for _, f := range q.Bool.Filter {
+		for k, v := range f.Term {
+			terms[k] = v
+		}
+	}
+	return terms
+}
+
+func (f *fakeElasticsearch) matches(entry elasticsearchLogEntry, terms map[string]string) bool {
+	values := map[string]string{"appname": entry.AppName, "source": entry.Source, "unit": entry.Unit}
+	for k, v := range terms {
+		if values[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeElasticsearch) search(w http.ResponseWriter, r *http.Request, index string) {
+	entries, ok := f.indexes[index]
+	if !ok {
+		http.Error(w, `{"error":{"type":"index_not_found_exception"}}`, http.StatusNotFound)
+		return
+	}
+	var query fakeElasticsearchQuery
+	json.NewDecoder(r.Body).Decode(&query)
+	terms := query.Query.terms()
+	var hits []map[string]interface{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if len(hits) == query.Size {
+			break
+		}
+		if f.matches(entries[i], terms) {
+			hits = append(hits, map[string]interface{}{"_source": entries[i]})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+}
+
+func (f *fakeElasticsearch) deleteByQuery(w http.ResponseWriter, r *http.Request, index string) {
+	entries, ok := f.indexes[index]
+	if !ok {
+		http.Error(w, `{"error":{"type":"index_not_found_exception"}}`, http.StatusNotFound)
+		return
+	}
+	var query fakeElasticsearchQuery
+	json.NewDecoder(r.Body).Decode(&query)
+	terms := query.Query.terms()
+	var kept []elasticsearchLogEntry
+	for _, entry := range entries {
+		if !f.matches(entry, terms) {
+			kept = append(kept, entry)
+		}
+	}
+	f.indexes[index] = kept
+	json.NewEncoder(w).Encode(map[string]interface{}{"deleted": len(entries) - len(kept)})
+}
+
+func (f *fakeElasticsearch) entries(index string) []elasticsearchLogEntry {
+	f.Lock()
+	defer f.Unlock()
+	return append([]elasticsearchLogEntry(nil), f.indexes[index]...)
+}
+
+func (s *S) TestLogServiceConfigSaveValidation(c *check.C) {
+	tests := []struct {
+		conf LogServiceConfig
+		err  error
+	}{
+		{LogServiceConfig{}, nil},
+		{LogServiceConfig{Backend: "mongodb"}, nil},
+		{LogServiceConfig{Backend: "elasticsearch", URL: "http://localhost:9200"}, nil},
+		{LogServiceConfig{Backend: "elasticsearch"}, ErrLogBackendURLRequired},
+		{LogServiceConfig{Backend: "cassandra"}, ErrInvalidLogBackend},
+	}
+	for _, t := range tests {
+		err := t.conf.Save("pool1")
+		c.Check(err, check.Equals, t.err, check.Commentf("config %#v", t.conf))
+	}
+}
+
+func (s *S) TestLogServiceForPool(c *check.C) {
+	service, err := LogServiceForPool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.FitsTypeOf, &mongoLogService{})
+	conf := LogServiceConfig{Backend: "elasticsearch", URL: "http://localhost:9200/"}
+	err = conf.Save("pool1")
+	c.Assert(err, check.IsNil)
+	service, err = LogServiceForPool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.DeepEquals, newElasticsearchLogService("http://localhost:9200", "tsuru-logs"))
+	service, err = LogServiceForPool("pool2")
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.FitsTypeOf, &mongoLogService{})
+}
+
+func (s *S) TestLogServiceForPoolInheritsDefault(c *check.C) {
+	conf := LogServiceConfig{Backend: "elasticsearch", URL: "http://localhost:9200", Index: "logs"}
+	err := conf.Save("")
+	c.Assert(err, check.IsNil)
+	service, err := LogServiceForPool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.DeepEquals, newElasticsearchLogService("http://localhost:9200", "logs"))
+	conf = LogServiceConfig{Backend: "mongodb"}
+	err = conf.Save("pool1")
+	c.Assert(err, check.IsNil)
+	service, err = LogServiceForPool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.FitsTypeOf, &mongoLogService{})
+}
+
+func (s *S) TestLogServiceConfigLoadAll(c *check.C) {
+	conf := LogServiceConfig{Backend: "elasticsearch", URL: "http://localhost:9200"}
+	err := conf.Save("pool1")
+	c.Assert(err, check.IsNil)
+	all, err := LogServiceConfigLoadAll()
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.DeepEquals, map[string]LogServiceConfig{
+		"":      {},
+		"pool1": {Backend: "elasticsearch", URL: "http://localhost:9200"},
+	})
+}
+
+func (s *S) TestMongoLogService(c *check.C) {
+	service := &mongoLogService{}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	err := service.Add("myapp", []Applog{
+		{Date: now, Message: "first", Source: "web", AppName: "myapp", Unit: "u1"},
+		{Date: now, Message: "second", Source: "tsuru", AppName: "myapp", Unit: "api"},
+		{Date: now, Message: "third", Source: "web", AppName: "myapp", Unit: "u2"},
+	})
+	c.Assert(err, check.IsNil)
+	logs, err := service.List("myapp", 2, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 2)
+	c.Assert(logs[0].Message, check.Equals, "second")
+	c.Assert(logs[1].Message, check.Equals, "third")
+	logs, err = service.List("myapp", 10, Applog{Source: "web", Unit: "u1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Message, check.Equals, "first")
+	err = service.Remove("myapp")
+	c.Assert(err, check.IsNil)
+	logs, err = service.List("myapp", 10, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 0)
+}
+
+func (s *S) TestElasticsearchLogService(c *check.C) {
+	fake := newFakeElasticsearch()
+	defer fake.Close()
+	service := newElasticsearchLogService(fake.server.URL, "tsuru-logs")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	err := service.Add("myapp", []Applog{
+		{Date: now, Message: "first", Source: "web", Unit: "u1"},
+		{Date: now, Message: "second", Source: "tsuru", Unit: "api"},
+		{Date: now, Message: "third", Source: "web", Unit: "u2"},
+	})
+	c.Assert(err, check.IsNil)
+	err = service.Add("otherapp", []Applog{{Date: now, Message: "other", Source: "web", Unit: "u3"}})
+	c.Assert(err, check.IsNil)
+	entries := fake.entries("tsuru-logs")
+	c.Assert(entries, check.HasLen, 4)
+	c.Assert(entries[0], check.DeepEquals, elasticsearchLogEntry{Date: now, Message: "first", Source: "web", AppName: "myapp", Unit: "u1"})
+	logs, err := service.List("myapp", 2, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.DeepEquals, []Applog{
+		{Date: now, Message: "second", Source: "tsuru", AppName: "myapp", Unit: "api"},
+		{Date: now, Message: "third", Source: "web", AppName: "myapp", Unit: "u2"},
+	})
+	logs, err = service.List("myapp", 10, Applog{Source: "web", Unit: "u1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Message, check.Equals, "first")
+	err = service.Remove("myapp")
+	c.Assert(err, check.IsNil)
+	logs, err = service.List("myapp", 10, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 0)
+	logs, err = service.List("otherapp", 10, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 1)
+}
+
+func (s *S) TestElasticsearchLogServiceMissingIndex(c *check.C) {
+	fake := newFakeElasticsearch()
+	defer fake.Close()
+	service := newElasticsearchLogService(fake.server.URL, "tsuru-logs")
+	logs, err := service.List("myapp", 10, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 0)
+	err = service.Remove("myapp")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestElasticsearchLogServiceBulkErrors(c *check.C) {
+	fake := newFakeElasticsearch()
+	defer fake.Close()
+	fake.failBulk = true
+	service := newElasticsearchLogService(fake.server.URL, "tsuru-logs")
+	err := service.Add("myapp", []Applog{{Date: time.Now(), Message: "first"}})
+	c.Assert(err, check.ErrorMatches, `elasticsearch: unable to index logs: .*mapper_parsing_exception.*`)
+}
+
+func (s *S) TestElasticsearchLogServiceInvalidResponse(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "cluster unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	service := newElasticsearchLogService(server.URL, "tsuru-logs")
+	_, err := service.List("myapp", 10, Applog{})
+	c.Assert(err, check.ErrorMatches, "elasticsearch: invalid response code 503 from POST /tsuru-logs/_search: cluster unavailable")
+}
+
+func (s *S) TestAppLogUsesPoolLogService(c *check.C) {
+	fake := newFakeElasticsearch()
+	defer fake.Close()
+	conf := LogServiceConfig{Backend: "elasticsearch", URL: fake.server.URL}
+	err := conf.Save(s.Pool)
+	c.Assert(err, check.IsNil)
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err = a.Log("some message\nother message", "tsuru", "api")
+	c.Assert(err, check.IsNil)
+	entries := fake.entries("tsuru-logs")
+	c.Assert(entries, check.HasLen, 2)
+	c.Assert(entries[0].AppName, check.Equals, a.Name)
+	c.Assert(entries[0].Message, check.Equals, "some message")
+	logs, err := a.LastLogs(10, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 2)
+	c.Assert(logs[1].Message, check.Equals, "other message")
+	count, err := s.logConn.Logs(a.Name).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	err = Delete(a, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.entries("tsuru-logs"), check.HasLen, 0)
+}
+
+func (s *S) TestLogDispatcherUsesPoolLogService(c *check.C) {
+	fake := newFakeElasticsearch()
+	defer fake.Close()
+	conf := LogServiceConfig{Backend: "elasticsearch", URL: fake.server.URL}
+	err := conf.Save(s.Pool)
+	c.Assert(err, check.IsNil)
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	dispatcher := NewlogDispatcher(10, 1)
+	dispatcher.Send(&Applog{Date: time.Now(), Message: "from unit", Source: "web", AppName: a.Name, Unit: "u1"})
+	timeout := time.After(5 * time.Second)
+	for len(fake.entries("tsuru-logs")) == 0 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for logs to be flushed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	dispatcher.Stop()
+	entries := fake.entries("tsuru-logs")
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Message, check.Equals, "from unit")
+	c.Assert(entries[0].AppName, check.Equals, a.Name)
+}
+
+func (s *S) TestLogDispatcherResolvesLogServiceAgain(c *check.C) {
+	defer func(ttl time.Duration) {
+		logServiceTTL = ttl
+	}(logServiceTTL)
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	d := &appLogDispatcher{appName: a.Name}
+	service, err := d.logService()
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.FitsTypeOf, &mongoLogService{})
+	fake := newFakeElasticsearch()
+	defer fake.Close()
+	conf := LogServiceConfig{Backend: "elasticsearch", URL: fake.server.URL}
+	err = conf.Save(s.Pool)
+	c.Assert(err, check.IsNil)
+	service, err = d.logService()
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.FitsTypeOf, &mongoLogService{})
+	logServiceTTL = 0
+	service, err = d.logService()
+	c.Assert(err, check.IsNil)
+	c.Assert(service, check.FitsTypeOf, &elasticsearchLogService{})
+}
@@ -0,0 +1,511 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/yaml.v1"
+)
+
+const (
+	ManifestFieldDescription = "description"
+	ManifestFieldPlan        = "plan"
+	ManifestFieldPool        = "pool"
+	ManifestFieldTeamOwner   = "teamOwner"
+	ManifestFieldEnv         = "env"
+	ManifestFieldCName       = "cname"
+	ManifestFieldService     = "service"
+	ManifestFieldUnits       = "units"
+
+	ManifestActionAdd    = "add"
+	ManifestActionUpdate = "update"
+	ManifestActionRemove = "remove"
+
+	maskedEnvValue = "*****"
+)
+
+// manifestIgnoredEnvs are the environment variables managed by tsuru, which
+// can't be set through manifests.
+var manifestIgnoredEnvs = map[string]bool{
+	"TSURU_APPNAME":     true,
+	"TSURU_APPDIR":      true,
+	"TSURU_APP_TOKEN":   true,
+	TsuruServicesEnvVar: true,
+}
+
+// Manifest is the declarative description of the configuration of an app.
+// Empty description, plan, pool and team owner are left unchanged when the
+// manifest is applied, as are envs, cnames and services when absent. When
+// present, even if empty, envs, cnames and services describe the complete
+// desired state: the ones missing in the lists are removed from the app.
+// Units only affect the processes listed in the manifest.
+type Manifest struct {
+	Name        string             `json:"name" yaml:"name"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Plan        string             `json:"plan,omitempty" yaml:"plan,omitempty"`
+	Pool        string             `json:"pool,omitempty" yaml:"pool,omitempty"`
+	TeamOwner   string             `json:"teamOwner,omitempty" yaml:"teamOwner,omitempty"`
+	Envs        *[]ManifestEnv     `json:"envs,omitempty" yaml:"envs,omitempty"`
+	CNames      *[]string          `json:"cnames,omitempty" yaml:"cnames,omitempty"`
+	Services    *[]ManifestService `json:"services,omitempty" yaml:"services,omitempty"`
+	Units       map[string]int     `json:"units,omitempty" yaml:"units,omitempty"`
+}
+
+type ManifestEnv struct {
+	Name    string `json:"name" yaml:"name"`
+	Value   string `json:"value" yaml:"value"`
+	Private bool   `json:"private,omitempty" yaml:"private,omitempty"`
+}
+
+type ManifestService struct {
+	Service  string `json:"service" yaml:"service"`
+	Instance string `json:"instance" yaml:"instance"`
+}
+
+// ManifestChange is one change needed to make an app match a manifest. The
+// values of private envs are masked in Old and New.
+type ManifestChange struct {
+	Field  string `json:"field"`
+	Action string `json:"action"`
+	Name   string `json:"name,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+
+	env      *bind.EnvVar
+	units    int
+	instance *service.ServiceInstance
+}
+
+func (c *ManifestChange) String() string {
+	desc := c.Field
+	if c.Name != "" {
+		desc += " " + c.Name
+	}
+	switch {
+	case c.Old != "" && c.New != "":
+		return fmt.Sprintf("%s: update from %q to %q", desc, c.Old, c.New)
+	case c.Action == ManifestActionRemove:
+		return fmt.Sprintf("%s: remove", desc)
+	case c.New != "":
+		return fmt.Sprintf("%s: add %q", desc, c.New)
+	}
+	return fmt.Sprintf("%s: add", desc)
+}
+
+// MaskEnvValue hides the current value of an env change, so the change can
+// be shown to users not allowed to read the envs of the app.
+func (c *ManifestChange) MaskEnvValue() {
+	if c.Field == ManifestFieldEnv && c.Old != "" {
+		c.Old = maskedEnvValue
+	}
+}
+
+// Env returns the environment variable set by the change, or nil if the
+// change doesn't set an environment variable.
+func (c *ManifestChange) Env() *bind.EnvVar {
+	return c.env
+}
+
+// ServiceInstance returns the service instance bound or unbound by the
+// change, or nil if the change isn't related to services.
+func (c *ManifestChange) ServiceInstance() *service.ServiceInstance {
+	return c.instance
+}
+
+// ParseManifest parses a manifest in YAML or JSON format.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	err := yaml.Unmarshal(data, &m)
+	if err != nil {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("unable to parse manifest: %s", err)}
+	}
+	return &m, nil
+}
+
+// Manifest returns the manifest describing the current configuration of the
+// app. It includes the values of private envs.
+func (app *App) Manifest() (*Manifest, error) {
+	envs, err := app.DecryptedEnvs()
+	if err != nil {
+		return nil, err
+	}
+	manifestEnvs := []ManifestEnv{}
+	for _, env := range envs {
+		if env.InstanceName != "" || manifestIgnoredEnvs[env.Name] {
+			continue
+		}
+		manifestEnvs = append(manifestEnvs, ManifestEnv{Name: env.Name, Value: env.Value, Private: !env.Public})
+	}
+	sort.Sort(manifestEnvsByName(manifestEnvs))
+	cnames := append([]string{}, app.CName...)
+	sort.Strings(cnames)
+	instances, err := app.serviceInstances()
+	if err != nil {
+		return nil, err
+	}
+	services := []ManifestService{}
+	for _, instance := range instances {
+		services = append(services, ManifestService{Service: instance.ServiceName, Instance: instance.Name})
+	}
+	sort.Sort(manifestServicesByName(services))
+	m := Manifest{
+		Name:        app.Name,
+		Description: app.Description,
+		Plan:        app.Plan.Name,
+		Pool:        app.Pool,
+		TeamOwner:   app.TeamOwner,
+		Envs:        &manifestEnvs,
+		CNames:      &cnames,
+		Services:    &services,
+	}
+	units, err := app.Units()
+	if err != nil {
+		return nil, err
+	}
+	if len(units) > 0 {
+		m.Units = map[string]int{}
+		for _, u := range units {
+			m.Units[u.ProcessName]++
+		}
+	}
+	return &m, nil
+}
+
+// DiffManifest validates the manifest and returns the changes needed to make
+// the app match it, sorted in the order they are applied.
+func (app *App) DiffManifest(m *Manifest) ([]ManifestChange, error) {
+	err := app.validateManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	current, err := app.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var changes []ManifestChange
+	scalars := []struct {
+		field, old, new string
+	}{
+		{ManifestFieldDescription, current.Description, m.Description},
+		{ManifestFieldPlan, current.Plan, m.Plan},
+		{ManifestFieldPool, current.Pool, m.Pool},
+		{ManifestFieldTeamOwner, current.TeamOwner, m.TeamOwner},
+	}
+	for _, s := range scalars {
+		if s.new != "" && s.new != s.old {
+			changes = append(changes, ManifestChange{Field: s.field, Action: ManifestActionUpdate, Old: s.old, New: s.new})
+		}
+	}
+	if m.Envs != nil {
+		changes = append(changes, diffManifestEnvs(*current.Envs, *m.Envs)...)
+	}
+	if m.CNames != nil {
+		changes = append(changes, diffManifestList(ManifestFieldCName, *current.CNames, *m.CNames)...)
+	}
+	if m.Services != nil {
+		serviceChanges, err := diffManifestServices(*current.Services, *m.Services)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, serviceChanges...)
+	}
+	processes := make([]string, 0, len(m.Units))
+	for process := range m.Units {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+	for _, process := range processes {
+		old, new := current.Units[process], m.Units[process]
+		if old == new {
+			continue
+		}
+		change := ManifestChange{
+			Field:  ManifestFieldUnits,
+			Action: ManifestActionAdd,
+			Name:   process,
+			Old:    strconv.Itoa(old),
+			New:    strconv.Itoa(new),
+			units:  new - old,
+		}
+		if new < old {
+			change.Action = ManifestActionRemove
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (app *App) validateManifest(m *Manifest) error {
+	if m.Name != "" && m.Name != app.Name {
+		return &errors.ValidationError{Message: fmt.Sprintf("manifest is for the app %q, not %q", m.Name, app.Name)}
+	}
+	var envs []ManifestEnv
+	if m.Envs != nil {
+		envs = *m.Envs
+	}
+	names := map[string]bool{}
+	for _, env := range envs {
+		if env.Name == "" {
+			return &errors.ValidationError{Message: "env names must not be empty"}
+		}
+		if names[env.Name] {
+			return &errors.ValidationError{Message: fmt.Sprintf("env %q is duplicated", env.Name)}
+		}
+		names[env.Name] = true
+		if manifestIgnoredEnvs[env.Name] {
+			return &errors.ValidationError{Message: fmt.Sprintf("env %q is managed by tsuru and can't be set", env.Name)}
+		}
+		if current, ok := app.Env[env.Name]; ok && current.InstanceName != "" {
+			return &errors.ValidationError{Message: fmt.Sprintf("env %q is set by the service instance %q", env.Name, current.InstanceName)}
+		}
+	}
+	for process, units := range m.Units {
+		if units < 0 {
+			return &errors.ValidationError{Message: fmt.Sprintf("invalid number of units for the process %q: %d", process, units)}
+		}
+	}
+	return nil
+}
+
+func diffManifestEnvs(current, desired []ManifestEnv) []ManifestChange {
+	currentMap := make(map[string]ManifestEnv, len(current))
+	for _, env := range current {
+		currentMap[env.Name] = env
+	}
+	desiredMap := make(map[string]ManifestEnv, len(desired))
+	for _, env := range desired {
+		desiredMap[env.Name] = env
+	}
+	names := make([]string, 0, len(currentMap)+len(desiredMap))
+	for name := range currentMap {
+		names = append(names, name)
+	}
+	for name := range desiredMap {
+		if _, ok := currentMap[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var changes []ManifestChange
+	for _, name := range names {
+		old, hasOld := currentMap[name]
+		new, hasNew := desiredMap[name]
+		if !hasNew {
+			changes = append(changes, ManifestChange{
+				Field:  ManifestFieldEnv,
+				Action: ManifestActionRemove,
+				Name:   name,
+				Old:    old.displayValue(),
+			})
+			continue
+		}
+		if hasOld && old == new {
+			continue
+		}
+		change := ManifestChange{
+			Field:  ManifestFieldEnv,
+			Action: ManifestActionAdd,
+			Name:   name,
+			New:    new.displayValue(),
+			env:    &bind.EnvVar{Name: new.Name, Value: new.Value, Public: !new.Private},
+		}
+		if hasOld {
+			change.Action = ManifestActionUpdate
+			change.Old = old.displayValue()
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func (e ManifestEnv) displayValue() string {
+	if e.Private {
+		return maskedEnvValue
+	}
+	return e.Value
+}
+
+func diffManifestList(field string, current, desired []string) []ManifestChange {
+	currentSet := make(map[string]bool, len(current))
+	for _, item := range current {
+		currentSet[item] = true
+	}
+	desiredSet := make(map[string]bool, len(desired))
+	for _, item := range desired {
+		desiredSet[item] = true
+	}
+	var changes []ManifestChange
+	for _, item := range current {
+		if !desiredSet[item] {
+			changes = append(changes, ManifestChange{Field: field, Action: ManifestActionRemove, Name: item})
+		}
+	}
+	added := []string{}
+	for item := range desiredSet {
+		if !currentSet[item] {
+			added = append(added, item)
+		}
+	}
+	sort.Strings(added)
+	for _, item := range added {
+		changes = append(changes, ManifestChange{Field: field, Action: ManifestActionAdd, Name: item})
+	}
+	return changes
+}
+
+func diffManifestServices(current, desired []ManifestService) ([]ManifestChange, error) {
+	key := func(s ManifestService) string {
+		return s.Service + "/" + s.Instance
+	}
+	currentNames := make([]string, len(current))
+	for i, s := range current {
+		currentNames[i] = key(s)
+	}
+	desiredNames := make([]string, len(desired))
+	for i, s := range desired {
+		if s.Service == "" || s.Instance == "" {
+			return nil, &errors.ValidationError{Message: "services must have both the service and the instance names"}
+		}
+		desiredNames[i] = key(s)
+	}
+	changes := diffManifestList(ManifestFieldService, currentNames, desiredNames)
+	for i := range changes {
+		parts := strings.SplitN(changes[i].Name, "/", 2)
+		instance, err := service.GetServiceInstance(parts[0], parts[1])
+		if err != nil {
+			if err == service.ErrServiceInstanceNotFound {
+				return nil, &errors.ValidationError{Message: fmt.Sprintf("service instance %q not found", changes[i].Name)}
+			}
+			return nil, err
+		}
+		changes[i].instance = instance
+	}
+	return changes, nil
+}
+
+// ApplyManifest applies the changes returned by DiffManifest, using the
+// regular operations of apps. The app is restarted once at the end if its
+// envs or services changed.
+func (app *App) ApplyManifest(changes []ManifestChange, w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	var update App
+	var setEnvs []bind.EnvVar
+	var unsetEnvs, addCNames, removeCNames []string
+	var restart bool
+	for _, change := range changes {
+		switch change.Field {
+		case ManifestFieldDescription:
+			update.Description = change.New
+		case ManifestFieldPlan:
+			update.Plan.Name = change.New
+		case ManifestFieldPool:
+			update.Pool = change.New
+		case ManifestFieldTeamOwner:
+			update.TeamOwner = change.New
+		case ManifestFieldEnv:
+			if change.Action == ManifestActionRemove {
+				unsetEnvs = append(unsetEnvs, change.Name)
+			} else {
+				setEnvs = append(setEnvs, *change.env)
+			}
+		case ManifestFieldCName:
+			if change.Action == ManifestActionRemove {
+				removeCNames = append(removeCNames, change.Name)
+			} else {
+				addCNames = append(addCNames, change.Name)
+			}
+		}
+	}
+	fmt.Fprintf(w, "---- Applying %d changes to the app %q ----\n", len(changes), app.Name)
+	for _, change := range changes {
+		fmt.Fprintf(w, "  %s\n", change.String())
+	}
+	if update.Description != "" || update.Plan.Name != "" || update.Pool != "" || update.TeamOwner != "" {
+		err := app.Update(update, w)
+		if err != nil {
+			return err
+		}
+	}
+	if len(setEnvs) > 0 {
+		err := app.setEnvsToApp(bind.SetEnvApp{Envs: setEnvs, PublicOnly: true}, w)
+		if err != nil {
+			return err
+		}
+		restart = true
+	}
+	if len(unsetEnvs) > 0 {
+		err := app.unsetEnvsToApp(bind.UnsetEnvApp{VariableNames: unsetEnvs}, w)
+		if err != nil {
+			return err
+		}
+		restart = true
+	}
+	if len(removeCNames) > 0 {
+		err := app.RemoveCName(removeCNames...)
+		if err != nil {
+			return err
+		}
+	}
+	if len(addCNames) > 0 {
+		err := app.AddCName(addCNames...)
+		if err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		var err error
+		switch {
+		case change.Field == ManifestFieldService && change.Action == ManifestActionRemove:
+			err = change.instance.UnbindApp(app, false, w)
+			restart = true
+		case change.Field == ManifestFieldService:
+			err = change.instance.BindApp(app, false, w)
+			restart = true
+		case change.Field == ManifestFieldUnits && change.units > 0:
+			err = app.AddUnits(uint(change.units), change.Name, w)
+		case change.Field == ManifestFieldUnits:
+			err = app.RemoveUnits(uint(-change.units), change.Name, w)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !restart {
+		return nil
+	}
+	units, err := app.Units()
+	if err != nil || len(units) == 0 {
+		return err
+	}
+	return app.Restart("", w)
+}
+
+type manifestEnvsByName []ManifestEnv
+
+func (l manifestEnvsByName) Len() int           { return len(l) }
+func (l manifestEnvsByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l manifestEnvsByName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+
+type manifestServicesByName []ManifestService
+
+func (l manifestServicesByName) Len() int      { return len(l) }
+func (l manifestServicesByName) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l manifestServicesByName) Less(i, j int) bool {
+	if l[i].Service == l[j].Service {
+		return l[i].Instance < l[j].Instance
+	}
+	return l[i].Service < l[j].Service
+}
@@ -0,0 +1,333 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+// manifestTestEnvs returns the envs of the app used by the manifest tests,
+// including one set by tsuru and one set by a service instance.
+func manifestTestEnvs() map[string]bind.EnvVar {
+	return map[string]bind.EnvVar{
+		"TSURU_APPNAME": {Name: "TSURU_APPNAME", Value: "myapp"},
+		"DEBUG":         {Name: "DEBUG", Value: "1", Public: true},
+		"PASSWORD":      {Name: "PASSWORD", Value: "secret"},
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", InstanceName: "mydb"},
+	}
+}
+
+func (s *S) TestParseManifest(c *check.C) {
+	expected := &Manifest{
+		Name:   "myapp",
+		Plan:   "default-plan",
+		Envs:   &[]ManifestEnv{{Name: "DEBUG", Value: "1"}, {Name: "PASSWORD", Value: "secret", Private: true}},
+		CNames: &[]string{"myapp.example.com"},
+		Units:  map[string]int{"web": 2},
+	}
+	m, err := ParseManifest([]byte(`name: myapp
+plan: default-plan
+envs:
+- name: DEBUG
+  value: "1"
+- name: PASSWORD
+  value: secret
+  private: true
+cnames:
+- myapp.example.com
+units:
+  web: 2
+`))
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, expected)
+	m, err = ParseManifest([]byte(`{
+  "name": "myapp",
+  "plan": "default-plan",
+  "envs": [{"name": "DEBUG", "value": "1"}, {"name": "PASSWORD", "value": "secret", "private": true}],
+  "cnames": ["myapp.example.com"],
+  "units": {"web": 2}
+}`))
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, expected)
+}
+
+func (s *S) TestParseManifestInvalid(c *check.C) {
+	_, err := ParseManifest([]byte("name: [myapp"))
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, "unable to parse manifest: .*")
+}
+
+func (s *S) TestAppManifest(c *check.C) {
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	instance := service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Apps: []string{a.Name}}
+	err := instance.Create()
+	c.Assert(err, check.IsNil)
+	m, err := a.Manifest()
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &Manifest{
+		Name:        "myapp",
+		Description: "my app",
+		Plan:        "default-plan",
+		Pool:        "pool1",
+		TeamOwner:   s.team.Name,
+		Envs: &[]ManifestEnv{
+			{Name: "DEBUG", Value: "1"},
+			{Name: "PASSWORD", Value: "secret", Private: true},
+		},
+		CNames:   &[]string{"myapp.example.com"},
+		Services: &[]ManifestService{{Service: "mysql", Instance: "mydb"}},
+		Units:    map[string]int{"web": 2},
+	})
+}
+
+func (s *S) TestAppManifestDecryptsEnvs(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1})()
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "PASSWORD", Value: "secret"}}}, nil)
+	c.Assert(err, check.IsNil)
+	m, err := a.Manifest()
+	c.Assert(err, check.IsNil)
+	c.Assert(*m.Envs, check.DeepEquals, []ManifestEnv{{Name: "PASSWORD", Value: "secret", Private: true}})
+	c.Assert(m.Units, check.IsNil)
+}
+
+func (s *S) TestDiffManifest(c *check.C) {
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	err := s.conn.Plans().Insert(Plan{Name: "large-plan", Memory: 4096})
+	c.Assert(err, check.IsNil)
+	changes, err := a.DiffManifest(&Manifest{
+		Name:      "myapp",
+		Plan:      "large-plan",
+		TeamOwner: s.team.Name,
+		Envs: &[]ManifestEnv{
+			{Name: "DEBUG", Value: "0"},
+			{Name: "PASSWORD", Value: "other", Private: true},
+			{Name: "WORKERS", Value: "4"},
+		},
+		CNames: &[]string{"myapp.example.org"},
+		Units:  map[string]int{"web": 1, "worker": 2},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 8)
+	for i := range changes {
+		changes[i].env = nil
+	}
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Field: "plan", Action: "update", Old: "default-plan", New: "large-plan"},
+		{Field: "env", Action: "update", Name: "DEBUG", Old: "1", New: "0"},
+		{Field: "env", Action: "update", Name: "PASSWORD", Old: "*****", New: "*****"},
+		{Field: "env", Action: "add", Name: "WORKERS", New: "4"},
+		{Field: "cname", Action: "remove", Name: "myapp.example.com"},
+		{Field: "cname", Action: "add", Name: "myapp.example.org"},
+		{Field: "units", Action: "remove", Name: "web", Old: "2", New: "1", units: -1},
+		{Field: "units", Action: "add", Name: "worker", Old: "0", New: "2", units: 2},
+	})
+	c.Assert(changes[0].String(), check.Equals, `plan: update from "default-plan" to "large-plan"`)
+	c.Assert(changes[4].String(), check.Equals, `cname myapp.example.com: remove`)
+	c.Assert(changes[5].String(), check.Equals, `cname myapp.example.org: add`)
+}
+
+func (s *S) TestDiffManifestNoChanges(c *check.C) {
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	m, err := a.Manifest()
+	c.Assert(err, check.IsNil)
+	changes, err := a.DiffManifest(m)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 0)
+}
+
+func (s *S) TestDiffManifestRemovesMissingEnvs(c *check.C) {
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	changes, err := a.DiffManifest(&Manifest{Envs: &[]ManifestEnv{}, CNames: &[]string{"myapp.example.com"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Field: "env", Action: "remove", Name: "DEBUG", Old: "1"},
+		{Field: "env", Action: "remove", Name: "PASSWORD", Old: "*****"},
+	})
+}
+
+func (s *S) TestDiffManifestIgnoresAbsentFields(c *check.C) {
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	m, err := ParseManifest([]byte("description: other description\n"))
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Envs, check.IsNil)
+	c.Assert(m.CNames, check.IsNil)
+	c.Assert(m.Services, check.IsNil)
+	changes, err := a.DiffManifest(m)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Field: "description", Action: "update", Old: "my app", New: "other description"},
+	})
+	m, err = ParseManifest([]byte("cnames: []\n"))
+	c.Assert(err, check.IsNil)
+	c.Assert(m.CNames, check.DeepEquals, &[]string{})
+	changes, err = a.DiffManifest(m)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Field: "cname", Action: "remove", Name: "myapp.example.com"},
+	})
+}
+
+func (s *S) TestDiffManifestValidation(c *check.C) {
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	tests := []struct {
+		manifest Manifest
+		msg      string
+	}{
+		{Manifest{Name: "otherapp"}, `manifest is for the app "otherapp", not "myapp"`},
+		{Manifest{Envs: &[]ManifestEnv{{Value: "1"}}}, `env names must not be empty`},
+		{Manifest{Envs: &[]ManifestEnv{{Name: "A"}, {Name: "A"}}}, `env "A" is duplicated`},
+		{Manifest{Envs: &[]ManifestEnv{{Name: "TSURU_APPNAME"}}}, `env "TSURU_APPNAME" is managed by tsuru and can't be set`},
+		{Manifest{Envs: &[]ManifestEnv{{Name: "DATABASE_HOST"}}}, `env "DATABASE_HOST" is set by the service instance "mydb"`},
+		{Manifest{Units: map[string]int{"web": -1}}, `invalid number of units for the process "web": -1`},
+		{Manifest{Services: &[]ManifestService{{Service: "mysql"}}}, `services must have both the service and the instance names`},
+		{Manifest{Services: &[]ManifestService{{Service: "mysql", Instance: "mydb"}}}, `service instance "mysql/mydb" not found`},
+	}
+	for _, tt := range tests {
+		_, err := a.DiffManifest(&tt.manifest)
+		c.Check(err, check.FitsTypeOf, &errors.ValidationError{})
+		c.Check(err, check.ErrorMatches, tt.msg)
+	}
+}
+
+func (s *S) TestApplyManifest(c *check.C) {
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	changes, err := a.DiffManifest(&Manifest{
+		Description: "new description",
+		Envs: &[]ManifestEnv{
+			{Name: "PASSWORD", Value: "other", Private: true},
+			{Name: "WORKERS", Value: "4"},
+		},
+		CNames: &[]string{"myapp.example.com", "myapp.example.org"},
+		Units:  map[string]int{"web": 3},
+	})
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = a.ApplyManifest(changes, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s)---- Applying 6 changes to the app "myapp" ----
+  description: update from "my app" to "new description"
+  env DEBUG: remove
+  env PASSWORD: update from "\*\*\*\*\*" to "\*\*\*\*\*"
+  env WORKERS: add "4"
+  cname myapp.example.org: add
+  units web: update from "2" to "3"
+.*`)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Description, check.Equals, "new description")
+	c.Assert(dbApp.CName, check.DeepEquals, []string{"myapp.example.com", "myapp.example.org"})
+	envs, err := dbApp.DecryptedEnvs()
+	c.Assert(err, check.IsNil)
+	c.Assert(envs, check.DeepEquals, map[string]bind.EnvVar{
+		"TSURU_APPNAME": {Name: "TSURU_APPNAME", Value: "myapp"},
+		"PASSWORD":      {Name: "PASSWORD", Value: "other"},
+		"WORKERS":       {Name: "WORKERS", Value: "4", Public: true},
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", InstanceName: "mydb"},
+	})
+	units, err := dbApp.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 3)
+	c.Assert(s.provisioner.Restarts(a, ""), check.Equals, 1)
+	changes, err = dbApp.DiffManifest(&Manifest{
+		Envs:   &[]ManifestEnv{{Name: "PASSWORD", Value: "other", Private: true}, {Name: "WORKERS", Value: "4"}},
+		CNames: &[]string{"myapp.example.com", "myapp.example.org"},
+		Units:  map[string]int{"web": 3},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 0)
+}
+
+func (s *S) TestApplyManifestServices(c *check.C) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == "POST" && r.URL.Path == "/resources/mycache/bind-app" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"REDIS_HOST": "localhost"}`))
+		}
+	}))
+	defer ts.Close()
+	a := s.createTestApp(c, App{
+		Description: "my app",
+		Plan:        s.defaultPlan,
+		CName:       []string{"myapp.example.com"},
+		Env:         manifestTestEnvs(),
+	}, 2)
+	for _, name := range []string{"mysql", "redis"} {
+		srvc := service.Service{Name: name, Endpoint: map[string]string{"production": ts.URL}}
+		err := srvc.Create()
+		c.Assert(err, check.IsNil)
+	}
+	mydb := service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Teams: []string{s.team.Name}, Apps: []string{a.Name}}
+	err := mydb.Create()
+	c.Assert(err, check.IsNil)
+	mycache := service.ServiceInstance{Name: "mycache", ServiceName: "redis", Teams: []string{s.team.Name}}
+	err = mycache.Create()
+	c.Assert(err, check.IsNil)
+	m, err := a.Manifest()
+	c.Assert(err, check.IsNil)
+	m.Services = &[]ManifestService{{Service: "redis", Instance: "mycache"}}
+	changes, err := a.DiffManifest(m)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 2)
+	c.Assert(changes[0].Name, check.Equals, "mysql/mydb")
+	c.Assert(changes[0].Action, check.Equals, ManifestActionRemove)
+	c.Assert(changes[0].ServiceInstance().Name, check.Equals, "mydb")
+	c.Assert(changes[1].Name, check.Equals, "redis/mycache")
+	c.Assert(changes[1].Action, check.Equals, ManifestActionAdd)
+	err = a.ApplyManifest(changes, nil)
+	c.Assert(err, check.IsNil)
+	instances, err := a.serviceInstances()
+	c.Assert(err, check.IsNil)
+	c.Assert(instances, check.HasLen, 1)
+	c.Assert(instances[0].Name, check.Equals, "mycache")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["REDIS_HOST"].Value, check.Equals, "localhost")
+	c.Assert(requests, check.Not(check.HasLen), 0)
+}
@@ -0,0 +1,39 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deployDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tsuru",
+		Subsystem: "deploy",
+		Name:      "duration_seconds",
+		Help:      "The time taken by app deploys, by deploy kind.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 10),
+	}, []string{"kind"})
+
+	deployFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tsuru",
+		Subsystem: "deploy",
+		Name:      "failures_total",
+		Help:      "The number of failed app deploys, by deploy kind.",
+	}, []string{"kind"})
+)
+
+func init() {
+	prometheus.MustRegister(deployDuration, deployFailures)
+}
+
+func observeDeploy(kind DeployKind, duration time.Duration, err error) {
+	deployDuration.WithLabelValues(string(kind)).Observe(duration.Seconds())
+	if err != nil {
+		deployFailures.WithLabelValues(string(kind)).Inc()
+	}
+}
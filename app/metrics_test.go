@@ -0,0 +1,39 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/check.v1"
+)
+
+func deployMetrics(c *check.C, kind DeployKind) (uint64, float64) {
+	var duration, failures dto.Metric
+	err := deployDuration.WithLabelValues(string(kind)).(prometheus.Histogram).Write(&duration)
+	c.Assert(err, check.IsNil)
+	err = deployFailures.WithLabelValues(string(kind)).Write(&failures)
+	c.Assert(err, check.IsNil)
+	return duration.GetHistogram().GetSampleCount(), failures.GetCounter().GetValue()
+}
+
+func (s *S) TestDeployObservesMetrics(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	count, failures := deployMetrics(c, DeployImage)
+	err := Deploy(DeployOptions{App: a, Image: "myimage", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	newCount, newFailures := deployMetrics(c, DeployImage)
+	c.Assert(newCount, check.Equals, count+1)
+	c.Assert(newFailures, check.Equals, failures)
+	s.provisioner.PrepareFailure("ImageDeploy", errors.New("deploy failed"))
+	err = Deploy(DeployOptions{App: a, Image: "myimage", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.NotNil)
+	newCount, newFailures = deployMetrics(c, DeployImage)
+	c.Assert(newCount, check.Equals, count+2)
+	c.Assert(newFailures, check.Equals, failures+1)
+}
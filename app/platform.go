@@ -5,8 +5,10 @@
 package app
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"strconv"
 
 	"github.com/tsuru/tsuru/db"
@@ -46,14 +48,56 @@ func Platforms(enabledOnly bool) ([]Platform, error) {
 	return platforms, err
 }
 
+// extensibleProvisioners returns the extensible provisioners among the
+// default provisioner and the ones initialized for pools, so platforms are
+// available to apps in every pool.
+func extensibleProvisioners() ([]provision.ExtensibleProvisioner, error) {
+	var result []provision.ExtensibleProvisioner
+	var seen []provision.Provisioner
+	for _, p := range append([]provision.Provisioner{Provisioner}, provision.Initialized()...) {
+		duplicated := false
+		for _, s := range seen {
+			if s == p {
+				duplicated = true
+				break
+			}
+		}
+		if duplicated {
+			continue
+		}
+		seen = append(seen, p)
+		if extensible, ok := p.(provision.ExtensibleProvisioner); ok {
+			result = append(result, extensible)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrProvisionerIsNotExtensible
+	}
+	return result, nil
+}
+
+// platformInputs reads the input of the platform once, returning a function
+// that gives each provisioner its own copy of the options.
+func platformInputs(opts provision.PlatformOptions) (func() provision.PlatformOptions, error) {
+	if opts.Input == nil {
+		return func() provision.PlatformOptions { return opts }, nil
+	}
+	data, err := ioutil.ReadAll(opts.Input)
+	if err != nil {
+		return nil, err
+	}
+	return func() provision.PlatformOptions {
+		provOpts := opts
+		provOpts.Input = bytes.NewReader(data)
+		return provOpts
+	}, nil
+}
+
 // PlatformAdd add a new platform to tsuru
 func PlatformAdd(opts provision.PlatformOptions) error {
-	var (
-		provisioner provision.ExtensibleProvisioner
-		ok          bool
-	)
-	if provisioner, ok = Provisioner.(provision.ExtensibleProvisioner); !ok {
-		return ErrProvisionerIsNotExtensible
+	provisioners, err := extensibleProvisioners()
+	if err != nil {
+		return err
 	}
 	if opts.Name == "" {
 		return ErrPlatformNameMissing
@@ -71,7 +115,20 @@ func PlatformAdd(opts provision.PlatformOptions) error {
 		}
 		return err
 	}
-	err = provisioner.PlatformAdd(opts)
+	nextOpts, err := platformInputs(opts)
+	if err == nil {
+		for i, provisioner := range provisioners {
+			err = provisioner.PlatformAdd(nextOpts())
+			if err != nil {
+				for _, added := range provisioners[:i] {
+					if rmErr := added.PlatformRemove(opts.Name); rmErr != nil {
+						log.Errorf("Failed to remove platform from provisioner: %s", rmErr)
+					}
+				}
+				break
+			}
+		}
+	}
 	if err != nil {
 		dbErr := conn.Platforms().RemoveId(p.Name)
 		if dbErr != nil {
@@ -83,13 +140,10 @@ func PlatformAdd(opts provision.PlatformOptions) error {
 }
 
 func PlatformUpdate(opts provision.PlatformOptions) error {
-	var (
-		provisioner provision.ExtensibleProvisioner
-		platform    Platform
-		ok          bool
-	)
-	if provisioner, ok = Provisioner.(provision.ExtensibleProvisioner); !ok {
-		return ErrProvisionerIsNotExtensible
+	var platform Platform
+	provisioners, err := extensibleProvisioners()
+	if err != nil {
+		return err
 	}
 	if opts.Name == "" {
 		return ErrPlatformNameMissing
@@ -107,10 +161,16 @@ func PlatformUpdate(opts provision.PlatformOptions) error {
 		return err
 	}
 	if opts.Args["dockerfile"] != "" || opts.Input != nil {
-		err = provisioner.PlatformUpdate(opts)
+		nextOpts, err := platformInputs(opts)
 		if err != nil {
 			return err
 		}
+		for _, provisioner := range provisioners {
+			err = provisioner.PlatformUpdate(nextOpts())
+			if err != nil {
+				return err
+			}
+		}
 		var apps []App
 		err = conn.Apps().Find(bson.M{"framework": opts.Name}).All(&apps)
 		if err != nil {
@@ -134,12 +194,9 @@ func PlatformUpdate(opts provision.PlatformOptions) error {
 }
 
 func PlatformRemove(name string) error {
-	var (
-		provisioner provision.ExtensibleProvisioner
-		ok          bool
-	)
-	if provisioner, ok = Provisioner.(provision.ExtensibleProvisioner); !ok {
-		return ErrProvisionerIsNotExtensible
+	provisioners, err := extensibleProvisioners()
+	if err != nil {
+		return err
 	}
 	if name == "" {
 		return ErrPlatformNameMissing
@@ -153,9 +210,11 @@ func PlatformRemove(name string) error {
 	if apps > 0 {
 		return ErrDeletePlatformWithApps
 	}
-	err = provisioner.PlatformRemove(name)
-	if err != nil {
-		log.Errorf("Failed to remove platform from provisioner: %s", err)
+	for _, provisioner := range provisioners {
+		err = provisioner.PlatformRemove(name)
+		if err != nil {
+			log.Errorf("Failed to remove platform from provisioner: %s", err)
+		}
 	}
 	err = conn.Platforms().Remove(bson.M{"_id": name})
 	if err == mgo.ErrNotFound {
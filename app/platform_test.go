@@ -181,6 +181,72 @@ func (s *PlatformSuite) TestPlatformAddWithProvisionerError(c *check.C) {
 	c.Assert(count, check.Equals, 0)
 }
 
+func (s *PlatformSuite) TestPlatformInPoolProvisioners(c *check.C) {
+	provisioner := provisiontest.ExtensibleFakeProvisioner{
+		FakeProvisioner: provisiontest.NewFakeProvisioner(),
+	}
+	Provisioner = &provisioner
+	poolProvisioner := provisiontest.ExtensibleFakeProvisioner{
+		FakeProvisioner: provisiontest.NewFakeProvisioner(),
+	}
+	provision.Register("platform-pool-provisioner", &poolProvisioner)
+	defer func() {
+		Provisioner = s.provisioner
+		// initialized provisioners can't be dropped, replacing it by the
+		// default one stops it from being used by other tests.
+		provision.Register("platform-pool-provisioner", s.provisioner)
+	}()
+	_, err := provision.Initialize("platform-pool-provisioner")
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	name := "test_platform_pool"
+	args := map[string]string{"dockerfile": "http://localhost/Dockerfile"}
+	err = PlatformAdd(provision.PlatformOptions{Name: name, Args: args, Input: bytes.NewBufferString("FROM tsuru/base")})
+	c.Assert(err, check.IsNil)
+	defer conn.Platforms().Remove(bson.M{"_id": name})
+	c.Assert(provisioner.GetPlatform(name), check.NotNil)
+	c.Assert(poolProvisioner.GetPlatform(name), check.NotNil)
+	err = PlatformUpdate(provision.PlatformOptions{Name: name, Args: args})
+	c.Assert(err, check.IsNil)
+	c.Assert(provisioner.GetPlatform(name).Version, check.Equals, 2)
+	c.Assert(poolProvisioner.GetPlatform(name).Version, check.Equals, 2)
+	err = PlatformRemove(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(provisioner.GetPlatform(name), check.IsNil)
+	c.Assert(poolProvisioner.GetPlatform(name), check.IsNil)
+}
+
+func (s *PlatformSuite) TestPlatformAddPoolProvisionerError(c *check.C) {
+	provisioner := provisiontest.ExtensibleFakeProvisioner{
+		FakeProvisioner: provisiontest.NewFakeProvisioner(),
+	}
+	Provisioner = &provisioner
+	poolProvisioner := provisiontest.ExtensibleFakeProvisioner{
+		FakeProvisioner: provisiontest.NewFakeProvisioner(),
+	}
+	poolProvisioner.PrepareFailure("PlatformAdd", errors.New("build error"))
+	provision.Register("platform-pool-provisioner", &poolProvisioner)
+	defer func() {
+		Provisioner = s.provisioner
+		provision.Register("platform-pool-provisioner", s.provisioner)
+	}()
+	_, err := provision.Initialize("platform-pool-provisioner")
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	name := "test_platform_pool"
+	err = PlatformAdd(provision.PlatformOptions{Name: name})
+	defer conn.Platforms().Remove(bson.M{"_id": name})
+	c.Assert(err, check.ErrorMatches, "build error")
+	c.Assert(provisioner.GetPlatform(name), check.IsNil)
+	count, err := conn.Platforms().FindId(name).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
 func (s *PlatformSuite) TestPlatformAddNotExtensibleProvisioner(c *check.C) {
 	err := PlatformAdd(provision.PlatformOptions{Name: "python"})
 	c.Assert(err, check.Equals, ErrProvisionerIsNotExtensible)
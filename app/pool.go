@@ -0,0 +1,152 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrAppAlreadyInPool       = errors.New("app is already in the given pool")
+	ErrPoolChangeNotSupported = errors.New("the provisioner of the new pool does not support image deploys")
+	ErrNoImageToMigrate       = errors.New("no image available to deploy in the new pool")
+)
+
+// ChangePool moves the app to another pool. When both pools use the same
+// provisioner, only the pool of the app is updated. Otherwise, the current
+// image of the app is deployed in the provisioner of the new pool, routes are
+// switched to the new units and the units in the old provisioner are removed.
+func (app *App) ChangePool(poolName string, w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	pool, err := app.GetPoolForApp(poolName)
+	if err != nil {
+		return err
+	}
+	if pool == app.Pool {
+		return ErrAppAlreadyInPool
+	}
+	oldProv, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	newProv, err := provisionerForPool(pool)
+	if err != nil {
+		return err
+	}
+	if oldProv == newProv {
+		app.Pool = pool
+		return app.savePool()
+	}
+	deployer, ok := newProv.(provision.ImageDeployer)
+	if !ok {
+		return ErrPoolChangeNotSupported
+	}
+	if app.Canary != nil {
+		return ErrCanaryInProgress
+	}
+	var image string
+	if app.Deploys > 0 {
+		images, err := oldProv.ValidAppImages(app.Name)
+		if err != nil {
+			return err
+		}
+		if len(images) == 0 {
+			return ErrNoImageToMigrate
+		}
+		image = images[len(images)-1]
+	}
+	oldPool := app.Pool
+	app.Pool = pool
+	err = newProv.Provision(app)
+	if err != nil && err != router.ErrBackendExists {
+		app.Pool = oldPool
+		return err
+	}
+	if image != "" {
+		fmt.Fprintf(w, "---- Deploying image %s in pool %s ----\n", image, pool)
+		_, err = deployer.ImageDeploy(app, image, w)
+		if err != nil {
+			fmt.Fprintf(w, "---- Removing units from pool %s ----\n", pool)
+			if rmErr := removeAllUnits(newProv, app, w); rmErr != nil {
+				log.Errorf("[change-pool] unable to remove units of app %s from pool %s: %s", app.Name, pool, rmErr)
+			}
+			app.Pool = oldPool
+			return err
+		}
+	}
+	err = app.savePool()
+	if err != nil {
+		return err
+	}
+	if image == "" {
+		return nil
+	}
+	_, err = app.RebuildRoutes()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "---- Removing units from pool %s ----\n", oldPool)
+	// The app is not destroyed in the old provisioner, as its image and its
+	// router backend are now used by the new one.
+	app.Pool = oldPool
+	err = removeAllUnits(oldProv, app, w)
+	app.Pool = pool
+	if err != nil {
+		return err
+	}
+	units, err := newProv.Units(app)
+	if err != nil {
+		return err
+	}
+	return app.SetQuotaInUse(len(units))
+}
+
+func (app *App) savePool() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"pool": app.Pool}})
+}
+
+// removeAllUnits removes all units of the app from the given provisioner,
+// process by process.
+func removeAllUnits(prov provision.Provisioner, app *App, w io.Writer) error {
+	units, err := prov.Units(app)
+	if err != nil {
+		return err
+	}
+	count := make(map[string]uint)
+	for _, u := range units {
+		if u.ProcessName != "" {
+			count[u.ProcessName]++
+		}
+	}
+	processes := make([]string, 0, len(count))
+	for process := range count {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+	for _, process := range processes {
+		err = prov.RemoveUnits(app, count[process], process, w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+// noImageProvisioner hides the optional interfaces of the wrapped
+// provisioner.
+type noImageProvisioner struct {
+	provision.Provisioner
+}
+
+func (s *S) addPoolWithProvisioner(c *check.C, name string, p provision.Provisioner) {
+	provision.Register("pool-provisioner", p)
+	err := provision.AddPool(provision.AddPoolOptions{Name: name, Public: true, Provisioner: "pool-provisioner"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestGetProvisionerDefault(c *check.C) {
+	a := App{Name: "myapp", Pool: s.Pool}
+	prov, err := a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, s.provisioner)
+	a.Pool = ""
+	prov, err = a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, s.provisioner)
+	a.Pool = "unknown"
+	prov, err = a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, s.provisioner)
+}
+
+func (s *S) TestGetProvisionerFromPool(c *check.C) {
+	p := provisiontest.NewFakeProvisioner()
+	s.addPoolWithProvisioner(c, "pool2", p)
+	a := App{Name: "myapp", Pool: "pool2"}
+	prov, err := a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, p)
+}
+
+func (s *S) TestUnitsUsePoolProvisioner(c *check.C) {
+	p := provisiontest.NewFakeProvisioner()
+	s.addPoolWithProvisioner(c, "pool2", p)
+	a := App{Name: "myapp", Pool: "pool2"}
+	err := p.Provision(&a)
+	c.Assert(err, check.IsNil)
+	_, err = p.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	c.Assert(s.provisioner.GetUnits(&a), check.HasLen, 0)
+}
+
+func (s *S) TestListFilteredByStatusMultipleProvisioners(c *check.C) {
+	p := provisiontest.NewFakeProvisioner()
+	s.addPoolWithProvisioner(c, "pool2", p)
+	a1 := App{Name: "app1", Pool: s.Pool}
+	a2 := App{Name: "app2", Pool: "pool2"}
+	a3 := App{Name: "app3", Pool: "pool2"}
+	for _, a := range []*App{&a1, &a2, &a3} {
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+	}
+	err := s.provisioner.Provision(&a1)
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnits(&a1, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = p.Provision(&a3)
+	c.Assert(err, check.IsNil)
+	_, err = p.AddUnits(&a3, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	apps, err := List(&Filter{Statuses: []string{"started"}})
+	c.Assert(err, check.IsNil)
+	names := make([]string, len(apps))
+	for i := range apps {
+		names[i] = apps[i].Name
+	}
+	c.Assert(names, check.DeepEquals, []string{"app1", "app3"})
+}
+
+func (s *S) TestUpdatePoolDifferentProvisioner(c *check.C) {
+	s.addPoolWithProvisioner(c, "pool2", provisiontest.NewFakeProvisioner())
+	a := s.createTestApp(c, App{Deploys: 1}, 1)
+	err := a.Update(App{Pool: "pool2"}, new(bytes.Buffer))
+	c.Assert(err, check.Equals, ErrPoolProvisionerChange)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, s.Pool)
+}
+
+func (s *S) TestChangePoolSameProvisioner(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	a := s.createTestApp(c, App{Deploys: 1}, 2)
+	err = a.ChangePool("pool2", nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 2)
+}
+
+func (s *S) TestChangePoolDifferentProvisioner(c *check.C) {
+	p := provisiontest.NewFakeProvisioner()
+	s.addPoolWithProvisioner(c, "pool2", p)
+	a := s.createTestApp(c, App{Deploys: 1}, 2)
+	buf := bytes.Buffer{}
+	err := a.ChangePool("pool2", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, "(?s)---- Deploying image app-image in pool pool2 ----.*---- Removing units from pool pool1 ----.*")
+	c.Assert(a.Pool, check.Equals, "pool2")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	c.Assert(dbApp.Quota.InUse, check.Equals, 0)
+	c.Assert(p.Provisioned(a), check.Equals, true)
+	c.Assert(p.Image(a), check.Equals, "app-image")
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 0)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+}
+
+func (s *S) TestChangePoolKeepsOldImage(c *check.C) {
+	p := provisiontest.NewFakeProvisioner()
+	s.addPoolWithProvisioner(c, "pool2", p)
+	a := s.createTestApp(c, App{Deploys: 1}, 1)
+	_, err := s.provisioner.ImageDeploy(a, "app-image", new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	err = a.ChangePool("pool2", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 0)
+	c.Assert(s.provisioner.Provisioned(a), check.Equals, true)
+	c.Assert(s.provisioner.Image(a), check.Equals, "app-image")
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+}
+
+func (s *S) TestChangePoolWithoutDeploys(c *check.C) {
+	p := provisiontest.NewFakeProvisioner()
+	s.addPoolWithProvisioner(c, "pool2", p)
+	a := s.createTestApp(c, App{}, 0)
+	err := a.ChangePool("pool2", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Provisioned(a), check.Equals, true)
+	c.Assert(p.Image(a), check.Equals, "")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+}
+
+func (s *S) TestChangePoolDeployFailure(c *check.C) {
+	p := provisiontest.NewFakeProvisioner()
+	p.PrepareFailure("ImageDeploy", errors.New("deploy failed"))
+	s.addPoolWithProvisioner(c, "pool2", p)
+	a := s.createTestApp(c, App{Deploys: 1}, 2)
+	err := a.ChangePool("pool2", nil)
+	c.Assert(err, check.ErrorMatches, "deploy failed")
+	c.Assert(a.Pool, check.Equals, s.Pool)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, s.Pool)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 2)
+}
+
+func (s *S) TestChangePoolNotSupported(c *check.C) {
+	s.addPoolWithProvisioner(c, "pool2", noImageProvisioner{provisiontest.NewFakeProvisioner()})
+	a := s.createTestApp(c, App{Deploys: 1}, 1)
+	err := a.ChangePool("pool2", nil)
+	c.Assert(err, check.Equals, ErrPoolChangeNotSupported)
+}
+
+func (s *S) TestChangePoolAlreadyInPool(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 1)
+	err := a.ChangePool(s.Pool, nil)
+	c.Assert(err, check.Equals, ErrAppAlreadyInPool)
+}
+
+func (s *S) TestChangePoolNotFound(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 1)
+	err := a.ChangePool("unknown", nil)
+	c.Assert(err, check.ErrorMatches, "pool not found")
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	stderr "errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tsuru/tsuru/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrPromoteNotSupported = stderr.New("the provisioner does not support image promotion")
+
+// PromoteOptions is the set of options used to promote an image from one app
+// to another.
+type PromoteOptions struct {
+	Source       *App
+	Dest         *App
+	Image        string
+	User         string
+	OutputStream io.Writer
+}
+
+// PromoteOrigin returns the origin recorded in the deploys of images
+// promoted from the given app.
+func PromoteOrigin(appName string) string {
+	return "promote:" + appName
+}
+
+// Promote deploys to opts.Dest an image built for opts.Source, keeping the
+// processes and tsuru.yaml data of the image. The image must be one of the
+// valid images of the source app, given either by its full name or by its
+// version (e.g. "v3"). When opts.Image is empty, the current image of the
+// source app is promoted.
+func Promote(opts PromoteOptions) error {
+	if opts.Source.Name == opts.Dest.Name {
+		return &errors.ValidationError{Message: "cannot promote an image to the same app"}
+	}
+	sourceProv, err := opts.Source.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	destProv, err := opts.Dest.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	if sourceProv != destProv {
+		return &errors.ValidationError{Message: fmt.Sprintf("the apps %q and %q must use the same provisioner", opts.Source.Name, opts.Dest.Name)}
+	}
+	image, err := opts.Source.promotableImage(opts.Image)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.OutputStream, "---- Promoting the image %s from the app %q to the app %q ----\n", image, opts.Source.Name, opts.Dest.Name)
+	return Deploy(DeployOptions{
+		App:          opts.Dest,
+		Image:        image,
+		User:         opts.User,
+		Origin:       PromoteOrigin(opts.Source.Name),
+		PromoteFrom:  opts.Source.Name,
+		OutputStream: opts.OutputStream,
+	})
+}
+
+// promotableImage returns the valid image of the app matching the given name
+// or version, or the current image of the app when it's empty.
+func (app *App) promotableImage(image string) (string, error) {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return "", err
+	}
+	images, err := prov.ValidAppImages(app.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(images) == 0 {
+		return "", &errors.ValidationError{Message: fmt.Sprintf("the app %q has no images to promote", app.Name)}
+	}
+	if image == "" {
+		release, err := lastRelease(bson.M{"app": app.Name, "image": bson.M{"$in": images}})
+		if err == nil {
+			return release.Image, nil
+		}
+		if err != ErrReleaseNotFound {
+			return "", err
+		}
+		return images[len(images)-1], nil
+	}
+	for i := len(images) - 1; i >= 0; i-- {
+		if images[i] == image || strings.HasSuffix(images[i], ":"+image) {
+			return images[i], nil
+		}
+	}
+	return "", &errors.ValidationError{Message: fmt.Sprintf("the image %q is not a valid image of the app %q", image, app.Name)}
+}
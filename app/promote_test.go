@@ -0,0 +1,81 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	stderr "errors"
+
+	"github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestPromote(c *check.C) {
+	source := s.createTestApp(c, App{Deploys: 1}, 0)
+	dest := s.createTestApp(c, App{Name: "myapp-prod"}, 0)
+	writer := &bytes.Buffer{}
+	err := Promote(PromoteOptions{Source: source, Dest: dest, User: s.user.Email, OutputStream: writer})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, `---- Promoting the image app-image from the app "myapp" to the app "myapp-prod" ----
+Promote image called`)
+	var deploy DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": dest.Name}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Image, check.Equals, "app-image")
+	c.Assert(deploy.Origin, check.Equals, "promote:myapp")
+	c.Assert(deploy.User, check.Equals, s.user.Email)
+	c.Assert(dest.Deploys, check.Equals, uint(1))
+	releases, err := ListReleases(dest.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 1)
+	c.Assert(releases[0].Image, check.Equals, "app-image")
+}
+
+func (s *S) TestPromoteImageByName(c *check.C) {
+	source := s.createTestApp(c, App{Deploys: 1}, 0)
+	dest := s.createTestApp(c, App{Name: "myapp-prod"}, 0)
+	writer := &bytes.Buffer{}
+	err := Promote(PromoteOptions{Source: source, Dest: dest, Image: "app-image-old", OutputStream: writer})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Matches, `---- Promoting the image app-image-old from .*`)
+}
+
+func (s *S) TestPromoteCurrentReleaseImage(c *check.C) {
+	source := s.createTestApp(c, App{Deploys: 1}, 0)
+	dest := s.createTestApp(c, App{Name: "myapp-prod"}, 0)
+	err := Deploy(DeployOptions{App: source, Image: "app-image-old", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	err = Promote(PromoteOptions{Source: source, Dest: dest, OutputStream: writer})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Matches, `---- Promoting the image app-image-old from .*`)
+}
+
+func (s *S) TestPromoteInvalidImage(c *check.C) {
+	source := s.createTestApp(c, App{Deploys: 1}, 0)
+	dest := s.createTestApp(c, App{Name: "myapp-prod"}, 0)
+	err := Promote(PromoteOptions{Source: source, Dest: dest, Image: "v9", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `the image "v9" is not a valid image of the app "myapp"`)
+	c.Assert(dest.Deploys, check.Equals, uint(0))
+}
+
+func (s *S) TestPromoteSameApp(c *check.C) {
+	source := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := Promote(PromoteOptions{Source: source, Dest: source, OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.ErrorMatches, "cannot promote an image to the same app")
+}
+
+func (s *S) TestPromoteError(c *check.C) {
+	source := s.createTestApp(c, App{Deploys: 1}, 0)
+	dest := s.createTestApp(c, App{Name: "myapp-prod"}, 0)
+	s.provisioner.PrepareFailure("PromoteImage", stderr.New("promote failed"))
+	err := Promote(PromoteOptions{Source: source, Dest: dest, OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.ErrorMatches, "promote failed")
+	releases, err := ListReleases(dest.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 0)
+}
@@ -0,0 +1,468 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type ReleaseReason string
+
+const (
+	ReleaseDeploy   ReleaseReason = "deploy"
+	ReleaseRollback ReleaseReason = "rollback"
+	ReleaseEnvSet   ReleaseReason = "env-set"
+	ReleaseEnvUnset ReleaseReason = "env-unset"
+
+	ReleaseFieldImage     = "image"
+	ReleaseFieldTsuruYaml = "tsuru.yaml"
+
+	maxReleaseCreateRetries = 5
+)
+
+var ErrReleaseNotFound = stderrors.New("release not found")
+
+// Release is an immutable snapshot of an app, holding the image deployed
+// and the configuration in effect when it was created. A new release is
+// created on each deploy and on each change to the environment variables of
+// the app, once it has been deployed.
+//
+// The values of private envs are stored as they're stored in the app,
+// encrypted with the data key in EnvKey when the encryption is enabled.
+type Release struct {
+	App        string                  `json:"app"`
+	Version    int                     `json:"version"`
+	Timestamp  time.Time               `json:"timestamp"`
+	Reason     ReleaseReason           `json:"reason"`
+	User       string                  `json:"user,omitempty" bson:",omitempty"`
+	RollbackOf int                     `json:"rollbackOf,omitempty" bson:",omitempty"`
+	Image      string                  `json:"image"`
+	Envs       map[string]bind.EnvVar  `json:"envs"`
+	EnvKey     *EnvKey                 `json:"-" bson:",omitempty"`
+	Processes  map[string]int          `json:"processes"`
+	TsuruYaml  provision.TsuruYamlData `json:"tsuruYaml"`
+}
+
+// ReleaseChange is a difference between two releases of an app.
+type ReleaseChange struct {
+	Field  string `json:"field"`
+	Action string `json:"action"`
+	Name   string `json:"name,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// ListReleases returns the releases of the app, newest first.
+func ListReleases(appName string) ([]Release, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var releases []Release
+	err = conn.Releases().Find(bson.M{"app": appName}).Sort("-version").All(&releases)
+	if err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// GetRelease returns the release of the app with the given version.
+func GetRelease(appName string, version int) (*Release, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var release Release
+	err = conn.Releases().Find(bson.M{"app": appName, "version": version}).One(&release)
+	if err == mgo.ErrNotFound {
+		return nil, ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+func lastRelease(query bson.M) (*Release, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var release Release
+	err = conn.Releases().Find(query).Sort("-version").One(&release)
+	if err == mgo.ErrNotFound {
+		return nil, ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+func removeReleases(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Releases().RemoveAll(bson.M{"app": appName})
+	return err
+}
+
+// createRelease stores a new release of the app with the image, reason,
+// user and tsuru.yaml data in r, and the envs and number of units of each
+// process currently in the app. Versions are sequential for each app.
+func (app *App) createRelease(r Release) (*Release, error) {
+	units, err := app.Units()
+	if err != nil {
+		return nil, err
+	}
+	r.App = app.Name
+	r.Timestamp = time.Now().UTC()
+	r.Envs = make(map[string]bind.EnvVar, len(app.Env))
+	for name, env := range app.Env {
+		r.Envs[name] = env
+	}
+	if app.EnvKey != nil {
+		envKey := *app.EnvKey
+		r.EnvKey = &envKey
+	}
+	r.Processes = map[string]int{}
+	for _, u := range units {
+		r.Processes[u.ProcessName]++
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	for i := 0; ; i++ {
+		r.Version = 1
+		last, err := lastRelease(bson.M{"app": app.Name})
+		if err == nil {
+			r.Version = last.Version + 1
+		} else if err != ErrReleaseNotFound {
+			return nil, err
+		}
+		err = conn.Releases().Insert(r)
+		if err == nil {
+			return &r, nil
+		}
+		if !mgo.IsDup(err) || i == maxReleaseCreateRetries-1 {
+			return nil, err
+		}
+	}
+}
+
+// createDeployRelease creates the release of a deploy of the given image,
+// with the tsuru.yaml data kept by the provisioner of the app.
+func (app *App) createDeployRelease(opts *DeployOptions, image string) (*Release, error) {
+	r := Release{Reason: ReleaseDeploy, User: opts.User, Image: image}
+	if opts.Rollback {
+		r.Reason = ReleaseRollback
+		r.RollbackOf = opts.Release
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	if yamlProv, ok := prov.(provision.TsuruYamlProvisioner); ok {
+		r.TsuruYaml, err = yamlProv.ImageTsuruYamlData(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return app.createRelease(r)
+}
+
+// createEnvRelease creates a release after a change in the envs of the app,
+// keeping the image and tsuru.yaml data of the last release. Apps that were
+// never deployed don't have releases.
+func (app *App) createEnvRelease(reason ReleaseReason) {
+	last, err := lastRelease(bson.M{"app": app.Name})
+	if err == ErrReleaseNotFound {
+		return
+	}
+	if err == nil {
+		_, err = app.createRelease(Release{Reason: reason, Image: last.Image, TsuruYaml: last.TsuruYaml})
+	}
+	if err != nil {
+		log.Errorf("WARNING: couldn't create release of the app %q: %s", app.Name, err)
+	}
+}
+
+// DecryptedEnvs returns the envs of the release with the values of private
+// variables in plain text.
+func (r *Release) DecryptedEnvs() (map[string]bind.EnvVar, error) {
+	a := App{Name: r.App, Env: r.Envs, EnvKey: r.EnvKey}
+	return a.DecryptedEnvs()
+}
+
+// MaskPrivateEnvs replaces the values of the private envs of the release,
+// so it can be shown to users.
+func (r *Release) MaskPrivateEnvs() {
+	for name, env := range r.Envs {
+		if !env.Public {
+			env.Value = maskedEnvValue
+			env.Encrypted = false
+			r.Envs[name] = env
+		}
+	}
+}
+
+// restoreReleaseEnvs replaces the envs of the app with the ones in the
+// release. Envs from service instances and envs managed by tsuru keep their
+// current values.
+func (app *App) restoreReleaseEnvs(r *Release) error {
+	releaseEnvs, err := r.DecryptedEnvs()
+	if err != nil {
+		return err
+	}
+	currentEnvs, err := app.DecryptedEnvs()
+	if err != nil {
+		return err
+	}
+	var envs []bind.EnvVar
+	for _, env := range currentEnvs {
+		if env.InstanceName != "" || manifestIgnoredEnvs[env.Name] {
+			envs = append(envs, env)
+		}
+	}
+	for _, env := range releaseEnvs {
+		if env.InstanceName == "" && !manifestIgnoredEnvs[env.Name] {
+			envs = append(envs, env)
+		}
+	}
+	err = app.encryptEnvs(envs)
+	if err != nil {
+		return err
+	}
+	envMap := make(map[string]bind.EnvVar, len(envs))
+	for _, env := range envs {
+		envMap[env.Name] = env
+	}
+	return app.replaceEnvs(envMap, app.EnvKey)
+}
+
+// replaceEnvs stores the given envs, already encrypted with key, as the
+// envs of the app.
+func (app *App) replaceEnvs(envs map[string]bind.EnvVar, key *EnvKey) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"env": envs, "envkey": key}})
+	if err != nil {
+		return err
+	}
+	app.Env = envs
+	app.EnvKey = key
+	return nil
+}
+
+// restoreReleaseUnits adds or removes units of the processes in the
+// release, so the app runs the same number of units it had.
+func (app *App) restoreReleaseUnits(r *Release, w io.Writer) error {
+	units, err := app.Units()
+	if err != nil {
+		return err
+	}
+	current := map[string]int{}
+	for _, u := range units {
+		current[u.ProcessName]++
+	}
+	processes := make([]string, 0, len(r.Processes))
+	for process := range r.Processes {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+	for _, process := range processes {
+		diff := r.Processes[process] - current[process]
+		switch {
+		case diff > 0:
+			err = app.AddUnits(uint(diff), process, w)
+		case diff < 0:
+			err = app.RemoveUnits(uint(-diff), process, w)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffReleases returns the changes between the releases from and to, in the
+// image, envs, number of units and tsuru.yaml data. Values of private envs
+// are masked.
+func DiffReleases(from, to *Release) ([]ReleaseChange, error) {
+	var changes []ReleaseChange
+	if from.Image != to.Image {
+		changes = append(changes, ReleaseChange{Field: ReleaseFieldImage, Action: ManifestActionUpdate, Old: from.Image, New: to.Image})
+	}
+	fromEnvs, err := from.DecryptedEnvs()
+	if err != nil {
+		return nil, err
+	}
+	toEnvs, err := to.DecryptedEnvs()
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, diffReleaseEnvs(fromEnvs, toEnvs)...)
+	changes = append(changes, diffReleaseProcesses(from.Processes, to.Processes)...)
+	yamlChanges, err := diffReleaseTsuruYaml(&from.TsuruYaml, &to.TsuruYaml)
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, yamlChanges...), nil
+}
+
+func diffReleaseEnvs(from, to map[string]bind.EnvVar) []ReleaseChange {
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var changes []ReleaseChange
+	for _, name := range names {
+		old, hasOld := from[name]
+		new, hasNew := to[name]
+		change := ReleaseChange{Field: ManifestFieldEnv, Name: name}
+		switch {
+		case !hasNew:
+			change.Action = ManifestActionRemove
+			change.Old = releaseEnvValue(old)
+		case !hasOld:
+			change.Action = ManifestActionAdd
+			change.New = releaseEnvValue(new)
+		case old.Value != new.Value || old.Public != new.Public:
+			change.Action = ManifestActionUpdate
+			change.Old = releaseEnvValue(old)
+			change.New = releaseEnvValue(new)
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func releaseEnvValue(env bind.EnvVar) string {
+	if !env.Public {
+		return maskedEnvValue
+	}
+	return env.Value
+}
+
+func diffReleaseProcesses(from, to map[string]int) []ReleaseChange {
+	processes := make([]string, 0, len(from)+len(to))
+	for process := range from {
+		processes = append(processes, process)
+	}
+	for process := range to {
+		if _, ok := from[process]; !ok {
+			processes = append(processes, process)
+		}
+	}
+	sort.Strings(processes)
+	var changes []ReleaseChange
+	for _, process := range processes {
+		old, new := from[process], to[process]
+		if old == new {
+			continue
+		}
+		changes = append(changes, ReleaseChange{
+			Field:  ManifestFieldUnits,
+			Action: ManifestActionUpdate,
+			Name:   process,
+			Old:    strconv.Itoa(old),
+			New:    strconv.Itoa(new),
+		})
+	}
+	return changes
+}
+
+func diffReleaseTsuruYaml(from, to *provision.TsuruYamlData) ([]ReleaseChange, error) {
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"hooks", from.Hooks, to.Hooks},
+		{"healthcheck", from.Healthcheck, to.Healthcheck},
+		{"deploy", from.Deploy, to.Deploy},
+	}
+	var changes []ReleaseChange
+	for _, section := range sections {
+		old, err := json.Marshal(section.old)
+		if err != nil {
+			return nil, err
+		}
+		new, err := json.Marshal(section.new)
+		if err != nil {
+			return nil, err
+		}
+		if string(old) == string(new) {
+			continue
+		}
+		changes = append(changes, ReleaseChange{
+			Field:  ReleaseFieldTsuruYaml,
+			Action: ManifestActionUpdate,
+			Name:   section.name,
+			Old:    string(old),
+			New:    string(new),
+		})
+	}
+	return changes, nil
+}
+
+// reencryptReleaseEnvs re-encrypts the envs of all the releases of the app
+// with new data keys, protected by the current master key.
+func reencryptReleaseEnvs(appName string) error {
+	releases, err := ListReleases(appName)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, r := range releases {
+		a := App{Name: r.App, Env: r.Envs, EnvKey: r.EnvKey}
+		err = a.reencryptEnvs()
+		if err != nil {
+			return fmt.Errorf("release %d: %s", r.Version, err)
+		}
+		err = conn.Releases().Update(
+			bson.M{"app": r.App, "version": r.Version},
+			bson.M{"$set": bson.M{"envs": a.Env, "envkey": a.EnvKey}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) deployRelease(c *check.C, a *App, image string) {
+	err := Deploy(DeployOptions{
+		App:          a,
+		Image:        image,
+		User:         s.user.Email,
+		OutputStream: &bytes.Buffer{},
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestDeployCreatesRelease(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 2)
+	yamlData := provision.TsuruYamlData{
+		Healthcheck: provision.TsuruYamlHealthcheck{Path: "/healthcheck"},
+	}
+	s.provisioner.SetImageTsuruYamlData("tsuru/app-myapp:v1", yamlData)
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "1", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	s.deployRelease(c, a, "tsuru/app-myapp:v1")
+	releases, err := ListReleases(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 1)
+	r := releases[0]
+	c.Assert(r.App, check.Equals, a.Name)
+	c.Assert(r.Version, check.Equals, 1)
+	c.Assert(r.Reason, check.Equals, ReleaseDeploy)
+	c.Assert(r.User, check.Equals, s.user.Email)
+	c.Assert(r.Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(r.Envs["DEBUG"], check.DeepEquals, bind.EnvVar{Name: "DEBUG", Value: "1", Public: true})
+	c.Assert(r.Processes, check.DeepEquals, map[string]int{"web": 2})
+	c.Assert(r.TsuruYaml, check.DeepEquals, yamlData)
+}
+
+func (s *S) TestEnvChangesCreateReleases(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "1", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	releases, err := ListReleases(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 0)
+	s.deployRelease(c, a, "tsuru/app-myapp:v1")
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	err = a.UnsetEnvs(bind.UnsetEnvApp{VariableNames: []string{"DEBUG"}}, nil)
+	c.Assert(err, check.IsNil)
+	releases, err = ListReleases(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 3)
+	c.Assert(releases[0].Version, check.Equals, 3)
+	c.Assert(releases[0].Reason, check.Equals, ReleaseEnvUnset)
+	c.Assert(releases[0].Image, check.Equals, "tsuru/app-myapp:v1")
+	_, ok := releases[0].Envs["DEBUG"]
+	c.Assert(ok, check.Equals, false)
+	c.Assert(releases[1].Version, check.Equals, 2)
+	c.Assert(releases[1].Reason, check.Equals, ReleaseEnvSet)
+	c.Assert(releases[1].Envs["DEBUG"].Value, check.Equals, "0")
+}
+
+func (s *S) TestRollbackToRelease(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 1)
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DATABASE", Value: "db1"}}}, nil)
+	c.Assert(err, check.IsNil)
+	s.deployRelease(c, a, "tsuru/app-myapp:v1")
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{
+		{Name: "DATABASE", Value: "db2"},
+		{Name: "NEW", Value: "new", Public: true},
+	}}, nil)
+	c.Assert(err, check.IsNil)
+	s.deployRelease(c, a, "tsuru/app-myapp:v2")
+	err = a.AddUnits(2, "web", nil)
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	err = Rollback(DeployOptions{App: a, Release: 1, User: s.user.Email, OutputStream: writer})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Matches, `(?s)---- Rolling back to the release 1 of the app "myapp" ----.*Rollback deploy called.*`)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DATABASE"].Value, check.Equals, "db1")
+	_, ok := dbApp.Env["NEW"]
+	c.Assert(ok, check.Equals, false)
+	units, err := dbApp.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	releases, err := ListReleases(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 4)
+	r := releases[0]
+	c.Assert(r.Version, check.Equals, 4)
+	c.Assert(r.Reason, check.Equals, ReleaseRollback)
+	c.Assert(r.RollbackOf, check.Equals, 1)
+	c.Assert(r.Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(r.Envs["DATABASE"].Value, check.Equals, "db1")
+	c.Assert(r.Processes, check.DeepEquals, map[string]int{"web": 1})
+}
+
+func (s *S) TestRollbackToImageRestoresRelease(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "1", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	s.deployRelease(c, a, "tsuru/app-myapp:v1")
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "0", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	s.deployRelease(c, a, "tsuru/app-myapp:v2")
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DEBUG", Value: "2", Public: true}}}, nil)
+	c.Assert(err, check.IsNil)
+	err = Rollback(DeployOptions{App: a, Image: "tsuru/app-myapp:v1", OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DEBUG"].Value, check.Equals, "0")
+	releases, err := ListReleases(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases[0].Reason, check.Equals, ReleaseRollback)
+	c.Assert(releases[0].RollbackOf, check.Equals, 2)
+}
+
+func (s *S) TestRollbackToReleaseFailureRestoresEnvs(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DATABASE", Value: "db1"}}}, nil)
+	c.Assert(err, check.IsNil)
+	s.deployRelease(c, a, "tsuru/app-myapp:v1")
+	err = a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "DATABASE", Value: "db2"}}}, nil)
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareFailure("ImageDeploy", errors.New("deploy error"))
+	err = Rollback(DeployOptions{App: a, Release: 1, OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.ErrorMatches, "deploy error")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	envs, err := dbApp.DecryptedEnvs()
+	c.Assert(err, check.IsNil)
+	c.Assert(envs["DATABASE"].Value, check.Equals, "db2")
+}
+
+func (s *S) TestRollbackToReleaseNotFound(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := Rollback(DeployOptions{App: a, Release: 3, OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.Equals, ErrReleaseNotFound)
+}
+
+func (s *S) TestRollbackToReleaseKeepsServiceEnvs(c *check.C) {
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	s.deployRelease(c, a, "tsuru/app-myapp:v1")
+	err := a.AddInstance(bind.InstanceApp{
+		ServiceName: "mysql",
+		Instance: bind.ServiceInstance{
+			Name: "mydb",
+			Envs: map[string]string{"DATABASE_HOST": "localhost"},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	err = Rollback(DeployOptions{App: a, Release: 1, OutputStream: &bytes.Buffer{}})
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DATABASE_HOST"].Value, check.Equals, "localhost")
+}
+
+func (s *S) TestDiffReleases(c *check.C) {
+	from := &Release{
+		App:   "myapp",
+		Image: "tsuru/app-myapp:v1",
+		Envs: map[string]bind.EnvVar{
+			"DEBUG":    {Name: "DEBUG", Value: "1", Public: true},
+			"PASSWORD": {Name: "PASSWORD", Value: "secret"},
+			"OLD":      {Name: "OLD", Value: "old", Public: true},
+			"SAME":     {Name: "SAME", Value: "same", Public: true},
+		},
+		Processes: map[string]int{"web": 2, "worker": 1},
+	}
+	to := &Release{
+		App:   "myapp",
+		Image: "tsuru/app-myapp:v2",
+		Envs: map[string]bind.EnvVar{
+			"DEBUG":    {Name: "DEBUG", Value: "0", Public: true},
+			"PASSWORD": {Name: "PASSWORD", Value: "other"},
+			"NEW":      {Name: "NEW", Value: "new", Public: true},
+			"SAME":     {Name: "SAME", Value: "same", Public: true},
+		},
+		Processes: map[string]int{"web": 3, "worker": 1},
+		TsuruYaml: provision.TsuruYamlData{
+			Healthcheck: provision.TsuruYamlHealthcheck{Path: "/hc"},
+		},
+	}
+	changes, err := DiffReleases(from, to)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 7)
+	c.Assert(changes[:6], check.DeepEquals, []ReleaseChange{
+		{Field: "image", Action: "update", Old: "tsuru/app-myapp:v1", New: "tsuru/app-myapp:v2"},
+		{Field: "env", Action: "update", Name: "DEBUG", Old: "1", New: "0"},
+		{Field: "env", Action: "add", Name: "NEW", New: "new"},
+		{Field: "env", Action: "remove", Name: "OLD", Old: "old"},
+		{Field: "env", Action: "update", Name: "PASSWORD", Old: "*****", New: "*****"},
+		{Field: "units", Action: "update", Name: "web", Old: "2", New: "3"},
+	})
+	c.Assert(changes[6].Field, check.Equals, "tsuru.yaml")
+	c.Assert(changes[6].Name, check.Equals, "healthcheck")
+	c.Assert(changes[6].New, check.Matches, `.*"Path":"/hc".*`)
+}
+
+func (s *S) TestReleaseEnvsAreEncrypted(c *check.C) {
+	defer setEnvMasterKeys("key1", map[string]string{"key1": testEnvKey1, "key2": testEnvKey2})()
+	a := s.createTestApp(c, App{Deploys: 1}, 0)
+	err := a.SetEnvs(bind.SetEnvApp{Envs: []bind.EnvVar{{Name: "PASSWORD", Value: "secret"}}}, nil)
+	c.Assert(err, check.IsNil)
+	s.deployRelease(c, a, "tsuru/app-myapp:v1")
+	r, err := GetRelease(a.Name, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Envs["PASSWORD"].Encrypted, check.Equals, true)
+	c.Assert(r.Envs["PASSWORD"].Value, check.Not(check.Equals), "secret")
+	c.Assert(r.EnvKey.MasterKeyID, check.Equals, "key1")
+	defer setEnvMasterKeys("key2", map[string]string{"key1": testEnvKey1, "key2": testEnvKey2})()
+	_, err = ReencryptAllEnvs(nil)
+	c.Assert(err, check.IsNil)
+	r, err = GetRelease(a.Name, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.EnvKey.MasterKeyID, check.Equals, "key2")
+	envs, err := r.DecryptedEnvs()
+	c.Assert(err, check.IsNil)
+	c.Assert(envs["PASSWORD"].Value, check.Equals, "secret")
+	r.MaskPrivateEnvs()
+	c.Assert(r.Envs["PASSWORD"], check.DeepEquals, bind.EnvVar{Name: "PASSWORD", Value: "*****"})
+}
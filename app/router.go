@@ -0,0 +1,219 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	stderr "errors"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrRouterAlreadyAttached = stderr.New("router is already attached to the app")
+	ErrRouterNotAttached     = stderr.New("router is not attached to the app")
+	ErrLastRouter            = stderr.New("cannot remove the last router of the app")
+	ErrRoutersSwapped        = stderr.New("cannot change the routers of a swapped app, swap it back first")
+)
+
+// GetRouters returns the routers the app is attached to. Apps never attached
+// to a router explicitly use only the router of their plan.
+func (app *App) GetRouters() ([]router.AppRouter, error) {
+	if len(app.Routers) > 0 {
+		routers := make([]router.AppRouter, len(app.Routers))
+		copy(routers, app.Routers)
+		return routers, nil
+	}
+	routerName, err := app.Plan.getRouter()
+	if err != nil {
+		return nil, err
+	}
+	return []router.AppRouter{{Name: routerName}}, nil
+}
+
+// allRouters returns a router changing the backend of the app in all of its
+// routers.
+func (app *App) allRouters() (router.Router, error) {
+	routers, err := app.GetRouters()
+	if err != nil {
+		return nil, err
+	}
+	return router.GetForApp(routers)
+}
+
+// RoutersWithAddr returns the routers of the app along with the address of
+// the app in each of them. Routers failing to return the address are listed
+// without it.
+func (app *App) RoutersWithAddr() []router.AppRouter {
+	routers, err := app.GetRouters()
+	if err != nil {
+		log.Errorf("unable to get routers of app %s: %s", app.Name, err)
+		return nil
+	}
+	for i := range routers {
+		r, err := router.Get(routers[i].Name)
+		if err != nil {
+			log.Errorf("unable to get router %s of app %s: %s", routers[i].Name, app.Name, err)
+			continue
+		}
+		routers[i].Address, err = r.Addr(app.Name)
+		if err != nil {
+			log.Errorf("unable to get address of app %s in router %s: %s", app.Name, routers[i].Name, err)
+		}
+	}
+	return routers
+}
+
+// AddRouter attaches the app to one more router, creating its backend there
+// with the given options and adding its cnames and routes.
+func (app *App) AddRouter(appRouter router.AppRouter) error {
+	routers, err := app.GetRouters()
+	if err != nil {
+		return err
+	}
+	for _, existing := range routers {
+		if existing.Name == appRouter.Name {
+			return ErrRouterAlreadyAttached
+		}
+	}
+	r, err := router.Get(appRouter.Name)
+	if err != nil {
+		return err
+	}
+	if _, ok := r.(router.OptsRouter); !ok && len(appRouter.Opts) > 0 {
+		return router.ErrOptsNotSupported
+	}
+	err = app.checkRoutersSwap()
+	if err != nil {
+		return err
+	}
+	var result RebuildRoutesResult
+	err = app.rebuildRouterRoutes(appRouter, false, &result)
+	if err == nil {
+		routers = append(routers, router.AppRouter{Name: appRouter.Name, Opts: appRouter.Opts})
+		err = app.updateRouters(routers, bson.M{})
+	}
+	if err != nil {
+		if rollbackErr := router.RemoveSharedBackend(r, app.Name); rollbackErr != nil {
+			log.Errorf("unable to remove backend of app %s from router %s: %s", app.Name, appRouter.Name, rollbackErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveRouter detaches the app from one of its routers, removing its backend
+// there. When the main router is removed, the next one takes its place and
+// the address of the app changes accordingly.
+func (app *App) RemoveRouter(name string) error {
+	routers, err := app.GetRouters()
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i, appRouter := range routers {
+		if appRouter.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ErrRouterNotAttached
+	}
+	if len(routers) == 1 {
+		return ErrLastRouter
+	}
+	err = app.checkRoutersSwap()
+	if err != nil {
+		return err
+	}
+	r, err := router.Get(name)
+	if err != nil {
+		return err
+	}
+	err = router.RemoveSharedBackend(r, app.Name)
+	if err != nil && err != router.ErrBackendNotFound {
+		return err
+	}
+	routers = append(routers[:index], routers[index+1:]...)
+	extra := bson.M{}
+	if index == 0 {
+		mainRouter, err := router.Get(routers[0].Name)
+		if err != nil {
+			return err
+		}
+		addr, err := mainRouter.Addr(app.Name)
+		if err != nil {
+			return err
+		}
+		extra["ip"] = addr
+		app.Ip = addr
+	}
+	return app.updateRouters(routers, extra)
+}
+
+func (app *App) updateRouters(routers []router.AppRouter, extra bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	extra["routers"] = routers
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": extra})
+	if err != nil {
+		return err
+	}
+	app.Routers = routers
+	return nil
+}
+
+// checkRoutersSwap fails when the app is swapped, as the name of its backend,
+// shared by all its routers, belongs to the other app.
+func (app *App) checkRoutersSwap() error {
+	swapped, _, err := router.IsSwapped(app.Name)
+	if err == router.ErrBackendNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if swapped {
+		return ErrRoutersSwapped
+	}
+	return nil
+}
+
+// haveSameRouters reports whether both apps are attached to the same
+// routers, a requirement for swapping them.
+func haveSameRouters(app1, app2 *App) (bool, error) {
+	routers1, err := app1.GetRouters()
+	if err != nil {
+		return false, err
+	}
+	routers2, err := app2.GetRouters()
+	if err != nil {
+		return false, err
+	}
+	if len(routers1) != len(routers2) {
+		return false, nil
+	}
+	for i := range routers1 {
+		if routers1[i].Name != routers2[i].Name {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
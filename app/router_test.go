@@ -0,0 +1,163 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestGetRoutersFromPlan(c *check.C) {
+	a := App{Name: "myapp", Plan: Plan{Router: "fake-tls"}}
+	routers, err := a.GetRouters()
+	c.Assert(err, check.IsNil)
+	c.Assert(routers, check.DeepEquals, []router.AppRouter{{Name: "fake-tls"}})
+	routerName, err := a.GetRouter()
+	c.Assert(err, check.IsNil)
+	c.Assert(routerName, check.Equals, "fake-tls")
+}
+
+func (s *S) TestGetRoutersAttached(c *check.C) {
+	a := App{Name: "myapp", Plan: Plan{Router: "fake-tls"}, Routers: []router.AppRouter{{Name: "fake"}, {Name: "fake-hc"}}}
+	routers, err := a.GetRouters()
+	c.Assert(err, check.IsNil)
+	c.Assert(routers, check.DeepEquals, []router.AppRouter{{Name: "fake"}, {Name: "fake-hc"}})
+	routerName, err := a.GetRouter()
+	c.Assert(err, check.IsNil)
+	c.Assert(routerName, check.Equals, "fake")
+}
+
+func (s *S) TestAddRouter(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}, CName: []string{"myapp.io"}}, 2)
+	units := s.provisioner.GetUnits(a)
+	defer s.provisioner.Destroy(a)
+	opts := map[string]string{"visibility": "internal"}
+	err := a.AddRouter(router.AppRouter{Name: "fake-tls", Opts: opts})
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.TLSRouter.HasBackend(a.Name), check.Equals, true)
+	c.Assert(routertest.TLSRouter.Opts(a.Name), check.DeepEquals, opts)
+	c.Assert(routertest.TLSRouter.HasCName("myapp.io"), check.Equals, true)
+	for _, unit := range units {
+		c.Assert(routertest.TLSRouter.HasRoute(a.Name, unit.Address.String()), check.Equals, true)
+	}
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Routers, check.DeepEquals, []router.AppRouter{{Name: "fake"}, {Name: "fake-tls", Opts: opts}})
+	routerName, err := stored.GetRouter()
+	c.Assert(err, check.IsNil)
+	c.Assert(routerName, check.Equals, "fake")
+}
+
+func (s *S) TestAddRouterAlreadyAttached(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}, CName: []string{"myapp.io"}}, 2)
+	defer s.provisioner.Destroy(a)
+	err := a.AddRouter(router.AppRouter{Name: "fake"})
+	c.Assert(err, check.Equals, ErrRouterAlreadyAttached)
+}
+
+func (s *S) TestAddRouterSwapped(c *check.C) {
+	a1 := s.createTestApp(c, App{Name: "myapp1", Plan: Plan{Router: "fake"}, CName: []string{"myapp1.io"}}, 2)
+	defer s.provisioner.Destroy(a1)
+	a2 := s.createTestApp(c, App{Name: "myapp2", Plan: Plan{Router: "fake"}, CName: []string{"myapp2.io"}}, 2)
+	defer s.provisioner.Destroy(a2)
+	err := routertest.FakeRouter.Swap(a1.Name, a2.Name)
+	c.Assert(err, check.IsNil)
+	err = a1.AddRouter(router.AppRouter{Name: "fake-tls"})
+	c.Assert(err, check.Equals, ErrRoutersSwapped)
+	c.Assert(routertest.TLSRouter.HasBackend(a1.Name), check.Equals, false)
+}
+
+func (s *S) TestRemoveRouter(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}, CName: []string{"myapp.io"}}, 2)
+	units := s.provisioner.GetUnits(a)
+	defer s.provisioner.Destroy(a)
+	err := a.AddRouter(router.AppRouter{Name: "fake-tls"})
+	c.Assert(err, check.IsNil)
+	err = a.RemoveRouter("fake")
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, false)
+	routes, err := routertest.TLSRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, len(units))
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Routers, check.DeepEquals, []router.AppRouter{{Name: "fake-tls"}})
+	c.Assert(stored.Ip, check.Equals, "myapp.fakerouter.com")
+}
+
+func (s *S) TestRemoveRouterLast(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}, CName: []string{"myapp.io"}}, 2)
+	defer s.provisioner.Destroy(a)
+	err := a.RemoveRouter("fake")
+	c.Assert(err, check.Equals, ErrLastRouter)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+}
+
+func (s *S) TestRemoveRouterNotAttached(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}, CName: []string{"myapp.io"}}, 2)
+	defer s.provisioner.Destroy(a)
+	err := a.RemoveRouter("fake-tls")
+	c.Assert(err, check.Equals, ErrRouterNotAttached)
+}
+
+func (s *S) TestRebuildRoutesMultipleRouters(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}, CName: []string{"myapp.io"}}, 2)
+	units := s.provisioner.GetUnits(a)
+	defer s.provisioner.Destroy(a)
+	err := a.AddRouter(router.AppRouter{Name: "fake-tls"})
+	c.Assert(err, check.IsNil)
+	invalid, _ := url.Parse("http://invalid:1234")
+	routertest.FakeRouter.RemoveRoute(a.Name, units[0].Address)
+	routertest.TLSRouter.RemoveRoute(a.Name, units[0].Address)
+	routertest.TLSRouter.RemoveRoute(a.Name, units[1].Address)
+	routertest.TLSRouter.AddRoute(a.Name, invalid)
+	routertest.TLSRouter.UnsetCName("myapp.io", a.Name)
+	result, err := a.RebuildRoutes()
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Added, check.DeepEquals, []string{units[0].Address.String(), units[1].Address.String()})
+	c.Assert(result.Removed, check.DeepEquals, []string{invalid.String()})
+	for _, unit := range units {
+		c.Assert(routertest.FakeRouter.HasRoute(a.Name, unit.Address.String()), check.Equals, true)
+		c.Assert(routertest.TLSRouter.HasRoute(a.Name, unit.Address.String()), check.Equals, true)
+	}
+	c.Assert(routertest.TLSRouter.HasRoute(a.Name, invalid.String()), check.Equals, false)
+	c.Assert(routertest.TLSRouter.HasCName("myapp.io"), check.Equals, true)
+}
+
+func (s *S) TestSwapDifferentRouters(c *check.C) {
+	a1 := s.createTestApp(c, App{Name: "myapp1", Plan: Plan{Router: "fake"}, CName: []string{"myapp1.io"}}, 2)
+	defer s.provisioner.Destroy(a1)
+	a2 := s.createTestApp(c, App{Name: "myapp2", Plan: Plan{Router: "fake"}, CName: []string{"myapp2.io"}}, 2)
+	defer s.provisioner.Destroy(a2)
+	err := a1.AddRouter(router.AppRouter{Name: "fake-tls"})
+	c.Assert(err, check.IsNil)
+	err = Swap(a1, a2, false)
+	c.Assert(err, check.Equals, ErrSwapDifferentRouters)
+	name, err := router.Retrieve(a1.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Equals, a1.Name)
+}
+
+func (s *S) TestAppMarshalJSONWithRouters(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}, CName: []string{"myapp.io"}}, 2)
+	defer s.provisioner.Destroy(a)
+	err := a.AddRouter(router.AppRouter{Name: "fake-tls", Opts: map[string]string{"visibility": "internal"}})
+	c.Assert(err, check.IsNil)
+	data, err := json.Marshal(a)
+	c.Assert(err, check.IsNil)
+	var result struct {
+		Routers []router.AppRouter
+	}
+	err = json.Unmarshal(data, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Routers, check.DeepEquals, []router.AppRouter{
+		{Name: "fake", Address: "myapp.fakerouter.com"},
+		{Name: "fake-tls", Opts: map[string]string{"visibility": "internal"}, Address: "myapp.fakerouter.com"},
+	})
+}
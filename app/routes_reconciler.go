@@ -0,0 +1,348 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/periodic"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultRoutesReconcilerInterval = 5 * time.Minute
+	defaultRoutesReconcilerMaxFixes = 10
+)
+
+var (
+	routesReconcilerLimiter     = &provision.MongodbLimiter{}
+	routesReconcilerLimiterOnce sync.Once
+)
+
+// RoutesStatus is the result of the last comparison between the routes of an
+// app in each of its routers and the addresses of its routable units. Error
+// holds failures not related to a single router, like getting the units.
+// Fixed reports whether the reconciler rebuilt the routes of the app after
+// finding differences.
+type RoutesStatus struct {
+	App       string `bson:"_id"`
+	Routers   []RouterRoutesStatus
+	Error     string
+	Fixed     bool
+	CheckedAt time.Time
+}
+
+// RouterRoutesStatus is the result of the comparison in one of the routers
+// of the app. Missing are the addresses of units absent from the router and
+// Extra are the routes not matching any unit.
+type RouterRoutesStatus struct {
+	Router  string
+	Missing []string
+	Extra   []string
+	Error   string
+}
+
+// Drifted reports whether the routes of the app didn't match its units in any
+// of its routers.
+func (s *RoutesStatus) Drifted() bool {
+	for _, r := range s.Routers {
+		if r.Drifted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Drifted reports whether the routes of the app didn't match its units in
+// the router.
+func (s *RouterRoutesStatus) Drifted() bool {
+	return len(s.Missing) > 0 || len(s.Extra) > 0
+}
+
+// routesDiff compares the routes of an app with its routable units, returning
+// the addresses of units missing from the routes and the routes not matching
+// any unit.
+func routesDiff(routes []*url.URL, units []provision.Unit) (missing, extra []*url.URL) {
+	current := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		current[route.String()] = true
+	}
+	expected := make(map[string]bool, len(units))
+	for _, unit := range units {
+		addr := unit.Address.String()
+		if !current[addr] && !expected[addr] {
+			missing = append(missing, unit.Address)
+		}
+		expected[addr] = true
+	}
+	for _, route := range routes {
+		if !expected[route.String()] {
+			extra = append(extra, route)
+		}
+	}
+	return missing, extra
+}
+
+// checkRoutes compares the routes of the app in each of its routers with its
+// routable units, without changing them.
+func (app *App) checkRoutes() *RoutesStatus {
+	status := &RoutesStatus{App: app.Name, CheckedAt: time.Now().UTC()}
+	routers, err := app.GetRouters()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	routes := make([][]*url.URL, len(routers))
+	status.Routers = make([]RouterRoutesStatus, len(routers))
+	for i, appRouter := range routers {
+		status.Routers[i].Router = appRouter.Name
+		routes[i], err = app.routerRoutes(appRouter.Name)
+		if err != nil {
+			status.Routers[i].Error = err.Error()
+		}
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	units, err := prov.RoutableUnits(app)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	for i := range status.Routers {
+		if status.Routers[i].Error != "" {
+			continue
+		}
+		missing, extra := routesDiff(routes[i], units)
+		for _, addr := range missing {
+			status.Routers[i].Missing = append(status.Routers[i].Missing, addr.String())
+		}
+		for _, addr := range extra {
+			status.Routers[i].Extra = append(status.Routers[i].Extra, addr.String())
+		}
+	}
+	return status
+}
+
+func (app *App) routerRoutes(routerName string) ([]*url.URL, error) {
+	r, err := router.Get(routerName)
+	if err != nil {
+		return nil, err
+	}
+	return r.Routes(app.Name)
+}
+
+// RoutesStatus returns the result of the last check of the routes of the
+// app, or nil if they weren't checked yet.
+func (app *App) RoutesStatus() (*RoutesStatus, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var status RoutesStatus
+	err = conn.RoutesStatus().FindId(app.Name).One(&status)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListRoutesDrifts returns the status of the routes of the apps matching the
+// filter whose routes didn't match their units in the last check.
+func ListRoutesDrifts(filter *Filter) ([]RoutesStatus, error) {
+	apps, err := List(filter)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(apps))
+	for i, a := range apps {
+		names[i] = a.Name
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{
+		"_id": bson.M{"$in": names},
+		"$or": []bson.M{
+			{"routers.missing.0": bson.M{"$exists": true}},
+			{"routers.extra.0": bson.M{"$exists": true}},
+		},
+	}
+	var statuses []RoutesStatus
+	err = conn.RoutesStatus().Find(query).Sort("_id").All(&statuses)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+func removeRoutesStatus(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.RoutesStatus().RemoveId(appName)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// RoutesReconciler periodically compares the routes of every app in each of
+// its routers with the addresses of its routable units, storing the differences
+// found. When configured to fix them, it rebuilds the routes of the drifted
+// apps, limited to a number of apps per router in each run, so a router
+// recovering from an outage isn't flooded with changes. Runs are serialized
+// through a lock shared by all API instances, and apps checked recently by
+// another instance are skipped.
+type RoutesReconciler struct {
+	*periodic.Loop
+	Fix      bool
+	MaxFixes int
+}
+
+// NewRoutesReconciler returns a reconciler configured by the
+// routes-reconciler:* settings, checking the routes every 5 minutes by
+// default, without fixing them.
+func NewRoutesReconciler() *RoutesReconciler {
+	interval := defaultRoutesReconcilerInterval
+	if seconds, err := config.GetInt("routes-reconciler:interval"); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+	fix, _ := config.GetBool("routes-reconciler:fix")
+	maxFixes, err := config.GetInt("routes-reconciler:max-fixes")
+	if err != nil || maxFixes <= 0 {
+		maxFixes = defaultRoutesReconcilerMaxFixes
+	}
+	r := &RoutesReconciler{Fix: fix, MaxFixes: maxFixes}
+	r.Loop = &periodic.Loop{
+		Name:     "routes reconciler",
+		Interval: interval,
+		Task:     r.runOnce,
+	}
+	return r
+}
+
+func (r *RoutesReconciler) runOnce() error {
+	routesReconcilerLimiterOnce.Do(func() {
+		routesReconcilerLimiter.Initialize(1)
+	})
+	done, ok := routesReconcilerLimiter.TryStart("routes-reconciler")
+	if !ok {
+		return nil
+	}
+	defer done()
+	apps, err := List(nil)
+	if err != nil {
+		return fmt.Errorf("error getting apps: %s", err)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	recent := time.Now().UTC().Add(-r.Interval / 2)
+	var checked []RoutesStatus
+	err = conn.RoutesStatus().Find(bson.M{"checkedat": bson.M{"$gt": recent}}).Select(bson.M{"_id": 1}).All(&checked)
+	if err != nil {
+		return fmt.Errorf("error getting routes status: %s", err)
+	}
+	skip := make(map[string]bool, len(checked))
+	for _, status := range checked {
+		skip[status.App] = true
+	}
+	fixes := make(map[string]int)
+	for i := range apps {
+		if skip[apps[i].Name] || apps[i].Lock.Locked {
+			continue
+		}
+		err = r.reconcile(&apps[i], fixes)
+		if err != nil {
+			log.Errorf("[routes-reconciler] error reconciling routes of app %s: %s", apps[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// reconcile checks the routes of the app, rebuilding them when they drifted
+// and none of the drifted routers reached the limit of fixes in this run.
+func (r *RoutesReconciler) reconcile(a *App, fixes map[string]int) error {
+	status := a.checkRoutes()
+	if status.Error == "" && status.Drifted() {
+		canFix := r.Fix
+		var drifted []string
+		for _, routerStatus := range status.Routers {
+			if !routerStatus.Drifted() {
+				continue
+			}
+			log.Errorf("[routes-reconciler] routes of app %s in router %s drifted from its units, missing: %v, extra: %v", a.Name, routerStatus.Router, routerStatus.Missing, routerStatus.Extra)
+			drifted = append(drifted, routerStatus.Router)
+			if fixes[routerStatus.Router] >= r.MaxFixes {
+				canFix = false
+			}
+		}
+		if canFix {
+			err := a.fixRoutes(status)
+			if _, ok := err.(event.ErrEventLocked); ok {
+				log.Debugf("[routes-reconciler] skipped fixing routes of app %s: %s", a.Name, err)
+			} else {
+				for _, routerName := range drifted {
+					fixes[routerName]++
+				}
+				if err != nil {
+					status.Error = err.Error()
+				} else {
+					status.Fixed = true
+				}
+			}
+		}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.RoutesStatus().UpsertId(a.Name, status)
+	return err
+}
+
+// fixRoutes rebuilds the routes of the app inside an event, so it doesn't
+// run concurrently with other operations on the app, like deploys.
+func (app *App) fixRoutes(status *RoutesStatus) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: app.Name},
+		InternalKind: "routes-reconcile",
+		CustomData:   status,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	result, err := app.RebuildRoutes()
+	if err != nil {
+		return err
+	}
+	log.Debugf("[routes-reconciler] rebuilt routes of app %s, added: %v, removed: %v", app.Name, result.Added, result.Removed)
+	return nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestRoutesDiff(c *check.C) {
+	addr1, _ := url.Parse("http://10.0.0.1:8080")
+	addr2, _ := url.Parse("http://10.0.0.2:8080")
+	addr3, _ := url.Parse("http://10.0.0.3:8080")
+	units := []provision.Unit{{Address: addr1}, {Address: addr2}, {Address: addr2}}
+	missing, extra := routesDiff([]*url.URL{addr1, addr3}, units)
+	c.Assert(missing, check.DeepEquals, []*url.URL{addr2})
+	c.Assert(extra, check.DeepEquals, []*url.URL{addr3})
+	missing, extra = routesDiff([]*url.URL{addr2, addr1}, units)
+	c.Assert(missing, check.IsNil)
+	c.Assert(extra, check.IsNil)
+}
+
+func (s *S) TestRoutesReconcilerReportsDrift(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}}, 3)
+	units := s.provisioner.GetUnits(a)
+	routertest.FakeRouter.RemoveRoute(a.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	defer s.provisioner.Destroy(a)
+	err := NewRoutesReconciler().runOnce()
+	c.Assert(err, check.IsNil)
+	status, err := a.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.NotNil)
+	c.Assert(status.Routers, check.DeepEquals, []RouterRoutesStatus{{
+		Router:  "fake",
+		Missing: []string{units[2].Address.String()},
+		Extra:   []string{"http://invalid:1234"},
+	}})
+	c.Assert(status.Fixed, check.Equals, false)
+	c.Assert(status.Error, check.Equals, "")
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, "http://invalid:1234"), check.Equals, true)
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, units[2].Address.String()), check.Equals, false)
+	drifts, err := ListRoutesDrifts(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(drifts, check.HasLen, 1)
+	c.Assert(drifts[0].App, check.Equals, a.Name)
+}
+
+func (s *S) TestRoutesReconcilerInSync(c *check.C) {
+	a := &App{Name: "myapp", Plan: Plan{Router: "fake"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.Provision(a)
+	c.Assert(err, check.IsNil)
+	defer s.provisioner.Destroy(a)
+	s.provisioner.AddUnits(a, 2, "web", nil)
+	err = NewRoutesReconciler().runOnce()
+	c.Assert(err, check.IsNil)
+	status, err := a.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.NotNil)
+	c.Assert(status.Drifted(), check.Equals, false)
+	drifts, err := ListRoutesDrifts(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(drifts, check.HasLen, 0)
+}
+
+func (s *S) TestRoutesReconcilerFixesDrift(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}}, 3)
+	units := s.provisioner.GetUnits(a)
+	routertest.FakeRouter.RemoveRoute(a.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	defer s.provisioner.Destroy(a)
+	reconciler := NewRoutesReconciler()
+	reconciler.Fix = true
+	err := reconciler.runOnce()
+	c.Assert(err, check.IsNil)
+	status, err := a.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Routers[0].Missing, check.DeepEquals, []string{units[2].Address.String()})
+	c.Assert(status.Fixed, check.Equals, true)
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, "http://invalid:1234"), check.Equals, false)
+	for _, unit := range units {
+		c.Assert(routertest.FakeRouter.HasRoute(a.Name, unit.Address.String()), check.Equals, true)
+	}
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   "routes-reconcile",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestRoutesReconcilerLimitsFixesPerRouter(c *check.C) {
+	a1 := s.createTestApp(c, App{Name: "myapp1", Plan: Plan{Router: "fake"}}, 3)
+	units := s.provisioner.GetUnits(a1)
+	routertest.FakeRouter.RemoveRoute(a1.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a1.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	defer s.provisioner.Destroy(a1)
+	a2 := s.createTestApp(c, App{Name: "myapp2", Plan: Plan{Router: "fake"}}, 3)
+	units = s.provisioner.GetUnits(a2)
+	routertest.FakeRouter.RemoveRoute(a2.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a2.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	defer s.provisioner.Destroy(a2)
+	reconciler := NewRoutesReconciler()
+	reconciler.Fix = true
+	reconciler.MaxFixes = 1
+	err := reconciler.runOnce()
+	c.Assert(err, check.IsNil)
+	status1, err := a1.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status1.Fixed, check.Equals, true)
+	status2, err := a2.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status2.Drifted(), check.Equals, true)
+	c.Assert(status2.Fixed, check.Equals, false)
+	c.Assert(routertest.FakeRouter.HasRoute(a2.Name, "http://invalid:1234"), check.Equals, true)
+}
+
+func (s *S) TestRoutesReconcilerSkipsLockedApps(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}}, 3)
+	units := s.provisioner.GetUnits(a)
+	routertest.FakeRouter.RemoveRoute(a.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	defer s.provisioner.Destroy(a)
+	locked, err := AcquireApplicationLock(a.Name, "me", "deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer ReleaseApplicationLock(a.Name)
+	err = NewRoutesReconciler().runOnce()
+	c.Assert(err, check.IsNil)
+	status, err := a.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.IsNil)
+}
+
+func (s *S) TestRoutesReconcilerSkipsRecentlyChecked(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}}, 3)
+	units := s.provisioner.GetUnits(a)
+	routertest.FakeRouter.RemoveRoute(a.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	defer s.provisioner.Destroy(a)
+	checkedAt := time.Now().UTC().Add(-time.Second)
+	_, err := s.conn.RoutesStatus().UpsertId(a.Name, RoutesStatus{App: a.Name, Routers: []RouterRoutesStatus{{Router: "fake"}}, CheckedAt: checkedAt})
+	c.Assert(err, check.IsNil)
+	err = NewRoutesReconciler().runOnce()
+	c.Assert(err, check.IsNil)
+	status, err := a.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Drifted(), check.Equals, false)
+	c.Assert(status.CheckedAt.Unix(), check.Equals, checkedAt.Unix())
+}
+
+func (s *S) TestRoutesReconcilerRecordsErrors(c *check.C) {
+	a := &App{Name: "myapp", Plan: Plan{Router: "fake"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = NewRoutesReconciler().runOnce()
+	c.Assert(err, check.IsNil)
+	status, err := a.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Error, check.Equals, "")
+	c.Assert(status.Routers, check.HasLen, 1)
+	c.Assert(status.Routers[0].Error, check.Not(check.Equals), "")
+	c.Assert(status.Drifted(), check.Equals, false)
+}
+
+func (s *S) TestRoutesReconcilerChecksEveryRouter(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}}, 3)
+	units := s.provisioner.GetUnits(a)
+	routertest.FakeRouter.RemoveRoute(a.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	defer s.provisioner.Destroy(a)
+	err := a.AddRouter(router.AppRouter{Name: "fake-tls"})
+	c.Assert(err, check.IsNil)
+	err = routertest.TLSRouter.RemoveRoute(a.Name, units[0].Address)
+	c.Assert(err, check.IsNil)
+	reconciler := NewRoutesReconciler()
+	reconciler.Fix = true
+	err = reconciler.runOnce()
+	c.Assert(err, check.IsNil)
+	status, err := a.RoutesStatus()
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Routers, check.HasLen, 2)
+	c.Assert(status.Routers[0].Router, check.Equals, "fake")
+	c.Assert(status.Routers[0].Drifted(), check.Equals, true)
+	c.Assert(status.Routers[1].Router, check.Equals, "fake-tls")
+	c.Assert(status.Routers[1].Missing, check.DeepEquals, []string{units[0].Address.String()})
+	c.Assert(status.Routers[1].Extra, check.IsNil)
+	c.Assert(status.Fixed, check.Equals, true)
+	for _, unit := range units {
+		c.Assert(routertest.TLSRouter.HasRoute(a.Name, unit.Address.String()), check.Equals, true)
+	}
+}
+
+func (s *S) TestDeleteRemovesRoutesStatus(c *check.C) {
+	a := s.createTestApp(c, App{Name: "myapp", Plan: Plan{Router: "fake"}}, 3)
+	units := s.provisioner.GetUnits(a)
+	routertest.FakeRouter.RemoveRoute(a.Name, units[2].Address)
+	routertest.FakeRouter.AddRoute(a.Name, &url.URL{Scheme: "http", Host: "invalid:1234"})
+	err := NewRoutesReconciler().runOnce()
+	c.Assert(err, check.IsNil)
+	err = Delete(a, nil)
+	c.Assert(err, check.IsNil)
+	n, err := s.conn.RoutesStatus().Find(bson.M{"_id": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
@@ -105,6 +105,39 @@ func (s *S) createUserAndTeam(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+// createTestApp inserts and provisions the given app, adding units to its web
+// process. The name, platform, pool, teams and quota of the app default to the
+// ones of the suite.
+func (s *S) createTestApp(c *check.C, a App, units uint) *App {
+	if a.Name == "" {
+		a.Name = "myapp"
+	}
+	if a.Platform == "" {
+		a.Platform = "python"
+	}
+	if a.Pool == "" {
+		a.Pool = s.Pool
+	}
+	if a.TeamOwner == "" {
+		a.TeamOwner = s.team.Name
+	}
+	if a.Teams == nil {
+		a.Teams = []string{s.team.Name}
+	}
+	if a.Quota == (quota.Quota{}) {
+		a.Quota = quota.Unlimited
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.Provision(&a)
+	c.Assert(err, check.IsNil)
+	if units > 0 {
+		_, err = s.provisioner.AddUnits(&a, units, "web", nil)
+		c.Assert(err, check.IsNil)
+	}
+	return &a
+}
+
 var nativeScheme = auth.Scheme(native.NativeScheme{})
 
 func (s *S) SetUpSuite(c *check.C) {
@@ -133,6 +166,7 @@ func (s *S) TearDownSuite(c *check.C) {
 
 func (s *S) SetUpTest(c *check.C) {
 	routertest.FakeRouter.Reset()
+	routertest.TLSRouter.Reset()
 	routertest.HCRouter.Reset()
 	s.provisioner.Reset()
 	repositorytest.Reset()
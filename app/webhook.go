@@ -0,0 +1,31 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/webhook"
+)
+
+// notifyWebhooks triggers the webhooks matching the given event in the app.
+// Failures are only logged, as a webhook must never break the operation that
+// triggered it.
+func (app *App) notifyWebhooks(kind, user string, opErr error, data map[string]interface{}) {
+	evt := webhook.Event{
+		Kind:      kind,
+		App:       app.Name,
+		TeamOwner: app.TeamOwner,
+		Teams:     app.Teams,
+		User:      user,
+		Data:      data,
+	}
+	if opErr != nil {
+		evt.Error = opErr.Error()
+	}
+	err := webhook.Notify(evt)
+	if err != nil {
+		log.Errorf("[webhook] unable to notify %s in app %s: %s", kind, app.Name, err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/tsuru/tsuru/queue"
+	"github.com/tsuru/tsuru/webhook"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestRestartNotifiesWebhooks(c *check.C) {
+	defer queue.ResetQueue()
+	err := webhook.Create(&webhook.Webhook{Name: "ci", TeamOwner: s.team.Name, URL: "http://ci.example.com"})
+	c.Assert(err, check.IsNil)
+	err = webhook.Create(&webhook.Webhook{
+		Name:        "deploys-only",
+		TeamOwner:   s.team.Name,
+		URL:         "http://ci.example.com",
+		EventFilter: webhook.EventFilter{Events: []string{webhook.EventDeploy}},
+	})
+	c.Assert(err, check.IsNil)
+	a := App{
+		Name:      "someapp",
+		Platform:  "django",
+		TeamOwner: s.team.Name,
+		Teams:     []string{s.team.Name},
+		Plan:      Plan{Router: "fake"},
+	}
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	var b bytes.Buffer
+	err = a.Restart("web", &b)
+	c.Assert(err, check.IsNil)
+	deliveries, err := webhook.ListDeliveries("ci", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 1)
+	c.Assert(deliveries[0].Event, check.Equals, webhook.EventRestart)
+	c.Assert(deliveries[0].App, check.Equals, "someapp")
+	var payload webhook.Event
+	err = json.Unmarshal([]byte(deliveries[0].Payload), &payload)
+	c.Assert(err, check.IsNil)
+	c.Assert(payload.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(payload.Data, check.DeepEquals, map[string]interface{}{"process": "web"})
+	deliveries, err = webhook.ListDeliveries("deploys-only", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 0)
+}
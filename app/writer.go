@@ -19,6 +19,7 @@ type Logger interface {
 type LogWriter struct {
 	App    Logger
 	Source string
+	Unit   string
 	msgCh  chan []byte
 	doneCh chan bool
 	closed bool
@@ -81,5 +82,9 @@ func (w *LogWriter) write(data []byte) error {
 	if source == "" {
 		source = "tsuru"
 	}
-	return w.App.Log(string(data), source, "api")
+	unit := w.Unit
+	if unit == "" {
+		unit = "api"
+	}
+	return w.App.Log(string(data), source, unit)
 }
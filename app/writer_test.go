@@ -71,6 +71,22 @@ func (s *WriterSuite) TestLogWriterCustomSource(c *check.C) {
 	c.Assert(logs[0].Source, check.Equals, "cool-test")
 }
 
+func (s *WriterSuite) TestLogWriterCustomUnit(c *check.C) {
+	a := App{Name: "down"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	writer := LogWriter{App: &a, Source: "app-job", Unit: "backup"}
+	data := []byte("ble")
+	_, err = writer.Write(data)
+	c.Assert(err, check.IsNil)
+	logs, err := a.LastLogs(1, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs[0].Message, check.Equals, string(data))
+	c.Assert(logs[0].Source, check.Equals, "app-job")
+	c.Assert(logs[0].Unit, check.Equals, "backup")
+}
+
 func (s *WriterSuite) TestLogWriterShouldReturnTheDataSize(c *check.C) {
 	a := App{Name: "down"}
 	err := s.conn.Apps().Insert(a)
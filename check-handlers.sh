@@ -86,6 +86,18 @@ function extra_perms {
     test -z "$fail"
 }
 
+function outdated_openapi {
+    tmpfile=$(mktemp)
+    (cd api && go run ./openapi/generator/main.go -o $tmpfile)
+    if ! diff -q -I "^// Copyright" api/openapi_spec.go $tmpfile >/dev/null; then
+        rm -f $tmpfile
+        echo "Outdated api/openapi_spec.go, please run 'go generate ./api'"
+        exit 1
+    fi
+    rm -f $tmpfile
+}
+
 check_go
 missing_handlers
 extra_perms
+outdated_openapi
@@ -32,12 +32,24 @@ func checkGandalf() error {
 
 // Check provisioner configs
 func checkProvisioner() error {
-	if value, _ := config.Get("provisioner"); value == "docker" || value == "" {
+	value, _ := config.Get("provisioner")
+	switch value {
+	case "docker", "", nil:
 		return checkDocker()
+	case "kubernetes":
+		return checkKubernetes()
 	}
 	return nil
 }
 
+// Check configs required by the kubernetes provisioner.
+func checkKubernetes() error {
+	if _, err := config.GetString("kubernetes:api:url"); err != nil {
+		return errors.New(`Config Error: you should configure the kubernetes API address in "kubernetes:api:url".`)
+	}
+	return checkRouter()
+}
+
 func checkBeanstalkd() error {
 	if value, _ := config.Get("queue"); value == "beanstalkd" {
 		return errors.New("beanstalkd is no longer supported, please use redis instead")
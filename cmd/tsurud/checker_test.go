@@ -67,6 +67,20 @@ func (s *CheckerSuite) TestCheckDockerJustCheckIfProvisionerIsDocker(c *check.C)
 	c.Assert(err, check.IsNil)
 }
 
+func (s *CheckerSuite) TestCheckKubernetes(c *check.C) {
+	config.Set("provisioner", "kubernetes")
+	config.Set("kubernetes:api:url", "https://10.0.0.1")
+	err := checkProvisioner()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *CheckerSuite) TestCheckKubernetesAPIURLNotConfigured(c *check.C) {
+	config.Set("provisioner", "kubernetes")
+	config.Unset("kubernetes")
+	err := checkProvisioner()
+	c.Assert(err, check.ErrorMatches, `Config Error: you should configure the kubernetes API address in "kubernetes:api:url".`)
+}
+
 func (s *CheckerSuite) TestCheckDockerIsNotConfigured(c *check.C) {
 	config.Unset("docker")
 	err := checkDocker()
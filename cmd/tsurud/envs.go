@@ -0,0 +1,64 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/tsuru/gnuflag"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/cmd"
+)
+
+type envsKeyRotateCmd struct {
+	fs       *gnuflag.FlagSet
+	generate bool
+}
+
+func (*envsKeyRotateCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "envs-key-rotate",
+		Usage: "envs-key-rotate [-g/--generate]",
+		Desc: `Re-encrypts the private environment variables of all apps with new data
+keys, protected by the master key in envs:encryption:current-key.
+
+To rotate the master key, generate a new key with the --generate flag, add it
+to envs:encryption:keys in the config file of all tsuru API instances and
+point envs:encryption:current-key to it. Then run this command, after which
+the previous master key may be removed from envs:encryption:keys.`,
+	}
+}
+
+func (c *envsKeyRotateCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	if c.generate {
+		key, err := app.GenerateEnvMasterKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(context.Stdout, key)
+		return nil
+	}
+	count, err := app.ReencryptAllEnvs(context.Stdout)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Envs of %d apps successfully re-encrypted.\n", count)
+	return nil
+}
+
+func (c *envsKeyRotateCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("envs-key-rotate", gnuflag.ExitOnError)
+		generateMsg := "Only print a new random master key, to be added to the config file"
+		c.fs.BoolVar(&c.generate, "generate", false, generateMsg)
+		c.fs.BoolVar(&c.generate, "g", false, generateMsg)
+	}
+	return c.fs
+}
+
+func encryptPrivateEnvs() error {
+	_, err := app.ReencryptAllEnvs(nil)
+	return err
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/cmd"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEnvsKeyRotateCmdGenerate(c *check.C) {
+	var stdout bytes.Buffer
+	context := cmd.Context{Stdout: &stdout}
+	command := envsKeyRotateCmd{}
+	err := command.Flags().Parse(true, []string{"--generate"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stdout.String()))
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.HasLen, 32)
+}
+
+func (s *S) TestEnvsKeyRotateCmdRun(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(app.App{
+		Name: "myapp",
+		Env: map[string]bind.EnvVar{
+			"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "secret"},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	config.Set("envs:encryption:current-key", "key1")
+	config.Set("envs:encryption:keys", map[interface{}]interface{}{
+		"key1": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("1"), 32)),
+	})
+	defer config.Unset("envs:encryption")
+	var stdout bytes.Buffer
+	context := cmd.Context{Stdout: &stdout}
+	command := envsKeyRotateCmd{}
+	err = command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "envs of the app \"myapp\" re-encrypted\nEnvs of 1 apps successfully re-encrypted.\n")
+	a, err := app.GetByName("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Env["DATABASE_PASSWORD"].Encrypted, check.Equals, true)
+	envs, err := a.DecryptedEnvs()
+	c.Assert(err, check.IsNil)
+	c.Assert(envs["DATABASE_PASSWORD"].Value, check.Equals, "secret")
+}
+
+func (s *S) TestEnvsKeyRotateCmdRunEncryptionDisabled(c *check.C) {
+	context := cmd.Context{Stdout: &bytes.Buffer{}}
+	command := envsKeyRotateCmd{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.Equals, app.ErrEnvEncryptionDisabled)
+}
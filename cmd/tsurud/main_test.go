@@ -59,6 +59,15 @@ func (s *S) TestMigrateCmdIsRegistered(c *check.C) {
 	c.Assert(migrate.Command, check.FitsTypeOf, &migrateCmd{})
 }
 
+func (s *S) TestEnvsKeyRotateCmdIsRegistered(c *check.C) {
+	manager := buildManager()
+	cmd, ok := manager.Commands["envs-key-rotate"]
+	c.Assert(ok, check.Equals, true)
+	rotate, ok := cmd.(*tsurudCommand)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(rotate.Command, check.FitsTypeOf, &envsKeyRotateCmd{})
+}
+
 func (s *S) TestGandalfSyncCmdIsRegistered(c *check.C) {
 	manager := buildManager()
 	cmd, ok := manager.Commands["gandalf-sync"]
@@ -48,6 +48,10 @@ func init() {
 	if err != nil {
 		log.Fatalf("unable to register migration: %s", err)
 	}
+	err = migration.RegisterOptional("encrypt-private-envs", encryptPrivateEnvs)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
 }
 
 func getProvisioner() (string, error) {
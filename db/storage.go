@@ -103,6 +103,24 @@ func (s *Storage) Deploys() *storage.Collection {
 	return c
 }
 
+// Releases returns the collection holding the snapshots of the image and
+// configuration of apps, created on each deploy and env change.
+func (s *Storage) Releases() *storage.Collection {
+	versionIndex := mgo.Index{Key: []string{"app", "-version"}, Unique: true}
+	c := s.Collection("releases")
+	c.EnsureIndex(versionIndex)
+	return c
+}
+
+// DeployQueue returns the collection holding the deploys waiting for the
+// lock of their apps to be released.
+func (s *Storage) DeployQueue() *storage.Collection {
+	queueIndex := mgo.Index{Key: []string{"app", "timestamp"}}
+	c := s.Collection("deploy_queue")
+	c.EnsureIndex(queueIndex)
+	return c
+}
+
 // Platforms returns the platforms collection from MongoDB.
 func (s *Storage) Platforms() *storage.Collection {
 	return s.Collection("platforms")
@@ -216,3 +234,83 @@ func (s *Storage) Roles() *storage.Collection {
 func (s *Storage) Limiter() *storage.Collection {
 	return s.Collection("limiter")
 }
+
+// Events returns the events collection from MongoDB.
+func (s *Storage) Events() *storage.Collection {
+	ownerIndex := mgo.Index{Key: []string{"owner.name"}}
+	targetIndex := mgo.Index{Key: []string{"target.value"}}
+	kindIndex := mgo.Index{Key: []string{"kind.name"}}
+	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
+	uniqueIDIndex := mgo.Index{Key: []string{"uniqueid"}}
+	c := s.Collection("events")
+	c.EnsureIndex(ownerIndex)
+	c.EnsureIndex(targetIndex)
+	c.EnsureIndex(kindIndex)
+	c.EnsureIndex(startTimeIndex)
+	c.EnsureIndex(uniqueIDIndex)
+	return c
+}
+
+// Webhooks returns the webhooks collection from MongoDB.
+func (s *Storage) Webhooks() *storage.Collection {
+	teamIndex := mgo.Index{Key: []string{"teamowner"}}
+	c := s.Collection("webhooks")
+	c.EnsureIndex(teamIndex)
+	return c
+}
+
+// WebhookDeliveries returns the collection holding the history of webhook
+// delivery attempts.
+func (s *Storage) WebhookDeliveries() *storage.Collection {
+	webhookIndex := mgo.Index{Key: []string{"webhook", "-timestamp"}}
+	c := s.Collection("webhook_deliveries")
+	c.EnsureIndex(webhookIndex)
+	return c
+}
+
+// Jobs returns the collection holding the scheduled jobs of apps.
+func (s *Storage) Jobs() *storage.Collection {
+	nameIndex := mgo.Index{Key: []string{"app", "name"}, Unique: true}
+	nextRunIndex := mgo.Index{Key: []string{"nextrun"}}
+	c := s.Collection("jobs")
+	c.EnsureIndex(nameIndex)
+	c.EnsureIndex(nextRunIndex)
+	return c
+}
+
+// JobRuns returns the collection holding the history of job runs.
+func (s *Storage) JobRuns() *storage.Collection {
+	jobIndex := mgo.Index{Key: []string{"app", "job", "-starttime"}}
+	c := s.Collection("job_runs")
+	c.EnsureIndex(jobIndex)
+	return c
+}
+
+// ACMEAccounts returns the collection holding the keys of the accounts used
+// to obtain certificates from ACME servers, keyed by the directory URL.
+func (s *Storage) ACMEAccounts() *storage.Collection {
+	return s.Collection("acme_accounts")
+}
+
+// ACMEChallenges returns the collection holding the key authorizations of
+// the pending ACME challenges, keyed by the challenge token.
+func (s *Storage) ACMEChallenges() *storage.Collection {
+	return s.Collection("acme_challenges")
+}
+
+// ACMECertificates returns the collection holding the issuance status of the
+// certificates of cnames obtained through ACME.
+func (s *Storage) ACMECertificates() *storage.Collection {
+	appIndex := mgo.Index{Key: []string{"app"}}
+	nextAttemptIndex := mgo.Index{Key: []string{"nextattempt"}}
+	c := s.Collection("acme_certificates")
+	c.EnsureIndex(appIndex)
+	c.EnsureIndex(nextAttemptIndex)
+	return c
+}
+
+// RoutesStatus returns the collection holding the result of the last check
+// of the routes of each app against its routable units, keyed by app name.
+func (s *Storage) RoutesStatus() *storage.Collection {
+	return s.Collection("routes_status")
+}
@@ -153,6 +153,26 @@ func (s *S) TestDeploys(c *check.C) {
 	c.Assert(deploys, HasIndex, []string{"app", "-timestamp"})
 }
 
+func (s *S) TestReleases(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	releases := strg.Releases()
+	releasesc := strg.Collection("releases")
+	c.Assert(releases, check.DeepEquals, releasesc)
+	c.Assert(releases, HasUniqueIndex, []string{"app", "-version"})
+}
+
+func (s *S) TestDeployQueue(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	queue := strg.DeployQueue()
+	queuec := strg.Collection("deploy_queue")
+	c.Assert(queue, check.DeepEquals, queuec)
+	c.Assert(queue, HasIndex, []string{"app", "timestamp"})
+}
+
 func (s *S) TestPlatforms(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
@@ -241,3 +261,93 @@ func (s *S) TestRoles(c *check.C) {
 	rolesc := strg.Collection("roles")
 	c.Assert(roles, check.DeepEquals, rolesc)
 }
+
+func (s *S) TestEvents(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	events := strg.Events()
+	eventsc := strg.Collection("events")
+	c.Assert(events, check.DeepEquals, eventsc)
+	c.Assert(events, HasIndex, []string{"uniqueid"})
+	c.Assert(events, HasIndex, []string{"-starttime"})
+}
+
+func (s *S) TestWebhooks(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	webhooks := strg.Webhooks()
+	webhooksc := strg.Collection("webhooks")
+	c.Assert(webhooks, check.DeepEquals, webhooksc)
+	c.Assert(webhooks, HasIndex, []string{"teamowner"})
+}
+
+func (s *S) TestWebhookDeliveries(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	deliveries := strg.WebhookDeliveries()
+	deliveriesc := strg.Collection("webhook_deliveries")
+	c.Assert(deliveries, check.DeepEquals, deliveriesc)
+	c.Assert(deliveries, HasIndex, []string{"webhook", "-timestamp"})
+}
+
+func (s *S) TestJobs(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	jobs := strg.Jobs()
+	jobsc := strg.Collection("jobs")
+	c.Assert(jobs, check.DeepEquals, jobsc)
+	c.Assert(jobs, HasUniqueIndex, []string{"app", "name"})
+	c.Assert(jobs, HasIndex, []string{"nextrun"})
+}
+
+func (s *S) TestJobRuns(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	runs := strg.JobRuns()
+	runsc := strg.Collection("job_runs")
+	c.Assert(runs, check.DeepEquals, runsc)
+	c.Assert(runs, HasIndex, []string{"app", "job", "-starttime"})
+}
+
+func (s *S) TestACMEAccounts(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	accounts := strg.ACMEAccounts()
+	accountsc := strg.Collection("acme_accounts")
+	c.Assert(accounts, check.DeepEquals, accountsc)
+}
+
+func (s *S) TestACMEChallenges(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	challenges := strg.ACMEChallenges()
+	challengesc := strg.Collection("acme_challenges")
+	c.Assert(challenges, check.DeepEquals, challengesc)
+}
+
+func (s *S) TestACMECertificates(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	certificates := strg.ACMECertificates()
+	certificatesc := strg.Collection("acme_certificates")
+	c.Assert(certificates, check.DeepEquals, certificatesc)
+	c.Assert(certificates, HasIndex, []string{"app"})
+	c.Assert(certificates, HasIndex, []string{"nextattempt"})
+}
+
+func (s *S) TestRoutesStatus(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	status := strg.RoutesStatus()
+	statusc := strg.Collection("routes_status")
+	c.Assert(status, check.DeepEquals, statusc)
+}
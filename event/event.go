@@ -0,0 +1,666 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package event provides types and functions for recording operations
+// executed in tsuru (events). Each event has a target, a kind and an owner,
+// and keeps track of its start and end times, status and output.
+//
+// While an event is running, it holds a lock on its target, preventing other
+// locking events from running on the same target at the same time.
+package event
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	lockUpdateInterval = 30 * time.Second
+	lockExpireTimeout  = 5 * time.Minute
+	updater            = lockUpdater{}
+
+	ErrNotCancelable     = errors.New("event is not cancelable")
+	ErrEventNotFound     = errors.New("event not found")
+	ErrNoOpts            = errors.New("event opts is required")
+	ErrNoTarget          = errors.New("event target is required")
+	ErrNoKind            = errors.New("event kind is required")
+	ErrNoOwner           = errors.New("event owner is required")
+	ErrNoInternalKind    = errors.New("event internal kind is required")
+	ErrInvalidOwner      = errors.New("event owner must not be set on internal events")
+	ErrInvalidKind       = errors.New("event kind must not be set on internal events")
+	ErrInvalidTargetType = errors.New("invalid event target type")
+)
+
+type ErrEventLocked struct {
+	Event *Event
+}
+
+func (err ErrEventLocked) Error() string {
+	return fmt.Sprintf("event locked: %v", err.Event)
+}
+
+type TargetType string
+
+const (
+	TargetTypeApp             TargetType = "app"
+	TargetTypeNode            TargetType = "node"
+	TargetTypeContainer       TargetType = "container"
+	TargetTypePool            TargetType = "pool"
+	TargetTypeServiceInstance TargetType = "service-instance"
+)
+
+var targetTypes = []TargetType{
+	TargetTypeApp,
+	TargetTypeNode,
+	TargetTypeContainer,
+	TargetTypePool,
+	TargetTypeServiceInstance,
+}
+
+// GetTargetType returns the TargetType represented by the given string,
+// returning an error if it's not a valid target type.
+func GetTargetType(t string) (TargetType, error) {
+	for _, tt := range targetTypes {
+		if string(tt) == t {
+			return tt, nil
+		}
+	}
+	return TargetType(""), ErrInvalidTargetType
+}
+
+type Target struct {
+	Type  TargetType
+	Value string
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s(%s)", t.Type, t.Value)
+}
+
+type eventID struct {
+	Target Target
+	ObjId  bson.ObjectId `bson:",omitempty"`
+}
+
+type OwnerType string
+
+const (
+	OwnerTypeUser     OwnerType = "user"
+	OwnerTypeApp      OwnerType = "app"
+	OwnerTypeInternal OwnerType = "internal"
+)
+
+type Owner struct {
+	Type OwnerType
+	Name string
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("%s %s", o.Type, o.Name)
+}
+
+type KindType string
+
+const (
+	KindTypePermission KindType = "permission"
+	KindTypeInternal   KindType = "internal"
+)
+
+type Kind struct {
+	Type KindType
+	Name string
+}
+
+func (k Kind) String() string {
+	return k.Name
+}
+
+type CancelInfo struct {
+	Owner     string
+	StartTime time.Time
+	AckTime   time.Time
+	Reason    string
+	Asked     bool
+	Canceled  bool
+}
+
+// Event represents an operation executed in tsuru.
+type Event struct {
+	ID              eventID `bson:"_id" json:"-"`
+	UniqueID        bson.ObjectId
+	StartTime       time.Time
+	EndTime         time.Time `bson:",omitempty"`
+	Target          Target
+	Kind            Kind
+	Owner           Owner
+	StartCustomData interface{} `bson:",omitempty"`
+	EndCustomData   interface{} `bson:",omitempty"`
+	LockUpdateTime  time.Time
+	Error           string
+	Log             string
+	Cancelable      bool
+	Running         bool
+	CancelInfo      CancelInfo
+
+	logBuffer *safe.Buffer
+	logWriter io.Writer
+}
+
+// Opts is the set of options used to create a new event.
+type Opts struct {
+	Target       Target
+	Kind         *permission.PermissionScheme
+	InternalKind string
+	Owner        auth.Token
+	CustomData   interface{}
+	DisableLock  bool
+	Cancelable   bool
+}
+
+type Filter struct {
+	Target         Target
+	KindType       KindType
+	KindName       string
+	OwnerType      OwnerType
+	OwnerName      string
+	Since          time.Time
+	Until          time.Time
+	Running        *bool
+	AllowedTargets []TargetFilter
+	Limit          int
+	Skip           int
+}
+
+// TargetFilter restricts the events returned in a listing to the ones whose
+// target has the given type and one of the given values. A nil Values field
+// means any value is accepted.
+type TargetFilter struct {
+	Type   TargetType
+	Values []string
+}
+
+func (f *Filter) toQuery() bson.M {
+	query := bson.M{}
+	if f.Target.Type != "" {
+		query["target.type"] = f.Target.Type
+	}
+	if f.Target.Value != "" {
+		query["target.value"] = f.Target.Value
+	}
+	if f.KindType != "" {
+		query["kind.type"] = f.KindType
+	}
+	if f.KindName != "" {
+		query["kind.name"] = f.KindName
+	}
+	if f.OwnerType != "" {
+		query["owner.type"] = f.OwnerType
+	}
+	if f.OwnerName != "" {
+		query["owner.name"] = f.OwnerName
+	}
+	var timeParts []bson.M
+	if !f.Since.IsZero() {
+		timeParts = append(timeParts, bson.M{"starttime": bson.M{"$gte": f.Since}})
+	}
+	if !f.Until.IsZero() {
+		timeParts = append(timeParts, bson.M{"starttime": bson.M{"$lte": f.Until}})
+	}
+	if len(timeParts) != 0 {
+		query["$and"] = timeParts
+	}
+	if f.Running != nil {
+		query["running"] = *f.Running
+	}
+	if f.AllowedTargets != nil {
+		var orBlock []bson.M
+		for _, at := range f.AllowedTargets {
+			item := bson.M{"target.type": at.Type}
+			if at.Values != nil {
+				item["target.value"] = bson.M{"$in": at.Values}
+			}
+			orBlock = append(orBlock, item)
+		}
+		if len(orBlock) == 0 {
+			// No allowed targets, the query must not match anything.
+			orBlock = append(orBlock, bson.M{"target.type": bson.M{"$in": []string{}}})
+		}
+		query["$or"] = orBlock
+	}
+	return query
+}
+
+// List returns the list of events matching the given filter, sorted by start
+// time, from the newest to the oldest.
+func List(filter *Filter) ([]Event, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{}
+	var limit, skip int
+	if filter != nil {
+		query = filter.toQuery()
+		limit, skip = filter.Limit, filter.Skip
+	}
+	find := conn.Events().Find(query).Sort("-starttime")
+	if skip != 0 {
+		find = find.Skip(skip)
+	}
+	if limit != 0 {
+		find = find.Limit(limit)
+	}
+	var evts []Event
+	err = find.All(&evts)
+	if err != nil {
+		return nil, err
+	}
+	return evts, nil
+}
+
+// GetByID returns the event identified by the given unique ID.
+func GetByID(id bson.ObjectId) (*Event, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var evt Event
+	err = conn.Events().Find(bson.M{"uniqueid": id}).One(&evt)
+	if err == mgo.ErrNotFound {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &evt, nil
+}
+
+// New creates a new event, started by the owner of the given token. If the
+// event locks its target (the default) and there's another running event
+// locking the same target, an ErrEventLocked is returned.
+func New(opts *Opts) (*Event, error) {
+	if opts == nil {
+		return nil, ErrNoOpts
+	}
+	if opts.Owner == nil {
+		return nil, ErrNoOwner
+	}
+	if opts.Kind == nil {
+		return nil, ErrNoKind
+	}
+	return newEvt(opts)
+}
+
+// NewInternal creates a new event started by tsuru itself, for operations
+// that aren't directly triggered by users, like healing and auto scale.
+func NewInternal(opts *Opts) (*Event, error) {
+	if opts == nil {
+		return nil, ErrNoOpts
+	}
+	if opts.Owner != nil {
+		return nil, ErrInvalidOwner
+	}
+	if opts.Kind != nil {
+		return nil, ErrInvalidKind
+	}
+	if opts.InternalKind == "" {
+		return nil, ErrNoInternalKind
+	}
+	return newEvt(opts)
+}
+
+func newEvt(opts *Opts) (*Event, error) {
+	if opts.Target.Type == "" || opts.Target.Value == "" {
+		return nil, ErrNoTarget
+	}
+	var k Kind
+	if opts.Kind == nil {
+		k.Type = KindTypeInternal
+		k.Name = opts.InternalKind
+	} else {
+		k.Type = KindTypePermission
+		k.Name = opts.Kind.FullName()
+	}
+	var o Owner
+	if opts.Owner == nil {
+		o.Type = OwnerTypeInternal
+	} else if opts.Owner.IsAppToken() {
+		o.Type = OwnerTypeApp
+		o.Name = opts.Owner.GetAppName()
+	} else {
+		o.Type = OwnerTypeUser
+		o.Name = opts.Owner.GetUserName()
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	coll := conn.Events()
+	uniqID := bson.NewObjectId()
+	id := eventID{Target: opts.Target}
+	if opts.DisableLock {
+		id.ObjId = uniqID
+	}
+	now := time.Now().UTC()
+	evt := Event{
+		ID:              id,
+		UniqueID:        uniqID,
+		Target:          opts.Target,
+		StartTime:       now,
+		LockUpdateTime:  now,
+		Kind:            k,
+		Owner:           o,
+		StartCustomData: opts.CustomData,
+		Cancelable:      opts.Cancelable,
+		Running:         true,
+		logBuffer:       safe.NewBuffer(nil),
+	}
+	maxRetries := 1
+	for i := 0; i < maxRetries+1; i++ {
+		err = coll.Insert(evt)
+		if err == nil {
+			updater.add(evt.ID)
+			return &evt, nil
+		}
+		if !mgo.IsDup(err) {
+			return nil, err
+		}
+		if i >= maxRetries {
+			break
+		}
+		var existing Event
+		err = coll.FindId(id).One(&existing)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if time.Since(existing.LockUpdateTime) <= lockExpireTimeout {
+			return nil, ErrEventLocked{Event: &existing}
+		}
+		// The lock wasn't updated for too long, the process holding it was
+		// probably killed before finishing the event, so we remove the
+		// stale event and try again.
+		log.Errorf("[events] removing expired lock from event %v", &existing)
+		err = existing.done(fmt.Errorf("event expired, no update for %v", time.Since(existing.LockUpdateTime)), nil, true)
+		if err != nil && err != mgo.ErrNotFound {
+			return nil, err
+		}
+	}
+	var existing Event
+	err = coll.FindId(id).One(&existing)
+	if err == nil {
+		return nil, ErrEventLocked{Event: &existing}
+	}
+	return nil, err
+}
+
+// SetLogWriter sets a writer that will also receive all data written in the
+// event log.
+func (e *Event) SetLogWriter(w io.Writer) {
+	e.logWriter = w
+}
+
+// Logf writes a formatted message in the event log.
+func (e *Event) Logf(format string, params ...interface{}) {
+	log.Debugf(fmt.Sprintf("%s(%s)[%s] %s", e.Target.Type, e.Target.Value, e.Kind, format), params...)
+	format += "\n"
+	fmt.Fprintf(e, format, params...)
+}
+
+// Write implements io.Writer, writing data in the event log and in the
+// writer set by SetLogWriter.
+func (e *Event) Write(data []byte) (int, error) {
+	if e.logWriter != nil {
+		e.logWriter.Write(data)
+	}
+	if e.logBuffer == nil {
+		e.logBuffer = safe.NewBuffer(nil)
+	}
+	return e.logBuffer.Write(data)
+}
+
+// TryCancel asks a cancelable running event to be canceled. The process
+// running the event is responsible for checking if cancellation was asked,
+// by calling CancelAsked, and acknowledging it with AckCancel once the
+// operation is actually stopped.
+func (e *Event) TryCancel(reason, owner string) error {
+	if !e.Cancelable || !e.Running {
+		return ErrNotCancelable
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	change := mgo.Change{
+		Update: bson.M{"$set": bson.M{
+			"cancelinfo": CancelInfo{
+				Owner:     owner,
+				Reason:    reason,
+				StartTime: time.Now().UTC(),
+				Asked:     true,
+			},
+		}},
+		ReturnNew: true,
+	}
+	var dbEvt Event
+	_, err = conn.Events().Find(bson.M{"_id": e.ID, "running": true, "cancelable": true}).Apply(change, &dbEvt)
+	if err == mgo.ErrNotFound {
+		return ErrNotCancelable
+	}
+	if err != nil {
+		return err
+	}
+	e.CancelInfo = dbEvt.CancelInfo
+	return nil
+}
+
+// CancelAsked checks whether cancellation was asked for the event, without
+// acknowledging it.
+func (e *Event) CancelAsked() (bool, error) {
+	if !e.Cancelable || !e.Running {
+		return false, nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	n, err := conn.Events().Find(bson.M{"_id": e.ID, "cancelinfo.asked": true}).Count()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// AckCancel marks the event as canceled, if cancellation was asked for it.
+// It should only be called after the operation tracked by the event is
+// stopped.
+func (e *Event) AckCancel() (bool, error) {
+	if !e.Cancelable || !e.Running {
+		return false, nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	change := mgo.Change{
+		Update: bson.M{"$set": bson.M{
+			"cancelinfo.acktime":  time.Now().UTC(),
+			"cancelinfo.canceled": true,
+		}},
+		ReturnNew: true,
+	}
+	var dbEvt Event
+	_, err = conn.Events().Find(bson.M{"_id": e.ID, "cancelinfo.asked": true}).Apply(change, &dbEvt)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	e.CancelInfo = dbEvt.CancelInfo
+	return true, nil
+}
+
+// Done finishes the event, storing its log and error, if any.
+func (e *Event) Done(evtErr error) error {
+	return e.done(evtErr, nil, false)
+}
+
+// DoneCustomData finishes the event, also storing some custom data about the
+// result of the operation.
+func (e *Event) DoneCustomData(evtErr error, customData interface{}) error {
+	return e.done(evtErr, customData, false)
+}
+
+// Abort finishes the event removing it from the database, it should be used
+// when the operation ended up not being executed.
+func (e *Event) Abort() error {
+	return e.done(nil, nil, true)
+}
+
+func (e *Event) done(evtErr error, customData interface{}, abort bool) (err error) {
+	defer func() {
+		if err != nil {
+			log.Errorf("[events] error marking event as done - %#v: %s", e, err)
+		}
+	}()
+	updater.remove(e.ID)
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.Events()
+	if abort {
+		return coll.RemoveId(e.ID)
+	}
+	if evtErr != nil {
+		e.Error = evtErr.Error()
+	} else if e.CancelInfo.Canceled {
+		e.Error = "canceled by user request"
+	}
+	e.EndTime = time.Now().UTC()
+	e.EndCustomData = customData
+	e.Running = false
+	if e.logBuffer != nil {
+		e.Log = e.logBuffer.String()
+	}
+	var dbEvt Event
+	err = coll.FindId(e.ID).One(&dbEvt)
+	if err == nil {
+		e.CancelInfo = dbEvt.CancelInfo
+	}
+	if len(e.ID.ObjId) != 0 {
+		return coll.UpdateId(e.ID, e)
+	}
+	defer coll.RemoveId(e.ID)
+	e.ID = eventID{Target: e.Target, ObjId: e.UniqueID}
+	return coll.Insert(e)
+}
+
+func (e *Event) String() string {
+	return fmt.Sprintf("%s(%s) running %q start by %s at %s",
+		e.Target.Type,
+		e.Target.Value,
+		e.Kind,
+		e.Owner,
+		e.StartTime.Format(time.RFC3339),
+	)
+}
+
+// FormToCustomData converts the given form values into a list of name/value
+// pairs, suitable for being stored as an event custom data. Field names are
+// kept as values because they may contain characters that aren't allowed in
+// mongodb keys.
+func FormToCustomData(form url.Values) []map[string]interface{} {
+	ret := make([]map[string]interface{}, 0, len(form))
+	for k, v := range form {
+		var value interface{} = v
+		if len(v) == 1 {
+			value = v[0]
+		}
+		ret = append(ret, map[string]interface{}{"name": k, "value": value})
+	}
+	return ret
+}
+
+// lockUpdater keeps updating the lock time of running events, so other
+// tsuru instances can tell apart running events from stale ones.
+type lockUpdater struct {
+	once     sync.Once
+	addCh    chan eventID
+	removeCh chan eventID
+}
+
+func (l *lockUpdater) start() {
+	l.once.Do(func() {
+		l.addCh = make(chan eventID)
+		l.removeCh = make(chan eventID)
+		go l.spin()
+	})
+}
+
+func (l *lockUpdater) add(id eventID) {
+	l.start()
+	l.addCh <- id
+}
+
+func (l *lockUpdater) remove(id eventID) {
+	l.start()
+	l.removeCh <- id
+}
+
+func (l *lockUpdater) spin() {
+	set := map[eventID]struct{}{}
+	ticker := time.NewTicker(lockUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case added := <-l.addCh:
+			set[added] = struct{}{}
+			continue
+		case removed := <-l.removeCh:
+			delete(set, removed)
+			continue
+		case <-ticker.C:
+		}
+		if len(set) == 0 {
+			continue
+		}
+		ids := make([]interface{}, 0, len(set))
+		for id := range set {
+			ids = append(ids, id)
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("[events] [lock update] error getting db conn: %s", err)
+			continue
+		}
+		_, err = conn.Events().UpdateAll(bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"lockupdatetime": time.Now().UTC()}})
+		if err != nil {
+			log.Errorf("[events] [lock update] error updating: %s", err)
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestNewDone(c *check.C) {
+	evt, err := New(&Opts{
+		Target:     Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:       permission.PermAppUpdateEnvSet,
+		Owner:      s.token,
+		CustomData: bson.M{"a": "b"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Running, check.Equals, true)
+	c.Assert(evt.Kind, check.DeepEquals, Kind{Type: KindTypePermission, Name: "app.update.env.set"})
+	c.Assert(evt.Owner, check.DeepEquals, Owner{Type: OwnerTypeUser, Name: "me@me.com"})
+	evts, err := List(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, true)
+	c.Assert(evts[0].UniqueID, check.Equals, evt.UniqueID)
+	c.Assert(evts[0].StartCustomData, check.DeepEquals, bson.M{"a": "b"})
+	evt.Logf("my log %d", 1)
+	err = evt.DoneCustomData(nil, bson.M{"c": "d"})
+	c.Assert(err, check.IsNil)
+	evts, err = List(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, false)
+	c.Assert(evts[0].Error, check.Equals, "")
+	c.Assert(evts[0].Log, check.Equals, "my log 1\n")
+	c.Assert(evts[0].EndTime.IsZero(), check.Equals, false)
+	c.Assert(evts[0].EndCustomData, check.DeepEquals, bson.M{"c": "d"})
+	c.Assert(evts[0].ID, check.DeepEquals, eventID{Target: evt.Target, ObjId: evt.UniqueID})
+}
+
+func (s *S) TestNewDoneWithError(c *check.C) {
+	evt, err := New(&Opts{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("something bad"))
+	c.Assert(err, check.IsNil)
+	got, err := GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.Error, check.Equals, "something bad")
+	c.Assert(got.Running, check.Equals, false)
+}
+
+func (s *S) TestNewAppToken(c *check.C) {
+	evt, err := New(&Opts{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  &fakeToken{appName: "otherapp"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Owner, check.DeepEquals, Owner{Type: OwnerTypeApp, Name: "otherapp"})
+}
+
+func (s *S) TestNewValidation(c *check.C) {
+	_, err := New(nil)
+	c.Assert(err, check.Equals, ErrNoOpts)
+	_, err = New(&Opts{Kind: permission.PermAppUpdateEnvSet})
+	c.Assert(err, check.Equals, ErrNoOwner)
+	_, err = New(&Opts{Owner: s.token})
+	c.Assert(err, check.Equals, ErrNoKind)
+	_, err = New(&Opts{Owner: s.token, Kind: permission.PermAppUpdateEnvSet})
+	c.Assert(err, check.Equals, ErrNoTarget)
+	_, err = NewInternal(&Opts{Owner: s.token, InternalKind: "healer"})
+	c.Assert(err, check.Equals, ErrInvalidOwner)
+	_, err = NewInternal(&Opts{Kind: permission.PermAppUpdateEnvSet, InternalKind: "healer"})
+	c.Assert(err, check.Equals, ErrInvalidKind)
+	_, err = NewInternal(&Opts{})
+	c.Assert(err, check.Equals, ErrNoInternalKind)
+}
+
+func (s *S) TestNewInternal(c *check.C) {
+	evt, err := NewInternal(&Opts{
+		Target:       Target{Type: TargetTypeNode, Value: "http://10.0.0.1:2375"},
+		InternalKind: "healer",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Kind, check.DeepEquals, Kind{Type: KindTypeInternal, Name: "healer"})
+	c.Assert(evt.Owner, check.DeepEquals, Owner{Type: OwnerTypeInternal})
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestNewLocked(c *check.C) {
+	opts := &Opts{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	}
+	evt, err := New(opts)
+	c.Assert(err, check.IsNil)
+	_, err = New(opts)
+	c.Assert(err, check.FitsTypeOf, ErrEventLocked{})
+	c.Assert(err.(ErrEventLocked).Event.UniqueID, check.Equals, evt.UniqueID)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	evt2, err := New(opts)
+	c.Assert(err, check.IsNil)
+	err = evt2.Done(nil)
+	c.Assert(err, check.IsNil)
+	evts, err := List(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+}
+
+func (s *S) TestNewDisableLock(c *check.C) {
+	opts := &Opts{
+		Target:      Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:        permission.PermAppUpdateEnvSet,
+		Owner:       s.token,
+		DisableLock: true,
+	}
+	evt1, err := New(opts)
+	c.Assert(err, check.IsNil)
+	evt2, err := New(opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt1.Done(nil), check.IsNil)
+	c.Assert(evt2.Done(nil), check.IsNil)
+	evts, err := List(&Filter{Running: new(bool)})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+}
+
+func (s *S) TestNewExpiredLock(c *check.C) {
+	opts := &Opts{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	}
+	evt, err := New(opts)
+	c.Assert(err, check.IsNil)
+	updater.remove(evt.ID)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Events().UpdateId(evt.ID, bson.M{"$set": bson.M{"lockupdatetime": time.Now().Add(-2 * lockExpireTimeout)}})
+	c.Assert(err, check.IsNil)
+	evt2, err := New(opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt2.Done(nil), check.IsNil)
+	expired, err := GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(expired.Running, check.Equals, false)
+	c.Assert(expired.Error, check.Matches, "event expired, no update for .*")
+}
+
+func (s *S) TestLogWriter(c *check.C) {
+	evt, err := New(&Opts{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	evt.SetLogWriter(&buf)
+	evt.Write([]byte("hello "))
+	evt.Logf("world")
+	c.Assert(buf.String(), check.Equals, "hello world\n")
+	c.Assert(evt.Done(nil), check.IsNil)
+	got, err := GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.Log, check.Equals, "hello world\n")
+}
+
+func (s *S) TestAbort(c *check.C) {
+	evt, err := New(&Opts{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Abort(), check.IsNil)
+	evts, err := List(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestListFilter(c *check.C) {
+	evt1, err := New(&Opts{
+		Target: Target{Type: TargetTypeApp, Value: "app1"},
+		Kind:   permission.PermAppUpdateEnvSet,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt1.Done(nil), check.IsNil)
+	evt2, err := New(&Opts{
+		Target: Target{Type: TargetTypeApp, Value: "app2"},
+		Kind:   permission.PermAppDeploy,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	evt3, err := NewInternal(&Opts{
+		Target:       Target{Type: TargetTypeNode, Value: "node1"},
+		InternalKind: "healer",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt3.Done(nil), check.IsNil)
+	evts, err := List(&Filter{Target: Target{Type: TargetTypeApp}})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+	evts, err = List(&Filter{KindName: "app.deploy"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].UniqueID, check.Equals, evt2.UniqueID)
+	running := true
+	evts, err = List(&Filter{Running: &running})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].UniqueID, check.Equals, evt2.UniqueID)
+	evts, err = List(&Filter{KindType: KindTypeInternal})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].UniqueID, check.Equals, evt3.UniqueID)
+	evts, err = List(&Filter{AllowedTargets: []TargetFilter{{Type: TargetTypeApp, Values: []string{"app1"}}}})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].UniqueID, check.Equals, evt1.UniqueID)
+	evts, err = List(&Filter{AllowedTargets: []TargetFilter{}})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+	evts, err = List(&Filter{Limit: 1})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].UniqueID, check.Equals, evt3.UniqueID)
+	c.Assert(evt2.Done(nil), check.IsNil)
+}
+
+func (s *S) TestGetByIDNotFound(c *check.C) {
+	_, err := GetByID(bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrEventNotFound)
+}
+
+func (s *S) TestCancel(c *check.C) {
+	evt, err := New(&Opts{
+		Target:     Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:       permission.PermAppDeploy,
+		Owner:      s.token,
+		Cancelable: true,
+	})
+	c.Assert(err, check.IsNil)
+	asked, err := evt.CancelAsked()
+	c.Assert(err, check.IsNil)
+	c.Assert(asked, check.Equals, false)
+	canceled, err := evt.AckCancel()
+	c.Assert(err, check.IsNil)
+	c.Assert(canceled, check.Equals, false)
+	err = evt.TryCancel("because I want", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.CancelInfo.Asked, check.Equals, true)
+	c.Assert(evt.CancelInfo.Reason, check.Equals, "because I want")
+	asked, err = evt.CancelAsked()
+	c.Assert(err, check.IsNil)
+	c.Assert(asked, check.Equals, true)
+	c.Assert(evt.CancelInfo.Canceled, check.Equals, false)
+	canceled, err = evt.AckCancel()
+	c.Assert(err, check.IsNil)
+	c.Assert(canceled, check.Equals, true)
+	c.Assert(evt.Done(nil), check.IsNil)
+	got, err := GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.Error, check.Equals, "canceled by user request")
+	c.Assert(got.CancelInfo.Canceled, check.Equals, true)
+	c.Assert(got.CancelInfo.Owner, check.Equals, "admin@example.com")
+}
+
+func (s *S) TestCancelNotCancelable(c *check.C) {
+	evt, err := New(&Opts{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   permission.PermAppDeploy,
+		Owner:  s.token,
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("reason", "admin@example.com")
+	c.Assert(err, check.Equals, ErrNotCancelable)
+	c.Assert(evt.Done(nil), check.IsNil)
+}
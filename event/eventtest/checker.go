@@ -0,0 +1,118 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventtest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// EventDesc describes an event to be matched by the HasEvent checker. Empty
+// fields are ignored when looking for the event.
+//
+// Custom data may be either a map[string]interface{}, whose keys are matched
+// against the event custom data using mongodb dotted notation, or a
+// []map[string]interface{}, whose items must all be present in the event
+// custom data list (as generated by event.FormToCustomData).
+type EventDesc struct {
+	Target          event.Target
+	Kind            string
+	Owner           string
+	StartCustomData interface{}
+	EndCustomData   interface{}
+	ErrorMatches    string
+	LogMatches      string
+	IsEmpty         bool
+}
+
+type hasEventChecker struct{}
+
+func (hasEventChecker) Info() *check.CheckerInfo {
+	return &check.CheckerInfo{Name: "HasEvent", Params: []string{"event desc"}}
+}
+
+func queryPartCustom(query bson.M, name string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			query[fmt.Sprintf("%s.%s", name, k)] = item
+		}
+	case []map[string]interface{}:
+		if len(v) == 0 {
+			return
+		}
+		all := make([]bson.M, len(v))
+		for i, item := range v {
+			all[i] = bson.M{"$elemMatch": item}
+		}
+		query[name] = bson.M{"$all": all}
+	}
+}
+
+func (hasEventChecker) Check(params []interface{}, names []string) (bool, string) {
+	var evt EventDesc
+	switch params[0].(type) {
+	case EventDesc:
+		evt = params[0].(EventDesc)
+	case *EventDesc:
+		evt = *params[0].(*EventDesc)
+	default:
+		return false, "First parameter must be of type EventDesc or *EventDesc"
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err.Error()
+	}
+	defer conn.Close()
+	query := bson.M{
+		"target":  evt.Target,
+		"running": false,
+	}
+	if evt.Kind != "" {
+		query["kind.name"] = evt.Kind
+	}
+	if evt.Owner != "" {
+		query["owner.name"] = evt.Owner
+	}
+	if evt.ErrorMatches != "" {
+		query["error"] = bson.M{"$regex": evt.ErrorMatches}
+	} else {
+		query["error"] = ""
+	}
+	if evt.LogMatches != "" {
+		query["log"] = bson.M{"$regex": evt.LogMatches}
+	}
+	queryPartCustom(query, "startcustomdata", evt.StartCustomData)
+	queryPartCustom(query, "endcustomdata", evt.EndCustomData)
+	timeout := time.After(2 * time.Second)
+	for {
+		n, err := conn.Events().Find(query).Count()
+		if err != nil {
+			return false, err.Error()
+		}
+		if evt.IsEmpty && n == 0 {
+			return true, ""
+		}
+		if !evt.IsEmpty && n > 0 {
+			return true, ""
+		}
+		select {
+		case <-timeout:
+			var all []event.Event
+			conn.Events().Find(nil).All(&all)
+			return false, fmt.Sprintf("event not found with query %#v, existing events: %#v", query, all)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// HasEvent checks whether a finished event matching the given EventDesc
+// exists in the database.
+var HasEvent check.Checker = hasEventChecker{}
@@ -0,0 +1,61 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	token auth.Token
+}
+
+var _ = check.Suite(&S{})
+
+type fakeToken struct {
+	userName string
+	appName  string
+}
+
+func (t *fakeToken) GetValue() string    { return "abc" }
+func (t *fakeToken) GetAppName() string  { return t.appName }
+func (t *fakeToken) GetUserName() string { return t.userName }
+func (t *fakeToken) IsAppToken() bool    { return t.appName != "" }
+func (t *fakeToken) User() (*auth.User, error) {
+	return &auth.User{Email: t.userName}, nil
+}
+func (t *fakeToken) Permissions() ([]permission.Permission, error) {
+	return nil, nil
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_events_tests")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = dbtest.ClearAllCollections(conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	s.token = &fakeToken{userName: "me@me.com"}
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+}
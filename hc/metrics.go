@@ -0,0 +1,66 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	statusDesc = prometheus.NewDesc(
+		"tsuru_healthcheck_status",
+		"The status of the components checked by the healthcheck, 1 when working and 0 otherwise.",
+		[]string{"name"}, nil,
+	)
+	durationDesc = prometheus.NewDesc(
+		"tsuru_healthcheck_duration_seconds",
+		"The time taken by the last check of each component.",
+		[]string{"name"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(&collector{})
+}
+
+// checkCacheTTL is the time the collector reuses the results of Check, so
+// metric scrapes don't hit every checked component.
+var checkCacheTTL = time.Minute
+
+// collector reports the results of Check, omitting disabled components.
+type collector struct {
+	mu        sync.Mutex
+	results   []Result
+	checkedAt time.Time
+}
+
+func (c *collector) check() []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil || time.Since(c.checkedAt) >= checkCacheTTL {
+		c.results = Check()
+		c.checkedAt = time.Now()
+	}
+	return c.results
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- statusDesc
+	ch <- durationDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, result := range c.check() {
+		var status float64
+		if result.Status == HealthCheckOK {
+			status = 1
+		}
+		ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, status, result.Name)
+		ch <- prometheus.MustNewConstMetric(durationDesc, prometheus.GaugeValue, result.Duration.Seconds(), result.Name)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hc
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/check.v1"
+)
+
+func (HCSuite) TestCollector(c *check.C) {
+	defer func(old []healthChecker) {
+		checkers = old
+	}(checkers)
+	checkers = nil
+	AddChecker("success", successChecker)
+	AddChecker("failing", failingChecker)
+	AddChecker("disabled", disabledChecker)
+	registry := prometheus.NewPedanticRegistry()
+	err := registry.Register(&collector{})
+	c.Assert(err, check.IsNil)
+	families, err := registry.Gather()
+	c.Assert(err, check.IsNil)
+	c.Assert(families, check.HasLen, 2)
+	c.Assert(families[0].GetName(), check.Equals, "tsuru_healthcheck_duration_seconds")
+	c.Assert(families[0].GetMetric(), check.HasLen, 2)
+	c.Assert(families[1].GetName(), check.Equals, "tsuru_healthcheck_status")
+	status := map[string]float64{}
+	for _, m := range families[1].GetMetric() {
+		status[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+	c.Assert(status, check.DeepEquals, map[string]float64{"success": 1, "failing": 0})
+}
+
+func (HCSuite) TestCollectorCachesResults(c *check.C) {
+	defer func(old []healthChecker, ttl time.Duration) {
+		checkers = old
+		checkCacheTTL = ttl
+	}(checkers, checkCacheTTL)
+	checkers = nil
+	var calls int
+	AddChecker("counting", func() error {
+		calls++
+		return nil
+	})
+	registry := prometheus.NewPedanticRegistry()
+	err := registry.Register(&collector{})
+	c.Assert(err, check.IsNil)
+	_, err = registry.Gather()
+	c.Assert(err, check.IsNil)
+	_, err = registry.Gather()
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.Equals, 1)
+	checkCacheTTL = 0
+	_, err = registry.Gather()
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.Equals, 2)
+}
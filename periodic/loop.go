@@ -0,0 +1,69 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package periodic runs functions in background at a fixed interval, for the
+// maintenance tasks executed by every tsuru API instance.
+package periodic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/log"
+)
+
+// Loop calls Task in background, waiting Interval between calls, until
+// Shutdown is called. Errors are logged and panics recovered, so the loop
+// never stops by itself.
+type Loop struct {
+	Name     string
+	Interval time.Duration
+	Task     func() error
+	done     chan bool
+}
+
+// Start runs the loop in background.
+func (l *Loop) Start() {
+	l.done = make(chan bool)
+	go l.run()
+}
+
+func (l *Loop) run() {
+	for {
+		err := l.RunOnce()
+		if err != nil {
+			log.Errorf("[%s] %s", l.Name, err)
+		}
+		select {
+		case <-l.done:
+			return
+		case <-time.After(l.Interval):
+		}
+	}
+}
+
+// RunOnce calls the task of the loop, returning a recovered panic as an
+// error.
+func (l *Loop) RunOnce() (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("recovered panic, we can never stop! panic: %v", r)
+		}
+	}()
+	return l.Task()
+}
+
+// Shutdown stops the loop, waiting for the running call of the task to
+// finish. It does nothing when the loop isn't running.
+func (l *Loop) Shutdown() {
+	if l.done == nil {
+		return
+	}
+	l.done <- true
+	l.done = nil
+}
+
+func (l *Loop) String() string {
+	return l.Name
+}
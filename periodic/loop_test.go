@@ -0,0 +1,75 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package periodic
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+func (s *S) TestLoopRunsTask(c *check.C) {
+	var calls int32
+	l := &Loop{
+		Name:     "test loop",
+		Interval: 10 * time.Millisecond,
+		Task: func() error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("failed")
+		},
+	}
+	l.Start()
+	timeout := time.After(5 * time.Second)
+	for atomic.LoadInt32(&calls) < 2 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for the task to run")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	l.Shutdown()
+	stopped := atomic.LoadInt32(&calls)
+	time.Sleep(50 * time.Millisecond)
+	c.Assert(atomic.LoadInt32(&calls), check.Equals, stopped)
+}
+
+func (s *S) TestLoopShutdownNotRunning(c *check.C) {
+	l := &Loop{Name: "test loop", Interval: time.Minute, Task: func() error { return nil }}
+	done := make(chan struct{})
+	go func() {
+		l.Shutdown()
+		l.Start()
+		l.Shutdown()
+		l.Shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for the loop to shut down")
+	}
+}
+
+func (s *S) TestLoopRunOnceRecoversPanic(c *check.C) {
+	l := &Loop{Task: func() error {
+		panic("boom")
+	}}
+	err := l.RunOnce()
+	c.Assert(err, check.ErrorMatches, "recovered panic, we can never stop! panic: boom")
+}
+
+func (s *S) TestLoopString(c *check.C) {
+	l := &Loop{Name: "test loop"}
+	c.Assert(l.String(), check.Equals, "test loop")
+}
@@ -11,6 +11,7 @@ var (
 	PermAll                              = PermissionRegistry.get("")
 	PermApp                              = PermissionRegistry.get("app")
 	PermAppAdmin                         = PermissionRegistry.get("app.admin")
+	PermAppAdminPool                     = PermissionRegistry.get("app.admin.pool")
 	PermAppAdminQuota                    = PermissionRegistry.get("app.admin.quota")
 	PermAppAdminRoutes                   = PermissionRegistry.get("app.admin.routes")
 	PermAppAdminUnlock                   = PermissionRegistry.get("app.admin.unlock")
@@ -19,31 +20,53 @@ var (
 	PermAppDeploy                        = PermissionRegistry.get("app.deploy")
 	PermAppDeployArchiveUrl              = PermissionRegistry.get("app.deploy.archive-url")
 	PermAppDeployBuild                   = PermissionRegistry.get("app.deploy.build")
+	PermAppDeployCanary                  = PermissionRegistry.get("app.deploy.canary")
+	PermAppDeployCanaryAbort             = PermissionRegistry.get("app.deploy.canary.abort")
+	PermAppDeployCanaryPromote           = PermissionRegistry.get("app.deploy.canary.promote")
+	PermAppDeployDockerfile              = PermissionRegistry.get("app.deploy.dockerfile")
 	PermAppDeployGit                     = PermissionRegistry.get("app.deploy.git")
 	PermAppDeployImage                   = PermissionRegistry.get("app.deploy.image")
+	PermAppDeployPromote                 = PermissionRegistry.get("app.deploy.promote")
+	PermAppDeployQueue                   = PermissionRegistry.get("app.deploy.queue")
 	PermAppDeployRollback                = PermissionRegistry.get("app.deploy.rollback")
 	PermAppDeployUpload                  = PermissionRegistry.get("app.deploy.upload")
 	PermAppRead                          = PermissionRegistry.get("app.read")
+	PermAppReadCertificate               = PermissionRegistry.get("app.read.certificate")
 	PermAppReadDeploy                    = PermissionRegistry.get("app.read.deploy")
 	PermAppReadEnv                       = PermissionRegistry.get("app.read.env")
+	PermAppReadEvents                    = PermissionRegistry.get("app.read.events")
+	PermAppReadJob                       = PermissionRegistry.get("app.read.job")
 	PermAppReadLog                       = PermissionRegistry.get("app.read.log")
 	PermAppReadMetric                    = PermissionRegistry.get("app.read.metric")
+	PermAppReadRoutes                    = PermissionRegistry.get("app.read.routes")
 	PermAppRun                           = PermissionRegistry.get("app.run")
 	PermAppUpdate                        = PermissionRegistry.get("app.update")
 	PermAppUpdateBind                    = PermissionRegistry.get("app.update.bind")
+	PermAppUpdateCertificate             = PermissionRegistry.get("app.update.certificate")
+	PermAppUpdateCertificateSet          = PermissionRegistry.get("app.update.certificate.set")
+	PermAppUpdateCertificateUnset        = PermissionRegistry.get("app.update.certificate.unset")
 	PermAppUpdateCname                   = PermissionRegistry.get("app.update.cname")
 	PermAppUpdateCnameAdd                = PermissionRegistry.get("app.update.cname.add")
 	PermAppUpdateCnameRemove             = PermissionRegistry.get("app.update.cname.remove")
+	PermAppUpdateDeployQueue             = PermissionRegistry.get("app.update.deploy-queue")
 	PermAppUpdateDescription             = PermissionRegistry.get("app.update.description")
 	PermAppUpdateEnv                     = PermissionRegistry.get("app.update.env")
 	PermAppUpdateEnvSet                  = PermissionRegistry.get("app.update.env.set")
 	PermAppUpdateEnvUnset                = PermissionRegistry.get("app.update.env.unset")
 	PermAppUpdateGrant                   = PermissionRegistry.get("app.update.grant")
+	PermAppUpdateJob                     = PermissionRegistry.get("app.update.job")
+	PermAppUpdateJobCreate               = PermissionRegistry.get("app.update.job.create")
+	PermAppUpdateJobDelete               = PermissionRegistry.get("app.update.job.delete")
+	PermAppUpdateJobRun                  = PermissionRegistry.get("app.update.job.run")
+	PermAppUpdateJobUpdate               = PermissionRegistry.get("app.update.job.update")
 	PermAppUpdateLog                     = PermissionRegistry.get("app.update.log")
 	PermAppUpdatePlan                    = PermissionRegistry.get("app.update.plan")
 	PermAppUpdatePool                    = PermissionRegistry.get("app.update.pool")
 	PermAppUpdateRestart                 = PermissionRegistry.get("app.update.restart")
 	PermAppUpdateRevoke                  = PermissionRegistry.get("app.update.revoke")
+	PermAppUpdateRouter                  = PermissionRegistry.get("app.update.router")
+	PermAppUpdateRouterAdd               = PermissionRegistry.get("app.update.router.add")
+	PermAppUpdateRouterRemove            = PermissionRegistry.get("app.update.router.remove")
 	PermAppUpdateSleep                   = PermissionRegistry.get("app.update.sleep")
 	PermAppUpdateStart                   = PermissionRegistry.get("app.update.start")
 	PermAppUpdateStop                    = PermissionRegistry.get("app.update.stop")
@@ -52,6 +75,7 @@ var (
 	PermAppUpdateUnbind                  = PermissionRegistry.get("app.update.unbind")
 	PermAppUpdateUnit                    = PermissionRegistry.get("app.update.unit")
 	PermAppUpdateUnitAdd                 = PermissionRegistry.get("app.update.unit.add")
+	PermAppUpdateUnitAutoscale           = PermissionRegistry.get("app.update.unit.autoscale")
 	PermAppUpdateUnitRegister            = PermissionRegistry.get("app.update.unit.register")
 	PermAppUpdateUnitRemove              = PermissionRegistry.get("app.update.unit.remove")
 	PermAppUpdateUnitStatus              = PermissionRegistry.get("app.update.unit.status")
@@ -68,11 +92,14 @@ var (
 	PermMachineTemplateDelete            = PermissionRegistry.get("machine.template.delete")
 	PermMachineTemplateRead              = PermissionRegistry.get("machine.template.read")
 	PermMachineTemplateUpdate            = PermissionRegistry.get("machine.template.update")
+	PermMetrics                          = PermissionRegistry.get("metrics")
+	PermMetricsRead                      = PermissionRegistry.get("metrics.read")
 	PermNode                             = PermissionRegistry.get("node")
 	PermNodeAutoscale                    = PermissionRegistry.get("node.autoscale")
 	PermNodeCreate                       = PermissionRegistry.get("node.create")
 	PermNodeDelete                       = PermissionRegistry.get("node.delete")
 	PermNodeRead                         = PermissionRegistry.get("node.read")
+	PermNodeReadEvents                   = PermissionRegistry.get("node.read.events")
 	PermNodeUpdate                       = PermissionRegistry.get("node.update")
 	PermNodecontainer                    = PermissionRegistry.get("nodecontainer")
 	PermNodecontainerCreate              = PermissionRegistry.get("nodecontainer.create")
@@ -90,6 +117,8 @@ var (
 	PermPool                             = PermissionRegistry.get("pool")
 	PermPoolCreate                       = PermissionRegistry.get("pool.create")
 	PermPoolDelete                       = PermissionRegistry.get("pool.delete")
+	PermPoolRead                         = PermissionRegistry.get("pool.read")
+	PermPoolReadEvents                   = PermissionRegistry.get("pool.read.events")
 	PermPoolUpdate                       = PermissionRegistry.get("pool.update")
 	PermPoolUpdateLogs                   = PermissionRegistry.get("pool.update.logs")
 	PermRole                             = PermissionRegistry.get("role")
@@ -106,6 +135,7 @@ var (
 	PermServiceInstanceCreate            = PermissionRegistry.get("service-instance.create")
 	PermServiceInstanceDelete            = PermissionRegistry.get("service-instance.delete")
 	PermServiceInstanceRead              = PermissionRegistry.get("service-instance.read")
+	PermServiceInstanceReadEvents        = PermissionRegistry.get("service-instance.read.events")
 	PermServiceInstanceReadStatus        = PermissionRegistry.get("service-instance.read.status")
 	PermServiceInstanceUpdate            = PermissionRegistry.get("service-instance.update")
 	PermServiceInstanceUpdateBind        = PermissionRegistry.get("service-instance.update.bind")
@@ -133,4 +163,9 @@ var (
 	PermUserUpdate                       = PermissionRegistry.get("user.update")
 	PermUserUpdateQuota                  = PermissionRegistry.get("user.update.quota")
 	PermUserUpdateToken                  = PermissionRegistry.get("user.update.token")
+	PermWebhook                          = PermissionRegistry.get("webhook")
+	PermWebhookCreate                    = PermissionRegistry.get("webhook.create")
+	PermWebhookDelete                    = PermissionRegistry.get("webhook.delete")
+	PermWebhookRead                      = PermissionRegistry.get("webhook.read")
+	PermWebhookUpdate                    = PermissionRegistry.get("webhook.update")
 )
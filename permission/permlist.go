@@ -18,6 +18,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.unit.remove",
 	"app.update.unit.register",
 	"app.update.unit.status",
+	"app.update.unit.autoscale",
 	"app.update.env.set",
 	"app.update.env.unset",
 	"app.update.restart",
@@ -30,31 +31,52 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.teamowner",
 	"app.update.cname.add",
 	"app.update.cname.remove",
+	"app.update.certificate.set",
+	"app.update.certificate.unset",
+	"app.update.router.add",
+	"app.update.router.remove",
 	"app.update.plan",
 	"app.update.bind",
 	"app.update.unbind",
+	"app.update.job.create",
+	"app.update.job.update",
+	"app.update.job.delete",
+	"app.update.job.run",
+	"app.update.deploy-queue",
 	"app.deploy",
 	"app.deploy.archive-url",
 	"app.deploy.build",
+	"app.deploy.canary",
+	"app.deploy.canary.abort",
+	"app.deploy.canary.promote",
+	"app.deploy.dockerfile",
 	"app.deploy.git",
 	"app.deploy.image",
+	"app.deploy.promote",
+	"app.deploy.queue",
 	"app.deploy.rollback",
 	"app.deploy.upload",
 	"app.read",
 	"app.read.deploy",
+	"app.read.certificate",
 	"app.read.env",
 	"app.read.metric",
 	"app.read.log",
+	"app.read.events",
+	"app.read.job",
+	"app.read.routes",
 	"app.delete",
 	"app.run",
 	"app.admin.unlock",
 	"app.admin.routes",
 	"app.admin.quota",
+	"app.admin.pool",
 ).addWithCtx(
 	"node", []contextType{CtxPool},
 ).add(
 	"node.create",
 	"node.read",
+	"node.read.events",
 	"node.update",
 	"node.delete",
 	"node.autoscale",
@@ -97,6 +119,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"service-instance.create", []contextType{CtxTeam},
 ).add(
 	"service-instance.read.status",
+	"service-instance.read.events",
 	"service-instance.delete",
 	"service-instance.update.proxy",
 	"service-instance.update.bind",
@@ -123,10 +146,12 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).addWithCtx(
 	"pool.create", []contextType{},
 ).add(
+	"pool.read.events",
 	"pool.update.logs",
 	"pool.delete",
 ).add(
 	"debug",
+	"metrics.read",
 ).add(
 	"healing.read",
 ).addWithCtx(
@@ -142,4 +167,11 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"nodecontainer.update",
 	"nodecontainer.update.upgrade",
 	"nodecontainer.delete",
+).addWithCtx(
+	"webhook", []contextType{CtxTeam},
+).add(
+	"webhook.create",
+	"webhook.read",
+	"webhook.update",
+	"webhook.delete",
 )
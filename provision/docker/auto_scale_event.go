@@ -13,6 +13,7 @@ import (
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/safe"
 	"gopkg.in/mgo.v2"
@@ -39,6 +40,7 @@ type autoScaleEvent struct {
 	Nodes         []cluster.Node
 	logBuffer     safe.Buffer
 	writer        io.Writer
+	evt           *event.Event
 }
 
 func autoScaleCollection() (*storage.Collection, error) {
@@ -72,7 +74,25 @@ func newAutoScaleEvent(metadataValue string, writer io.Writer) (*autoScaleEvent,
 	if mgo.IsDup(err) {
 		return nil, errAutoScaleRunning
 	}
-	return &evt, err
+	if err != nil {
+		return nil, err
+	}
+	// Nodes without grouping metadata have no target to be associated with,
+	// so their scaling is only recorded in the auto scale history.
+	if metadataValue != "" {
+		evt.evt, err = event.NewInternal(&event.Opts{
+			Target:       event.Target{Type: event.TargetTypePool, Value: metadataValue},
+			InternalKind: "autoscale",
+		})
+		if err != nil {
+			coll.RemoveId(evt.ID)
+			if _, ok := err.(event.ErrEventLocked); ok {
+				return nil, errAutoScaleRunning
+			}
+			return nil, err
+		}
+	}
+	return &evt, nil
 }
 
 func (evt *autoScaleEvent) updateNodes(nodes []cluster.Node) {
@@ -86,6 +106,9 @@ func (evt *autoScaleEvent) logMsg(msg string, params ...interface{}) {
 		fmt.Fprintf(evt.writer, msg, params...)
 	}
 	fmt.Fprintf(&evt.logBuffer, msg, params...)
+	if evt.evt != nil {
+		fmt.Fprintf(evt.evt, msg, params...)
+	}
 }
 
 func (evt *autoScaleEvent) update(action, reason string) error {
@@ -104,6 +127,17 @@ func (evt *autoScaleEvent) finish(errParam error) error {
 		evt.Error = errParam.Error()
 		evt.logMsg(evt.Error)
 	}
+	if evt.evt != nil {
+		if evt.Action == "" {
+			evt.evt.Abort()
+		} else {
+			evt.evt.DoneCustomData(errParam, map[string]interface{}{
+				"action": evt.Action,
+				"reason": evt.Reason,
+				"nodes":  evt.Nodes,
+			})
+		}
+	}
 	coll, err := autoScaleCollection()
 	if err != nil {
 		return err
@@ -115,6 +149,7 @@ func (evt *autoScaleEvent) finish(errParam error) error {
 	evt.Log = evt.logBuffer.String()
 	evt.Successful = errParam == nil
 	evt.EndTime = time.Now().UTC()
+	observeAutoScale("node", evt.Action, evt.Successful)
 	defer coll.RemoveId(evt.ID)
 	evt.ID = bson.NewObjectId()
 	return coll.Insert(evt)
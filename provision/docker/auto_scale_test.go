@@ -18,6 +18,8 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/iaas"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
@@ -155,6 +157,15 @@ func (s *AutoScaleSuite) TestAutoScaleConfigRun(c *check.C) {
 	c.Assert(logParts[0], check.Matches, `.*running scaler.*countScaler.*pool1.*`)
 	c.Assert(logParts[2], check.Matches, `.*new machine created.*`)
 	c.Assert(logParts[5], check.Matches, `.*Rebalancing 4 units.*`)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Kind:   "autoscale",
+		EndCustomData: map[string]interface{}{
+			"action": "add",
+			"reason": "number of free slots is -2, adding 1 nodes",
+		},
+		LogMatches: `Rebalancing 4 units`,
+	}, eventtest.HasEvent)
 	// Also should have rebalanced
 	containers1, err := s.p.listContainersByHost(net.URLToHost(nodes[0].Address))
 	c.Assert(err, check.IsNil)
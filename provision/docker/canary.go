@@ -0,0 +1,176 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/tsuru/tsuru/action"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+)
+
+var errCanaryWithoutUnits = errors.New("canary deploys require the app to have running units")
+
+func canaryWeight(a provision.App) int {
+	if ca, ok := a.(provision.CanaryApp); ok {
+		return ca.GetCanaryWeight()
+	}
+	return 0
+}
+
+// canaryUnitsToAdd returns the number of canary units to be started for each
+// process of the new image, proportional to the weight of the canary and to
+// the number of units currently running each process, with at least one unit
+// per process.
+func canaryUnitsToAdd(data ImageMetadata, oldContainers []container.Container, weight int) map[string]*containersToAdd {
+	current := getContainersToAdd(data, oldContainers)
+	for _, ct := range current {
+		ct.Quantity = int(math.Ceil(float64(ct.Quantity*weight) / 100))
+		if ct.Quantity < 1 {
+			ct.Quantity = 1
+		}
+	}
+	return current
+}
+
+// runCanaryDeploy starts units with the new image alongside the current units
+// of the app, routing them without updating the current image of the app.
+func (p *dockerProvisioner) runCanaryDeploy(w io.Writer, a provision.App, oldContainers []container.Container, imageId string, weight int) error {
+	if len(oldContainers) == 0 {
+		return errCanaryWithoutUnits
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	imageData, err := getImageCustomData(imageId)
+	if err != nil {
+		return err
+	}
+	toAdd := canaryUnitsToAdd(imageData, oldContainers, weight)
+	total := len(oldContainers)
+	for _, ct := range toAdd {
+		total += ct.Quantity
+	}
+	err = a.SetQuotaInUse(total)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n---- Starting canary units with %d%% of the traffic ----\n", weight)
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toAdd:       toAdd,
+		writer:      w,
+		imageId:     imageId,
+		provisioner: p,
+	}
+	pipeline := action.NewPipeline(
+		&provisionAddUnitsToHost,
+		&bindAndHealthcheck,
+		&addNewRoutes,
+	)
+	err = pipeline.Execute(args)
+	if err != nil {
+		a.SetQuotaInUse(len(oldContainers))
+	}
+	return err
+}
+
+func (p *dockerProvisioner) canaryContainers(appName, imageId string) ([]container.Container, []container.Container, error) {
+	containers, err := p.listContainersByApp(appName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var canary, stable []container.Container
+	for _, c := range containers {
+		if c.Image == imageId {
+			canary = append(canary, c)
+		} else {
+			stable = append(stable, c)
+		}
+	}
+	return canary, stable, nil
+}
+
+func (p *dockerProvisioner) CanaryUnits(a provision.App, imageId string) ([]provision.Unit, error) {
+	canary, _, err := p.canaryContainers(a.GetName(), imageId)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]provision.Unit, len(canary))
+	for i, c := range canary {
+		units[i] = c.AsUnit(a)
+	}
+	return units, nil
+}
+
+func (p *dockerProvisioner) PromoteCanary(a provision.App, imageId string, w io.Writer) error {
+	canary, stable, err := p.canaryContainers(a.GetName(), imageId)
+	if err != nil {
+		return err
+	}
+	imageData, err := getImageCustomData(imageId)
+	if err != nil {
+		return err
+	}
+	toAdd := make(map[string]*containersToAdd, len(imageData.Processes))
+	for name := range imageData.Processes {
+		toAdd[name] = &containersToAdd{}
+	}
+	for _, c := range stable {
+		if ct, ok := toAdd[c.ProcessName]; ok {
+			ct.Quantity++
+		}
+	}
+	for _, c := range canary {
+		if ct, ok := toAdd[c.ProcessName]; ok && ct.Quantity > 0 {
+			ct.Quantity--
+		}
+	}
+	total := len(canary)
+	for _, ct := range toAdd {
+		total += ct.Quantity
+	}
+	err = a.SetQuotaInUse(total)
+	if err != nil {
+		return err
+	}
+	_, err = p.runReplaceUnitsPipeline(w, a, toAdd, stable, imageId)
+	routesRebuildOrEnqueue(a.GetName())
+	return err
+}
+
+func (p *dockerProvisioner) AbortCanary(a provision.App, imageId string, w io.Writer) error {
+	canary, stable, err := p.canaryContainers(a.GetName(), imageId)
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	fmt.Fprintf(w, "\n---- Removing %d canary %s ----\n", len(canary), pluralize("unit", len(canary)))
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toRemove:    canary,
+		writer:      w,
+		provisioner: p,
+	}
+	err = action.NewPipeline(
+		&removeOldRoutes,
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	).Execute(args)
+	if err != nil {
+		return err
+	}
+	a.SetQuotaInUse(len(stable))
+	p.cleanImage(a.GetName(), imageId)
+	routesRebuildOrEnqueue(a.GetName())
+	return nil
+}
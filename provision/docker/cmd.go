@@ -7,9 +7,11 @@ package docker
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -808,3 +810,261 @@ func (c *dockerLogInfo) Run(context *cmd.Context, client *cmd.Client) error {
 	}
 	return nil
 }
+
+type unitAutoScaleHistoryCmd struct {
+	fs      *gnuflag.FlagSet
+	appName string
+	page    int
+}
+
+func (c *unitAutoScaleHistoryCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-unit-autoscale-list",
+		Usage: "docker-unit-autoscale-list [--app/-a appname] [--page/-p 1]",
+		Desc:  "List unit auto scale history, optionally filtered by app.",
+	}
+}
+
+func (c *unitAutoScaleHistoryCmd) Run(ctx *cmd.Context, client *cmd.Client) error {
+	if c.page < 1 {
+		c.page = 1
+	}
+	limit := 20
+	skip := (c.page - 1) * limit
+	qs := url.Values{}
+	qs.Set("skip", strconv.Itoa(skip))
+	qs.Set("limit", strconv.Itoa(limit))
+	if c.appName != "" {
+		qs.Set("app", c.appName)
+	}
+	u, err := cmd.GetURL("/docker/autoscale/units?" + qs.Encode())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var history []unitAutoScaleEvent
+	err = json.NewDecoder(resp.Body).Decode(&history)
+	if err != nil {
+		return err
+	}
+	headers := cmd.Row([]string{"Start", "Finish", "Success", "App", "Process", "Action", "Units", "Reason", "Error"})
+	t := cmd.Table{Headers: headers}
+	for i := range history {
+		event := &history[i]
+		t.AddRow(cmd.Row([]string{
+			event.StartTime.Local().Format(time.Stamp),
+			event.EndTime.Local().Format(time.Stamp),
+			fmt.Sprintf("%t", event.Successful),
+			event.AppName,
+			event.Process,
+			event.Action,
+			fmt.Sprintf("%d -> %d", event.UnitsBefore, event.UnitsAfter),
+			event.Reason,
+			event.Error,
+		}))
+	}
+	t.LineSeparator = true
+	ctx.Stdout.Write(t.Bytes())
+	return nil
+}
+
+func (c *unitAutoScaleHistoryCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("with-flags", gnuflag.ContinueOnError)
+		c.fs.StringVar(&c.appName, "app", "", "Filter history by app name")
+		c.fs.StringVar(&c.appName, "a", "", "Filter history by app name")
+		c.fs.IntVar(&c.page, "page", 1, "Current page")
+		c.fs.IntVar(&c.page, "p", 1, "Current page")
+	}
+	return c.fs
+}
+
+type unitAutoScaleListRulesCmd struct {
+	fs      *gnuflag.FlagSet
+	appName string
+}
+
+func (c *unitAutoScaleListRulesCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-unit-autoscale-rule-list",
+		Usage: "docker-unit-autoscale-rule-list [--app/-a appname]",
+		Desc:  "List unit auto scale rules, optionally filtered by app.",
+	}
+}
+
+func (c *unitAutoScaleListRulesCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	path := "/docker/autoscale/units/rules"
+	if c.appName != "" {
+		path += "?app=" + url.QueryEscape(c.appName)
+	}
+	u, err := cmd.GetURL(path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var rules []unitAutoScaleRule
+	err = json.NewDecoder(resp.Body).Decode(&rules)
+	if err != nil {
+		return err
+	}
+	table := cmd.Table{Headers: cmd.Row([]string{"App", "Process", "Min units", "Max units", "CPU target", "Memory target", "Enabled"})}
+	for _, rule := range rules {
+		table.AddRow(cmd.Row([]string{
+			rule.AppName,
+			rule.Process,
+			strconv.FormatUint(uint64(rule.MinUnits), 10),
+			strconv.FormatUint(uint64(rule.MaxUnits), 10),
+			strconv.FormatFloat(rule.CPUTarget, 'f', 2, 64),
+			strconv.FormatFloat(rule.MemoryTarget, 'f', 2, 64),
+			strconv.FormatBool(rule.Enabled),
+		}))
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+func (c *unitAutoScaleListRulesCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("with-flags", gnuflag.ContinueOnError)
+		c.fs.StringVar(&c.appName, "app", "", "Filter rules by app name")
+		c.fs.StringVar(&c.appName, "a", "", "Filter rules by app name")
+	}
+	return c.fs
+}
+
+type unitAutoScaleSetRuleCmd struct {
+	fs           *gnuflag.FlagSet
+	appName      string
+	process      string
+	minUnits     uint
+	maxUnits     uint
+	cpuTarget    float64
+	memoryTarget float64
+	enabled      bool
+}
+
+func (c *unitAutoScaleSetRuleCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-unit-autoscale-rule-set",
+		Usage: "docker-unit-autoscale-rule-set --app/-a appname [--process/-p process] [--min 1] --max 10 [--cpu 70] [--memory 80] [-e/--enabled true]",
+		Desc: `Creates or updates the unit auto scale rule of a process of an app. tsuru
+will add or remove units of the process, keeping between the minimum and
+maximum amount of units, trying to keep the average CPU and memory usage close
+to the given targets. Both targets are percentages, the CPU target is relative
+to one CPU core and the memory target is relative to the memory limit of each
+unit.`,
+	}
+}
+
+func (c *unitAutoScaleSetRuleCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	rule := unitAutoScaleRule{
+		AppName:      c.appName,
+		Process:      c.process,
+		Enabled:      c.enabled,
+		MinUnits:     c.minUnits,
+		MaxUnits:     c.maxUnits,
+		CPUTarget:    c.cpuTarget,
+		MemoryTarget: c.memoryTarget,
+	}
+	v, err := form.EncodeToValues(&rule)
+	if err != nil {
+		return err
+	}
+	u, err := cmd.GetURL("/docker/autoscale/units/rules")
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Rule successfully defined.")
+	return nil
+}
+
+func (c *unitAutoScaleSetRuleCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("unit-autoscale-rule-set", gnuflag.ExitOnError)
+		c.fs.StringVar(&c.appName, "app", "", "The name of the app.")
+		c.fs.StringVar(&c.appName, "a", "", "The name of the app.")
+		c.fs.StringVar(&c.process, "process", "", "The process of the app that will be scaled.")
+		c.fs.StringVar(&c.process, "p", "", "The process of the app that will be scaled.")
+		c.fs.UintVar(&c.minUnits, "min", 1, "The minimum amount of units.")
+		c.fs.UintVar(&c.maxUnits, "max", 0, "The maximum amount of units.")
+		c.fs.Float64Var(&c.cpuTarget, "cpu", 0, "The target CPU usage of the units, in percent of one CPU core. Zero means CPU usage is not considered.")
+		c.fs.Float64Var(&c.memoryTarget, "memory", 0, "The target memory usage of the units, in percent of the memory limit. Zero means memory usage is not considered.")
+		c.fs.BoolVar(&c.enabled, "enabled", true, "A boolean flag indicating whether the rule should be enabled or disabled")
+		c.fs.BoolVar(&c.enabled, "e", true, "A boolean flag indicating whether the rule should be enabled or disabled")
+	}
+	return c.fs
+}
+
+type unitAutoScaleDeleteRuleCmd struct {
+	cmd.ConfirmationCommand
+	fs      *gnuflag.FlagSet
+	appName string
+	process string
+}
+
+func (c *unitAutoScaleDeleteRuleCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-unit-autoscale-rule-remove",
+		Usage: "docker-unit-autoscale-rule-remove --app/-a appname [--process/-p process] [-y/--assume-yes]",
+		Desc:  "Removes the unit auto scale rule of a process of an app.",
+	}
+}
+
+func (c *unitAutoScaleDeleteRuleCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	if c.appName == "" {
+		return errors.New("the name of the app is required")
+	}
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to remove the rule of app %q, process %q?", c.appName, c.process)) {
+		return nil
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/docker/autoscale/units/rules/%s?process=%s", c.appName, url.QueryEscape(c.process)))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Rule successfully removed.")
+	return nil
+}
+
+func (c *unitAutoScaleDeleteRuleCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.ConfirmationCommand.Flags()
+		c.fs.StringVar(&c.appName, "app", "", "The name of the app.")
+		c.fs.StringVar(&c.appName, "a", "", "The name of the app.")
+		c.fs.StringVar(&c.process, "process", "", "The process of the app.")
+		c.fs.StringVar(&c.process, "p", "", "The process of the app.")
+	}
+	return c.fs
+}
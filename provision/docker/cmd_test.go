@@ -741,3 +741,124 @@ Log driver [pool p1]: x
 Log driver [pool p2]: bs
 `)
 }
+
+func (s *S) TestUnitAutoScaleHistoryCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	msg := `[{
+	"StartTime": "2014-10-23T08:00:00.000Z",
+	"EndTime": "2014-10-23T08:30:00.000Z",
+	"Successful": true,
+	"AppName": "myapp",
+	"Process": "web",
+	"Action": "add",
+	"Reason": "r1",
+	"UnitsBefore": 1,
+	"UnitsAfter": 2
+}]`
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: msg, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/docker/autoscale/units" && req.URL.Query().Get("app") == "myapp" &&
+				req.URL.Query().Get("skip") == "20" && req.URL.Query().Get("limit") == "20"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	command := &unitAutoScaleHistoryCmd{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-p", "2"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*\| Start +\| Finish +\| Success \| App +\| Process \| Action \| Units +\| Reason \| Error \|.*`)
+	c.Assert(buf.String(), check.Matches, `(?s).*\| true +\| myapp \| web +\| add +\| 1 -> 2 \| r1 +\| +\|.*`)
+}
+
+func (s *S) TestUnitAutoScaleListRulesCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	msg := `[{"AppName": "myapp", "Process": "web", "Enabled": true, "MinUnits": 1, "MaxUnits": 5, "CPUTarget": 70},
+{"AppName": "otherapp", "MinUnits": 2, "MaxUnits": 3, "MemoryTarget": 80}]`
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: msg, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/docker/autoscale/units/rules"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	command := &unitAutoScaleListRulesCmd{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+----------+---------+-----------+-----------+------------+---------------+---------+
+| App      | Process | Min units | Max units | CPU target | Memory target | Enabled |
++----------+---------+-----------+-----------+------------+---------------+---------+
+| myapp    | web     | 1         | 5         | 70.00      | 0.00          | true    |
+| otherapp |         | 2         | 3         | 0.00       | 80.00         | false   |
++----------+---------+-----------+-----------+------------+---------------+---------+
+`
+	c.Assert(buf.String(), check.Equals, expected)
+}
+
+func (s *S) TestUnitAutoScaleSetRuleCmdRun(c *check.C) {
+	var called bool
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			called = true
+			err := req.ParseForm()
+			c.Assert(err, check.IsNil)
+			c.Assert(req.Form.Get("AppName"), check.Equals, "myapp")
+			c.Assert(req.Form.Get("Process"), check.Equals, "web")
+			c.Assert(req.Form.Get("MinUnits"), check.Equals, "2")
+			c.Assert(req.Form.Get("MaxUnits"), check.Equals, "8")
+			c.Assert(req.Form.Get("CPUTarget"), check.Equals, "70")
+			c.Assert(req.Form.Get("Enabled"), check.Equals, "true")
+			return req.Method == "POST" && req.URL.Path == "/1.0/docker/autoscale/units/rules"
+		},
+	}
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	var manager cmd.Manager
+	client := cmd.NewClient(&http.Client{Transport: &transport}, nil, &manager)
+	var command unitAutoScaleSetRuleCmd
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-p", "web", "--min", "2", "--max", "8", "--cpu", "70"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(called, check.Equals, true)
+	c.Assert(buf.String(), check.Equals, "Rule successfully defined.\n")
+}
+
+func (s *S) TestUnitAutoScaleDeleteRuleCmdRun(c *check.C) {
+	var called bool
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			called = true
+			return req.Method == "DELETE" && req.URL.Path == "/1.0/docker/autoscale/units/rules/myapp" &&
+				req.URL.Query().Get("process") == "web"
+		},
+	}
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	var manager cmd.Manager
+	client := cmd.NewClient(&http.Client{Transport: &transport}, nil, &manager)
+	var command unitAutoScaleDeleteRuleCmd
+	err := command.Flags().Parse(true, []string{"-y", "-a", "myapp", "-p", "web"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(called, check.Equals, true)
+	c.Assert(buf.String(), check.Equals, "Rule successfully removed.\n")
+}
+
+func (s *S) TestUnitAutoScaleDeleteRuleCmdRunWithoutApp(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	var command unitAutoScaleDeleteRuleCmd
+	err := command.Flags().Parse(true, []string{"-y"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the name of the app is required")
+}
@@ -25,7 +25,11 @@ func deployCmds(app provision.App, params ...string) ([]string, error) {
 	}
 	cmds := append([]string{deployCmd}, params...)
 	host, _ := config.GetString("host")
-	token := app.Envs()["TSURU_APP_TOKEN"].Value
+	envs, err := provision.AppEnvs(app)
+	if err != nil {
+		return nil, err
+	}
+	token := envs["TSURU_APP_TOKEN"].Value
 	unitAgentCmds := []string{"tsuru_unit_agent", host, token, app.GetName(), `"` + strings.Join(cmds, " ") + `"`, "deploy"}
 	finalCmd := strings.Join(unitAgentCmds, " ")
 	return []string{"/bin/bash", "-lc", finalCmd}, nil
@@ -43,7 +47,11 @@ func runWithAgentCmds(app provision.App) ([]string, error) {
 		return nil, err
 	}
 	host, _ := config.GetString("host")
-	token := app.Envs()["TSURU_APP_TOKEN"].Value
+	envs, err := provision.AppEnvs(app)
+	if err != nil {
+		return nil, err
+	}
+	token := envs["TSURU_APP_TOKEN"].Value
 	return []string{"tsuru_unit_agent", host, token, app.GetName(), runCmd}, nil
 }
 
@@ -22,6 +22,7 @@ import (
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/secret"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -153,7 +154,10 @@ func (c *Container) Create(args *CreateArgs) error {
 		SecurityOpts: securityOpts,
 		User:         user,
 	}
-	c.addEnvsToConfig(args, strings.TrimSuffix(c.ExposedPort, "/tcp"), &conf)
+	err = c.addEnvsToConfig(args, strings.TrimSuffix(c.ExposedPort, "/tcp"), &conf)
+	if err != nil {
+		return err
+	}
 	opts := docker.CreateContainerOptions{Name: c.Name, Config: &conf}
 	var nodeList []string
 	if len(args.DestinationHosts) > 0 {
@@ -196,10 +200,18 @@ func (c *Container) hostToNodeAddress(p DockerProvisioner, host string) (string,
 	return "", fmt.Errorf("Host `%s` not found", host)
 }
 
-func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.Config) {
+func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.Config) error {
 	if !args.Deploy {
-		for _, envData := range args.App.Envs() {
-			cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", envData.Name, envData.Value))
+		envs, err := provision.AppEnvs(args.App)
+		if err != nil {
+			return err
+		}
+		for _, envData := range envs {
+			value, err := secret.Resolve(envData.Value, args.App.GetTeamOwner())
+			if err != nil {
+				return fmt.Errorf("unable to resolve env %q: %s", envData.Name, err)
+			}
+			cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", envData.Name, value))
 		}
 		cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", "TSURU_PROCESSNAME", c.ProcessName))
 	}
@@ -217,6 +229,7 @@ func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.C
 		}
 		cfg.Env = append(cfg.Env, fmt.Sprintf("TSURU_SHAREDFS_MOUNTPOINT=%s", sharedMount))
 	}
+	return nil
 }
 
 func (c *Container) user() string {
@@ -128,6 +128,66 @@ func (s *S) TestContainerCreate(c *check.C) {
 	})
 }
 
+func (s *S) TestContainerCreateResolvesSecretEnvs(c *check.C) {
+	s.server.CustomHandler("/images/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := docker.Image{
+			Config: &docker.Config{
+				ExposedPorts: map[docker.Port]struct{}{},
+			},
+		}
+		j, _ := json.Marshal(response)
+		w.Write(j)
+	}))
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/myteam/db" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"password": "s3cr3t"}`))
+	}))
+	defer store.Close()
+	config.Set("secrets:provider", "http")
+	config.Set("secrets:http:url", store.URL)
+	defer config.Unset("secrets")
+	app := provisiontest.NewFakeApp("app-name", "brainfuck", 1)
+	app.TeamOwner = "myteam"
+	app.SetEnv(bind.EnvVar{Name: "A", Value: "myenva"})
+	app.SetEnv(bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "secret://myteam/db#password"})
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	img := "tsuru/brainfuck:latest"
+	s.p.Cluster().PullImage(docker.PullImageOptions{Repository: img}, docker.AuthConfiguration{})
+	cont := Container{
+		Name:        "myName",
+		AppName:     app.GetName(),
+		Type:        app.GetPlatform(),
+		Status:      "created",
+		ProcessName: "myprocess1",
+	}
+	err := cont.Create(&CreateArgs{
+		App:         app,
+		ImageID:     img,
+		Commands:    []string{"docker", "run"},
+		Provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(&cont)
+	dcli, _ := docker.NewClient(s.server.URL())
+	container, err := dcli.InspectContainer(cont.ID)
+	c.Assert(err, check.IsNil)
+	sort.Strings(container.Config.Env)
+	c.Assert(container.Config.Env[:2], check.DeepEquals, []string{"A=myenva", "DATABASE_PASSWORD=s3cr3t"})
+	app.SetEnv(bind.EnvVar{Name: "DATABASE_USER", Value: "secret://myteam/db#user"})
+	cont2 := Container{Name: "myName2", AppName: app.GetName(), ProcessName: "myprocess1"}
+	err = cont2.Create(&CreateArgs{
+		App:         app,
+		ImageID:     img,
+		Commands:    []string{"docker", "run"},
+		Provisioner: s.p,
+	})
+	c.Assert(err, check.ErrorMatches, `unable to resolve env "DATABASE_USER": unable to resolve secret://myteam/db#user: secret not found`)
+}
+
 func (s *S) TestContainerCreateAllocatesPortExposedInImage(c *check.C) {
 	s.server.CustomHandler("/images/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := docker.Image{
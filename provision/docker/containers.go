@@ -334,7 +334,7 @@ func (p *dockerProvisioner) runCommandInContainer(image string, command string,
 			AttachStdout: true,
 			AttachStderr: true,
 			Image:        image,
-			Entrypoint:   []string{"/bin/bash", "-c"},
+			Entrypoint:   []string{"/bin/sh", "-c"},
 			Cmd:          []string{command},
 		},
 	}
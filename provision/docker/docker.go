@@ -66,6 +66,7 @@ func randomString() string {
 }
 
 func (p *dockerProvisioner) archiveDeploy(app provision.App, image, archiveURL string, w io.Writer) (string, error) {
+	defer provision.ObserveOperation(provisionerName, "build", time.Now())
 	commands, err := archiveDeployCmds(app, archiveURL)
 	if err != nil {
 		return "", err
@@ -0,0 +1,191 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/yaml.v1"
+)
+
+const (
+	procfileCmd  = "cat /home/application/current/Procfile || cat /app/user/Procfile || cat /Procfile"
+	tsuruYamlCmd = "cat /home/application/current/tsuru.yaml || cat /home/application/current/tsuru.yml || " +
+		"cat /app/user/tsuru.yaml || cat /app/user/tsuru.yml || cat /tsuru.yaml || cat /tsuru.yml || true"
+)
+
+// DockerfileDeploy builds the uploaded archive with the Dockerfile in its
+// root, in a node of the pool of the app chosen by the scheduler, and
+// deploys the resulting image. The processes and the tsuru.yaml data are
+// read from the image, so they work just like in images built from
+// platforms.
+func (p *dockerProvisioner) DockerfileDeploy(app provision.App, archiveFile io.ReadCloser, w io.Writer) (string, error) {
+	defer archiveFile.Close()
+	newImage, err := p.buildDockerfileImage(app, archiveFile, w)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(w, "---- Getting processes from image ----")
+	customData, err := p.dockerfileImageCustomData(app, newImage)
+	if err != nil {
+		return "", err
+	}
+	for name, cmd := range customData["processes"].(map[string]interface{}) {
+		fmt.Fprintf(w, "  ---> Process %s found with command: %v\n", name, cmd)
+	}
+	err = saveImageCustomData(newImage, customData)
+	if err != nil {
+		return "", err
+	}
+	imageInfo := strings.Split(newImage, ":")
+	err = p.PushImage(strings.Join(imageInfo[:len(imageInfo)-1], ":"), imageInfo[len(imageInfo)-1])
+	if err != nil {
+		return "", err
+	}
+	return newImage, p.deploy(app, newImage, w)
+}
+
+// buildDockerfileImage builds a new image of the app from the archive, which
+// is used as the build context.
+func (p *dockerProvisioner) buildDockerfileImage(app provision.App, archive io.Reader, w io.Writer) (string, error) {
+	defer provision.ObserveOperation(provisionerName, "build", time.Now())
+	cluster := p.Cluster()
+	nodes, err := cluster.NodesForMetadata(map[string]string{"pool": app.GetPool()})
+	if err != nil {
+		return "", err
+	}
+	addr, _, err := p.scheduler.minMaxNodes(nodes, app.GetName(), "")
+	if err != nil {
+		return "", err
+	}
+	node, err := cluster.GetNode(addr)
+	if err != nil {
+		return "", err
+	}
+	client, err := node.Client()
+	if err != nil {
+		return "", err
+	}
+	newImage, err := appNewImageName(app.GetName())
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(w, "---- Building image from Dockerfile in %s ----\n", net.URLToHost(addr))
+	buildOptions := docker.BuildImageOptions{
+		Name:              newImage,
+		Pull:              true,
+		RmTmpContainer:    true,
+		InputStream:       archive,
+		OutputStream:      w,
+		InactivityTimeout: net.StreamInactivityTimeout,
+	}
+	err = client.BuildImage(buildOptions)
+	if err != nil {
+		return "", err
+	}
+	img, err := client.InspectImage(newImage)
+	if err != nil {
+		return "", err
+	}
+	err = p.storage.StoreImage(newImage, img.ID, addr)
+	if err != nil {
+		return "", err
+	}
+	return newImage, nil
+}
+
+// dockerfileImageCustomData returns the custom data of an image built from a
+// Dockerfile, with the processes declared in its Procfile, or its entrypoint
+// and command, and the data in its tsuru.yaml.
+func (p *dockerProvisioner) dockerfileImageCustomData(app provision.App, imageId string) (map[string]interface{}, error) {
+	output, err := p.runCommandInContainer(imageId, procfileCmd, app)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Procfile of the image: %s", err)
+	}
+	procfile := getProcessesFromProcfile(output.String())
+	if len(procfile) == 0 {
+		img, err := p.Cluster().InspectImage(imageId)
+		if err != nil {
+			return nil, err
+		}
+		cmd := imageCommand(img)
+		if cmd == "" {
+			return nil, ErrEntrypointOrProcfileNotFound
+		}
+		procfile["web"] = cmd
+	}
+	output, err = p.runCommandInContainer(imageId, tsuruYamlCmd, app)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the tsuru.yaml of the image: %s", err)
+	}
+	customData, err := parseTsuruYaml(output.String())
+	if err != nil {
+		return nil, err
+	}
+	processes := make(map[string]interface{}, len(procfile))
+	for name, cmd := range procfile {
+		processes[name] = cmd
+	}
+	customData["processes"] = processes
+	return customData, nil
+}
+
+// imageCommand returns the command run by the image, composed by its
+// entrypoint and cmd.
+func imageCommand(img *docker.Image) string {
+	if img.Config == nil {
+		return ""
+	}
+	args := append(append([]string{}, img.Config.Entrypoint...), img.Config.Cmd...)
+	if len(args) == 0 {
+		return ""
+	}
+	cmd := args[0]
+	for _, arg := range args[1:] {
+		cmd += fmt.Sprintf(" %q", arg)
+	}
+	return cmd
+}
+
+// parseTsuruYaml parses the content of a tsuru.yaml file in the format of
+// the custom data sent by the builds of images.
+func parseTsuruYaml(data string) (map[string]interface{}, error) {
+	var parsed map[interface{}]interface{}
+	err := yaml.Unmarshal([]byte(data), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tsuru.yaml: %s", err)
+	}
+	return stringKeys(parsed), nil
+}
+
+// stringKeys converts the maps decoded from yaml, which may have keys of any
+// type, to maps that can be stored in the database.
+func stringKeys(m map[interface{}]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		result[fmt.Sprint(key)] = stringKeysValue(value)
+	}
+	return result
+}
+
+func stringKeysValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		return stringKeys(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			list[i] = stringKeysValue(v[i])
+		}
+		return list
+	}
+	return value
+}
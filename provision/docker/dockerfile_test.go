@@ -0,0 +1,168 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/fsouza/go-dockerclient/external/github.com/docker/docker/pkg/stdcopy"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/check.v1"
+)
+
+func dockerfileArchive(c *check.C) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	dockerfile := []byte("FROM busybox\nRUN apk add --no-cache imagemagick\n")
+	err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0644, Size: int64(len(dockerfile))})
+	c.Assert(err, check.IsNil)
+	_, err = tw.Write(dockerfile)
+	c.Assert(err, check.IsNil)
+	err = tw.Close()
+	c.Assert(err, check.IsNil)
+	return &buf
+}
+
+func (s *S) TestDockerfileDeploy(c *check.C) {
+	p, err := s.startMultipleServersClusterSeggregated()
+	c.Assert(err, check.IsNil)
+	app.Provisioner = p
+	outputs := []string{
+		"web: python web.py\nworker: python worker.py\n",
+		"healthcheck:\n  path: /status\nhooks:\n  build:\n    - ./build.sh\n",
+	}
+	var calls int32
+	s.server.CustomHandler("/containers/.*/attach", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "cannot hijack connection", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+		w.WriteHeader(http.StatusOK)
+		conn, _, cErr := hijacker.Hijack()
+		if cErr != nil {
+			http.Error(w, cErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		outStream := stdcopy.NewStdWriter(conn, stdcopy.Stdout)
+		call := int(atomic.AddInt32(&calls, 1)) - 1
+		if call < len(outputs) {
+			fmt.Fprint(outStream, outputs[call])
+		}
+		conn.Close()
+	}))
+	a := app.App{
+		Name:     "otherapp",
+		Platform: "python",
+		Quota:    quota.Unlimited,
+		Pool:     "pool1",
+	}
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	p.Provision(&a)
+	defer p.Destroy(&a)
+	w := safe.NewBuffer(make([]byte, 2048))
+	newImage, err := p.DockerfileDeploy(&a, ioutil.NopCloser(dockerfileArchive(c)), w)
+	c.Assert(err, check.IsNil)
+	c.Assert(w.String(), check.Matches, `(?s).*---- Building image from Dockerfile in .* ----.*`)
+	currentImage, err := appCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(newImage, check.Equals, currentImage)
+	imd, err := getImageCustomData(newImage)
+	c.Assert(err, check.IsNil)
+	c.Assert(imd.Processes, check.DeepEquals, map[string]string{
+		"web":    "python web.py",
+		"worker": "python worker.py",
+	})
+	yamlData, err := getImageTsuruYamlData(newImage)
+	c.Assert(err, check.IsNil)
+	c.Assert(yamlData.Healthcheck.Path, check.Equals, "/status")
+	c.Assert(yamlData.Hooks.Build, check.DeepEquals, []string{"./build.sh"})
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+}
+
+func (s *S) TestDockerfileDeployWithoutProcfileOrEntrypoint(c *check.C) {
+	p, err := s.startMultipleServersClusterSeggregated()
+	c.Assert(err, check.IsNil)
+	app.Provisioner = p
+	a := app.App{
+		Name:     "otherapp",
+		Platform: "python",
+		Quota:    quota.Unlimited,
+		Pool:     "pool1",
+	}
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	p.Provision(&a)
+	defer p.Destroy(&a)
+	w := safe.NewBuffer(make([]byte, 2048))
+	_, err = p.DockerfileDeploy(&a, ioutil.NopCloser(dockerfileArchive(c)), w)
+	c.Assert(err, check.Equals, ErrEntrypointOrProcfileNotFound)
+}
+
+func (s *S) TestDockerfileDeployCommandFailure(c *check.C) {
+	p, err := s.startMultipleServersClusterSeggregated()
+	c.Assert(err, check.IsNil)
+	app.Provisioner = p
+	a := app.App{
+		Name:     "otherapp",
+		Platform: "python",
+		Quota:    quota.Unlimited,
+		Pool:     "pool1",
+	}
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	p.Provision(&a)
+	defer p.Destroy(&a)
+	s.server.PrepareFailure("no space left on device", "/containers/create")
+	defer s.server.ResetFailure("no space left on device")
+	w := safe.NewBuffer(make([]byte, 2048))
+	_, err = p.DockerfileDeploy(&a, ioutil.NopCloser(dockerfileArchive(c)), w)
+	c.Assert(err, check.ErrorMatches, `(?s)unable to read the Procfile of the image: .*no space left on device.*`)
+}
+
+func (s *S) TestImageCommand(c *check.C) {
+	var tests = []struct {
+		config   *docker.Config
+		expected string
+	}{
+		{nil, ""},
+		{&docker.Config{}, ""},
+		{&docker.Config{Cmd: []string{"./start"}}, "./start"},
+		{&docker.Config{Entrypoint: []string{"/bin/sh", "-c"}, Cmd: []string{"python app.py"}}, `/bin/sh "-c" "python app.py"`},
+	}
+	for _, t := range tests {
+		c.Check(imageCommand(&docker.Image{Config: t.config}), check.Equals, t.expected)
+	}
+}
+
+func (s *S) TestParseTsuruYaml(c *check.C) {
+	data, err := parseTsuruYaml("healthcheck:\n  path: /\n  status: 200\nhooks:\n  restart:\n    before:\n      - ./before.sh\n")
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, map[string]interface{}{
+		"healthcheck": map[string]interface{}{"path": "/", "status": 200},
+		"hooks": map[string]interface{}{
+			"restart": map[string]interface{}{
+				"before": []interface{}{"./before.sh"},
+			},
+		},
+	})
+	data, err = parseTsuruYaml("")
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, map[string]interface{}{})
+	_, err = parseTsuruYaml("healthcheck: [")
+	c.Assert(err, check.ErrorMatches, "invalid tsuru.yaml: .*")
+}
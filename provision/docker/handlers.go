@@ -23,6 +23,7 @@ import (
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/iaas"
 	_ "github.com/tsuru/tsuru/iaas/cloudstack"
 	_ "github.com/tsuru/tsuru/iaas/digitalocean"
@@ -35,6 +36,7 @@ import (
 	"github.com/tsuru/tsuru/provision/docker/nodecontainer"
 	"github.com/tsuru/tsuru/queue"
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func init() {
@@ -58,6 +60,10 @@ func init() {
 	api.RegisterHandler("/docker/autoscale/rules", "POST", api.AuthorizationRequiredHandler(autoScaleSetRule))
 	api.RegisterHandler("/docker/autoscale/rules", "DELETE", api.AuthorizationRequiredHandler(autoScaleDeleteRule))
 	api.RegisterHandler("/docker/autoscale/rules/{id}", "DELETE", api.AuthorizationRequiredHandler(autoScaleDeleteRule))
+	api.RegisterHandler("/docker/autoscale/units", "GET", api.AuthorizationRequiredHandler(unitAutoScaleHistoryHandler))
+	api.RegisterHandler("/docker/autoscale/units/rules", "GET", api.AuthorizationRequiredHandler(unitAutoScaleListRules))
+	api.RegisterHandler("/docker/autoscale/units/rules", "POST", api.AuthorizationRequiredHandler(unitAutoScaleSetRule))
+	api.RegisterHandler("/docker/autoscale/units/rules/{app}", "DELETE", api.AuthorizationRequiredHandler(unitAutoScaleDeleteRule))
 	api.RegisterHandler("/docker/bs/upgrade", "POST", api.AuthorizationRequiredHandler(bsUpgradeHandler))
 	api.RegisterHandler("/docker/bs/env", "POST", api.AuthorizationRequiredHandler(bsEnvSetHandler))
 	api.RegisterHandler("/docker/bs", "GET", api.AuthorizationRequiredHandler(bsConfigGetHandler))
@@ -249,6 +255,20 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		}
 	}
 	delete(params, "register")
+	target := event.Target{Type: event.TargetTypeNode, Value: params["address"]}
+	if target.Value == "" {
+		target = event.Target{Type: event.TargetTypePool, Value: pool}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     target,
+		Kind:       permission.PermNodeCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
 	defer keepAliveWriter.Stop()
@@ -297,6 +317,16 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) err
 			return permission.ErrUnauthorized
 		}
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNode, Value: address},
+		Kind:       permission.PermNodeDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	node.CreationStatus = cluster.NodeCreationStatusDisabled
 	_, err = mainDockerProvisioner.Cluster().UpdateNode(node)
 	if err != nil {
@@ -304,7 +334,8 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) err
 	}
 	noRebalance, err := strconv.ParseBool(r.URL.Query().Get("no-rebalance"))
 	if !noRebalance {
-		err = mainDockerProvisioner.rebalanceContainersByHost(net.URLToHost(address), w)
+		evt.SetLogWriter(w)
+		err = mainDockerProvisioner.rebalanceContainersByHost(net.URLToHost(address), evt)
 		if err != nil {
 			return err
 		}
@@ -319,7 +350,8 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) err
 		if err != nil && err != mgo.ErrNotFound {
 			return nil
 		}
-		return m.Destroy()
+		err = m.Destroy()
+		return err
 	}
 	return nil
 }
@@ -448,6 +480,16 @@ func updateNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) err
 	if enable {
 		node.CreationStatus = cluster.NodeCreationStatusCreated
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNode, Value: address},
+		Kind:       permission.PermNodeUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	_, err = mainDockerProvisioner.Cluster().UpdateNode(node)
 	return err
 }
@@ -490,15 +532,26 @@ func moveContainerHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if !permission.Check(t, permission.PermNode, permContexts...) {
 		return permission.ErrUnauthorized
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeContainer, Value: contId},
+		Kind:       permission.PermNode,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	_, err = mainDockerProvisioner.moveContainer(contId, to, writer)
+	evt.SetLogWriter(writer)
+	_, err = mainDockerProvisioner.moveContainer(contId, to, evt)
 	if err != nil {
-		fmt.Fprintf(writer, "Error trying to move container: %s\n", err.Error())
+		fmt.Fprintf(evt, "Error trying to move container: %s\n", err.Error())
 	} else {
-		fmt.Fprintf(writer, "Containers moved successfully!\n")
+		fmt.Fprintf(evt, "Containers moved successfully!\n")
 	}
 	return nil
 }
@@ -537,15 +590,26 @@ func moveContainersHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if !permission.Check(t, permission.PermNode, permContexts...) {
 		return permission.ErrUnauthorized
 	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNode, Value: from},
+		Kind:       permission.PermNode,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = mainDockerProvisioner.MoveContainers(from, to, writer)
+	evt.SetLogWriter(writer)
+	err = mainDockerProvisioner.MoveContainers(from, to, evt)
 	if err != nil {
-		fmt.Fprintf(writer, "Error trying to move containers: %s\n", err.Error())
+		fmt.Fprintf(evt, "Error trying to move containers: %s\n", err.Error())
 	} else {
-		fmt.Fprintf(writer, "Containers moved successfully!\n")
+		fmt.Fprintf(evt, "Containers moved successfully!\n")
 	}
 	return nil
 }
@@ -1093,3 +1157,165 @@ func nodeContainerUpgrade(w http.ResponseWriter, r *http.Request, t auth.Token)
 	}
 	return nil
 }
+
+// unitAutoScaleAppFilter returns the query filtering unit auto scale data by
+// the app in the request. Without an app, only users allowed to manage node
+// auto scale can see data from all apps.
+func unitAutoScaleAppFilter(r *http.Request, t auth.Token) (string, error) {
+	appName := r.URL.Query().Get("app")
+	if appName == "" {
+		if !permission.Check(t, permission.PermNodeAutoscale) {
+			return "", permission.ErrUnauthorized
+		}
+		return "", nil
+	}
+	a, err := getUnitAutoScaleApp(appName)
+	if err != nil {
+		return "", err
+	}
+	if !permission.Check(t, permission.PermAppRead, appPermissionContexts(a)...) {
+		return "", permission.ErrUnauthorized
+	}
+	return appName, nil
+}
+
+func getUnitAutoScaleApp(appName string) (*app.App, error) {
+	a, err := app.GetByName(appName)
+	if err == app.ErrAppNotFound {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return a, err
+}
+
+func appPermissionContexts(a *app.App) []permission.PermissionContext {
+	return append(permission.Contexts(permission.CtxTeam, a.Teams),
+		permission.Context(permission.CtxApp, a.Name),
+		permission.Context(permission.CtxPool, a.Pool),
+	)
+}
+
+// title: unit autoscale history
+// path: /docker/autoscale/units
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: App not found
+func unitAutoScaleHistoryHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName, err := unitAutoScaleAppFilter(r, t)
+	if err != nil {
+		return err
+	}
+	skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	history, err := listUnitAutoScaleEvents(appName, skip, limit)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(&history)
+}
+
+// title: unit autoscale rules list
+// path: /docker/autoscale/units/rules
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: App not found
+func unitAutoScaleListRules(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName, err := unitAutoScaleAppFilter(r, t)
+	if err != nil {
+		return err
+	}
+	var query bson.M
+	if appName != "" {
+		query = bson.M{"appname": appName}
+	}
+	rules, err := listUnitAutoScaleRules(query)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(&rules)
+}
+
+// title: unit autoscale set rule
+// path: /docker/autoscale/units/rules
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func unitAutoScaleSetRule(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var rule unitAutoScaleRule
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&rule, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	err = rule.validate()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	a, err := getUnitAutoScaleApp(rule.AppName)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateUnitAutoscale, appPermissionContexts(a)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       permission.PermAppUpdateUnitAutoscale,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return rule.update()
+}
+
+// title: unit autoscale delete rule
+// path: /docker/autoscale/units/rules/{app}
+// method: DELETE
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: Not found
+func unitAutoScaleDeleteRule(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getUnitAutoScaleApp(r.URL.Query().Get(":app"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermAppUpdateUnitAutoscale, appPermissionContexts(a)...) {
+		return permission.ErrUnauthorized
+	}
+	process := r.URL.Query().Get("process")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       permission.PermAppUpdateUnitAutoscale,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = deleteUnitAutoScaleRule(a.Name, process)
+	if err == errUnitAutoScaleRuleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -31,6 +31,8 @@ import (
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/iaas"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	tsuruNet "github.com/tsuru/tsuru/net"
@@ -210,6 +212,16 @@ func (s *HandlersSuite) TestAddNodeHandler(c *check.C) {
 		"LastSuccess": nodes[0].Metadata["LastSuccess"],
 	})
 	c.Assert(nodes[0].CreationStatus, check.Equals, cluster.NodeCreationStatusCreated)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: server.URL()},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "address", "value": server.URL()},
+			{"name": "pool", "value": "pool1"},
+			{"name": "register", "value": "true"},
+		},
+	}, eventtest.HasEvent)
 }
 
 func (s *HandlersSuite) TestAddNodeHandlerCreatingAnIaasMachine(c *check.C) {
@@ -434,6 +446,11 @@ func (s *HandlersSuite) TestRemoveNodeHandler(c *check.C) {
 	c.Assert(rec.Code, check.Equals, http.StatusOK)
 	nodes, err := mainDockerProvisioner.Cluster().Nodes()
 	c.Assert(nodes, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: "host.com:2375"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.delete",
+	}, eventtest.HasEvent)
 }
 
 func (s *HandlersSuite) TestRemoveNodeHandlerWithoutRemoveIaaS(c *check.C) {
@@ -788,6 +805,16 @@ func (s *HandlersSuite) TestMoveContainersHandler(c *check.C) {
 		{Message: "No units to move in localhost\n"},
 		{Message: "Containers moved successfully!\n"},
 	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: "localhost"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node",
+		StartCustomData: []map[string]interface{}{
+			{"name": "from", "value": "localhost"},
+			{"name": "to", "value": "127.0.0.1"},
+		},
+		LogMatches: "Containers moved successfully",
+	}, eventtest.HasEvent)
 }
 
 func (s *HandlersSuite) TestMoveContainerNotFound(c *check.C) {
@@ -1136,6 +1163,11 @@ func (s *HandlersSuite) TestUpdateNodeHandler(c *check.C) {
 		"m2": "v9",
 		"m3": "v8",
 	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: "localhost:1999"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.update",
+	}, eventtest.HasEvent)
 }
 
 func (s *HandlersSuite) TestUpdateNodeHandlerNoAddress(c *check.C) {
@@ -2386,3 +2418,211 @@ func (s *HandlersSuite) TestNodeContainerUpgrade(c *check.C) {
 		}},
 	})
 }
+
+func (s *HandlersSuite) TestUnitAutoScaleSetRule(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	rule := unitAutoScaleRule{AppName: "myapp", Process: "web", Enabled: true, MinUnits: 2, MaxUnits: 5, CPUTarget: 70}
+	v, err := form.EncodeToValues(&rule)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/docker/autoscale/units/rules", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rules, err := listUnitAutoScaleRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []unitAutoScaleRule{rule})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.unit.autoscale",
+		StartCustomData: []map[string]interface{}{
+			{"name": "AppName", "value": "myapp"},
+			{"name": "MaxUnits", "value": "5"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *HandlersSuite) TestUnitAutoScaleSetRuleInvalidRule(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	rule := unitAutoScaleRule{AppName: "myapp", MinUnits: 2, MaxUnits: 1, CPUTarget: 70}
+	v, err := form.EncodeToValues(&rule)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/docker/autoscale/units/rules", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid rule, max units (1) must be greater than or equal to min units (2)\n")
+}
+
+func (s *HandlersSuite) TestUnitAutoScaleSetRuleAppNotFound(c *check.C) {
+	rule := unitAutoScaleRule{AppName: "myapp", MinUnits: 1, MaxUnits: 2, CPUTarget: 70}
+	v, err := form.EncodeToValues(&rule)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/docker/autoscale/units/rules", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *HandlersSuite) TestUnitAutoScaleSetRuleWithoutPermission(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	limitedUser := &auth.User{Email: "mylimited@groundcontrol.com", Password: "123456"}
+	_, err = nativeScheme.Create(limitedUser)
+	c.Assert(err, check.IsNil)
+	defer nativeScheme.Remove(limitedUser)
+	t := createTokenForUser(limitedUser, "app.read", string(permission.CtxApp), "myapp", c)
+	rule := unitAutoScaleRule{AppName: "myapp", MinUnits: 1, MaxUnits: 2, CPUTarget: 70}
+	v, err := form.EncodeToValues(&rule)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/docker/autoscale/units/rules", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+t.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	rules, err := listUnitAutoScaleRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+}
+
+func (s *HandlersSuite) TestUnitAutoScaleListRules(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	rule1 := unitAutoScaleRule{AppName: "myapp", Process: "web", MinUnits: 1, MaxUnits: 2, CPUTarget: 70}
+	err = rule1.update()
+	c.Assert(err, check.IsNil)
+	rule2 := unitAutoScaleRule{AppName: "otherapp", Process: "web", MinUnits: 1, MaxUnits: 2, MemoryTarget: 70}
+	err = rule2.update()
+	c.Assert(err, check.IsNil)
+	server := api.RunServer(true)
+	request, err := http.NewRequest("GET", "/docker/autoscale/units/rules", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var rules []unitAutoScaleRule
+	err = json.Unmarshal(recorder.Body.Bytes(), &rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []unitAutoScaleRule{rule1, rule2})
+	request, err = http.NewRequest("GET", "/docker/autoscale/units/rules?app=myapp", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rules = nil
+	err = json.Unmarshal(recorder.Body.Bytes(), &rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []unitAutoScaleRule{rule1})
+}
+
+func (s *HandlersSuite) TestUnitAutoScaleListRulesAppPermission(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	limitedUser := &auth.User{Email: "mylimited@groundcontrol.com", Password: "123456"}
+	_, err = nativeScheme.Create(limitedUser)
+	c.Assert(err, check.IsNil)
+	defer nativeScheme.Remove(limitedUser)
+	t := createTokenForUser(limitedUser, "app.read", string(permission.CtxApp), "myapp", c)
+	server := api.RunServer(true)
+	request, err := http.NewRequest("GET", "/docker/autoscale/units/rules", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+t.GetValue())
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	request, err = http.NewRequest("GET", "/docker/autoscale/units/rules?app=myapp", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+t.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *HandlersSuite) TestUnitAutoScaleDeleteRule(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	rule := unitAutoScaleRule{AppName: "myapp", Process: "web", MinUnits: 1, MaxUnits: 2, CPUTarget: 70}
+	err = rule.update()
+	c.Assert(err, check.IsNil)
+	server := api.RunServer(true)
+	request, err := http.NewRequest("DELETE", "/docker/autoscale/units/rules/myapp?process=worker", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	request, err = http.NewRequest("DELETE", "/docker/autoscale/units/rules/myapp?process=web", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rules, err := listUnitAutoScaleRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target:          event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Owner:           s.token.GetUserName(),
+		Kind:            "app.update.unit.autoscale",
+		StartCustomData: []map[string]interface{}{{"name": "process", "value": "web"}},
+	}, eventtest.HasEvent)
+}
+
+func (s *HandlersSuite) TestUnitAutoScaleHistoryHandler(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	for _, appName := range []string{"myapp", "otherapp"} {
+		evt, err := newUnitAutoScaleEvent(&unitAutoScaleRule{AppName: appName, Process: "web"}, nil)
+		c.Assert(err, check.IsNil)
+		evt.Action = scaleActionAdd
+		evt.Reason = "cpu usage 100.00% (target 50.00%)"
+		evt.UnitsBefore = 1
+		evt.UnitsAfter = 2
+		err = evt.finish(nil)
+		c.Assert(err, check.IsNil)
+	}
+	server := api.RunServer(true)
+	request, err := http.NewRequest("GET", "/docker/autoscale/units?app=myapp", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var history []unitAutoScaleEvent
+	err = json.Unmarshal(recorder.Body.Bytes(), &history)
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 1)
+	c.Assert(history[0].AppName, check.Equals, "myapp")
+	c.Assert(history[0].Action, check.Equals, scaleActionAdd)
+	c.Assert(history[0].UnitsAfter, check.Equals, 2)
+	c.Assert(history[0].Successful, check.Equals, true)
+	request, err = http.NewRequest("GET", "/docker/autoscale/units", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	history = nil
+	err = json.Unmarshal(recorder.Body.Bytes(), &history)
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 2)
+}
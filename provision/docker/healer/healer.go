@@ -163,6 +163,7 @@ func (evt *HealingEvent) Update(created interface{}, healingErr error) error {
 		evt.CreatedContainer = v
 		evt.Successful = v.ID != ""
 	}
+	observeHealing(evt)
 	defer coll.RemoveId(evt.ID)
 	evt.ID = bson.NewObjectId()
 	return coll.Insert(evt)
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
@@ -113,8 +114,17 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 		return fmt.Errorf("Containers healing: unable to heal %q couldn't verify it still exists: %s", cont.ID, err)
 	}
 	log.Errorf("Initiating healing process for container %q, unresponsive since %s.", cont.ID, cont.LastSuccessStatusUpdate)
+	healerEvt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeContainer, Value: cont.ID},
+		InternalKind: "healer",
+		CustomData:   cont,
+	})
+	if err != nil {
+		return fmt.Errorf("Error trying to insert container healing event, healing aborted: %s", err.Error())
+	}
 	evt, err := NewHealingEvent(cont)
 	if err != nil {
+		healerEvt.Abort()
 		return fmt.Errorf("Error trying to insert container healing event, healing aborted: %s", err.Error())
 	}
 	newCont, healErr := h.healContainer(cont)
@@ -125,6 +135,10 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 	if err != nil {
 		log.Errorf("Error trying to update containers healing event: %s", err.Error())
 	}
+	err = healerEvt.DoneCustomData(healErr, newCont)
+	if err != nil {
+		log.Errorf("Error trying to update containers healing event: %s", err.Error())
+	}
 	return healErr
 }
 
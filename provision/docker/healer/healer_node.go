@@ -15,6 +15,7 @@ import (
 	"github.com/tsuru/monsterqueue"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/iaas"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
@@ -158,8 +159,24 @@ func (h *NodeHealer) tryHealingNode(node *cluster.Node, reason string, extra int
 		log.Debugf("node %q doesn't have IaaS information, healing (%s) won't run on it.", node.Address, reason)
 		return nil
 	}
+	healerEvt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeNode, Value: node.Address},
+		InternalKind: "healer",
+		CustomData: map[string]interface{}{
+			"reason": reason,
+			"extra":  extra,
+		},
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			// Healing in progress.
+			return nil
+		}
+		return fmt.Errorf("error trying to insert healing event: %s", err.Error())
+	}
 	evt, err := NewHealingEventWithReason(*node, reason, extra)
 	if err != nil {
+		healerEvt.Abort()
 		if err == errHealingInProgress {
 			// Healing in progress.
 			return nil
@@ -177,6 +194,10 @@ func (h *NodeHealer) tryHealingNode(node *cluster.Node, reason string, extra int
 		if updateErr != nil {
 			log.Errorf("error trying to update healing event: %s", updateErr.Error())
 		}
+		updateErr = healerEvt.DoneCustomData(evtErr, created)
+		if updateErr != nil {
+			log.Errorf("error trying to update healing event: %s", updateErr.Error())
+		}
 	}()
 	_, err = h.provisioner.Cluster().GetNode(node.Address)
 	if err != nil {
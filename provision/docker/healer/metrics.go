@@ -0,0 +1,26 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package healer
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var healingActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tsuru",
+	Subsystem: "healer",
+	Name:      "actions_total",
+	Help:      "The number of healing actions, by action and result.",
+}, []string{"action", "successful"})
+
+func init() {
+	prometheus.MustRegister(healingActions)
+}
+
+func observeHealing(evt *HealingEvent) {
+	healingActions.WithLabelValues(evt.Action, strconv.FormatBool(evt.Successful)).Inc()
+}
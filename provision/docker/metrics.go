@@ -0,0 +1,60 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/net"
+)
+
+var (
+	autoScaleActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tsuru",
+		Subsystem: "autoscale",
+		Name:      "actions_total",
+		Help:      "The number of auto scale actions, by scaled resource, action and result.",
+	}, []string{"kind", "action", "successful"})
+
+	limiterActionsDesc = prometheus.NewDesc(
+		"tsuru_docker_limiter_actions",
+		"The number of actions running in each node, as accounted by the action limiter.",
+		[]string{"node"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(autoScaleActions, &limiterCollector{})
+}
+
+func observeAutoScale(kind, action string, successful bool) {
+	autoScaleActions.WithLabelValues(kind, action, strconv.FormatBool(successful)).Inc()
+}
+
+// limiterCollector reports the occupancy of the action limiter of the docker
+// provisioner for each node in the cluster.
+type limiterCollector struct{}
+
+func (c *limiterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- limiterActionsDesc
+}
+
+func (c *limiterCollector) Collect(ch chan<- prometheus.Metric) {
+	p := mainDockerProvisioner
+	if p == nil || p.cluster == nil || p.actionLimiter == nil {
+		return
+	}
+	nodes, err := p.Cluster().UnfilteredNodes()
+	if err != nil {
+		log.Errorf("[metrics] unable to list nodes: %s", err)
+		return
+	}
+	for _, node := range nodes {
+		addr := net.URLToHost(node.Address)
+		ch <- prometheus.MustNewConstMetric(limiterActionsDesc, prometheus.GaugeValue, float64(p.ActionLimiter().Len(addr)), addr)
+	}
+}
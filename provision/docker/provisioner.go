@@ -37,24 +37,28 @@ import (
 	_ "github.com/tsuru/tsuru/router/galeb"
 	_ "github.com/tsuru/tsuru/router/hipache"
 	_ "github.com/tsuru/tsuru/router/routertest"
+	_ "github.com/tsuru/tsuru/router/template"
 	_ "github.com/tsuru/tsuru/router/vulcand"
+	"github.com/tsuru/tsuru/secret"
 	"gopkg.in/mgo.v2/bson"
 )
 
+const provisionerName = "docker"
+
 var mainDockerProvisioner *dockerProvisioner
 var ErrEntrypointOrProcfileNotFound = stderr.New("You should provide a entrypoint in image or a Procfile in the following locations: /home/application/current or /app/user or /.")
 
 func init() {
 	mainDockerProvisioner = &dockerProvisioner{}
-	provision.Register("docker", mainDockerProvisioner)
+	provision.Register(provisionerName, mainDockerProvisioner)
 }
 
 func getRouterForApp(app provision.App) (router.Router, error) {
-	routerName, err := app.GetRouter()
+	routers, err := app.GetRouters()
 	if err != nil {
 		return nil, err
 	}
-	return router.Get(routerName)
+	return router.GetForApp(routers)
 }
 
 type dockerProvisioner struct {
@@ -143,6 +147,11 @@ func (p *dockerProvisioner) initDockerCluster() error {
 		shutdown.Register(autoScale)
 		go autoScale.run()
 	}
+	unitAutoScale := p.initUnitAutoScaler()
+	if unitAutoScale.Enabled {
+		shutdown.Register(unitAutoScale)
+		unitAutoScale.Start()
+	}
 	limitMode, _ := config.GetString("docker:limit:mode")
 	if limitMode == "global" {
 		p.actionLimiter = &provision.MongodbLimiter{}
@@ -177,6 +186,14 @@ func (p *dockerProvisioner) initAutoScaleConfig() *autoScaleConfig {
 	}
 }
 
+func (p *dockerProvisioner) initUnitAutoScaler() *unitAutoScaler {
+	enabled, _ := config.GetBool("docker:unit-auto-scale:enabled")
+	runInterval, _ := config.GetInt("docker:unit-auto-scale:run-interval")
+	scaler := newUnitAutoScaler(p, time.Duration(runInterval)*time.Second)
+	scaler.Enabled = enabled
+	return scaler
+}
+
 func (p *dockerProvisioner) cloneProvisioner(ignoredContainers []container.Container) (*dockerProvisioner, error) {
 	var err error
 	overridenProvisioner := *p
@@ -281,6 +298,7 @@ func (p *dockerProvisioner) Initialize() error {
 
 // Provision creates a route for the container
 func (p *dockerProvisioner) Provision(app provision.App) error {
+	defer provision.ObserveOperation(provisionerName, "provision", time.Now())
 	r, err := getRouterForApp(app)
 	if err != nil {
 		log.Fatalf("Failed to get router: %s", err)
@@ -290,6 +308,7 @@ func (p *dockerProvisioner) Provision(app provision.App) error {
 }
 
 func (p *dockerProvisioner) Restart(a provision.App, process string, w io.Writer) error {
+	defer provision.ObserveOperation(provisionerName, "restart", time.Now())
 	containers, err := p.listContainersByProcess(a.GetName(), process)
 	if err != nil {
 		return err
@@ -316,6 +335,7 @@ func (p *dockerProvisioner) Restart(a provision.App, process string, w io.Writer
 }
 
 func (p *dockerProvisioner) Start(app provision.App, process string) error {
+	defer provision.ObserveOperation(provisionerName, "start", time.Now())
 	containers, err := p.listContainersByProcess(app.GetName(), process)
 	if err != nil {
 		return stderr.New(fmt.Sprintf("Got error while getting app containers: %s", err))
@@ -339,6 +359,7 @@ func (p *dockerProvisioner) Start(app provision.App, process string) error {
 }
 
 func (p *dockerProvisioner) Stop(app provision.App, process string) error {
+	defer provision.ObserveOperation(provisionerName, "stop", time.Now())
 	containers, err := p.listContainersByProcess(app.GetName(), process)
 	if err != nil {
 		log.Errorf("Got error while getting app containers: %s", err)
@@ -354,6 +375,7 @@ func (p *dockerProvisioner) Stop(app provision.App, process string) error {
 }
 
 func (p *dockerProvisioner) Sleep(app provision.App, process string) error {
+	defer provision.ObserveOperation(provisionerName, "sleep", time.Now())
 	containers, err := p.listContainersByProcess(app.GetName(), process)
 	if err != nil {
 		log.Errorf("Got error while getting app containers: %s", err)
@@ -369,6 +391,7 @@ func (p *dockerProvisioner) Sleep(app provision.App, process string) error {
 }
 
 func (p *dockerProvisioner) Swap(app1, app2 provision.App) error {
+	defer provision.ObserveOperation(provisionerName, "swap", time.Now())
 	r, err := getRouterForApp(app1)
 	if err != nil {
 		return err
@@ -390,21 +413,7 @@ func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, w io.
 	if !strings.Contains(imageId, ":") {
 		imageId = fmt.Sprintf("%s:latest", imageId)
 	}
-	fmt.Fprintln(w, "---- Pulling image to tsuru ----")
-	pullOpts := docker.PullImageOptions{
-		Repository:        imageId,
-		OutputStream:      w,
-		InactivityTimeout: net.StreamInactivityTimeout,
-	}
-	nodes, err := cluster.NodesForMetadata(map[string]string{"pool": app.GetPool()})
-	if err != nil {
-		return "", err
-	}
-	node, _, err := p.scheduler.minMaxNodes(nodes, app.GetName(), "")
-	if err != nil {
-		return "", err
-	}
-	err = cluster.PullImage(pullOpts, docker.AuthConfiguration{}, node)
+	err := p.pullAppImage(app, imageId, w)
 	if err != nil {
 		return "", err
 	}
@@ -430,6 +439,77 @@ func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, w io.
 	for k, v := range procfile {
 		fmt.Fprintf(w, "  ---> Process %s found with command: %v\n", k, v)
 	}
+	newImage, err := p.pushAppImage(app, imageId, w)
+	if err != nil {
+		return "", err
+	}
+	imageData := createImageMetadata(newImage, procfile)
+	err = saveImageCustomData(newImage, imageData.CustomData)
+	if err != nil {
+		return "", err
+	}
+	app.SetUpdatePlatform(true)
+	return newImage, p.deploy(app, newImage, w)
+}
+
+// PromoteImage deploys to the app an image built for another app. The image
+// is tagged as a new image of the app, keeping its processes and tsuru.yaml
+// data.
+func (p *dockerProvisioner) PromoteImage(app provision.App, imageId string, w io.Writer) (string, error) {
+	data, err := getImageCustomData(imageId)
+	if err != nil {
+		return "", err
+	}
+	err = p.pullAppImage(app, imageId, w)
+	if err != nil {
+		return "", err
+	}
+	newImage, err := p.pushAppImage(app, imageId, w)
+	if err != nil {
+		return "", err
+	}
+	customData := make(map[string]interface{}, len(data.CustomData)+1)
+	for key, value := range data.CustomData {
+		customData[key] = value
+	}
+	if len(data.Processes) > 0 {
+		processes := make(map[string]interface{}, len(data.Processes))
+		for name, command := range data.Processes {
+			processes[name] = command
+		}
+		customData["processes"] = processes
+	}
+	err = saveImageCustomData(newImage, customData)
+	if err != nil {
+		return "", err
+	}
+	return newImage, p.deploy(app, newImage, w)
+}
+
+// pullAppImage pulls the image in one of the nodes of the pool of the app.
+func (p *dockerProvisioner) pullAppImage(app provision.App, imageId string, w io.Writer) error {
+	cluster := p.Cluster()
+	fmt.Fprintln(w, "---- Pulling image to tsuru ----")
+	pullOpts := docker.PullImageOptions{
+		Repository:        imageId,
+		OutputStream:      w,
+		InactivityTimeout: net.StreamInactivityTimeout,
+	}
+	nodes, err := cluster.NodesForMetadata(map[string]string{"pool": app.GetPool()})
+	if err != nil {
+		return err
+	}
+	node, _, err := p.scheduler.minMaxNodes(nodes, app.GetName(), "")
+	if err != nil {
+		return err
+	}
+	return cluster.PullImage(pullOpts, docker.AuthConfiguration{}, node)
+}
+
+// pushAppImage tags the image as a new image of the app and pushes it to the
+// registry, returning the name of the new image.
+func (p *dockerProvisioner) pushAppImage(app provision.App, imageId string, w io.Writer) (string, error) {
+	cluster := p.Cluster()
 	newImage, err := appNewImageName(app.GetName())
 	if err != nil {
 		return "", err
@@ -455,13 +535,7 @@ func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, w io.
 	if err != nil {
 		return "", err
 	}
-	imageData := createImageMetadata(newImage, procfile)
-	err = saveImageCustomData(newImage, imageData.CustomData)
-	if err != nil {
-		return "", err
-	}
-	app.SetUpdatePlatform(true)
-	return newImage, p.deploy(app, newImage, w)
+	return newImage, nil
 }
 
 func (p *dockerProvisioner) ArchiveDeploy(app provision.App, archiveURL string, w io.Writer) (string, error) {
@@ -576,6 +650,26 @@ func (p *dockerProvisioner) UploadDeploy(app provision.App, archiveFile io.ReadC
 	return imageId, p.deployAndClean(app, imageId, w)
 }
 
+// CancelDeploy kills the containers building a new image for the app. The
+// deploy pipeline fails once the build container exits, rolling back the
+// actions already executed.
+func (p *dockerProvisioner) CancelDeploy(app provision.App) error {
+	containers, err := p.listBuildingContainersByApp(app.GetName())
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("no image being built for app %q", app.GetName())
+	}
+	for _, c := range containers {
+		err = p.Cluster().KillContainer(docker.KillContainerOptions{ID: c.ID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *dockerProvisioner) deployAndClean(a provision.App, imageId string, w io.Writer) error {
 	err := p.deploy(a, imageId, w)
 	if err != nil {
@@ -585,10 +679,16 @@ func (p *dockerProvisioner) deployAndClean(a provision.App, imageId string, w io
 }
 
 func (p *dockerProvisioner) deploy(a provision.App, imageId string, w io.Writer) error {
+	defer provision.ObserveOperation(provisionerName, "deploy", time.Now())
 	containers, err := p.listContainersByApp(a.GetName())
 	if err != nil {
 		return err
 	}
+	if weight := canaryWeight(a); weight > 0 {
+		err = p.runCanaryDeploy(w, a, containers, imageId, weight)
+		routesRebuildOrEnqueue(a.GetName())
+		return err
+	}
 	imageData, err := getImageCustomData(imageId)
 	if err != nil {
 		return err
@@ -612,7 +712,18 @@ func (p *dockerProvisioner) deploy(a provision.App, imageId string, w io.Writer)
 		if err = setQuota(a, toAdd); err != nil {
 			return err
 		}
-		_, err = p.runReplaceUnitsPipeline(w, a, toAdd, containers, imageId)
+		var strategy *rollingStrategy
+		if !p.isDryMode {
+			strategy, err = getRollingStrategy(imageId)
+			if err != nil {
+				return err
+			}
+		}
+		if strategy != nil {
+			err = p.runRollingDeploy(w, a, toAdd, containers, imageId, strategy)
+		} else {
+			_, err = p.runReplaceUnitsPipeline(w, a, toAdd, containers, imageId)
+		}
 	}
 	routesRebuildOrEnqueue(a.GetName())
 	return err
@@ -659,6 +770,7 @@ func getContainersToAdd(data ImageMetadata, oldContainers []container.Container)
 }
 
 func (p *dockerProvisioner) Destroy(app provision.App) error {
+	defer provision.ObserveOperation(provisionerName, "destroy", time.Now())
 	containers, err := p.listContainersByApp(app.GetName())
 	if err != nil {
 		log.Errorf("Failed to list app containers: %s", err.Error())
@@ -807,6 +919,7 @@ func addContainersWithHost(args *changeUnitsPipelineArgs) ([]container.Container
 }
 
 func (p *dockerProvisioner) AddUnits(a provision.App, units uint, process string, w io.Writer) ([]provision.Unit, error) {
+	defer provision.ObserveOperation(provisionerName, "add-units", time.Now())
 	if a.GetDeploys() == 0 {
 		return nil, stderr.New("New units can only be added after the first deployment")
 	}
@@ -834,6 +947,7 @@ func (p *dockerProvisioner) AddUnits(a provision.App, units uint, process string
 }
 
 func (p *dockerProvisioner) RemoveUnits(a provision.App, units uint, processName string, w io.Writer) error {
+	defer provision.ObserveOperation(provisionerName, "remove-units", time.Now())
 	if a == nil {
 		return stderr.New("remove units: app should not be nil")
 	}
@@ -950,6 +1064,105 @@ func (p *dockerProvisioner) ExecuteCommand(stdout, stderr io.Writer, app provisi
 	return nil
 }
 
+// ExecuteCommandIsolated runs the command in a new container created from the
+// current image of the app, with the same environment variables of the units
+// of the app. The container is removed once the command finishes.
+func (p *dockerProvisioner) ExecuteCommandIsolated(opts provision.IsolatedExecOptions) (int, error) {
+	a := opts.App
+	imageId, err := appCurrentImageName(a.GetName())
+	if err != nil {
+		return 0, err
+	}
+	appEnvs, err := provision.AppEnvs(a)
+	if err != nil {
+		return 0, err
+	}
+	var envs []string
+	for _, env := range appEnvs {
+		value, err := secret.Resolve(env.Value, a.GetTeamOwner())
+		if err != nil {
+			return 0, fmt.Errorf("unable to resolve env %q: %s", env.Name, err)
+		}
+		envs = append(envs, fmt.Sprintf("%s=%s", env.Name, value))
+	}
+	host, _ := config.GetString("host")
+	envs = append(envs, fmt.Sprintf("%s=%s", "TSURU_HOST", host))
+	securityOpts, _ := config.GetList("docker:security-opts")
+	createOptions := docker.CreateContainerOptions{
+		Config: &docker.Config{
+			AttachStdout: true,
+			AttachStderr: true,
+			Image:        imageId,
+			Entrypoint:   []string{},
+			Cmd:          append([]string{"/bin/bash", "-lc", opts.Cmd}, opts.Args...),
+			Env:          envs,
+			Memory:       a.GetMemory(),
+			MemorySwap:   a.GetMemory() + a.GetSwap(),
+			CPUShares:    int64(a.GetCpuShare()),
+			SecurityOpts: securityOpts,
+		},
+	}
+	cluster := p.Cluster()
+	schedOpts := &container.SchedulerOpts{
+		AppName:       a.GetName(),
+		ActionLimiter: p.ActionLimiter(),
+	}
+	addr, cont, err := cluster.CreateContainerSchedulerOpts(createOptions, schedOpts, net.StreamInactivityTimeout)
+	hostAddr := net.URLToHost(addr)
+	if schedOpts.LimiterDone != nil {
+		schedOpts.LimiterDone()
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		done := p.ActionLimiter().Start(hostAddr)
+		cluster.RemoveContainer(docker.RemoveContainerOptions{ID: cont.ID, Force: true})
+		done()
+	}()
+	attachOptions := docker.AttachToContainerOptions{
+		Container:    cont.ID,
+		OutputStream: opts.Stdout,
+		ErrorStream:  opts.Stderr,
+		Stream:       true,
+		Stdout:       true,
+		Stderr:       true,
+		Success:      make(chan struct{}),
+	}
+	waiter, err := cluster.AttachToContainerNonBlocking(attachOptions)
+	if err != nil {
+		return 0, err
+	}
+	<-attachOptions.Success
+	close(attachOptions.Success)
+	done := p.ActionLimiter().Start(hostAddr)
+	err = cluster.StartContainer(cont.ID, nil)
+	done()
+	if err != nil {
+		return 0, err
+	}
+	type waitResult struct {
+		code int
+		err  error
+	}
+	resultCh := make(chan waitResult, 1)
+	go func() {
+		waiter.Wait()
+		code, waitErr := cluster.WaitContainer(cont.ID)
+		resultCh <- waitResult{code: code, err: waitErr}
+	}()
+	var timeoutCh <-chan time.Time
+	if opts.Timeout > 0 {
+		timeoutCh = time.After(opts.Timeout)
+	}
+	select {
+	case result := <-resultCh:
+		return result.code, result.err
+	case <-timeoutCh:
+		return 0, provision.ErrExecTimeout
+	}
+}
+
 func (p *dockerProvisioner) SetCName(app provision.App, cname string) error {
 	r, err := getRouterForApp(app)
 	if err != nil {
@@ -991,6 +1204,10 @@ func (p *dockerProvisioner) AdminCommands() []cmd.Command {
 		&autoScaleInfoCmd{},
 		&autoScaleSetRuleCmd{},
 		&autoScaleDeleteRuleCmd{},
+		&unitAutoScaleHistoryCmd{},
+		&unitAutoScaleListRulesCmd{},
+		&unitAutoScaleSetRuleCmd{},
+		&unitAutoScaleDeleteRuleCmd{},
 		&updateNodeToSchedulerCmd{},
 		&dockerLogInfo{},
 		&dockerLogUpdate{},
@@ -1162,6 +1379,10 @@ func (p *dockerProvisioner) ValidAppImages(appName string) ([]string, error) {
 	return listValidAppImages(appName)
 }
 
+func (p *dockerProvisioner) ImageTsuruYamlData(image string) (provision.TsuruYamlData, error) {
+	return getImageTsuruYamlData(image)
+}
+
 func (p *dockerProvisioner) Nodes(app provision.App) ([]cluster.Node, error) {
 	pool := app.GetPool()
 	var (
@@ -24,6 +24,7 @@ import (
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/docker-cluster/storage"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/net"
@@ -917,6 +918,87 @@ func (s *S) TestImageDeployWithProcfile(c *check.C) {
 	c.Assert(imd.Processes, check.DeepEquals, expectedProcesses)
 }
 
+func (s *S) TestPromoteImage(c *check.C) {
+	p, err := s.startMultipleServersClusterSeggregated()
+	c.Assert(err, check.IsNil)
+	app.Provisioner = p
+	u, _ := url.Parse(s.server.URL())
+	imageName := fmt.Sprintf("%s/%s", u.Host, "tsuru/app-staging:v3")
+	config.Set("docker:registry", u.Host)
+	defer config.Unset("docker:registry")
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python web.py",
+			"worker": "python worker.py",
+		},
+		"healthcheck": map[string]interface{}{"path": "/status"},
+	}
+	err = s.newFakeImage(p, imageName, customData)
+	c.Assert(err, check.IsNil)
+	a := app.App{
+		Name:     "otherapp",
+		Platform: "python",
+		Quota:    quota.Unlimited,
+		Pool:     "pool1",
+	}
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	p.Provision(&a)
+	defer p.Destroy(&a)
+	w := safe.NewBuffer(make([]byte, 2048))
+	newImage, err := p.PromoteImage(&a, imageName, w)
+	c.Assert(err, check.IsNil)
+	currentImage, err := appCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(newImage, check.Equals, currentImage)
+	c.Assert(newImage, check.Not(check.Equals), imageName)
+	imd, err := getImageCustomData(newImage)
+	c.Assert(err, check.IsNil)
+	c.Assert(imd.Processes, check.DeepEquals, map[string]string{
+		"web":    "python web.py",
+		"worker": "python worker.py",
+	})
+	yamlData, err := getImageTsuruYamlData(newImage)
+	c.Assert(err, check.IsNil)
+	c.Assert(yamlData.Healthcheck.Path, check.Equals, "/status")
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+}
+
+func (s *S) TestCancelDeploy(c *check.C) {
+	building, err := s.newContainer(&newContainerOpts{AppName: "myapp"}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(building)
+	running, err := s.newContainer(&newContainerOpts{AppName: "myapp"}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(running)
+	coll := s.p.Collection()
+	defer coll.Close()
+	err = coll.Update(bson.M{"id": building.ID}, bson.M{"$set": bson.M{"buildingimage": "tsuru/app-myapp:v2"}})
+	c.Assert(err, check.IsNil)
+	for _, cont := range []*container.Container{building, running} {
+		err = s.p.Cluster().StartContainer(cont.ID, nil)
+		c.Assert(err, check.IsNil)
+	}
+	err = s.p.CancelDeploy(provisiontest.NewFakeApp("myapp", "python", 0))
+	c.Assert(err, check.IsNil)
+	dockerContainer, err := s.p.Cluster().InspectContainer(building.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dockerContainer.State.Running, check.Equals, false)
+	dockerContainer, err = s.p.Cluster().InspectContainer(running.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dockerContainer.State.Running, check.Equals, true)
+}
+
+func (s *S) TestCancelDeployNotBuilding(c *check.C) {
+	cont, err := s.newContainer(&newContainerOpts{AppName: "myapp"}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	err = s.p.CancelDeploy(provisiontest.NewFakeApp("myapp", "python", 0))
+	c.Assert(err, check.ErrorMatches, `no image being built for app "myapp"`)
+}
+
 func (s *S) TestImageDeployShouldHaveAnEntrypoint(c *check.C) {
 	p, err := s.startMultipleServersClusterSeggregated()
 	c.Assert(err, check.IsNil)
@@ -1726,6 +1808,102 @@ func (s *S) TestProvisionerExecuteCommandOnceNoContainers(c *check.C) {
 	c.Assert(err, check.Equals, provision.ErrEmptyApp)
 }
 
+func (s *S) TestProvisionerExecuteCommandIsolated(c *check.C) {
+	err := s.newFakeImage(s.p, "tsuru/app-almah", nil)
+	c.Assert(err, check.IsNil)
+	a := app.App{
+		Name:     "almah",
+		Platform: "static",
+		Quota:    quota.Unlimited,
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost"},
+		},
+	}
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	config.Set("host", "tsuru_host")
+	defer config.Unset("host")
+	var createConfig docker.Config
+	s.server.SetHook(func(r *http.Request) {
+		if r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/containers/create") {
+			data, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
+			json.Unmarshal(data, &createConfig)
+		}
+	})
+	defer s.server.SetHook(nil)
+	s.server.CustomHandler("/containers/.*/attach", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "cannot hijack connection", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+		w.WriteHeader(http.StatusOK)
+		conn, _, cErr := hijacker.Hijack()
+		if cErr != nil {
+			return
+		}
+		outStream := stdcopy.NewStdWriter(conn, stdcopy.Stdout)
+		fmt.Fprintln(outStream, "job output")
+		conn.Close()
+	}))
+	s.server.CustomHandler("/containers/.*/wait", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int{"StatusCode": 2})
+	}))
+	var stdout, stderr bytes.Buffer
+	code, err := s.p.ExecuteCommandIsolated(provision.IsolatedExecOptions{
+		App:    &a,
+		Cmd:    "ls",
+		Args:   []string{"-l"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(code, check.Equals, 2)
+	c.Assert(stdout.String(), check.Equals, "job output\n")
+	c.Assert(createConfig.Image, check.Equals, "tsuru/app-almah")
+	c.Assert(createConfig.Cmd, check.DeepEquals, []string{"/bin/bash", "-lc", "ls", "-l"})
+	c.Assert(createConfig.Env, check.DeepEquals, []string{"DATABASE_HOST=localhost", "TSURU_HOST=tsuru_host"})
+	client, err := docker.NewClient(s.server.URL())
+	c.Assert(err, check.IsNil)
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+}
+
+func (s *S) TestProvisionerExecuteCommandIsolatedTimeout(c *check.C) {
+	err := s.newFakeImage(s.p, "tsuru/app-almah", nil)
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "almah", Platform: "static", Quota: quota.Unlimited}
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	release := make(chan bool)
+	defer close(release)
+	s.server.CustomHandler("/containers/.*/attach", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+		w.WriteHeader(http.StatusOK)
+	}))
+	s.server.CustomHandler("/containers/.*/wait", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(map[string]int{"StatusCode": 0})
+	}))
+	var buf bytes.Buffer
+	_, err = s.p.ExecuteCommandIsolated(provision.IsolatedExecOptions{
+		App:     &a,
+		Cmd:     "sleep 3600",
+		Stdout:  &buf,
+		Stderr:  &buf,
+		Timeout: 100 * time.Millisecond,
+	})
+	c.Assert(err, check.Equals, provision.ErrExecTimeout)
+	client, err := docker.NewClient(s.server.URL())
+	c.Assert(err, check.IsNil)
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+}
+
 func (s *S) TestProvisionCollection(c *check.C) {
 	collection := s.p.Collection()
 	defer collection.Close()
@@ -1781,6 +1959,10 @@ func (s *S) TestAdminCommands(c *check.C) {
 		&autoScaleInfoCmd{},
 		&autoScaleSetRuleCmd{},
 		&autoScaleDeleteRuleCmd{},
+		&unitAutoScaleHistoryCmd{},
+		&unitAutoScaleListRulesCmd{},
+		&unitAutoScaleSetRuleCmd{},
+		&unitAutoScaleDeleteRuleCmd{},
 		&updateNodeToSchedulerCmd{},
 		&dockerLogInfo{},
 		&dockerLogUpdate{},
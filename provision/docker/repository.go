@@ -82,6 +82,10 @@ func (p *dockerProvisioner) listContainersByApp(appName string) ([]container.Con
 	return p.ListContainers(bson.M{"appname": appName})
 }
 
+func (p *dockerProvisioner) listBuildingContainersByApp(appName string) ([]container.Container, error) {
+	return p.ListContainers(bson.M{"appname": appName, "buildingimage": bson.M{"$nin": []string{""}}})
+}
+
 func (p *dockerProvisioner) listContainersByAppAndHost(appNames, addresses []string) ([]container.Container, error) {
 	query := bson.M{}
 	if len(appNames) > 0 {
@@ -0,0 +1,310 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/action"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+)
+
+const (
+	deployStrategyDefault = "default"
+	deployStrategyRolling = "rolling"
+
+	defaultMaxSurge       = "25%"
+	defaultMaxUnavailable = 0
+)
+
+type rollingStrategy struct {
+	maxSurge       interface{}
+	maxUnavailable interface{}
+}
+
+// getRollingStrategy returns the rolling strategy settings to be used when
+// deploying the given image, or nil if units should be replaced all at once.
+// Settings in the tsuru.yaml of the image take precedence over the ones in
+// tsuru.conf.
+func getRollingStrategy(imageId string) (*rollingStrategy, error) {
+	yamlData, err := getImageTsuruYamlData(imageId)
+	if err != nil {
+		return nil, err
+	}
+	deployData := yamlData.Deploy
+	strategy := deployData.Strategy
+	if strategy == "" {
+		strategy, _ = config.GetString("docker:deploy:strategy")
+	}
+	switch strategy {
+	case "", deployStrategyDefault:
+		return nil, nil
+	case deployStrategyRolling:
+	default:
+		return nil, fmt.Errorf("invalid deploy strategy %q", strategy)
+	}
+	rs := rollingStrategy{
+		maxSurge:       deployData.MaxSurge,
+		maxUnavailable: deployData.MaxUnavailable,
+	}
+	if rs.maxSurge == nil {
+		rs.maxSurge, err = config.Get("docker:deploy:max-surge")
+		if err != nil {
+			rs.maxSurge = defaultMaxSurge
+		}
+	}
+	if rs.maxUnavailable == nil {
+		rs.maxUnavailable, err = config.Get("docker:deploy:max-unavailable")
+		if err != nil {
+			rs.maxUnavailable = defaultMaxUnavailable
+		}
+	}
+	return &rs, nil
+}
+
+// intOrPercent resolves a value that is either an absolute number or a
+// percentage of total. Percentages are rounded up or down according to
+// roundUp.
+func intOrPercent(value interface{}, total int, roundUp bool) (int, error) {
+	var result int
+	switch v := value.(type) {
+	case int:
+		result = v
+	case float64:
+		result = int(v)
+	case string:
+		v = strings.TrimSpace(v)
+		if strings.HasSuffix(v, "%") {
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid percentage %q", v)
+			}
+			n := percent * float64(total) / 100
+			if roundUp {
+				result = int(math.Ceil(n))
+			} else {
+				result = int(math.Floor(n))
+			}
+		} else {
+			var err error
+			result, err = strconv.Atoi(v)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q, expected a number or a percentage", v)
+			}
+		}
+	default:
+		return 0, fmt.Errorf("invalid value %v, expected a number or a percentage", value)
+	}
+	if result < 0 {
+		return 0, fmt.Errorf("invalid value %v, must not be negative", value)
+	}
+	return result, nil
+}
+
+// limits returns the surge and the number of unavailable units allowed for a
+// process with the given number of units. Surge is rounded up and
+// unavailability is rounded down, and at least one of them is always
+// positive, so that the deploy is able to make progress.
+func (s *rollingStrategy) limits(total int) (int, int, error) {
+	surge, err := intOrPercent(s.maxSurge, total, true)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max surge: %s", err)
+	}
+	unavailable, err := intOrPercent(s.maxUnavailable, total, false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max unavailable: %s", err)
+	}
+	if surge == 0 && unavailable == 0 {
+		surge = 1
+	}
+	return surge, unavailable, nil
+}
+
+// rollingBatch is a step of a rolling deploy. Units in preRemove are removed
+// before starting the new units, consuming the allowed unavailability, while
+// units in toRemove are only removed after the new units are healthy.
+type rollingBatch struct {
+	process   string
+	toAdd     int
+	preRemove []container.Container
+	toRemove  []container.Container
+}
+
+// planRollingBatches splits the replacement of the old containers by the new
+// ones described in toAdd in batches, respecting the strategy limits for
+// each process. Old containers running processes that don't exist anymore
+// are removed in the last batch.
+func (s *rollingStrategy) planRollingBatches(toAdd map[string]*containersToAdd, oldContainers []container.Container) ([]rollingBatch, error) {
+	processes := make([]string, 0, len(toAdd))
+	for name := range toAdd {
+		processes = append(processes, name)
+	}
+	sort.Strings(processes)
+	oldByProcess := make(map[string][]container.Container)
+	var leftover []container.Container
+	for _, c := range oldContainers {
+		if _, ok := toAdd[c.ProcessName]; ok {
+			oldByProcess[c.ProcessName] = append(oldByProcess[c.ProcessName], c)
+		} else {
+			leftover = append(leftover, c)
+		}
+	}
+	var batches []rollingBatch
+	for _, process := range processes {
+		desired := toAdd[process].Quantity
+		old := oldByProcess[process]
+		surge, unavailable, err := s.limits(desired)
+		if err != nil {
+			return nil, err
+		}
+		created := 0
+		for created < desired || len(old) > 0 {
+			batch := rollingBatch{process: process}
+			preRemove := minInt(unavailable, len(old))
+			batch.preRemove, old = old[:preRemove], old[preRemove:]
+			batch.toAdd = minInt(desired-created, maxInt(surge+preRemove, 1))
+			created += batch.toAdd
+			toRemove := maxInt(0, len(old)-(desired-created))
+			batch.toRemove, old = old[:toRemove], old[toRemove:]
+			batches = append(batches, batch)
+		}
+	}
+	if len(leftover) > 0 {
+		if len(batches) == 0 {
+			batches = append(batches, rollingBatch{})
+		}
+		last := &batches[len(batches)-1]
+		last.toRemove = append(last.toRemove, leftover...)
+	}
+	return batches, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// runRollingDeploy replaces the old containers in batches, according to the
+// strategy. Each batch starts, binds and checks the health of its new units
+// before routing to them and removing the old units. If any batch fails, the
+// units replaced by the previous batches are recreated with the current
+// image of the app.
+func (p *dockerProvisioner) runRollingDeploy(w io.Writer, a provision.App, toAdd map[string]*containersToAdd, oldContainers []container.Container, imageId string, strategy *rollingStrategy) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	batches, err := strategy.planRollingBatches(toAdd, oldContainers)
+	if err != nil {
+		return err
+	}
+	var (
+		created []container.Container
+		removed []container.Container
+	)
+	for i, batch := range batches {
+		fmt.Fprintf(w, "\n---- Rolling deploy batch %d of %d [%s: +%d -%d] ----\n",
+			i+1, len(batches), batch.process, batch.toAdd, len(batch.preRemove)+len(batch.toRemove))
+		args := changeUnitsPipelineArgs{
+			app:         a,
+			toRemove:    batch.preRemove,
+			writer:      w,
+			imageId:     imageId,
+			provisioner: p,
+		}
+		if len(batch.preRemove) > 0 {
+			err = action.NewPipeline(
+				&removeOldRoutes,
+				&provisionRemoveOldUnits,
+				&provisionUnbindOldUnits,
+			).Execute(args)
+			if err != nil {
+				return p.rollbackRollingDeploy(w, a, created, removed, err)
+			}
+			removed = append(removed, batch.preRemove...)
+		}
+		args.toAdd = map[string]*containersToAdd{}
+		if batch.toAdd > 0 {
+			args.toAdd[batch.process] = &containersToAdd{Quantity: batch.toAdd}
+		}
+		args.toRemove = batch.toRemove
+		var pipeline *action.Pipeline
+		if i == len(batches)-1 {
+			pipeline = action.NewPipeline(
+				&provisionAddUnitsToHost,
+				&bindAndHealthcheck,
+				&addNewRoutes,
+				&setRouterHealthcheck,
+				&removeOldRoutes,
+				&updateAppImage,
+				&provisionRemoveOldUnits,
+				&provisionUnbindOldUnits,
+			)
+		} else {
+			pipeline = action.NewPipeline(
+				&provisionAddUnitsToHost,
+				&bindAndHealthcheck,
+				&addNewRoutes,
+				&removeOldRoutes,
+				&provisionRemoveOldUnits,
+				&provisionUnbindOldUnits,
+			)
+		}
+		err = pipeline.Execute(args)
+		if err != nil {
+			return p.rollbackRollingDeploy(w, a, created, removed, err)
+		}
+		created = append(created, pipeline.Result().([]container.Container)...)
+		removed = append(removed, batch.toRemove...)
+	}
+	return nil
+}
+
+func (p *dockerProvisioner) rollbackRollingDeploy(w io.Writer, a provision.App, created, removed []container.Container, deployErr error) error {
+	if len(created) == 0 && len(removed) == 0 {
+		return deployErr
+	}
+	fmt.Fprintf(w, "\n**** ROLLING BACK %d REPLACED %s ****\n", len(removed), strings.ToUpper(pluralize("unit", len(removed))))
+	currentImage, err := appCurrentImageName(a.GetName())
+	if err == nil {
+		toAdd := map[string]*containersToAdd{}
+		for _, c := range removed {
+			if _, ok := toAdd[c.ProcessName]; !ok {
+				toAdd[c.ProcessName] = &containersToAdd{}
+			}
+			toAdd[c.ProcessName].Quantity++
+		}
+		_, err = p.runReplaceUnitsPipeline(w, a, toAdd, created, currentImage)
+	}
+	if err != nil {
+		log.Errorf("[rolling deploy] unable to roll back app %q: %s", a.GetName(), err)
+		return &tsuruErrors.CompositeError{
+			Base:    deployErr,
+			Message: fmt.Sprintf("rolling deploy failed and could not be rolled back: %s", err),
+		}
+	}
+	return &tsuruErrors.CompositeError{
+		Base:    deployErr,
+		Message: "rolling deploy failed and was rolled back",
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestIntOrPercent(c *check.C) {
+	tests := []struct {
+		value    interface{}
+		total    int
+		roundUp  bool
+		expected int
+		err      string
+	}{
+		{2, 10, true, 2, ""},
+		{float64(3), 10, true, 3, ""},
+		{"4", 10, true, 4, ""},
+		{"25%", 10, true, 3, ""},
+		{"25%", 10, false, 2, ""},
+		{" 50% ", 3, false, 1, ""},
+		{"100%", 3, false, 3, ""},
+		{"x%", 10, true, 0, `invalid percentage "x%"`},
+		{"abc", 10, true, 0, `invalid value "abc", expected a number or a percentage`},
+		{-1, 10, true, 0, `invalid value -1, must not be negative`},
+		{true, 10, true, 0, `invalid value true, expected a number or a percentage`},
+	}
+	for i, t := range tests {
+		result, err := intOrPercent(t.value, t.total, t.roundUp)
+		if t.err != "" {
+			c.Check(err, check.ErrorMatches, t.err, check.Commentf("test %d", i))
+			continue
+		}
+		c.Check(err, check.IsNil, check.Commentf("test %d", i))
+		c.Check(result, check.Equals, t.expected, check.Commentf("test %d", i))
+	}
+}
+
+func (s *S) TestRollingStrategyLimits(c *check.C) {
+	rs := rollingStrategy{maxSurge: 0, maxUnavailable: "10%"}
+	surge, unavailable, err := rs.limits(5)
+	c.Assert(err, check.IsNil)
+	c.Assert(surge, check.Equals, 1)
+	c.Assert(unavailable, check.Equals, 0)
+	rs = rollingStrategy{maxSurge: "10%", maxUnavailable: "50%"}
+	surge, unavailable, err = rs.limits(5)
+	c.Assert(err, check.IsNil)
+	c.Assert(surge, check.Equals, 1)
+	c.Assert(unavailable, check.Equals, 2)
+	rs = rollingStrategy{maxSurge: "a", maxUnavailable: 0}
+	_, _, err = rs.limits(5)
+	c.Assert(err, check.ErrorMatches, `invalid max surge: .*`)
+}
+
+type batchSummary struct {
+	process   string
+	toAdd     int
+	preRemove int
+	toRemove  int
+}
+
+func summarizeBatches(batches []rollingBatch) []batchSummary {
+	result := make([]batchSummary, len(batches))
+	for i, b := range batches {
+		result[i] = batchSummary{b.process, b.toAdd, len(b.preRemove), len(b.toRemove)}
+	}
+	return result
+}
+
+func makeContainers(process string, n int) []container.Container {
+	containers := make([]container.Container, n)
+	for i := range containers {
+		containers[i] = container.Container{ProcessName: process}
+	}
+	return containers
+}
+
+func (s *S) TestPlanRollingBatchesSurgeOnly(c *check.C) {
+	rs := rollingStrategy{maxSurge: 1, maxUnavailable: 0}
+	batches, err := rs.planRollingBatches(map[string]*containersToAdd{"web": {Quantity: 3}}, makeContainers("web", 3))
+	c.Assert(err, check.IsNil)
+	c.Assert(summarizeBatches(batches), check.DeepEquals, []batchSummary{
+		{"web", 1, 0, 1},
+		{"web", 1, 0, 1},
+		{"web", 1, 0, 1},
+	})
+}
+
+func (s *S) TestPlanRollingBatchesSurgeAndUnavailable(c *check.C) {
+	rs := rollingStrategy{maxSurge: 1, maxUnavailable: 1}
+	batches, err := rs.planRollingBatches(map[string]*containersToAdd{"web": {Quantity: 4}}, makeContainers("web", 4))
+	c.Assert(err, check.IsNil)
+	c.Assert(summarizeBatches(batches), check.DeepEquals, []batchSummary{
+		{"web", 2, 1, 1},
+		{"web", 2, 1, 1},
+	})
+}
+
+func (s *S) TestPlanRollingBatchesUnavailableOnly(c *check.C) {
+	rs := rollingStrategy{maxSurge: 0, maxUnavailable: "50%"}
+	batches, err := rs.planRollingBatches(map[string]*containersToAdd{"web": {Quantity: 4}}, makeContainers("web", 4))
+	c.Assert(err, check.IsNil)
+	c.Assert(summarizeBatches(batches), check.DeepEquals, []batchSummary{
+		{"web", 2, 2, 0},
+		{"web", 2, 2, 0},
+	})
+}
+
+func (s *S) TestPlanRollingBatchesMultipleProcesses(c *check.C) {
+	rs := rollingStrategy{maxSurge: "50%", maxUnavailable: 0}
+	old := append(makeContainers("web", 2), makeContainers("worker", 1)...)
+	old = append(old, makeContainers("old-process", 1)...)
+	toAdd := map[string]*containersToAdd{
+		"worker": {Quantity: 1},
+		"web":    {Quantity: 2},
+		"clock":  {Quantity: 1},
+	}
+	batches, err := rs.planRollingBatches(toAdd, old)
+	c.Assert(err, check.IsNil)
+	c.Assert(summarizeBatches(batches), check.DeepEquals, []batchSummary{
+		{"clock", 1, 0, 0},
+		{"web", 1, 0, 1},
+		{"web", 1, 0, 1},
+		{"worker", 1, 0, 2},
+	})
+	c.Assert(batches[3].toRemove[1].ProcessName, check.Equals, "old-process")
+}
+
+func (s *S) TestGetRollingStrategy(c *check.C) {
+	err := saveImageCustomData("tsuru/app-myapp:v1", map[string]interface{}{
+		"deploy": map[string]interface{}{
+			"strategy":  "rolling",
+			"max_surge": 2,
+		},
+	})
+	c.Assert(err, check.IsNil)
+	strategy, err := getRollingStrategy("tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.DeepEquals, &rollingStrategy{maxSurge: 2, maxUnavailable: defaultMaxUnavailable})
+	err = saveImageCustomData("tsuru/app-myapp:v2", map[string]interface{}{})
+	c.Assert(err, check.IsNil)
+	strategy, err = getRollingStrategy("tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.IsNil)
+	config.Set("docker:deploy:strategy", "rolling")
+	config.Set("docker:deploy:max-unavailable", "10%")
+	defer config.Unset("docker:deploy")
+	strategy, err = getRollingStrategy("tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.DeepEquals, &rollingStrategy{maxSurge: defaultMaxSurge, maxUnavailable: "10%"})
+	config.Set("docker:deploy:strategy", "bogus")
+	_, err = getRollingStrategy("tsuru/app-myapp:v2")
+	c.Assert(err, check.ErrorMatches, `invalid deploy strategy "bogus"`)
+}
+
+func (s *S) TestRunRollingDeploy(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	s.p.Provision(a)
+	defer s.p.Destroy(a)
+	err := s.newFakeImage(s.p, "tsuru/app-myapp:v1", nil)
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(s.p, "tsuru/app-myapp:v2", nil)
+	c.Assert(err, check.IsNil)
+	err = appendAppImageName("myapp", "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	oldContainers, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		app:         a,
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 3}},
+		imageId:     "tsuru/app-myapp:v1",
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	buf := safe.NewBuffer(nil)
+	strategy := &rollingStrategy{maxSurge: 1, maxUnavailable: 0}
+	err = s.p.runRollingDeploy(buf, a, map[string]*containersToAdd{"web": {Quantity: 3}}, oldContainers, "tsuru/app-myapp:v2", strategy)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*Rolling deploy batch 1 of 3 \[web: \+1 -1\].*Rolling deploy batch 3 of 3 \[web: \+1 -1\].*`)
+	containers, err := s.p.listContainersByApp("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 3)
+	for _, cont := range containers {
+		c.Assert(cont.Image, check.Equals, "tsuru/app-myapp:v2")
+	}
+	imageName, err := appCurrentImageName("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(imageName, check.Equals, "tsuru/app-myapp:v2")
+}
+
+func (s *S) TestRunRollingDeployRollback(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	s.p.Provision(a)
+	defer s.p.Destroy(a)
+	err := s.newFakeImage(s.p, "tsuru/app-myapp:v1", nil)
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(s.p, "tsuru/app-myapp:v2", nil)
+	c.Assert(err, check.IsNil)
+	err = appendAppImageName("myapp", "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	oldContainers, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		app:         a,
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 3}},
+		imageId:     "tsuru/app-myapp:v1",
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	var newCreated int32
+	s.server.CustomHandler("/containers/create", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+		var result docker.Config
+		jsonErr := json.Unmarshal(data, &result)
+		if jsonErr == nil && result.Image == "tsuru/app-myapp:v2" {
+			if atomic.AddInt32(&newCreated, 1) > 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		s.server.DefaultHandler().ServeHTTP(w, r)
+	}))
+	defer s.server.CustomHandler("/containers/create", s.server.DefaultHandler())
+	buf := safe.NewBuffer(nil)
+	strategy := &rollingStrategy{maxSurge: 1, maxUnavailable: 0}
+	err = s.p.runRollingDeploy(buf, a, map[string]*containersToAdd{"web": {Quantity: 3}}, oldContainers, "tsuru/app-myapp:v2", strategy)
+	c.Assert(err, check.ErrorMatches, `(?s)rolling deploy failed and was rolled back Caused by: .*`)
+	c.Assert(buf.String(), check.Matches, `(?s).*ROLLING BACK 1 REPLACED UNIT.*`)
+	containers, err := s.p.listContainersByApp("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 3)
+	for _, cont := range containers {
+		c.Assert(cont.Image, check.Equals, "tsuru/app-myapp:v1")
+	}
+	imageName, err := appCurrentImageName("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(imageName, check.Equals, "tsuru/app-myapp:v1")
+}
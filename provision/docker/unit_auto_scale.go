@@ -0,0 +1,236 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/periodic"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// unitAutoScaleTolerance is the maximum distance between the usage/target
+// ratio and 1.0 that doesn't trigger a scale action, avoiding flapping when
+// the usage is close to the target.
+const unitAutoScaleTolerance = 0.1
+
+// unitAutoScaler periodically checks the resource usage of app units,
+// adding and removing units according to the unit auto scale rules.
+type unitAutoScaler struct {
+	*periodic.Loop
+	Enabled     bool
+	provisioner *dockerProvisioner
+	writer      io.Writer
+}
+
+type unitUsage struct {
+	cpu    float64
+	memory float64
+}
+
+func newUnitAutoScaler(p *dockerProvisioner, interval time.Duration) *unitAutoScaler {
+	if interval == 0 {
+		interval = time.Minute
+	}
+	a := &unitAutoScaler{provisioner: p}
+	a.Loop = &periodic.Loop{
+		Name:     "unit autoscale",
+		Interval: interval,
+		Task:     a.runScaler,
+	}
+	return a
+}
+
+func (a *unitAutoScaler) logError(msg string, params ...interface{}) {
+	msg = fmt.Sprintf("[unit autoscale] %s", msg)
+	log.Errorf(msg, params...)
+}
+
+func (a *unitAutoScaler) logDebug(msg string, params ...interface{}) {
+	msg = fmt.Sprintf("[unit autoscale] %s", msg)
+	log.Debugf(msg, params...)
+}
+
+func (a *unitAutoScaler) runScaler() error {
+	rules, err := listUnitAutoScaleRules(bson.M{"enabled": true})
+	if err != nil {
+		return fmt.Errorf("error getting rules: %s", err.Error())
+	}
+	for i := range rules {
+		err = a.scaleRule(&rules[i])
+		if err != nil {
+			a.logError("error scaling app %s, process %q: %s", rules[i].AppName, rules[i].Process, err.Error())
+		}
+	}
+	return nil
+}
+
+func (a *unitAutoScaler) scaleRule(rule *unitAutoScaleRule) error {
+	dbApp, err := app.GetByName(rule.AppName)
+	if err != nil {
+		if err == app.ErrAppNotFound {
+			a.logDebug("skipped rule for app %s, app not found", rule.AppName)
+			return nil
+		}
+		return err
+	}
+	prov, err := dbApp.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	if prov != provision.Provisioner(a.provisioner) {
+		a.logDebug("skipped rule for app %s, app is not in a docker pool", rule.AppName)
+		return nil
+	}
+	containers, err := a.provisioner.listContainersByProcess(rule.AppName, rule.Process)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if c.Status != provision.StatusStarted.String() && c.Status != provision.StatusStarting.String() {
+			a.logDebug("skipped rule for app %s, unit %s is %s", rule.AppName, c.ShortID(), c.Status)
+			return nil
+		}
+	}
+	current := len(containers)
+	if current == 0 {
+		return nil
+	}
+	usage, err := a.provisioner.averageUsage(containers)
+	if err != nil {
+		return err
+	}
+	desired, reason := rule.desiredUnits(current, usage)
+	if desired > current && !dbApp.Quota.Unlimited() {
+		available := dbApp.Quota.Limit - dbApp.Quota.InUse
+		if available <= 0 {
+			a.logDebug("skipped scaling app %s up, quota exceeded", rule.AppName)
+			return nil
+		}
+		if desired-current > available {
+			desired = current + available
+		}
+	}
+	if desired == current {
+		return nil
+	}
+	evt, err := newUnitAutoScaleEvent(rule, a.writer)
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			a.logDebug("skipped scaling app %s, app is locked", rule.AppName)
+			return nil
+		}
+		return err
+	}
+	evt.Reason = reason
+	evt.UnitsBefore = current
+	evt.UnitsAfter = desired
+	if desired > current {
+		evt.Action = scaleActionAdd
+		evt.logMsg("adding %d units to app %s, process %q: %s", desired-current, rule.AppName, rule.Process, reason)
+		err = dbApp.AddUnits(uint(desired-current), rule.Process, evt)
+	} else {
+		evt.Action = scaleActionRemove
+		evt.logMsg("removing %d units from app %s, process %q: %s", current-desired, rule.AppName, rule.Process, reason)
+		err = dbApp.RemoveUnits(uint(current-desired), rule.Process, evt)
+	}
+	return evt.finish(err)
+}
+
+// desiredUnits returns the amount of units needed to get the usage close to
+// the targets of the rule, along with a description of the usage. When both
+// targets are set, the greater amount of units wins.
+func (r *unitAutoScaleRule) desiredUnits(current int, usage unitUsage) (int, string) {
+	desired := 0
+	var reasons []string
+	scale := func(name string, value, target float64) {
+		reasons = append(reasons, fmt.Sprintf("%s usage %.2f%% (target %.2f%%)", name, value, target))
+		n := current
+		ratio := value / target
+		if math.Abs(ratio-1.0) > unitAutoScaleTolerance {
+			n = int(math.Ceil(float64(current) * ratio))
+		}
+		if n > desired {
+			desired = n
+		}
+	}
+	if r.CPUTarget > 0 {
+		scale("cpu", usage.cpu, r.CPUTarget)
+	}
+	if r.MemoryTarget > 0 {
+		scale("memory", usage.memory, r.MemoryTarget)
+	}
+	if desired < int(r.MinUnits) {
+		desired = int(r.MinUnits)
+	}
+	if desired > int(r.MaxUnits) {
+		desired = int(r.MaxUnits)
+	}
+	return desired, strings.Join(reasons, ", ")
+}
+
+func (p *dockerProvisioner) averageUsage(containers []container.Container) (unitUsage, error) {
+	var total unitUsage
+	for i := range containers {
+		usage, err := p.containerUsage(&containers[i])
+		if err != nil {
+			return unitUsage{}, err
+		}
+		total.cpu += usage.cpu
+		total.memory += usage.memory
+	}
+	n := float64(len(containers))
+	return unitUsage{cpu: total.cpu / n, memory: total.memory / n}, nil
+}
+
+// containerUsage reads the container stats from the Docker API, returning the
+// CPU usage as a percentage of one core and the memory usage as a percentage
+// of the memory limit of the container.
+func (p *dockerProvisioner) containerUsage(c *container.Container) (unitUsage, error) {
+	node, err := p.getNodeByHost(c.HostAddr)
+	if err != nil {
+		return unitUsage{}, err
+	}
+	client, err := node.Client()
+	if err != nil {
+		return unitUsage{}, err
+	}
+	statsCh := make(chan *docker.Stats, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Stats(docker.StatsOptions{ID: c.ID, Stats: statsCh, Timeout: time.Minute})
+	}()
+	stats := <-statsCh
+	for range statsCh {
+	}
+	err = <-errCh
+	if err != nil {
+		return unitUsage{}, err
+	}
+	if stats == nil {
+		return unitUsage{}, errors.New("no stats available for unit " + c.ShortID())
+	}
+	var usage unitUsage
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemCPUUsage) - float64(stats.PreCPUStats.SystemCPUUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		usage.cpu = cpuDelta * float64(len(stats.CPUStats.CPUUsage.PercpuUsage)) * 100 / systemDelta
+	}
+	if stats.MemoryStats.Limit > 0 {
+		usage.memory = float64(stats.MemoryStats.Usage) * 100 / float64(stats.MemoryStats.Limit)
+	}
+	return usage, nil
+}
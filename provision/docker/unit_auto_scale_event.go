@@ -0,0 +1,139 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type unitAutoScaleEvent struct {
+	ID          bson.ObjectId `bson:"_id"`
+	AppName     string
+	Process     string
+	Action      string // scaleActionAdd, scaleActionRemove
+	Reason      string
+	UnitsBefore int
+	UnitsAfter  int
+	StartTime   time.Time
+	EndTime     time.Time `bson:",omitempty"`
+	Successful  bool
+	Error       string `bson:",omitempty"`
+	Log         string `bson:",omitempty"`
+	logBuffer   safe.Buffer
+	writer      io.Writer
+	evt         *event.Event
+}
+
+func unitAutoScaleCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	name, err := config.GetString("docker:collection")
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection(fmt.Sprintf("%s_unit_auto_scale", name)), nil
+}
+
+// newUnitAutoScaleEvent starts a new scale event for the app, the event holds
+// the app lock until finish is called, so other operations in the app (and
+// other auto scale runs) can't run concurrently.
+func newUnitAutoScaleEvent(rule *unitAutoScaleRule, writer io.Writer) (*unitAutoScaleEvent, error) {
+	evt := unitAutoScaleEvent{
+		ID:        bson.NewObjectId(),
+		AppName:   rule.AppName,
+		Process:   rule.Process,
+		StartTime: time.Now().UTC(),
+		writer:    writer,
+	}
+	var err error
+	evt.evt, err = event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: rule.AppName},
+		InternalKind: "autoscale",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &evt, nil
+}
+
+func (evt *unitAutoScaleEvent) logMsg(msg string, params ...interface{}) {
+	log.Debugf(fmt.Sprintf("[unit autoscale] %s", msg), params...)
+	msg += "\n"
+	if evt.writer != nil {
+		fmt.Fprintf(evt.writer, msg, params...)
+	}
+	fmt.Fprintf(&evt.logBuffer, msg, params...)
+	fmt.Fprintf(evt.evt, msg, params...)
+}
+
+// Write allows the event to be used as the output of app operations.
+func (evt *unitAutoScaleEvent) Write(data []byte) (int, error) {
+	if evt.writer != nil {
+		evt.writer.Write(data)
+	}
+	evt.logBuffer.Write(data)
+	return evt.evt.Write(data)
+}
+
+func (evt *unitAutoScaleEvent) finish(errParam error) error {
+	if errParam != nil {
+		evt.Error = errParam.Error()
+		evt.logMsg(evt.Error)
+	}
+	evt.evt.DoneCustomData(errParam, map[string]interface{}{
+		"action":      evt.Action,
+		"reason":      evt.Reason,
+		"process":     evt.Process,
+		"unitsBefore": evt.UnitsBefore,
+		"unitsAfter":  evt.UnitsAfter,
+	})
+	coll, err := unitAutoScaleCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	evt.Log = evt.logBuffer.String()
+	evt.Successful = errParam == nil
+	evt.EndTime = time.Now().UTC()
+	observeAutoScale("unit", evt.Action, evt.Successful)
+	return coll.Insert(evt)
+}
+
+func listUnitAutoScaleEvents(appName string, skip, limit int) ([]unitAutoScaleEvent, error) {
+	coll, err := unitAutoScaleCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var filter bson.M
+	if appName != "" {
+		filter = bson.M{"appname": appName}
+	}
+	query := coll.Find(filter).Sort("-starttime")
+	if skip != 0 {
+		query = query.Skip(skip)
+	}
+	if limit != 0 {
+		query = query.Limit(limit)
+	}
+	var list []unitAutoScaleEvent
+	err = query.All(&list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
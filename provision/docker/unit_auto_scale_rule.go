@@ -0,0 +1,116 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var errUnitAutoScaleRuleNotFound = errors.New("unit auto scale rule not found")
+
+// unitAutoScaleRule defines how the units of a process of an app are scaled.
+// The amount of units is kept between MinUnits and MaxUnits, trying to keep
+// the average utilization of the units close to the configured targets.
+// Targets are percentages: MemoryTarget is relative to the memory limit of
+// each unit while CPUTarget is relative to one CPU core, so it may be greater
+// than 100.
+type unitAutoScaleRule struct {
+	AppName      string
+	Process      string
+	Enabled      bool
+	MinUnits     uint
+	MaxUnits     uint
+	CPUTarget    float64
+	MemoryTarget float64
+}
+
+func (r *unitAutoScaleRule) validate() error {
+	if r.AppName == "" {
+		return errors.New("invalid rule, app name is required")
+	}
+	if r.MinUnits == 0 {
+		return errors.New("invalid rule, min units must be greater than 0")
+	}
+	if r.MaxUnits < r.MinUnits {
+		return fmt.Errorf("invalid rule, max units (%d) must be greater than or equal to min units (%d)", r.MaxUnits, r.MinUnits)
+	}
+	if r.CPUTarget < 0 || r.MemoryTarget < 0 {
+		return errors.New("invalid rule, targets cannot be negative")
+	}
+	if r.MemoryTarget > 100 {
+		return fmt.Errorf("invalid rule, memory target must be lesser than or equal to 100, got %f", r.MemoryTarget)
+	}
+	if r.CPUTarget == 0 && r.MemoryTarget == 0 {
+		return errors.New("invalid rule, either cpu target or memory target must be set")
+	}
+	return nil
+}
+
+func (r *unitAutoScaleRule) query() bson.M {
+	return bson.M{"appname": r.AppName, "process": r.Process}
+}
+
+func (r *unitAutoScaleRule) update() error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+	coll, err := unitAutoScaleRuleCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.Upsert(r.query(), r)
+	return err
+}
+
+func unitAutoScaleRuleCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	name, err := config.GetString("docker:collection")
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection(fmt.Sprintf("%s_unit_auto_scale_rule", name)), nil
+}
+
+func listUnitAutoScaleRules(query bson.M) ([]unitAutoScaleRule, error) {
+	coll, err := unitAutoScaleRuleCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var rules []unitAutoScaleRule
+	err = coll.Find(query).Sort("appname", "process").All(&rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func deleteUnitAutoScaleRule(appName, process string) error {
+	coll, err := unitAutoScaleRuleCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	rule := unitAutoScaleRule{AppName: appName, Process: process}
+	info, err := coll.RemoveAll(rule.query())
+	if err != nil {
+		return err
+	}
+	if info.Removed == 0 {
+		return errUnitAutoScaleRuleNotFound
+	}
+	return nil
+}
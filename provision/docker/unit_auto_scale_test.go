@@ -0,0 +1,289 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newUnitAutoScaleApp(c *check.C, units uint, q quota.Quota) *app.App {
+	err := s.newFakeImage(s.p, "tsuru/app-myapp", nil)
+	c.Assert(err, check.IsNil)
+	a := app.App{
+		Name:     "myapp",
+		Platform: "python",
+		Pool:     "test-default",
+		Deploys:  1,
+		Quota:    q,
+	}
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.Provision(&a)
+	c.Assert(err, check.IsNil)
+	_, err = s.p.AddUnits(&a, units, "web", nil)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+// prepareUnitsUsage makes all containers of the app report the given CPU and
+// memory usage percentages.
+func (s *S) prepareUnitsUsage(c *check.C, appName string, cpu, memory uint64) {
+	containers, err := s.p.listContainersByApp(appName)
+	c.Assert(err, check.IsNil)
+	for _, cont := range containers {
+		s.server.PrepareStats(cont.ID, func(string) docker.Stats {
+			var stats docker.Stats
+			stats.CPUStats.CPUUsage.PercpuUsage = []uint64{1}
+			stats.CPUStats.CPUUsage.TotalUsage = 1000 + cpu*2
+			stats.PreCPUStats.CPUUsage.TotalUsage = 1000
+			stats.CPUStats.SystemCPUUsage = 5000 + 200
+			stats.PreCPUStats.SystemCPUUsage = 5000
+			stats.MemoryStats.Usage = memory
+			stats.MemoryStats.Limit = 100
+			return stats
+		})
+	}
+}
+
+func (s *S) TestUnitAutoScaleRuleValidate(c *check.C) {
+	tests := []struct {
+		rule unitAutoScaleRule
+		err  string
+	}{
+		{unitAutoScaleRule{AppName: "myapp", MinUnits: 1, MaxUnits: 2, CPUTarget: 50}, ""},
+		{unitAutoScaleRule{AppName: "myapp", MinUnits: 2, MaxUnits: 2, MemoryTarget: 80}, ""},
+		{unitAutoScaleRule{MinUnits: 1, MaxUnits: 2, CPUTarget: 50}, "invalid rule, app name is required"},
+		{unitAutoScaleRule{AppName: "myapp", MaxUnits: 2, CPUTarget: 50}, "invalid rule, min units must be greater than 0"},
+		{unitAutoScaleRule{AppName: "myapp", MinUnits: 3, MaxUnits: 2, CPUTarget: 50}, `invalid rule, max units \(2\) must be greater than or equal to min units \(3\)`},
+		{unitAutoScaleRule{AppName: "myapp", MinUnits: 1, MaxUnits: 2}, "invalid rule, either cpu target or memory target must be set"},
+		{unitAutoScaleRule{AppName: "myapp", MinUnits: 1, MaxUnits: 2, CPUTarget: -1}, "invalid rule, targets cannot be negative"},
+		{unitAutoScaleRule{AppName: "myapp", MinUnits: 1, MaxUnits: 2, MemoryTarget: 120}, "invalid rule, memory target must be lesser than or equal to 100, got 120.000000"},
+	}
+	for _, tt := range tests {
+		err := tt.rule.validate()
+		if tt.err == "" {
+			c.Check(err, check.IsNil)
+		} else {
+			c.Check(err, check.ErrorMatches, tt.err)
+		}
+	}
+}
+
+func (s *S) TestUnitAutoScaleRuleDesiredUnits(c *check.C) {
+	rule := unitAutoScaleRule{MinUnits: 1, MaxUnits: 10, CPUTarget: 50}
+	tests := []struct {
+		current  int
+		usage    unitUsage
+		expected int
+	}{
+		{2, unitUsage{cpu: 100}, 4},
+		{2, unitUsage{cpu: 52}, 2},
+		{4, unitUsage{cpu: 10}, 1},
+		{4, unitUsage{cpu: 30}, 3},
+		{6, unitUsage{cpu: 100}, 10},
+	}
+	for _, tt := range tests {
+		desired, _ := rule.desiredUnits(tt.current, tt.usage)
+		c.Check(desired, check.Equals, tt.expected)
+	}
+	rule = unitAutoScaleRule{MinUnits: 2, MaxUnits: 10, CPUTarget: 50, MemoryTarget: 80}
+	desired, reason := rule.desiredUnits(4, unitUsage{cpu: 10, memory: 100})
+	c.Assert(desired, check.Equals, 5)
+	c.Assert(reason, check.Equals, "cpu usage 10.00% (target 50.00%), memory usage 100.00% (target 80.00%)")
+	desired, _ = rule.desiredUnits(4, unitUsage{cpu: 5, memory: 5})
+	c.Assert(desired, check.Equals, 2)
+}
+
+func (s *S) TestUnitAutoScaleRuleUpdate(c *check.C) {
+	rule := unitAutoScaleRule{AppName: "myapp", Process: "web", Enabled: true, MinUnits: 1, MaxUnits: 3, CPUTarget: 50}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	rule.MaxUnits = 5
+	err = rule.update()
+	c.Assert(err, check.IsNil)
+	other := unitAutoScaleRule{AppName: "myapp", Process: "worker", MinUnits: 1, MaxUnits: 2, MemoryTarget: 70}
+	err = other.update()
+	c.Assert(err, check.IsNil)
+	rules, err := listUnitAutoScaleRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []unitAutoScaleRule{rule, other})
+	rules, err = listUnitAutoScaleRules(bson.M{"enabled": true})
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []unitAutoScaleRule{rule})
+}
+
+func (s *S) TestUnitAutoScaleRuleUpdateInvalid(c *check.C) {
+	rule := unitAutoScaleRule{AppName: "myapp", MinUnits: 1, MaxUnits: 3}
+	err := rule.update()
+	c.Assert(err, check.ErrorMatches, "invalid rule, either cpu target or memory target must be set")
+	rules, err := listUnitAutoScaleRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+}
+
+func (s *S) TestDeleteUnitAutoScaleRule(c *check.C) {
+	rule := unitAutoScaleRule{AppName: "myapp", Process: "web", MinUnits: 1, MaxUnits: 3, CPUTarget: 50}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	err = deleteUnitAutoScaleRule("myapp", "worker")
+	c.Assert(err, check.Equals, errUnitAutoScaleRuleNotFound)
+	err = deleteUnitAutoScaleRule("myapp", "web")
+	c.Assert(err, check.IsNil)
+	rules, err := listUnitAutoScaleRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+}
+
+func (s *S) TestContainerUsage(c *check.C) {
+	a := s.newUnitAutoScaleApp(c, 1, quota.Unlimited)
+	s.prepareUnitsUsage(c, a.Name, 75, 40)
+	containers, err := s.p.listContainersByApp(a.Name)
+	c.Assert(err, check.IsNil)
+	usage, err := s.p.containerUsage(&containers[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.Equals, unitUsage{cpu: 75, memory: 40})
+}
+
+func (s *S) TestUnitAutoScaleRunAddUnits(c *check.C) {
+	a := s.newUnitAutoScaleApp(c, 2, quota.Unlimited)
+	rule := unitAutoScaleRule{AppName: a.Name, Process: "web", Enabled: true, MinUnits: 1, MaxUnits: 10, CPUTarget: 50}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	s.prepareUnitsUsage(c, a.Name, 100, 10)
+	scaler := s.p.initUnitAutoScaler()
+	err = scaler.RunOnce()
+	c.Assert(err, check.IsNil)
+	containers, err := s.p.listContainersByProcess(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 4)
+	history, err := listUnitAutoScaleEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 1)
+	c.Assert(history[0].Action, check.Equals, scaleActionAdd)
+	c.Assert(history[0].Successful, check.Equals, true)
+	c.Assert(history[0].UnitsBefore, check.Equals, 2)
+	c.Assert(history[0].UnitsAfter, check.Equals, 4)
+	c.Assert(history[0].Reason, check.Equals, "cpu usage 100.00% (target 50.00%)")
+	c.Assert(history[0].Log, check.Matches, `(?s)adding 2 units to app myapp, process "web".*`)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   "autoscale",
+		EndCustomData: map[string]interface{}{
+			"action":      "add",
+			"reason":      "cpu usage 100.00% (target 50.00%)",
+			"process":     "web",
+			"unitsBefore": 2,
+			"unitsAfter":  4,
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnitAutoScaleRunRemoveUnits(c *check.C) {
+	a := s.newUnitAutoScaleApp(c, 4, quota.Unlimited)
+	rule := unitAutoScaleRule{AppName: a.Name, Process: "web", Enabled: true, MinUnits: 2, MaxUnits: 10, MemoryTarget: 80}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	s.prepareUnitsUsage(c, a.Name, 0, 20)
+	scaler := s.p.initUnitAutoScaler()
+	err = scaler.RunOnce()
+	c.Assert(err, check.IsNil)
+	containers, err := s.p.listContainersByProcess(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	history, err := listUnitAutoScaleEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 1)
+	c.Assert(history[0].Action, check.Equals, scaleActionRemove)
+	c.Assert(history[0].UnitsBefore, check.Equals, 4)
+	c.Assert(history[0].UnitsAfter, check.Equals, 2)
+}
+
+func (s *S) TestUnitAutoScaleRunWithinTarget(c *check.C) {
+	a := s.newUnitAutoScaleApp(c, 2, quota.Unlimited)
+	rule := unitAutoScaleRule{AppName: a.Name, Process: "web", Enabled: true, MinUnits: 1, MaxUnits: 10, CPUTarget: 50}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	s.prepareUnitsUsage(c, a.Name, 52, 10)
+	scaler := s.p.initUnitAutoScaler()
+	err = scaler.RunOnce()
+	c.Assert(err, check.IsNil)
+	containers, err := s.p.listContainersByProcess(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	history, err := listUnitAutoScaleEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 0)
+}
+
+func (s *S) TestUnitAutoScaleRunRespectsQuota(c *check.C) {
+	a := s.newUnitAutoScaleApp(c, 2, quota.Quota{Limit: 3, InUse: 2})
+	rule := unitAutoScaleRule{AppName: a.Name, Process: "web", Enabled: true, MinUnits: 1, MaxUnits: 10, CPUTarget: 50}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	s.prepareUnitsUsage(c, a.Name, 100, 10)
+	scaler := s.p.initUnitAutoScaler()
+	err = scaler.RunOnce()
+	c.Assert(err, check.IsNil)
+	containers, err := s.p.listContainersByProcess(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 3)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Quota, check.DeepEquals, quota.Quota{Limit: 3, InUse: 3})
+}
+
+func (s *S) TestUnitAutoScaleRunIgnoresDisabledRules(c *check.C) {
+	a := s.newUnitAutoScaleApp(c, 2, quota.Unlimited)
+	rule := unitAutoScaleRule{AppName: a.Name, Process: "web", Enabled: false, MinUnits: 1, MaxUnits: 10, CPUTarget: 50}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	s.prepareUnitsUsage(c, a.Name, 100, 10)
+	scaler := s.p.initUnitAutoScaler()
+	err = scaler.RunOnce()
+	c.Assert(err, check.IsNil)
+	containers, err := s.p.listContainersByProcess(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+}
+
+func (s *S) TestUnitAutoScaleRunStoppedApp(c *check.C) {
+	a := s.newUnitAutoScaleApp(c, 2, quota.Unlimited)
+	rule := unitAutoScaleRule{AppName: a.Name, Process: "web", Enabled: true, MinUnits: 1, MaxUnits: 10, CPUTarget: 50}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	s.prepareUnitsUsage(c, a.Name, 100, 10)
+	coll := s.p.Collection()
+	defer coll.Close()
+	_, err = coll.UpdateAll(bson.M{"appname": a.Name}, bson.M{"$set": bson.M{"status": provision.StatusStopped.String()}})
+	c.Assert(err, check.IsNil)
+	scaler := s.p.initUnitAutoScaler()
+	err = scaler.RunOnce()
+	c.Assert(err, check.IsNil)
+	containers, err := s.p.listContainersByProcess(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+}
+
+func (s *S) TestInitUnitAutoScalerInterval(c *check.C) {
+	scaler := s.p.initUnitAutoScaler()
+	c.Assert(scaler.Interval, check.Equals, time.Minute)
+	c.Assert(scaler.Enabled, check.Equals, false)
+	config.Set("docker:unit-auto-scale:enabled", true)
+	config.Set("docker:unit-auto-scale:run-interval", 30)
+	defer config.Unset("docker:unit-auto-scale")
+	scaler = s.p.initUnitAutoScaler()
+	c.Assert(scaler.Interval, check.Equals, 30*time.Second)
+	c.Assert(scaler.Enabled, check.Equals, true)
+}
@@ -0,0 +1,330 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	stdnet "net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/net"
+)
+
+const (
+	deploymentsAPIPrefix = "/apis/extensions/v1beta1"
+	coreAPIPrefix        = "/api/v1"
+	defaultNamespace     = "default"
+)
+
+// apiError is the error returned by the Kubernetes API for unsuccessful
+// requests.
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("kubernetes API error (%d): %s", e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.Code == http.StatusNotFound
+}
+
+// clusterClient is a minimal client for the Kubernetes API, supporting only
+// the operations required by the provisioner.
+type clusterClient struct {
+	addr         string
+	token        string
+	namespace    string
+	tlsConfig    *tls.Config
+	httpClient   *http.Client
+	streamClient *http.Client
+}
+
+func newClusterClient() (*clusterClient, error) {
+	addr, err := config.GetString("kubernetes:api:url")
+	if err != nil {
+		return nil, errors.New(`kubernetes: missing "kubernetes:api:url" config`)
+	}
+	token, _ := config.GetString("kubernetes:api:token")
+	namespace, _ := config.GetString("kubernetes:namespace")
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	client := &clusterClient{
+		addr:         strings.TrimRight(addr, "/"),
+		token:        token,
+		namespace:    namespace,
+		httpClient:   net.Dial5Full60ClientNoKeepAlive,
+		streamClient: net.Dial5FullUnlimitedClient,
+	}
+	caFile, _ := config.GetString("kubernetes:api:ca-file")
+	if caFile != "" {
+		var caData []byte
+		caData, err = ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("kubernetes: no certificates found in %q", caFile)
+		}
+		client.tlsConfig = &tls.Config{RootCAs: pool}
+		client.httpClient = newTLSClient(client.tlsConfig, time.Minute)
+		client.streamClient = newTLSClient(client.tlsConfig, 0)
+	}
+	return client, nil
+}
+
+func newTLSClient(tlsConfig *tls.Config, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial:                net.Dial5Dialer.Dial,
+			TLSHandshakeTimeout: 5 * time.Second,
+			TLSClientConfig:     tlsConfig,
+		},
+		Timeout: timeout,
+	}
+}
+
+func (c *clusterClient) deploymentsPath(name string) string {
+	path := fmt.Sprintf("%s/namespaces/%s/deployments", deploymentsAPIPrefix, c.namespace)
+	if name != "" {
+		path += "/" + name
+	}
+	return path
+}
+
+func (c *clusterClient) podsPath(name string) string {
+	path := fmt.Sprintf("%s/namespaces/%s/pods", coreAPIPrefix, c.namespace)
+	if name != "" {
+		path += "/" + name
+	}
+	return path
+}
+
+func (c *clusterClient) newRequest(method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := c.addr + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+func (c *clusterClient) send(client *http.Client, req *http.Request) (*http.Response, error) {
+	rsp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		defer rsp.Body.Close()
+		data, _ := ioutil.ReadAll(rsp.Body)
+		apiErr := &apiError{Code: rsp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var status apiStatus
+		if json.Unmarshal(data, &status) == nil && status.Message != "" {
+			apiErr.Message = status.Message
+		}
+		return nil, apiErr
+	}
+	return rsp, nil
+}
+
+func (c *clusterClient) do(method, path string, query url.Values, body, result interface{}) error {
+	req, err := c.newRequest(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	rsp, err := c.send(c.httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(rsp.Body).Decode(result)
+}
+
+func (c *clusterClient) getDeployment(name string) (*deployment, error) {
+	var dep deployment
+	err := c.do("GET", c.deploymentsPath(name), nil, nil, &dep)
+	if err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
+func (c *clusterClient) listDeployments(labels map[string]string) ([]deployment, error) {
+	var list deploymentList
+	err := c.do("GET", c.deploymentsPath(""), selectorQuery(labels), nil, &list)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(deploymentsByName(list.Items))
+	return list.Items, nil
+}
+
+func (c *clusterClient) createDeployment(dep *deployment) (*deployment, error) {
+	dep.Kind = "Deployment"
+	dep.APIVersion = "extensions/v1beta1"
+	var created deployment
+	err := c.do("POST", c.deploymentsPath(""), nil, dep, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *clusterClient) updateDeployment(dep *deployment) (*deployment, error) {
+	dep.Kind = "Deployment"
+	dep.APIVersion = "extensions/v1beta1"
+	var updated deployment
+	err := c.do("PUT", c.deploymentsPath(dep.Metadata.Name), nil, dep, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// deleteDeployment removes the deployment, along with its replica sets and
+// pods.
+func (c *clusterClient) deleteDeployment(name string) error {
+	orphan := false
+	opts := deleteOptions{Kind: "DeleteOptions", APIVersion: "v1", OrphanDependents: &orphan}
+	return c.do("DELETE", c.deploymentsPath(name), nil, opts, nil)
+}
+
+func (c *clusterClient) getPod(name string) (*pod, error) {
+	var p pod
+	err := c.do("GET", c.podsPath(name), nil, nil, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (c *clusterClient) listPods(labels map[string]string) ([]pod, error) {
+	var list podList
+	err := c.do("GET", c.podsPath(""), selectorQuery(labels), nil, &list)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(podsByName(list.Items))
+	return list.Items, nil
+}
+
+func (c *clusterClient) createPod(p *pod) (*pod, error) {
+	p.Kind = "Pod"
+	p.APIVersion = "v1"
+	var created pod
+	err := c.do("POST", c.podsPath(""), nil, p, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *clusterClient) deletePod(name string) error {
+	return c.do("DELETE", c.podsPath(name), nil, nil, nil)
+}
+
+// podLogs copies the logs of the given container in the pod to w. When follow
+// is true, it only returns after the container stops.
+func (c *clusterClient) podLogs(name, containerName string, follow bool, w io.Writer) error {
+	query := url.Values{"container": []string{containerName}}
+	if follow {
+		query.Set("follow", "true")
+	}
+	req, err := c.newRequest("GET", c.podsPath(name)+"/log", query, nil)
+	if err != nil {
+		return err
+	}
+	rsp, err := c.send(c.streamClient, req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	_, err = io.Copy(w, rsp.Body)
+	return err
+}
+
+func (c *clusterClient) dial() (stdnet.Conn, error) {
+	u, err := url.Parse(c.addr)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if _, _, err = stdnet.SplitHostPort(host); err != nil {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = stdnet.JoinHostPort(host, port)
+	}
+	if u.Scheme == "https" {
+		return tls.DialWithDialer(net.Dial5Dialer, "tcp", host, c.tlsConfig)
+	}
+	return net.Dial5Dialer.Dial("tcp", host)
+}
+
+func selectorQuery(labels map[string]string) url.Values {
+	if len(labels) == 0 {
+		return nil
+	}
+	return url.Values{"labelSelector": []string{labelSelectorString(labels)}}
+}
+
+func labelSelectorString(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for k, v := range labels {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+type deploymentsByName []deployment
+
+func (l deploymentsByName) Len() int           { return len(l) }
+func (l deploymentsByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l deploymentsByName) Less(i, j int) bool { return l[i].Metadata.Name < l[j].Metadata.Name }
+
+type podsByName []pod
+
+func (l podsByName) Len() int           { return len(l) }
+func (l podsByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l podsByName) Less(i, j int) bool { return l[i].Metadata.Name < l[j].Metadata.Name }
@@ -0,0 +1,516 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/secret"
+)
+
+const (
+	buildContainer       = "build"
+	deployAgentContainer = "deploy-agent"
+	uploadArchivePath    = "/home/application/archive.tar.gz"
+)
+
+var (
+	errNoProcesses      = errors.New("no processes found in the image, please declare them in a Procfile")
+	errProcfileNotFound = errors.New("You should provide a Procfile in the image in one of the following locations: /home/application/current or /app/user or /.")
+
+	procfileRegex = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+)
+
+func buildPodName(appName string) string {
+	return fmt.Sprintf("%s-build", appName)
+}
+
+func deployCmds(a provision.App, params ...string) ([]string, error) {
+	deployCmd, _ := config.GetString("docker:deploy-cmd")
+	if deployCmd == "" {
+		deployCmd = "/var/lib/tsuru/deploy"
+	}
+	cmds := append([]string{deployCmd}, params...)
+	host, _ := config.GetString("host")
+	envs, err := provision.AppEnvs(a)
+	if err != nil {
+		return nil, err
+	}
+	token := envs["TSURU_APP_TOKEN"].Value
+	unitAgentCmds := []string{"tsuru_unit_agent", host, token, a.GetName(), `"` + strings.Join(cmds, " ") + `"`, "deploy"}
+	return []string{"/bin/bash", "-lc", strings.Join(unitAgentCmds, " ")}, nil
+}
+
+// commitScript is run by the deploy-agent container of build pods, using the
+// docker daemon of the node to commit the build container as the new image
+// of the app once it finishes successfully.
+func commitScript(podName, imageId string) string {
+	return fmt.Sprintf(`filter="-f label=io.kubernetes.pod.name=%[1]s -f label=io.kubernetes.container.name=%[2]s -f status=exited"
+while [ -z "$(docker ps -a -q $filter)" ]; do sleep 1; done
+id=$(docker ps -a -q $filter | head -n 1)
+code=$(docker inspect -f '{{.State.ExitCode}}' $id)
+[ "$code" = "0" ] || exit $code
+docker commit $id %[3]s && docker push %[3]s`, podName, buildContainer, imageId)
+}
+
+func newBuildPod(a provision.App, baseImage, imageId string, cmds []string, stdin bool) *pod {
+	name := buildPodName(a.GetName())
+	agentImage, _ := config.GetString("kubernetes:deploy-agent-image")
+	if agentImage == "" {
+		agentImage = "docker:1.11"
+	}
+	return &pod{
+		Metadata: objectMeta{
+			Name: name,
+			Labels: map[string]string{
+				labelIsTsuru: "true",
+				labelIsBuild: "true",
+				labelAppName: a.GetName(),
+				labelAppPool: a.GetPool(),
+			},
+			Annotations: map[string]string{annotationBuildImage: imageId},
+		},
+		Spec: podSpec{
+			RestartPolicy: "Never",
+			Containers: []container{
+				{
+					Name:      buildContainer,
+					Image:     baseImage,
+					Command:   cmds,
+					Stdin:     stdin,
+					StdinOnce: stdin,
+				},
+				{
+					Name:         deployAgentContainer,
+					Image:        agentImage,
+					Command:      []string{"/bin/sh", "-c", commitScript(name, imageId)},
+					VolumeMounts: []volumeMount{{Name: "dockersock", MountPath: "/var/run/docker.sock"}},
+				},
+			},
+			Volumes: []volume{
+				{Name: "dockersock", HostPath: &hostPathVolumeSource{Path: "/var/run/docker.sock"}},
+			},
+		},
+	}
+}
+
+// waitForPod polls the pod until cond returns true, returning the pod in its
+// last observed state.
+func waitForPod(client *clusterClient, name string, timeout time.Duration, cond func(*pod) bool) (*pod, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		pod, err := client.getPod(name)
+		if err != nil {
+			return nil, err
+		}
+		if cond(pod) {
+			return pod, nil
+		}
+		if time.Now().After(deadline) {
+			return pod, fmt.Errorf("timeout waiting for pod %q, current phase is %q", name, pod.Status.Phase)
+		}
+		time.Sleep(podPollInterval)
+	}
+}
+
+func podStarted(p *pod) bool {
+	return p.Status.Phase != podPending
+}
+
+func podFinished(p *pod) bool {
+	return p.Status.Phase == podSucceeded || p.Status.Phase == podFailed
+}
+
+// runPod creates the pod and waits for it to finish, streaming the logs of
+// its containers to w. The input is sent to the stdin of the first container
+// of the pod when not nil.
+func runPod(client *clusterClient, p *pod, stdin io.Reader, w io.Writer) (*pod, error) {
+	name := p.Metadata.Name
+	err := client.deletePod(name)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	_, err = client.createPod(p)
+	if err != nil {
+		return nil, err
+	}
+	timeout := deployTimeout()
+	_, err = waitForPod(client, name, timeout, podStarted)
+	if err != nil {
+		return nil, err
+	}
+	if stdin != nil {
+		err = client.attach(name, streamOptions{
+			container:  p.Spec.Containers[0].Name,
+			stdin:      stdin,
+			closeStdin: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range p.Spec.Containers {
+		err = client.podLogs(name, c.Name, true, w)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return waitForPod(client, name, timeout, podFinished)
+}
+
+func podFailure(p *pod) error {
+	for _, cs := range p.Status.ContainerStatuses {
+		if t := cs.State.Terminated; t != nil && t.ExitCode != 0 {
+			return fmt.Errorf("container %q of pod %q exited with status %d", cs.Name, p.Metadata.Name, t.ExitCode)
+		}
+	}
+	return fmt.Errorf("pod %q failed", p.Metadata.Name)
+}
+
+// build runs the deploy commands in a pod created from the platform image,
+// committing the result as a new image of the app.
+func (p *kubernetesProvisioner) build(a provision.App, cmds []string, stdin io.Reader, w io.Writer) (string, error) {
+	defer provision.ObserveOperation(provisionerName, "build", time.Now())
+	client, err := newClusterClient()
+	if err != nil {
+		return "", err
+	}
+	imageId, err := appNewImageName(a.GetName())
+	if err != nil {
+		return "", err
+	}
+	buildPod := newBuildPod(a, platformImageName(a.GetPlatform()), imageId, cmds, stdin != nil)
+	fmt.Fprintln(w, "---- Building application image ----")
+	result, err := runPod(client, buildPod, stdin, w)
+	defer client.deletePod(buildPod.Metadata.Name)
+	if err != nil {
+		return "", err
+	}
+	if result.Status.Phase == podFailed {
+		return "", podFailure(result)
+	}
+	return imageId, nil
+}
+
+func (p *kubernetesProvisioner) ArchiveDeploy(a provision.App, archiveURL string, w io.Writer) (string, error) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	cmds, err := deployCmds(a, "archive", archiveURL)
+	if err != nil {
+		return "", err
+	}
+	imageId, err := p.build(a, cmds, nil, w)
+	if err != nil {
+		return "", err
+	}
+	return imageId, p.deploy(a, imageId, w)
+}
+
+func (p *kubernetesProvisioner) UploadDeploy(a provision.App, archiveFile io.ReadCloser, fileSize int64, build bool, w io.Writer) (string, error) {
+	defer archiveFile.Close()
+	if build {
+		return "", errors.New("running UploadDeploy with build=true is not yet supported")
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	cmds, err := deployCmds(a, "archive", "file://"+uploadArchivePath)
+	if err != nil {
+		return "", err
+	}
+	cmds[2] = fmt.Sprintf("cat >%s && %s", uploadArchivePath, cmds[2])
+	imageId, err := p.build(a, cmds, archiveFile, w)
+	if err != nil {
+		return "", err
+	}
+	return imageId, p.deploy(a, imageId, w)
+}
+
+func getProcessesFromProcfile(strProcfile string) map[string]string {
+	processes := map[string]string{}
+	for _, line := range strings.Split(strProcfile, "\n") {
+		if p := procfileRegex.FindStringSubmatch(line); p != nil {
+			processes[p[1]] = strings.Trim(p[2], " ")
+		}
+	}
+	return processes
+}
+
+// ImageDeploy deploys the app using an image from an external registry,
+// reading the processes from the Procfile inside the image.
+func (p *kubernetesProvisioner) ImageDeploy(a provision.App, imageId string, w io.Writer) (string, error) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	if !strings.Contains(imageId, ":") {
+		imageId = fmt.Sprintf("%s:latest", imageId)
+	}
+	client, err := newClusterClient()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(w, "---- Getting process from image ----")
+	inspectPod := &pod{
+		Metadata: objectMeta{
+			Name: buildPodName(a.GetName()),
+			Labels: map[string]string{
+				labelIsTsuru: "true",
+				labelIsBuild: "true",
+				labelAppName: a.GetName(),
+				labelAppPool: a.GetPool(),
+			},
+		},
+		Spec: podSpec{
+			RestartPolicy: "Never",
+			Containers: []container{{
+				Name:    buildContainer,
+				Image:   imageId,
+				Command: []string{"/bin/sh", "-c", "cat /home/application/current/Procfile || cat /app/user/Procfile || cat /Procfile"},
+			}},
+		},
+	}
+	var output bytes.Buffer
+	_, err = runPod(client, inspectPod, nil, &output)
+	client.deletePod(inspectPod.Metadata.Name)
+	if err != nil {
+		return "", err
+	}
+	procfile := getProcessesFromProcfile(output.String())
+	if len(procfile) == 0 {
+		return "", errProcfileNotFound
+	}
+	processes := make(map[string]interface{}, len(procfile))
+	for name, cmd := range procfile {
+		fmt.Fprintf(w, "  ---> Process %s found with command: %v\n", name, cmd)
+		processes[name] = cmd
+	}
+	err = saveImageCustomData(imageId, map[string]interface{}{"processes": processes})
+	if err != nil {
+		return "", err
+	}
+	return imageId, p.deploy(a, imageId, w)
+}
+
+func processCommand(cmd string, yamlData provision.TsuruYamlData) []string {
+	before := strings.Join(yamlData.Hooks.Restart.Before, " && ")
+	if before != "" {
+		before += " && "
+	}
+	return []string{
+		"/bin/sh",
+		"-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; " + before + "exec " + cmd,
+	}
+}
+
+// appEnvs returns the envs of the units of a process. Secret references are
+// resolved here, so their values end up in plain text in the Deployment spec.
+func appEnvs(a provision.App, process string) ([]envVar, error) {
+	appEnvs, err := provision.AppEnvs(a)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(appEnvs))
+	for name := range appEnvs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	envs := make([]envVar, 0, len(names)+4)
+	for _, name := range names {
+		value, err := secret.Resolve(appEnvs[name].Value, a.GetTeamOwner())
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve env %q: %s", name, err)
+		}
+		envs = append(envs, envVar{Name: name, Value: value})
+	}
+	host, _ := config.GetString("host")
+	port := strconv.Itoa(appPort())
+	return append(envs,
+		envVar{Name: "TSURU_PROCESSNAME", Value: process},
+		envVar{Name: "TSURU_HOST", Value: host},
+		envVar{Name: "port", Value: port},
+		envVar{Name: "PORT", Value: port},
+	), nil
+}
+
+func newDeployment(a provision.App, process, imageId string, data imageMetadata, replicas int) (*deployment, error) {
+	yamlData, err := getImageTsuruYamlData(imageId)
+	if err != nil {
+		return nil, err
+	}
+	name := deploymentName(a.GetName(), process)
+	labels := processSelector(a.GetName(), process)
+	labels[labelIsTsuru] = "true"
+	labels[labelAppPool] = a.GetPool()
+	port := appPort()
+	envs, err := appEnvs(a, process)
+	if err != nil {
+		return nil, err
+	}
+	c := container{
+		Name:    name,
+		Image:   imageId,
+		Command: processCommand(data.Processes[process], yamlData),
+		Env:     envs,
+		Ports:   []containerPort{{ContainerPort: port}},
+	}
+	if memory := a.GetMemory(); memory > 0 {
+		c.Resources = &resourceRequirements{
+			Limits: map[string]string{"memory": strconv.FormatInt(memory, 10)},
+		}
+	}
+	hc := yamlData.Healthcheck
+	method := strings.ToUpper(hc.Method)
+	if hc.Path != "" && (method == "" || method == "GET") && process == webProcessName(data) {
+		c.ReadinessProbe = &probe{
+			HTTPGet:          &httpGetAction{Path: "/" + strings.TrimLeft(hc.Path, "/"), Port: port},
+			FailureThreshold: hc.AllowedFailures + 1,
+		}
+	}
+	return &deployment{
+		Metadata: objectMeta{Name: name, Labels: labels},
+		Spec: deploymentSpec{
+			Replicas: replicas,
+			Selector: &labelSelector{MatchLabels: processSelector(a.GetName(), process)},
+			Template: podTemplateSpec{
+				Metadata: objectMeta{Labels: labels},
+				Spec: podSpec{
+					Containers:    []container{c},
+					RestartPolicy: "Always",
+				},
+			},
+		},
+	}, nil
+}
+
+func deploymentReady(dep *deployment) bool {
+	status := dep.Status
+	return status.ObservedGeneration >= dep.Metadata.Generation &&
+		status.UpdatedReplicas == dep.Spec.Replicas &&
+		status.AvailableReplicas == dep.Spec.Replicas &&
+		status.Replicas == dep.Spec.Replicas
+}
+
+// waitForDeployment waits until all the replicas of the deployment are
+// updated and available, reporting the progress to w.
+func waitForDeployment(client *clusterClient, name string, w io.Writer) error {
+	deadline := time.Now().Add(deployTimeout())
+	lastAvailable := -1
+	for {
+		dep, err := client.getDeployment(name)
+		if err != nil {
+			return err
+		}
+		if deploymentReady(dep) {
+			return nil
+		}
+		if available := dep.Status.AvailableReplicas; available != lastAvailable {
+			fmt.Fprintf(w, "  ---> %d of %d units of %s ready\n", available, dep.Spec.Replicas, name)
+			lastAvailable = available
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for deployment %q to be ready", name)
+		}
+		time.Sleep(podPollInterval)
+	}
+}
+
+// deploy updates the deployments of the app to run the given image, creating
+// one unit for each new process, and removing the deployments of processes
+// that are not declared in the image anymore.
+func (p *kubernetesProvisioner) deploy(a provision.App, imageId string, w io.Writer) error {
+	defer provision.ObserveOperation(provisionerName, "deploy", time.Now())
+	if w == nil {
+		w = ioutil.Discard
+	}
+	data, err := getImageMetadata(imageId)
+	if err != nil {
+		return err
+	}
+	if len(data.Processes) == 0 {
+		return errNoProcesses
+	}
+	client, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	deps, err := client.listDeployments(appSelector(a.GetName()))
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*deployment, len(deps))
+	for i := range deps {
+		existing[deps[i].Metadata.Name] = &deps[i]
+	}
+	processes := make([]string, 0, len(data.Processes))
+	for process := range data.Processes {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+	replicas := make(map[string]int, len(processes))
+	var total int
+	for _, process := range processes {
+		replicas[process] = 1
+		if dep, ok := existing[deploymentName(a.GetName(), process)]; ok {
+			replicas[process] = dep.Spec.Replicas
+		}
+		total += replicas[process]
+	}
+	err = a.SetQuotaInUse(total)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n---- Updating units [%s] ----\n", strings.Join(processes, ", "))
+	for _, process := range processes {
+		var dep *deployment
+		dep, err = newDeployment(a, process, imageId, data, replicas[process])
+		if err != nil {
+			return err
+		}
+		if old, ok := existing[dep.Metadata.Name]; ok {
+			dep.Metadata.Annotations = old.Metadata.Annotations
+			_, err = client.updateDeployment(dep)
+		} else {
+			_, err = client.createDeployment(dep)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, process := range processes {
+		err = waitForDeployment(client, deploymentName(a.GetName(), process), w)
+		if err != nil {
+			return err
+		}
+	}
+	for _, dep := range deps {
+		if _, ok := data.Processes[dep.Metadata.Labels[labelAppProcess]]; ok {
+			continue
+		}
+		fmt.Fprintf(w, "\n---- Removing units of process %s ----\n", dep.Metadata.Labels[labelAppProcess])
+		err = client.deleteDeployment(dep.Metadata.Name)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	err = appendAppImageName(a.GetName(), imageId)
+	if err != nil {
+		return err
+	}
+	rebuildRoutes(a.GetName())
+	return nil
+}
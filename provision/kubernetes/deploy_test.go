@@ -0,0 +1,240 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+// registerBuild makes the fake server act as tsuru_unit_agent running inside
+// the build pod, registering the given procfile when the build finishes.
+func (s *S) registerBuild(c *check.C, procfile string) {
+	s.server.onFinish = func(p *pod) {
+		if p.Metadata.Labels[labelIsBuild] != "true" {
+			return
+		}
+		err := s.p.RegisterUnit(provision.Unit{ID: p.Metadata.Name}, map[string]interface{}{
+			"procfile": procfile,
+		})
+		c.Check(err, check.IsNil)
+	}
+}
+
+func (s *S) TestArchiveDeploy(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.registerBuild(c, "web: python app.py\nworker: python worker.py")
+	buf := bytes.Buffer{}
+	imageId, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageId, check.Equals, "registry.tsuru.io/tsuru/app-myapp:v1")
+	c.Assert(buf.String(), check.Matches, "(?s).*---- Building application image ----.*---- Updating units \\[web, worker\\] ----.*")
+	c.Assert(s.server.getPod(buildPodName("myapp")), check.IsNil)
+	web := s.server.getDeployment("myapp-web")
+	c.Assert(web, check.NotNil)
+	c.Assert(web.Spec.Replicas, check.Equals, 1)
+	c.Assert(web.Spec.Template.Spec.Containers[0].Image, check.Equals, imageId)
+	c.Assert(s.server.getDeployment("myapp-worker"), check.NotNil)
+	images, err := s.p.ValidAppImages(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(images, check.DeepEquals, []string{imageId})
+	units, err := s.p.RoutableUnits(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, units[0].Address.String()), check.Equals, true)
+}
+
+func (s *S) TestArchiveDeployBuildPod(c *check.C) {
+	a := s.newApp(c, "myapp")
+	var buildPod *pod
+	s.server.onFinish = func(p *pod) {
+		buildPod = p
+		s.p.RegisterUnit(provision.Unit{ID: p.Metadata.Name}, map[string]interface{}{
+			"procfile": "web: python app.py",
+		})
+	}
+	_, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(buildPod, check.NotNil)
+	c.Assert(buildPod.Metadata.Name, check.Equals, "myapp-build")
+	c.Assert(buildPod.Metadata.Annotations[annotationBuildImage], check.Equals, "registry.tsuru.io/tsuru/app-myapp:v1")
+	c.Assert(buildPod.Spec.RestartPolicy, check.Equals, "Never")
+	c.Assert(buildPod.Spec.Containers, check.HasLen, 2)
+	build := buildPod.Spec.Containers[0]
+	c.Assert(build.Name, check.Equals, buildContainer)
+	c.Assert(build.Image, check.Equals, "registry.tsuru.io/tsuru/python:latest")
+	c.Assert(build.Command, check.HasLen, 3)
+	c.Assert(build.Command[2], check.Matches, ".*tsuru_unit_agent http://tsuru.io:8080 abc123 myapp \"/var/lib/tsuru/deploy archive http://server/myfile.tgz\" deploy")
+	agent := buildPod.Spec.Containers[1]
+	c.Assert(agent.Name, check.Equals, deployAgentContainer)
+	c.Assert(agent.Command[2], check.Matches, "(?s).*docker commit .* registry.tsuru.io/tsuru/app-myapp:v1.*docker push registry.tsuru.io/tsuru/app-myapp:v1.*")
+}
+
+func (s *S) TestArchiveDeployBuildFailure(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.server.failBuild = true
+	_, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", nil)
+	c.Assert(err, check.ErrorMatches, `container "build" of pod "myapp-build" exited with status 1`)
+	c.Assert(s.server.deployments, check.HasLen, 0)
+	c.Assert(s.server.getPod(buildPodName("myapp")), check.IsNil)
+}
+
+func (s *S) TestArchiveDeployWithoutProcesses(c *check.C) {
+	a := s.newApp(c, "myapp")
+	_, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", nil)
+	c.Assert(err, check.Equals, errNoProcesses)
+}
+
+func (s *S) TestUploadDeploy(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.registerBuild(c, "web: python app.py")
+	archive := ioutil.NopCloser(strings.NewReader("my archive data"))
+	imageId, err := s.p.UploadDeploy(a, archive, 15, false, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageId, check.Equals, "registry.tsuru.io/tsuru/app-myapp:v1")
+	c.Assert(s.server.stdin["myapp-build"], check.Equals, "my archive data")
+	c.Assert(s.server.getDeployment("myapp-web"), check.NotNil)
+}
+
+func (s *S) TestUploadDeployBuild(c *check.C) {
+	a := s.newApp(c, "myapp")
+	archive := ioutil.NopCloser(strings.NewReader("my archive data"))
+	_, err := s.p.UploadDeploy(a, archive, 15, true, nil)
+	c.Assert(err, check.ErrorMatches, "running UploadDeploy with build=true is not yet supported")
+}
+
+func (s *S) TestImageDeploy(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.server.logs[buildContainer] = "web: python app.py\nworker: python worker.py\n"
+	buf := bytes.Buffer{}
+	imageId, err := s.p.ImageDeploy(a, "myregistry/myimage", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageId, check.Equals, "myregistry/myimage:latest")
+	c.Assert(buf.String(), check.Matches, "(?s).*Process web found with command: python app.py.*")
+	data, err := getImageMetadata(imageId)
+	c.Assert(err, check.IsNil)
+	c.Assert(data.Processes, check.DeepEquals, map[string]string{
+		"web":    "python app.py",
+		"worker": "python worker.py",
+	})
+	web := s.server.getDeployment("myapp-web")
+	c.Assert(web, check.NotNil)
+	c.Assert(web.Spec.Template.Spec.Containers[0].Image, check.Equals, imageId)
+	c.Assert(s.server.getDeployment("myapp-worker"), check.NotNil)
+}
+
+func (s *S) TestImageDeployProcfileNotFound(c *check.C) {
+	a := s.newApp(c, "myapp")
+	_, err := s.p.ImageDeploy(a, "myregistry/myimage", nil)
+	c.Assert(err, check.Equals, errProcfileNotFound)
+	c.Assert(s.server.deployments, check.HasLen, 0)
+}
+
+func (s *S) TestDeployUpdatesProcesses(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py", "worker": "python worker.py"})
+	_, err := s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = saveImageCustomData("myimg:v2", map[string]interface{}{
+		"processes": map[string]interface{}{"web": "python app2.py", "clock": "python clock.py"},
+	})
+	c.Assert(err, check.IsNil)
+	buf := bytes.Buffer{}
+	err = s.p.deploy(a, "myimg:v2", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, "(?s).*---- Updating units \\[clock, web\\] ----.*---- Removing units of process worker ----.*")
+	c.Assert(s.server.getDeployment("myapp-worker"), check.IsNil)
+	web := s.server.getDeployment("myapp-web")
+	c.Assert(web.Spec.Replicas, check.Equals, 3)
+	c.Assert(web.Spec.Template.Spec.Containers[0].Image, check.Equals, "myimg:v2")
+	clock := s.server.getDeployment("myapp-clock")
+	c.Assert(clock.Spec.Replicas, check.Equals, 1)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 4)
+	for _, u := range units {
+		c.Assert(u.ProcessName, check.Not(check.Equals), "worker")
+	}
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Quota.InUse, check.Equals, 4)
+}
+
+func (s *S) TestDeployKeepsStoppedProcess(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	err := s.p.Stop(a, "")
+	c.Assert(err, check.IsNil)
+	s.deployApp(c, a, "myimg:v2", map[string]interface{}{"web": "python app.py"})
+	web := s.server.getDeployment("myapp-web")
+	c.Assert(web.Spec.Replicas, check.Equals, 0)
+	c.Assert(web.Metadata.Annotations[annotationStoppedReplicas], check.Equals, "1")
+}
+
+func (s *S) TestNewDeployment(c *check.C) {
+	a := s.newApp(c, "myapp")
+	a.Plan.Memory = 1024
+	err := saveImageCustomData("myimg:v1", map[string]interface{}{
+		"processes": map[string]interface{}{"web": "python app.py"},
+		"hooks": map[string]interface{}{
+			"restart": map[string]interface{}{"before": []string{"cmd1", "cmd2"}},
+		},
+		"healthcheck": map[string]interface{}{"path": "status", "allowed_failures": 2},
+	})
+	c.Assert(err, check.IsNil)
+	data, err := getImageMetadata("myimg:v1")
+	c.Assert(err, check.IsNil)
+	dep, err := newDeployment(a, "web", "myimg:v1", data, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Metadata.Name, check.Equals, "myapp-web")
+	c.Assert(dep.Spec.Replicas, check.Equals, 2)
+	c.Assert(dep.Spec.Selector.MatchLabels, check.DeepEquals, map[string]string{
+		labelAppName:    "myapp",
+		labelAppProcess: "web",
+		labelIsBuild:    "false",
+	})
+	c.Assert(dep.Spec.Template.Metadata.Labels[labelAppPool], check.Equals, "mypool")
+	cont := dep.Spec.Template.Spec.Containers[0]
+	c.Assert(cont.Command, check.DeepEquals, []string{
+		"/bin/sh", "-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; cmd1 && cmd2 && exec python app.py",
+	})
+	c.Assert(cont.Env, check.DeepEquals, []envVar{
+		{Name: "TSURU_APP_TOKEN", Value: "abc123"},
+		{Name: "TSURU_PROCESSNAME", Value: "web"},
+		{Name: "TSURU_HOST", Value: "http://tsuru.io:8080"},
+		{Name: "port", Value: "8888"},
+		{Name: "PORT", Value: "8888"},
+	})
+	c.Assert(cont.Ports, check.DeepEquals, []containerPort{{ContainerPort: 8888}})
+	c.Assert(cont.Resources.Limits, check.DeepEquals, map[string]string{"memory": "1024"})
+	c.Assert(cont.ReadinessProbe, check.DeepEquals, &probe{
+		HTTPGet:          &httpGetAction{Path: "/status", Port: 8888},
+		FailureThreshold: 3,
+	})
+}
+
+func (s *S) TestNewDeploymentNoHealthcheckForOtherProcesses(c *check.C) {
+	a := s.newApp(c, "myapp")
+	err := saveImageCustomData("myimg:v1", map[string]interface{}{
+		"processes":   map[string]interface{}{"web": "python app.py", "worker": "python worker.py"},
+		"healthcheck": map[string]interface{}{"path": "/status", "method": "POST"},
+	})
+	c.Assert(err, check.IsNil)
+	data, err := getImageMetadata("myimg:v1")
+	c.Assert(err, check.IsNil)
+	dep, err := newDeployment(a, "worker", "myimg:v1", data, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].ReadinessProbe, check.IsNil)
+	dep, err = newDeployment(a, "web", "myimg:v1", data, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].ReadinessProbe, check.IsNil)
+}
@@ -0,0 +1,170 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// Channels of the channel.k8s.io streaming protocol, used by the exec and
+// attach subresources of pods. Each message starts with the byte identifying
+// its channel.
+const (
+	stdinChannel byte = iota
+	stdoutChannel
+	stderrChannel
+	errorChannel
+	resizeChannel
+)
+
+const streamProtocol = "channel.k8s.io"
+
+type streamOptions struct {
+	container string
+	command   []string
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+	tty       bool
+	width     int
+	height    int
+	// closeStdin closes the connection as soon as stdin is fully sent,
+	// which is the only way to send an EOF to containers attached with
+	// stdinOnce.
+	closeStdin bool
+}
+
+type execError struct {
+	message string
+}
+
+func (e *execError) Error() string {
+	return e.message
+}
+
+// exec runs the command in the given container of the pod, streaming its
+// input and output.
+func (c *clusterClient) exec(podName string, opts streamOptions) error {
+	return c.stream(podName, "exec", opts)
+}
+
+// attach attaches to the main process of the given container of the pod.
+func (c *clusterClient) attach(podName string, opts streamOptions) error {
+	return c.stream(podName, "attach", opts)
+}
+
+func (c *clusterClient) stream(podName, subresource string, opts streamOptions) error {
+	query := url.Values{}
+	query.Set("container", opts.container)
+	for _, arg := range opts.command {
+		query.Add("command", arg)
+	}
+	if opts.stdin != nil {
+		query.Set("stdin", "true")
+	}
+	if opts.stdout != nil {
+		query.Set("stdout", "true")
+	}
+	if opts.stderr != nil && !opts.tty {
+		query.Set("stderr", "true")
+	}
+	if opts.tty {
+		query.Set("tty", "true")
+	}
+	location := c.addr + c.podsPath(podName) + "/" + subresource + "?" + query.Encode()
+	location = "ws" + strings.TrimPrefix(location, "http")
+	cfg, err := websocket.NewConfig(location, c.addr)
+	if err != nil {
+		return err
+	}
+	cfg.Protocol = []string{streamProtocol}
+	cfg.TlsConfig = c.tlsConfig
+	cfg.Header = http.Header{}
+	if c.token != "" {
+		cfg.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	ws, err := websocket.NewClient(cfg, conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer ws.Close()
+	if opts.tty && opts.width > 0 && opts.height > 0 {
+		size, _ := json.Marshal(map[string]int{"Width": opts.width, "Height": opts.height})
+		err = websocket.Message.Send(ws, append([]byte{resizeChannel}, size...))
+		if err != nil {
+			return err
+		}
+	}
+	if opts.stdin != nil {
+		if opts.closeStdin {
+			return sendStdin(ws, opts.stdin)
+		}
+		go sendStdin(ws, opts.stdin)
+	}
+	stdout, stderr := opts.stdout, opts.stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	var errMsg []byte
+	for {
+		var data []byte
+		err = websocket.Message.Receive(ws, &data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(data) < 2 {
+			continue
+		}
+		switch data[0] {
+		case stdoutChannel:
+			stdout.Write(data[1:])
+		case stderrChannel:
+			stderr.Write(data[1:])
+		case errorChannel:
+			errMsg = append(errMsg, data[1:]...)
+		}
+	}
+	if len(errMsg) > 0 {
+		return &execError{message: fmt.Sprintf("error running command in pod %q: %s", podName, errMsg)}
+	}
+	return nil
+}
+
+func sendStdin(ws *websocket.Conn, r io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if sendErr := websocket.Message.Send(ws, append([]byte{stdinChannel}, buf[:n]...)); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,410 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+type fakeExec struct {
+	pod       string
+	container string
+	command   []string
+	stdin     string
+}
+
+// fakeServer is a fake Kubernetes API server, keeping deployments and pods in
+// memory. Deployments are rolled out as soon as they're changed, and pods
+// with restartPolicy=Never finish as soon as they're started, or once their
+// stdin is closed.
+type fakeServer struct {
+	mu          sync.Mutex
+	server      *httptest.Server
+	deployments map[string]*deployment
+	pods        map[string]*pod
+	counter     int
+	execs       []fakeExec
+	stdin       map[string]string
+	logs        map[string]string
+	authHeaders []string
+	execOutput  string
+	execError   string
+	failBuild   bool
+	onFinish    func(p *pod)
+}
+
+func newFakeServer() *fakeServer {
+	s := &fakeServer{
+		deployments: make(map[string]*deployment),
+		pods:        make(map[string]*pod),
+		stdin:       make(map[string]string),
+		logs:        make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(deploymentsAPIPrefix+"/namespaces/", s.handleDeployments)
+	mux.HandleFunc(coreAPIPrefix+"/namespaces/", s.handlePods)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *fakeServer) Close() {
+	s.server.Close()
+}
+
+func (s *fakeServer) URL() string {
+	return s.server.URL
+}
+
+func (s *fakeServer) getPod(name string) *pod {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pods[name]; ok {
+		result := *p
+		return &result
+	}
+	return nil
+}
+
+func (s *fakeServer) getDeployment(name string) *deployment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.deployments[name]; ok {
+		result := *d
+		return &result
+	}
+	return nil
+}
+
+func writeStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(apiStatus{Kind: "Status", Status: "Failure", Message: message, Code: code})
+}
+
+func writeObject(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
+
+func parseSelector(selector string) map[string]string {
+	labels := map[string]string{}
+	for _, part := range strings.Split(selector, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			labels[kv[0]] = kv[1]
+		}
+	}
+	return labels
+}
+
+func matchLabels(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPath returns the name of the object and the subresource, if any, from
+// paths like /<prefix>/namespaces/<ns>/<resource>/<name>/<subresource>.
+func splitPath(path, prefix string) (string, string) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, prefix), "/"), "/")
+	var name, subresource string
+	if len(parts) > 3 {
+		name = parts[3]
+	}
+	if len(parts) > 4 {
+		subresource = parts[4]
+	}
+	return name, subresource
+}
+
+func templateHash(template podTemplateSpec) string {
+	data, _ := json.Marshal(template)
+	h := fnv.New32a()
+	h.Write(data)
+	return fmt.Sprint(h.Sum32())
+}
+
+// rollout replaces the pods of the deployment not matching its current
+// template, and scales them to the number of replicas. It must be called with
+// the lock held.
+func (s *fakeServer) rollout(dep *deployment) {
+	hash := templateHash(dep.Spec.Template)
+	var current []string
+	for name, p := range s.pods {
+		if !matchLabels(dep.Spec.Selector.MatchLabels, p.Metadata.Labels) {
+			continue
+		}
+		if p.Metadata.Labels["pod-template-hash"] != hash {
+			delete(s.pods, name)
+			continue
+		}
+		current = append(current, name)
+	}
+	for i := len(current); i < dep.Spec.Replicas; i++ {
+		s.counter++
+		labels := map[string]string{"pod-template-hash": hash}
+		for k, v := range dep.Spec.Template.Metadata.Labels {
+			labels[k] = v
+		}
+		p := &pod{
+			Metadata: objectMeta{
+				Name:   fmt.Sprintf("%s-%s-%d", dep.Metadata.Name, hash, s.counter),
+				Labels: labels,
+			},
+			Spec: dep.Spec.Template.Spec,
+			Status: podStatus{
+				Phase:  podRunning,
+				HostIP: "192.168.99.1",
+				PodIP:  fmt.Sprintf("10.0.0.%d", s.counter),
+			},
+		}
+		for _, c := range p.Spec.Containers {
+			p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, containerStatus{
+				Name:  c.Name,
+				Ready: true,
+				State: containerState{Running: &containerStateRunning{}},
+			})
+		}
+		s.pods[p.Metadata.Name] = p
+	}
+	for i := dep.Spec.Replicas; i < len(current); i++ {
+		delete(s.pods, current[i])
+	}
+	dep.Status = deploymentStatus{
+		ObservedGeneration: dep.Metadata.Generation,
+		Replicas:           dep.Spec.Replicas,
+		UpdatedReplicas:    dep.Spec.Replicas,
+		AvailableReplicas:  dep.Spec.Replicas,
+	}
+}
+
+func (s *fakeServer) handleDeployments(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authHeaders = append(s.authHeaders, r.Header.Get("Authorization"))
+	name, _ := splitPath(r.URL.Path, deploymentsAPIPrefix)
+	switch {
+	case r.Method == "GET" && name == "":
+		selector := parseSelector(r.URL.Query().Get("labelSelector"))
+		list := deploymentList{Items: []deployment{}}
+		for _, dep := range s.deployments {
+			if matchLabels(selector, dep.Metadata.Labels) {
+				list.Items = append(list.Items, *dep)
+			}
+		}
+		writeObject(w, http.StatusOK, list)
+	case r.Method == "GET":
+		dep, ok := s.deployments[name]
+		if !ok {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("deployments %q not found", name))
+			return
+		}
+		writeObject(w, http.StatusOK, dep)
+	case r.Method == "POST":
+		var dep deployment
+		if err := json.NewDecoder(r.Body).Decode(&dep); err != nil {
+			writeStatus(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, ok := s.deployments[dep.Metadata.Name]; ok {
+			writeStatus(w, http.StatusConflict, fmt.Sprintf("deployments %q already exists", dep.Metadata.Name))
+			return
+		}
+		dep.Metadata.Generation = 1
+		s.rollout(&dep)
+		s.deployments[dep.Metadata.Name] = &dep
+		writeObject(w, http.StatusCreated, dep)
+	case r.Method == "PUT":
+		old, ok := s.deployments[name]
+		if !ok {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("deployments %q not found", name))
+			return
+		}
+		var dep deployment
+		if err := json.NewDecoder(r.Body).Decode(&dep); err != nil {
+			writeStatus(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		dep.Metadata.Generation = old.Metadata.Generation
+		if !reflect.DeepEqual(old.Spec, dep.Spec) {
+			dep.Metadata.Generation++
+		}
+		s.rollout(&dep)
+		s.deployments[name] = &dep
+		writeObject(w, http.StatusOK, dep)
+	case r.Method == "DELETE":
+		dep, ok := s.deployments[name]
+		if !ok {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("deployments %q not found", name))
+			return
+		}
+		for podName, p := range s.pods {
+			if matchLabels(dep.Spec.Selector.MatchLabels, p.Metadata.Labels) {
+				delete(s.pods, podName)
+			}
+		}
+		delete(s.deployments, name)
+		writeObject(w, http.StatusOK, apiStatus{Kind: "Status", Status: "Success"})
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *fakeServer) handlePods(w http.ResponseWriter, r *http.Request) {
+	name, subresource := splitPath(r.URL.Path, coreAPIPrefix)
+	switch subresource {
+	case "exec":
+		websocket.Server{Handler: func(ws *websocket.Conn) { s.handleExec(ws, name, r) }}.ServeHTTP(w, r)
+		return
+	case "attach":
+		websocket.Server{Handler: func(ws *websocket.Conn) { s.handleAttach(ws, name, r) }}.ServeHTTP(w, r)
+		return
+	}
+	s.mu.Lock()
+	s.authHeaders = append(s.authHeaders, r.Header.Get("Authorization"))
+	switch {
+	case subresource == "log":
+		defer s.mu.Unlock()
+		if _, ok := s.pods[name]; !ok {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("pods %q not found", name))
+			return
+		}
+		io.WriteString(w, s.logs[r.URL.Query().Get("container")])
+	case r.Method == "GET" && name == "":
+		defer s.mu.Unlock()
+		selector := parseSelector(r.URL.Query().Get("labelSelector"))
+		list := podList{Items: []pod{}}
+		for _, p := range s.pods {
+			if matchLabels(selector, p.Metadata.Labels) {
+				list.Items = append(list.Items, *p)
+			}
+		}
+		writeObject(w, http.StatusOK, list)
+	case r.Method == "GET":
+		defer s.mu.Unlock()
+		p, ok := s.pods[name]
+		if !ok {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("pods %q not found", name))
+			return
+		}
+		writeObject(w, http.StatusOK, p)
+	case r.Method == "POST":
+		var p pod
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			s.mu.Unlock()
+			writeStatus(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, ok := s.pods[p.Metadata.Name]; ok {
+			s.mu.Unlock()
+			writeStatus(w, http.StatusConflict, fmt.Sprintf("pods %q already exists", p.Metadata.Name))
+			return
+		}
+		s.counter++
+		p.Status = podStatus{Phase: podRunning, HostIP: "192.168.99.1", PodIP: fmt.Sprintf("10.0.0.%d", s.counter)}
+		s.pods[p.Metadata.Name] = &p
+		s.mu.Unlock()
+		if p.Spec.RestartPolicy == "Never" && !p.Spec.Containers[0].Stdin {
+			s.finishPod(p.Metadata.Name)
+		}
+		writeObject(w, http.StatusCreated, p)
+	case r.Method == "DELETE":
+		defer s.mu.Unlock()
+		if _, ok := s.pods[name]; !ok {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("pods %q not found", name))
+			return
+		}
+		delete(s.pods, name)
+		writeObject(w, http.StatusOK, apiStatus{Kind: "Status", Status: "Success"})
+	default:
+		s.mu.Unlock()
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// finishPod calls the onFinish hook, which may act as the containers of the
+// pod, and then marks the pod as finished.
+func (s *fakeServer) finishPod(name string) {
+	p := s.getPod(name)
+	if p == nil {
+		return
+	}
+	if s.onFinish != nil {
+		s.onFinish(p)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pods[name]
+	if !ok {
+		return
+	}
+	p.Status.Phase = podSucceeded
+	exitCode := 0
+	if s.failBuild {
+		p.Status.Phase = podFailed
+		exitCode = 1
+	}
+	p.Status.ContainerStatuses = nil
+	for _, c := range p.Spec.Containers {
+		p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, containerStatus{
+			Name:  c.Name,
+			State: containerState{Terminated: &containerStateTerminated{ExitCode: exitCode}},
+		})
+	}
+}
+
+func (s *fakeServer) handleExec(ws *websocket.Conn, podName string, r *http.Request) {
+	defer ws.Close()
+	query := r.URL.Query()
+	exec := fakeExec{pod: podName, container: query.Get("container"), command: query["command"]}
+	if query.Get("stdin") == "true" {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err == nil && len(data) > 0 && data[0] == stdinChannel {
+			exec.stdin = string(data[1:])
+		}
+	}
+	s.mu.Lock()
+	s.execs = append(s.execs, exec)
+	output, errMsg := s.execOutput, s.execError
+	s.mu.Unlock()
+	if output != "" {
+		websocket.Message.Send(ws, append([]byte{stdoutChannel}, output...))
+	}
+	if errMsg != "" {
+		websocket.Message.Send(ws, append([]byte{errorChannel}, errMsg...))
+	}
+}
+
+func (s *fakeServer) handleAttach(ws *websocket.Conn, podName string, r *http.Request) {
+	var stdin []byte
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			break
+		}
+		if len(data) > 0 && data[0] == stdinChannel {
+			stdin = append(stdin, data[1:]...)
+		}
+	}
+	ws.Close()
+	s.mu.Lock()
+	s.stdin[podName] = string(stdin)
+	s.mu.Unlock()
+	s.finishPod(podName)
+}
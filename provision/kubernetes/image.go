@@ -0,0 +1,269 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	dbStorage "github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/yaml.v1"
+)
+
+var errNoImagesAvailable = errors.New("no images available for app")
+
+type appImages struct {
+	AppName string `bson:"_id"`
+	Images  []string
+	Count   int
+}
+
+type imageMetadata struct {
+	Name       string `bson:"_id"`
+	CustomData map[string]interface{}
+	Processes  map[string]string
+}
+
+func appImagesColl() (*dbStorage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("kubernetes_app_image"), nil
+}
+
+func imageMetadataColl() (*dbStorage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("kubernetes_image_custom_data"), nil
+}
+
+// basicImageName returns the prefix of the images, sharing the registry and
+// repository namespace used by the docker provisioner, where platform images
+// are built.
+func basicImageName() string {
+	parts := make([]string, 0, 2)
+	registry, _ := config.GetString("docker:registry")
+	if registry != "" {
+		parts = append(parts, registry)
+	}
+	repoNamespace, _ := config.GetString("docker:repository-namespace")
+	if repoNamespace == "" {
+		repoNamespace = "tsuru"
+	}
+	parts = append(parts, repoNamespace)
+	return strings.Join(parts, "/")
+}
+
+func platformImageName(platformName string) string {
+	return fmt.Sprintf("%s/%s:latest", basicImageName(), platformName)
+}
+
+func appBasicImageName(appName string) string {
+	return fmt.Sprintf("%s/app-%s", basicImageName(), appName)
+}
+
+func appNewImageName(appName string) (string, error) {
+	coll, err := appImagesColl()
+	if err != nil {
+		return "", err
+	}
+	defer coll.Close()
+	var imgs appImages
+	dbChange := mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"count": 1}},
+		ReturnNew: true,
+		Upsert:    true,
+	}
+	_, err = coll.FindId(appName).Apply(dbChange, &imgs)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:v%d", appBasicImageName(appName), imgs.Count), nil
+}
+
+func appCurrentImageName(appName string) (string, error) {
+	coll, err := appImagesColl()
+	if err != nil {
+		return "", err
+	}
+	defer coll.Close()
+	var imgs appImages
+	err = coll.FindId(appName).One(&imgs)
+	if err != nil && err != mgo.ErrNotFound {
+		return "", err
+	}
+	if len(imgs.Images) == 0 {
+		return "", errNoImagesAvailable
+	}
+	return imgs.Images[len(imgs.Images)-1], nil
+}
+
+func appendAppImageName(appName, imageId string) error {
+	coll, err := appImagesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(appName, bson.M{"$pull": bson.M{"images": imageId}})
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpsertId(appName, bson.M{"$push": bson.M{"images": imageId}})
+	return err
+}
+
+func listValidAppImages(appName string) ([]string, error) {
+	coll, err := appImagesColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var imgs appImages
+	err = coll.FindId(appName).One(&imgs)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	historySize, _ := config.GetInt("docker:image-history-size")
+	if historySize == 0 {
+		historySize = 10
+	}
+	if len(imgs.Images) > historySize {
+		imgs.Images = imgs.Images[len(imgs.Images)-historySize:]
+	}
+	return imgs.Images, nil
+}
+
+func deleteAllAppImageNames(appName string) error {
+	coll, err := appImagesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	var imgs appImages
+	err = coll.FindId(appName).One(&imgs)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dataColl, err := imageMetadataColl()
+	if err != nil {
+		return err
+	}
+	defer dataColl.Close()
+	_, err = dataColl.RemoveAll(bson.M{"$or": []bson.M{
+		{"_id": bson.M{"$in": imgs.Images}},
+		{"_id": bson.RegEx{Pattern: appBasicImageName(appName) + `:v\d+$`}},
+	}})
+	if err != nil {
+		return err
+	}
+	return coll.RemoveId(appName)
+}
+
+// saveImageCustomData stores the data sent by the build of an image,
+// including its processes, declared either in the "processes" key or in a
+// Procfile.
+func saveImageCustomData(imageName string, customData map[string]interface{}) error {
+	var processes map[string]string
+	if data, ok := customData["processes"].(map[string]interface{}); ok {
+		processes = make(map[string]string, len(data))
+		for name, command := range data {
+			processes[name], _ = command.(string)
+		}
+		delete(customData, "processes")
+		delete(customData, "procfile")
+	}
+	if data, ok := customData["procfile"]; ok {
+		procfile, _ := data.(string)
+		err := yaml.Unmarshal([]byte(procfile), &processes)
+		if err != nil || len(processes) == 0 {
+			return errors.New("invalid Procfile")
+		}
+		delete(customData, "procfile")
+	}
+	coll, err := imageMetadataColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(imageName, imageMetadata{
+		Name:       imageName,
+		CustomData: customData,
+		Processes:  processes,
+	})
+	return err
+}
+
+func getImageMetadata(imageName string) (imageMetadata, error) {
+	coll, err := imageMetadataColl()
+	if err != nil {
+		return imageMetadata{}, err
+	}
+	defer coll.Close()
+	var data imageMetadata
+	err = coll.FindId(imageName).One(&data)
+	if err == mgo.ErrNotFound {
+		return data, nil
+	}
+	return data, err
+}
+
+func getImageTsuruYamlData(imageName string) (provision.TsuruYamlData, error) {
+	var customData struct {
+		Customdata provision.TsuruYamlData
+	}
+	coll, err := imageMetadataColl()
+	if err != nil {
+		return customData.Customdata, err
+	}
+	defer coll.Close()
+	err = coll.FindId(imageName).One(&customData)
+	if err == mgo.ErrNotFound {
+		return customData.Customdata, nil
+	}
+	return customData.Customdata, err
+}
+
+// webProcessName returns the name of the process that receives the traffic
+// of the app, which is the only process declared or the one named "web".
+func webProcessName(data imageMetadata) string {
+	if len(data.Processes) == 1 {
+		for name := range data.Processes {
+			return name
+		}
+	}
+	return "web"
+}
+
+// processName validates the name of the process against the processes of the
+// image, using the only process declared when the name is empty.
+func processName(data imageMetadata, name string) (string, error) {
+	if name == "" {
+		if len(data.Processes) > 1 {
+			return "", provision.InvalidProcessError{Msg: "no process name specified and more than one declared in Procfile"}
+		}
+		for processName := range data.Processes {
+			name = processName
+		}
+	}
+	if data.Processes[name] == "" {
+		return "", provision.InvalidProcessError{Msg: fmt.Sprintf("no command declared in Procfile for process %q", name)}
+	}
+	return name, nil
+}
@@ -0,0 +1,695 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package kubernetes provides a provisioner implementation that runs tsuru
+// apps in a Kubernetes cluster.
+//
+// Each process of an app is mapped to a Deployment, whose Pods are the units
+// of the app. Units are routed through their pod IPs, so the routers must be
+// able to reach the pod network of the cluster.
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	stdnet "net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+)
+
+const (
+	provisionerName = "kubernetes"
+
+	labelIsTsuru    = "tsuru.io/is-tsuru"
+	labelIsBuild    = "tsuru.io/is-build"
+	labelAppName    = "tsuru.io/app-name"
+	labelAppProcess = "tsuru.io/app-process"
+	labelAppPool    = "tsuru.io/app-pool"
+
+	annotationBuildImage      = "tsuru.io/build-image"
+	annotationStoppedReplicas = "tsuru.io/stopped-replicas"
+	annotationRestartedAt     = "tsuru.io/restarted-at"
+
+	defaultAppPort = 8888
+)
+
+var podPollInterval = time.Second
+
+func init() {
+	provision.Register(provisionerName, &kubernetesProvisioner{})
+}
+
+type kubernetesProvisioner struct{}
+
+func (p *kubernetesProvisioner) Initialize() error {
+	_, err := newClusterClient()
+	return err
+}
+
+func getRouterForApp(a provision.App) (router.Router, error) {
+	routers, err := a.GetRouters()
+	if err != nil {
+		return nil, err
+	}
+	return router.GetForApp(routers)
+}
+
+func rebuildRoutes(appName string) {
+	a, err := app.GetByName(appName)
+	if err != nil {
+		log.Errorf("[kubernetes] unable to get app %q to rebuild routes: %s", appName, err)
+		return
+	}
+	_, err = a.RebuildRoutes()
+	if err != nil {
+		log.Errorf("[kubernetes] unable to rebuild routes for app %q: %s", appName, err)
+	}
+}
+
+func appPort() int {
+	port, _ := config.GetInt("kubernetes:app-port")
+	if port == 0 {
+		port = defaultAppPort
+	}
+	return port
+}
+
+func deployTimeout() time.Duration {
+	timeout, _ := config.GetInt("kubernetes:deploy-timeout")
+	if timeout == 0 {
+		timeout = 600
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// maxNameLength is the maximum length of the names of deployments, which are
+// also used as the names of their containers.
+const maxNameLength = 63
+
+var (
+	validNameRegexp   = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	invalidNameRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// deploymentName returns the name of the deployment of the process of the
+// app. Process names may contain characters not allowed in Kubernetes names,
+// so, when needed, the name is sanitized and truncated, with a hash of the
+// original name appended to keep the names of different processes apart.
+func deploymentName(appName, process string) string {
+	name := fmt.Sprintf("%s-%s", appName, process)
+	if len(name) <= maxNameLength && validNameRegexp.MatchString(name) {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(sum[:])[:10]
+	base := invalidNameRegexp.ReplaceAllString(strings.ToLower(name), "-")
+	if maxBase := maxNameLength - len(suffix) - 1; len(base) > maxBase {
+		base = base[:maxBase]
+	}
+	return strings.Trim(base, "-") + "-" + suffix
+}
+
+func appSelector(appName string) map[string]string {
+	return map[string]string{labelAppName: appName}
+}
+
+func appUnitsSelector(appName string) map[string]string {
+	return map[string]string{labelAppName: appName, labelIsBuild: "false"}
+}
+
+func processSelector(appName, process string) map[string]string {
+	return map[string]string{labelAppName: appName, labelAppProcess: process, labelIsBuild: "false"}
+}
+
+func unitStatus(p *pod) provision.Status {
+	if p.Metadata.Labels[labelIsBuild] == "true" {
+		return provision.StatusBuilding
+	}
+	switch p.Status.Phase {
+	case podPending:
+		return provision.StatusCreated
+	case podRunning:
+		for _, cs := range p.Status.ContainerStatuses {
+			if !cs.Ready {
+				return provision.StatusStarting
+			}
+		}
+		return provision.StatusStarted
+	case podSucceeded:
+		return provision.StatusStopped
+	}
+	return provision.StatusError
+}
+
+func podToUnit(p *pod, a provision.App) provision.Unit {
+	unit := provision.Unit{
+		ID:          p.Metadata.Name,
+		Name:        p.Metadata.Name,
+		AppName:     a.GetName(),
+		ProcessName: p.Metadata.Labels[labelAppProcess],
+		Type:        a.GetPlatform(),
+		Ip:          p.Status.HostIP,
+		Status:      unitStatus(p),
+	}
+	if p.Status.PodIP != "" {
+		unit.Address = &url.URL{
+			Scheme: "http",
+			Host:   stdnet.JoinHostPort(p.Status.PodIP, strconv.Itoa(appPort())),
+		}
+	}
+	return unit
+}
+
+// Provision creates a route for the app.
+func (p *kubernetesProvisioner) Provision(a provision.App) error {
+	defer provision.ObserveOperation(provisionerName, "provision", time.Now())
+	r, err := getRouterForApp(a)
+	if err != nil {
+		return err
+	}
+	return r.AddBackend(a.GetName())
+}
+
+func (p *kubernetesProvisioner) Destroy(a provision.App) error {
+	defer provision.ObserveOperation(provisionerName, "destroy", time.Now())
+	client, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	deps, err := client.listDeployments(appSelector(a.GetName()))
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		err = client.deleteDeployment(dep.Metadata.Name)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	err = client.deletePod(buildPodName(a.GetName()))
+	if err != nil && !isNotFound(err) {
+		log.Errorf("[kubernetes] failed to remove build pod for app %q: %s", a.GetName(), err)
+	}
+	err = deleteAllAppImageNames(a.GetName())
+	if err != nil {
+		log.Errorf("[kubernetes] failed to remove image names from storage for app %q: %s", a.GetName(), err)
+	}
+	r, err := getRouterForApp(a)
+	if err != nil {
+		return err
+	}
+	return r.RemoveBackend(a.GetName())
+}
+
+func (p *kubernetesProvisioner) AddUnits(a provision.App, units uint, process string, w io.Writer) ([]provision.Unit, error) {
+	defer provision.ObserveOperation(provisionerName, "add-units", time.Now())
+	if a.GetDeploys() == 0 {
+		return nil, errors.New("New units can only be added after the first deployment")
+	}
+	if units == 0 {
+		return nil, errors.New("Cannot add 0 units")
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	imageId, err := appCurrentImageName(a.GetName())
+	if err != nil {
+		return nil, err
+	}
+	data, err := getImageMetadata(imageId)
+	if err != nil {
+		return nil, err
+	}
+	process, err = processName(data, process)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	before, err := client.listPods(processSelector(a.GetName(), process))
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(before))
+	for _, pod := range before {
+		existing[pod.Metadata.Name] = true
+	}
+	fmt.Fprintf(w, "\n---- Starting %d new %s [%s] ----\n", units, pluralize("unit", int(units)), process)
+	err = p.scaleProcess(client, a, process, imageId, data, int(units))
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.listPods(processSelector(a.GetName(), process))
+	if err != nil {
+		return nil, err
+	}
+	var result []provision.Unit
+	for i := range pods {
+		if !existing[pods[i].Metadata.Name] {
+			result = append(result, podToUnit(&pods[i], a))
+		}
+	}
+	return result, nil
+}
+
+func (p *kubernetesProvisioner) RemoveUnits(a provision.App, units uint, process string, w io.Writer) error {
+	defer provision.ObserveOperation(provisionerName, "remove-units", time.Now())
+	if a == nil {
+		return errors.New("remove units: app should not be nil")
+	}
+	if units == 0 {
+		return errors.New("cannot remove zero units")
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	imageId, err := appCurrentImageName(a.GetName())
+	if err != nil {
+		return err
+	}
+	data, err := getImageMetadata(imageId)
+	if err != nil {
+		return err
+	}
+	process, err = processName(data, process)
+	if err != nil {
+		return err
+	}
+	client, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	dep, err := client.getDeployment(deploymentName(a.GetName(), process))
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("cannot remove %d units from process %q, only 0 available", units, process)
+		}
+		return err
+	}
+	if dep.Spec.Replicas < int(units) {
+		return fmt.Errorf("cannot remove %d units from process %q, only %d available", units, process, dep.Spec.Replicas)
+	}
+	fmt.Fprintf(w, "\n---- Removing %d %s ----\n", units, pluralize("unit", int(units)))
+	return p.scaleProcess(client, a, process, imageId, data, -int(units))
+}
+
+// scaleProcess changes the number of replicas of the deployment of the given
+// process by delta, creating the deployment if it doesn't exist yet.
+func (p *kubernetesProvisioner) scaleProcess(client *clusterClient, a provision.App, process, imageId string, data imageMetadata, delta int) error {
+	name := deploymentName(a.GetName(), process)
+	dep, err := client.getDeployment(name)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	var current int
+	if dep != nil {
+		current = dep.Spec.Replicas
+	}
+	total, err := p.totalReplicas(client, a.GetName())
+	if err != nil {
+		return err
+	}
+	err = a.SetQuotaInUse(total + delta)
+	if err != nil {
+		return err
+	}
+	if dep == nil {
+		dep, err = newDeployment(a, process, imageId, data, delta)
+		if err != nil {
+			return err
+		}
+		_, err = client.createDeployment(dep)
+	} else {
+		dep.Spec.Replicas = current + delta
+		_, err = client.updateDeployment(dep)
+	}
+	if err != nil {
+		a.SetQuotaInUse(total)
+		return err
+	}
+	err = waitForDeployment(client, name, ioutil.Discard)
+	rebuildRoutes(a.GetName())
+	return err
+}
+
+func (p *kubernetesProvisioner) totalReplicas(client *clusterClient, appName string) (int, error) {
+	deps, err := client.listDeployments(appSelector(appName))
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	for _, dep := range deps {
+		total += dep.Spec.Replicas
+	}
+	return total, nil
+}
+
+// SetUnitStatus is a no-op, the status of the units is always taken from
+// their pods.
+func (p *kubernetesProvisioner) SetUnitStatus(unit provision.Unit, status provision.Status) error {
+	client, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	pod, err := client.getPod(unit.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return &provision.UnitNotFoundError{ID: unit.ID}
+		}
+		return err
+	}
+	if unit.AppName != "" && pod.Metadata.Labels[labelAppName] != unit.AppName {
+		return errors.New("wrong app name")
+	}
+	return nil
+}
+
+func (p *kubernetesProvisioner) runningPods(a provision.App) (*clusterClient, []pod, error) {
+	client, err := newClusterClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	pods, err := client.listPods(appUnitsSelector(a.GetName()))
+	if err != nil {
+		return nil, nil, err
+	}
+	running := make([]pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase == podRunning {
+			running = append(running, pod)
+		}
+	}
+	if len(running) == 0 {
+		return nil, nil, provision.ErrEmptyApp
+	}
+	return client, running, nil
+}
+
+func execInPod(client *clusterClient, pod *pod, stdout, stderr io.Writer, cmd string, args ...string) error {
+	command := append([]string{"/bin/bash", "-lc", cmd}, args...)
+	return client.exec(pod.Metadata.Name, streamOptions{
+		container: pod.Spec.Containers[0].Name,
+		command:   command,
+		stdout:    stdout,
+		stderr:    stderr,
+	})
+}
+
+func (p *kubernetesProvisioner) ExecuteCommand(stdout, stderr io.Writer, a provision.App, cmd string, args ...string) error {
+	client, pods, err := p.runningPods(a)
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		err = execInPod(client, &pods[i], stdout, stderr, cmd, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *kubernetesProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, a provision.App, cmd string, args ...string) error {
+	client, pods, err := p.runningPods(a)
+	if err != nil {
+		return err
+	}
+	return execInPod(client, &pods[0], stdout, stderr, cmd, args...)
+}
+
+// updateDeployments applies fn to every deployment of the app, or only to
+// the deployment of the given process, waiting for the changes to be rolled
+// out.
+func (p *kubernetesProvisioner) updateDeployments(a provision.App, process string, w io.Writer, fn func(*deployment)) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	client, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	var deps []deployment
+	if process == "" {
+		deps, err = client.listDeployments(appSelector(a.GetName()))
+	} else {
+		var dep *deployment
+		dep, err = client.getDeployment(deploymentName(a.GetName(), process))
+		if dep != nil {
+			deps = []deployment{*dep}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for i := range deps {
+		fn(&deps[i])
+		_, err = client.updateDeployment(&deps[i])
+		if err != nil {
+			return err
+		}
+	}
+	for _, dep := range deps {
+		err = waitForDeployment(client, dep.Metadata.Name, w)
+		if err != nil {
+			break
+		}
+	}
+	rebuildRoutes(a.GetName())
+	return err
+}
+
+func (p *kubernetesProvisioner) Restart(a provision.App, process string, w io.Writer) error {
+	defer provision.ObserveOperation(provisionerName, "restart", time.Now())
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	return p.updateDeployments(a, process, w, func(dep *deployment) {
+		if dep.Spec.Template.Metadata.Annotations == nil {
+			dep.Spec.Template.Metadata.Annotations = map[string]string{}
+		}
+		dep.Spec.Template.Metadata.Annotations[annotationRestartedAt] = now
+	})
+}
+
+func (p *kubernetesProvisioner) Start(a provision.App, process string) error {
+	defer provision.ObserveOperation(provisionerName, "start", time.Now())
+	return p.updateDeployments(a, process, nil, func(dep *deployment) {
+		stopped, ok := dep.Metadata.Annotations[annotationStoppedReplicas]
+		if !ok {
+			return
+		}
+		replicas, _ := strconv.Atoi(stopped)
+		dep.Spec.Replicas = replicas
+		delete(dep.Metadata.Annotations, annotationStoppedReplicas)
+	})
+}
+
+func (p *kubernetesProvisioner) Stop(a provision.App, process string) error {
+	defer provision.ObserveOperation(provisionerName, "stop", time.Now())
+	return p.updateDeployments(a, process, nil, func(dep *deployment) {
+		if _, ok := dep.Metadata.Annotations[annotationStoppedReplicas]; ok {
+			return
+		}
+		if dep.Metadata.Annotations == nil {
+			dep.Metadata.Annotations = map[string]string{}
+		}
+		dep.Metadata.Annotations[annotationStoppedReplicas] = strconv.Itoa(dep.Spec.Replicas)
+		dep.Spec.Replicas = 0
+	})
+}
+
+// Sleep stops the units of the app, as pods can't be paused.
+func (p *kubernetesProvisioner) Sleep(a provision.App, process string) error {
+	defer provision.ObserveOperation(provisionerName, "sleep", time.Now())
+	return p.Stop(a, process)
+}
+
+func (p *kubernetesProvisioner) Addr(a provision.App) (string, error) {
+	r, err := getRouterForApp(a)
+	if err != nil {
+		return "", err
+	}
+	return r.Addr(a.GetName())
+}
+
+func (p *kubernetesProvisioner) Swap(app1, app2 provision.App) error {
+	defer provision.ObserveOperation(provisionerName, "swap", time.Now())
+	r, err := getRouterForApp(app1)
+	if err != nil {
+		return err
+	}
+	return r.Swap(app1.GetName(), app2.GetName())
+}
+
+func (p *kubernetesProvisioner) Units(a provision.App) ([]provision.Unit, error) {
+	client, err := newClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.listPods(appSelector(a.GetName()))
+	if err != nil {
+		return nil, err
+	}
+	units := make([]provision.Unit, len(pods))
+	for i := range pods {
+		units[i] = podToUnit(&pods[i], a)
+	}
+	return units, nil
+}
+
+func (p *kubernetesProvisioner) RoutableUnits(a provision.App) ([]provision.Unit, error) {
+	imageId, err := appCurrentImageName(a.GetName())
+	if err != nil {
+		if err == errNoImagesAvailable {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := getImageMetadata(imageId)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.listPods(processSelector(a.GetName(), webProcessName(data)))
+	if err != nil {
+		return nil, err
+	}
+	units := make([]provision.Unit, 0, len(pods))
+	for i := range pods {
+		if pods[i].Status.Phase == podRunning && pods[i].Status.PodIP != "" {
+			units = append(units, podToUnit(&pods[i], a))
+		}
+	}
+	return units, nil
+}
+
+// RegisterUnit stores the custom data sent by the build of an image, the
+// status of the other units is taken from their pods.
+func (p *kubernetesProvisioner) RegisterUnit(unit provision.Unit, customData map[string]interface{}) error {
+	client, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	pod, err := client.getPod(unit.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return &provision.UnitNotFoundError{ID: unit.ID}
+		}
+		return err
+	}
+	if pod.Metadata.Labels[labelIsBuild] != "true" || customData == nil {
+		return nil
+	}
+	buildImage := pod.Metadata.Annotations[annotationBuildImage]
+	if buildImage == "" {
+		return nil
+	}
+	return saveImageCustomData(buildImage, customData)
+}
+
+func (p *kubernetesProvisioner) Shell(opts provision.ShellOptions) error {
+	client, err := newClusterClient()
+	if err != nil {
+		return err
+	}
+	var target *pod
+	if opts.Unit != "" {
+		target, err = client.getPod(opts.Unit)
+		if err != nil {
+			if isNotFound(err) {
+				return &provision.UnitNotFoundError{ID: opts.Unit}
+			}
+			return err
+		}
+		if target.Metadata.Labels[labelAppName] != opts.App.GetName() {
+			return &provision.UnitNotFoundError{ID: opts.Unit}
+		}
+	} else {
+		var pods []pod
+		_, pods, err = p.runningPods(opts.App)
+		if err != nil {
+			return err
+		}
+		target = &pods[0]
+	}
+	term := opts.Term
+	if term == "" {
+		term = "xterm"
+	}
+	return client.exec(target.Metadata.Name, streamOptions{
+		container: target.Spec.Containers[0].Name,
+		command:   []string{"/usr/bin/env", "TERM=" + term, "bash", "-l"},
+		stdin:     opts.Conn,
+		stdout:    opts.Conn,
+		stderr:    opts.Conn,
+		tty:       true,
+		width:     opts.Width,
+		height:    opts.Height,
+	})
+}
+
+func (p *kubernetesProvisioner) ValidAppImages(appName string) ([]string, error) {
+	return listValidAppImages(appName)
+}
+
+func (p *kubernetesProvisioner) ImageTsuruYamlData(image string) (provision.TsuruYamlData, error) {
+	return getImageTsuruYamlData(image)
+}
+
+func (p *kubernetesProvisioner) MetricEnvs(a provision.App) map[string]string {
+	return map[string]string{}
+}
+
+func (p *kubernetesProvisioner) Rollback(a provision.App, imageId string, w io.Writer) (string, error) {
+	return imageId, p.deploy(a, imageId, w)
+}
+
+func (p *kubernetesProvisioner) FilterAppsByUnitStatus(apps []provision.App, status []string) ([]provision.App, error) {
+	if apps == nil {
+		return nil, errors.New("apps must be provided to FilterAppsByUnitStatus")
+	}
+	result := make([]provision.App, 0)
+	if status == nil {
+		return result, nil
+	}
+	for _, a := range apps {
+		units, err := p.Units(a)
+		if err != nil {
+			return nil, err
+		}
+	unitsLoop:
+		for _, u := range units {
+			for _, s := range status {
+				if u.Status.String() == s {
+					result = append(result, a)
+					break unitsLoop
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func pluralize(str string, sz int) string {
+	if sz == 0 || sz > 1 {
+		str = str + "s"
+	}
+	return str
+}
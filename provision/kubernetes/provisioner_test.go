@@ -0,0 +1,352 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRegistered(c *check.C) {
+	p, err := provision.Get("kubernetes")
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.FitsTypeOf, &kubernetesProvisioner{})
+	var _ provision.ArchiveDeployer = s.p
+	var _ provision.UploadDeployer = s.p
+	var _ provision.ImageDeployer = s.p
+}
+
+func (s *S) TestDeploymentName(c *check.C) {
+	c.Assert(deploymentName("myapp", "web"), check.Equals, "myapp-web")
+	c.Assert(deploymentName("myapp", "worker-2"), check.Equals, "myapp-worker-2")
+	names := map[string]bool{}
+	for _, process := range []string{"web_1", "web-1", "Web-1", "-web", strings.Repeat("worker", 20), strings.Repeat("worker", 20) + "2"} {
+		name := deploymentName("myapp", process)
+		c.Check(len(name) <= 63, check.Equals, true, check.Commentf("name %q", name))
+		c.Check(name, check.Matches, `[a-z0-9]([-a-z0-9]*[a-z0-9])?`)
+		c.Check(names[name], check.Equals, false, check.Commentf("duplicated name %q", name))
+		names[name] = true
+	}
+	c.Assert(deploymentName("myapp", "web_1"), check.Matches, `myapp-web-1-[0-9a-f]{10}`)
+}
+
+func (s *S) TestProvision(c *check.C) {
+	a := s.newApp(c, "myapp")
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+}
+
+func (s *S) TestDestroy(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py", "worker": "python worker.py"})
+	err := s.p.Destroy(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.server.deployments, check.HasLen, 0)
+	c.Assert(s.server.pods, check.HasLen, 0)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, false)
+	images, err := s.p.ValidAppImages(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(images, check.HasLen, 0)
+}
+
+func (s *S) TestUnits(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py", "worker": "python worker.py"})
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	processes := []string{units[0].ProcessName, units[1].ProcessName}
+	c.Assert(processes, check.DeepEquals, []string{"web", "worker"})
+	for _, u := range units {
+		pod := s.server.getPod(u.ID)
+		c.Assert(pod, check.NotNil)
+		c.Assert(u.AppName, check.Equals, "myapp")
+		c.Assert(u.Type, check.Equals, "python")
+		c.Assert(u.Ip, check.Equals, "192.168.99.1")
+		c.Assert(u.Status, check.Equals, provision.StatusStarted)
+		c.Assert(u.Address.String(), check.Equals, "http://"+pod.Status.PodIP+":8888")
+	}
+}
+
+func (s *S) TestUnitsStatus(c *check.C) {
+	tests := []struct {
+		phase    string
+		ready    bool
+		build    bool
+		expected provision.Status
+	}{
+		{podPending, false, false, provision.StatusCreated},
+		{podRunning, false, false, provision.StatusStarting},
+		{podRunning, true, false, provision.StatusStarted},
+		{podSucceeded, false, false, provision.StatusStopped},
+		{podFailed, false, false, provision.StatusError},
+		{podRunning, true, true, provision.StatusBuilding},
+	}
+	for _, tt := range tests {
+		p := &pod{
+			Metadata: objectMeta{Labels: map[string]string{labelIsBuild: "false"}},
+			Status: podStatus{
+				Phase:             tt.phase,
+				ContainerStatuses: []containerStatus{{Ready: tt.ready}},
+			},
+		}
+		if tt.build {
+			p.Metadata.Labels[labelIsBuild] = "true"
+		}
+		c.Check(unitStatus(p), check.Equals, tt.expected)
+	}
+}
+
+func (s *S) TestRoutableUnits(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py", "worker": "python worker.py"})
+	units, err := s.p.RoutableUnits(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	c.Assert(units[0].ProcessName, check.Equals, "web")
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, units[0].Address.String()), check.Equals, true)
+}
+
+func (s *S) TestRoutableUnitsNotDeployed(c *check.C) {
+	a := s.newApp(c, "myapp")
+	units, err := s.p.RoutableUnits(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 0)
+}
+
+func (s *S) TestAddUnits(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py", "worker": "python worker.py"})
+	buf := bytes.Buffer{}
+	units, err := s.p.AddUnits(a, 2, "web", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	c.Assert(buf.String(), check.Matches, "(?s).*---- Starting 2 new units \\[web\\] ----.*")
+	dep := s.server.getDeployment("myapp-web")
+	c.Assert(dep.Spec.Replicas, check.Equals, 3)
+	allUnits, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(allUnits, check.HasLen, 4)
+	routes, err := routertest.FakeRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, 3)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Quota.InUse, check.Equals, 4)
+}
+
+func (s *S) TestAddUnitsWithoutDeploys(c *check.C) {
+	a := s.newApp(c, "myapp")
+	_, err := s.p.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.ErrorMatches, "New units can only be added after the first deployment")
+}
+
+func (s *S) TestAddUnitsInvalidProcess(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py", "worker": "python worker.py"})
+	_, err := s.p.AddUnits(a, 1, "", nil)
+	c.Assert(err, check.FitsTypeOf, provision.InvalidProcessError{})
+	_, err = s.p.AddUnits(a, 1, "other", nil)
+	c.Assert(err, check.FitsTypeOf, provision.InvalidProcessError{})
+}
+
+func (s *S) TestRemoveUnits(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	_, err := s.p.AddUnits(a, 2, "", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	dep := s.server.getDeployment("myapp-web")
+	c.Assert(dep.Spec.Replicas, check.Equals, 1)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	routes, err := routertest.FakeRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, 1)
+}
+
+func (s *S) TestRemoveUnitsTooMany(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	err := s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.ErrorMatches, `cannot remove 2 units from process "web", only 1 available`)
+}
+
+func (s *S) TestRestart(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	before, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.Restart(a, "", nil)
+	c.Assert(err, check.IsNil)
+	dep := s.server.getDeployment("myapp-web")
+	c.Assert(dep.Spec.Template.Metadata.Annotations[annotationRestartedAt], check.Not(check.Equals), "")
+	after, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(after, check.HasLen, 1)
+	c.Assert(after[0].ID, check.Not(check.Equals), before[0].ID)
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, after[0].Address.String()), check.Equals, true)
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, before[0].Address.String()), check.Equals, false)
+}
+
+func (s *S) TestStopStart(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py", "worker": "python worker.py"})
+	_, err := s.p.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.Stop(a, "web")
+	c.Assert(err, check.IsNil)
+	dep := s.server.getDeployment("myapp-web")
+	c.Assert(dep.Spec.Replicas, check.Equals, 0)
+	c.Assert(dep.Metadata.Annotations[annotationStoppedReplicas], check.Equals, "2")
+	c.Assert(s.server.getDeployment("myapp-worker").Spec.Replicas, check.Equals, 1)
+	err = s.p.Stop(a, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.server.getDeployment("myapp-web").Metadata.Annotations[annotationStoppedReplicas], check.Equals, "2")
+	err = s.p.Start(a, "")
+	c.Assert(err, check.IsNil)
+	dep = s.server.getDeployment("myapp-web")
+	c.Assert(dep.Spec.Replicas, check.Equals, 2)
+	_, ok := dep.Metadata.Annotations[annotationStoppedReplicas]
+	c.Assert(ok, check.Equals, false)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 3)
+}
+
+func (s *S) TestExecuteCommand(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	_, err := s.p.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	s.server.execOutput = "hello\n"
+	var stdout, stderr bytes.Buffer
+	err = s.p.ExecuteCommand(&stdout, &stderr, a, "ls", "-l")
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "hello\nhello\n")
+	c.Assert(s.server.execs, check.HasLen, 2)
+	for _, exec := range s.server.execs {
+		c.Assert(exec.container, check.Equals, "myapp-web")
+		c.Assert(exec.command, check.DeepEquals, []string{"/bin/bash", "-lc", "ls", "-l"})
+	}
+}
+
+func (s *S) TestExecuteCommandOnce(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	_, err := s.p.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	s.server.execOutput = "hello\n"
+	var stdout, stderr bytes.Buffer
+	err = s.p.ExecuteCommandOnce(&stdout, &stderr, a, "ls")
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "hello\n")
+	c.Assert(s.server.execs, check.HasLen, 1)
+}
+
+func (s *S) TestExecuteCommandError(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	s.server.execError = "command terminated with non-zero exit code"
+	err := s.p.ExecuteCommandOnce(nil, nil, a, "ls")
+	c.Assert(err, check.ErrorMatches, `error running command in pod ".*": command terminated with non-zero exit code`)
+}
+
+func (s *S) TestExecuteCommandWithoutUnits(c *check.C) {
+	a := s.newApp(c, "myapp")
+	err := s.p.ExecuteCommand(nil, nil, a, "ls")
+	c.Assert(err, check.Equals, provision.ErrEmptyApp)
+	err = s.p.ExecuteCommandOnce(nil, nil, a, "ls")
+	c.Assert(err, check.Equals, provision.ErrEmptyApp)
+}
+
+type fakeConn struct {
+	io.Reader
+	bytes.Buffer
+}
+
+func (c *fakeConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (s *S) TestShell(c *check.C) {
+	a := s.newApp(c, "myapp")
+	s.deployApp(c, a, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	s.server.execOutput = "$ "
+	conn := &fakeConn{Reader: strings.NewReader("ls\n")}
+	err = s.p.Shell(provision.ShellOptions{App: a, Conn: conn, Width: 80, Height: 24, Unit: units[0].ID, Term: "xterm"})
+	c.Assert(err, check.IsNil)
+	c.Assert(conn.Buffer.String(), check.Equals, "$ ")
+	c.Assert(s.server.execs, check.HasLen, 1)
+	c.Assert(s.server.execs[0].pod, check.Equals, units[0].ID)
+	c.Assert(s.server.execs[0].command, check.DeepEquals, []string{"/usr/bin/env", "TERM=xterm", "bash", "-l"})
+	c.Assert(s.server.execs[0].stdin, check.Equals, "ls\n")
+}
+
+func (s *S) TestShellUnitNotFound(c *check.C) {
+	a := s.newApp(c, "myapp")
+	err := s.p.Shell(provision.ShellOptions{App: a, Unit: "unknown"})
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+}
+
+func (s *S) TestRegisterUnitBuildPod(c *check.C) {
+	a := s.newApp(c, "myapp")
+	buildPod := newBuildPod(a, "tsuru/python:latest", "registry.tsuru.io/tsuru/app-myapp:v1", []string{"true"}, true)
+	client, err := newClusterClient()
+	c.Assert(err, check.IsNil)
+	_, err = client.createPod(buildPod)
+	c.Assert(err, check.IsNil)
+	err = s.p.RegisterUnit(provision.Unit{ID: buildPod.Metadata.Name}, map[string]interface{}{
+		"procfile": "web: python app.py\nworker: python worker.py",
+	})
+	c.Assert(err, check.IsNil)
+	data, err := getImageMetadata("registry.tsuru.io/tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(data.Processes, check.DeepEquals, map[string]string{
+		"web":    "python app.py",
+		"worker": "python worker.py",
+	})
+}
+
+func (s *S) TestRegisterUnitNotFound(c *check.C) {
+	err := s.p.RegisterUnit(provision.Unit{ID: "unknown"}, nil)
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+}
+
+func (s *S) TestFilterAppsByUnitStatus(c *check.C) {
+	a1 := s.newApp(c, "myapp")
+	a2 := s.newApp(c, "otherapp")
+	s.deployApp(c, a1, "myimg:v1", map[string]interface{}{"web": "python app.py"})
+	apps, err := s.p.FilterAppsByUnitStatus([]provision.App{a1, a2}, []string{"started"})
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.DeepEquals, []provision.App{a1})
+	apps, err = s.p.FilterAppsByUnitStatus([]provision.App{a1, a2}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 0)
+	_, err = s.p.FilterAppsByUnitStatus(nil, nil)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestAPIToken(c *check.C) {
+	config.Set("kubernetes:api:token", "secret-token")
+	a := s.newApp(c, "myapp")
+	_, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.server.authHeaders, check.DeepEquals, []string{"Bearer secret-token"})
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	conn           *db.Storage
+	server         *fakeServer
+	p              *kubernetesProvisioner
+	oldProvisioner provision.Provisioner
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "provision_kubernetes_tests_s")
+	config.Set("docker:router", "fake")
+	config.Set("docker:registry", "registry.tsuru.io")
+	config.Set("docker:repository-namespace", "tsuru")
+	config.Set("routers:fake:type", "fake")
+	config.Set("host", "http://tsuru.io:8080")
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	s.oldProvisioner = app.Provisioner
+	podPollInterval = time.Millisecond
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	app.Provisioner = s.oldProvisioner
+	s.conn.Apps().Database.DropDatabase()
+	s.conn.Close()
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.server = newFakeServer()
+	config.Set("kubernetes:api:url", s.server.URL())
+	config.Unset("kubernetes:api:token")
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	routertest.FakeRouter.Reset()
+	s.p = &kubernetesProvisioner{}
+	app.Provisioner = s.p
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+func (s *S) newApp(c *check.C, name string) *app.App {
+	a := app.App{
+		Name:     name,
+		Platform: "python",
+		Pool:     "mypool",
+		Quota:    quota.Unlimited,
+		Env: map[string]bind.EnvVar{
+			"TSURU_APP_TOKEN": {Name: "TSURU_APP_TOKEN", Value: "abc123"},
+		},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.Provision(&a)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+// deployApp deploys the app with an image declaring the given processes, as
+// if it had been built by the provisioner.
+func (s *S) deployApp(c *check.C, a *app.App, imageId string, processes map[string]interface{}) {
+	err := saveImageCustomData(imageId, map[string]interface{}{"processes": processes})
+	c.Assert(err, check.IsNil)
+	err = s.p.deploy(a, imageId, nil)
+	c.Assert(err, check.IsNil)
+	a.Deploys++
+}
@@ -0,0 +1,176 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+// The types below are a subset of the objects of the Kubernetes API, holding
+// only the fields used by the provisioner.
+
+type objectMeta struct {
+	Name            string            `json:"name,omitempty"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Generation      int64             `json:"generation,omitempty"`
+}
+
+type labelSelector struct {
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+type deployment struct {
+	Kind       string           `json:"kind,omitempty"`
+	APIVersion string           `json:"apiVersion,omitempty"`
+	Metadata   objectMeta       `json:"metadata"`
+	Spec       deploymentSpec   `json:"spec"`
+	Status     deploymentStatus `json:"status"`
+}
+
+type deploymentSpec struct {
+	Replicas int             `json:"replicas"`
+	Selector *labelSelector  `json:"selector,omitempty"`
+	Template podTemplateSpec `json:"template"`
+}
+
+type deploymentStatus struct {
+	ObservedGeneration  int64 `json:"observedGeneration,omitempty"`
+	Replicas            int   `json:"replicas,omitempty"`
+	UpdatedReplicas     int   `json:"updatedReplicas,omitempty"`
+	AvailableReplicas   int   `json:"availableReplicas,omitempty"`
+	UnavailableReplicas int   `json:"unavailableReplicas,omitempty"`
+}
+
+type deploymentList struct {
+	Items []deployment `json:"items"`
+}
+
+type podTemplateSpec struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     podSpec    `json:"spec"`
+}
+
+type podSpec struct {
+	Containers    []container       `json:"containers"`
+	RestartPolicy string            `json:"restartPolicy,omitempty"`
+	Volumes       []volume          `json:"volumes,omitempty"`
+	NodeSelector  map[string]string `json:"nodeSelector,omitempty"`
+}
+
+type container struct {
+	Name           string                `json:"name"`
+	Image          string                `json:"image"`
+	Command        []string              `json:"command,omitempty"`
+	Env            []envVar              `json:"env,omitempty"`
+	Ports          []containerPort       `json:"ports,omitempty"`
+	Resources      *resourceRequirements `json:"resources,omitempty"`
+	ReadinessProbe *probe                `json:"readinessProbe,omitempty"`
+	VolumeMounts   []volumeMount         `json:"volumeMounts,omitempty"`
+	Stdin          bool                  `json:"stdin,omitempty"`
+	StdinOnce      bool                  `json:"stdinOnce,omitempty"`
+}
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type containerPort struct {
+	ContainerPort int `json:"containerPort"`
+}
+
+type resourceRequirements struct {
+	Limits map[string]string `json:"limits,omitempty"`
+}
+
+type probe struct {
+	HTTPGet          *httpGetAction `json:"httpGet,omitempty"`
+	FailureThreshold int            `json:"failureThreshold,omitempty"`
+}
+
+type httpGetAction struct {
+	Path string `json:"path"`
+	Port int    `json:"port"`
+}
+
+type volume struct {
+	Name     string                `json:"name"`
+	HostPath *hostPathVolumeSource `json:"hostPath,omitempty"`
+}
+
+type hostPathVolumeSource struct {
+	Path string `json:"path"`
+}
+
+type volumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+}
+
+type pod struct {
+	Kind       string     `json:"kind,omitempty"`
+	APIVersion string     `json:"apiVersion,omitempty"`
+	Metadata   objectMeta `json:"metadata"`
+	Spec       podSpec    `json:"spec"`
+	Status     podStatus  `json:"status"`
+}
+
+type podStatus struct {
+	Phase             string            `json:"phase,omitempty"`
+	HostIP            string            `json:"hostIP,omitempty"`
+	PodIP             string            `json:"podIP,omitempty"`
+	ContainerStatuses []containerStatus `json:"containerStatuses,omitempty"`
+}
+
+type containerStatus struct {
+	Name  string         `json:"name"`
+	Ready bool           `json:"ready"`
+	State containerState `json:"state"`
+}
+
+type containerState struct {
+	Waiting    *containerStateWaiting    `json:"waiting,omitempty"`
+	Running    *containerStateRunning    `json:"running,omitempty"`
+	Terminated *containerStateTerminated `json:"terminated,omitempty"`
+}
+
+type containerStateWaiting struct {
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type containerStateRunning struct {
+	StartedAt string `json:"startedAt,omitempty"`
+}
+
+type containerStateTerminated struct {
+	ExitCode int    `json:"exitCode"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+type podList struct {
+	Items []pod `json:"items"`
+}
+
+type deleteOptions struct {
+	Kind             string `json:"kind"`
+	APIVersion       string `json:"apiVersion"`
+	OrphanDependents *bool  `json:"orphanDependents,omitempty"`
+}
+
+type apiStatus struct {
+	Kind    string `json:"kind,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+const (
+	podPending   = "Pending"
+	podRunning   = "Running"
+	podSucceeded = "Succeeded"
+	podFailed    = "Failed"
+)
@@ -140,25 +140,47 @@ func (l *MongodbLimiter) Start(action string) func() {
 		return noop
 	}
 	defer coll.Close()
-	var pushedId bson.ObjectId
 	for {
-		coll.RemoveAll(bson.M{"elements.update": bson.M{"$lt": time.Now().Add(-l.maxStale).UTC()}})
-		pushedId = bson.NewObjectId()
-		_, err := coll.Upsert(bson.M{
-			"_id": action,
-			fmt.Sprintf("elements.%d", l.limit-1): bson.M{"$exists": false},
-		}, bson.M{
-			"$push": bson.M{"elements": bson.M{"id": pushedId, "update": time.Now().UTC()}},
-		})
+		done, err := l.push(coll, action)
 		if err == nil {
-			l.idsCh <- pushedId
-			break
+			return done
 		}
 		if !mgo.IsDup(err) {
 			return noop
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
+}
+
+// TryStart is like Start, but it doesn't wait when the limit of concurrent
+// executions of the action has been reached, returning false instead. It's
+// useful for running an action in only one tsuru API instance at a time.
+func (l *MongodbLimiter) TryStart(action string) (func(), bool) {
+	coll := l.collection()
+	if coll == nil {
+		return noop, true
+	}
+	defer coll.Close()
+	done, err := l.push(coll, action)
+	if err != nil {
+		return noop, false
+	}
+	return done, true
+}
+
+func (l *MongodbLimiter) push(coll *storage.Collection, action string) (func(), error) {
+	coll.RemoveAll(bson.M{"elements.update": bson.M{"$lt": time.Now().Add(-l.maxStale).UTC()}})
+	pushedId := bson.NewObjectId()
+	_, err := coll.Upsert(bson.M{
+		"_id": action,
+		fmt.Sprintf("elements.%d", l.limit-1): bson.M{"$exists": false},
+	}, bson.M{
+		"$push": bson.M{"elements": bson.M{"id": pushedId, "update": time.Now().UTC()}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.idsCh <- pushedId
 	return func() {
 		doneColl := l.collection()
 		if doneColl == nil {
@@ -166,7 +188,7 @@ func (l *MongodbLimiter) Start(action string) func() {
 		}
 		defer doneColl.Close()
 		doneColl.Update(bson.M{"_id": action}, bson.M{"$pull": bson.M{"elements": bson.M{"id": pushedId}}})
-	}
+	}, nil
 }
 
 func (l *MongodbLimiter) Len(action string) int {
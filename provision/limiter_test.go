@@ -167,3 +167,31 @@ func (s *S) TestMongodbLimiterTimeoutUpdated(c *check.C) {
 	case <-time.After(1 * time.Second):
 	}
 }
+
+func (s *S) TestMongodbLimiterTryStart(c *check.C) {
+	l := &MongodbLimiter{}
+	l.Initialize(1)
+	done, ok := l.TryStart("n1")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(l.Len("n1"), check.Equals, 1)
+	_, ok = l.TryStart("n1")
+	c.Assert(ok, check.Equals, false)
+	c.Assert(l.Len("n1"), check.Equals, 1)
+	otherDone, ok := l.TryStart("n2")
+	c.Assert(ok, check.Equals, true)
+	otherDone()
+	done()
+	c.Assert(l.Len("n1"), check.Equals, 0)
+	done, ok = l.TryStart("n1")
+	c.Assert(ok, check.Equals, true)
+	done()
+}
+
+func (s *S) TestMongodbLimiterTryStartZeroLimit(c *check.C) {
+	l := &MongodbLimiter{}
+	l.Initialize(0)
+	_, ok := l.TryStart("n1")
+	c.Assert(ok, check.Equals, true)
+	_, ok = l.TryStart("n1")
+	c.Assert(ok, check.Equals, true)
+}
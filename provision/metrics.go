@@ -0,0 +1,30 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "tsuru",
+	Subsystem: "provisioner",
+	Name:      "operation_duration_seconds",
+	Help:      "The time taken by provisioner operations, by provisioner and operation.",
+	Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14),
+}, []string{"provisioner", "operation"})
+
+func init() {
+	prometheus.MustRegister(operationDuration)
+}
+
+// ObserveOperation records the time taken by an operation of the given
+// provisioner, started at the given time. It's meant to be deferred, with
+// time.Now(), at the beginning of the operation.
+func ObserveOperation(provisioner, operation string, start time.Time) {
+	operationDuration.WithLabelValues(provisioner, operation).Observe(time.Since(start).Seconds())
+}
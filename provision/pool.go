@@ -17,6 +17,10 @@ type Pool struct {
 	Teams   []string
 	Public  bool
 	Default bool
+	// Provisioner is the name of the provisioner used by apps in the pool.
+	// Pools without a provisioner use the default one, defined in the
+	// "provisioner" config key.
+	Provisioner string `bson:",omitempty" json:",omitempty"`
 }
 
 var (
@@ -24,19 +28,25 @@ var (
 	ErrDefaultPoolAlreadyExists       = errors.New("Default pool already exists.")
 	ErrPoolNameIsRequired             = errors.New("Pool name is required.")
 	ErrPoolNotFound                   = errors.New("Pool does not exist.")
+	ErrPoolProvisionerNotFound        = errors.New("Provisioner does not exist.")
+	ErrPoolHasApps                    = errors.New("Pool provisioner can't be changed while there are apps in the pool.")
 )
 
 type AddPoolOptions struct {
-	Name    string
-	Public  bool
-	Default bool
-	Force   bool
+	Name        string
+	Public      bool
+	Default     bool
+	Force       bool
+	Provisioner string
 }
 
 func AddPool(opts AddPoolOptions) error {
 	if opts.Name == "" {
 		return ErrPoolNameIsRequired
 	}
+	if err := validateProvisioner(opts.Provisioner); err != nil {
+		return err
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -48,10 +58,20 @@ func AddPool(opts AddPoolOptions) error {
 			return err
 		}
 	}
-	pool := Pool{Name: opts.Name, Public: opts.Public, Default: opts.Default}
+	pool := Pool{Name: opts.Name, Public: opts.Public, Default: opts.Default, Provisioner: opts.Provisioner}
 	return conn.Pools().Insert(pool)
 }
 
+func validateProvisioner(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := Get(name); err != nil {
+		return ErrPoolProvisionerNotFound
+	}
+	return nil
+}
+
 func changeDefaultPool(force bool) error {
 	conn, err := db.Conn()
 	if err != nil {
@@ -124,6 +144,24 @@ func RemoveTeamsFromPool(poolName string, teams []string) error {
 	return err
 }
 
+// GetPoolByName returns the pool with the given name, or ErrPoolNotFound.
+func GetPoolByName(name string) (*Pool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var pool Pool
+	err = conn.Pools().FindId(name).One(&pool)
+	if err == mgo.ErrNotFound {
+		return nil, ErrPoolNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
 func ListPools(query bson.M) ([]Pool, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -144,6 +182,12 @@ func PoolUpdate(poolName string, query bson.M, forceDefault bool) error {
 		return err
 	}
 	defer conn.Close()
+	if provisioner, ok := query["provisioner"]; ok {
+		err = checkPoolProvisionerChange(conn, poolName, provisioner)
+		if err != nil {
+			return err
+		}
+	}
 	if _, ok := query["default"]; ok {
 		err = changeDefaultPool(forceDefault)
 		if err != nil {
@@ -156,3 +200,28 @@ func PoolUpdate(poolName string, query bson.M, forceDefault bool) error {
 	}
 	return err
 }
+
+// checkPoolProvisionerChange ensures the new provisioner exists and that no
+// app would be left behind on the old provisioner of the pool.
+func checkPoolProvisionerChange(conn *db.Storage, poolName string, provisioner interface{}) error {
+	name, _ := provisioner.(string)
+	err := validateProvisioner(name)
+	if err != nil {
+		return err
+	}
+	pool, err := GetPoolByName(poolName)
+	if err != nil {
+		return err
+	}
+	if pool.Provisioner == name {
+		return nil
+	}
+	n, err := conn.Apps().Find(bson.M{"pool": poolName}).Count()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrPoolHasApps
+	}
+	return nil
+}
@@ -48,6 +48,37 @@ func (s *S) TestAddPool(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *S) TestAddPoolWithProvisioner(c *check.C) {
+	Register("pool-provisioner", nil)
+	defer delete(provisioners, "pool-provisioner")
+	opts := AddPoolOptions{Name: "pool1", Provisioner: "pool-provisioner"}
+	err := AddPool(opts)
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "pool-provisioner")
+}
+
+func (s *S) TestAddPoolWithUnknownProvisioner(c *check.C) {
+	opts := AddPoolOptions{Name: "pool1", Provisioner: "unknown"}
+	err := AddPool(opts)
+	c.Assert(err, check.Equals, ErrPoolProvisionerNotFound)
+	_, err = GetPoolByName("pool1")
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
+
+func (s *S) TestGetPoolByName(c *check.C) {
+	coll := s.storage.Pools()
+	pool := Pool{Name: "pool1", Public: true}
+	err := coll.Insert(pool)
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*p, check.DeepEquals, pool)
+	_, err = GetPoolByName("pool2")
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
+
 func (s *S) TestAddNonPublicPool(c *check.C) {
 	coll := s.storage.Pools()
 	defer coll.RemoveId("pool1")
@@ -332,6 +363,44 @@ func (s *S) TestPoolUpdateDontHaveSideEffects(c *check.C) {
 	c.Assert(p.Default, check.Equals, true)
 }
 
+func (s *S) TestPoolUpdateProvisioner(c *check.C) {
+	Register("pool-provisioner", nil)
+	defer delete(provisioners, "pool-provisioner")
+	coll := s.storage.Pools()
+	err := coll.Insert(Pool{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "pool-provisioner"}, false)
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Provisioner, check.Equals, "pool-provisioner")
+}
+
+func (s *S) TestPoolUpdateUnknownProvisioner(c *check.C) {
+	coll := s.storage.Pools()
+	err := coll.Insert(Pool{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "unknown"}, false)
+	c.Assert(err, check.Equals, ErrPoolProvisionerNotFound)
+}
+
+func (s *S) TestPoolUpdateProvisionerWithApps(c *check.C) {
+	Register("pool-provisioner", nil)
+	defer delete(provisioners, "pool-provisioner")
+	coll := s.storage.Pools()
+	err := coll.Insert(Pool{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(bson.M{"name": "myapp", "pool": "pool1"})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "pool-provisioner"}, false)
+	c.Assert(err, check.Equals, ErrPoolHasApps)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "", "public": true}, false)
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Public, check.Equals, true)
+}
+
 func (s *S) TestListPoolAll(c *check.C) {
 	coll := s.storage.Pools()
 	pool := Pool{Name: "pool1", Public: false, Default: true}
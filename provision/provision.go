@@ -12,15 +12,19 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/router"
 )
 
 var (
 	ErrInvalidStatus = errors.New("invalid status")
 	ErrEmptyApp      = errors.New("no units for this app")
+	ErrExecTimeout   = errors.New("timeout waiting for the command to finish")
 )
 
 type UnitNotFoundError struct {
@@ -190,6 +194,8 @@ type App interface {
 
 	GetRouter() (string, error)
 
+	GetRouters() ([]router.AppRouter, error)
+
 	GetPool() string
 
 	GetTeamOwner() string
@@ -206,6 +212,22 @@ type App interface {
 	GetLock() AppLock
 }
 
+// EnvDecrypter is implemented by apps that store the values of private
+// environment variables encrypted. Envs returns the stored values, while
+// DecryptedEnvs returns them in plain text.
+type EnvDecrypter interface {
+	DecryptedEnvs() (map[string]bind.EnvVar, error)
+}
+
+// AppEnvs returns the environment variables that should be set in the units
+// of the app, decrypting them when the app implements EnvDecrypter.
+func AppEnvs(a App) (map[string]bind.EnvVar, error) {
+	if decrypter, ok := a.(EnvDecrypter); ok {
+		return decrypter.DecryptedEnvs()
+	}
+	return a.Envs(), nil
+}
+
 type AppLock interface {
 	json.Marshaler
 
@@ -252,6 +274,84 @@ type ImageDeployer interface {
 	ImageDeploy(app App, image string, w io.Writer) (string, error)
 }
 
+// ImagePromoter is a provisioner that can deploy to an app an image built
+// for another app, keeping the processes and tsuru.yaml data of the image.
+type ImagePromoter interface {
+	PromoteImage(app App, image string, w io.Writer) (string, error)
+}
+
+// DockerfileDeployer is a provisioner that can deploy apps from archives
+// built with their own Dockerfile, instead of a platform image.
+type DockerfileDeployer interface {
+	DockerfileDeploy(app App, file io.ReadCloser, w io.Writer) (string, error)
+}
+
+// DeployCanceler is a provisioner that can cancel the running deploy of an
+// app, stopping the containers used to build its image.
+type DeployCanceler interface {
+	CancelDeploy(app App) error
+}
+
+// TsuruYamlProvisioner is a provisioner that keeps the data read from the
+// tsuru.yaml file of the images it builds.
+type TsuruYamlProvisioner interface {
+	ImageTsuruYamlData(image string) (TsuruYamlData, error)
+}
+
+// IsolatedExecOptions is the set of options that can be used when calling
+// the method ExecuteCommandIsolated in the provisioner.
+type IsolatedExecOptions struct {
+	App    App
+	Cmd    string
+	Args   []string
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Timeout is the maximum duration of the command, when it expires the
+	// command is killed and ErrExecTimeout is returned. Zero means no
+	// timeout.
+	Timeout time.Duration
+}
+
+// IsolatedExecutor is a provisioner that can run commands in a temporary
+// unit, created from the current image of the app just for running the
+// command and removed once it finishes. The units serving the app are not
+// affected by the command.
+type IsolatedExecutor interface {
+	// ExecuteCommandIsolated runs the command, returning its exit code.
+	ExecuteCommandIsolated(IsolatedExecOptions) (int, error)
+}
+
+// CanaryDeployer is a provisioner that can run units with a new image of an
+// app alongside its current units, in a canary deploy.
+//
+// While deploying an app implementing CanaryApp with a positive canary
+// weight, canary deployers must keep the current units of the app running,
+// starting units with the new image next to them, and must not change the
+// current image of the app.
+type CanaryDeployer interface {
+	// CanaryUnits returns the units of the app running the given image.
+	CanaryUnits(app App, imageId string) ([]Unit, error)
+
+	// PromoteCanary replaces the units of the app that are not running the
+	// canary image with units running it, making it the current image of
+	// the app.
+	PromoteCanary(app App, imageId string, w io.Writer) error
+
+	// AbortCanary removes the units of the app running the canary image.
+	AbortCanary(app App, imageId string, w io.Writer) error
+}
+
+// CanaryApp is an app that may be deployed as a canary.
+type CanaryApp interface {
+	App
+
+	// GetCanaryWeight returns the percentage of the traffic sent to units
+	// running the canary image, or 0 when the app is not in a canary
+	// deploy.
+	GetCanaryWeight() int
+}
+
 // Provisioner is the basic interface of this package.
 //
 // Any tsuru provisioner must implement this interface in order to provision
@@ -411,6 +511,54 @@ func Get(name string) (Provisioner, error) {
 	return p, nil
 }
 
+var (
+	initializedMu sync.Mutex
+	initialized   = make(map[string]bool)
+)
+
+// Initialize gets the named provisioner from the registry, calling its
+// Initialize method if it's an InitializableProvisioner. Each provisioner is
+// initialized only once, so it's safe to call Initialize whenever a
+// provisioner is assigned to a pool.
+func Initialize(name string) (Provisioner, error) {
+	p, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	initializedMu.Lock()
+	defer initializedMu.Unlock()
+	if initialized[name] {
+		return p, nil
+	}
+	if initializable, ok := p.(InitializableProvisioner); ok {
+		err = initializable.Initialize()
+		if err != nil {
+			return nil, err
+		}
+	}
+	initialized[name] = true
+	return p, nil
+}
+
+// Initialized returns the list of provisioners already initialized, sorted by
+// name.
+func Initialized() []Provisioner {
+	initializedMu.Lock()
+	defer initializedMu.Unlock()
+	names := make([]string, 0, len(initialized))
+	for name := range initialized {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]Provisioner, 0, len(names))
+	for _, name := range names {
+		if p, ok := provisioners[name]; ok {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
 // Registry returns the list of registered provisioners.
 func Registry() []Provisioner {
 	registry := make([]Provisioner, 0, len(provisioners))
@@ -455,7 +603,17 @@ type TsuruYamlHealthcheck struct {
 	AllowedFailures int `json:"allowed_failures" bson:"allowed_failures"`
 }
 
+// TsuruYamlDeploy describes how new units replace the old ones during a
+// deploy. MaxSurge and MaxUnavailable may be either absolute numbers or
+// percentages of the number of units (e.g. "25%").
+type TsuruYamlDeploy struct {
+	Strategy       string
+	MaxSurge       interface{} `json:"max_surge" bson:"max_surge"`
+	MaxUnavailable interface{} `json:"max_unavailable" bson:"max_unavailable"`
+}
+
 type TsuruYamlData struct {
 	Hooks       TsuruYamlHooks
 	Healthcheck TsuruYamlHealthcheck
+	Deploy      TsuruYamlDeploy
 }
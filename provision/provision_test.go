@@ -32,6 +32,70 @@ func (ProvisionSuite) TestRegisterAndGetProvisioner(c *check.C) {
 	c.Assert(err.Error(), check.Equals, expectedMessage)
 }
 
+type initializableProvisioner struct {
+	Provisioner
+	calls int
+	err   error
+}
+
+func (p *initializableProvisioner) Initialize() error {
+	p.calls++
+	return p.err
+}
+
+func (ProvisionSuite) TestInitialize(c *check.C) {
+	p := &initializableProvisioner{}
+	Register("init-provisioner", p)
+	defer delete(provisioners, "init-provisioner")
+	defer delete(initialized, "init-provisioner")
+	got, err := Initialize("init-provisioner")
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.Equals, p)
+	_, err = Initialize("init-provisioner")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.calls, check.Equals, 1)
+}
+
+func (ProvisionSuite) TestInitializeError(c *check.C) {
+	p := &initializableProvisioner{err: errors.New("init failed")}
+	Register("init-provisioner", p)
+	defer delete(provisioners, "init-provisioner")
+	_, err := Initialize("init-provisioner")
+	c.Assert(err, check.ErrorMatches, "init failed")
+	p.err = nil
+	_, err = Initialize("init-provisioner")
+	c.Assert(err, check.IsNil)
+	delete(initialized, "init-provisioner")
+	c.Assert(p.calls, check.Equals, 2)
+}
+
+func (ProvisionSuite) TestInitializeUnknown(c *check.C) {
+	_, err := Initialize("unknown-provisioner")
+	c.Assert(err, check.ErrorMatches, `unknown provisioner: "unknown-provisioner"`)
+}
+
+func (ProvisionSuite) TestInitialized(c *check.C) {
+	p1 := &initializableProvisioner{}
+	p2 := &initializableProvisioner{}
+	Register("init-provisioner-2", p2)
+	Register("init-provisioner-1", p1)
+	Register("init-provisioner-3", &initializableProvisioner{})
+	defer func() {
+		for _, name := range []string{"init-provisioner-1", "init-provisioner-2", "init-provisioner-3"} {
+			delete(provisioners, name)
+			delete(initialized, name)
+		}
+	}()
+	_, err := Initialize("init-provisioner-2")
+	c.Assert(err, check.IsNil)
+	_, err = Initialize("init-provisioner-1")
+	c.Assert(err, check.IsNil)
+	got := Initialized()
+	c.Assert(got, check.HasLen, 2)
+	c.Assert(got[0], check.Equals, p1)
+	c.Assert(got[1], check.Equals, p2)
+}
+
 func (ProvisionSuite) TestRegistry(c *check.C) {
 	var p1, p2 Provisioner
 	Register("my-provisioner", p1)
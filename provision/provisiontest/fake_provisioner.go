@@ -324,6 +324,10 @@ func (app *FakeApp) GetRouter() (string, error) {
 	return "fake", nil
 }
 
+func (app *FakeApp) GetRouters() ([]router.AppRouter, error) {
+	return []router.AppRouter{{Name: "fake"}}, nil
+}
+
 func (app *FakeApp) GetTeamsName() []string {
 	return app.Teams
 }
@@ -333,9 +337,11 @@ func (app *FakeApp) GetTeamOwner() string {
 }
 
 type Cmd struct {
-	Cmd  string
-	Args []string
-	App  provision.App
+	Cmd      string
+	Args     []string
+	App      provision.App
+	Isolated bool
+	Timeout  time.Duration
 }
 
 type failure struct {
@@ -345,21 +351,25 @@ type failure struct {
 
 // Fake implementation for provision.Provisioner.
 type FakeProvisioner struct {
-	cmds     []Cmd
-	cmdMut   sync.Mutex
-	outputs  chan []byte
-	failures chan failure
-	apps     map[string]provisionedApp
-	mut      sync.RWMutex
-	shells   map[string][]provision.ShellOptions
-	shellMut sync.Mutex
+	cmds      []Cmd
+	cmdMut    sync.Mutex
+	outputs   chan []byte
+	failures  chan failure
+	exitCodes chan int
+	apps      map[string]provisionedApp
+	yamlData  map[string]provision.TsuruYamlData
+	mut       sync.RWMutex
+	shells    map[string][]provision.ShellOptions
+	shellMut  sync.Mutex
 }
 
 func NewFakeProvisioner() *FakeProvisioner {
 	p := FakeProvisioner{}
 	p.outputs = make(chan []byte, 8)
 	p.failures = make(chan failure, 8)
+	p.exitCodes = make(chan int, 8)
 	p.apps = make(map[string]provisionedApp)
+	p.yamlData = make(map[string]provision.TsuruYamlData)
 	p.shells = make(map[string][]provision.ShellOptions)
 	return &p
 }
@@ -411,12 +421,33 @@ func (p *FakeProvisioner) Sleeps(app provision.App, process string) int {
 	return p.apps[app.GetName()].sleeps[process]
 }
 
+// Image returns the image deployed to the given app.
+func (p *FakeProvisioner) Image(app provision.App) string {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].image
+}
+
 func (p *FakeProvisioner) CustomData(app provision.App) map[string]interface{} {
 	p.mut.RLock()
 	defer p.mut.RUnlock()
 	return p.apps[app.GetName()].lastData
 }
 
+// SetImageTsuruYamlData sets the tsuru.yaml data returned by
+// ImageTsuruYamlData for the given image.
+func (p *FakeProvisioner) SetImageTsuruYamlData(image string, data provision.TsuruYamlData) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.yamlData[image] = data
+}
+
+func (p *FakeProvisioner) ImageTsuruYamlData(image string) (provision.TsuruYamlData, error) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.yamlData[image], nil
+}
+
 // Shells return all shell calls to the given unit.
 func (p *FakeProvisioner) Shells(unit string) []provision.ShellOptions {
 	p.shellMut.Lock()
@@ -464,6 +495,12 @@ func (p *FakeProvisioner) PrepareOutput(b []byte) {
 	p.outputs <- b
 }
 
+// PrepareExitCode sends the given exit code to a queue of exit codes, used by
+// ExecuteCommandIsolated.
+func (p *FakeProvisioner) PrepareExitCode(code int) {
+	p.exitCodes <- code
+}
+
 // PrepareFailure prepares a failure for the given method name.
 //
 // For instance, PrepareFailure("GitDeploy", errors.New("GitDeploy failed")) will
@@ -485,6 +522,7 @@ func (p *FakeProvisioner) Reset() {
 
 	p.mut.Lock()
 	p.apps = make(map[string]provisionedApp)
+	p.yamlData = make(map[string]provision.TsuruYamlData)
 	p.mut.Unlock()
 
 	p.shellMut.Lock()
@@ -495,6 +533,7 @@ func (p *FakeProvisioner) Reset() {
 		select {
 		case <-p.outputs:
 		case <-p.failures:
+		case <-p.exitCodes:
 		default:
 			return
 		}
@@ -517,6 +556,9 @@ func (p *FakeProvisioner) ArchiveDeploy(app provision.App, archiveURL string, w
 	}
 	w.Write([]byte("Archive deploy called"))
 	pApp.lastArchive = archiveURL
+	if err := p.deployCanary(&pApp, app, "app-image"); err != nil {
+		return "", err
+	}
 	p.apps[app.GetName()] = pApp
 	return "app-image", nil
 }
@@ -533,10 +575,55 @@ func (p *FakeProvisioner) UploadDeploy(app provision.App, file io.ReadCloser, fi
 	}
 	w.Write([]byte("Upload deploy called"))
 	pApp.lastFile = file
+	if err := p.deployCanary(&pApp, app, "app-image"); err != nil {
+		return "", err
+	}
 	p.apps[app.GetName()] = pApp
 	return "app-image", nil
 }
 
+func (p *FakeProvisioner) DockerfileDeploy(app provision.App, file io.ReadCloser, w io.Writer) (string, error) {
+	if err := p.getError("DockerfileDeploy"); err != nil {
+		return "", err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return "", errNotProvisioned
+	}
+	w.Write([]byte("Dockerfile deploy called"))
+	pApp.lastFile = file
+	if err := p.deployCanary(&pApp, app, "app-image"); err != nil {
+		return "", err
+	}
+	p.apps[app.GetName()] = pApp
+	return "app-image", nil
+}
+
+func (p *FakeProvisioner) CancelDeploy(app provision.App) error {
+	if err := p.getError("CancelDeploy"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	pApp.cancels++
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// Cancels returns the number of times the deploy of the given app was
+// canceled.
+func (p *FakeProvisioner) Cancels(app provision.App) int {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].cancels
+}
+
 func (p *FakeProvisioner) ImageDeploy(app provision.App, img string, w io.Writer) (string, error) {
 	if err := p.getError("ImageDeploy"); err != nil {
 		return "", err
@@ -547,8 +634,30 @@ func (p *FakeProvisioner) ImageDeploy(app provision.App, img string, w io.Writer
 	if !ok {
 		return "", errNotProvisioned
 	}
-	pApp.image = img
 	w.Write([]byte("Image deploy called"))
+	if canaryWeight(app) > 0 {
+		if err := p.deployCanary(&pApp, app, img); err != nil {
+			return "", err
+		}
+	} else {
+		pApp.image = img
+	}
+	p.apps[app.GetName()] = pApp
+	return img, nil
+}
+
+func (p *FakeProvisioner) PromoteImage(app provision.App, img string, w io.Writer) (string, error) {
+	if err := p.getError("PromoteImage"); err != nil {
+		return "", err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return "", errNotProvisioned
+	}
+	w.Write([]byte("Promote image called"))
+	pApp.image = img
 	p.apps[app.GetName()] = pApp
 	return img, nil
 }
@@ -576,7 +685,7 @@ func (p *FakeProvisioner) Provision(app provision.App) error {
 		return &provision.Error{Reason: "App already provisioned."}
 	}
 	err := routertest.FakeRouter.AddBackend(app.GetName())
-	if err != nil {
+	if err != nil && err != router.ErrBackendExists {
 		return err
 	}
 	p.mut.Lock()
@@ -755,7 +864,7 @@ func (p *FakeProvisioner) RemoveUnits(app provision.App, n uint, process string,
 		if removedCount > 0 && u.ProcessName == process {
 			removedCount--
 			err := routertest.FakeRouter.RemoveRoute(app.GetName(), u.Address)
-			if err != nil {
+			if err != nil && err != router.ErrRouteNotFound {
 				return err
 			}
 			continue
@@ -862,6 +971,40 @@ func (p *FakeProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, app provi
 	return nil
 }
 
+// ExecuteCommandIsolated pretends to run the command in a temporary unit,
+// recording data about it. The output of the command may be prepared with
+// PrepareOutput and its exit code with PrepareExitCode, the exit code is 0
+// when none is prepared.
+func (p *FakeProvisioner) ExecuteCommandIsolated(opts provision.IsolatedExecOptions) (int, error) {
+	if err := p.getError("ExecuteCommandIsolated"); err != nil {
+		return 0, err
+	}
+	if !p.Provisioned(opts.App) {
+		return 0, errNotProvisioned
+	}
+	command := Cmd{
+		Cmd:      opts.Cmd,
+		Args:     opts.Args,
+		App:      opts.App,
+		Isolated: true,
+		Timeout:  opts.Timeout,
+	}
+	p.cmdMut.Lock()
+	p.cmds = append(p.cmds, command)
+	p.cmdMut.Unlock()
+	select {
+	case output := <-p.outputs:
+		opts.Stdout.Write(output)
+	default:
+	}
+	select {
+	case code := <-p.exitCodes:
+		return code, nil
+	default:
+	}
+	return 0, nil
+}
+
 func (p *FakeProvisioner) AddUnit(app provision.App, unit provision.Unit) {
 	p.mut.Lock()
 	defer p.mut.Unlock()
@@ -1048,6 +1191,147 @@ func (p *FakeProvisioner) Shell(opts provision.ShellOptions) error {
 	return nil
 }
 
+func canaryWeight(app provision.App) int {
+	if ca, ok := app.(provision.CanaryApp); ok {
+		return ca.GetCanaryWeight()
+	}
+	return 0
+}
+
+// deployCanary starts a canary unit running the given image if the app is
+// being deployed as a canary. It must be called with p.mut locked.
+func (p *FakeProvisioner) deployCanary(pApp *provisionedApp, app provision.App, imageId string) error {
+	if canaryWeight(app) == 0 {
+		return nil
+	}
+	if len(pApp.units) == 0 {
+		return errors.New("canary deploys require the app to have running units")
+	}
+	unit := p.newUnit(pApp, app, "web")
+	err := routertest.FakeRouter.AddRoute(app.GetName(), unit.Address)
+	if err != nil {
+		return err
+	}
+	pApp.units = append(pApp.units, unit)
+	if pApp.canary == nil {
+		pApp.canary = make(map[string][]provision.Unit)
+	}
+	pApp.canary[imageId] = append(pApp.canary[imageId], unit)
+	return nil
+}
+
+func (p *FakeProvisioner) newUnit(pApp *provisionedApp, app provision.App, process string) provision.Unit {
+	val := atomic.AddInt32(&uniqueIpCounter, 1)
+	unit := provision.Unit{
+		ID:          fmt.Sprintf("%s-%d", app.GetName(), pApp.unitLen),
+		AppName:     app.GetName(),
+		Type:        app.GetPlatform(),
+		Status:      provision.StatusStarted,
+		Ip:          fmt.Sprintf("10.10.10.%d", val),
+		ProcessName: process,
+		Address: &url.URL{
+			Scheme: "http",
+			Host:   fmt.Sprintf("10.10.10.%d:%d", val, val),
+		},
+	}
+	pApp.unitLen++
+	return unit
+}
+
+func (p *FakeProvisioner) CanaryUnits(app provision.App, imageId string) ([]provision.Unit, error) {
+	if err := p.getError("CanaryUnits"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return nil, errNotProvisioned
+	}
+	return pApp.canary[imageId], nil
+}
+
+// PromoteCanary replaces the units not running the canary image with new
+// units, keeping the total number of units of the app.
+func (p *FakeProvisioner) PromoteCanary(app provision.App, imageId string, w io.Writer) error {
+	if err := p.getError("PromoteCanary"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	canary := pApp.canary[imageId]
+	isCanary := make(map[string]bool, len(canary))
+	for _, u := range canary {
+		isCanary[u.ID] = true
+	}
+	var newUnits, stable []provision.Unit
+	for _, u := range pApp.units {
+		if isCanary[u.ID] {
+			newUnits = append(newUnits, u)
+			continue
+		}
+		stable = append(stable, u)
+		err := routertest.FakeRouter.RemoveRoute(app.GetName(), u.Address)
+		if err != nil {
+			return err
+		}
+	}
+	for i := len(canary); i < len(stable); i++ {
+		unit := p.newUnit(&pApp, app, stable[i].ProcessName)
+		err := routertest.FakeRouter.AddRoute(app.GetName(), unit.Address)
+		if err != nil {
+			return err
+		}
+		newUnits = append(newUnits, unit)
+	}
+	pApp.units = newUnits
+	pApp.image = imageId
+	delete(pApp.canary, imageId)
+	p.apps[app.GetName()] = pApp
+	if w != nil {
+		fmt.Fprintf(w, "canary %s promoted", imageId)
+	}
+	return nil
+}
+
+func (p *FakeProvisioner) AbortCanary(app provision.App, imageId string, w io.Writer) error {
+	if err := p.getError("AbortCanary"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	isCanary := make(map[string]bool)
+	for _, u := range pApp.canary[imageId] {
+		isCanary[u.ID] = true
+	}
+	var newUnits []provision.Unit
+	for _, u := range pApp.units {
+		if !isCanary[u.ID] {
+			newUnits = append(newUnits, u)
+			continue
+		}
+		err := routertest.FakeRouter.RemoveRoute(app.GetName(), u.Address)
+		if err != nil {
+			return err
+		}
+	}
+	pApp.units = newUnits
+	delete(pApp.canary, imageId)
+	p.apps[app.GetName()] = pApp
+	if w != nil {
+		fmt.Fprintf(w, "canary %s aborted", imageId)
+	}
+	return nil
+}
+
 func (p *FakeProvisioner) ValidAppImages(appName string) ([]string, error) {
 	if err := p.getError("ValidAppImages"); err != nil {
 		return nil, err
@@ -1184,6 +1468,8 @@ type provisionedApp struct {
 	unitLen     int
 	lastData    map[string]interface{}
 	image       string
+	canary      map[string][]provision.Unit
+	cancels     int
 }
 
 type provisionedPlatform struct {
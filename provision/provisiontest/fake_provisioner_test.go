@@ -912,6 +912,38 @@ func (s *S) TestExecuteCommandOnce(c *check.C) {
 	c.Assert(buf.String(), check.Equals, string(output))
 }
 
+func (s *S) TestExecuteCommandIsolated(c *check.C) {
+	var buf bytes.Buffer
+	output := []byte("myoutput!")
+	app := NewFakeApp("grand-designs", "rush", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	p.PrepareOutput(output)
+	p.PrepareExitCode(3)
+	code, err := p.ExecuteCommandIsolated(provision.IsolatedExecOptions{
+		App:    app,
+		Cmd:    "ls",
+		Args:   []string{"-l"},
+		Stdout: &buf,
+		Stderr: &buf,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(code, check.Equals, 3)
+	cmds := p.GetCmds("ls", app)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Isolated, check.Equals, true)
+	c.Assert(cmds[0].Args, check.DeepEquals, []string{"-l"})
+	c.Assert(buf.String(), check.Equals, string(output))
+}
+
+func (s *S) TestExecuteCommandIsolatedNotProvisioned(c *check.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("grand-designs", "rush", 1)
+	p := NewFakeProvisioner()
+	_, err := p.ExecuteCommandIsolated(provision.IsolatedExecOptions{App: app, Cmd: "ls", Stdout: &buf, Stderr: &buf})
+	c.Assert(err, check.Equals, errNotProvisioned)
+}
+
 func (s *S) TestExtensiblePlatformAdd(c *check.C) {
 	p := ExtensibleFakeProvisioner{FakeProvisioner: NewFakeProvisioner()}
 	args := map[string]string{"dockerfile": "mydockerfile.txt"}
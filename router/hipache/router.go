@@ -502,12 +502,19 @@ func (r *hipacheRouter) Routes(name string) ([]*url.URL, error) {
 		return nil, router.ErrBackendNotFound
 	}
 	routes = routes[1:]
-	result := make([]*url.URL, len(routes))
-	for i, route := range routes {
-		result[i], err = url.Parse(route)
+	result := make([]*url.URL, 0, len(routes))
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		// Weighted routes are stored multiple times in the frontend.
+		if seen[route] {
+			continue
+		}
+		seen[route] = true
+		parsed, err := url.Parse(route)
 		if err != nil {
 			return nil, err
 		}
+		result = append(result, parsed)
 	}
 	return result, nil
 }
@@ -577,3 +584,145 @@ func (r *hipacheRouter) SetHealthcheck(name string, data router.HealthcheckData)
 	}
 	return nil
 }
+
+// SetWeights changes the weight of the routes of the backend. As hipache picks
+// a random route for each request, weights are implemented by storing each
+// route in the frontend as many times as its weight.
+func (r *hipacheRouter) SetWeights(name string, weights map[string]int) error {
+	for _, weight := range weights {
+		if weight < 1 {
+			return router.ErrInvalidWeight
+		}
+	}
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	domain, err := config.GetString(r.prefix + ":domain")
+	if err != nil {
+		return &router.RouterError{Op: "setWeights", Err: err}
+	}
+	routes, err := r.Routes(name)
+	if err != nil {
+		return err
+	}
+	cnames, err := r.getCNames(backendName)
+	if err != nil {
+		return err
+	}
+	frontends := []string{"frontend:" + backendName + "." + domain}
+	for _, cname := range cnames {
+		frontends = append(frontends, "frontend:"+cname)
+	}
+	conn, err := r.connect()
+	if err != nil {
+		return &router.RouterError{Op: "setWeights", Err: err}
+	}
+	pipe := conn.Pipeline()
+	defer pipe.Close()
+	for _, frontend := range frontends {
+		current, err := r.frontendWeights(frontend)
+		if err != nil {
+			return err
+		}
+		for _, route := range routes {
+			addr := route.String()
+			weight := weights[addr]
+			if weight == 0 {
+				weight = 1
+			}
+			diff := weight - current[addr]
+			if diff > 0 {
+				copies := make([]string, diff)
+				for i := range copies {
+					copies[i] = addr
+				}
+				pipe.RPush(frontend, copies...)
+			} else if diff < 0 {
+				// Negative counts remove the last occurrences, so the
+				// route is never left out of the frontend.
+				pipe.LRem(frontend, int64(diff), addr)
+			}
+		}
+	}
+	_, err = pipe.Exec()
+	if err != nil {
+		return &router.RouterError{Op: "setWeights", Err: err}
+	}
+	return nil
+}
+
+func (r *hipacheRouter) Weights(name string) (map[string]int, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := config.GetString(r.prefix + ":domain")
+	if err != nil {
+		return nil, &router.RouterError{Op: "weights", Err: err}
+	}
+	return r.frontendWeights("frontend:" + backendName + "." + domain)
+}
+
+func (r *hipacheRouter) frontendWeights(frontend string) (map[string]int, error) {
+	conn, err := r.connect()
+	if err != nil {
+		return nil, &router.RouterError{Op: "weights", Err: err}
+	}
+	routes, err := conn.LRange(frontend, 1, -1).Result()
+	if err != nil && err != redis.Nil {
+		return nil, &router.RouterError{Op: "weights", Err: err}
+	}
+	weights := make(map[string]int, len(routes))
+	for _, route := range routes {
+		weights[route]++
+	}
+	return weights, nil
+}
+
+// AddCertificate stores the certificate and the key of the cname in the
+// "tls:<cname>" hash, read by hipache compatible routers supporting SNI.
+func (r *hipacheRouter) AddCertificate(cname, certificate, key string) error {
+	conn, err := r.connect()
+	if err != nil {
+		return &router.RouterError{Op: "addCertificate", Err: err}
+	}
+	err = conn.HMSetMap("tls:"+cname, map[string]string{
+		"certificate": certificate,
+		"key":         key,
+	}).Err()
+	if err != nil {
+		return &router.RouterError{Op: "addCertificate", Err: err}
+	}
+	return nil
+}
+
+func (r *hipacheRouter) RemoveCertificate(cname string) error {
+	conn, err := r.connect()
+	if err != nil {
+		return &router.RouterError{Op: "removeCertificate", Err: err}
+	}
+	count, err := conn.Del("tls:" + cname).Result()
+	if err != nil {
+		return &router.RouterError{Op: "removeCertificate", Err: err}
+	}
+	if count == 0 {
+		return router.ErrCertificateNotFound
+	}
+	return nil
+}
+
+func (r *hipacheRouter) GetCertificate(cname string) (string, error) {
+	conn, err := r.connect()
+	if err != nil {
+		return "", &router.RouterError{Op: "getCertificate", Err: err}
+	}
+	certificate, err := conn.HGet("tls:"+cname, "certificate").Result()
+	if err == redis.Nil {
+		return "", router.ErrCertificateNotFound
+	}
+	if err != nil {
+		return "", &router.RouterError{Op: "getCertificate", Err: err}
+	}
+	return certificate, nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+)
+
+// AppRouter is one of the routers an app is attached to, along with the
+// options used to create the backend of the app in it. Address is only filled
+// when showing the app.
+type AppRouter struct {
+	Name    string            `json:"name"`
+	Opts    map[string]string `json:"opts,omitempty" bson:",omitempty"`
+	Address string            `json:"address,omitempty" bson:"-"`
+}
+
+// OptsRouter is a router able to customize the backend of each app through
+// options, like exposing it only inside the cluster.
+type OptsRouter interface {
+	AddBackendOpts(name string, opts map[string]string) error
+}
+
+// GetForApp returns the router handling the backend of an app attached to
+// the given routers. With a single router without options, it's the router
+// itself. Otherwise, the returned router sends every change in the backend
+// to all of them, undoing the change in the routers already changed when one
+// of them fails. Addresses and routes are read from the first router.
+func GetForApp(routers []AppRouter) (Router, error) {
+	if len(routers) == 0 {
+		return nil, errors.New("no routers given")
+	}
+	if len(routers) == 1 && len(routers[0].Opts) == 0 {
+		return Get(routers[0].Name)
+	}
+	m := &multiRouter{
+		names:   make([]string, len(routers)),
+		routers: make([]Router, len(routers)),
+		opts:    make([]map[string]string, len(routers)),
+	}
+	for i, appRouter := range routers {
+		r, err := Get(appRouter.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(appRouter.Opts) > 0 {
+			if _, ok := r.(OptsRouter); !ok {
+				return nil, fmt.Errorf("router %q: %s", appRouter.Name, ErrOptsNotSupported)
+			}
+		}
+		m.names[i] = appRouter.Name
+		m.routers[i] = r
+		m.opts[i] = appRouter.Opts
+	}
+	return m, nil
+}
+
+// AddBackendOpts adds the backend to the router, using the options when
+// there are any.
+func AddBackendOpts(r Router, name string, opts map[string]string) error {
+	if len(opts) == 0 {
+		return r.AddBackend(name)
+	}
+	optsRouter, ok := r.(OptsRouter)
+	if !ok {
+		return ErrOptsNotSupported
+	}
+	return optsRouter.AddBackendOpts(name, opts)
+}
+
+// RemoveSharedBackend removes the backend from one of the routers of an app
+// attached to many routers, keeping the backend name, used by the other
+// routers, stored.
+func RemoveSharedBackend(r Router, name string) error {
+	data, err := retrieveRouterData(name)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return ErrBackendNotFound
+		}
+		return err
+	}
+	err = r.RemoveBackend(name)
+	if err != nil {
+		return err
+	}
+	return Store(name, data["router"], data["kind"])
+}
+
+type multiRouter struct {
+	names   []string
+	routers []Router
+	opts    []map[string]string
+}
+
+// each calls fn for every router, stopping at the first error and calling
+// rollback, in reverse order, for the routers already changed. The error is
+// returned as is, so callers can still compare it with the errors of this
+// package.
+func (r *multiRouter) each(fn, rollback func(Router) error) error {
+	for i, rt := range r.routers {
+		err := fn(rt)
+		if err == nil {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if rollbackErr := rollback(r.routers[j]); rollbackErr != nil {
+				log.Errorf("[router %s] error rolling back change: %s", r.names[j], rollbackErr)
+			}
+		}
+		log.Errorf("[router %s] %s", r.names[i], err)
+		return err
+	}
+	return nil
+}
+
+func (r *multiRouter) AddBackend(name string) error {
+	for i, rt := range r.routers {
+		err := AddBackendOpts(rt, name, r.opts[i])
+		if err == nil {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if rollbackErr := RemoveSharedBackend(r.routers[j], name); rollbackErr != nil {
+				log.Errorf("[router %s] error rolling back backend creation: %s", r.names[j], rollbackErr)
+			}
+		}
+		if err != ErrBackendExists {
+			Remove(name)
+		}
+		log.Errorf("[router %s] error adding backend %s: %s", r.names[i], name, err)
+		return err
+	}
+	return nil
+}
+
+// RemoveBackend removes the backend from every router, even when some of
+// them fail, returning the first error.
+func (r *multiRouter) RemoveBackend(name string) error {
+	swapped, _, err := IsSwapped(name)
+	if err != nil {
+		return err
+	}
+	if swapped {
+		return ErrBackendSwapped
+	}
+	var firstErr error
+	last := len(r.routers) - 1
+	for i, rt := range r.routers {
+		if i < last {
+			err = RemoveSharedBackend(rt, name)
+		} else {
+			err = rt.RemoveBackend(name)
+		}
+		if err != nil && err != ErrBackendNotFound {
+			log.Errorf("[router %s] error removing backend %s: %s", r.names[i], name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	err = Remove(name)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *multiRouter) AddRoute(name string, address *url.URL) error {
+	return r.each(func(rt Router) error {
+		return rt.AddRoute(name, address)
+	}, func(rt Router) error {
+		return rt.RemoveRoute(name, address)
+	})
+}
+
+func (r *multiRouter) AddRoutes(name string, addresses []*url.URL) error {
+	return r.each(func(rt Router) error {
+		return rt.AddRoutes(name, addresses)
+	}, func(rt Router) error {
+		return rt.RemoveRoutes(name, addresses)
+	})
+}
+
+func (r *multiRouter) RemoveRoute(name string, address *url.URL) error {
+	return r.each(func(rt Router) error {
+		return rt.RemoveRoute(name, address)
+	}, func(rt Router) error {
+		return rt.AddRoute(name, address)
+	})
+}
+
+func (r *multiRouter) RemoveRoutes(name string, addresses []*url.URL) error {
+	return r.each(func(rt Router) error {
+		return rt.RemoveRoutes(name, addresses)
+	}, func(rt Router) error {
+		return rt.AddRoutes(name, addresses)
+	})
+}
+
+func (r *multiRouter) SetCName(cname, name string) error {
+	return r.each(func(rt Router) error {
+		return rt.SetCName(cname, name)
+	}, func(rt Router) error {
+		return rt.UnsetCName(cname, name)
+	})
+}
+
+func (r *multiRouter) UnsetCName(cname, name string) error {
+	return r.each(func(rt Router) error {
+		return rt.UnsetCName(cname, name)
+	}, func(rt Router) error {
+		return rt.SetCName(cname, name)
+	})
+}
+
+// SetHealthcheck sets the healthcheck of the backend in the routers
+// supporting custom healthchecks.
+func (r *multiRouter) SetHealthcheck(name string, data HealthcheckData) error {
+	for i, rt := range r.routers {
+		hcRouter, ok := rt.(CustomHealthcheckRouter)
+		if !ok {
+			continue
+		}
+		err := hcRouter.SetHealthcheck(name, data)
+		if err != nil {
+			log.Errorf("[router %s] error setting healthcheck of %s: %s", r.names[i], name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *multiRouter) Addr(name string) (string, error) {
+	return r.routers[0].Addr(name)
+}
+
+func (r *multiRouter) Routes(name string) ([]*url.URL, error) {
+	return r.routers[0].Routes(name)
+}
+
+// Swap exchanges the routes of the backends in every router, swapping their
+// names, shared by all routers, only once.
+func (r *multiRouter) Swap(backend1, backend2 string) error {
+	err := checkSwapKinds(backend1, backend2)
+	if err != nil {
+		return err
+	}
+	swap := func(rt Router) error {
+		return swapRoutes(rt, backend1, backend2)
+	}
+	err = r.each(swap, swap)
+	if err != nil {
+		return err
+	}
+	return swapBackendName(backend1, backend2)
+}
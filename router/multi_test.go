@@ -0,0 +1,210 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router_test
+
+import (
+	"net/url"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+type MultiSuite struct {
+	conn *db.Storage
+}
+
+var _ = check.Suite(&MultiSuite{})
+
+func (s *MultiSuite) SetUpSuite(c *check.C) {
+	config.Set("hipache:domain", "multitest.org")
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "router_multi_tests")
+	config.Set("routers:fake:type", "fake")
+	config.Set("routers:fake-tls:type", "fake-tls")
+	config.Set("routers:myhipache:type", "hipache")
+}
+
+func (s *MultiSuite) SetUpTest(c *check.C) {
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	dbtest.ClearAllCollections(s.conn.Collection("router").Database)
+	routertest.FakeRouter.Reset()
+	routertest.TLSRouter.Reset()
+}
+
+func (s *MultiSuite) TearDownTest(c *check.C) {
+	s.conn.Close()
+}
+
+func (s *MultiSuite) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+}
+
+func (s *MultiSuite) multiRouter(c *check.C) router.Router {
+	r, err := router.GetForApp([]router.AppRouter{
+		{Name: "fake"},
+		{Name: "fake-tls", Opts: map[string]string{"visibility": "internal"}},
+	})
+	c.Assert(err, check.IsNil)
+	return r
+}
+
+func (s *MultiSuite) TestGetForAppSingleRouter(c *check.C) {
+	r, err := router.GetForApp([]router.AppRouter{{Name: "fake"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(r, check.Equals, &routertest.FakeRouter)
+}
+
+func (s *MultiSuite) TestGetForAppOptsNotSupported(c *check.C) {
+	_, err := router.GetForApp([]router.AppRouter{
+		{Name: "fake"},
+		{Name: "myhipache", Opts: map[string]string{"visibility": "internal"}},
+	})
+	c.Assert(err, check.ErrorMatches, `router "myhipache": Router does not support backend options`)
+}
+
+func (s *MultiSuite) TestAddBackend(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.FakeRouter.HasBackend("myapp"), check.Equals, true)
+	c.Assert(routertest.FakeRouter.Opts("myapp"), check.IsNil)
+	c.Assert(routertest.TLSRouter.HasBackend("myapp"), check.Equals, true)
+	c.Assert(routertest.TLSRouter.Opts("myapp"), check.DeepEquals, map[string]string{"visibility": "internal"})
+	addr, err := r.Addr("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(addr, check.Equals, "myapp.fakerouter.com")
+}
+
+func (s *MultiSuite) TestAddBackendRollback(c *check.C) {
+	err := routertest.TLSRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	r := s.multiRouter(c)
+	err = r.AddBackend("myapp")
+	c.Assert(err, check.Equals, router.ErrBackendExists)
+	c.Assert(routertest.FakeRouter.HasBackend("myapp"), check.Equals, false)
+	name, err := router.Retrieve("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Equals, "myapp")
+}
+
+func (s *MultiSuite) TestRemoveBackend(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.RemoveBackend("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.FakeRouter.HasBackend("myapp"), check.Equals, false)
+	c.Assert(routertest.TLSRouter.HasBackend("myapp"), check.Equals, false)
+	_, err = router.Retrieve("myapp")
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+}
+
+func (s *MultiSuite) TestRemoveSharedBackend(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = router.RemoveSharedBackend(&routertest.TLSRouter, "myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.TLSRouter.HasBackend("myapp"), check.Equals, false)
+	c.Assert(routertest.FakeRouter.HasBackend("myapp"), check.Equals, true)
+	addr, _ := url.Parse("http://10.0.0.1:8080")
+	err = routertest.FakeRouter.AddRoute("myapp", addr)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *MultiSuite) TestAddRoutes(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.0.0.1:8080")
+	addr2, _ := url.Parse("http://10.0.0.2:8080")
+	err = r.AddRoutes("myapp", []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	for _, rt := range []interface {
+		HasRoute(string, string) bool
+	}{&routertest.FakeRouter, &routertest.TLSRouter} {
+		c.Assert(rt.HasRoute("myapp", addr1.String()), check.Equals, true)
+		c.Assert(rt.HasRoute("myapp", addr2.String()), check.Equals, true)
+	}
+	err = r.RemoveRoutes("myapp", []*url.URL{addr1})
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.FakeRouter.HasRoute("myapp", addr1.String()), check.Equals, false)
+	c.Assert(routertest.TLSRouter.HasRoute("myapp", addr1.String()), check.Equals, false)
+}
+
+func (s *MultiSuite) TestAddRoutesRollback(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	addr, _ := url.Parse("http://10.0.0.1:8080")
+	routertest.TLSRouter.FailForIp(addr.String())
+	err = r.AddRoutes("myapp", []*url.URL{addr})
+	c.Assert(err, check.Equals, routertest.ErrForcedFailure)
+	c.Assert(routertest.FakeRouter.HasRoute("myapp", addr.String()), check.Equals, false)
+}
+
+func (s *MultiSuite) TestRemoveRouteRollback(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	addr, _ := url.Parse("http://10.0.0.1:8080")
+	err = r.AddRoute("myapp", addr)
+	c.Assert(err, check.IsNil)
+	routertest.TLSRouter.FailForIp(addr.String())
+	err = r.RemoveRoute("myapp", addr)
+	c.Assert(err, check.Equals, routertest.ErrForcedFailure)
+	c.Assert(routertest.FakeRouter.HasRoute("myapp", addr.String()), check.Equals, true)
+}
+
+func (s *MultiSuite) TestSetCNameRollback(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = routertest.TLSRouter.AddBackend("otherapp")
+	c.Assert(err, check.IsNil)
+	err = routertest.TLSRouter.SetCName("myapp.io", "otherapp")
+	c.Assert(err, check.IsNil)
+	err = r.SetCName("myapp.io", "myapp")
+	c.Assert(err, check.Equals, router.ErrCNameExists)
+	c.Assert(routertest.FakeRouter.HasCName("myapp.io"), check.Equals, false)
+}
+
+func (s *MultiSuite) TestSwap(c *check.C) {
+	r := s.multiRouter(c)
+	err := r.AddBackend("app1")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("app2")
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.0.0.1:8080")
+	addr2, _ := url.Parse("http://10.0.0.2:8080")
+	err = r.AddRoute("app1", addr1)
+	c.Assert(err, check.IsNil)
+	err = r.AddRoute("app2", addr2)
+	c.Assert(err, check.IsNil)
+	err = r.Swap("app1", "app2")
+	c.Assert(err, check.IsNil)
+	name, err := router.Retrieve("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Equals, "app2")
+	for _, rt := range []router.Router{&routertest.FakeRouter, &routertest.TLSRouter} {
+		routes, err := rt.Routes("app1")
+		c.Assert(err, check.IsNil)
+		c.Assert(routes, check.DeepEquals, []*url.URL{addr1})
+		routes, err = rt.Routes("app2")
+		c.Assert(err, check.IsNil)
+		c.Assert(routes, check.DeepEquals, []*url.URL{addr2})
+	}
+	c.Assert(routertest.FakeRouter.HasRoute("app2", addr1.String()), check.Equals, true)
+	c.Assert(routertest.TLSRouter.HasRoute("app2", addr1.String()), check.Equals, true)
+}
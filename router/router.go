@@ -32,8 +32,20 @@ var (
 	ErrCNameExists     = errors.New("CName already exists")
 	ErrCNameNotFound   = errors.New("CName not found")
 	ErrCNameNotAllowed = errors.New("CName as router subdomain not allowed")
+	ErrInvalidCName    = errors.New("Invalid cname")
+	ErrInvalidWeight   = errors.New("Route weight must be greater than zero")
+
+	ErrCertificateNotFound    = errors.New("Certificate not found")
+	ErrChallengeRouteNotFound = errors.New("Challenge route not found")
+
+	ErrOptsNotSupported = errors.New("Router does not support backend options")
+	ErrInvalidOpts      = errors.New("Invalid backend options")
 )
 
+// ACMEChallengePath is the path prefix of the requests made by ACME servers to
+// validate HTTP-01 challenges.
+const ACMEChallengePath = "/.well-known/acme-challenge/"
+
 var routers = make(map[string]routerFactory)
 
 // Register registers a new router.
@@ -93,6 +105,49 @@ type CustomHealthcheckRouter interface {
 	SetHealthcheck(name string, data HealthcheckData) error
 }
 
+// WeightedRouter is a router able to distribute the traffic of a backend
+// unevenly among its routes, allowing, for instance, a new version of an app
+// to receive only a fraction of the requests.
+type WeightedRouter interface {
+	// SetWeights sets the relative weight of the routes of the backend,
+	// keyed by the route address. Routes not present in weights get the
+	// default weight of 1, so calling SetWeights with a nil map resets
+	// the backend to an even distribution.
+	SetWeights(name string, weights map[string]int) error
+
+	// Weights returns the weight of each route of the backend, keyed by
+	// the route address.
+	Weights(name string) (map[string]int, error)
+}
+
+// TLSRouter is a router able to serve the cnames of its backends over HTTPS,
+// using the certificates added to it.
+type TLSRouter interface {
+	// AddCertificate adds or replaces the certificate and the private key
+	// used to serve the cname, both PEM encoded.
+	AddCertificate(cname, certificate, key string) error
+
+	// RemoveCertificate removes the certificate of the cname, returning
+	// ErrCertificateNotFound when it has none.
+	RemoveCertificate(cname string) error
+
+	// GetCertificate returns the PEM encoded certificate of the cname.
+	GetCertificate(cname string) (string, error)
+}
+
+// ACMEChallengeRouter is a router able to send the requests to the ACME
+// HTTP-01 challenge path of a cname to another address, so tsuru can answer
+// the challenges while the cname is served by the backend of its app.
+type ACMEChallengeRouter interface {
+	// AddChallengeRoute sends the requests to ACMEChallengePath in the
+	// cname to the address, replacing any existing challenge route.
+	AddChallengeRoute(cname string, address *url.URL) error
+
+	// RemoveChallengeRoute removes the challenge route of the cname,
+	// returning ErrChallengeRouteNotFound when it has none.
+	RemoveChallengeRoute(cname string) error
+}
+
 type HealthChecker interface {
 	HealthCheck() error
 }
@@ -121,19 +176,20 @@ func collection() (*storage.Collection, error) {
 }
 
 // Store stores the app name related with the
-// router name.
+// router name. The backend name of an app attached to many routers is shared
+// by all of them, so storing it again keeps the existing one.
 func Store(appName, routerName, kind string) error {
 	coll, err := collection()
 	if err != nil {
 		return err
 	}
 	defer coll.Close()
-	data := map[string]string{
-		"app":    appName,
+	data := bson.M{
 		"router": routerName,
 		"kind":   kind,
 	}
-	return coll.Insert(&data)
+	_, err = coll.Upsert(bson.M{"app": appName}, bson.M{"$setOnInsert": data})
+	return err
 }
 
 func retrieveRouterData(appName string) (map[string]string, error) {
@@ -196,6 +252,18 @@ func swapBackendName(backend1, backend2 string) error {
 }
 
 func Swap(r Router, backend1, backend2 string) error {
+	err := checkSwapKinds(backend1, backend2)
+	if err != nil {
+		return err
+	}
+	err = swapRoutes(r, backend1, backend2)
+	if err != nil {
+		return err
+	}
+	return swapBackendName(backend1, backend2)
+}
+
+func checkSwapKinds(backend1, backend2 string) error {
 	data1, err := retrieveRouterData(backend1)
 	if err != nil {
 		return err
@@ -208,6 +276,12 @@ func Swap(r Router, backend1, backend2 string) error {
 		return fmt.Errorf("swap is only allowed between routers of the same kind. %q uses %q, %q uses %q",
 			backend1, data1["kind"], backend2, data2["kind"])
 	}
+	return nil
+}
+
+// swapRoutes exchanges the routes of two backends in the router, keeping
+// their names. Calling it twice restores the original routes.
+func swapRoutes(r Router, backend1, backend2 string) error {
 	routes1, err := r.Routes(backend1)
 	if err != nil {
 		return err
@@ -228,11 +302,7 @@ func Swap(r Router, backend1, backend2 string) error {
 	if err != nil {
 		return err
 	}
-	err = r.RemoveRoutes(backend2, routes2)
-	if err != nil {
-		return err
-	}
-	return swapBackendName(backend1, backend2)
+	return r.RemoveRoutes(backend2, routes2)
 }
 
 type PlanRouter struct {
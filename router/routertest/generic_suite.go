@@ -489,3 +489,104 @@ func (s *RouterSuite) TestSetHealthcheck(c *check.C) {
 	err = s.Router.RemoveBackend("mybackend")
 	c.Assert(err, check.IsNil)
 }
+
+func (s *RouterSuite) TestSetWeights(c *check.C) {
+	wRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement WeightedRouter", s.Router))
+	}
+	name := "backend1"
+	err := s.Router.AddBackend(name)
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.10.10.10:8080")
+	addr2, _ := url.Parse("http://10.10.10.11:8080")
+	err = s.Router.AddRoutes(name, []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetWeights(name, map[string]int{addr1.String(): 3})
+	c.Assert(err, check.IsNil)
+	weights, err := wRouter.Weights(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.String(): 3, addr2.String(): 1})
+	routes, err := s.Router.Routes(name)
+	c.Assert(err, check.IsNil)
+	sort.Sort(URLList(routes))
+	c.Assert(routes, check.DeepEquals, []*url.URL{addr1, addr2})
+	err = wRouter.SetWeights(name, map[string]int{addr1.String(): 2, addr2.String(): 4})
+	c.Assert(err, check.IsNil)
+	weights, err = wRouter.Weights(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.String(): 2, addr2.String(): 4})
+	err = wRouter.SetWeights(name, nil)
+	c.Assert(err, check.IsNil)
+	weights, err = wRouter.Weights(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.String(): 1, addr2.String(): 1})
+	err = wRouter.SetWeights(name, map[string]int{addr1.String(): 0})
+	c.Assert(err, check.Equals, router.ErrInvalidWeight)
+	err = s.Router.RemoveBackend(name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *RouterSuite) TestRemoveWeightedRoute(c *check.C) {
+	wRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement WeightedRouter", s.Router))
+	}
+	name := "backend1"
+	err := s.Router.AddBackend(name)
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.10.10.10:8080")
+	addr2, _ := url.Parse("http://10.10.10.11:8080")
+	err = s.Router.AddRoutes(name, []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetWeights(name, map[string]int{addr1.String(): 3})
+	c.Assert(err, check.IsNil)
+	err = s.Router.RemoveRoute(name, addr1)
+	c.Assert(err, check.IsNil)
+	routes, err := s.Router.Routes(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{addr2})
+	weights, err := wRouter.Weights(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr2.String(): 1})
+	err = s.Router.RemoveBackend(name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *RouterSuite) TestAddCertificate(c *check.C) {
+	tlsRouter, ok := s.Router.(router.TLSRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement TLSRouter", s.Router))
+	}
+	_, err := tlsRouter.GetCertificate(CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	err = tlsRouter.AddCertificate(CertificateCName, Certificate, CertificateKey)
+	c.Assert(err, check.IsNil)
+	err = tlsRouter.AddCertificate(CertificateCName, Certificate, CertificateKey)
+	c.Assert(err, check.IsNil)
+	certificate, err := tlsRouter.GetCertificate(CertificateCName)
+	c.Assert(err, check.IsNil)
+	c.Assert(certificate, check.Equals, Certificate)
+	err = tlsRouter.RemoveCertificate(CertificateCName)
+	c.Assert(err, check.IsNil)
+	_, err = tlsRouter.GetCertificate(CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	err = tlsRouter.RemoveCertificate(CertificateCName)
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+}
+
+func (s *RouterSuite) TestAddChallengeRoute(c *check.C) {
+	challengeRouter, ok := s.Router.(router.ACMEChallengeRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement ACMEChallengeRouter", s.Router))
+	}
+	addr, _ := url.Parse("http://tsuru.example.com:8080")
+	err := challengeRouter.AddChallengeRoute(CertificateCName, addr)
+	c.Assert(err, check.IsNil)
+	err = challengeRouter.AddChallengeRoute(CertificateCName, addr)
+	c.Assert(err, check.IsNil)
+	err = challengeRouter.RemoveChallengeRoute(CertificateCName)
+	c.Assert(err, check.IsNil)
+	err = challengeRouter.RemoveChallengeRoute(CertificateCName)
+	c.Assert(err, check.Equals, router.ErrChallengeRouteNotFound)
+}
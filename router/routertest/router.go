@@ -17,11 +17,19 @@ var FakeRouter = newFakeRouter()
 
 var HCRouter = hcRouter{fakeRouter: newFakeRouter()}
 
+var TLSRouter = tlsRouter{
+	fakeRouter:      newFakeRouter(),
+	certificates:    make(map[string]string),
+	keys:            make(map[string]string),
+	challengeRoutes: make(map[string]*url.URL),
+}
+
 var ErrForcedFailure = errors.New("Forced failure")
 
 func init() {
 	router.Register("fake", createRouter)
 	router.Register("fake-hc", createHCRouter)
+	router.Register("fake-tls", createTLSRouter)
 }
 
 func createRouter(name, prefix string) (router.Router, error) {
@@ -32,8 +40,12 @@ func createHCRouter(name, prefix string) (router.Router, error) {
 	return &HCRouter, nil
 }
 
+func createTLSRouter(name, prefix string) (router.Router, error) {
+	return &TLSRouter, nil
+}
+
 func newFakeRouter() fakeRouter {
-	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), healthcheck: make(map[string]router.HealthcheckData), mutex: &sync.Mutex{}}
+	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), healthcheck: make(map[string]router.HealthcheckData), weights: make(map[string]map[string]int), opts: make(map[string]map[string]string), mutex: &sync.Mutex{}}
 }
 
 type fakeRouter struct {
@@ -41,6 +53,8 @@ type fakeRouter struct {
 	cnames       map[string]string
 	failuresByIp map[string]bool
 	healthcheck  map[string]router.HealthcheckData
+	weights      map[string]map[string]int
+	opts         map[string]map[string]string
 	mutex        *sync.Mutex
 }
 
@@ -95,15 +109,28 @@ func (r *fakeRouter) HasRoute(name, address string) bool {
 }
 
 func (r *fakeRouter) AddBackend(name string) error {
+	return r.AddBackendOpts(name, nil)
+}
+
+func (r *fakeRouter) AddBackendOpts(name string, opts map[string]string) error {
 	if r.HasBackend(name) {
 		return router.ErrBackendExists
 	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	r.backends[name] = nil
+	if len(opts) > 0 {
+		r.opts[name] = opts
+	}
 	return router.Store(name, name, "fake")
 }
 
+func (r *fakeRouter) Opts(name string) map[string]string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.opts[name]
+}
+
 func (r *fakeRouter) RemoveBackend(name string) error {
 	backendName, err := router.Retrieve(name)
 	if err != nil {
@@ -123,6 +150,8 @@ func (r *fakeRouter) RemoveBackend(name string) error {
 		}
 	}
 	delete(r.backends, backendName)
+	delete(r.weights, backendName)
+	delete(r.opts, backendName)
 	return router.Remove(backendName)
 }
 
@@ -178,6 +207,7 @@ func (r *fakeRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 				break
 			}
 		}
+		delete(r.weights[backendName], addr.String())
 	}
 	r.backends[backendName] = routes
 	return nil
@@ -231,6 +261,7 @@ func (r *fakeRouter) RemoveRoute(name string, address *url.URL) error {
 	}
 	routes[index] = routes[len(routes)-1]
 	r.backends[backendName] = routes[:len(routes)-1]
+	delete(r.weights[backendName], address.String())
 	return nil
 }
 
@@ -291,6 +322,8 @@ func (r *fakeRouter) Reset() {
 	r.failuresByIp = make(map[string]bool)
 	r.cnames = make(map[string]string)
 	r.healthcheck = make(map[string]router.HealthcheckData)
+	r.weights = make(map[string]map[string]int)
+	r.opts = make(map[string]map[string]string)
 }
 
 func (r *fakeRouter) Routes(name string) ([]*url.URL, error) {
@@ -328,6 +361,83 @@ func (r *hcRouter) HealthCheck() error {
 	return r.err
 }
 
+type tlsRouter struct {
+	fakeRouter
+	certificates    map[string]string
+	keys            map[string]string
+	challengeRoutes map[string]*url.URL
+}
+
+func (r *tlsRouter) AddCertificate(cname, certificate, key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.certificates[cname] = certificate
+	r.keys[cname] = key
+	return nil
+}
+
+func (r *tlsRouter) RemoveCertificate(cname string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.certificates[cname]; !ok {
+		return router.ErrCertificateNotFound
+	}
+	delete(r.certificates, cname)
+	delete(r.keys, cname)
+	return nil
+}
+
+func (r *tlsRouter) GetCertificate(cname string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	certificate, ok := r.certificates[cname]
+	if !ok {
+		return "", router.ErrCertificateNotFound
+	}
+	return certificate, nil
+}
+
+// GetKey returns the private key added with the certificate of the cname.
+func (r *tlsRouter) GetKey(cname string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.keys[cname]
+}
+
+func (r *tlsRouter) AddChallengeRoute(cname string, address *url.URL) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.challengeRoutes[cname] = address
+	return nil
+}
+
+func (r *tlsRouter) RemoveChallengeRoute(cname string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.challengeRoutes[cname]; !ok {
+		return router.ErrChallengeRouteNotFound
+	}
+	delete(r.challengeRoutes, cname)
+	return nil
+}
+
+// ChallengeRoute returns the address receiving the ACME challenges of the
+// cname, or nil if it has no challenge route.
+func (r *tlsRouter) ChallengeRoute(cname string) *url.URL {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.challengeRoutes[cname]
+}
+
+func (r *tlsRouter) Reset() {
+	r.fakeRouter.Reset()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.certificates = make(map[string]string)
+	r.keys = make(map[string]string)
+	r.challengeRoutes = make(map[string]*url.URL)
+}
+
 func (r *fakeRouter) SetHealthcheck(name string, data router.HealthcheckData) error {
 	backendName, err := router.Retrieve(name)
 	if err != nil {
@@ -338,3 +448,46 @@ func (r *fakeRouter) SetHealthcheck(name string, data router.HealthcheckData) er
 	r.healthcheck[backendName] = data
 	return nil
 }
+
+func (r *fakeRouter) SetWeights(name string, weights map[string]int) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if !r.HasBackend(backendName) {
+		return router.ErrBackendNotFound
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	backendWeights := make(map[string]int)
+	for _, route := range r.backends[backendName] {
+		weight, ok := weights[route]
+		if !ok {
+			continue
+		}
+		if weight < 1 {
+			return router.ErrInvalidWeight
+		}
+		backendWeights[route] = weight
+	}
+	r.weights[backendName] = backendWeights
+	return nil
+}
+
+func (r *fakeRouter) Weights(name string) (map[string]int, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	weights := make(map[string]int)
+	for _, route := range r.backends[backendName] {
+		weight := r.weights[backendName][route]
+		if weight == 0 {
+			weight = 1
+		}
+		weights[route] = weight
+	}
+	return weights, nil
+}
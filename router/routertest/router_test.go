@@ -37,6 +37,17 @@ func init() {
 		suite.Router = &r
 	}
 	check.Suite(suite)
+	tlsSuite := &RouterSuite{
+		SetUpSuiteFunc:   base.SetUpSuite,
+		TearDownTestFunc: base.TearDownTest,
+	}
+	tlsSuite.SetUpTestFunc = func(c *check.C) {
+		config.Set("database:name", "router_generic_fake_tls_tests")
+		base.SetUpTest(c)
+		r := tlsRouter{fakeRouter: newFakeRouter(), certificates: make(map[string]string), keys: make(map[string]string)}
+		tlsSuite.Router = &r
+	}
+	check.Suite(tlsSuite)
 }
 
 func (s *S) SetUpSuite(c *check.C) {
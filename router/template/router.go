@@ -0,0 +1,542 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package template provides a router implementation that keeps backends,
+// routes and cnames in the tsuru database and renders them into the
+// configuration file of a proxy, like nginx or HAProxy, using a Go template
+// supplied by the user. After each change, the file is rewritten and a
+// reload command is run, so the proxy picks the new configuration.
+//
+// The file is rendered in the host of the tsuru API instance handling the
+// change, so this router is meant for tsuru API instances running alongside
+// the proxy, or sharing its configuration directory.
+//
+// It does not provide any exported function, in order to use the router, you
+// must import this package and get the router instance using the function
+// router.Get. The types Data and Backend describe the value the template is
+// executed with.
+//
+// In order to use this router, you need to define the "routers:<name>:type =
+// template" in your config.
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"text/template"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const routerType = "template"
+
+var (
+	// renderMtx serializes the writing of the configuration files and the
+	// runs of the reload commands in this process.
+	renderMtx sync.Mutex
+
+	// pendingReloads holds the configuration files written since the last
+	// successful run of their reload commands.
+	pendingReloads = map[string]bool{}
+
+	errNotMatched = errors.New("backend does not match the conditions")
+
+	// Options and cnames are rendered as they are, so they are restricted
+	// to characters that can't change the structure of the file.
+	optNameRegexp  = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	optValueRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.,:/@=+-]*$`)
+	cnameRegexp    = regexp.MustCompile(`^(\*\.)?[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+func init() {
+	router.Register(routerType, createRouter)
+	hc.AddChecker("Router template", router.BuildHealthCheck(routerType))
+}
+
+// Data is the value the template is executed with.
+type Data struct {
+	// Domain is the domain of the router.
+	Domain string
+
+	// Backends are the backends of the router, sorted by name.
+	Backends []Backend
+}
+
+// Backend is a backend of the router, as seen by the template.
+type Backend struct {
+	// Name is the name of the backend.
+	Name string
+
+	// Host is the address of the backend in the domain of the router.
+	Host string
+
+	// CNames are the additional hosts served by the backend.
+	CNames []string
+
+	// Routes are the addresses requests to the backend are sent to.
+	Routes []*url.URL
+
+	// Healthcheck is the healthcheck of the backend, the zero value
+	// when none was set.
+	Healthcheck router.HealthcheckData
+
+	// Opts are the options given by the app when attaching to the
+	// router, like {{index .Opts "listen"}} in the template. Names may
+	// only contain letters, digits, "_", "." and "-", and values may also
+	// contain ",", ":", "/", "@", "=" and "+".
+	Opts map[string]string
+}
+
+type backend struct {
+	Router      string
+	Name        string
+	Routes      []string
+	CNames      []string
+	Healthcheck router.HealthcheckData
+	Opts        map[string]string `bson:",omitempty"`
+}
+
+type templateRouter struct {
+	routerName    string
+	domain        string
+	templatePath  string
+	outputPath    string
+	reloadCommand string
+}
+
+func createRouter(routerName, configPrefix string) (router.Router, error) {
+	domain, err := config.GetString(configPrefix + ":domain")
+	if err != nil {
+		return nil, err
+	}
+	templatePath, err := config.GetString(configPrefix + ":template")
+	if err != nil {
+		return nil, err
+	}
+	outputPath, err := config.GetString(configPrefix + ":output")
+	if err != nil {
+		return nil, err
+	}
+	reloadCommand, _ := config.GetString(configPrefix + ":reload-command")
+	return &templateRouter{
+		routerName:    routerName,
+		domain:        domain,
+		templatePath:  templatePath,
+		outputPath:    outputPath,
+		reloadCommand: reloadCommand,
+	}, nil
+}
+
+func collection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	coll := conn.Collection("router_template")
+	coll.EnsureIndex(mgo.Index{Key: []string{"router", "name"}, Unique: true})
+	return coll, nil
+}
+
+func (r *templateRouter) query(backendName string) bson.M {
+	return bson.M{"router": r.routerName, "name": backendName}
+}
+
+// update applies the change to the backend, returning ErrBackendNotFound
+// when the backend doesn't exist and errNotMatched when it exists but doesn't
+// match the extra conditions of the query.
+func (r *templateRouter) update(backendName string, conditions bson.M, change bson.M) error {
+	coll, err := collection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	query := r.query(backendName)
+	for k, v := range conditions {
+		query[k] = v
+	}
+	err = coll.Update(query, change)
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	n, err := coll.Find(r.query(backendName)).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return router.ErrBackendNotFound
+	}
+	return errNotMatched
+}
+
+func (r *templateRouter) AddBackend(name string) error {
+	return r.AddBackendOpts(name, nil)
+}
+
+func (r *templateRouter) AddBackendOpts(name string, opts map[string]string) error {
+	for k, v := range opts {
+		if !optNameRegexp.MatchString(k) || !optValueRegexp.MatchString(v) {
+			return router.ErrInvalidOpts
+		}
+	}
+	coll, err := collection()
+	if err != nil {
+		return &router.RouterError{Op: "add", Err: err}
+	}
+	defer coll.Close()
+	err = coll.Insert(backend{Router: r.routerName, Name: name, Routes: []string{}, CNames: []string{}, Opts: opts})
+	if mgo.IsDup(err) {
+		return router.ErrBackendExists
+	}
+	if err != nil {
+		return &router.RouterError{Op: "add", Err: err}
+	}
+	err = router.Store(name, name, routerType)
+	if err != nil {
+		return &router.RouterError{Op: "add", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) RemoveBackend(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if backendName != name {
+		return router.ErrBackendSwapped
+	}
+	coll, err := collection()
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	defer coll.Close()
+	err = coll.Remove(r.query(backendName))
+	if err == mgo.ErrNotFound {
+		return router.ErrBackendNotFound
+	}
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	err = router.Remove(backendName)
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) AddRoute(name string, address *url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	addr := address.String()
+	err = r.update(backendName, bson.M{"routes": bson.M{"$ne": addr}}, bson.M{"$push": bson.M{"routes": addr}})
+	if err == errNotMatched {
+		return router.ErrRouteExists
+	}
+	if err == router.ErrBackendNotFound {
+		return err
+	}
+	if err != nil {
+		return &router.RouterError{Op: "add", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) AddRoutes(name string, addresses []*url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	addrs := make([]string, len(addresses))
+	for i := range addresses {
+		addrs[i] = addresses[i].String()
+	}
+	err = r.update(backendName, nil, bson.M{"$addToSet": bson.M{"routes": bson.M{"$each": addrs}}})
+	if err == router.ErrBackendNotFound {
+		return err
+	}
+	if err != nil {
+		return &router.RouterError{Op: "add", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) RemoveRoute(name string, address *url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	addr := address.String()
+	err = r.update(backendName, bson.M{"routes": addr}, bson.M{"$pull": bson.M{"routes": addr}})
+	if err == errNotMatched {
+		return router.ErrRouteNotFound
+	}
+	if err == router.ErrBackendNotFound {
+		return err
+	}
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) RemoveRoutes(name string, addresses []*url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	addrs := make([]string, len(addresses))
+	for i := range addresses {
+		addrs[i] = addresses[i].String()
+	}
+	err = r.update(backendName, nil, bson.M{"$pullAll": bson.M{"routes": addrs}})
+	if err == router.ErrBackendNotFound {
+		return err
+	}
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) SetCName(cname, name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if !cnameRegexp.MatchString(cname) {
+		return router.ErrInvalidCName
+	}
+	if !router.ValidCName(cname, r.domain) {
+		return router.ErrCNameNotAllowed
+	}
+	coll, err := collection()
+	if err != nil {
+		return &router.RouterError{Op: "setCName", Err: err}
+	}
+	defer coll.Close()
+	n, err := coll.Find(bson.M{"router": r.routerName, "cnames": cname}).Count()
+	if err != nil {
+		return &router.RouterError{Op: "setCName", Err: err}
+	}
+	if n > 0 {
+		return router.ErrCNameExists
+	}
+	err = r.update(backendName, nil, bson.M{"$push": bson.M{"cnames": cname}})
+	if err == router.ErrBackendNotFound {
+		return err
+	}
+	if err != nil {
+		return &router.RouterError{Op: "setCName", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) UnsetCName(cname, name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	err = r.update(backendName, bson.M{"cnames": cname}, bson.M{"$pull": bson.M{"cnames": cname}})
+	if err == errNotMatched {
+		return router.ErrCNameNotFound
+	}
+	if err == router.ErrBackendNotFound {
+		return err
+	}
+	if err != nil {
+		return &router.RouterError{Op: "unsetCName", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) SetHealthcheck(name string, data router.HealthcheckData) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	err = r.update(backendName, nil, bson.M{"$set": bson.M{"healthcheck": data}})
+	if err == router.ErrBackendNotFound {
+		return err
+	}
+	if err != nil {
+		return &router.RouterError{Op: "setHealthcheck", Err: err}
+	}
+	return r.render()
+}
+
+func (r *templateRouter) Addr(name string) (string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return "", err
+	}
+	return r.host(backendName), nil
+}
+
+func (r *templateRouter) host(backendName string) string {
+	return fmt.Sprintf("%s.%s", backendName, r.domain)
+}
+
+func (r *templateRouter) Swap(backend1, backend2 string) error {
+	return router.Swap(r, backend1, backend2)
+}
+
+func (r *templateRouter) Routes(name string) ([]*url.URL, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	coll, err := collection()
+	if err != nil {
+		return nil, &router.RouterError{Op: "routes", Err: err}
+	}
+	defer coll.Close()
+	var b backend
+	err = coll.Find(r.query(backendName)).One(&b)
+	if err == mgo.ErrNotFound {
+		return nil, router.ErrBackendNotFound
+	}
+	if err != nil {
+		return nil, &router.RouterError{Op: "routes", Err: err}
+	}
+	return parseRoutes(b.Routes)
+}
+
+func parseRoutes(addrs []string) ([]*url.URL, error) {
+	routes := make([]*url.URL, 0, len(addrs))
+	for _, addr := range addrs {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, u)
+	}
+	return routes, nil
+}
+
+// HealthCheck checks that the template of the router is valid.
+func (r *templateRouter) HealthCheck() error {
+	_, err := r.parseTemplate()
+	return err
+}
+
+func (r *templateRouter) parseTemplate() (*template.Template, error) {
+	return template.ParseFiles(r.templatePath)
+}
+
+// data returns the value the template is executed with, from the backends
+// stored in the database.
+func (r *templateRouter) data() (*Data, error) {
+	coll, err := collection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var backends []backend
+	err = coll.Find(bson.M{"router": r.routerName}).Sort("name").All(&backends)
+	if err != nil {
+		return nil, err
+	}
+	data := Data{Domain: r.domain, Backends: make([]Backend, len(backends))}
+	for i, b := range backends {
+		routes, err := parseRoutes(b.Routes)
+		if err != nil {
+			return nil, err
+		}
+		data.Backends[i] = Backend{
+			Name:        b.Name,
+			Host:        r.host(b.Name),
+			CNames:      b.CNames,
+			Routes:      routes,
+			Healthcheck: b.Healthcheck,
+			Opts:        b.Opts,
+		}
+	}
+	return &data, nil
+}
+
+// render writes the configuration file with the current backends and runs
+// the reload command. Nothing is done when the configuration didn't change
+// and its last reload succeeded.
+func (r *templateRouter) render() error {
+	renderMtx.Lock()
+	defer renderMtx.Unlock()
+	err := r.writeConfig()
+	if err != nil {
+		return &router.RouterError{Op: "render", Err: err}
+	}
+	return nil
+}
+
+func (r *templateRouter) writeConfig() error {
+	tmpl, err := r.parseTemplate()
+	if err != nil {
+		return err
+	}
+	data, err := r.data()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return err
+	}
+	current, err := ioutil.ReadFile(r.outputPath)
+	if err == nil && bytes.Equal(current, buf.Bytes()) && !pendingReloads[r.outputPath] {
+		return nil
+	}
+	file, err := ioutil.TempFile(filepath.Dir(r.outputPath), "."+filepath.Base(r.outputPath))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(buf.Bytes())
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(file.Name(), r.outputPath)
+	if err != nil {
+		return err
+	}
+	pendingReloads[r.outputPath] = true
+	err = r.reload()
+	if err != nil {
+		return err
+	}
+	delete(pendingReloads, r.outputPath)
+	return nil
+}
+
+func (r *templateRouter) reload() error {
+	if r.reloadCommand == "" {
+		return nil
+	}
+	out, err := exec.Command("/bin/sh", "-c", r.reloadCommand).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error running reload command %q: %s - output: %s", r.reloadCommand, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+const testTemplate = `{{range .Backends}}{{.Host}}{{range .CNames}} {{.}}{{end}}:{{range .Routes}} {{.Host}}{{end}}{{with .Healthcheck.Path}} hc={{.}}{{end}}
+{{end}}`
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	conn   *db.Storage
+	dir    string
+	output string
+	reload string
+}
+
+var _ = check.Suite(&S{})
+
+func init() {
+	base := &S{}
+	suite := &routertest.RouterSuite{
+		SetUpSuiteFunc:   base.SetUpSuite,
+		TearDownTestFunc: base.TearDownTest,
+	}
+	suite.SetUpTestFunc = func(c *check.C) {
+		config.Set("database:name", "router_generic_template_tests")
+		base.SetUpTest(c)
+		r, err := router.Get("template")
+		c.Assert(err, check.IsNil)
+		suite.Router = r
+	}
+	check.Suite(suite)
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("routers:template:type", "template")
+	config.Set("routers:template:domain", "template.example.com")
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "router_template_tests")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	dbtest.ClearAllCollections(s.conn.Collection("router_template").Database)
+	s.dir, err = ioutil.TempDir("", "router-template")
+	c.Assert(err, check.IsNil)
+	tmplPath := filepath.Join(s.dir, "proxy.conf.tmpl")
+	err = ioutil.WriteFile(tmplPath, []byte(testTemplate), 0644)
+	c.Assert(err, check.IsNil)
+	s.output = filepath.Join(s.dir, "proxy.conf")
+	s.reload = filepath.Join(s.dir, "reloads")
+	config.Set("routers:template:template", tmplPath)
+	config.Set("routers:template:output", s.output)
+	config.Set("routers:template:reload-command", "echo reload >> "+s.reload)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	os.RemoveAll(s.dir)
+	s.conn.Close()
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	s.conn, _ = db.Conn()
+	defer s.conn.Close()
+	s.conn.Collection("router_template").Database.DropDatabase()
+}
+
+func (s *S) reloads(c *check.C) int {
+	data, err := ioutil.ReadFile(s.reload)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	c.Assert(err, check.IsNil)
+	return len(data) / len("reload\n")
+}
+
+func (s *S) rendered(c *check.C) string {
+	data, err := ioutil.ReadFile(s.output)
+	c.Assert(err, check.IsNil)
+	return string(data)
+}
+
+func (s *S) TestCreateRouterRequiresTemplate(c *check.C) {
+	config.Unset("routers:template:template")
+	_, err := router.Get("template")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestRenderBackends(c *check.C) {
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("otherapp")
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.0.0.1:8080")
+	addr2, _ := url.Parse("http://10.0.0.2:8080")
+	err = r.AddRoutes("myapp", []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = r.SetCName("myapp.io", "myapp")
+	c.Assert(err, check.IsNil)
+	err = r.(router.CustomHealthcheckRouter).SetHealthcheck("myapp", router.HealthcheckData{Path: "/healthcheck", Status: 200})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.rendered(c), check.Equals, "myapp.template.example.com myapp.io: 10.0.0.1:8080 10.0.0.2:8080 hc=/healthcheck\n"+
+		"otherapp.template.example.com:\n")
+	err = r.RemoveRoute("myapp", addr1)
+	c.Assert(err, check.IsNil)
+	err = r.UnsetCName("myapp.io", "myapp")
+	c.Assert(err, check.IsNil)
+	err = r.RemoveBackend("otherapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.rendered(c), check.Equals, "myapp.template.example.com: 10.0.0.2:8080 hc=/healthcheck\n")
+}
+
+func (s *S) TestAddBackendInvalidOpts(c *check.C) {
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	optsRouter := r.(router.OptsRouter)
+	for _, opts := range []map[string]string{
+		{"listen": "80;\n}\nserver {"},
+		{"listen": "{{.Domain}}"},
+		{"list en": "80"},
+	} {
+		err = optsRouter.AddBackendOpts("myapp", opts)
+		c.Check(err, check.Equals, router.ErrInvalidOpts)
+	}
+	err = optsRouter.AddBackendOpts("myapp", map[string]string{"listen": "10.0.0.1:80", "proxy.timeout": "30s"})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.reloads(c), check.Equals, 1)
+}
+
+func (s *S) TestSetCNameInvalid(c *check.C) {
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	for _, cname := range []string{"myapp.io;", "myapp.io other.io", "myapp.io\n", "-myapp.io"} {
+		err = r.SetCName(cname, "myapp")
+		c.Check(err, check.Equals, router.ErrInvalidCName)
+	}
+	err = r.SetCName("*.myapp.io", "myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.rendered(c), check.Equals, "myapp.template.example.com *.myapp.io:\n")
+}
+
+func (s *S) TestRenderAfterSwap(c *check.C) {
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("app1")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("app2")
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.0.0.1:8080")
+	addr2, _ := url.Parse("http://10.0.0.2:8080")
+	err = r.AddRoute("app1", addr1)
+	c.Assert(err, check.IsNil)
+	err = r.AddRoute("app2", addr2)
+	c.Assert(err, check.IsNil)
+	err = r.Swap("app1", "app2")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.rendered(c), check.Equals, "app1.template.example.com: 10.0.0.2:8080\n"+
+		"app2.template.example.com: 10.0.0.1:8080\n")
+}
+
+func (s *S) TestReloadOnlyOnChanges(c *check.C) {
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.reloads(c), check.Equals, 1)
+	addr, _ := url.Parse("http://10.0.0.1:8080")
+	err = r.AddRoute("myapp", addr)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.reloads(c), check.Equals, 2)
+	err = r.AddRoutes("myapp", []*url.URL{addr})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.reloads(c), check.Equals, 2)
+}
+
+func (s *S) TestReloadFailure(c *check.C) {
+	config.Set("routers:template:reload-command", "echo invalid config >&2; exit 1")
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("myapp")
+	c.Assert(err, check.ErrorMatches, `(?s).*error running reload command.*invalid config.*`)
+	c.Assert(s.rendered(c), check.Equals, "myapp.template.example.com:\n")
+	config.Set("routers:template:reload-command", "echo reload >> "+s.reload)
+	r, err = router.Get("template")
+	c.Assert(err, check.IsNil)
+	addr, _ := url.Parse("http://10.0.0.1:8080")
+	err = r.AddRoutes("myapp", []*url.URL{})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.reloads(c), check.Equals, 1)
+	err = r.AddRoute("myapp", addr)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.reloads(c), check.Equals, 2)
+}
+
+func (s *S) TestInvalidTemplate(c *check.C) {
+	tmplPath, _ := config.GetString("routers:template:template")
+	err := ioutil.WriteFile(tmplPath, []byte("{{range .Backends}"), 0644)
+	c.Assert(err, check.IsNil)
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	err = r.(router.HealthChecker).HealthCheck()
+	c.Assert(err, check.NotNil)
+	err = r.AddBackend("myapp")
+	c.Assert(err, check.FitsTypeOf, &router.RouterError{})
+	_, err = os.Stat(s.output)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+	c.Assert(s.reloads(c), check.Equals, 0)
+}
+
+func (s *S) TestHealthCheck(c *check.C) {
+	r, err := router.Get("template")
+	c.Assert(err, check.IsNil)
+	err = r.(router.HealthChecker).HealthCheck()
+	c.Assert(err, check.IsNil)
+}
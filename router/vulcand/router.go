@@ -65,6 +65,12 @@ func (r *vulcandRouter) serverName(address string) string {
 	return fmt.Sprintf("tsuru_%x", md5.Sum([]byte(address)))
 }
 
+// serverCopyName returns the name of the n-th additional server pointing to
+// the given address, used to give the address a weight greater than one.
+func (r *vulcandRouter) serverCopyName(address string, n int) string {
+	return fmt.Sprintf("%s_%d", r.serverName(address), n)
+}
+
 func (r *vulcandRouter) AddBackend(name string) error {
 	backendName := r.backendName(name)
 	frontendName := r.frontendName(r.frontendHostname(name))
@@ -214,7 +220,7 @@ func (r *vulcandRouter) RemoveRoute(name string, address *url.URL) error {
 		}
 		return &router.RouterError{Err: err, Op: "remove-route"}
 	}
-	return nil
+	return r.removeServerCopies(serverKey.BackendKey, []*url.URL{address})
 }
 
 func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) error {
@@ -235,7 +241,7 @@ func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 			return &router.RouterError{Err: err, Op: "remove-route"}
 		}
 	}
-	return nil
+	return r.removeServerCopies(engine.BackendKey{Id: r.backendName(usedName)}, addresses)
 }
 
 func (r *vulcandRouter) SetCName(cname, name string) error {
@@ -307,10 +313,15 @@ func (r *vulcandRouter) Routes(name string) ([]*url.URL, error) {
 	if err != nil {
 		return nil, &router.RouterError{Err: err, Op: "routes"}
 	}
-	routes := make([]*url.URL, len(servers))
-	for i, server := range servers {
+	routes := make([]*url.URL, 0, len(servers))
+	seen := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		if seen[server.URL] {
+			continue
+		}
+		seen[server.URL] = true
 		parsedUrl, _ := url.Parse(server.URL)
-		routes[i] = parsedUrl
+		routes = append(routes, parsedUrl)
 	}
 	return routes, nil
 }
@@ -323,3 +334,197 @@ func (r *vulcandRouter) StartupMessage() (string, error) {
 func (r *vulcandRouter) HealthCheck() error {
 	return r.client.GetStatus()
 }
+
+// SetWeights changes the weight of the routes of the backend. Vulcand balances
+// requests evenly among the servers of a backend, so weights are implemented
+// by adding copies of the server pointing to the same address.
+func (r *vulcandRouter) SetWeights(name string, weights map[string]int) error {
+	for _, weight := range weights {
+		if weight < 1 {
+			return router.ErrInvalidWeight
+		}
+	}
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	backendKey := engine.BackendKey{Id: r.backendName(usedName)}
+	current, err := r.Weights(name)
+	if err != nil {
+		return err
+	}
+	for addr, currentWeight := range current {
+		weight := weights[addr]
+		if weight == 0 {
+			weight = 1
+		}
+		for n := currentWeight; n < weight; n++ {
+			server, err := engine.NewServer(r.serverCopyName(addr, n), addr)
+			if err != nil {
+				return &router.RouterError{Err: err, Op: "set-weights"}
+			}
+			err = r.client.UpsertServer(backendKey, *server, engine.NoTTL)
+			if err != nil {
+				return &router.RouterError{Err: err, Op: "set-weights"}
+			}
+		}
+		for n := weight; n < currentWeight; n++ {
+			err = r.client.DeleteServer(engine.ServerKey{Id: r.serverCopyName(addr, n), BackendKey: backendKey})
+			if err != nil {
+				if _, ok := err.(*engine.NotFoundError); ok {
+					continue
+				}
+				return &router.RouterError{Err: err, Op: "set-weights"}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) Weights(name string) (map[string]int, error) {
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := r.client.GetServers(engine.BackendKey{
+		Id: r.backendName(usedName),
+	})
+	if err != nil {
+		return nil, &router.RouterError{Err: err, Op: "weights"}
+	}
+	weights := make(map[string]int, len(servers))
+	for _, server := range servers {
+		weights[server.URL]++
+	}
+	return weights, nil
+}
+
+// AddCertificate upserts a vulcand host named after the cname, holding its
+// certificate and key.
+func (r *vulcandRouter) AddCertificate(cname, certificate, key string) error {
+	keyPair, err := engine.NewKeyPair([]byte(certificate), []byte(key))
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-certificate"}
+	}
+	host, err := engine.NewHost(cname, engine.HostSettings{KeyPair: keyPair})
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-certificate"}
+	}
+	err = r.client.UpsertHost(*host)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-certificate"}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) RemoveCertificate(cname string) error {
+	err := r.client.DeleteHost(engine.HostKey{Name: cname})
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); ok {
+			return router.ErrCertificateNotFound
+		}
+		return &router.RouterError{Err: err, Op: "remove-certificate"}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) GetCertificate(cname string) (string, error) {
+	host, err := r.client.GetHost(engine.HostKey{Name: cname})
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); ok {
+			return "", router.ErrCertificateNotFound
+		}
+		return "", &router.RouterError{Err: err, Op: "get-certificate"}
+	}
+	if host.Settings.KeyPair == nil {
+		return "", router.ErrCertificateNotFound
+	}
+	return string(host.Settings.KeyPair.Cert), nil
+}
+
+// challengeName returns the name of the frontend and of the backend sending
+// the ACME challenges of the cname to tsuru.
+func (r *vulcandRouter) challengeName(cname string) string {
+	return fmt.Sprintf("tsuru_acme_%s", cname)
+}
+
+// AddChallengeRoute adds a frontend matching the challenge path in the cname,
+// more specific than the frontend of the cname, pointing to a backend whose
+// only server is the address.
+func (r *vulcandRouter) AddChallengeRoute(cname string, address *url.URL) error {
+	name := r.challengeName(cname)
+	backend, err := engine.NewHTTPBackend(name, engine.HTTPBackendSettings{})
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-challenge-route"}
+	}
+	err = r.client.UpsertBackend(*backend)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-challenge-route"}
+	}
+	server, err := engine.NewServer(r.serverName(address.String()), address.String())
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-challenge-route"}
+	}
+	err = r.client.UpsertServer(engine.BackendKey{Id: name}, *server, engine.NoTTL)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-challenge-route"}
+	}
+	frontend, err := engine.NewHTTPFrontend(
+		route.NewMux(),
+		name,
+		backend.Id,
+		fmt.Sprintf(`Host(%q) && PathRegexp(%q)`, cname, router.ACMEChallengePath+".*"),
+		engine.HTTPFrontendSettings{},
+	)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-challenge-route"}
+	}
+	err = r.client.UpsertFrontend(*frontend, engine.NoTTL)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-challenge-route"}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) RemoveChallengeRoute(cname string) error {
+	name := r.challengeName(cname)
+	err := r.client.DeleteFrontend(engine.FrontendKey{Id: name})
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); ok {
+			return router.ErrChallengeRouteNotFound
+		}
+		return &router.RouterError{Err: err, Op: "remove-challenge-route"}
+	}
+	err = r.client.DeleteBackend(engine.BackendKey{Id: name})
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); ok {
+			return nil
+		}
+		return &router.RouterError{Err: err, Op: "remove-challenge-route"}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) removeServerCopies(backendKey engine.BackendKey, addresses []*url.URL) error {
+	servers, err := r.client.GetServers(backendKey)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "remove-route"}
+	}
+	toRemove := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		toRemove[addr.String()] = true
+	}
+	for _, server := range servers {
+		if !toRemove[server.URL] {
+			continue
+		}
+		err = r.client.DeleteServer(engine.ServerKey{Id: server.Id, BackendKey: backendKey})
+		if err != nil {
+			if _, ok := err.(*engine.NotFoundError); ok {
+				continue
+			}
+			return &router.RouterError{Err: err, Op: "remove-route"}
+		}
+	}
+	return nil
+}
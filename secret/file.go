@@ -0,0 +1,52 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tsuru/config"
+)
+
+func init() {
+	Register("file", createFileProvider)
+}
+
+// fileProvider reads secrets from a directory tree, where each secret is a
+// directory and each key a file inside it, like the secrets mounted by
+// orchestrators. A single trailing newline is removed from the values.
+type fileProvider struct {
+	baseDir string
+}
+
+func createFileProvider(configPrefix string) (SecretProvider, error) {
+	baseDir, err := config.GetString(configPrefix + ":base-dir")
+	if err != nil {
+		return nil, fmt.Errorf("%s:base-dir must be set", configPrefix)
+	}
+	return &fileProvider{baseDir: filepath.Clean(baseDir)}, nil
+}
+
+func (p *fileProvider) Get(path, key string) (string, error) {
+	if strings.Contains(key, "/") || key == "." || key == ".." {
+		return "", ErrSecretNotFound
+	}
+	file := filepath.Join(p.baseDir, filepath.FromSlash(path), key)
+	if !strings.HasPrefix(file, p.baseDir+string(filepath.Separator)) {
+		return "", ErrSecretNotFound
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createSecretsDir(c *check.C) string {
+	dir, err := ioutil.TempDir("", "secrets")
+	c.Assert(err, check.IsNil)
+	err = os.MkdirAll(filepath.Join(dir, "db", "prod"), 0700)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "db", "prod", "password"), []byte("s3cr3t\n"), 0600)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "outside"), []byte("outside"), 0600)
+	c.Assert(err, check.IsNil)
+	return dir
+}
+
+func (s *S) TestFileProvider(c *check.C) {
+	dir := s.createSecretsDir(c)
+	defer os.RemoveAll(dir)
+	config.Set("secrets:provider", "file")
+	config.Set("secrets:file:base-dir", filepath.Join(dir, "db"))
+	provider, err := GetProvider()
+	c.Assert(err, check.IsNil)
+	value, err := provider.Get("prod", "password")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	_, err = provider.Get("prod", "user")
+	c.Assert(err, check.Equals, ErrSecretNotFound)
+	_, err = provider.Get("dev", "password")
+	c.Assert(err, check.Equals, ErrSecretNotFound)
+	_, err = provider.Get("..", "outside")
+	c.Assert(err, check.Equals, ErrSecretNotFound)
+	_, err = provider.Get("prod", "../../outside")
+	c.Assert(err, check.Equals, ErrSecretNotFound)
+}
+
+func (s *S) TestFileProviderResolve(c *check.C) {
+	dir := s.createSecretsDir(c)
+	defer os.RemoveAll(dir)
+	config.Set("secrets:provider", "file")
+	config.Set("secrets:file:base-dir", dir)
+	value, err := Resolve("secret://db/prod#password", "db")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestFileProviderNoBaseDir(c *check.C) {
+	config.Set("secrets:provider", "file")
+	_, err := GetProvider()
+	c.Assert(err, check.ErrorMatches, `secrets:file:base-dir must be set`)
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+func init() {
+	Register("http", createHTTPProvider)
+}
+
+// httpProvider reads secrets from a key/value store with an HTTP API. The
+// secret in path is retrieved with GET <url>/<path>, which must respond with
+// a JSON object mapping keys to values. When a token is configured, it's sent
+// in the Authorization header.
+type httpProvider struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func createHTTPProvider(configPrefix string) (SecretProvider, error) {
+	storeURL, err := config.GetString(configPrefix + ":url")
+	if err != nil {
+		return nil, fmt.Errorf("%s:url must be set", configPrefix)
+	}
+	token, _ := config.GetString(configPrefix + ":token")
+	return &httpProvider{
+		url:    strings.TrimRight(storeURL, "/"),
+		token:  token,
+		client: tsuruNet.Dial5Full60ClientNoKeepAlive,
+	}, nil
+}
+
+func (p *httpProvider) Get(path, key string) (string, error) {
+	secretURL := p.url + (&url.URL{Path: "/" + path}).EscapedPath()
+	req, err := http.NewRequest("GET", secretURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "bearer "+p.token)
+	}
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotFound {
+		return "", ErrSecretNotFound
+	}
+	if rsp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(rsp.Body)
+		return "", fmt.Errorf("invalid response code %d from secret store: %s", rsp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var values map[string]string
+	err = json.NewDecoder(rsp.Body).Decode(&values)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse response from secret store: %s", err)
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
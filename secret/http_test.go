@@ -0,0 +1,90 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type fakeSecretStore struct {
+	secrets  map[string]map[string]string
+	requests []*http.Request
+}
+
+func (s *fakeSecretStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r)
+	if r.URL.Path == "/broken" {
+		http.Error(w, "store unavailable", http.StatusInternalServerError)
+		return
+	}
+	values, ok := s.secrets[r.URL.Path]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(values)
+}
+
+func (s *S) startSecretStore(c *check.C) (*fakeSecretStore, *httptest.Server) {
+	store := &fakeSecretStore{secrets: map[string]map[string]string{
+		"/db/prod": {"password": "s3cr3t"},
+	}}
+	server := httptest.NewServer(store)
+	config.Set("secrets:provider", "http")
+	config.Set("secrets:http:url", server.URL+"/")
+	return store, server
+}
+
+func (s *S) TestHTTPProvider(c *check.C) {
+	store, server := s.startSecretStore(c)
+	defer server.Close()
+	config.Set("secrets:http:token", "mytoken")
+	provider, err := GetProvider()
+	c.Assert(err, check.IsNil)
+	value, err := provider.Get("db/prod", "password")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	c.Assert(store.requests, check.HasLen, 1)
+	c.Assert(store.requests[0].Method, check.Equals, "GET")
+	c.Assert(store.requests[0].URL.Path, check.Equals, "/db/prod")
+	c.Assert(store.requests[0].Header.Get("Authorization"), check.Equals, "bearer mytoken")
+	_, err = provider.Get("db/prod", "user")
+	c.Assert(err, check.Equals, ErrSecretNotFound)
+	_, err = provider.Get("db/dev", "password")
+	c.Assert(err, check.Equals, ErrSecretNotFound)
+	_, err = provider.Get("broken", "password")
+	c.Assert(err, check.ErrorMatches, `invalid response code 500 from secret store: store unavailable`)
+}
+
+func (s *S) TestHTTPProviderResolve(c *check.C) {
+	store, server := s.startSecretStore(c)
+	defer server.Close()
+	value, err := Resolve("secret://db/prod#password", "db")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	c.Assert(store.requests[0].Header.Get("Authorization"), check.Equals, "")
+}
+
+func (s *S) TestHTTPProviderInvalidResponse(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer server.Close()
+	config.Set("secrets:provider", "http")
+	config.Set("secrets:http:url", server.URL)
+	_, err := Resolve("secret://db/prod#password", "db")
+	c.Assert(err, check.ErrorMatches, `unable to resolve secret://db/prod#password: unable to parse response from secret store: .*`)
+}
+
+func (s *S) TestHTTPProviderNoURL(c *check.C) {
+	config.Set("secrets:provider", "http")
+	_, err := GetProvider()
+	c.Assert(err, check.ErrorMatches, `secrets:http:url must be set`)
+}
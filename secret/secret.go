@@ -0,0 +1,130 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package secret provides interfaces and functions for resolving references
+// to values held by external secret stores, used in place of the literal
+// values of environment variables of apps.
+//
+// A reference has the form secret://<team>/<path>#<key>, where team/path
+// identifies a secret in the store and key one of the values of that secret.
+// The first segment of the path is the team owning the secret, only apps owned
+// by that team are allowed to use it. References are resolved by the
+// SecretProvider configured in secrets:provider.
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tsuru/config"
+)
+
+// ReferencePrefix is the prefix of values referencing a secret.
+const ReferencePrefix = "secret://"
+
+type providerFactory func(configPrefix string) (SecretProvider, error)
+
+var (
+	ErrSecretNotFound        = errors.New("secret not found")
+	ErrProviderNotConfigured = errors.New("secrets:provider is not configured")
+	ErrSecretNotAllowed      = errors.New("secret belongs to another team")
+)
+
+var providers = make(map[string]providerFactory)
+
+// SecretProvider is the basic interface of this package, it must be
+// implemented by secret stores.
+type SecretProvider interface {
+	// Get returns the value of key in the secret identified by path. It
+	// returns ErrSecretNotFound when either the secret or the key doesn't
+	// exist.
+	Get(path, key string) (string, error)
+}
+
+// Reference is a parsed reference to a value held by a secret store.
+type Reference struct {
+	Path string
+	Key  string
+}
+
+func (r Reference) String() string {
+	return ReferencePrefix + r.Path + "#" + r.Key
+}
+
+// Team returns the name of the team owning the referenced secret.
+func (r Reference) Team() string {
+	return strings.SplitN(r.Path, "/", 2)[0]
+}
+
+// Register registers a new secret provider.
+func Register(name string, factory providerFactory) {
+	providers[name] = factory
+}
+
+// GetProvider returns the secret provider configured in secrets:provider.
+// Its settings are read from secrets:<provider>.
+func GetProvider() (SecretProvider, error) {
+	name, err := config.GetString("secrets:provider")
+	if err != nil || name == "" {
+		return nil, ErrProviderNotConfigured
+	}
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret provider: %q", name)
+	}
+	return factory("secrets:" + name)
+}
+
+// IsReference tells whether the value is a reference to a secret.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, ReferencePrefix)
+}
+
+// ParseReference parses a reference in the form secret://<team>/<path>#<key>.
+func ParseReference(value string) (Reference, error) {
+	if !IsReference(value) {
+		return Reference{}, fmt.Errorf("invalid secret reference %q: must start with %s", value, ReferencePrefix)
+	}
+	ref := strings.TrimPrefix(value, ReferencePrefix)
+	idx := strings.LastIndex(ref, "#")
+	if idx == -1 {
+		return Reference{}, fmt.Errorf("invalid secret reference %q: must be in the form %s<team>/<path>#<key>", value, ReferencePrefix)
+	}
+	path, key := strings.Trim(ref[:idx], "/"), ref[idx+1:]
+	if !strings.Contains(path, "/") || key == "" {
+		return Reference{}, fmt.Errorf("invalid secret reference %q: must be in the form %s<team>/<path>#<key>", value, ReferencePrefix)
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == "" || part == "." || part == ".." {
+			return Reference{}, fmt.Errorf("invalid secret reference %q: invalid path", value)
+		}
+	}
+	return Reference{Path: path, Key: key}, nil
+}
+
+// Resolve returns the value referenced by value, if it's a reference to a
+// secret, or value itself otherwise. Secrets are only resolved for the team
+// owning them, ErrSecretNotAllowed is returned for any other team.
+func Resolve(value, team string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", err
+	}
+	if ref.Team() != team {
+		return "", fmt.Errorf("unable to resolve %s: %s", ref, ErrSecretNotAllowed)
+	}
+	provider, err := GetProvider()
+	if err != nil {
+		return "", err
+	}
+	resolved, err := provider.Get(ref.Path, ref.Key)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %s: %s", ref, err)
+	}
+	return resolved, nil
+}
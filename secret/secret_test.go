@@ -0,0 +1,116 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("secrets")
+}
+
+type fakeProvider struct {
+	secrets map[string]map[string]string
+}
+
+func (p *fakeProvider) Get(path, key string) (string, error) {
+	if path == "db/broken" {
+		return "", errors.New("store unavailable")
+	}
+	value, ok := p.secrets[path][key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func init() {
+	Register("fake", func(configPrefix string) (SecretProvider, error) {
+		return &fakeProvider{secrets: map[string]map[string]string{
+			"db/prod": {"password": "s3cr3t"},
+		}}, nil
+	})
+}
+
+func (s *S) TestIsReference(c *check.C) {
+	c.Assert(IsReference("secret://db/prod#password"), check.Equals, true)
+	c.Assert(IsReference("secret:/db/prod#password"), check.Equals, false)
+	c.Assert(IsReference("value"), check.Equals, false)
+}
+
+func (s *S) TestParseReference(c *check.C) {
+	ref, err := ParseReference("secret://db/prod#password")
+	c.Assert(err, check.IsNil)
+	c.Assert(ref, check.Equals, Reference{Path: "db/prod", Key: "password"})
+	c.Assert(ref.String(), check.Equals, "secret://db/prod#password")
+	c.Assert(ref.Team(), check.Equals, "db")
+	ref, err = ParseReference("secret:///db/prod/#pass#word")
+	c.Assert(err, check.IsNil)
+	c.Assert(ref, check.Equals, Reference{Path: "db/prod/#pass", Key: "word"})
+}
+
+func (s *S) TestParseReferenceInvalid(c *check.C) {
+	invalid := []string{
+		"db/prod#password",
+		"secret://db/prod",
+		"secret://db#password",
+		"secret://#password",
+		"secret://db/prod#",
+		"secret://db//prod#password",
+		"secret://db/../prod#password",
+		"secret://./prod#password",
+	}
+	for _, value := range invalid {
+		_, err := ParseReference(value)
+		c.Check(err, check.NotNil, check.Commentf("value: %s", value))
+	}
+}
+
+func (s *S) TestGetProvider(c *check.C) {
+	_, err := GetProvider()
+	c.Assert(err, check.Equals, ErrProviderNotConfigured)
+	config.Set("secrets:provider", "unknown")
+	_, err = GetProvider()
+	c.Assert(err, check.ErrorMatches, `unknown secret provider: "unknown"`)
+	config.Set("secrets:provider", "fake")
+	provider, err := GetProvider()
+	c.Assert(err, check.IsNil)
+	c.Assert(provider, check.FitsTypeOf, &fakeProvider{})
+}
+
+func (s *S) TestResolve(c *check.C) {
+	config.Set("secrets:provider", "fake")
+	value, err := Resolve("secret://db/prod#password", "db")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	value, err = Resolve("plain value", "db")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "plain value")
+}
+
+func (s *S) TestResolveErrors(c *check.C) {
+	_, err := Resolve("secret://db/prod#password", "db")
+	c.Assert(err, check.Equals, ErrProviderNotConfigured)
+	config.Set("secrets:provider", "fake")
+	_, err = Resolve("secret://db/prod#user", "db")
+	c.Assert(err, check.ErrorMatches, `unable to resolve secret://db/prod#user: secret not found`)
+	_, err = Resolve("secret://db/broken#user", "db")
+	c.Assert(err, check.ErrorMatches, `unable to resolve secret://db/broken#user: store unavailable`)
+	_, err = Resolve("secret://db/prod#password", "otherteam")
+	c.Assert(err, check.ErrorMatches, `unable to resolve secret://db/prod#password: secret belongs to another team`)
+	_, err = Resolve("secret://db/prod", "db")
+	c.Assert(err, check.ErrorMatches, `invalid secret reference .*`)
+}
@@ -0,0 +1,341 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/monsterqueue"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/periodic"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	deliveryTaskName = "webhook-delivery"
+
+	SignatureHeader = "X-Tsuru-Signature"
+	EventHeader     = "X-Tsuru-Event"
+	DeliveryHeader  = "X-Tsuru-Delivery"
+
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+
+	defaultMaxAttempts = 5
+)
+
+var (
+	deliveryRetryInterval = 10 * time.Second
+	deliveryClient        = net.Dial5Full60ClientNoKeepAlive
+
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// Event holds the data about an app lifecycle event, used to find the
+// matching webhooks and sent to them as the JSON payload.
+type Event struct {
+	Kind      string                 `json:"event"`
+	App       string                 `json:"app"`
+	TeamOwner string                 `json:"teamowner"`
+	Teams     []string               `json:"-"`
+	User      string                 `json:"user,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+type Attempt struct {
+	Timestamp  time.Time
+	Duration   time.Duration
+	StatusCode int
+	Error      string `bson:",omitempty" json:",omitempty"`
+}
+
+// Delivery is a notification of an event to a webhook, along with the
+// history of its delivery attempts. Pending deliveries whose previous attempt
+// failed are retried at NextAttempt.
+type Delivery struct {
+	ID          bson.ObjectId `bson:"_id"`
+	Webhook     string
+	Event       string
+	App         string
+	Payload     string
+	Timestamp   time.Time
+	Status      string
+	Attempts    []Attempt
+	NextAttempt time.Time `bson:",omitempty"`
+}
+
+// Notify enqueues a delivery of the event to every webhook it matches.
+func Notify(evt Event) error {
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now().UTC()
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var webhooks []Webhook
+	err = conn.Webhooks().Find(bson.M{"teamowner": bson.M{"$in": evt.Teams}}).All(&webhooks)
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	for _, w := range webhooks {
+		if !w.matches(&evt) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(evt)
+			if err != nil {
+				return err
+			}
+		}
+		d := Delivery{
+			ID:        bson.NewObjectId(),
+			Webhook:   w.Name,
+			Event:     evt.Kind,
+			App:       evt.App,
+			Payload:   string(payload),
+			Timestamp: evt.Timestamp,
+			Status:    DeliveryStatusPending,
+		}
+		err = conn.WebhookDeliveries().Insert(d)
+		if err != nil {
+			return err
+		}
+		err = enqueueDelivery(d.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDeliveries returns the delivery history of a webhook, most recent
+// first.
+func ListDeliveries(webhook string, skip, limit int) ([]Delivery, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := conn.WebhookDeliveries().Find(bson.M{"webhook": webhook}).Sort("-timestamp")
+	if skip != 0 {
+		query = query.Skip(skip)
+	}
+	if limit != 0 {
+		query = query.Limit(limit)
+	}
+	var deliveries []Delivery
+	err = query.All(&deliveries)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func getDelivery(id bson.ObjectId) (*Delivery, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var d Delivery
+	err = conn.WebhookDeliveries().FindId(id).One(&d)
+	if err == mgo.ErrNotFound {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// addAttempt records an attempt of the delivery. Failed attempts of pending
+// deliveries schedule the next one, waiting a bit longer after each of them.
+func (d *Delivery) addAttempt(attempt Attempt, status string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	d.Attempts = append(d.Attempts, attempt)
+	d.Status = status
+	d.NextAttempt = time.Time{}
+	if status == DeliveryStatusPending {
+		d.NextAttempt = time.Now().UTC().Add(time.Duration(len(d.Attempts)) * deliveryRetryInterval)
+	}
+	return conn.WebhookDeliveries().UpdateId(d.ID, bson.M{
+		"$push": bson.M{"attempts": attempt},
+		"$set":  bson.M{"status": status, "nextattempt": d.NextAttempt},
+	})
+}
+
+// NewDeliveryRetrier returns a loop enqueueing again the pending deliveries
+// whose next attempt is due.
+func NewDeliveryRetrier() *periodic.Loop {
+	return &periodic.Loop{
+		Name:     "webhook delivery retrier",
+		Interval: deliveryRetryInterval,
+		Task:     retryDeliveries,
+	}
+}
+
+// retryDeliveries enqueues the due deliveries. Each of them is claimed by
+// clearing its next attempt, so only one API instance enqueues it.
+func retryDeliveries() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var deliveries []Delivery
+	query := bson.M{
+		"status":      DeliveryStatusPending,
+		"nextattempt": bson.M{"$gt": time.Time{}, "$lte": time.Now().UTC()},
+	}
+	err = conn.WebhookDeliveries().Find(query).Select(bson.M{"_id": 1, "nextattempt": 1}).All(&deliveries)
+	if err != nil {
+		return fmt.Errorf("error getting deliveries: %s", err)
+	}
+	for _, d := range deliveries {
+		err = conn.WebhookDeliveries().Update(
+			bson.M{"_id": d.ID, "nextattempt": d.NextAttempt},
+			bson.M{"$set": bson.M{"nextattempt": time.Time{}}},
+		)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			log.Errorf("[webhook] unable to claim delivery retry %s: %s", d.ID.Hex(), err)
+			continue
+		}
+		err = enqueueDelivery(d.ID)
+		if err != nil {
+			log.Errorf("[webhook] unable to enqueue delivery retry %s: %s", d.ID.Hex(), err)
+			conn.WebhookDeliveries().UpdateId(d.ID, bson.M{"$set": bson.M{"nextattempt": d.NextAttempt}})
+		}
+	}
+	return nil
+}
+
+// Sign returns the value of the signature header for the given payload:
+// the hex encoded HMAC-SHA256 of the payload, keyed by the webhook secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Delivery) send(w *Webhook) Attempt {
+	attempt := Attempt{Timestamp: time.Now().UTC()}
+	payload := []byte(d.Payload)
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tsuru-webhook")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID.Hex())
+	req.Header.Set(SignatureHeader, Sign(w.Secret, payload))
+	rsp, err := deliveryClient.Do(req)
+	attempt.Duration = time.Since(attempt.Timestamp)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	rsp.Body.Close()
+	attempt.StatusCode = rsp.StatusCode
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status code: %d", rsp.StatusCode)
+	}
+	return attempt
+}
+
+func maxAttempts() int {
+	attempts, _ := config.GetInt("webhooks:max-attempts")
+	if attempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return attempts
+}
+
+type deliveryTask struct{}
+
+func (t *deliveryTask) Name() string {
+	return deliveryTaskName
+}
+
+func (t *deliveryTask) Run(job monsterqueue.Job) {
+	params := job.Parameters()
+	id, ok := params["id"].(string)
+	if !ok || !bson.IsObjectIdHex(id) {
+		job.Error(errors.New("invalid parameters, expected id"))
+		return
+	}
+	d, err := getDelivery(bson.ObjectIdHex(id))
+	if err != nil {
+		job.Error(err)
+		return
+	}
+	w, err := Find(d.Webhook)
+	if err != nil {
+		job.Error(err)
+		return
+	}
+	attempt := d.send(w)
+	status := DeliveryStatusSucceeded
+	if attempt.Error != "" {
+		status = DeliveryStatusPending
+		if len(d.Attempts)+1 >= maxAttempts() {
+			status = DeliveryStatusFailed
+		}
+	}
+	err = d.addAttempt(attempt, status)
+	if err != nil {
+		log.Errorf("[webhook] unable to record delivery attempt %s: %s", id, err)
+	}
+	if attempt.Error == "" {
+		job.Success(nil)
+		return
+	}
+	job.Error(errors.New(attempt.Error))
+}
+
+// RegisterQueueTask registers the webhook delivery task in the tsuru queue.
+func RegisterQueueTask() error {
+	q, err := queue.Queue()
+	if err != nil {
+		return err
+	}
+	return q.RegisterTask(&deliveryTask{})
+}
+
+func enqueueDelivery(id bson.ObjectId) error {
+	q, err := queue.Queue()
+	if err != nil {
+		return err
+	}
+	_, err = q.Enqueue(deliveryTaskName, monsterqueue.JobParams{"id": id.Hex()})
+	return err
+}
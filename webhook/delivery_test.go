@@ -0,0 +1,185 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type hookServer struct {
+	sync.Mutex
+	requests []receivedRequest
+	statuses []int
+}
+
+func (h *hookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	h.requests = append(h.requests, receivedRequest{header: r.Header, body: body})
+	status := http.StatusOK
+	if len(h.statuses) > 0 {
+		status = h.statuses[0]
+		if len(h.statuses) > 1 {
+			h.statuses = h.statuses[1:]
+		}
+	}
+	w.WriteHeader(status)
+}
+
+func (s *S) TestSign(c *check.C) {
+	c.Assert(Sign("key", []byte("The quick brown fox jumps over the lazy dog")), check.Equals,
+		"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8")
+}
+
+func (s *S) TestNotify(c *check.C) {
+	h := &hookServer{}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	err := Create(&Webhook{Name: "ci", TeamOwner: "team1", URL: srv.URL, Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	evt := Event{
+		Kind:      EventDeploy,
+		App:       "myapp",
+		TeamOwner: "team1",
+		Teams:     []string{"team1"},
+		User:      "me@me.com",
+		Data:      map[string]interface{}{"image": "tsuru/app-myapp:v1"},
+	}
+	err = Notify(evt)
+	c.Assert(err, check.IsNil)
+	err = queue.TestingWaitQueueTasks(1, 10*time.Second)
+	c.Assert(err, check.IsNil)
+	deliveries, err := ListDeliveries("ci", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 1)
+	d := deliveries[0]
+	c.Assert(d.Status, check.Equals, DeliveryStatusSucceeded)
+	c.Assert(d.Event, check.Equals, EventDeploy)
+	c.Assert(d.App, check.Equals, "myapp")
+	c.Assert(d.Attempts, check.HasLen, 1)
+	c.Assert(d.Attempts[0].StatusCode, check.Equals, http.StatusOK)
+	c.Assert(d.Attempts[0].Error, check.Equals, "")
+	c.Assert(h.requests, check.HasLen, 1)
+	req := h.requests[0]
+	c.Assert(string(req.body), check.Equals, d.Payload)
+	c.Assert(req.header.Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(req.header.Get(EventHeader), check.Equals, EventDeploy)
+	c.Assert(req.header.Get(DeliveryHeader), check.Equals, d.ID.Hex())
+	c.Assert(req.header.Get(SignatureHeader), check.Equals, Sign("s3cr3t", req.body))
+	var payload map[string]interface{}
+	err = json.Unmarshal(req.body, &payload)
+	c.Assert(err, check.IsNil)
+	c.Assert(payload["event"], check.Equals, EventDeploy)
+	c.Assert(payload["app"], check.Equals, "myapp")
+	c.Assert(payload["teamowner"], check.Equals, "team1")
+	c.Assert(payload["user"], check.Equals, "me@me.com")
+	c.Assert(payload["data"], check.DeepEquals, map[string]interface{}{"image": "tsuru/app-myapp:v1"})
+}
+
+func (s *S) TestNotifyFiltered(c *check.C) {
+	err := Create(&Webhook{Name: "other-team", TeamOwner: "team2", URL: "http://a.com"})
+	c.Assert(err, check.IsNil)
+	err = Create(&Webhook{
+		Name:        "other-event",
+		TeamOwner:   "team1",
+		URL:         "http://a.com",
+		EventFilter: EventFilter{Events: []string{EventRestart}},
+	})
+	c.Assert(err, check.IsNil)
+	err = Notify(Event{Kind: EventDeploy, App: "myapp", TeamOwner: "team1", Teams: []string{"team1"}})
+	c.Assert(err, check.IsNil)
+	for _, name := range []string{"other-team", "other-event"} {
+		deliveries, err := ListDeliveries(name, 0, 0)
+		c.Assert(err, check.IsNil)
+		c.Assert(deliveries, check.HasLen, 0)
+	}
+}
+
+// waitRetry waits for the first attempt of a delivery, then enqueues and
+// waits for its retry.
+func (s *S) waitRetry(c *check.C) {
+	err := queue.TestingWaitQueueTasks(1, 10*time.Second)
+	c.Assert(err, check.IsNil)
+	err = RegisterQueueTask()
+	c.Assert(err, check.IsNil)
+	time.Sleep(10 * time.Millisecond)
+	err = retryDeliveries()
+	c.Assert(err, check.IsNil)
+	err = queue.TestingWaitQueueTasks(1, 10*time.Second)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestNotifyRetry(c *check.C) {
+	h := &hookServer{statuses: []int{http.StatusInternalServerError, http.StatusOK}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	w := Webhook{Name: "ci", TeamOwner: "team1", URL: srv.URL}
+	err := Create(&w)
+	c.Assert(err, check.IsNil)
+	err = Notify(Event{Kind: EventRestart, App: "myapp", TeamOwner: "team1", Teams: []string{"team1"}})
+	c.Assert(err, check.IsNil)
+	s.waitRetry(c)
+	deliveries, err := ListDeliveries("ci", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 1)
+	c.Assert(deliveries[0].Status, check.Equals, DeliveryStatusSucceeded)
+	c.Assert(deliveries[0].NextAttempt.IsZero(), check.Equals, true)
+	c.Assert(deliveries[0].Attempts, check.HasLen, 2)
+	c.Assert(deliveries[0].Attempts[0].StatusCode, check.Equals, http.StatusInternalServerError)
+	c.Assert(deliveries[0].Attempts[0].Error, check.Equals, "unexpected status code: 500")
+	c.Assert(deliveries[0].Attempts[1].StatusCode, check.Equals, http.StatusOK)
+	c.Assert(h.requests, check.HasLen, 2)
+	c.Assert(h.requests[0].header.Get(SignatureHeader), check.Equals, Sign(w.Secret, h.requests[0].body))
+}
+
+func (s *S) TestRetryDeliveriesNotDue(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	next := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+	d := Delivery{ID: bson.NewObjectId(), Webhook: "ci", Status: DeliveryStatusPending, NextAttempt: next}
+	err = conn.WebhookDeliveries().Insert(d)
+	c.Assert(err, check.IsNil)
+	err = retryDeliveries()
+	c.Assert(err, check.IsNil)
+	stored, err := getDelivery(d.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.NextAttempt.Equal(next), check.Equals, true)
+}
+
+func (s *S) TestNotifyMaxAttempts(c *check.C) {
+	config.Set("webhooks:max-attempts", 2)
+	h := &hookServer{statuses: []int{http.StatusInternalServerError}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	err := Create(&Webhook{Name: "ci", TeamOwner: "team1", URL: srv.URL})
+	c.Assert(err, check.IsNil)
+	err = Notify(Event{Kind: EventDelete, App: "myapp", TeamOwner: "team1", Teams: []string{"team1"}})
+	c.Assert(err, check.IsNil)
+	s.waitRetry(c)
+	deliveries, err := ListDeliveries("ci", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 1)
+	c.Assert(deliveries[0].Status, check.Equals, DeliveryStatusFailed)
+	c.Assert(deliveries[0].Attempts, check.HasLen, 2)
+	c.Assert(h.requests, check.HasLen, 2)
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_webhook_tests")
+	config.Set("queue:mongo-url", "127.0.0.1:27017")
+	config.Set("queue:mongo-database", "tsuru_webhook_queue_tests")
+	config.Set("queue:mongo-polling-interval", 0.01)
+	deliveryRetryInterval = time.Millisecond
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = dbtest.ClearAllCollections(conn.Webhooks().Database)
+	c.Assert(err, check.IsNil)
+	queue.ResetQueue()
+	err = RegisterQueueTask()
+	c.Assert(err, check.IsNil)
+	config.Unset("webhooks:max-attempts")
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	queue.ResetQueue()
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Webhooks().Database.DropDatabase()
+}
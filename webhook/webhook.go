@@ -0,0 +1,241 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package webhook provides outgoing webhooks, notifying external systems
+// (CI servers, chat rooms, etc.) about events in the lifecycle of apps.
+//
+// Each webhook belongs to a team and is triggered only by apps the team has
+// access to, optionally narrowed by an event filter. Deliveries are signed
+// with the webhook secret, sent through the tsuru queue and retried on
+// failures, with every attempt recorded in the delivery history.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	EventDeploy      = "app.deploy"
+	EventRollback    = "app.rollback"
+	EventRestart     = "app.restart"
+	EventAddUnits    = "app.unit.add"
+	EventRemoveUnits = "app.unit.remove"
+	EventDelete      = "app.delete"
+)
+
+// Events lists all the event names that may be used in webhook filters.
+var Events = []string{
+	EventDeploy,
+	EventRollback,
+	EventRestart,
+	EventAddUnits,
+	EventRemoveUnits,
+	EventDelete,
+}
+
+var (
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrWebhookAlreadyExists = errors.New("webhook already exists")
+	ErrNameRequired         = errors.New("webhook name is required")
+	ErrTeamOwnerRequired    = errors.New("webhook team owner is required")
+	ErrInvalidURL           = errors.New("webhook url must be a valid http or https url")
+	ErrInvalidEvent         = errors.New("invalid event in webhook filter")
+	ErrSecretRequired       = errors.New("webhook secret is required")
+)
+
+// EventFilter narrows the events that trigger a webhook. Empty fields match
+// everything, so a webhook with an empty filter is triggered by every event
+// in every app of its team.
+type EventFilter struct {
+	Events []string `bson:",omitempty"`
+	Apps   []string `bson:",omitempty"`
+	Teams  []string `bson:",omitempty"`
+}
+
+type Webhook struct {
+	Name        string `bson:"_id"`
+	Description string
+	TeamOwner   string
+	URL         string
+	Secret      string `json:"-"`
+	EventFilter EventFilter
+}
+
+func (w *Webhook) validate() error {
+	if w.Name == "" {
+		return ErrNameRequired
+	}
+	if w.TeamOwner == "" {
+		return ErrTeamOwnerRequired
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	for _, e := range w.EventFilter.Events {
+		if !contains(Events, e) {
+			return ErrInvalidEvent
+		}
+	}
+	if w.Secret == "" {
+		return ErrSecretRequired
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	var b [32]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// matches returns whether the webhook should be triggered by the given event.
+func (w *Webhook) matches(evt *Event) bool {
+	if !contains(evt.Teams, w.TeamOwner) {
+		return false
+	}
+	f := w.EventFilter
+	if len(f.Events) > 0 && !contains(f.Events, evt.Kind) {
+		return false
+	}
+	if len(f.Apps) > 0 && !contains(f.Apps, evt.App) {
+		return false
+	}
+	if len(f.Teams) > 0 && !contains(f.Teams, evt.TeamOwner) {
+		return false
+	}
+	return true
+}
+
+// Create stores a new webhook, validating its fields. When the webhook has
+// no secret, a random one is generated and set in w, so it can be handed
+// to the user.
+func Create(w *Webhook) error {
+	if w.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+	err := w.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Webhooks().Insert(w)
+	if mgo.IsDup(err) {
+		return ErrWebhookAlreadyExists
+	}
+	return err
+}
+
+// Update replaces the stored webhook with the same name. An empty secret
+// keeps the current one.
+func Update(w Webhook) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if w.Secret == "" {
+		var current Webhook
+		err = conn.Webhooks().FindId(w.Name).One(&current)
+		if err == mgo.ErrNotFound {
+			return ErrWebhookNotFound
+		}
+		if err != nil {
+			return err
+		}
+		w.Secret = current.Secret
+	}
+	err = w.validate()
+	if err != nil {
+		return err
+	}
+	err = conn.Webhooks().UpdateId(w.Name, w)
+	if err == mgo.ErrNotFound {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// Delete removes the webhook and its delivery history.
+func Delete(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Webhooks().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.WebhookDeliveries().RemoveAll(bson.M{"webhook": name})
+	return err
+}
+
+// Find returns the webhook with the given name.
+func Find(name string) (*Webhook, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var w Webhook
+	err = conn.Webhooks().FindId(name).One(&w)
+	if err == mgo.ErrNotFound {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// List returns the webhooks owned by the given teams. A nil list of teams
+// returns all webhooks.
+func List(teams []string) ([]Webhook, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var query bson.M
+	if teams != nil {
+		query = bson.M{"teamowner": bson.M{"$in": teams}}
+	}
+	var webhooks []Webhook
+	err = conn.Webhooks().Find(query).Sort("_id").All(&webhooks)
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
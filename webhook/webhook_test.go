@@ -0,0 +1,158 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestCreate(c *check.C) {
+	w := Webhook{
+		Name:        "ci",
+		Description: "notify ci",
+		TeamOwner:   "team1",
+		URL:         "http://ci.example.com/hook",
+		Secret:      "s3cr3t",
+		EventFilter: EventFilter{Events: []string{EventDeploy}, Apps: []string{"myapp"}},
+	}
+	err := Create(&w)
+	c.Assert(err, check.IsNil)
+	dbHook, err := Find("ci")
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbHook, check.DeepEquals, w)
+}
+
+func (s *S) TestCreateGeneratesSecret(c *check.C) {
+	w := Webhook{Name: "ci", TeamOwner: "team1", URL: "http://ci.example.com"}
+	err := Create(&w)
+	c.Assert(err, check.IsNil)
+	c.Assert(w.Secret, check.HasLen, 64)
+	dbHook, err := Find("ci")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbHook.Secret, check.Equals, w.Secret)
+	other := Webhook{Name: "ci2", TeamOwner: "team1", URL: "http://ci.example.com"}
+	err = Create(&other)
+	c.Assert(err, check.IsNil)
+	c.Assert(other.Secret, check.Not(check.Equals), w.Secret)
+}
+
+func (s *S) TestCreateAlreadyExists(c *check.C) {
+	w := Webhook{Name: "ci", TeamOwner: "team1", URL: "http://ci.example.com"}
+	err := Create(&w)
+	c.Assert(err, check.IsNil)
+	err = Create(&w)
+	c.Assert(err, check.Equals, ErrWebhookAlreadyExists)
+}
+
+func (s *S) TestCreateValidation(c *check.C) {
+	tests := []struct {
+		w   Webhook
+		err error
+	}{
+		{Webhook{TeamOwner: "team1", URL: "http://a.com"}, ErrNameRequired},
+		{Webhook{Name: "ci", URL: "http://a.com"}, ErrTeamOwnerRequired},
+		{Webhook{Name: "ci", TeamOwner: "team1"}, ErrInvalidURL},
+		{Webhook{Name: "ci", TeamOwner: "team1", URL: "ftp://a.com"}, ErrInvalidURL},
+		{Webhook{Name: "ci", TeamOwner: "team1", URL: "http://"}, ErrInvalidURL},
+		{Webhook{Name: "ci", TeamOwner: "team1", URL: "http://a.com", EventFilter: EventFilter{Events: []string{"app.explode"}}}, ErrInvalidEvent},
+	}
+	for _, t := range tests {
+		c.Check(Create(&t.w), check.Equals, t.err)
+	}
+	hooks, err := List(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(hooks, check.HasLen, 0)
+}
+
+func (s *S) TestUpdate(c *check.C) {
+	err := Create(&Webhook{Name: "ci", TeamOwner: "team1", URL: "http://ci.example.com", Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	err = Update(Webhook{Name: "ci", TeamOwner: "team2", URL: "https://ci2.example.com"})
+	c.Assert(err, check.IsNil)
+	dbHook, err := Find("ci")
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbHook, check.DeepEquals, Webhook{
+		Name:      "ci",
+		TeamOwner: "team2",
+		URL:       "https://ci2.example.com",
+		Secret:    "s3cr3t",
+	})
+}
+
+func (s *S) TestUpdateNotFound(c *check.C) {
+	err := Update(Webhook{Name: "ci", TeamOwner: "team1", URL: "http://ci.example.com"})
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+	err = Update(Webhook{Name: "ci", TeamOwner: "team1", URL: "http://ci.example.com", Secret: "x"})
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestDelete(c *check.C) {
+	err := Create(&Webhook{Name: "ci", TeamOwner: "team1", URL: "http://ci.example.com"})
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.WebhookDeliveries().Insert(Delivery{ID: bson.NewObjectId(), Webhook: "ci"}, Delivery{ID: bson.NewObjectId(), Webhook: "other"})
+	c.Assert(err, check.IsNil)
+	err = Delete("ci")
+	c.Assert(err, check.IsNil)
+	_, err = Find("ci")
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+	n, err := conn.WebhookDeliveries().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+}
+
+func (s *S) TestDeleteNotFound(c *check.C) {
+	err := Delete("ci")
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestList(c *check.C) {
+	for _, w := range []Webhook{
+		{Name: "w3", TeamOwner: "team1", URL: "http://a.com"},
+		{Name: "w1", TeamOwner: "team2", URL: "http://a.com"},
+		{Name: "w2", TeamOwner: "team3", URL: "http://a.com"},
+	} {
+		err := Create(&w)
+		c.Assert(err, check.IsNil)
+	}
+	hooks, err := List(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(hooks, check.HasLen, 3)
+	c.Assert(hooks[0].Name, check.Equals, "w1")
+	c.Assert(hooks[2].Name, check.Equals, "w3")
+	hooks, err = List([]string{"team1", "team3"})
+	c.Assert(err, check.IsNil)
+	c.Assert(hooks, check.HasLen, 2)
+	c.Assert(hooks[0].Name, check.Equals, "w2")
+	c.Assert(hooks[1].Name, check.Equals, "w3")
+	hooks, err = List([]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(hooks, check.HasLen, 0)
+}
+
+func (s *S) TestMatches(c *check.C) {
+	evt := &Event{Kind: EventDeploy, App: "myapp", TeamOwner: "team1", Teams: []string{"team1", "team2"}}
+	tests := []struct {
+		w        Webhook
+		expected bool
+	}{
+		{Webhook{TeamOwner: "team1"}, true},
+		{Webhook{TeamOwner: "team2"}, true},
+		{Webhook{TeamOwner: "team3"}, false},
+		{Webhook{TeamOwner: "team2", EventFilter: EventFilter{Events: []string{EventDeploy, EventRollback}}}, true},
+		{Webhook{TeamOwner: "team2", EventFilter: EventFilter{Events: []string{EventRestart}}}, false},
+		{Webhook{TeamOwner: "team2", EventFilter: EventFilter{Apps: []string{"myapp"}}}, true},
+		{Webhook{TeamOwner: "team2", EventFilter: EventFilter{Apps: []string{"otherapp"}}}, false},
+		{Webhook{TeamOwner: "team2", EventFilter: EventFilter{Teams: []string{"team1"}}}, true},
+		{Webhook{TeamOwner: "team2", EventFilter: EventFilter{Teams: []string{"team2"}}}, false},
+	}
+	for i, t := range tests {
+		c.Check(t.w.matches(evt), check.Equals, t.expected, check.Commentf("test %d", i))
+	}
+}